@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/admin"
+	"goproduct/internal/agent"
+	"goproduct/internal/conversations"
+	"goproduct/internal/datadir"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/tracing"
+)
+
+const claudeExport = `[{
+	"uuid": "conv-1",
+	"name": "Launch plan",
+	"created_at": "2025-01-02T15:04:05Z",
+	"chat_messages": [
+		{"uuid": "m1", "sender": "human", "text": "When do we launch?", "created_at": "2025-01-02T15:04:05Z"},
+		{"uuid": "m2", "sender": "assistant", "text": "In March.", "created_at": "2025-01-02T15:04:10Z"}
+	]
+}]`
+
+// newAdminServer registers the admin commands for an agent of the built-in persona and
+// a temporary data directory
+func newAdminServer(t *testing.T, store knowledge.Store) (*admin.Server, *agent.Agent, *datadir.Dir) {
+	t.Helper()
+	personas, err := loadPersonas(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatalf("Failed to load personas: %v", err)
+	}
+	agentInstance := agent.NewAgent(personas[0].Persona(nil))
+	dataDir := datadir.New(t.TempDir())
+	server := admin.NewServer(filepath.Join(t.TempDir(), "admin.sock"))
+	registerAdminCommands(server, tracing.NewEnhancedTracer(tracing.NewNoopTracer(), "test"), store,
+		knowledge.NewAccessTracker(store, time.Minute), agentInstance, dataDir)
+	return server, agentInstance, dataDir
+}
+
+func TestAdminImport(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	server, _, _ := newAdminServer(t, store)
+
+	path := filepath.Join(t.TempDir(), "conversations.json")
+	if err := os.WriteFile(path, []byte(claudeExport), 0600); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+	if reply := server.Execute("import claude " + path + " Alex Andy"); !strings.Contains(reply, "Imported 2 messages of 1 conversations") {
+		t.Fatalf("Expected the export to be imported, got %q", reply)
+	}
+	if reply := server.Execute("import claude " + path); !strings.Contains(reply, "2 already imported") {
+		t.Errorf("Expected a second import to skip the messages, got %q", reply)
+	}
+
+	// The messages are in the conversation history of the human
+	history, err := conversations.NewRepository(store).ListConversations("Alex", conversations.TimeRange{})
+	if err != nil || len(history) != 1 || history[0].Title != "Launch plan" || history[0].MessageCount != 2 {
+		t.Errorf("Expected the imported conversation in the history, got %+v, %v", history, err)
+	}
+}
+
+func TestAdminPersonasReload(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	server, agentInstance, dataDir := newAdminServer(t, store)
+
+	if err := os.MkdirAll(dataDir.Personas(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir.Personas(), "andy.yaml"), []byte("name: Andy\nrole: Lead\nsystem_prompt: You lead.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if reply := server.Execute("personas reload"); !strings.Contains(reply, "Persona Andy reloaded from "+dataDir.Personas()) {
+		t.Fatalf("Expected the persona to be reloaded, got %q", reply)
+	}
+	if agentInstance.Persona.Role != "Lead" || agentInstance.Persona.SystemPrompt != "You lead." {
+		t.Errorf("Expected the agent to take on the reloaded persona, got %+v", agentInstance.Persona)
+	}
+
+	// A broken definition leaves the agent as it is
+	os.WriteFile(filepath.Join(dataDir.Personas(), "andy.yaml"), []byte("name: Andy\n"), 0o644)
+	if reply := server.Execute("personas reload"); strings.Contains(reply, "reloaded") || agentInstance.Persona.Role != "Lead" {
+		t.Errorf("Expected an invalid persona to be refused, got %q and %+v", reply, agentInstance.Persona)
+	}
+}
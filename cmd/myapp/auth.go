@@ -0,0 +1,53 @@
+package main
+
+import (
+	"goproduct/internal/auth"
+	"goproduct/internal/config"
+	"strings"
+)
+
+// newAuthenticator creates the authenticator of the configured providers, nil if there
+// are none
+func newAuthenticator(cfg config.AuthConfig) (*auth.Authenticator, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	var providers []auth.Provider
+	if len(cfg.Users) > 0 {
+		local := auth.NewLocalProvider()
+		for username, user := range cfg.Users {
+			if err := local.SetPassword(username, user.Password, user.EntityID); err != nil {
+				return nil, err
+			}
+		}
+		providers = append(providers, local)
+	}
+	for _, provider := range cfg.OIDC {
+		providers = append(providers, auth.NewOIDCProvider(provider.Name, provider.Issuer, oauthConfig(provider.OAuthClientConfig)))
+	}
+	if cfg.GitHub.ClientID != "" {
+		providers = append(providers, auth.NewGitHubProvider(oauthConfig(cfg.GitHub)))
+	}
+
+	// Email addresses are looked up in lower case
+	identities := make(map[string]string, len(cfg.Identities))
+	for key, entityID := range cfg.Identities {
+		if strings.Contains(key, "@") {
+			key = strings.ToLower(key)
+		}
+		identities[key] = entityID
+	}
+	identityMap := auth.IdentityMap{Identities: identities, AllowEmail: cfg.AllowEmail}
+	sessions := auth.NewSessionManager(cfg.AccessTTL, cfg.RefreshTTL)
+	return auth.NewAuthenticator(sessions, identityMap.Resolve, providers...), nil
+}
+
+// oauthConfig converts the registration of an OAuth client
+func oauthConfig(cfg config.OAuthClientConfig) auth.OAuthConfig {
+	return auth.OAuthConfig{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       cfg.Scopes,
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"goproduct/internal/auth"
+	"goproduct/internal/config"
+)
+
+func TestNewAuthenticator(t *testing.T) {
+	if authenticator, err := newAuthenticator(config.AuthConfig{}); err != nil || authenticator != nil {
+		t.Fatalf("Expected no authenticator without providers, got %v, %v", authenticator, err)
+	}
+
+	authenticator, err := newAuthenticator(config.AuthConfig{
+		Users: map[string]config.AuthUserConfig{"alice": {Password: "s3cret", EntityID: "alice"}},
+		OIDC: []config.OIDCConfig{{Name: "google", Issuer: "https://accounts.google.com",
+			OAuthClientConfig: config.OAuthClientConfig{ClientID: "app", RedirectURL: "https://host/api/auth/google/callback"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+	if _, ok := authenticator.Provider("google"); !ok {
+		t.Error("Expected the OIDC provider")
+	}
+	session, err := authenticator.Login(context.Background(), auth.ProviderLocal, auth.Credentials{Username: "alice", Password: "s3cret"})
+	if err != nil || session.EntityID != "alice" {
+		t.Fatalf("Expected alice to log in, got %+v, %v", session, err)
+	}
+	if _, err := authenticator.Login(context.Background(), auth.ProviderLocal, auth.Credentials{Username: "alice", Password: "wrong"}); err == nil {
+		t.Error("Expected a wrong password to be refused")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestComposeOnBehalfOfUser tests drafting a message with the agent and sending it after confirmation
+func TestComposeOnBehalfOfUser(t *testing.T) {
+	os.Setenv("LLM_TYPE", "echo")
+	os.Setenv("LLM_DELAY", "0")
+	defer func() {
+		os.Unsetenv("LLM_TYPE")
+		os.Unsetenv("LLM_DELAY")
+	}()
+
+	pipeReader, pipeWriter := io.Pipe()
+	defer pipeReader.Close()
+	out := new(bytes.Buffer)
+
+	go func() {
+		if err := RunCLIChatApp(pipeReader, out); err != nil {
+			t.Errorf("RunCLIChatApp returned error: %v", err)
+		}
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+
+	messages := []string{
+		"tell Andy we're slipping the date",
+		"maybe",
+		"yes",
+		"tell Andy the demo moved",
+		"/help exit",
+		"no",
+		"exit()",
+	}
+	for _, msg := range messages {
+		if _, err := pipeWriter.Write([]byte(msg + "\n")); err != nil {
+			t.Fatalf("Failed to write message: %v", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	pipeWriter.Close()
+	time.Sleep(300 * time.Millisecond)
+
+	output := out.String()
+	t.Logf("Output:\n%s", output)
+
+	expected := []string{
+		"Andy: Here's a draft to Andy:",
+		"Send it? (yes/no)",
+		"Please answer yes to send the draft to Andy or no to discard it.",
+		"Message sent to Andy",
+		"Draft discarded.",
+		"Goodbye!",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing expected text: %q", want)
+		}
+	}
+
+	// A command with arguments runs while a draft is pending rather than answering it
+	if !strings.Contains(output, "Exit the application") {
+		t.Errorf("expected /help exit to run while a draft is pending")
+	}
+	if count := strings.Count(output, "Please answer yes"); count != 1 {
+		t.Errorf("expected only \"maybe\" to be refused as an answer, got %d refusals", count)
+	}
+}
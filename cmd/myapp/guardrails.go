@@ -0,0 +1,77 @@
+package main
+
+import (
+	"goproduct/internal/config"
+	"goproduct/internal/guardrails"
+	"goproduct/internal/llm"
+	"goproduct/internal/tracing"
+)
+
+// newGuardrails creates the checks of the configuration, nil if there are none. The
+// moderation check asks the language model.
+func newGuardrails(cfg config.GuardrailsConfig, model llm.LanguageModel, tracer tracing.Tracer) (*guardrails.Pipeline, error) {
+	options := []guardrails.Option{
+		guardrails.WithFailClosed(cfg.FailClosed),
+		guardrails.WithTracer(tracer),
+	}
+
+	lengthAction, err := guardrailAction(cfg.LengthAction)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxInputLength > 0 {
+		options = append(options, guardrails.WithRule(guardrails.NewMaxLength(cfg.MaxInputLength), lengthAction, guardrails.Inbound))
+	}
+	if cfg.MaxOutputLength > 0 {
+		options = append(options, guardrails.WithRule(guardrails.NewMaxLength(cfg.MaxOutputLength), lengthAction, guardrails.Outbound))
+	}
+
+	for _, rule := range cfg.Denylist {
+		denylist, err := guardrails.NewDenylist("denylist", rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		action, err := guardrailAction(rule.Action)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, guardrails.WithRule(denylist, action, guardrailDirections(rule.Direction)...))
+	}
+
+	if cfg.Moderation.Direction != "" {
+		var moderatorOptions []guardrails.ModeratorOption
+		if len(cfg.Moderation.Categories) > 0 {
+			moderatorOptions = append(moderatorOptions, guardrails.WithModerationCategories(cfg.Moderation.Categories...))
+		}
+		action, err := guardrailAction(cfg.Moderation.Action)
+		if err != nil {
+			return nil, err
+		}
+		moderator := guardrails.NewModerator(model, moderatorOptions...)
+		options = append(options, guardrails.WithRule(moderator, action, guardrailDirections(cfg.Moderation.Direction)...))
+	}
+
+	pipeline := guardrails.NewPipeline(options...)
+	if pipeline.Len() == 0 {
+		return nil, nil
+	}
+	return pipeline, nil
+}
+
+// guardrailAction returns the configured action, blocking if none is set
+func guardrailAction(name string) (guardrails.Action, error) {
+	if name == "" {
+		return guardrails.ActionBlock, nil
+	}
+	return guardrails.ParseAction(name)
+}
+
+// guardrailDirections returns the configured direction, none meaning both
+func guardrailDirections(name string) []guardrails.Direction {
+	switch name {
+	case string(guardrails.Inbound), string(guardrails.Outbound):
+		return []guardrails.Direction{guardrails.Direction(name)}
+	default:
+		return nil
+	}
+}
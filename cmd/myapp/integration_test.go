@@ -0,0 +1,115 @@
+//go:build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/integration"
+	"goproduct/internal/knowledge"
+)
+
+// buildApp compiles the application once per test binary
+func buildApp(t *testing.T) string {
+	t.Helper()
+	binary := filepath.Join(t.TempDir(), "gogoproduct")
+	build := exec.Command("go", "build", "-o", binary, ".")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build the application: %v\n%s", err, output)
+	}
+	return binary
+}
+
+// runBatch runs the built application with the messages piped to its standard input,
+// pausing between them so each gets a reply, and returns its output
+func runBatch(t *testing.T, binary, dataDir string, env []string, messages ...string) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, binary, "--data-dir", dataDir)
+	cmd.Env = append(append(os.Environ(), "LLM_TYPE=echo", "LLM_DELAY=0"), env...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("Failed to create stdin pipe: %v", err)
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start the application: %v", err)
+	}
+	for _, message := range append(messages, "exit()") {
+		io.WriteString(stdin, message+"\n")
+		time.Sleep(500 * time.Millisecond)
+	}
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Application failed: %v\n%s", err, out.String())
+	}
+	return out.String()
+}
+
+func TestIntegrationBatchFileStore(t *testing.T) {
+	binary := buildApp(t)
+	dataDir := t.TempDir()
+
+	output := runBatch(t, binary, dataDir, nil, "Hello")
+	for _, want := range []string{"Andy: ECHO: You said: Hello", "Goodbye!"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Output missing %q:\n%s", want, output)
+		}
+	}
+
+	// The knowledge written by the run is on disk and readable by the store
+	store, err := knowledge.NewWALFileStore(filepath.Join(dataDir, "knowledge", "memories.json"), knowledge.DefaultWALCompactAfter)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open the knowledge written by the application: %v", err)
+	}
+	defer store.Close()
+	if record, err := store.GetRecord("1"); err != nil || string(record.Content) != "This is a fact." {
+		t.Errorf("Expected the seeded fact, got %+v, %v", record, err)
+	}
+
+	// A second run over the same data directory starts cleanly
+	if output := runBatch(t, binary, dataDir, nil, "Again"); !strings.Contains(output, "You said: Again") {
+		t.Errorf("Second run failed:\n%s", output)
+	}
+}
+
+func TestIntegrationBatchObjectStore(t *testing.T) {
+	storage := integration.S3(t)
+	prefix := integration.Prefix(t, storage)
+	config := integration.S3Config()
+	binary := buildApp(t)
+
+	runBatch(t, binary, t.TempDir(), []string{
+		"KNOWLEDGE_S3_BUCKET=" + config.Bucket,
+		"KNOWLEDGE_S3_ENDPOINT=" + config.Endpoint,
+		"KNOWLEDGE_S3_REGION=" + config.Region,
+		"KNOWLEDGE_S3_PREFIX=" + prefix,
+		"AWS_ACCESS_KEY_ID=" + config.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY=" + config.SecretAccessKey,
+	}, "Hello")
+
+	// The application flushed its knowledge to the bucket on exit
+	store, _ := knowledge.NewObjectStore(storage, prefix, 0)
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open the knowledge written by the application: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.GetRecord("1"); err != nil {
+		t.Errorf("Expected the seeded fact in object storage: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"goproduct/internal/common"
+	"goproduct/internal/config"
+	"goproduct/internal/logging"
+	"goproduct/internal/tracing"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// levelReloader returns the component that applies the log and trace levels of the
+// configuration again when the process receives SIGHUP, so that a running process can be
+// debugged by editing config.yaml and sending "kill -HUP <pid>" rather than restarting it
+func levelReloader(settingsPath string, logger *logging.Logger, tracer *tracing.EnhancedTracer) common.Component {
+	hangups := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	return common.Component{
+		Name:     "level reloader",
+		Optional: true,
+		Start: func(ctx context.Context) error {
+			signal.Notify(hangups, syscall.SIGHUP)
+			go func() {
+				for {
+					select {
+					case <-hangups:
+						reloadLevels(settingsPath, logger, tracer)
+					case <-done:
+						return
+					}
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			signal.Stop(hangups)
+			close(done)
+			return nil
+		},
+	}
+}
+
+// reloadLevels loads the configuration and applies its log and trace levels; levels set
+// with /loglevel or /tracelevel since are replaced. An invalid configuration changes
+// nothing.
+func reloadLevels(settingsPath string, logger *logging.Logger, tracer *tracing.EnhancedTracer) error {
+	cfg, err := config.Load(settingsPath)
+	if err != nil {
+		logger.Warn("Levels not reloaded", "error", err)
+		return err
+	}
+	options := cfg.Logging.Options()
+	logger.SetLevels(options.Level, options.Components)
+	traceLevel, _ := tracing.ParseLevel(cfg.Tracing.Level)
+	tracer.SetLevel(traceLevel)
+	logger.Info("Levels reloaded", "log_level", options.Level, "trace_level", traceLevel)
+	return nil
+}
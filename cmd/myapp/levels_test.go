@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goproduct/internal/logging"
+	"goproduct/internal/tracing"
+)
+
+func TestReloadLevels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	settings := "logging:\n  level: warn\n  components:\n    llm: debug\ntracing:\n  level: error\n"
+	if err := os.WriteFile(path, []byte(settings), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	logger := logging.New(io.Discard, logging.DefaultOptions())
+	logger.SetComponentLevel("messaging", logging.LevelError)
+	tracer := tracing.NewMemoryTracer()
+
+	if err := reloadLevels(path, logger, tracer); err != nil {
+		t.Fatalf("Failed to reload levels: %v", err)
+	}
+	levels := logger.ComponentLevels()
+	if logger.Level() != logging.LevelWarn || len(levels) != 1 || levels["llm"] != logging.LevelDebug {
+		t.Errorf("Expected the configured log levels, got %s and %v", logger.Level(), levels)
+	}
+	if tracer.Level() != tracing.LevelError {
+		t.Errorf("Expected the configured trace level, got %s", tracer.Level())
+	}
+
+	if err := os.WriteFile(path, []byte("logging:\n  level: chatty\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadLevels(path, logger, tracer); err == nil || logger.Level() != logging.LevelWarn {
+		t.Errorf("Expected an invalid configuration to change nothing, got %v and %s", err, logger.Level())
+	}
+}
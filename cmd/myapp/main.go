@@ -2,106 +2,220 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"goproduct/internal/admin"
 	"goproduct/internal/agent"
 	"goproduct/internal/chat"
 	"goproduct/internal/common"
+	"goproduct/internal/config"
+	"goproduct/internal/datadir"
 	"goproduct/internal/entity"
+	"goproduct/internal/export"
+	"goproduct/internal/health"
+	"goproduct/internal/importer"
 	"goproduct/internal/knowledge"
 	"goproduct/internal/llm"
 	"goproduct/internal/logging"
 	"goproduct/internal/messaging"
+	busgrpc "goproduct/internal/messaging/grpc"
+	"goproduct/internal/objectstore"
+	"goproduct/internal/presence"
+	"goproduct/internal/scheduler"
+	"goproduct/internal/tools"
 	"goproduct/internal/tracing"
+	"goproduct/internal/tui"
 	"io"
 	"os"
+	"sort"
+	"strings"
 	"time"
 )
 
+// dataDirPath is the data directory of the profile, set with --data-dir
+var dataDirPath = datadir.DefaultPath
+
+// configPath is the configuration file, set with --config; empty for config.yaml in
+// the data directory
+var configPath string
+
+// personaName is the persona the agent takes on, set with --persona; empty for the
+// configured persona
+var personaName string
+
+// resumeSession continues the last chat session, set with --resume
+var resumeSession bool
+
+// serveAddr is the address of the HTTP API, set with --serve; empty for the configured
+// address, and without one the chat prompt runs
+var serveAddr string
+
+// serveInsecure lets --serve accept unauthenticated requests on a non-loopback address,
+// set with --insecure
+var serveInsecure bool
+
+// uiMode is the interface of the chat, set with --ui: "cli" for the prompt or "tui" for
+// the full-screen interface
+var uiMode = "cli"
+
 // RunCLIChatApp runs the CLI chat app with the given input/output streams.
 func RunCLIChatApp(in io.Reader, out io.Writer) error {
 	ctx := context.Background()
 
-	logger := logging.File("./data/app.log", true)
-	defer logger.Close()
-	logging.Init(logger)
-
 	// Determine if we're in test mode by checking if input/output are not the standard streams
 	isTestMode := in != os.Stdin || out != os.Stdout
 
-	var enhancedTracer *tracing.EnhancedTracer
+	// Create the data directory and move files of older layouts into it
+	dataDir := datadir.New(dataDirPath)
+	var migrated []datadir.Migration
 	var err error
+	if !isTestMode {
+		migrated, err = dataDir.Prepare()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Settings come from config.yaml in the data directory, overridden by the environment
+	settingsPath := configPath
+	if settingsPath == "" {
+		settingsPath = dataDir.Config()
+	}
+	cfg, err := config.Load(settingsPath)
+	if err != nil {
+		return err
+	}
+	appLog, traceLog := cfg.Paths.AppLog, cfg.Paths.TraceLog
+	if appLog == "" {
+		appLog = dataDir.AppLog()
+	}
+	if traceLog == "" {
+		traceLog = dataDir.TraceLog()
+	}
+
+	logger, err := logging.Open(appLog, cfg.Logging.Options())
+	if err != nil {
+		// Fall back to stderr if the log can't be opened, as in tests
+		logger = logging.Console()
+	}
+	logging.Init(logger)
+	for _, migration := range migrated {
+		logging.Get().Info("Migrated legacy data file", "from", migration.From, "to", migration.To)
+	}
+
+	// What is started from here on is stopped by the runtime context in dependency order,
+	// when the chat ends or the user presses Ctrl+C, so that buffered traces and pending
+	// changes to the store are written
+	runtime, err := common.NewRuntimeContext(common.RuntimeOptions{})
+	if err != nil {
+		return err
+	}
+	runtime.OnShutdown(common.StageLoggers, "application log", func(ctx context.Context) error {
+		return logger.Close()
+	})
+	shutdown := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+		defer cancel()
+		if err := runtime.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Shutdown incomplete: %v\n", err)
+		}
+	}
+	defer shutdown()
+
+	var enhancedTracer *tracing.EnhancedTracer
+	traceLevel, _ := tracing.ParseLevel(cfg.Tracing.Level)
 
 	if isTestMode {
 		// Use in-memory tracer for tests to avoid file dependency
 		enhancedTracer = tracing.NewMemoryTracer()
 	} else {
-		// Create directories if they don't exist
-		os.MkdirAll("./data", 0755)
-
 		// Use file tracer for normal operation
 		enhancedTracer, err = tracing.CreateFileTracer(
-			"./data/trace.log",
-			5*time.Second,
+			traceLog,
+			cfg.Intervals.TraceFlush,
 			4096,
 		)
 		if err != nil {
 			return err
 		}
+		enhancedTracer.SetLevel(traceLevel)
+
+		// Export the trace events to an OpenTelemetry collector too
+		if tracingConfig := cfg.Tracing; tracingConfig.OTLPEndpoint != "" {
+			options := tracing.DefaultOTelTracerOptions()
+			options.Endpoint = tracingConfig.OTLPEndpoint
+			options.ServiceName = tracingConfig.ServiceName
+			options.Headers = tracingConfig.Headers
+			options.FlushInterval = cfg.Intervals.TraceFlush
+			otelTracer, err := tracing.NewOTelTracer(options)
+			if err != nil {
+				return err
+			}
+			multiTracer := tracing.NewMultiTracer(enhancedTracer, otelTracer)
+			multiTracer.SetLevel(traceLevel)
+			enhancedTracer = tracing.NewEnhancedTracer(multiTracer, "system")
+		}
 	}
-	defer enhancedTracer.Close()
+	runtime.OnShutdown(common.StageTracers, "tracer", func(ctx context.Context) error {
+		return enhancedTracer.Close()
+	})
 
 	logging.Get().Info("Application started")
 	enhancedTracer.Info("Application started")
 
-	messageBus := messaging.NewMemoryMessageBus()
-	enhancedTracer.Info("Message bus created")
-
-	runtime, err := common.NewRuntimeContext(common.RuntimeOptions{
-		MessageBus: messageBus,
-	})
-	if err != nil {
-		return err
-	}
-	enhancedTracer.Info("Runtime context created")
-
-	// Check environment variables for LLM type
-	var languageModel llm.LanguageModel
-	llmType := os.Getenv("LLM_TYPE")
-
-	switch llmType {
-	case "echo":
-		// Create an Echo LLM
-		echoConfig := &llm.EchoConfig{}
-		languageModel, err = llm.NewLLM(ctx, echoConfig)
+	// Entities share one process by default; a NATS or Redis URL connects them across
+	// processes
+	var messageBus messaging.MessageBus = messaging.NewMemoryMessageBus(messaging.WithHistory(cfg.Bus.History))
+	if natsURL := cfg.Bus.NatsURL; natsURL != "" && !isTestMode {
+		natsBus, err := messaging.NewNatsMessageBus(natsURL, messaging.WithNatsTracer(enhancedTracer), messaging.WithNatsHistory(cfg.Bus.History))
 		if err != nil {
 			return err
 		}
-		enhancedTracer.Info("EchoLLM created with delay from env LLM_DELAY")
-
-	case "exception":
-		// Create an Exception LLM
-		exceptionConfig := &llm.ExceptionConfig{}
-		languageModel, err = llm.NewLLM(ctx, exceptionConfig)
-		if err != nil {
-			return err
-		}
-		enhancedTracer.Info("ExceptionLLM created with delay from env LLM_DELAY")
-
-	default:
-		// Default to LM Studio LLM
-		languageModel, err = llm.NewLMStudioLLM("http://localhost:1234/v1",
-			llm.WithLMStudioModel("gemma-3-4b-it"),
-			llm.WithLMStudioTemperature(0.7),
-			llm.WithLMStudioMaxTokens(4096),
-			llm.WithLMStudioTimeout(60),
-			llm.WithLMStudioTopP(0.9),
-			llm.WithLMStudioPresencePenalty(0.0),
-			llm.WithLMStudioFrequencyPenalty(0.0),
-		)
+		messageBus = natsBus
+	} else if redisURL := cfg.Bus.RedisURL; redisURL != "" && !isTestMode {
+		redisBus, err := messaging.NewRedisMessageBus(redisURL, messaging.WithRedisTracer(enhancedTracer), messaging.WithRedisHistory(cfg.Bus.History))
 		if err != nil {
 			return err
 		}
-		enhancedTracer.Info("LMStudio LLM created")
+		messageBus = redisBus
+	}
+	runtime.SetMessageBus(messageBus)
+	enhancedTracer.Info("Message bus created and added to runtime context")
+
+	// Background services, servers and agents are components of the runtime context, all
+	// started in the order added once they are wired, and stopped on shutdown
+	var components []common.Component
+
+	// The health of the bus, the store and the language model is checked with probes; those
+	// costing a round trip are cached
+	components = append(components, common.Component{
+		Name:   "message bus",
+		Health: health.Cached(health.Bus(messageBus), health.DefaultInterval),
+	})
+
+	// The gRPC bridge serves the bus to services in other languages, see bus.proto
+	if grpcAddr := cfg.Bus.GRPCAddr; grpcAddr != "" && !isTestMode {
+		grpcServer := busgrpc.NewServer(grpcAddr, messageBus)
+		components = append(components, common.Component{
+			Name: "gRPC bus bridge",
+			Start: func(ctx context.Context) error {
+				if err := grpcServer.Start(); err != nil {
+					return err
+				}
+				enhancedTracer.Info("gRPC bus bridge listening on %s", grpcServer.Addr())
+				return nil
+			},
+			Stop: func(ctx context.Context) error { return grpcServer.Close() },
+		})
 	}
+
+	languageModel, err := newLanguageModel(ctx, cfg, enhancedTracer)
+	if err != nil {
+		return err
+	}
+	// Count the tokens and cost of every call that reaches a provider
+	usageLedger := llm.NewUsageLedger()
+	languageModel = llm.NewMeteredLLM(languageModel, usageLedger, llm.WithMeterTracer(enhancedTracer))
 	enhancedTracer.Info("LLM created")
 
 	// Use appropriate knowledge store based on test mode
@@ -114,10 +228,43 @@ func RunCLIChatApp(in io.Reader, out io.Writer) error {
 			return err
 		}
 	} else {
-		// Use file-based knowledge store for normal operation
-		store, err = knowledge.NewFileStore("./data/memories.json")
-		if err != nil {
-			return err
+		switch cfg.Store.Backend {
+		case config.StoreMemory:
+			store, err = knowledge.NewMemoryStore()
+			if err != nil {
+				return err
+			}
+		case config.StoreS3:
+			// Keep knowledge in object storage where the local disk is not durable
+			s3Config := cfg.Store.S3
+			s3, err := objectstore.NewS3(objectstore.S3Config{
+				Endpoint:        s3Config.Endpoint,
+				Region:          s3Config.Region,
+				Bucket:          s3Config.Bucket,
+				AccessKeyID:     s3Config.AccessKeyID,
+				SecretAccessKey: s3Config.SecretAccessKey,
+				SessionToken:    s3Config.SessionToken,
+			})
+			if err != nil {
+				return err
+			}
+			store, err = knowledge.NewObjectStore(s3, s3Config.Prefix, knowledge.DefaultObjectStoreShards)
+			if err != nil {
+				return err
+			}
+			enhancedTracer.Info("Using object storage bucket %s for knowledge", s3Config.Bucket)
+		default:
+			// Use file-based knowledge store for normal operation; flushes append to a log and
+			// run in the background so a crash loses at most a few seconds of changes
+			knowledgeFile := cfg.Store.File
+			if knowledgeFile == "" {
+				knowledgeFile = dataDir.KnowledgeFile()
+			}
+			store, err = knowledge.NewWALFileStore(knowledgeFile, knowledge.DefaultWALCompactAfter,
+				knowledge.WithAutoFlush(cfg.Intervals.StoreFlush, 100))
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -126,11 +273,34 @@ func RunCLIChatApp(in io.Reader, out io.Writer) error {
 	if err != nil {
 		return err
 	}
-	defer store.Close()
 
+	// Time the store's operations for /metrics
+	store = knowledge.NewMeteredStore(store, cfg.Store.Backend)
+
+	// The runtime context flushes and closes its memory store on shutdown
 	runtime.SetMemory(store)
+	components = append(components, common.Component{Name: "knowledge store", Health: health.Store(store)})
 	enhancedTracer.Info("Memory store created and added to runtime context")
 
+	// Answer repeated requests from the cache
+	if cacheConfig := cfg.LLM.Cache; cacheConfig.TTL > 0 {
+		options := []llm.CacheOption{
+			llm.WithCacheTTL(cacheConfig.TTL),
+			llm.WithCacheSize(cacheConfig.Size),
+			llm.WithCacheParameters(llm.RequestOptions{Temperature: cfg.LLM.Temperature, MaxTokens: cfg.LLM.MaxTokens}),
+		}
+		if cacheConfig.Persist {
+			options = append(options, llm.WithCacheStore(store))
+		}
+		languageModel = llm.NewCachingLLM(languageModel, options...)
+		enhancedTracer.Info("LLM responses cached for %s", cacheConfig.TTL)
+	}
+	runtime.SetLanguageModel(languageModel)
+	components = append(components, common.Component{
+		Name:   "language model",
+		Health: health.Cached(health.LanguageModel(languageModel), health.DefaultInterval),
+	})
+
 	store.AddRecord(knowledge.Entry{
 		ID:          "1",
 		Category:    knowledge.CategoryFact,
@@ -149,71 +319,347 @@ func RunCLIChatApp(in io.Reader, out io.Writer) error {
 		Tags:        []string{"fact"},
 		References:  []knowledge.Reference{},
 		Metadata:    map[string]string{"source": "chat"},
+		Provenance: []knowledge.ProvenanceStep{{
+			Origin:  knowledge.OriginSystem,
+			Details: map[string]string{"reason": "startup seed"},
+		}},
 	})
 	err = store.Flush()
 	if err != nil {
 		return err
 	}
 
-	persona := agent.Persona{
-		Name: "Andy",
-		Role: "Assistant",
-		Type: "Text",
-		LanguageModels: agent.LanguageModels{
-			Default: languageModel,
+	// Soft-delete expired knowledge
+	expiryJanitor := knowledge.NewExpiryJanitor(store, cfg.Intervals.Expiry, knowledge.ExpirySoftDelete)
+	expiryJanitor.SetTracer(enhancedTracer)
+	components = append(components, common.Component{
+		Name: "expiry janitor",
+		Start: func(ctx context.Context) error {
+			expiryJanitor.Start(ctx)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			expiryJanitor.Stop()
+			return nil
 		},
-		SystemPrompt: `
-You are an AI Product Owner for a software company that creates websites, HTTP REST services, Android apps, iOS apps, Windows apps, and macOS apps. The CEO is your primary human stakeholder.
-
-# Responsibilities
-- Gather and clarify requirements.
-- Create and maintain product roadmaps, plans, and specifications.
-- Coordinate across departments to ensure alignment.
-- Prioritize the product backlog for maximum value.
-- Provide strategic, outcome-focused guidance.
-
-# Communication & Tone
-- Greet casually (e.g., “Hey, what’s up?”).
-- Keep replies short, warm, and to the point—like a helpful teammate.
-- Avoid formal or overly detailed language.
-- Never use vulgar or insulting language.
-- Stay friendly, polite, and adaptive to feedback.
-
-# Scope & Limitations
-- Focus strictly on product development topics.
-- Politely decline requests unrelated to product development (e.g., weather updates, math solutions, personal opinions unrelated to the product).
-- When greeted informally (e.g., “Hello” or “Hey”), respond in a brief, friendly way. If the user asks about or references product matters, respond with strategic, product-focused guidance.
-`,
+	})
+
+	// Produce a daily knowledge quality digest; the reporter also serves the latest report over HTTP
+	if !isTestMode {
+		qualityReporter := knowledge.NewQualityReporter(store, cfg.Intervals.QualityReport, knowledge.DefaultQualityOptions(), func(report knowledge.QualityReport) {
+			enhancedTracer.Info("%s", report.Digest())
+		})
+		qualityReporter.OnError(func(err error) {
+			enhancedTracer.Warning("Knowledge quality report failed: %v", err)
+		})
+		components = append(components, common.Component{
+			Name: "quality reporter",
+			Start: func(ctx context.Context) error {
+				qualityReporter.Start(ctx)
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				qualityReporter.Stop()
+				return nil
+			},
+		})
+	}
+
+	// Personas ship with the binary and can be added or overridden in the data directory
+	personas, err := loadPersonas(dataDir.Personas())
+	if err != nil {
+		return err
 	}
+	selectedPersona := personaName
+	if selectedPersona == "" {
+		selectedPersona = cfg.Agent.Persona
+	}
+	definition, ok := agent.FindPersona(personas, selectedPersona)
+	if !ok {
+		return fmt.Errorf("unknown persona %q, see --list-personas", selectedPersona)
+	}
+	personaLLM := languageModel
+	if !definition.Model.IsDefault() && !isTestMode {
+		personaLLM, err = llm.NewLLM(ctx, definition.Model.Config())
+		if err != nil {
+			return fmt.Errorf("failed to create the model of persona %s: %w", definition.Name, err)
+		}
+		personaLLM = llm.NewRetryingLLM(personaLLM, llm.WithRetryTracer(enhancedTracer))
+		personaLLM = rateLimited(personaLLM, cfg.LLM.RateLimit, enhancedTracer)
+		personaLLM = llm.NewMeteredLLM(personaLLM, usageLedger, llm.WithMeterTracer(enhancedTracer))
+	}
+	persona := definition.Persona(personaLLM)
+	enhancedTracer.Info("Persona %s loaded from %s", persona.Name, definition.Source)
 
 	agentInstance := agent.NewAgent(persona)
 	enhancedTracer.Info("Agent created")
 
-	productAgent := entity.NewProductAgentEntity(agentInstance, messageBus)
+	// System prompts are templates assembled from the prompt library, which the data
+	// directory can extend or override per persona
+	promptLibrary, err := loadPrompts(dataDir.Prompts(), store)
+	if err != nil {
+		return err
+	}
+	agentInstance.SetPrompts(promptLibrary)
+
+	// Respond in the user's language when the persona has a system prompt for it;
+	// /language switches it during the chat
+	if locale := agent.LocaleLanguage(); locale != "" {
+		if err := agentInstance.SetLanguage(locale); err != nil {
+			enhancedTracer.Debug("No system prompt for locale %s, using %s", locale, persona.Language)
+		} else {
+			enhancedTracer.Info("Agent language: %s", agentInstance.Language())
+		}
+	}
+
+	// Ground answers in stored knowledge, framed by the persona's memory template
+	agentInstance.SetMemoryStore(store)
+
+	// Record chat turns and summarize older ones past the context budget
+	if err := agentInstance.SetConversationMemory(store, cfg.Agent.ContextBudget); err != nil {
+		enhancedTracer.Warning("Earlier conversation summaries not loaded: %v", err)
+	}
+
+	// The entity is created before the agent's tools, so that the tasks the agent schedules
+	// are sent to it
+	productAgent := entity.NewProductAgentEntity(agentInstance, messageBus,
+		entity.WithConcurrency(cfg.Agent.MaxConcurrent, cfg.Agent.InboxSize))
 	enhancedTracer.Info("Product agent entity created: %s (%s)", productAgent.Name(), productAgent.ID())
 
+	// Agents schedule tasks for themselves, e.g. a summary of the day's decisions at 5pm;
+	// the tasks are kept in the store of the runtime context
+	taskScheduler, err := scheduler.NewScheduler(runtime)
+	if err != nil {
+		return err
+	}
+
+	// Let the agent search, remember, update and forget facts and schedule tasks while it
+	// answers, as far as the persona allows
+	availableTools := append(tools.KnowledgeTools(store), scheduler.Tools(taskScheduler, productAgent.ID())...)
+	personaTools, err := selectTools(definition, availableTools)
+	if err != nil {
+		return err
+	}
+	agentInstance.SetTools(tools.NewRegistry(personaTools...))
+
+	// Think, act with the tools and observe step by step before answering, when configured
+	if steps := cfg.Agent.PlanSteps; steps > 0 {
+		agentInstance.SetPlanner(agent.NewPlanner(agent.WithMaxSteps(steps), agent.WithPlanTracer(enhancedTracer)))
+		enhancedTracer.Info("Reasoning loop enabled, up to %d steps", steps)
+	}
+
+	// Count how often knowledge is surfaced; counts are written to the store in batches
+	accessTracker := knowledge.NewAccessTracker(store, cfg.Intervals.AccessFlush)
+	components = append(components, common.Component{
+		Name: "access tracker",
+		Start: func(ctx context.Context) error {
+			accessTracker.Start(ctx)
+			return nil
+		},
+		Stop: func(ctx context.Context) error { return accessTracker.Stop() },
+	})
+	agentInstance.SetAccessTracker(accessTracker)
+
+	// Check the messages sent to the agent and its replies against the configured rules
+	pipeline, err := newGuardrails(cfg.Guardrails, languageModel, enhancedTracer)
+	if err != nil {
+		return err
+	}
+	if pipeline != nil {
+		agentInstance.SetGuardrails(pipeline)
+		enhancedTracer.Info("Guardrails enabled with %d checks", pipeline.Len())
+	}
+
+	// Capture confident answers to factual questions as provisional knowledge for review
+	if cfg.Store.Backfill {
+		agentInstance.SetKnowledgeBackfill(store)
+		enhancedTracer.Info("Knowledge backfill enabled")
+	}
+
+	// Operational commands are served on a local socket rather than the chat prompt;
+	// send them with "myapp admin <command>"
+	if !isTestMode {
+		adminServer := admin.NewServer(dataDir.AdminSocket())
+		registerAdminCommands(adminServer, enhancedTracer, store, accessTracker, agentInstance, dataDir)
+		components = append(components, common.Component{
+			Name:     "admin server",
+			Optional: true,
+			Start: func(ctx context.Context) error {
+				if err := adminServer.Start(); err != nil {
+					enhancedTracer.Warning("Admin server not available: %v", err)
+					return err
+				}
+				enhancedTracer.Info("Admin server listening on %s", dataDir.AdminSocket())
+				return nil
+			},
+			Stop: func(ctx context.Context) error { return adminServer.Close() },
+		})
+	}
+
+	// SIGHUP applies the log and trace levels of the configuration file again
+	if !isTestMode {
+		components = append(components, levelReloader(settingsPath, logger, enhancedTracer))
+	}
+
 	humanaEntity := entity.NewCliHumanEntity("User", messageBus)
 	enhancedTracer.Info("Human entity created: %s (%s)", humanaEntity.Name(), humanaEntity.ID())
 
-	err = productAgent.Start(ctx)
+	// Agents run as a team so groups and conversation routes can be configured in the
+	// team file of the data directory
+	teamConfig := entity.TeamConfig{}
+	if !isTestMode {
+		teamConfig, err = entity.LoadTeamConfig(dataDir.TeamConfig())
+		if err != nil {
+			return err
+		}
+	}
+	team, err := entity.NewAgentTeam(messageBus, teamConfig)
 	if err != nil {
-		enhancedTracer.Error("Failed to start product agent: %v", err)
 		return err
 	}
-	enhancedTracer.Info("Product agent started")
+	if err := team.Register(productAgent); err != nil {
+		return err
+	}
+	components = append(components, common.Component{
+		Name: "agent team",
+		Start: func(ctx context.Context) error {
+			if err := team.Start(ctx); err != nil {
+				enhancedTracer.Error("Failed to start product agent: %v", err)
+				return err
+			}
+			enhancedTracer.Info("Product agent started")
+			return nil
+		},
+		Stop: func(ctx context.Context) error { return team.Shutdown() },
+	})
+
+	// Run the scheduled tasks once the agents answer messages
+	components = append(components, common.Component{
+		Name:     "task scheduler",
+		Optional: true,
+		Start: func(ctx context.Context) error {
+			if err := taskScheduler.Start(ctx); err != nil {
+				enhancedTracer.Warning("Scheduled tasks not run: %v", err)
+				return err
+			}
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			taskScheduler.Stop()
+			return nil
+		},
+	})
+
+	// Agents publish heartbeats so the chat can tell when one is busy or unreachable
+	const presenceComponent = "presence registry"
+	var registry *presence.Registry
+	if !isTestMode {
+		registry = presence.NewRegistry(messageBus)
+		components = append(components, common.Component{
+			Name:     presenceComponent,
+			Optional: true,
+			Start: func(ctx context.Context) error {
+				if err := registry.Start(); err != nil {
+					enhancedTracer.Warning("Presence not tracked: %v", err)
+					return err
+				}
+				return nil
+			},
+			Stop: func(ctx context.Context) error { return registry.Stop() },
+		})
+		for _, member := range team.Agents() {
+			heartbeat := presence.NewHeartbeat(messageBus, member, presence.WithInterval(cfg.Intervals.Heartbeat))
+			components = append(components, common.Component{
+				Name: "heartbeat of " + member.Name(),
+				Start: func(ctx context.Context) error {
+					heartbeat.Start(ctx)
+					return nil
+				},
+				Stop: func(ctx context.Context) error {
+					heartbeat.Stop()
+					return nil
+				},
+			})
+		}
+	}
+
+	for _, component := range components {
+		if err := runtime.Register(component); err != nil {
+			return err
+		}
+	}
+	if err := runtime.Start(ctx); err != nil {
+		return err
+	}
+
+	// --serve replaces the chat prompt with the HTTP API and WebSocket gateway
+	addr := serveAddr
+	if addr == "" {
+		addr = cfg.Server.Addr
+	}
+	if addr != "" && !isTestMode {
+		return serve(ctx, addr, serveInsecure, cfg.Server, messageBus, productAgent, store, runtime, enhancedTracer)
+	}
 
+	// Tests keep the chat's short wait for replies
+	var chatOptions []chat.EnhancedChatOption
+	if !isTestMode {
+		chatOptions = append(chatOptions,
+			chat.WithReplyTimeout(cfg.Timeouts.ChatReply),
+			chat.WithReplyRetries(cfg.Chat.ReplyRetries))
+	}
+	if cfg.Chat.FallbackReply != "" {
+		chatOptions = append(chatOptions, chat.WithFallbackReply(cfg.Chat.FallbackReply))
+	}
+	chatOptions = append(chatOptions, chat.WithShutdown(shutdown))
 	chatInterface := chat.NewEnhancedChat(
 		humanaEntity,
 		productAgent,
 		messageBus,
 		enhancedTracer,
+		chatOptions...,
 	)
 
+	// Agents the user can message through "tell <name> ..." requests
+	for _, member := range team.Agents() {
+		chatInterface.RegisterContact(member)
+	}
+
+	// Offer knowledge titles, tags and recent topics as tab completions
+	chatInterface.SetSuggestionProvider(chat.NewKnowledgeSuggestionProvider(store, cfg.Intervals.Suggestions))
+
+	// Store the chat transcript and summaries of /standup, /triage and other conversation modes
+	chatInterface.SetKnowledgeStore(store)
+
+	// Report token usage and cost with /usage
+	chatInterface.SetUsageLedger(usageLedger)
+
+	// Pick up where the last session left off
+	chatInterface.SetResume(resumeSession)
+
+	// Report the health of the components with /health
+	chatInterface.SetHealth(runtime.Health)
+
+	// Warn before messaging an agent that is offline, and list presence with /presence
+	if runtime.Running(presenceComponent) {
+		chatInterface.SetPresence(registry)
+	}
+
 	// Set test mode in the chat interface
 	chatInterface.IsTestMode = isTestMode
 	enhancedTracer.Info("Enhanced chat interface created (isTestMode=%v)", isTestMode)
 	enhancedTracer.Info("Enhanced chat interface created")
 
+	// The full-screen interface shows the chat beside the knowledge store and the logs
+	if uiMode == "tui" && !isTestMode {
+		app := tui.New(chatInterface,
+			tui.WithPrompt(humanaEntity.Name()),
+			tui.WithKnowledgeStore(store),
+			tui.WithLogTail(traceLog),
+			tui.WithLogTail(appLog))
+		return app.Run(ctx)
+	}
+
 	// Start the chat interface with custom IO if supported
 	if ci, ok := interface{}(chatInterface).(interface {
 		StartWithIO(io.Reader, io.Writer) error
@@ -225,5 +671,193 @@ You are an AI Product Owner for a software company that creates websites, HTTP R
 }
 
 func main() {
-	_ = RunCLIChatApp(os.Stdin, os.Stdout)
+	flag.StringVar(&dataDirPath, "data-dir", datadir.DefaultPath, "directory holding knowledge, logs and traces; use one per profile")
+	flag.StringVar(&configPath, "config", "", "configuration file (default config.yaml in the data directory)")
+	flag.StringVar(&serveAddr, "serve", "", "serve the agent over HTTP on the address (e.g. :8080) instead of the chat prompt")
+	flag.BoolVar(&serveInsecure, "insecure", false, "let --serve accept unauthenticated requests on a non-loopback address")
+	flag.StringVar(&personaName, "persona", "", "persona the agent takes on (default from the configuration); see --list-personas")
+	flag.BoolVar(&resumeSession, "resume", false, "continue the last chat session with the persona, restoring its context")
+	flag.StringVar(&uiMode, "ui", uiMode, "chat interface: cli for the prompt, or tui for full screen with knowledge and trace panes")
+	listPersonasFlag := flag.Bool("list-personas", false, "list the available personas and exit")
+	flag.Parse()
+
+	if uiMode != "cli" && uiMode != "tui" {
+		fmt.Fprintf(os.Stderr, "unknown interface %q, use cli or tui\n", uiMode)
+		os.Exit(1)
+	}
+
+	if *listPersonasFlag {
+		personas, err := loadPersonas(datadir.New(dataDirPath).Personas())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		listPersonas(os.Stdout, personas)
+		return
+	}
+
+	// "admin <command>" sends an operational command to the running application
+	if args := flag.Args(); len(args) > 0 && args[0] == "admin" {
+		reply, err := admin.Send(datadir.New(dataDirPath).AdminSocket(), strings.Join(args[1:], " "))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print(reply)
+		return
+	}
+
+	if err := RunCLIChatApp(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// registerAdminCommands registers the operational commands of the application
+func registerAdminCommands(server *admin.Server, tracer *tracing.EnhancedTracer, store knowledge.Store, accessTracker *knowledge.AccessTracker, agentInstance *agent.Agent, dataDir *datadir.Dir) {
+	server.Register(admin.Command{
+		Name:        "tracelevel",
+		Usage:       "<level>",
+		Description: "Set the trace level: error, warning, info, debug or verbose",
+		Handler: func(args []string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("usage: tracelevel <level>")
+			}
+			level, err := tracing.ParseLevel(args[0])
+			if err != nil {
+				return "", err
+			}
+			tracer.SetLevel(level)
+			tracer.Info("Trace level set to %s by admin command", level)
+			return fmt.Sprintf("Trace level set to %s", level), nil
+		},
+	})
+
+	server.Register(admin.Command{
+		Name:        "flush",
+		Description: "Write pending knowledge, access statistics and traces to disk",
+		Handler: func(args []string) (string, error) {
+			if err := accessTracker.Flush(); err != nil {
+				return "", err
+			}
+			if err := store.Flush(); err != nil {
+				return "", err
+			}
+			if err := tracer.Flush(); err != nil {
+				return "", err
+			}
+			return "Flushed", nil
+		},
+	})
+
+	server.Register(admin.Command{
+		Name:        "personas",
+		Usage:       "reload",
+		Description: "Reload the personas and prompt templates of the data directory into the agent",
+		Handler: func(args []string) (string, error) {
+			if len(args) != 1 || args[0] != "reload" {
+				return "", fmt.Errorf("usage: personas reload")
+			}
+			definition, err := reloadPersona(agentInstance, dataDir, store)
+			if err != nil {
+				return "", err
+			}
+			tracer.Info("Persona %s reloaded from %s by admin command", definition.Name, definition.Source)
+			return fmt.Sprintf("Persona %s reloaded from %s", definition.Name, definition.Source), nil
+		},
+	})
+
+	server.Register(admin.Command{
+		Name:        "knowledge",
+		Description: "Show knowledge store information",
+		Handler: func(args []string) (string, error) {
+			info, err := store.Info()
+			if err != nil {
+				return "", err
+			}
+			keys := make([]string, 0, len(info))
+			for key := range info {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			var sb strings.Builder
+			for _, key := range keys {
+				sb.WriteString(fmt.Sprintf("%s: %s\n", key, info[key]))
+			}
+			return sb.String(), nil
+		},
+	})
+
+	server.Register(admin.Command{
+		Name:        "export",
+		Usage:       "<profile> <file> [category]",
+		Description: "Export knowledge, optionally one category such as \"message\", redacted by a profile",
+		Handler: func(args []string) (string, error) {
+			if len(args) < 2 || len(args) > 3 {
+				return "", fmt.Errorf("usage: export <profile> <file> [category]")
+			}
+			profiles, err := export.LoadProfiles(dataDir.ExportProfiles())
+			if err != nil {
+				return "", err
+			}
+			profile, ok := profiles[args[0]]
+			if !ok {
+				return "", fmt.Errorf("unknown export profile %q", args[0])
+			}
+
+			query := knowledge.Query().OrderBy("CreatedAt")
+			if len(args) == 3 {
+				query = query.Where("Category", "=", args[2])
+			}
+			filter, err := query.Build()
+			if err != nil {
+				return "", err
+			}
+
+			file, err := os.OpenFile(args[1], os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+			if err != nil {
+				return "", fmt.Errorf("failed to create export file: %w", err)
+			}
+			report, err := export.Export(file, store, filter, profile)
+			if closeErr := file.Close(); err == nil && closeErr != nil {
+				err = fmt.Errorf("failed to close export file: %w", closeErr)
+			}
+			if err != nil {
+				os.Remove(args[1])
+				return "", err
+			}
+			tracer.Info("Exported %d entries to %s with profile %s", report.Exported, args[1], profile.Name)
+			return report.String(), nil
+		},
+	})
+
+	server.Register(admin.Command{
+		Name:        "import",
+		Usage:       "<chatgpt|claude|slack> <file> [human] [agent]",
+		Description: "Import the conversations of a ChatGPT, Claude or Slack export into the conversation history",
+		Handler: func(args []string) (string, error) {
+			if len(args) < 2 || len(args) > 4 {
+				return "", fmt.Errorf("usage: import <chatgpt|claude|slack> <file> [human] [agent]")
+			}
+			conversations, err := importer.ParseFile(args[0], args[1])
+			if err != nil {
+				return "", err
+			}
+			// Speakers are attributed to the human and agent named, if any
+			var owners importer.OwnerMap
+			if len(args) > 2 {
+				owners.DefaultHuman = args[2]
+			}
+			if len(args) > 3 {
+				owners.DefaultAgent = args[3]
+			}
+			result, err := importer.NewImporter(store, owners).Import(conversations)
+			if err != nil {
+				return "", err
+			}
+			tracer.Info("Imported %d messages of %d conversations from %s", result.Imported, result.Conversations, args[1])
+			return fmt.Sprintf("Imported %d messages of %d conversations (%d already imported, %d empty)",
+				result.Imported, result.Conversations, result.Duplicates, result.Skipped), nil
+		},
+	})
 }
@@ -2,7 +2,11 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -69,3 +73,52 @@ func TestChatApp_OutOfOfficeFallback(t *testing.T) {
 		}
 	}
 }
+
+// runMain runs main in a copy of the test binary with the arguments and returns its
+// standard error and exit error; TestMainProcess is the entry point in the copy
+func runMain(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMainProcess$")
+	cmd.Env = append(os.Environ(), "MYAPP_MAIN_ARGS="+strings.Join(args, "\n"))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stderr.String(), err
+}
+
+func TestMainProcess(t *testing.T) {
+	args, ok := os.LookupEnv("MYAPP_MAIN_ARGS")
+	if !ok {
+		t.Skip("only runs as the application, from runMain")
+	}
+	os.Args = append([]string{os.Args[0]}, strings.Split(args, "\n")...)
+	main()
+	os.Exit(0)
+}
+
+func TestMainBadConfig(t *testing.T) {
+	dir := t.TempDir()
+	malformed := filepath.Join(dir, "malformed.yaml")
+	if err := os.WriteFile(malformed, []byte("llm: ["), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, path, want string
+	}{
+		{"malformed", malformed, "failed to parse configuration"},
+		{"unreadable", dir, "failed to read configuration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stderr, err := runMain(t, "--data-dir", filepath.Join(dir, "data"), "--config", tt.path)
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) || exitErr.ExitCode() == 0 {
+				t.Fatalf("expected a non-zero exit, got %v\n%s", err, stderr)
+			}
+			if !strings.Contains(stderr, tt.want) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, tt.want)
+			}
+		})
+	}
+}
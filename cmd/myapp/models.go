@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"goproduct/internal/config"
+	"goproduct/internal/llm"
+	"goproduct/internal/tracing"
+)
+
+// newLanguageModel creates the language model of the configuration with the registered
+// provider it names, falling back to the configured fallback providers. Each provider
+// retries transient failures before the next one is asked.
+func newLanguageModel(ctx context.Context, cfg config.Config, tracer *tracing.EnhancedTracer) (llm.LanguageModel, error) {
+	providers := append([]string{cfg.LLM.Provider}, cfg.LLM.Fallbacks...)
+	models := make([]llm.LanguageModel, 0, len(providers))
+	for _, provider := range providers {
+		model, err := newProviderModel(ctx, cfg, provider, tracer)
+		if err != nil {
+			return nil, err
+		}
+		// Retry transient provider failures instead of giving up on the first network blip
+		models = append(models, llm.NewRetryingLLM(model, llm.WithRetryTracer(tracer)))
+	}
+	if len(models) == 1 {
+		return rateLimited(models[0], cfg.LLM.RateLimit, tracer), nil
+	}
+
+	tracer.Info("LLM fallbacks %v, racing %t", cfg.LLM.Fallbacks, cfg.LLM.Race)
+	failover, err := llm.NewFailoverLLM(models,
+		llm.WithFailoverTimeout(cfg.Timeouts.LLMRequest),
+		llm.WithFailoverRacing(cfg.LLM.Race),
+		llm.WithFailoverTracer(tracer),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return rateLimited(failover, cfg.LLM.RateLimit, tracer), nil
+}
+
+// rateLimited applies the configured rate limits to a language model, if any
+func rateLimited(model llm.LanguageModel, limit config.LLMRateLimitConfig, tracer *tracing.EnhancedTracer) llm.LanguageModel {
+	if limit.RequestsPerMinute == 0 && limit.TokensPerMinute == 0 {
+		return model
+	}
+	tracer.Info("LLM limited to %d requests and %d tokens per minute", limit.RequestsPerMinute, limit.TokensPerMinute)
+	return llm.NewRateLimitedLLM(model,
+		llm.WithRequestsPerMinute(limit.RequestsPerMinute),
+		llm.WithTokensPerMinute(limit.TokensPerMinute),
+		llm.WithRateLimitReject(limit.Reject),
+		llm.WithRateLimitMaxWait(limit.MaxWait),
+		llm.WithRateLimitTracer(tracer),
+	)
+}
+
+// newProviderModel creates the language model of one provider. The provider's section,
+// and the top-level settings for the selected provider, override its environment
+// variables; the request timeout applies to providers that have one.
+func newProviderModel(ctx context.Context, cfg config.Config, provider string, tracer *tracing.EnhancedTracer) (llm.LanguageModel, error) {
+	settings := llm.Settings(cfg.LLM.ProviderSettings(provider))
+	if settings["timeout"] == "" {
+		settings["timeout"] = strconv.Itoa(int(cfg.Timeouts.LLMRequest.Seconds()))
+	}
+
+	providerConfig, err := llm.LoadProviderConfig(provider, settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure LLM provider %s: %w", provider, err)
+	}
+	model, err := llm.NewLLM(ctx, providerConfig)
+	if err != nil {
+		return nil, err
+	}
+	tracer.Info("%s LLM created with model %s", provider, llm.ModelName(model))
+	return model, nil
+}
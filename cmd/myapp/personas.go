@@ -0,0 +1,139 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"goproduct/internal/agent"
+	"goproduct/internal/datadir"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/prompts"
+	"goproduct/internal/tools"
+)
+
+// builtinPersonas are the personas shipped with the application
+//
+//go:embed personas/*.yaml
+var builtinPersonas embed.FS
+
+// builtinPrompts are the prompt templates the built-in personas are assembled from
+//
+//go:embed prompts/*.tmpl
+var builtinPrompts embed.FS
+
+// loadPersonas returns the built-in personas together with those in the directory; a
+// persona in the directory replaces the built-in persona of the same name
+func loadPersonas(dir string) ([]agent.PersonaDefinition, error) {
+	builtin, err := fs.Sub(builtinPersonas, "personas")
+	if err != nil {
+		return nil, err
+	}
+	personas, err := agent.LoadPersonas(builtin)
+	if err != nil {
+		return nil, err
+	}
+	for i := range personas {
+		personas[i].Source = "built-in"
+	}
+
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return personas, nil
+	}
+	custom, err := agent.LoadPersonas(os.DirFS(dir))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+	for _, definition := range custom {
+		definition.Source = dir + string(os.PathSeparator) + definition.Source
+		replaced := false
+		for i := range personas {
+			if strings.EqualFold(personas[i].Name, definition.Name) {
+				personas[i], replaced = definition, true
+			}
+		}
+		if !replaced {
+			personas = append(personas, definition)
+		}
+	}
+	return personas, nil
+}
+
+// loadPrompts returns a library of the built-in prompt templates together with those in
+// the directory, which replace the built-in templates of the same name. The templates
+// can interpolate facts from the store.
+func loadPrompts(dir string, store knowledge.Store) (*prompts.Library, error) {
+	library := prompts.New(prompts.WithFuncs(prompts.KnowledgeFuncs(store)))
+	builtin, err := fs.Sub(builtinPrompts, "prompts")
+	if err != nil {
+		return nil, err
+	}
+	if err := library.Load(builtin); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return library, nil
+	}
+	if err := library.Load(os.DirFS(dir)); err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+	return library, nil
+}
+
+// reloadPersona reads the personas and prompt templates of the data directory again and
+// has the agent take on the new definition of its persona, keeping its language model
+func reloadPersona(a *agent.Agent, dir *datadir.Dir, store knowledge.Store) (agent.PersonaDefinition, error) {
+	personas, err := loadPersonas(dir.Personas())
+	if err != nil {
+		return agent.PersonaDefinition{}, err
+	}
+	definition, ok := agent.FindPersona(personas, a.Persona.Name)
+	if !ok {
+		return agent.PersonaDefinition{}, fmt.Errorf("persona %s is no longer defined", a.Persona.Name)
+	}
+	library, err := loadPrompts(dir.Prompts(), store)
+	if err != nil {
+		return agent.PersonaDefinition{}, err
+	}
+	a.ReloadPersona(definition.Persona(a.Persona.LanguageModels.Default))
+	a.SetPrompts(library)
+	return definition, nil
+}
+
+// listPersonas writes a table of the personas
+func listPersonas(w io.Writer, personas []agent.PersonaDefinition) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tROLE\tMODEL\tSOURCE")
+	for _, persona := range personas {
+		model := "default"
+		if !persona.Model.IsDefault() {
+			model = persona.Model.Provider + "/" + persona.Model.Model
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", persona.Name, persona.Role, model, persona.Source)
+	}
+	table.Flush()
+}
+
+// selectTools returns the tools a persona may call: all of them if the persona does not
+// list any, otherwise the listed ones
+func selectTools(persona agent.PersonaDefinition, available []tools.Tool) ([]tools.Tool, error) {
+	if persona.Tools == nil {
+		return available, nil
+	}
+	registry := tools.NewRegistry(available...)
+	selected := make([]tools.Tool, 0, len(persona.Tools))
+	for _, name := range persona.Tools {
+		tool, ok := registry.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("persona %s lists unknown tool %q", persona.Name, name)
+		}
+		selected = append(selected, tool)
+	}
+	return selected, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/agent"
+	"goproduct/internal/config"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/tools"
+)
+
+func TestLoadPersonasOverridesBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"andy.yaml":      "name: andy\nrole: Lead\nsystem_prompt: You lead.\ntools: []\n",
+		"architect.yaml": "name: Ada\nrole: Architect\nsystem_prompt: You design.\nmodel:\n  provider: ollama\n  model: llama3\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	personas, err := loadPersonas(dir)
+	if err != nil {
+		t.Fatalf("Failed to load personas: %v", err)
+	}
+	if len(personas) != 2 {
+		t.Fatalf("Expected the built-in persona to be replaced, got %+v", personas)
+	}
+	if personas[0].Role != "Lead" || personas[0].Source != filepath.Join(dir, "andy.yaml") {
+		t.Errorf("Unexpected persona: %+v", personas[0])
+	}
+
+	selected, err := selectTools(personas[0], []tools.Tool{{Name: "recall"}})
+	if err != nil || len(selected) != 0 {
+		t.Errorf("Expected no tools, got %v, %v", selected, err)
+	}
+	personas[0].Tools = []string{"unknown"}
+	if _, err := selectTools(personas[0], nil); err == nil {
+		t.Error("Expected an error for an unknown tool")
+	}
+
+	var out bytes.Buffer
+	listPersonas(&out, personas)
+	if !strings.Contains(out.String(), "ollama/llama3") {
+		t.Errorf("Expected the model binding in the listing, got:\n%s", out.String())
+	}
+}
+
+func TestLoadPersonasWithoutDirectory(t *testing.T) {
+	personas, err := loadPersonas(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatalf("Failed to load personas: %v", err)
+	}
+	if len(personas) != 1 || personas[0].Name != config.Default().Agent.Persona || personas[0].Source != "built-in" {
+		t.Errorf("Expected only the built-in persona, got %+v", personas)
+	}
+}
+
+func TestBuiltinPersonaPrompt(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	now := time.Now()
+	store.AddRecord(knowledge.Entry{ID: "stack", Category: knowledge.CategoryFact, Content: []byte("Backends are written in Go"), CreatedAt: now, UpdatedAt: now})
+
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "andy"), 0o755)
+	os.WriteFile(filepath.Join(dir, "andy", "company.tmpl"), []byte("a game studio"), 0o644)
+
+	library, err := loadPrompts(dir, store)
+	if err != nil {
+		t.Fatalf("Failed to load prompts: %v", err)
+	}
+	personas, _ := loadPersonas(filepath.Join(t.TempDir(), "missing"))
+	prompt, err := library.RenderText(personas[0].Name, personas[0].SystemPrompt, agent.PromptData{Name: personas[0].Name})
+	if err != nil {
+		t.Fatalf("Failed to render the built-in persona's prompt: %v", err)
+	}
+	for _, want := range []string{"AI Product Owner for a game studio.", "# Communication & Tone", "- Backends are written in Go"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected %q in the prompt, got:\n%s", want, prompt)
+		}
+	}
+}
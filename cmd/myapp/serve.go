@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"goproduct/internal/config"
+	"goproduct/internal/entity"
+	"goproduct/internal/health"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/messaging"
+	"goproduct/internal/metrics"
+	"goproduct/internal/server"
+	"goproduct/internal/tracing"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// serve runs the HTTP API and the WebSocket gateway for the product agent until the
+// process is interrupted. With auth providers configured, users sign in under /api/auth
+// and requests act as the entity of their session; otherwise requests act as one web
+// user and the token, when set, is the bearer token they must present. /healthz and
+// /readyz report the health of the application without authentication, for load
+// balancers and orchestrators, and /metrics its metrics for Prometheus. Without a token
+// or providers it refuses to listen on other than a loopback address unless insecure.
+func serve(ctx context.Context, addr string, insecure bool, cfg config.ServerConfig, bus messaging.MessageBus, productAgent *entity.ProductAgentEntity, store knowledge.Store, reporter health.Reporter, tracer *tracing.EnhancedTracer) error {
+	mux := http.NewServeMux()
+	authenticator, err := newAuthenticator(cfg.Auth)
+	if err != nil {
+		return err
+	}
+	var authenticate server.Authenticate
+	if authenticator != nil {
+		authenticate = server.SessionAuthenticator(authenticator)
+		mux.Handle("/api/auth/", server.NewAuthHandler(authenticator))
+	} else {
+		if cfg.Token == "" {
+			if !insecure && !isLoopback(addr) {
+				return fmt.Errorf("refusing to serve %s without server.token or auth providers; bind a loopback address or pass --insecure", addr)
+			}
+			tracer.Warning("No server token is configured, the HTTP API accepts unauthenticated requests")
+		}
+		authenticate = server.TokenAuthenticator(cfg.Token, server.Principal{EntityID: "web-user", Name: "User"})
+	}
+
+	api := server.NewAPI(bus, productAgent.ID(), store, authenticate)
+	defer api.Close()
+	gateway := server.NewGateway(bus, authenticate)
+	defer gateway.Close()
+
+	mux.Handle("/api/", api)
+	mux.Handle("/ws", gateway)
+	mux.Handle("/healthz", health.HealthHandler(reporter))
+	mux.Handle("/readyz", health.ReadyHandler(reporter))
+	mux.Handle("/metrics", metrics.Default().Handler())
+	httpServer := server.NewServer(addr, mux)
+	if err := httpServer.Start(); err != nil {
+		return err
+	}
+	defer httpServer.Close()
+	tracer.Info("HTTP API listening on %s", httpServer.Addr())
+	fmt.Printf("Serving %s on http://%s (Ctrl+C to stop)\n", productAgent.Name(), httpServer.Addr())
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	tracer.Info("HTTP API shutting down")
+	return nil
+}
+
+// isLoopback reports whether the address only listens on the loopback interface; an
+// address without a host listens on every interface
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"goproduct/internal/config"
+)
+
+func TestIsLoopback(t *testing.T) {
+	tests := map[string]bool{
+		"localhost:8080": true,
+		"127.0.0.1:8080": true,
+		"[::1]:8080":     true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"[::]:8080":      false,
+		"10.0.0.5:8080":  false,
+		"example.com:80": false,
+	}
+	for addr, want := range tests {
+		if got := isLoopback(addr); got != want {
+			t.Errorf("isLoopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestServeRefusesUnauthenticatedPublicAddress(t *testing.T) {
+	err := serve(context.Background(), ":0", false, config.ServerConfig{}, nil, nil, nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "--insecure") {
+		t.Fatalf("Expected serve to refuse an unauthenticated public address, got %v", err)
+	}
+}
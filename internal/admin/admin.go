@@ -0,0 +1,203 @@
+// Package admin serves operational commands, such as changing the trace level or flushing
+// stores, on a local socket. It keeps operational control out of the user-facing chat.
+// Access is limited to the user running the application by the socket's permissions.
+package admin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"goproduct/internal/logging"
+)
+
+// maxCommandLength bounds a command line sent to the server
+const maxCommandLength = 4096
+
+// connectionTimeout bounds how long a client may take to send its command and read the reply
+const connectionTimeout = 30 * time.Second
+
+// Handler runs an admin command with its arguments and returns the reply
+type Handler func(args []string) (string, error)
+
+// Command is an operational command served by the admin server
+type Command struct {
+	Name        string  // Word that invokes the command, e.g. "flush"
+	Usage       string  // Arguments, e.g. "<level>"; empty if there are none
+	Description string  // One-line description shown by help
+	Handler     Handler // Runs the command
+}
+
+// Server accepts one command per connection on a Unix socket and replies with its output
+type Server struct {
+	path     string
+	commands map[string]Command
+	listener net.Listener
+	logger   *logging.Logger
+	mu       sync.RWMutex // Protects commands and listener
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a server for the socket at path. The help command is built in.
+func NewServer(path string) *Server {
+	s := &Server{
+		path:     path,
+		commands: make(map[string]Command),
+		logger:   logging.Get().Component("admin"),
+	}
+	s.Register(Command{Name: "help", Description: "List the admin commands", Handler: s.help})
+	return s
+}
+
+// Register adds a command, replacing any command with the same name
+func (s *Server) Register(command Command) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands[command.Name] = command
+}
+
+// Start listens on the socket and serves commands until Close is called. A socket file
+// left behind by a previous run is replaced.
+func (s *Server) Start() error {
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale admin socket: %w", err)
+	}
+	listener, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on admin socket: %w", err)
+	}
+	if err := os.Chmod(s.path, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict admin socket: %w", err)
+	}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	s.logger.Info("Admin server listening", "socket", s.path)
+	s.wg.Add(1)
+	go s.serve(listener)
+	return nil
+}
+
+// Close stops accepting commands, waits for running ones and removes the socket
+func (s *Server) Close() error {
+	s.mu.Lock()
+	listener := s.listener
+	s.listener = nil
+	s.mu.Unlock()
+
+	if listener == nil {
+		return nil
+	}
+	err := listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+// Execute runs a command line and returns the reply
+func (s *Server) Execute(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "Empty command. Type \"help\" for the list of commands."
+	}
+
+	s.mu.RLock()
+	command, exists := s.commands[fields[0]]
+	s.mu.RUnlock()
+	if !exists {
+		return fmt.Sprintf("Unknown command %q. Type \"help\" for the list of commands.", fields[0])
+	}
+
+	s.logger.Info("Admin command executed", "command", fields[0], "args", strings.Join(fields[1:], " "))
+	reply, err := command.Handler(fields[1:])
+	if err != nil {
+		s.logger.Warn("Admin command failed", "command", fields[0], "error", err)
+		return "Error: " + err.Error()
+	}
+	return reply
+}
+
+// serve accepts connections until the listener is closed
+func (s *Server) serve(listener net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.logger.Error("Admin server stopped accepting connections", "error", err)
+			}
+			return
+		}
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// handle reads one command from the connection and writes its reply
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(connectionTimeout))
+
+	line, err := bufio.NewReader(io.LimitReader(conn, maxCommandLength)).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.logger.Warn("Failed to read admin command", "error", err)
+		return
+	}
+	reply := s.Execute(line)
+	if _, err := io.WriteString(conn, strings.TrimRight(reply, "\n")+"\n"); err != nil {
+		s.logger.Warn("Failed to write admin reply", "error", err)
+	}
+}
+
+// help lists the registered commands
+func (s *Server) help(args []string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.commands))
+	for name := range s.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("Admin commands:\n")
+	for _, name := range names {
+		command := s.commands[name]
+		usage := command.Name
+		if command.Usage != "" {
+			usage += " " + command.Usage
+		}
+		sb.WriteString(fmt.Sprintf("  %s - %s\n", usage, command.Description))
+	}
+	return sb.String(), nil
+}
+
+// Send runs a command on the admin server listening at path and returns its reply
+func Send(path, line string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to admin socket %s (is the application running?): %w", path, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(connectionTimeout))
+
+	if _, err := io.WriteString(conn, strings.TrimSpace(line)+"\n"); err != nil {
+		return "", fmt.Errorf("failed to send admin command: %w", err)
+	}
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to read admin reply: %w", err)
+	}
+	return string(reply), nil
+}
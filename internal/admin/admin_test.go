@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	// Socket paths are limited in length, so avoid deeply nested temp directories
+	dir, err := os.MkdirTemp("", "admin")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "admin.sock")
+
+	server := NewServer(path)
+	server.Register(Command{
+		Name:        "echo",
+		Usage:       "<words>",
+		Description: "Repeat the words",
+		Handler: func(args []string) (string, error) {
+			if len(args) == 0 {
+				return "", errors.New("nothing to echo")
+			}
+			return strings.Join(args, " "), nil
+		},
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server, path
+}
+
+func TestServerCommands(t *testing.T) {
+	_, path := newTestServer(t)
+
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{"echo hello  world", "hello world\n"},
+		{"echo", "Error: nothing to echo\n"},
+		{"reboot", "Unknown command \"reboot\""},
+		{"", "Empty command"},
+		{"help", "  echo <words> - Repeat the words\n  help - List the admin commands\n"},
+	}
+	for _, tt := range tests {
+		reply, err := Send(path, tt.command)
+		if err != nil {
+			t.Fatalf("Send(%q) failed: %v", tt.command, err)
+		}
+		if !strings.Contains(reply, tt.expected) {
+			t.Errorf("Send(%q) = %q, expected it to contain %q", tt.command, reply, tt.expected)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat socket: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket permissions 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	server, path := newTestServer(t)
+	if err := server.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := Send(path, "help"); err == nil {
+		t.Error("Expected error after the server was closed")
+	}
+
+	// A socket file left behind does not prevent a restart
+	os.WriteFile(path, nil, 0600)
+	restarted := NewServer(path)
+	if err := restarted.Start(); err != nil {
+		t.Fatalf("Failed to restart: %v", err)
+	}
+	defer restarted.Close()
+	if _, err := Send(path, "help"); err != nil {
+		t.Errorf("Expected the restarted server to answer: %v", err)
+	}
+}
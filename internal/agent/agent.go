@@ -3,25 +3,51 @@ package agent
 import (
 	"context"
 	"fmt"
+	"goproduct/internal/guardrails"
+	"goproduct/internal/knowledge"
 	"goproduct/internal/llm"
 	"goproduct/internal/logging"
+	"goproduct/internal/prompts"
+	"goproduct/internal/tools"
+	"goproduct/internal/tracing"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Agent struct {
-	Persona   Persona
-	stopCh    chan struct{}
-	_messages chan Message
-	_history  []llm.Message
-	logger    *logging.Logger
+	Persona    Persona
+	stopCh     chan struct{}
+	_messages  chan Message
+	_history   []llm.Message
+	thread     string                   // Conversation thread of _history
+	threads    map[string][]llm.Message // Histories of the other threads by thread ID
+	logger     *logging.Logger
+	backfill   knowledge.Store               // Receives provisional entries for answered questions, nil when off
+	memories   knowledge.Store               // Memories are retrieved from here for each chat message, nil when off
+	access     *knowledge.AccessTracker      // Records which memories were surfaced, nil when off
+	language   string                        // Selected system prompt language, empty for the persona's default
+	teammates  []Teammate                    // Other agents of the team, listed in the system prompt
+	tools      *tools.Registry               // Tools the agent may call while answering, nil when none
+	prompts    *prompts.Library              // Renders the system prompt as a template, nil to use it as is
+	guardrails *guardrails.Pipeline          // Checks chat messages and replies, nil when off
+	hooks      Hooks                         // Run while answering chat messages
+	planner    *Planner                      // Runs the reasoning loop answering chat messages, nil when off
+	restored   []llm.Message                 // Turns of an earlier session put back into the history with the next chat message
+	inFlight   map[string]context.CancelFunc // Cancels the answer to a chat message by message ID
+	queued     map[string]bool               // Queued chat messages by ID, false once cancelled
+	mutex      sync.Mutex                    // Protects the persona's prompts and memory template, language, teammates, tools, prompts, guardrails, hooks, planner, restored, inFlight and queued
+
+	conversations knowledge.Store // Chat turns and summaries are recorded here, nil when off
+	contextBudget int             // Estimated tokens of history kept before summarizing
+	summaries     []string        // Summaries of older turns, injected into the system prompt
 }
 
 func (a *Agent) Start(ctx context.Context) {
 	// Ensure logger is initialized
 	if a.logger == nil {
-		a.logger = logging.Get()
+		a.logger = logging.Get().Component("agent")
 	}
 
 	a.logger.Info("Agent starting", "name", a.Persona.Name, "role", a.Persona.Role)
@@ -61,40 +87,88 @@ func (a *Agent) handleMessage(msg Message) {
 	case "chat":
 		a.logger.Debug("Handling chat message", "message_id", msg.Id)
 		a.handleChat(msg)
+	case closeThreadType:
+		a.dropThread(msg.Thread)
 	default:
 		a.logger.Warn("Received unknown message type", "message_id", msg.Id, "type", msg.Type)
 	}
 }
 
+// messageContext returns the context of the work done answering a message, attributing
+// its usage to the agent and the sender and its trace events to the message's correlation
+func messageContext(agentName string, msg Message) context.Context {
+	ctx := llm.WithUsageScope(context.Background(), agentName, msg.From)
+	return tracing.WithCorrelationID(ctx, msg.CorrelationID)
+}
+
 func (a *Agent) handleChat(msg Message) {
 	a.logger.Debug("Processing chat message",
 		"message_id", msg.Id,
+		"correlation_id", msg.CorrelationID,
 		"from", msg.From,
 		"content_length", len(msg.Content))
 
+	ctx, done := a.track(messageContext(a.Persona.Name, msg), msg.Id)
+	defer done()
+	if ctx.Err() != nil {
+		a.replyCancelled(msg)
+		return
+	}
+
+	msg, blocked := a.checkInbound(ctx, msg)
+	if blocked {
+		a.refuse(msg)
+		return
+	}
+
+	a.useThread(msg.Thread)
+	systemPrompt := a.systemPrompt() + a.summaryPrompt()
 	if len(a._history) == 0 {
 		a.logger.Debug("Initializing chat history with system prompt",
-			"prompt_length", len(a.Persona.SystemPrompt))
+			"prompt_length", len(systemPrompt))
 		a._history = append(a._history, llm.Message{
 			Role:    "system",
-			Content: a.Persona.SystemPrompt,
+			Content: systemPrompt,
 		})
+	} else if a._history[0].Content != systemPrompt {
+		// The language or the conversation summaries changed
+		a.logger.Debug("Switching system prompt", "language", a.Language())
+		a._history[0].Content = systemPrompt
+	}
+	if restored := a.takeRestored(); len(restored) > 0 {
+		a.logger.Debug("Restoring chat history", "messages", len(restored))
+		a._history = append(append([]llm.Message{a._history[0]}, restored...), a._history[1:]...)
 	}
 
 	a._history = append(a._history, llm.Message{
 		Role:    "user",
 		Content: msg.Content,
+		Images:  msg.Images,
 	})
 
 	a.logger.Debug("Generating LLM response",
 		"message_id", msg.Id,
 		"history_length", len(a._history))
 
-	response, err := a.Persona.LanguageModels.Default.GenerateChat(context.Background(), a._history)
+	messages, err := a.runBefore(ctx, msg, a.withMemories(msg.Content))
+	var response string
+	if err == nil {
+		response, err = a.generate(ctx, msg, messages)
+	}
+	if err == nil {
+		response, err = a.runAfter(ctx, msg, response)
+	}
+	if err != nil && ctx.Err() != nil {
+		// Cancelled; the message is left out of the history as if it was never sent
+		a._history = a._history[:len(a._history)-1]
+		a.replyCancelled(msg)
+		return
+	}
 	if err != nil {
-		a.handleLLMError(msg, err)
+		a.handleLLMError(ctx, msg, err)
 		return
 	}
+	response = a.checkOutbound(ctx, msg, response)
 
 	a.logger.Debug("LLM response received",
 		"message_id", msg.Id,
@@ -105,6 +179,14 @@ func (a *Agent) handleChat(msg Message) {
 		Content: response,
 	})
 
+	if a.backfill != nil {
+		a.backfillAnswer(msg, response)
+	}
+	if a.conversations != nil {
+		a.recordTurn(msg, response)
+		a.compactHistory(msg)
+	}
+
 	// Create a proper response message with a new ID that references the original
 	responseContent := fmt.Sprintf("%s", response)
 	responseMsg := Message{
@@ -127,13 +209,16 @@ func (a *Agent) handleChat(msg Message) {
 	msg.ResponseReady <- responseMsg
 }
 
-func (a *Agent) handleLLMError(msg Message, err error) {
+func (a *Agent) handleLLMError(ctx context.Context, msg Message, err error) {
 	a.logger.Error("LLM generation failed",
 		"error", err,
 		"message_id", msg.Id)
 
-	// Prepare fallback response text
+	// Prepare fallback response text, unless an error hook replies instead
 	content := "I'm out of office today. If you need immediate assistance, please contact Tom Reynolds."
+	if reply, ok := a.runError(ctx, msg, err); ok {
+		content = reply
+	}
 
 	// Create a proper response message that references the original
 	responseID := uuid.New().String()
@@ -185,6 +270,7 @@ func (a *Agent) Chat(from string, message string) Message {
 	}
 
 	a.logger.Debug("Sending message to agent processing queue", "message_id", id)
+	a.enqueue(id)
 	a._messages <- msg
 	return msg
 }
@@ -194,7 +280,7 @@ func (a *Agent) Chat(from string, message string) Message {
 func (a *Agent) HandleExternalMessage(msg Message) {
 	// Ensure logger is initialized
 	if a.logger == nil {
-		a.logger = logging.Get()
+		a.logger = logging.Get().Component("agent")
 	}
 
 	a.logger.Debug("Handling external message",
@@ -204,11 +290,12 @@ func (a *Agent) HandleExternalMessage(msg Message) {
 
 	// Process the message through normal flow
 	a.logger.Debug("Forwarding external message to internal queue", "message_id", msg.Id)
+	a.enqueue(msg.Id)
 	a._messages <- msg
 }
 
 func NewAgent(p Persona) *Agent {
-	logger := logging.Get()
+	logger := logging.Get().Component("agent")
 	logger.Info("Creating new agent", "name", p.Name, "role", p.Role)
 
 	return &Agent{
@@ -221,22 +308,29 @@ func NewAgent(p Persona) *Agent {
 }
 
 type Message struct {
-	Content       string       `json:"content"`
-	Created       time.Time    `json:"created"`
-	Id            string       `json:"id"`
-	From          string       `json:"from"`
-	To            []string     `json:"to"`
-	Type          string       `json:"type"`
-	ResponseReady chan Message `json:"response_ready"`
-	OriginalId    string       `json:"original_id,omitempty"` // References original message in a conversation
+	Content       string            `json:"content"`
+	Images        []llm.Image       `json:"images,omitempty"` // Screenshots and other images attached to a chat message
+	Created       time.Time         `json:"created"`
+	Id            string            `json:"id"`
+	From          string            `json:"from"`
+	To            []string          `json:"to"`
+	Type          string            `json:"type"`
+	ResponseReady chan Message      `json:"response_ready"`
+	OriginalId    string            `json:"original_id,omitempty"`    // References original message in a conversation
+	Thread        string            `json:"thread,omitempty"`         // Conversation thread with its own history; "" for the default thread
+	CorrelationID string            `json:"correlation_id,omitempty"` // Shared by the trace events of everything done answering the message
+	OnToken       func(text string) `json:"-"`                        // Receives the reply as the model writes it, when it can be streamed
 }
 
 type Persona struct {
-	Name           string         `json:"name"`
-	Role           string         `json:"role"`
-	Type           string         `json:"type"`
-	SystemPrompt   string         `json:"system_prompt"`
-	LanguageModels LanguageModels `json:"language_models"`
+	Name           string            `json:"name"`
+	Role           string            `json:"role"`
+	Type           string            `json:"type"`
+	SystemPrompt   string            `json:"system_prompt"`
+	Language       string            `json:"language"`       // Language of SystemPrompt, e.g. "en"
+	SystemPrompts  map[string]string `json:"system_prompts"` // System prompt variants by language, e.g. "es" or "pt-BR"
+	Memory         MemoryTemplate    `json:"memory"`         // How retrieved memories are framed in the prompt
+	LanguageModels LanguageModels    `json:"language_models"`
 }
 
 type LanguageModels struct {
@@ -150,3 +150,32 @@ func TestContextCancellation(t *testing.T) {
 	agent.Start(ctx2)
 	agent.Stop()
 }
+
+func TestAgentDraftMessage(t *testing.T) {
+	persona := Persona{
+		Name: "TestAgent",
+		Role: "Assistant",
+		Type: "Test",
+		LanguageModels: LanguageModels{
+			Default: &MockLLM{},
+		},
+	}
+	agent := NewAgent(persona)
+
+	draft, err := agent.DraftMessage(context.Background(), "Alex", "Design", "we're slipping the date")
+	if err != nil {
+		t.Fatalf("Failed to draft message: %v", err)
+	}
+	if draft != "ECHO: ECHO: Tell Design: we're slipping the date" {
+		t.Errorf("Unexpected draft: %q", draft)
+	}
+
+	// Drafting must not touch the agent's chat history
+	if len(agent._history) != 0 {
+		t.Errorf("Expected empty chat history after drafting, got %d messages", len(agent._history))
+	}
+
+	if _, err := agent.DraftMessage(context.Background(), "Alex", "Design", "  "); err == nil {
+		t.Error("Expected error for empty instruction")
+	}
+}
@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
+)
+
+// Backfill metadata, tags and statuses of provisional knowledge entries
+const (
+	BackfillTag         = "backfill"     // Tag of every entry captured from an answered question
+	BackfillReviewTag   = "needs-review" // Tag removed by the reviewer once the entry is curated
+	BackfillProvisional = "provisional"  // Status of an entry that has not been reviewed yet
+
+	backfillQuestionKey = "question_key" // Normalized question used to recognize repeats
+	backfillAskedCount  = "asked_count"  // How often the question was asked while provisional
+)
+
+// factualQuestionStarters are the first words of questions that ask for a fact
+var factualQuestionStarters = map[string]bool{
+	"what": true, "who": true, "whom": true, "whose": true, "when": true, "where": true, "which": true,
+	"how": true, "is": true, "are": true, "was": true, "were": true, "does": true, "do": true, "did": true,
+}
+
+// opinionWords mark questions about the speaker, the agent or advice rather than facts
+var opinionWords = map[string]bool{
+	"you": true, "your": true, "i": true, "me": true, "my": true,
+	"should": true, "would": true, "could": true,
+}
+
+// hedgePhrases mark answers the model was not confident about
+var hedgePhrases = []string{
+	"not sure", "not certain", "don't know", "do not know", "i think", "i believe", "i guess",
+	"maybe", "perhaps", "possibly", "probably", "might", "unclear", "it depends",
+	"can't", "cannot", "unable to", "don't have", "do not have", "as an ai",
+}
+
+// SetKnowledgeBackfill makes the agent capture confidently answered factual questions
+// as provisional knowledge entries flagged for human review. A nil store turns it off.
+func (a *Agent) SetKnowledgeBackfill(store knowledge.Store) {
+	a.backfill = store
+}
+
+// backfillAnswer records a generated answer to a factual question as provisional knowledge,
+// unless the store already holds curated knowledge that answers it
+func (a *Agent) backfillAnswer(msg Message, answer string) {
+	question := strings.TrimSpace(msg.Content)
+	if !isFactualQuestion(question) || !isConfidentAnswer(answer) {
+		return
+	}
+
+	id, err := a.captureAnswer(msg, question, strings.TrimSpace(answer))
+	if err != nil {
+		a.logger.Error("Failed to backfill knowledge from answer", "message_id", msg.Id, "error", err)
+		return
+	}
+	if id != "" {
+		a.logger.Debug("Backfilled provisional knowledge from answer", "message_id", msg.Id, "entry_id", id)
+	}
+}
+
+// captureAnswer adds a provisional entry for the question, or counts a repeat of an
+// existing one. Returns the ID of the entry, or an empty ID if nothing was captured.
+func (a *Agent) captureAnswer(msg Message, question, answer string) (string, error) {
+	key := normalizeQuestion(question)
+
+	// Retrieval would have answered from curated knowledge; only capture what is missing
+	results, err := a.backfill.FullTextSearch(question, knowledge.WithSearchMatchAll(), knowledge.WithSearchCategories(knowledge.CategoryFact))
+	if err != nil {
+		return "", fmt.Errorf("failed to search knowledge: %w", err)
+	}
+	for _, result := range results {
+		if result.Entry.Metadata["status"] != BackfillProvisional {
+			return "", nil
+		}
+	}
+
+	existing, err := a.backfill.SearchRecords(knowledge.Filter{
+		RootGroup: knowledge.FilterGroup{
+			Operator:   knowledge.OpAnd,
+			Conditions: []knowledge.Condition{{Field: "Tags", Operator: "CONTAINS", Value: BackfillTag}},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to search backfilled knowledge: %w", err)
+	}
+	for _, entry := range existing {
+		if entry.Metadata[backfillQuestionKey] != key {
+			continue
+		}
+		if entry.Metadata["status"] != BackfillProvisional {
+			// Already reviewed
+			return "", nil
+		}
+		count, _ := strconv.Atoi(entry.Metadata[backfillAskedCount])
+		entry.Metadata[backfillAskedCount] = strconv.Itoa(count + 1)
+		entry.UpdatedAt = time.Now()
+		if err := a.backfill.UpdateRecord(entry); err != nil {
+			return "", fmt.Errorf("failed to update backfilled knowledge %s: %w", entry.ID, err)
+		}
+		return entry.ID, a.backfill.Flush()
+	}
+
+	provenance := knowledge.FromGeneration(modelName(a.Persona.LanguageModels.Default), "")
+	provenance.MessageID = msg.Id
+	provenance.ActorID = a.Persona.Name
+	provenance.ActorType = "agent"
+
+	entry := knowledge.Entry{
+		ID:          uuid.New().String(),
+		Category:    knowledge.CategoryFact,
+		ContentType: knowledge.ContentTypeText,
+		Content:     []byte(answer),
+		Importance:  knowledge.ImportanceLow,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		SourceID:    msg.From,
+		SourceType:  "chat",
+		OwnerID:     a.Persona.Name,
+		OwnerType:   "agent",
+		SubjectIDs:  []string{},
+		Tags:        []string{BackfillTag, BackfillReviewTag},
+		References:  []knowledge.Reference{},
+		Metadata: map[string]string{
+			"title":             question,
+			"question":          question,
+			"status":            BackfillProvisional,
+			backfillQuestionKey: key,
+			backfillAskedCount:  "1",
+		},
+		Provenance: []knowledge.ProvenanceStep{provenance},
+	}
+	if err := a.backfill.AddRecord(entry); err != nil {
+		return "", fmt.Errorf("failed to add backfilled knowledge: %w", err)
+	}
+	return entry.ID, a.backfill.Flush()
+}
+
+// isFactualQuestion reports whether the input asks for a fact rather than an opinion,
+// advice or small talk
+func isFactualQuestion(input string) bool {
+	words := strings.Fields(strings.ToLower(strings.TrimRight(input, "?!. ")))
+	if len(words) < 3 || !factualQuestionStarters[words[0]] {
+		return false
+	}
+	for _, word := range words {
+		if opinionWords[strings.Trim(word, ",;:'\"")] {
+			return false
+		}
+	}
+	return true
+}
+
+// isConfidentAnswer reports whether the answer is a statement without hedging
+func isConfidentAnswer(answer string) bool {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "" || strings.HasSuffix(answer, "?") {
+		return false
+	}
+	answer = strings.ReplaceAll(answer, "’", "'")
+	for _, phrase := range hedgePhrases {
+		if strings.Contains(answer, phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeQuestion reduces a question to lower-case words so rephrasings in case and
+// punctuation are recognized as the same question
+func normalizeQuestion(question string) string {
+	words := strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	return strings.Join(words, " ")
+}
+
+// modelName returns the name of the language model if it reports one
+func modelName(model llm.LanguageModel) string {
+	if name := llm.ModelName(model); name != "" {
+		return name
+	}
+	if named, ok := model.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", model)
+}
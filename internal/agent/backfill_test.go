@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
+)
+
+// answerLLM answers every chat with the same text
+type answerLLM struct {
+	answer string
+}
+
+func (m *answerLLM) GenerateChat(ctx context.Context, messages []llm.Message) (string, error) {
+	return m.answer, nil
+}
+
+func (m *answerLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return m.answer, nil
+}
+
+func newBackfillAgent(t *testing.T, answer string) (*Agent, knowledge.Store) {
+	t.Helper()
+	store, err := knowledge.NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	agent := NewAgent(Persona{
+		Name:           "TestAgent",
+		LanguageModels: LanguageModels{Default: &answerLLM{answer: answer}},
+	})
+	agent.SetKnowledgeBackfill(store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	agent.Start(ctx)
+	t.Cleanup(func() {
+		agent.Stop()
+		cancel()
+	})
+	return agent, store
+}
+
+func askAgent(t *testing.T, agent *Agent, question string) {
+	t.Helper()
+	msg := agent.Chat("TestUser", question)
+	select {
+	case <-msg.ResponseReady:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for response")
+	}
+}
+
+func backfilledEntries(t *testing.T, store knowledge.Store) []knowledge.Entry {
+	t.Helper()
+	entries, err := store.SearchRecords(knowledge.Filter{
+		RootGroup: knowledge.FilterGroup{
+			Operator:   knowledge.OpAnd,
+			Conditions: []knowledge.Condition{{Field: "Tags", Operator: "CONTAINS", Value: BackfillTag}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to search store: %v", err)
+	}
+	return entries
+}
+
+func TestBackfillCapturesConfidentAnswer(t *testing.T) {
+	agent, store := newBackfillAgent(t, "The billing service is owned by the payments team.")
+
+	askAgent(t, agent, "Who owns the billing service?")
+
+	entries := backfilledEntries(t, store)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 backfilled entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Category != knowledge.CategoryFact {
+		t.Errorf("Expected fact category, got %s", entry.Category)
+	}
+	if entry.Metadata["status"] != BackfillProvisional {
+		t.Errorf("Expected provisional status, got %q", entry.Metadata["status"])
+	}
+	if entry.Metadata["question"] != "Who owns the billing service?" {
+		t.Errorf("Unexpected question metadata: %q", entry.Metadata["question"])
+	}
+	if string(entry.Content) != "The billing service is owned by the payments team." {
+		t.Errorf("Unexpected content: %q", entry.Content)
+	}
+	if len(entry.Provenance) == 0 || entry.Provenance[0].Origin != knowledge.OriginGeneration {
+		t.Errorf("Expected generation provenance, got %+v", entry.Provenance)
+	}
+}
+
+func TestBackfillCountsRepeatedQuestions(t *testing.T) {
+	agent, store := newBackfillAgent(t, "The billing service is owned by the payments team.")
+
+	askAgent(t, agent, "Who owns the billing service?")
+	askAgent(t, agent, "who owns the billing service")
+
+	entries := backfilledEntries(t, store)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 backfilled entry, got %d", len(entries))
+	}
+	if entries[0].Metadata["asked_count"] != "2" {
+		t.Errorf("Expected asked_count 2, got %q", entries[0].Metadata["asked_count"])
+	}
+}
+
+func TestBackfillSkipsCuratedKnowledge(t *testing.T) {
+	agent, store := newBackfillAgent(t, "The billing service is owned by the payments team.")
+
+	store.AddRecord(knowledge.Entry{
+		ID:          "curated",
+		Category:    knowledge.CategoryFact,
+		ContentType: knowledge.ContentTypeText,
+		Content:     []byte("The payments team owns the billing service."),
+	})
+
+	askAgent(t, agent, "Who owns the billing service?")
+
+	if entries := backfilledEntries(t, store); len(entries) != 0 {
+		t.Errorf("Expected no backfill when curated knowledge exists, got %d entries", len(entries))
+	}
+}
+
+func TestBackfillSkipsNonFactualOrHedged(t *testing.T) {
+	tests := []struct {
+		name     string
+		question string
+		answer   string
+	}{
+		{"statement", "Let's plan the release.", "Sure, here is a plan."},
+		{"greeting", "How are you?", "Doing great, thanks."},
+		{"advice", "What should we build next?", "Build the mobile app."},
+		{"hedged", "When is the release date?", "I'm not sure, maybe next week."},
+		{"question back", "Which platform ships first?", "Which platform do you prefer?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent, store := newBackfillAgent(t, tt.answer)
+			askAgent(t, agent, tt.question)
+			if entries := backfilledEntries(t, store); len(entries) != 0 {
+				t.Errorf("Expected no backfill, got %d entries", len(entries))
+			}
+		})
+	}
+}
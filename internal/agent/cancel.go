@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CancelledReply is the reply to a chat message whose answer was cancelled
+const CancelledReply = "Request cancelled."
+
+// Cancel stops answering a chat message: the language model call of a message being
+// answered is cancelled, and a queued message is skipped when its turn comes. It returns
+// whether the agent had the message.
+func (a *Agent) Cancel(messageID string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if cancel, inFlight := a.inFlight[messageID]; inFlight {
+		cancel()
+		return true
+	}
+	if a.queued[messageID] {
+		a.queued[messageID] = false
+		return true
+	}
+	return false
+}
+
+// Busy checks if the agent is answering or has queued chat messages
+func (a *Agent) Busy() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, queued := range a.queued {
+		if queued {
+			return true
+		}
+	}
+	return len(a.inFlight) > 0
+}
+
+// enqueue records that a message was queued, so that it can be cancelled before its turn
+func (a *Agent) enqueue(messageID string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.queued == nil {
+		a.queued = make(map[string]bool)
+	}
+	a.queued[messageID] = true
+}
+
+// track returns the context of answering a message, which Cancel cancels, and a function
+// to call once it is answered
+func (a *Agent) track(parent context.Context, messageID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if queued, known := a.queued[messageID]; known && !queued {
+		// Cancelled while queued
+		cancel()
+	}
+	delete(a.queued, messageID)
+	if a.inFlight == nil {
+		a.inFlight = make(map[string]context.CancelFunc)
+	}
+	a.inFlight[messageID] = cancel
+
+	return ctx, func() {
+		a.mutex.Lock()
+		delete(a.inFlight, messageID)
+		a.mutex.Unlock()
+		cancel()
+	}
+}
+
+// replyCancelled answers a message whose answer was cancelled with CancelledReply
+func (a *Agent) replyCancelled(msg Message) {
+	a.logger.Info("Chat message cancelled", "message_id", msg.Id, "from", msg.From)
+	msg.ResponseReady <- Message{
+		Content:       CancelledReply,
+		From:          a.Persona.Name,
+		To:            []string{msg.From},
+		Type:          "chat",
+		ResponseReady: msg.ResponseReady,
+		Created:       time.Now(),
+		Id:            uuid.New().String(),
+		OriginalId:    msg.Id,
+	}
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"goproduct/internal/llm"
+)
+
+// blockingLLM answers only once its context is done, reporting each call as it starts
+type blockingLLM struct {
+	MockLLM
+	started chan string
+}
+
+func (m *blockingLLM) GenerateChat(ctx context.Context, messages []llm.Message) (string, error) {
+	m.started <- messages[len(messages)-1].Content
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestCancel(t *testing.T) {
+	model := &blockingLLM{started: make(chan string, 2)}
+	agent := NewAgent(Persona{Name: "TestAgent", LanguageModels: LanguageModels{Default: model}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agent.Start(ctx)
+	defer agent.Stop()
+
+	first := agent.Chat("TestUser", "Draft the roadmap")
+	queued := agent.Chat("TestUser", "And the budget")
+	select {
+	case <-model.started:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the model to be called")
+	}
+
+	if !agent.Cancel(queued.Id) {
+		t.Error("Expected the queued message to be cancelled")
+	}
+	if !agent.Cancel(first.Id) {
+		t.Error("Expected the message being answered to be cancelled")
+	}
+	if agent.Cancel("unknown") {
+		t.Error("Expected an unknown message not to be cancelled")
+	}
+
+	for _, msg := range []Message{first, queued} {
+		select {
+		case response := <-msg.ResponseReady:
+			if response.Content != CancelledReply || response.OriginalId != msg.Id {
+				t.Errorf("Expected %q in reply to %s, got %q", CancelledReply, msg.Id, response.Content)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected a reply to %s", msg.Id)
+		}
+	}
+	if len(model.started) != 0 {
+		t.Error("Expected the queued message not to reach the model")
+	}
+	if len(agent._history) != 1 {
+		t.Errorf("Expected only the system prompt left in the history, got %+v", agent._history)
+	}
+}
@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goproduct/internal/llm"
+	"goproduct/internal/logging"
+	"strings"
+)
+
+// composePrompt instructs the language model to write a message on someone else's behalf
+const composePrompt = `You are %s, drafting a message that %s will send to %s.
+Write only the message body, in the first person from %s's point of view.
+Keep it short, clear and friendly. Do not add a subject line, greeting notes or explanations.`
+
+// DraftMessage asks the agent to draft a message that the sender will send to the recipient.
+// The draft is generated outside the agent's chat history so it does not pollute the conversation.
+func (a *Agent) DraftMessage(ctx context.Context, senderName, recipientName, instruction string) (string, error) {
+	if a.logger == nil {
+		a.logger = logging.Get().Component("agent")
+	}
+
+	if strings.TrimSpace(instruction) == "" {
+		return "", errors.New("nothing to draft: instruction is empty")
+	}
+
+	model := a.Persona.LanguageModels.Default
+	if model == nil {
+		return "", errors.New("agent has no language model configured")
+	}
+
+	a.logger.Debug("Drafting message on behalf of sender",
+		"agent", a.Persona.Name,
+		"sender", senderName,
+		"recipient", recipientName,
+		"instruction_length", len(instruction))
+
+	messages := []llm.Message{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf(composePrompt, a.Persona.Name, senderName, recipientName, senderName),
+		},
+		{
+			Role:    "user",
+			Content: fmt.Sprintf("Tell %s: %s", recipientName, instruction),
+		},
+	}
+
+	draft, err := model.GenerateChat(ctx, messages)
+	if err != nil {
+		a.logger.Error("Failed to draft message", "error", err, "recipient", recipientName)
+		return "", fmt.Errorf("failed to draft message: %w", err)
+	}
+
+	draft = strings.TrimSpace(draft)
+	if draft == "" {
+		return "", errors.New("language model returned an empty draft")
+	}
+
+	return draft, nil
+}
@@ -0,0 +1,27 @@
+package agent
+
+import "goproduct/internal/llm"
+
+// replyReserve is the part of the context window kept free for the model's reply
+var replyReserve = llm.DefaultRequestOptions.MaxTokens
+
+// contextLimit returns the tokenizer of the agent's model and the tokens of a request
+// that fit its context window with room for the reply
+func (a *Agent) contextLimit() (llm.Tokenizer, int) {
+	name := llm.ModelName(a.Persona.LanguageModels.Default)
+	return llm.TokenizerFor(name), llm.ContextWindow(name) - replyReserve
+}
+
+// fitContext drops the oldest messages of a request that does not fit the model's context
+// window, so providers neither reject nor silently truncate it
+func (a *Agent) fitContext(msg Message, messages []llm.Message) ([]llm.Message, error) {
+	tokenizer, limit := a.contextLimit()
+	fitted, err := llm.FitMessages(tokenizer, messages, limit)
+	if err == nil && len(fitted) < len(messages) {
+		a.logger.Warn("Trimmed history to fit the context window",
+			"message_id", msg.Id,
+			"dropped", len(messages)-len(fitted),
+			"limit", limit)
+	}
+	return fitted, err
+}
@@ -0,0 +1,212 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
+)
+
+// Conversation memory tags
+const (
+	ConversationTag        = "conversation"         // Tag of every recorded chat turn
+	ConversationSummaryTag = "conversation-summary" // Tag of the summaries of older turns
+)
+
+// Conversation memory defaults
+const (
+	DefaultContextBudget = 8000 // Tokens of history sent to the model before older turns are summarized
+	DefaultKeepMessages  = 6    // Most recent history messages never summarized
+	maxPromptSummaries   = 3    // Most recent summaries injected into the system prompt
+)
+
+// summarizePrompt asks the model to summarize older turns of a conversation
+const summarizePrompt = `Summarize the following conversation in a few sentences. Keep every fact, decision, ` +
+	`name, number and open question; leave out greetings and small talk. Reply with the summary only.
+
+%s`
+
+// SetConversationMemory makes the agent record every chat turn in the store. When the
+// history grows past the budget in tokens, or past the model's context window, older
+// turns are summarized by the model; summaries are stored as facts and injected into the
+// system prompt in their place. Summaries stored in earlier sessions are loaded. A nil
+// store turns it off, and a budget of 0 or less uses DefaultContextBudget.
+func (a *Agent) SetConversationMemory(store knowledge.Store, budget int) error {
+	if budget <= 0 {
+		budget = DefaultContextBudget
+	}
+	a.conversations = store
+	a.contextBudget = budget
+	a.summaries = nil
+	if store == nil {
+		return nil
+	}
+
+	entries, err := store.SearchRecords(knowledge.Filter{
+		RootGroup: knowledge.FilterGroup{
+			Operator: knowledge.OpAnd,
+			Conditions: []knowledge.Condition{
+				{Field: "Tags", Operator: "CONTAINS", Value: ConversationSummaryTag},
+				{Field: "OwnerID", Operator: "=", Value: a.Persona.Name},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load conversation summaries: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].CreatedAt.Before(entries[j].CreatedAt) })
+	for _, entry := range entries {
+		a.summaries = append(a.summaries, strings.TrimSpace(string(entry.Content)))
+	}
+	return nil
+}
+
+// summaryPrompt returns the system prompt section with the latest conversation
+// summaries, or "" if there are none
+func (a *Agent) summaryPrompt() string {
+	summaries := a.summaries
+	if len(summaries) == 0 {
+		return ""
+	}
+	if len(summaries) > maxPromptSummaries {
+		summaries = summaries[len(summaries)-maxPromptSummaries:]
+	}
+	var sb strings.Builder
+	sb.WriteString("\n# Earlier in the conversation\n")
+	for _, summary := range summaries {
+		fmt.Fprintf(&sb, "- %s\n", summary)
+	}
+	return sb.String()
+}
+
+// RestoreHistory continues an earlier session: the messages, its user and assistant
+// turns in order, are put back into the chat history, after the system prompt, when the
+// next chat message is handled. Turns that no longer fit are summarized as usual.
+func (a *Agent) RestoreHistory(messages []llm.Message) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.restored = append([]llm.Message(nil), messages...)
+}
+
+// takeRestored returns the turns to restore, if any, and clears them
+func (a *Agent) takeRestored() []llm.Message {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	restored := a.restored
+	a.restored = nil
+	return restored
+}
+
+// recordTurn stores a message and the agent's answer to it in the conversation memory
+func (a *Agent) recordTurn(msg Message, answer string) {
+	turns := []struct{ role, from, content string }{
+		{"user", msg.From, msg.Content},
+		{"assistant", a.Persona.Name, answer},
+	}
+	now := time.Now()
+	for _, turn := range turns {
+		entry := knowledge.Entry{
+			ID:          uuid.New().String(),
+			Category:    knowledge.CategoryMessage,
+			ContentType: knowledge.ContentTypeText,
+			Content:     []byte(turn.content),
+			Importance:  knowledge.ImportanceLow,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			SourceID:    turn.from,
+			SourceType:  "chat",
+			OwnerID:     a.Persona.Name,
+			OwnerType:   "agent",
+			SubjectIDs:  []string{msg.From},
+			Tags:        []string{ConversationTag},
+			References:  []knowledge.Reference{},
+			Metadata: map[string]string{
+				"role":       turn.role,
+				"message_id": msg.Id,
+			},
+		}
+		if msg.Thread != "" {
+			entry.Metadata["thread_id"] = msg.Thread
+		}
+		if err := a.conversations.AddRecord(entry); err != nil {
+			a.logger.Error("Failed to record conversation turn", "message_id", msg.Id, "error", err)
+			return
+		}
+	}
+	if err := a.conversations.Flush(); err != nil {
+		a.logger.Error("Failed to flush conversation turn", "message_id", msg.Id, "error", err)
+	}
+}
+
+// compactHistory summarizes the older turns of the history once it exceeds the context
+// budget or the model's context window. The summary replaces them; the system prompt and
+// the latest messages stay.
+func (a *Agent) compactHistory(msg Message) {
+	tokenizer, limit := a.contextLimit()
+	if llm.CountMessageTokens(tokenizer, a._history) <= min(a.contextBudget, limit) || len(a._history) <= DefaultKeepMessages+1 {
+		return
+	}
+	older := a._history[1 : len(a._history)-DefaultKeepMessages]
+
+	var transcript strings.Builder
+	for _, m := range older {
+		fmt.Fprintf(&transcript, "%s: %s\n", m.Role, m.Content)
+	}
+	ctx := messageContext(a.Persona.Name, msg)
+	summary, err := a.Persona.LanguageModels.Default.GenerateResponse(ctx, fmt.Sprintf(summarizePrompt, transcript.String()))
+	if err != nil {
+		a.logger.Error("Failed to summarize conversation", "message_id", msg.Id, "error", err)
+		return
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return
+	}
+
+	provenance := knowledge.FromGeneration(modelName(a.Persona.LanguageModels.Default), "")
+	provenance.MessageID = msg.Id
+	provenance.ActorID = a.Persona.Name
+	provenance.ActorType = "agent"
+	now := time.Now()
+	entry := knowledge.Entry{
+		ID:          uuid.New().String(),
+		Category:    knowledge.CategoryFact,
+		ContentType: knowledge.ContentTypeText,
+		Content:     []byte(summary),
+		Importance:  knowledge.ImportanceMedium,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		SourceID:    msg.From,
+		SourceType:  "chat",
+		OwnerID:     a.Persona.Name,
+		OwnerType:   "agent",
+		SubjectIDs:  []string{msg.From},
+		Tags:        []string{ConversationSummaryTag},
+		References:  []knowledge.Reference{},
+		Metadata: map[string]string{
+			"title":    "Conversation summary",
+			"messages": strconv.Itoa(len(older)),
+		},
+		Provenance: []knowledge.ProvenanceStep{provenance},
+	}
+	if err := a.conversations.AddRecord(entry); err != nil {
+		a.logger.Error("Failed to store conversation summary", "message_id", msg.Id, "error", err)
+		return
+	}
+	if err := a.conversations.Flush(); err != nil {
+		a.logger.Error("Failed to flush conversation summary", "message_id", msg.Id, "error", err)
+	}
+
+	a.summaries = append(a.summaries, summary)
+	history := make([]llm.Message, 0, DefaultKeepMessages+1)
+	history = append(history, a._history[0])
+	a._history = append(history, a._history[len(a._history)-DefaultKeepMessages:]...)
+	a._history[0].Content = a.systemPrompt() + a.summaryPrompt()
+	a.logger.Debug("Summarized conversation history", "message_id", msg.Id, "summarized", len(older), "entry_id", entry.ID)
+}
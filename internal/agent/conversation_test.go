@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
+)
+
+// summarizingLLM answers chats with a fixed answer and summarizes with a fixed summary
+type summarizingLLM struct {
+	answerLLM
+	summary string
+}
+
+func (m *summarizingLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return m.summary, nil
+}
+
+func taggedEntries(t *testing.T, store knowledge.Store, tag string) []knowledge.Entry {
+	t.Helper()
+	entries, err := store.SearchRecords(knowledge.Filter{
+		RootGroup: knowledge.FilterGroup{
+			Operator:   knowledge.OpAnd,
+			Conditions: []knowledge.Condition{{Field: "Tags", Operator: "CONTAINS", Value: tag}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to search store: %v", err)
+	}
+	return entries
+}
+
+func TestConversationMemorySummarizesOlderTurns(t *testing.T) {
+	store, err := knowledge.NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	model := &summarizingLLM{answerLLM: answerLLM{answer: "Noted, that is a long answer."}, summary: "The user planned the March launch."}
+	agent := NewAgent(Persona{Name: "TestAgent", LanguageModels: LanguageModels{Default: model}})
+	if err := agent.SetConversationMemory(store, 20); err != nil {
+		t.Fatalf("Failed to set conversation memory: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agent.Start(ctx)
+	defer agent.Stop()
+
+	for _, question := range []string{"We launch in March.", "Marketing starts in February.", "Pricing is final.", "Docs are late."} {
+		askAgent(t, agent, question)
+	}
+
+	if turns := taggedEntries(t, store, ConversationTag); len(turns) != 8 {
+		t.Errorf("Expected 8 recorded turns, got %d", len(turns))
+	}
+	summaries := taggedEntries(t, store, ConversationSummaryTag)
+	if len(summaries) == 0 {
+		t.Fatal("Expected a conversation summary")
+	}
+	if summaries[0].Category != knowledge.CategoryFact || string(summaries[0].Content) != model.summary {
+		t.Errorf("Unexpected summary entry: %s %q", summaries[0].Category, summaries[0].Content)
+	}
+	if len(agent._history) > DefaultKeepMessages+1 {
+		t.Errorf("Expected the history to be compacted, got %d messages", len(agent._history))
+	}
+	if !strings.Contains(agent._history[0].Content, model.summary) {
+		t.Errorf("Expected the summary in the system prompt, got %q", agent._history[0].Content)
+	}
+
+	// A new session starts with the stored summaries
+	restarted := NewAgent(Persona{Name: "TestAgent", LanguageModels: LanguageModels{Default: model}})
+	if err := restarted.SetConversationMemory(store, 0); err != nil {
+		t.Fatalf("Failed to set conversation memory: %v", err)
+	}
+	if !strings.Contains(restarted.summaryPrompt(), model.summary) {
+		t.Errorf("Expected stored summaries to be loaded, got %q", restarted.summaryPrompt())
+	}
+}
+
+func TestRestoreHistory(t *testing.T) {
+	model := &scriptedLLM{answers: []string{"Activation, as before."}}
+	agent := NewAgent(Persona{Name: "TestAgent", SystemPrompt: "You are helpful.", LanguageModels: LanguageModels{Default: model}})
+	agent.RestoreHistory([]llm.Message{
+		{Role: "user", Content: "Which metric matters most?"},
+		{Role: "assistant", Content: "Activation."},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agent.Start(ctx)
+	defer agent.Stop()
+
+	<-agent.Chat("TestUser", "Remind me?").ResponseReady
+	chat := model.chats[0]
+	if len(chat) != 4 || chat[0].Role != "system" || chat[1].Content != "Which metric matters most?" || chat[3].Content != "Remind me?" {
+		t.Errorf("Expected the restored turns between the system prompt and the message, got %+v", chat)
+	}
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"goproduct/internal/guardrails"
+
+	"github.com/google/uuid"
+)
+
+// BlockedReply is the reply to a message, or in place of a reply, blocked by the guardrails
+const BlockedReply = "Sorry, I can't help with that."
+
+// SetGuardrails checks the chat messages sent to the agent and its replies with the
+// pipeline from the next message on. A nil pipeline turns the checks off.
+func (a *Agent) SetGuardrails(pipeline *guardrails.Pipeline) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.guardrails = pipeline
+}
+
+// guardrailPipeline returns the agent's guardrails, nil if it has none
+func (a *Agent) guardrailPipeline() *guardrails.Pipeline {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.guardrails
+}
+
+// checkInbound runs the guardrails on a chat message, returning it as redacted and
+// whether it was blocked
+func (a *Agent) checkInbound(ctx context.Context, msg Message) (Message, bool) {
+	result := a.guardrailPipeline().Inspect(ctx, guardrails.Inbound, msg.From, msg.Content)
+	if result.Blocked {
+		a.logger.Warn("Chat message blocked by guardrails", "message_id", msg.Id, "reason", result.Reason())
+		return msg, true
+	}
+	msg.Content = result.Text
+	return msg, false
+}
+
+// checkOutbound runs the guardrails on a reply, returning it as redacted, or BlockedReply
+// if it was blocked
+func (a *Agent) checkOutbound(ctx context.Context, msg Message, response string) string {
+	result := a.guardrailPipeline().Inspect(ctx, guardrails.Outbound, a.Persona.Name, response)
+	if result.Blocked {
+		a.logger.Warn("Reply blocked by guardrails", "message_id", msg.Id, "reason", result.Reason())
+		return BlockedReply
+	}
+	return result.Text
+}
+
+// refuse answers a blocked message with BlockedReply, leaving the chat history as it was
+func (a *Agent) refuse(msg Message) {
+	msg.ResponseReady <- Message{
+		Content:       BlockedReply,
+		From:          a.Persona.Name,
+		To:            []string{msg.From},
+		Type:          "chat",
+		ResponseReady: msg.ResponseReady,
+		Created:       time.Now(),
+		Id:            uuid.New().String(),
+		OriginalId:    msg.Id,
+	}
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"goproduct/internal/llm"
+)
+
+// BeforeHook runs before the model is asked to answer a chat message and returns the
+// messages to send it, e.g. with memories retrieved from elsewhere added. The changes are
+// not kept in the chat history. An error stops the answer and goes to the error hooks.
+type BeforeHook func(ctx context.Context, msg Message, messages []llm.Message) ([]llm.Message, error)
+
+// AfterHook runs on the model's answer to a chat message and returns the answer to reply
+// with, e.g. with its tone adjusted. An error stops the answer and goes to the error hooks.
+type AfterHook func(ctx context.Context, msg Message, response string) (string, error)
+
+// ErrorHook runs when a chat message could not be answered, because the model or a hook
+// failed. It returns a reply to send instead of the fallback, and whether it did.
+type ErrorHook func(ctx context.Context, msg Message, err error) (string, bool)
+
+// Hooks are the behaviour added to how an agent answers chat messages, without changing
+// the agent. Each kind of hook runs in the order added, the result of one passed to the
+// next; error hooks run until one replies. Hooks run after the inbound guardrails and
+// before the outbound ones.
+type Hooks struct {
+	Before []BeforeHook
+	After  []AfterHook
+	Error  []ErrorHook
+}
+
+// AddHooks adds the hooks to those run from the next chat message on
+func (a *Agent) AddHooks(hooks Hooks) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.hooks.Before = append(a.hooks.Before, hooks.Before...)
+	a.hooks.After = append(a.hooks.After, hooks.After...)
+	a.hooks.Error = append(a.hooks.Error, hooks.Error...)
+}
+
+// hookChains returns the agent's hooks as they are now
+func (a *Agent) hookChains() Hooks {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return Hooks{
+		Before: append([]BeforeHook(nil), a.hooks.Before...),
+		After:  append([]AfterHook(nil), a.hooks.After...),
+		Error:  append([]ErrorHook(nil), a.hooks.Error...),
+	}
+}
+
+// runBefore runs the before hooks on the messages for the model. The hooks get a copy, as
+// the messages may share their array with the chat history.
+func (a *Agent) runBefore(ctx context.Context, msg Message, messages []llm.Message) ([]llm.Message, error) {
+	hooks := a.hookChains().Before
+	if len(hooks) > 0 {
+		messages = append([]llm.Message(nil), messages...)
+	}
+	for i, hook := range hooks {
+		var err error
+		if messages, err = hook(ctx, msg, messages); err != nil {
+			return nil, fmt.Errorf("before hook %d: %w", i+1, err)
+		}
+	}
+	return messages, nil
+}
+
+// runAfter runs the after hooks on the model's answer
+func (a *Agent) runAfter(ctx context.Context, msg Message, response string) (string, error) {
+	for i, hook := range a.hookChains().After {
+		var err error
+		if response, err = hook(ctx, msg, response); err != nil {
+			return "", fmt.Errorf("after hook %d: %w", i+1, err)
+		}
+	}
+	return response, nil
+}
+
+// runError runs the error hooks until one replies
+func (a *Agent) runError(ctx context.Context, msg Message, err error) (string, bool) {
+	for _, hook := range a.hookChains().Error {
+		if reply, ok := hook(ctx, msg, err); ok {
+			return reply, true
+		}
+	}
+	return "", false
+}
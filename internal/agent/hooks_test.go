@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"goproduct/internal/llm"
+)
+
+func TestAgentHooks(t *testing.T) {
+	model := &scriptedLLM{answers: []string{"the launch is in march"}}
+	agent := NewAgent(Persona{Name: "TestAgent", LanguageModels: LanguageModels{Default: model}})
+	var order []string
+	agent.AddHooks(Hooks{
+		Before: []BeforeHook{func(ctx context.Context, msg Message, messages []llm.Message) ([]llm.Message, error) {
+			order = append(order, "retrieve")
+			return append(messages, llm.Message{Role: "system", Content: "The launch moved to March."}), nil
+		}},
+		After: []AfterHook{func(ctx context.Context, msg Message, response string) (string, error) {
+			order = append(order, "tone")
+			return strings.ToUpper(response[:1]) + response[1:] + ".", nil
+		}},
+	})
+	agent.AddHooks(Hooks{
+		Before: []BeforeHook{func(ctx context.Context, msg Message, messages []llm.Message) ([]llm.Message, error) {
+			order = append(order, "comply")
+			if strings.Contains(msg.Content, "salary") {
+				return nil, errors.New("salaries are confidential")
+			}
+			return messages, nil
+		}},
+		Error: []ErrorHook{
+			func(ctx context.Context, msg Message, err error) (string, bool) {
+				return "I can't discuss that: " + errors.Unwrap(err).Error() + ".", strings.Contains(err.Error(), "confidential")
+			},
+			func(ctx context.Context, msg Message, err error) (string, bool) {
+				t.Error("Expected the error handled by the first error hook")
+				return "", false
+			},
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agent.Start(ctx)
+	defer agent.Stop()
+
+	response := <-agent.Chat("TestUser", "When is the launch?").ResponseReady
+	if response.Content != "The launch is in march." {
+		t.Errorf("Expected the answer adjusted by the after hook, got %q", response.Content)
+	}
+	if sent := model.chats[0]; sent[len(sent)-1].Content != "The launch moved to March." {
+		t.Errorf("Expected the before hook's message sent to the model, got %+v", sent)
+	}
+	if last := agent._history[len(agent._history)-1]; last.Content != response.Content {
+		t.Errorf("Expected the adjusted answer in the history, got %q", last.Content)
+	}
+	for _, m := range agent._history {
+		if m.Content == "The launch moved to March." {
+			t.Error("Expected the before hook's message kept out of the history")
+		}
+	}
+
+	response = <-agent.Chat("TestUser", "What is Bea's salary?").ResponseReady
+	if response.Content != "I can't discuss that: salaries are confidential." {
+		t.Errorf("Expected the error hook's reply, got %q", response.Content)
+	}
+	if len(model.chats) != 1 {
+		t.Errorf("Expected the stopped answer not to reach the model, got %d chats", len(model.chats))
+	}
+	if got := strings.Join(order, ","); got != "retrieve,comply,tone,retrieve,comply" {
+		t.Errorf("Expected the hooks run in order, got %s", got)
+	}
+}
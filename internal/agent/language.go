@@ -0,0 +1,161 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"goproduct/internal/tools"
+)
+
+// SetLanguage selects the system prompt variant for the given language, e.g. "es" or
+// "pt-BR". A regional tag falls back to its base language and the other way round.
+// An empty language, or the persona's own Language, selects the default SystemPrompt.
+// The change applies from the next chat message on.
+func (a *Agent) SetLanguage(language string) error {
+	language = NormalizeLanguage(language)
+	selected := ""
+	if language != "" && !sameLanguage(language, NormalizeLanguage(a.Persona.Language)) {
+		var ok bool
+		selected, ok = a.Persona.variantFor(language)
+		if !ok {
+			return fmt.Errorf("no system prompt for language %q (available: %s)", language, strings.Join(a.Persona.Languages(), ", "))
+		}
+	}
+
+	a.mutex.Lock()
+	a.language = selected
+	a.mutex.Unlock()
+
+	if a.logger != nil {
+		a.logger.Info("Agent language selected", "name", a.Persona.Name, "language", a.Language())
+	}
+	return nil
+}
+
+// Language returns the language of the selected system prompt, empty if the persona
+// does not declare one
+func (a *Agent) Language() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.language != "" {
+		return a.language
+	}
+	return a.Persona.Language
+}
+
+// Languages returns the languages the agent has system prompts for
+func (a *Agent) Languages() []string {
+	return a.Persona.Languages()
+}
+
+// systemPrompt returns the system prompt for the selected language, followed by the
+// description of the tools, or of the reasoning loop with the tools as its actions, and
+// the introduction of the teammates
+func (a *Agent) systemPrompt() string {
+	a.mutex.Lock()
+	persona, language, teammates, registry, library, planner := a.Persona, a.language, a.teammates, a.tools, a.prompts, a.planner
+	a.mutex.Unlock()
+
+	prompt := persona.SystemPrompt
+	if variant, ok := persona.SystemPrompts[language]; ok && language != "" {
+		prompt = variant
+	}
+	if library != nil {
+		prompt = a.renderPrompt(library, persona, prompt, language)
+	}
+	switch {
+	case planner != nil && registry != nil:
+		prompt += planPrompt(registry)
+	case planner != nil:
+		prompt += planPrompt(tools.NewRegistry())
+	case registry != nil:
+		prompt += registry.Prompt()
+	}
+	return prompt + teamPrompt(teammates)
+}
+
+// Languages returns the persona's default language followed by its variants, sorted
+func (p Persona) Languages() []string {
+	languages := make([]string, 0, len(p.SystemPrompts)+1)
+	if p.Language != "" {
+		languages = append(languages, p.Language)
+	}
+	variants := make([]string, 0, len(p.SystemPrompts))
+	for language := range p.SystemPrompts {
+		variants = append(variants, language)
+	}
+	sort.Strings(variants)
+	return append(languages, variants...)
+}
+
+// variantFor returns the SystemPrompts key best matching the language: an exact
+// match, then the base language, then any region of the same base language
+func (p Persona) variantFor(language string) (string, bool) {
+	keys := make([]string, 0, len(p.SystemPrompts))
+	for key := range p.SystemPrompts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if NormalizeLanguage(key) == language {
+			return key, true
+		}
+	}
+	base := baseLanguage(language)
+	for _, key := range keys {
+		if NormalizeLanguage(key) == base {
+			return key, true
+		}
+	}
+	for _, key := range keys {
+		if baseLanguage(NormalizeLanguage(key)) == base {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// LocaleLanguage returns the user's language from the LC_ALL, LC_MESSAGES and LANG
+// environment variables, empty if none is set or the locale is "C" or "POSIX"
+func LocaleLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return NormalizeLanguage(value)
+		}
+	}
+	return ""
+}
+
+// NormalizeLanguage turns a language tag or POSIX locale such as "pt_BR.UTF-8" into a
+// lower-case tag such as "pt-br"
+func NormalizeLanguage(language string) string {
+	language = strings.TrimSpace(language)
+	if i := strings.IndexAny(language, ".@"); i >= 0 {
+		language = language[:i]
+	}
+	language = strings.ToLower(strings.ReplaceAll(language, "_", "-"))
+	if language == "c" || language == "posix" {
+		return ""
+	}
+	return language
+}
+
+// sameLanguage reports whether two normalized tags name the same language, treating
+// a base language as matching its regions
+func sameLanguage(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	return a == b || a == baseLanguage(b) || baseLanguage(a) == b
+}
+
+// baseLanguage returns the language without its region, e.g. "pt" for "pt-br"
+func baseLanguage(language string) string {
+	if i := strings.Index(language, "-"); i >= 0 {
+		return language[:i]
+	}
+	return language
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := map[string]string{
+		"es":          "es",
+		"pt_BR.UTF-8": "pt-br",
+		"de_DE@euro":  "de-de",
+		" EN-us ":     "en-us",
+		"C.UTF-8":     "",
+		"POSIX":       "",
+	}
+	for input, expected := range tests {
+		if got := NormalizeLanguage(input); got != expected {
+			t.Errorf("NormalizeLanguage(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestSetLanguage(t *testing.T) {
+	agent := NewAgent(Persona{
+		Name:         "TestAgent",
+		SystemPrompt: "You are helpful.",
+		Language:     "en",
+		SystemPrompts: map[string]string{
+			"es":    "Eres útil.",
+			"pt-BR": "Você é útil.",
+		},
+	})
+
+	tests := []struct {
+		language string
+		expected string
+		prompt   string
+	}{
+		{"es", "es", "Eres útil."},
+		{"es_MX.UTF-8", "es", "Eres útil."},
+		{"pt", "pt-BR", "Você é útil."},
+		{"en-GB", "en", "You are helpful."},
+		{"pt-br", "pt-BR", "Você é útil."},
+		{"", "en", "You are helpful."},
+	}
+	for _, tt := range tests {
+		if err := agent.SetLanguage(tt.language); err != nil {
+			t.Fatalf("SetLanguage(%q) failed: %v", tt.language, err)
+		}
+		if agent.Language() != tt.expected || agent.systemPrompt() != tt.prompt {
+			t.Errorf("SetLanguage(%q): got %q with prompt %q", tt.language, agent.Language(), agent.systemPrompt())
+		}
+	}
+
+	if err := agent.SetLanguage("fr"); err == nil {
+		t.Error("Expected error for a language without a system prompt")
+	}
+	if agent.Language() != "en" {
+		t.Errorf("Expected a failed switch to keep the language, got %q", agent.Language())
+	}
+
+	languages := agent.Languages()
+	if len(languages) != 3 || languages[0] != "en" || languages[1] != "es" || languages[2] != "pt-BR" {
+		t.Errorf("Unexpected languages: %v", languages)
+	}
+}
+
+func TestLanguageSwitchMidConversation(t *testing.T) {
+	model := &recordingLLM{}
+	agent := NewAgent(Persona{
+		Name:           "TestAgent",
+		SystemPrompt:   "You are helpful.",
+		SystemPrompts:  map[string]string{"es": "Eres útil."},
+		LanguageModels: LanguageModels{Default: model},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agent.Start(ctx)
+	defer agent.Stop()
+
+	askAgent(t, agent, "Hello")
+	if model.messages[0].Content != "You are helpful." {
+		t.Errorf("Expected the default prompt, got %q", model.messages[0].Content)
+	}
+
+	if err := agent.SetLanguage("es"); err != nil {
+		t.Fatalf("SetLanguage failed: %v", err)
+	}
+	askAgent(t, agent, "Hola")
+	if model.messages[0].Content != "Eres útil." {
+		t.Errorf("Expected the Spanish prompt, got %q", model.messages[0].Content)
+	}
+	if len(model.messages) != 4 {
+		t.Errorf("Expected the conversation to be kept, got %d messages", len(model.messages))
+	}
+}
@@ -0,0 +1,192 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
+)
+
+// Memory formats
+const (
+	MemoryFormatBullet = "bullet" // "- memory" lines
+	MemoryFormatQuoted = "quoted" // "> memory" blocks
+)
+
+// Memory template defaults
+const (
+	DefaultMemoryHeader      = "Relevant knowledge:"
+	DefaultMemoryMaxSnippets = 5
+)
+
+// memoryUsageBoost is the weight of access statistics when ranking memories
+const memoryUsageBoost = 0.1
+
+// MemoryTemplate controls how retrieved memories are framed in the prompt. Framing
+// measurably changes model behavior, so each persona can tune it.
+type MemoryTemplate struct {
+	Header      string `json:"header" yaml:"header"`             // Section header above the memories, DefaultMemoryHeader if empty
+	Format      string `json:"format" yaml:"format"`             // MemoryFormatBullet (default) or MemoryFormatQuoted
+	MaxSnippets int    `json:"max_snippets" yaml:"max_snippets"` // Most memories injected, DefaultMemoryMaxSnippets if 0
+	Recency     bool   `json:"recency" yaml:"recency"`           // Annotate each memory with its age, e.g. "(3 days ago)"
+	Item        string `json:"item" yaml:"item"`                 // Optional text/template for one memory; overrides Format
+}
+
+// MemorySnippet is the data available to a MemoryTemplate's Item template
+type MemorySnippet struct {
+	Index     int       // Position in the section, starting at 1
+	ID        string    // The entry's ID, for templates that cite their sources
+	Title     string    // The entry's "title" metadata, if any
+	Content   string    // The entry's content as text
+	Category  string    // The entry's category
+	UpdatedAt time.Time // When the entry was last updated
+	Age       string    // Human-readable age, e.g. "3 days ago"
+}
+
+// SetMemoryStore sets the store that memories relevant to each chat message are
+// retrieved from. They are framed according to the persona's MemoryTemplate.
+// A nil store turns retrieval off.
+func (a *Agent) SetMemoryStore(store knowledge.Store) {
+	a.memories = store
+}
+
+// SetAccessTracker sets the tracker that records which memories were surfaced, so
+// frequently used knowledge ranks higher. A nil tracker turns tracking off.
+func (a *Agent) SetAccessTracker(tracker *knowledge.AccessTracker) {
+	a.access = tracker
+}
+
+// withMemories returns the chat history to send to the model, with the memories
+// relevant to the latest message injected right before it. The stored history is
+// not changed.
+func (a *Agent) withMemories(query string) []llm.Message {
+	if a.memories == nil {
+		return a._history
+	}
+
+	memory := a.memoryTemplate()
+	limit := memory.MaxSnippets
+	if limit <= 0 {
+		limit = DefaultMemoryMaxSnippets
+	}
+	results, err := a.memories.FullTextSearch(query, knowledge.WithSearchLimit(limit), knowledge.WithSearchUsageBoost(memoryUsageBoost))
+	if err != nil {
+		a.logger.Error("Failed to retrieve memories", "error", err)
+		return a._history
+	}
+	if len(results) == 0 {
+		return a._history
+	}
+
+	entries := make([]knowledge.Entry, 0, len(results))
+	ids := make([]string, 0, len(results))
+	for _, result := range results {
+		entries = append(entries, result.Entry)
+		ids = append(ids, result.Entry.ID)
+	}
+	section, err := memory.Render(entries, time.Now())
+	if err != nil {
+		a.logger.Error("Failed to render memories", "error", err)
+		return a._history
+	}
+
+	a.logger.Debug("Injecting retrieved memories", "count", len(entries))
+	if a.access != nil {
+		a.access.Record(ids...)
+	}
+
+	last := len(a._history) - 1
+	messages := make([]llm.Message, 0, len(a._history)+1)
+	messages = append(messages, a._history[:last]...)
+	messages = append(messages, llm.Message{Role: "system", Content: section})
+	return append(messages, a._history[last])
+}
+
+// Render frames the entries as a prompt section in the given order, most relevant first
+func (t MemoryTemplate) Render(entries []knowledge.Entry, now time.Time) (string, error) {
+	limit := t.MaxSnippets
+	if limit <= 0 {
+		limit = DefaultMemoryMaxSnippets
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	var item *template.Template
+	if t.Item != "" {
+		var err error
+		item, err = template.New("memory").Parse(t.Item)
+		if err != nil {
+			return "", fmt.Errorf("invalid memory item template: %w", err)
+		}
+	}
+
+	header := t.Header
+	if header == "" {
+		header = DefaultMemoryHeader
+	}
+
+	var sb strings.Builder
+	sb.WriteString(header)
+	sb.WriteString("\n")
+	for i, entry := range entries {
+		snippet := MemorySnippet{
+			Index:     i + 1,
+			ID:        entry.ID,
+			Title:     entry.Metadata["title"],
+			Content:   strings.TrimSpace(string(entry.Content)),
+			Category:  entry.Category,
+			UpdatedAt: entry.UpdatedAt,
+			Age:       formatAge(now.Sub(entry.UpdatedAt)),
+		}
+		if entry.ContentType == knowledge.ContentTypeBinary {
+			snippet.Content = "(binary content)"
+		}
+
+		if item != nil {
+			if err := item.Execute(&sb, snippet); err != nil {
+				return "", fmt.Errorf("failed to render memory: %w", err)
+			}
+			sb.WriteString("\n")
+			continue
+		}
+
+		text := snippet.Content
+		if snippet.Title != "" {
+			text = snippet.Title + ": " + text
+		}
+		if t.Recency && !entry.UpdatedAt.IsZero() {
+			text += " (" + snippet.Age + ")"
+		}
+		if t.Format == MemoryFormatQuoted {
+			sb.WriteString("> " + strings.ReplaceAll(text, "\n", "\n> ") + "\n")
+		} else {
+			sb.WriteString("- " + strings.ReplaceAll(text, "\n", " ") + "\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+// formatAge returns a short human-readable age such as "just now" or "3 days ago"
+func formatAge(age time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return plural(int(age/time.Minute), "minute")
+	case age < 24*time.Hour:
+		return plural(int(age/time.Hour), "hour")
+	default:
+		return plural(int(age/(24*time.Hour)), "day")
+	}
+}
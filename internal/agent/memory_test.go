@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
+)
+
+// recordingLLM remembers the messages of the last chat
+type recordingLLM struct {
+	messages []llm.Message
+}
+
+func (m *recordingLLM) GenerateChat(ctx context.Context, messages []llm.Message) (string, error) {
+	m.messages = messages
+	return "OK", nil
+}
+
+func (m *recordingLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return "OK", nil
+}
+
+func TestMemoryTemplateRender(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	entries := []knowledge.Entry{
+		{Content: []byte("We use Go for the backend"), Category: knowledge.CategoryFact, UpdatedAt: now.Add(-72 * time.Hour),
+			Metadata: map[string]string{"title": "Stack"}},
+		{Content: []byte("Ship on Fridays"), Category: knowledge.CategoryDecision, UpdatedAt: now.Add(-time.Hour)},
+		{Content: []byte("Third"), Category: knowledge.CategoryFact},
+	}
+
+	tests := []struct {
+		name     string
+		template MemoryTemplate
+		expected string
+	}{
+		{
+			name:     "defaults",
+			template: MemoryTemplate{},
+			expected: "Relevant knowledge:\n- Stack: We use Go for the backend\n- Ship on Fridays\n- Third",
+		},
+		{
+			name:     "quoted with recency and limit",
+			template: MemoryTemplate{Header: "Known:", Format: MemoryFormatQuoted, Recency: true, MaxSnippets: 2},
+			expected: "Known:\n> Stack: We use Go for the backend (3 days ago)\n> Ship on Fridays (1 hour ago)",
+		},
+		{
+			name:     "item template",
+			template: MemoryTemplate{Header: "Sources:", Item: "[{{.Index}}] ({{.Category}}) {{.Content}}", MaxSnippets: 2},
+			expected: "Sources:\n[1] (fact) We use Go for the backend\n[2] (decision) Ship on Fridays",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := tt.template.Render(entries, now)
+			if err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			if rendered != tt.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, rendered)
+			}
+		})
+	}
+
+	if _, err := (MemoryTemplate{Item: "{{.Missing"}).Render(entries, now); err == nil {
+		t.Error("Expected error for an invalid item template")
+	}
+}
+
+func TestAgentInjectsMemories(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	store.Open()
+	store.AddRecord(knowledge.Entry{ID: "stack", Category: knowledge.CategoryFact, Content: []byte("The backend is written in Go")})
+
+	model := &recordingLLM{}
+	agent := NewAgent(Persona{
+		Name:           "TestAgent",
+		SystemPrompt:   "You are helpful.",
+		Memory:         MemoryTemplate{Header: "Known:"},
+		LanguageModels: LanguageModels{Default: model},
+	})
+	agent.SetMemoryStore(store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agent.Start(ctx)
+	defer agent.Stop()
+
+	msg := agent.Chat("TestUser", "Which language is the backend written in?")
+	select {
+	case <-msg.ResponseReady:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for response")
+	}
+
+	if len(model.messages) != 3 {
+		t.Fatalf("Expected system prompt, memories and question, got %d messages", len(model.messages))
+	}
+	if memories := model.messages[1]; memories.Role != "system" || !strings.Contains(memories.Content, "Known:\n- The backend is written in Go") {
+		t.Errorf("Unexpected memory message: %+v", memories)
+	}
+	if model.messages[2].Role != "user" {
+		t.Errorf("Expected the question last, got %+v", model.messages[2])
+	}
+
+	// Memories are not kept in the chat history
+	if len(agent._history) != 3 || agent._history[1].Role != "user" {
+		t.Errorf("Expected history of system prompt, question and answer, got %+v", agent._history)
+	}
+}
@@ -0,0 +1,171 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"goproduct/internal/llm"
+)
+
+// PersonaDefinition is a persona as written in a persona file. It describes the agent
+// without binding it to a language model instance, which Persona does.
+type PersonaDefinition struct {
+	Name          string            `json:"name" yaml:"name"`
+	Role          string            `json:"role" yaml:"role"`
+	Type          string            `json:"type" yaml:"type"`
+	Language      string            `json:"language" yaml:"language"`             // Language of SystemPrompt, "en" if empty
+	SystemPrompt  string            `json:"system_prompt" yaml:"system_prompt"`   // Required
+	SystemPrompts map[string]string `json:"system_prompts" yaml:"system_prompts"` // System prompt variants by language
+	Memory        MemoryTemplate    `json:"memory" yaml:"memory"`
+	Model         ModelBinding      `json:"model" yaml:"model"` // Model of the persona; empty uses the default model
+	Tools         []string          `json:"tools" yaml:"tools"` // Tools the persona may call; omitted for all, empty for none
+
+	Source string `json:"-" yaml:"-"` // File the persona was loaded from
+}
+
+// ModelBinding selects the language model of a persona
+type ModelBinding struct {
+	Provider    string  `json:"provider" yaml:"provider"` // e.g. "openai" or "ollama"; empty for the default model
+	Model       string  `json:"model" yaml:"model"`       // e.g. "gpt-4o"
+	Temperature float32 `json:"temperature" yaml:"temperature"`
+	MaxTokens   int     `json:"max_tokens" yaml:"max_tokens"`
+}
+
+// IsDefault reports whether the binding leaves the persona on the default model
+func (b ModelBinding) IsDefault() bool {
+	return b.Provider == ""
+}
+
+// Config returns the provider configuration of the binding; API keys and endpoints
+// come from the provider's environment variables
+func (b ModelBinding) Config() llm.ProviderConfig {
+	config := llm.BaseConfig{
+		Provider:    b.Provider,
+		Model:       b.Model,
+		Temperature: b.Temperature,
+		MaxTokens:   b.MaxTokens,
+	}
+	if config.Temperature == 0 {
+		config.Temperature = llm.DefaultRequestOptions.Temperature
+	}
+	if config.MaxTokens == 0 {
+		config.MaxTokens = llm.DefaultRequestOptions.MaxTokens
+	}
+	return config
+}
+
+// Validate checks that the definition describes a usable persona
+func (d PersonaDefinition) Validate() error {
+	var errs []error
+	if strings.TrimSpace(d.Name) == "" {
+		errs = append(errs, errors.New("name is required"))
+	}
+	if strings.TrimSpace(d.SystemPrompt) == "" {
+		errs = append(errs, errors.New("system_prompt is required"))
+	}
+	switch d.Memory.Format {
+	case "", MemoryFormatBullet, MemoryFormatQuoted:
+	default:
+		errs = append(errs, fmt.Errorf("memory format %q is not %s or %s", d.Memory.Format, MemoryFormatBullet, MemoryFormatQuoted))
+	}
+	if d.Memory.MaxSnippets < 0 {
+		errs = append(errs, errors.New("memory max_snippets cannot be negative"))
+	}
+	if d.Memory.Item != "" {
+		if _, err := template.New("memory").Parse(d.Memory.Item); err != nil {
+			errs = append(errs, fmt.Errorf("invalid memory item template: %w", err))
+		}
+	}
+	if d.Model.IsDefault() && d.Model.Model != "" {
+		errs = append(errs, fmt.Errorf("model %q needs a provider", d.Model.Model))
+	}
+	if d.Model.Temperature < 0 || d.Model.Temperature > 2 {
+		errs = append(errs, fmt.Errorf("model temperature %v is not between 0 and 2", d.Model.Temperature))
+	}
+	for language, prompt := range d.SystemPrompts {
+		if strings.TrimSpace(prompt) == "" {
+			errs = append(errs, fmt.Errorf("system prompt for %s is empty", language))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Persona binds the definition to a language model
+func (d PersonaDefinition) Persona(model llm.LanguageModel) Persona {
+	language := d.Language
+	if language == "" {
+		language = "en"
+	}
+	return Persona{
+		Name:           d.Name,
+		Role:           d.Role,
+		Type:           d.Type,
+		SystemPrompt:   d.SystemPrompt,
+		Language:       language,
+		SystemPrompts:  d.SystemPrompts,
+		Memory:         d.Memory,
+		LanguageModels: LanguageModels{Default: model},
+	}
+}
+
+// LoadPersonas reads the persona definitions of the .yaml, .yml and .json files at the
+// top of a directory, sorted by name. Every definition is validated, and names must be
+// unique regardless of case.
+func LoadPersonas(fsys fs.FS) ([]PersonaDefinition, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read personas: %w", err)
+	}
+
+	var personas []PersonaDefinition
+	sources := make(map[string]string)
+	for _, file := range files {
+		ext := strings.ToLower(path.Ext(file.Name()))
+		if file.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, file.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read persona %s: %w", file.Name(), err)
+		}
+		var definition PersonaDefinition
+		if ext == ".json" {
+			err = json.Unmarshal(data, &definition)
+		} else {
+			err = yaml.Unmarshal(data, &definition)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse persona %s: %w", file.Name(), err)
+		}
+		if err := definition.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid persona %s: %w", file.Name(), err)
+		}
+		key := strings.ToLower(definition.Name)
+		if other, exists := sources[key]; exists {
+			return nil, fmt.Errorf("persona %s is defined in both %s and %s", definition.Name, other, file.Name())
+		}
+		sources[key] = file.Name()
+		definition.Source = file.Name()
+		personas = append(personas, definition)
+	}
+	sort.Slice(personas, func(i, j int) bool { return strings.ToLower(personas[i].Name) < strings.ToLower(personas[j].Name) })
+	return personas, nil
+}
+
+// FindPersona returns the persona with the name, ignoring case
+func FindPersona(personas []PersonaDefinition, name string) (PersonaDefinition, bool) {
+	for _, persona := range personas {
+		if strings.EqualFold(persona.Name, name) {
+			return persona, true
+		}
+	}
+	return PersonaDefinition{}, false
+}
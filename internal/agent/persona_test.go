@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadPersonas(t *testing.T) {
+	fsys := fstest.MapFS{
+		"architect.yaml": {Data: []byte(`
+name: Ada
+role: Architect
+system_prompt: You design systems.
+memory:
+  format: quoted
+  max_snippets: 3
+model:
+  provider: ollama
+  model: llama3
+  temperature: 0.2
+tools: [recall]
+`)},
+		"qa.json":   {Data: []byte(`{"name": "Quinn", "role": "QA", "system_prompt": "You test.", "language": "de"}`)},
+		"notes.txt": {Data: []byte("not a persona")},
+	}
+
+	personas, err := LoadPersonas(fsys)
+	if err != nil {
+		t.Fatalf("Failed to load personas: %v", err)
+	}
+	if len(personas) != 2 || personas[0].Name != "Ada" || personas[1].Name != "Quinn" {
+		t.Fatalf("Expected Ada and Quinn, got %+v", personas)
+	}
+
+	ada := personas[0]
+	if ada.Source != "architect.yaml" || ada.Memory.Format != MemoryFormatQuoted || ada.Memory.MaxSnippets != 3 {
+		t.Errorf("Unexpected persona: %+v", ada)
+	}
+	config := ada.Model.Config()
+	if ada.Model.IsDefault() || config.GetProvider() != "ollama" || config.GetModel() != "llama3" {
+		t.Errorf("Unexpected model binding: %+v", ada.Model)
+	}
+	if len(ada.Tools) != 1 || ada.Tools[0] != "recall" {
+		t.Errorf("Unexpected tools: %v", ada.Tools)
+	}
+
+	quinn, ok := FindPersona(personas, "quinn")
+	if !ok {
+		t.Fatal("Expected to find Quinn ignoring case")
+	}
+	persona := quinn.Persona(&answerLLM{})
+	if persona.Language != "de" || persona.SystemPrompt != "You test." || persona.LanguageModels.Default == nil {
+		t.Errorf("Unexpected persona: %+v", persona)
+	}
+	if quinn.Tools != nil || !quinn.Model.IsDefault() {
+		t.Errorf("Expected all tools and the default model, got %v and %+v", quinn.Tools, quinn.Model)
+	}
+}
+
+func TestLoadPersonasRejectsInvalidDefinitions(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"system_prompt is required": {"a.yaml": {Data: []byte("name: Ada")}},
+		"needs a provider":          {"a.yaml": {Data: []byte("name: Ada\nsystem_prompt: hi\nmodel:\n  model: gpt-4o")}},
+		"memory format":             {"a.yaml": {Data: []byte("name: Ada\nsystem_prompt: hi\nmemory:\n  format: table")}},
+		"defined in both":           {"a.yaml": {Data: []byte("name: Ada\nsystem_prompt: hi")}, "b.json": {Data: []byte(`{"name": "ADA", "system_prompt": "hi"}`)}},
+		"failed to parse":           {"a.json": {Data: []byte("{")}},
+	}
+	for want, fsys := range tests {
+		if _, err := LoadPersonas(fsys); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error containing %q, got %v", want, err)
+		}
+	}
+}
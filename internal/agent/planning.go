@@ -0,0 +1,276 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"goproduct/internal/llm"
+	"goproduct/internal/tools"
+	"goproduct/internal/tracing"
+)
+
+// DefaultMaxPlanSteps bounds the actions an agent takes in its reasoning loop while
+// answering one message
+const DefaultMaxPlanSteps = 8
+
+// maxRepeats is how many times the model may repeat an action with the same input before
+// the loop is stopped
+const maxRepeats = 2
+
+// Labels of the reasoning loop's reply format
+const (
+	labelThought     = "Thought:"
+	labelAction      = "Action:"
+	labelInput       = "Action Input:"
+	labelFinal       = "Final Answer:"
+	labelObservation = "Observation:"
+)
+
+// Observations of the steps that did not run a tool
+const (
+	repeatedObservation = "You already took this action with the same input; use its observation above or take another action."
+	finishObservation   = "Stop taking actions and reply with your Final Answer now."
+)
+
+// PlanStep is a step of the reasoning loop: the model's thought, then the action it took
+// and what it observed, or its final answer
+type PlanStep struct {
+	Number      int             `json:"number"`
+	Thought     string          `json:"thought,omitempty"`
+	Action      string          `json:"action,omitempty"`       // Tool called
+	Input       tools.Arguments `json:"input,omitempty"`        // Arguments of the tool
+	Observation string          `json:"observation,omitempty"`  // Result of the tool, or why it did not run
+	Answer      string          `json:"final_answer,omitempty"` // Set on the last step
+}
+
+// Planner runs the reasoning loop of an agent: the model thinks and chooses an action,
+// the action runs as a tool call and its observation goes back to the model, until the
+// model gives its final answer. The zero value is not usable, use NewPlanner.
+type Planner struct {
+	maxSteps int
+	tracer   tracing.Tracer
+}
+
+// PlannerOption is a function that configures a Planner
+type PlannerOption func(*Planner)
+
+// WithMaxSteps sets the actions taken before the model must answer, DefaultMaxPlanSteps
+// by default
+func WithMaxSteps(steps int) PlannerOption {
+	return func(p *Planner) {
+		if steps > 0 {
+			p.maxSteps = steps
+		}
+	}
+}
+
+// WithPlanTracer sets the tracer receiving an event for every step
+func WithPlanTracer(tracer tracing.Tracer) PlannerOption {
+	return func(p *Planner) {
+		if tracer != nil {
+			p.tracer = tracer
+		}
+	}
+}
+
+// NewPlanner creates a reasoning loop
+func NewPlanner(opts ...PlannerOption) *Planner {
+	p := &Planner{
+		maxSteps: DefaultMaxPlanSteps,
+		tracer:   tracing.NewNoopTracer(),
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// SetPlanner makes the agent answer chat messages through the reasoning loop, with its
+// tools as the actions, from the next message on. A nil planner turns the loop off.
+func (a *Agent) SetPlanner(planner *Planner) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.planner = planner
+}
+
+// reasoningLoop returns the agent's planner, nil if it has none
+func (a *Agent) reasoningLoop() *Planner {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.planner
+}
+
+// planPrompt returns the system prompt section describing the reply format of the
+// reasoning loop and the actions available
+func planPrompt(registry *tools.Registry) string {
+	var sb strings.Builder
+	sb.WriteString("\n# Reasoning\n")
+	sb.WriteString("Work towards the answer step by step. Reply in exactly one of these two forms, then stop and wait:\n\n")
+	sb.WriteString(labelThought + " <what you know and what to do next>\n")
+	sb.WriteString(labelAction + " <one of the actions below>\n")
+	sb.WriteString(labelInput + " <the action's arguments as a JSON object>\n\n")
+	sb.WriteString(labelThought + " <why you can answer now>\n")
+	sb.WriteString(labelFinal + " <your answer to the user>\n\n")
+	sb.WriteString("The result of each action comes back as an " + labelObservation + "\n")
+	if actions := registry.Describe(); actions != "" {
+		sb.WriteString("\nActions:\n")
+		sb.WriteString(actions)
+	} else {
+		sb.WriteString("\nNo actions are available, answer from what you know.\n")
+	}
+	return sb.String()
+}
+
+// plan answers a message through the reasoning loop
+func (a *Agent) plan(ctx context.Context, planner *Planner, registry *tools.Registry, msg Message, messages []llm.Message) (string, error) {
+	model := a.Persona.LanguageModels.Default
+	ctx = tools.WithCaller(ctx, tools.Caller{AgentName: a.Persona.Name, SenderID: msg.From, MessageID: msg.Id})
+	working := append([]llm.Message(nil), messages...)
+	seen := make(map[string]int) // Times each action was taken with the same input
+	actions := 0                 // Tools run
+	finishing := false           // The model was told to answer
+
+	for number := 1; ; number++ {
+		fitted, err := a.fitContext(msg, working)
+		if err != nil {
+			return "", err
+		}
+		response, err := model.GenerateChat(ctx, fitted)
+		if err != nil {
+			return "", err
+		}
+
+		step := parseStep(response)
+		step.Number = number
+		if step.Action == "" {
+			planner.trace(ctx, a.Persona.Name, msg, step)
+			return step.Answer, nil
+		}
+
+		key := step.Action + " " + canonicalInput(step.Input)
+		seen[key]++
+		switch {
+		case finishing:
+			// Still acting when told to answer: the last thought is the best answer there is
+			step.Observation = "not run, no steps left"
+			planner.trace(ctx, a.Persona.Name, msg, step)
+			a.logger.Warn("Agent kept acting past the end of its reasoning loop", "message_id", msg.Id, "action", step.Action)
+			if step.Thought == "" {
+				return "", fmt.Errorf("no final answer after %d steps", number)
+			}
+			return step.Thought, nil
+		case actions >= planner.maxSteps:
+			step.Observation = finishObservation
+			finishing = true
+		case seen[key] > maxRepeats:
+			step.Observation = finishObservation
+			finishing = true
+			a.logger.Warn("Agent repeats an action, stopping its reasoning loop", "message_id", msg.Id, "action", step.Action)
+		case seen[key] > 1:
+			step.Observation = repeatedObservation
+		default:
+			result, err := registry.Call(ctx, tools.Call{Name: step.Action, Arguments: step.Input})
+			if err != nil {
+				result = "Error: " + err.Error()
+			}
+			step.Observation = result
+			actions++
+		}
+		planner.trace(ctx, a.Persona.Name, msg, step)
+
+		working = append(working,
+			llm.Message{Role: "assistant", Content: response},
+			llm.Message{Role: "user", Content: labelObservation + " " + step.Observation},
+		)
+	}
+}
+
+// trace records a step of the reasoning loop
+func (p *Planner) trace(ctx context.Context, agentName string, msg Message, step PlanStep) {
+	message := fmt.Sprintf("Step %d: %s", step.Number, step.Action)
+	if step.Action == "" {
+		message = fmt.Sprintf("Step %d: final answer", step.Number)
+	}
+	p.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentAgent,
+		Operation: tracing.OperationPlan,
+		Level:     tracing.LevelDebug,
+		SourceID:  agentName,
+		ObjectID:  msg.Id,
+		Message:   message,
+		Metadata: tracing.Correlate(ctx, map[string]interface{}{
+			"step":         step.Number,
+			"thought":      step.Thought,
+			"action":       step.Action,
+			"input":        step.Input,
+			"observation":  step.Observation,
+			"final_answer": step.Answer,
+		}),
+	})
+}
+
+// parseStep reads a reply of the reasoning loop. A reply that is neither an action nor a
+// final answer is taken as the final answer, as models do not always keep to the format.
+func parseStep(response string) PlanStep {
+	var step PlanStep
+	thought, rest := response, ""
+	if i := strings.Index(response, labelFinal); i >= 0 {
+		thought, step.Answer = response[:i], strings.TrimSpace(response[i+len(labelFinal):])
+	} else if i := strings.Index(response, labelAction); i >= 0 {
+		thought, rest = response[:i], response[i+len(labelAction):]
+	}
+	step.Thought = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(thought), labelThought))
+
+	switch {
+	case step.Answer != "":
+		return step
+	case rest == "":
+		step.Answer = step.Thought
+		return step
+	}
+
+	input := ""
+	if i := strings.Index(rest, labelInput); i >= 0 {
+		rest, input = rest[:i], strings.TrimSpace(rest[i+len(labelInput):])
+	}
+	step.Action = strings.TrimSpace(rest)
+	if input != "" {
+		if err := json.Unmarshal([]byte(firstObject(input)), &step.Input); err != nil {
+			// Passed on to the tool, which reports the missing arguments
+			step.Input = tools.Arguments{}
+		}
+	}
+	return step
+}
+
+// firstObject returns the first JSON object of the text, which models may follow with
+// more text
+func firstObject(text string) string {
+	start := strings.Index(text, "{")
+	if start < 0 {
+		return text
+	}
+	decoder := json.NewDecoder(strings.NewReader(text[start:]))
+	var raw json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return text
+	}
+	return string(raw)
+}
+
+// canonicalInput returns the arguments of an action in a form that compares equal for
+// equal arguments
+func canonicalInput(input tools.Arguments) string {
+	data, err := json.Marshal(input) // Map keys are sorted
+	if err != nil {
+		return fmt.Sprint(input)
+	}
+	return string(data)
+}
@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"goproduct/internal/tools"
+	"goproduct/internal/tracing"
+)
+
+// recordingTracer keeps the events traced
+type recordingTracer struct {
+	tracing.NoopTracer
+	events []tracing.Event
+	mu     sync.Mutex
+}
+
+func (r *recordingTracer) Trace(event tracing.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+// newPlanningAgent returns a started agent with a lookup tool counting its calls
+func newPlanningAgent(t *testing.T, model *scriptedLLM, planner *Planner) (*Agent, *int) {
+	t.Helper()
+	agent := NewAgent(Persona{Name: "TestAgent", LanguageModels: LanguageModels{Default: model}})
+	calls := 0
+	agent.SetTools(tools.NewRegistry(tools.Tool{
+		Name:        "lookup",
+		Description: "Look up a topic",
+		Parameters:  []tools.Parameter{{Name: "topic", Type: tools.TypeString, Required: true}},
+		Handler: func(ctx context.Context, args tools.Arguments) (string, error) {
+			calls++
+			return "The launch is planned for March.", nil
+		},
+	}))
+	agent.SetPlanner(planner)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	agent.Start(ctx)
+	t.Cleanup(agent.Stop)
+	return agent, &calls
+}
+
+func TestAgentPlans(t *testing.T) {
+	action := "Thought: I need the launch date.\nAction: lookup\nAction Input: {\"topic\": \"launch\"}"
+	model := &scriptedLLM{answers: []string{
+		action,
+		action,
+		"Thought: The lookup says March.\nFinal Answer: The launch is in March.",
+	}}
+	tracer := &recordingTracer{}
+	agent, calls := newPlanningAgent(t, model, NewPlanner(WithPlanTracer(tracer)))
+
+	response := <-agent.Chat("TestUser", "When is the launch?").ResponseReady
+	if response.Content != "The launch is in March." {
+		t.Errorf("Expected the final answer, got %q", response.Content)
+	}
+	if *calls != 1 {
+		t.Errorf("Expected the repeated action not to run again, got %d calls", *calls)
+	}
+
+	system := model.chats[0][0].Content
+	if !strings.Contains(system, "# Reasoning") || !strings.Contains(system, "- lookup: Look up a topic") || strings.Contains(system, "<tool_call>") {
+		t.Errorf("Expected the reasoning format with the tools as actions, got %q", system)
+	}
+	observations := []string{"Observation: The launch is planned for March.", "Observation: " + repeatedObservation}
+	for i, want := range observations {
+		sent := model.chats[i+1]
+		if got := sent[len(sent)-1].Content; got != want {
+			t.Errorf("Expected observation %d %q, got %q", i+1, want, got)
+		}
+	}
+	if last := agent._history[len(agent._history)-1]; last.Content != "The launch is in March." {
+		t.Errorf("Expected only the final answer in the history, got %q", last.Content)
+	}
+
+	if len(tracer.events) != 3 {
+		t.Fatalf("Expected every step traced, got %d events", len(tracer.events))
+	}
+	first, last := tracer.events[0], tracer.events[2]
+	if first.Operation != tracing.OperationPlan || first.Metadata["thought"] != "I need the launch date." ||
+		first.Metadata["action"] != "lookup" || first.Metadata["observation"] != "The launch is planned for March." {
+		t.Errorf("Unexpected first step: %+v", first)
+	}
+	if last.Message != "Step 3: final answer" || last.Metadata["final_answer"] != "The launch is in March." {
+		t.Errorf("Unexpected last step: %+v", last)
+	}
+}
+
+func TestAgentPlanTracesCorrelation(t *testing.T) {
+	model := &scriptedLLM{answers: []string{"Thought: I know this.\nFinal Answer: March."}}
+	tracer := &recordingTracer{}
+	agent, _ := newPlanningAgent(t, model, NewPlanner(WithPlanTracer(tracer)))
+
+	msg := Message{Id: "m1", Content: "When is the launch?", From: "TestUser", Type: "chat",
+		ResponseReady: make(chan Message, 1), CorrelationID: "c1"}
+	agent.HandleExternalMessage(msg)
+	<-msg.ResponseReady
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	if len(tracer.events) != 1 || tracing.CorrelationIDOf(tracer.events[0]) != "c1" {
+		t.Errorf("Expected the step traced with the message's correlation ID, got %+v", tracer.events)
+	}
+}
+
+func TestAgentPlanLimits(t *testing.T) {
+	// The model keeps looking up other topics, past the step limit and the instruction to answer
+	model := &scriptedLLM{answers: []string{
+		"Thought: Check one.\nAction: lookup\nAction Input: {\"topic\": \"one\"}",
+		"Thought: Check two.\nAction: lookup\nAction Input: {\"topic\": \"two\"}",
+		"Thought: Probably March.\nAction: lookup\nAction Input: {\"topic\": \"three\"}",
+	}}
+	agent, calls := newPlanningAgent(t, model, NewPlanner(WithMaxSteps(1)))
+	response := <-agent.Chat("TestUser", "When is the launch?").ResponseReady
+	if response.Content != "Probably March." || *calls != 1 {
+		t.Errorf("Expected the last thought after one action, got %q after %d calls", response.Content, *calls)
+	}
+	if sent := model.chats[2]; sent[len(sent)-1].Content != "Observation: "+finishObservation {
+		t.Errorf("Expected the model told to answer, got %q", sent[len(sent)-1].Content)
+	}
+
+	// The model repeats the same action
+	repeat := "Thought: Check again.\nAction: lookup\nAction Input: {\"topic\": \"launch\"}"
+	model = &scriptedLLM{answers: []string{repeat, repeat, repeat, "Final Answer: March."}}
+	agent, calls = newPlanningAgent(t, model, NewPlanner())
+	response = <-agent.Chat("TestUser", "When is the launch?").ResponseReady
+	if response.Content != "March." || *calls != 1 {
+		t.Errorf("Expected the loop stopped after repeats, got %q after %d calls", response.Content, *calls)
+	}
+	if sent := model.chats[3]; sent[len(sent)-1].Content != "Observation: "+finishObservation {
+		t.Errorf("Expected the model told to answer after repeating itself, got %q", sent[len(sent)-1].Content)
+	}
+}
+
+func TestParseStep(t *testing.T) {
+	tests := []struct {
+		response string
+		want     PlanStep
+	}{
+		{"Thought: Easy.\nFinal Answer: Yes.", PlanStep{Thought: "Easy.", Answer: "Yes."}},
+		{"Just an answer", PlanStep{Thought: "Just an answer", Answer: "Just an answer"}},
+		{"Thought: Look.\nAction: lookup\nAction Input: {\"topic\": \"x\"} and then more", PlanStep{Thought: "Look.", Action: "lookup", Input: tools.Arguments{"topic": "x"}}},
+		{"Action: lookup\nAction Input: not json", PlanStep{Action: "lookup", Input: tools.Arguments{}}},
+	}
+	for _, tt := range tests {
+		got := parseStep(tt.response)
+		if got.Thought != tt.want.Thought || got.Action != tt.want.Action || got.Answer != tt.want.Answer ||
+			canonicalInput(got.Input) != canonicalInput(tt.want.Input) {
+			t.Errorf("parseStep(%q) = %+v, want %+v", tt.response, got, tt.want)
+		}
+	}
+}
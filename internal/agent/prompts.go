@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"time"
+
+	"goproduct/internal/prompts"
+)
+
+// PromptData is the data a system prompt template is rendered with
+type PromptData struct {
+	Name     string    // The persona's name
+	Role     string    // The persona's role
+	Language string    // Language of the selected system prompt, e.g. "en"
+	Now      time.Time // When the prompt is rendered, e.g. {{.Now.Format "Monday, January 2, 2006"}}
+}
+
+// SetPrompts renders the system prompt as a template of the library from the next chat
+// message on, so it can include the library's templates, as overridden for the persona,
+// and interpolate runtime facts. A nil library uses the system prompt as is.
+func (a *Agent) SetPrompts(library *prompts.Library) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.prompts = library
+}
+
+// ReloadPersona takes on the role, system prompts and memory template of the persona
+// from the next chat message on. The name, language and models of the agent are kept:
+// entities address it by name and its language models are created at startup.
+func (a *Agent) ReloadPersona(p Persona) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.Persona.Role = p.Role
+	a.Persona.SystemPrompt = p.SystemPrompt
+	a.Persona.SystemPrompts = p.SystemPrompts
+	a.Persona.Memory = p.Memory
+}
+
+// memoryTemplate returns how the persona frames retrieved memories
+func (a *Agent) memoryTemplate() MemoryTemplate {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.Persona.Memory
+}
+
+// renderPrompt renders a system prompt template, falling back to the template text if
+// it fails so that a broken template does not silence the agent
+func (a *Agent) renderPrompt(library *prompts.Library, persona Persona, prompt, language string) string {
+	if language == "" {
+		language = persona.Language
+	}
+	data := PromptData{Name: persona.Name, Role: persona.Role, Language: language, Now: time.Now()}
+	rendered, err := library.RenderText(persona.Name, prompt, data)
+	if err != nil {
+		if a.logger != nil {
+			a.logger.Error("Failed to render the system prompt", "name", persona.Name, "error", err)
+		}
+		return prompt
+	}
+	return rendered
+}
@@ -0,0 +1,26 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"goproduct/internal/prompts"
+)
+
+func TestSystemPromptTemplate(t *testing.T) {
+	library := prompts.New()
+	library.Add("company", "Acme")
+	library.Override("andy", "company", "Andy's Acme")
+
+	agent := NewAgent(Persona{Name: "Andy", Role: "Product Owner", Language: "en", SystemPrompt: `{{.Name}}, {{.Role}} at {{template "company" .}} ({{.Language}})`})
+	agent.SetPrompts(library)
+	if prompt := agent.systemPrompt(); prompt != "Andy, Product Owner at Andy's Acme (en)" {
+		t.Errorf("Unexpected system prompt: %q", prompt)
+	}
+
+	// A broken template leaves the prompt as written
+	agent.Persona.SystemPrompt = `You work at {{template "missing" .}}`
+	if prompt := agent.systemPrompt(); !strings.HasPrefix(prompt, "You work at {{template") {
+		t.Errorf("Expected the template text when rendering fails, got %q", prompt)
+	}
+}
@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
+)
+
+// DefaultSelfCheckQuestions is the number of questions asked by a self-check
+const DefaultSelfCheckQuestions = 5
+
+// selfCheckQuestionPrompt asks the model to turn a knowledge entry into a quiz question
+const selfCheckQuestionPrompt = `Write one short question that can only be answered with the fact below.
+Reply with the question only.
+
+Fact: %s`
+
+// selfCheckAnswerPrompt asks the model to answer from the retrieved knowledge with citations
+const selfCheckAnswerPrompt = `Answer the question using only the knowledge below.
+After each statement, cite the ID of the entry it comes from in square brackets, e.g. [abc].
+If the knowledge does not answer the question, say so.
+
+%s`
+
+// selfCheckJudgePrompt asks the model whether an answer agrees with the source entry
+const selfCheckJudgePrompt = `Does the answer agree with the fact? Reply with "yes" or "no" only.
+
+Fact: %s
+Question: %s
+Answer: %s`
+
+// SelfCheckResult is the outcome of one self-check question
+type SelfCheckResult struct {
+	EntryID   string   // Knowledge entry the question was generated from
+	Question  string   // Generated question
+	Answer    string   // The agent's answer
+	Retrieved bool     // Retrieval surfaced the source entry
+	Cited     bool     // The answer cites the source entry
+	Correct   bool     // The answer agrees with the source entry
+	Issues    []string // Discrepancies found, empty if the check passed
+}
+
+// Passed reports whether the question was answered correctly with a citation
+func (r SelfCheckResult) Passed() bool {
+	return len(r.Issues) == 0
+}
+
+// SelfCheck quizzes the agent on its most important knowledge: it generates a question
+// from each of up to count high-importance entries, answers it through retrieval and
+// checks that the answer is correct and cites the entry. It is a quick audit of whether
+// retrieval and memory actually work. Questions are asked outside the chat history.
+func (a *Agent) SelfCheck(ctx context.Context, count int) ([]SelfCheckResult, error) {
+	if a.memories == nil {
+		return nil, errors.New("agent has no knowledge store configured")
+	}
+	model := a.Persona.LanguageModels.Default
+	if model == nil {
+		return nil, errors.New("agent has no language model configured")
+	}
+	if count <= 0 {
+		count = DefaultSelfCheckQuestions
+	}
+
+	entries, err := selfCheckEntries(a.memories, count)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SelfCheckResult, 0, len(entries))
+	for _, entry := range entries {
+		result, err := a.checkEntry(ctx, model, entry)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// selfCheckEntries picks the most important text entries to quiz on
+func selfCheckEntries(store knowledge.Store, count int) ([]knowledge.Entry, error) {
+	records, err := store.SearchRecords(knowledge.Filter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load knowledge: %w", err)
+	}
+
+	entries := make([]knowledge.Entry, 0)
+	for _, record := range records {
+		if record.Importance >= knowledge.ImportanceHigh && record.ContentType != knowledge.ContentTypeBinary &&
+			strings.TrimSpace(string(record.Content)) != "" {
+			entries = append(entries, record)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Importance != entries[j].Importance {
+			return entries[i].Importance > entries[j].Importance
+		}
+		return entries[i].UpdatedAt.After(entries[j].UpdatedAt)
+	})
+	if len(entries) > count {
+		entries = entries[:count]
+	}
+	return entries, nil
+}
+
+// checkEntry asks, answers and grades one question about the entry
+func (a *Agent) checkEntry(ctx context.Context, model llm.LanguageModel, entry knowledge.Entry) (SelfCheckResult, error) {
+	fact := strings.TrimSpace(string(entry.Content))
+	result := SelfCheckResult{EntryID: entry.ID}
+
+	question, err := model.GenerateResponse(ctx, fmt.Sprintf(selfCheckQuestionPrompt, fact))
+	if err != nil {
+		return result, fmt.Errorf("failed to generate question for %s: %w", entry.ID, err)
+	}
+	result.Question = strings.TrimSpace(question)
+
+	// Answer the way a chat message would be answered: retrieve, then generate
+	limit := a.memoryTemplate().MaxSnippets
+	if limit <= 0 {
+		limit = DefaultMemoryMaxSnippets
+	}
+	found, err := a.memories.FullTextSearch(result.Question, knowledge.WithSearchLimit(limit))
+	if err != nil {
+		return result, fmt.Errorf("failed to retrieve knowledge for %s: %w", entry.ID, err)
+	}
+	retrieved := make([]knowledge.Entry, 0, len(found))
+	for _, hit := range found {
+		retrieved = append(retrieved, hit.Entry)
+		if hit.Entry.ID == entry.ID {
+			result.Retrieved = true
+		}
+	}
+
+	// Entries are listed with their IDs so the answer can cite them
+	citable := MemoryTemplate{Header: "Knowledge:", Item: "[{{.ID}}] {{.Content}}", MaxSnippets: limit}
+	rendered, err := citable.Render(retrieved, time.Now())
+	if err != nil {
+		return result, err
+	}
+	messages := []llm.Message{
+		{Role: "system", Content: fmt.Sprintf(selfCheckAnswerPrompt, rendered)},
+		{Role: "user", Content: result.Question},
+	}
+	answer, err := model.GenerateChat(ctx, messages)
+	if err != nil {
+		return result, fmt.Errorf("failed to answer question for %s: %w", entry.ID, err)
+	}
+	result.Answer = strings.TrimSpace(answer)
+	result.Cited = strings.Contains(result.Answer, "["+entry.ID+"]")
+
+	verdict, err := model.GenerateResponse(ctx, fmt.Sprintf(selfCheckJudgePrompt, fact, result.Question, result.Answer))
+	if err != nil {
+		return result, fmt.Errorf("failed to grade answer for %s: %w", entry.ID, err)
+	}
+	result.Correct = strings.HasPrefix(strings.ToLower(strings.TrimSpace(verdict)), "yes")
+
+	if !result.Retrieved {
+		result.Issues = append(result.Issues, "retrieval did not surface the entry")
+	}
+	if !result.Cited {
+		result.Issues = append(result.Issues, "answer does not cite the entry")
+	}
+	if !result.Correct {
+		result.Issues = append(result.Issues, "answer does not agree with the entry")
+	}
+	return result, nil
+}
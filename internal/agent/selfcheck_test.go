@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
+)
+
+// quizLLM asks scripted questions, answers with the first knowledge line it is given
+// and judges an answer correct when it contains the fact
+type quizLLM struct {
+	questions map[string]string // Fact to question
+}
+
+func (m *quizLLM) GenerateChat(ctx context.Context, messages []llm.Message) (string, error) {
+	lines := strings.Split(messages[0].Content, "\n")
+	for i, line := range lines {
+		if line == "Knowledge:" && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "[") {
+			return lines[i+1], nil
+		}
+	}
+	return "I don't know.", nil
+}
+
+func (m *quizLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	if strings.HasPrefix(prompt, "Does the answer agree") {
+		fact := strings.TrimPrefix(strings.Split(prompt, "\n")[2], "Fact: ")
+		if strings.Contains(prompt, "Answer: ") && strings.Contains(prompt[strings.Index(prompt, "Answer: "):], fact) {
+			return "Yes", nil
+		}
+		return "No", nil
+	}
+	for fact, question := range m.questions {
+		if strings.HasSuffix(prompt, fact) {
+			return question, nil
+		}
+	}
+	return "What?", nil
+}
+
+func TestSelfCheck(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	store.Open()
+	store.AddRecord(knowledge.Entry{ID: "stack", Importance: knowledge.ImportanceCritical, Category: knowledge.CategoryFact,
+		Content: []byte("The backend is written in Go")})
+	store.AddRecord(knowledge.Entry{ID: "release", Importance: knowledge.ImportanceHigh, Category: knowledge.CategoryFact,
+		Content: []byte("Releases happen every Tuesday")})
+	store.AddRecord(knowledge.Entry{ID: "trivia", Importance: knowledge.ImportanceLow, Category: knowledge.CategoryFact,
+		Content: []byte("The office plant is called Fern")})
+
+	model := &quizLLM{questions: map[string]string{
+		"The backend is written in Go":  "Which language is the backend written in?",
+		"Releases happen every Tuesday": "When do we ship?",
+	}}
+	agent := NewAgent(Persona{Name: "TestAgent", LanguageModels: LanguageModels{Default: model}})
+	agent.SetMemoryStore(store)
+
+	results, err := agent.SelfCheck(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("SelfCheck failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results for the important entries, got %d", len(results))
+	}
+
+	// Critical entries are checked first
+	stack := results[0]
+	if stack.EntryID != "stack" || !stack.Passed() {
+		t.Errorf("Expected the stack question to pass, got %+v", stack)
+	}
+	if stack.Answer != "[stack] The backend is written in Go" {
+		t.Errorf("Unexpected answer: %q", stack.Answer)
+	}
+
+	// No keywords in common with the entry, so retrieval misses it
+	release := results[1]
+	if release.EntryID != "release" || release.Passed() {
+		t.Errorf("Expected the release question to fail, got %+v", release)
+	}
+	if release.Retrieved || release.Cited || release.Correct || len(release.Issues) != 3 {
+		t.Errorf("Expected every check to fail, got %+v", release)
+	}
+
+	limited, err := agent.SelfCheck(context.Background(), 1)
+	if err != nil || len(limited) != 1 {
+		t.Errorf("Expected 1 result, got %d, %v", len(limited), err)
+	}
+}
+
+func TestSelfCheckRequiresStore(t *testing.T) {
+	agent := NewAgent(Persona{Name: "TestAgent", LanguageModels: LanguageModels{Default: &quizLLM{}}})
+	if _, err := agent.SelfCheck(context.Background(), 1); err == nil {
+		t.Error("Expected error without a knowledge store")
+	}
+}
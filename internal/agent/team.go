@@ -0,0 +1,41 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Teammate is another agent working alongside this one
+type Teammate struct {
+	Name string
+	Role string
+}
+
+// SetTeammates tells the agent which other agents it works with; they are listed in its
+// system prompt so it can point users to the right teammate
+func (a *Agent) SetTeammates(teammates []Teammate) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.teammates = append([]Teammate(nil), teammates...)
+}
+
+// Teammates returns the agents this one works with
+func (a *Agent) Teammates() []Teammate {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return append([]Teammate(nil), a.teammates...)
+}
+
+// teamPrompt returns the system prompt section introducing the teammates, or "" if the
+// agent works alone
+func teamPrompt(teammates []Teammate) string {
+	if len(teammates) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n# Team\nYou work with these teammates; suggest one when a request is more their area than yours:\n")
+	for _, teammate := range teammates {
+		fmt.Fprintf(&sb, "- %s (%s)\n", teammate.Name, teammate.Role)
+	}
+	return sb.String()
+}
@@ -0,0 +1,27 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTeammatesInSystemPrompt(t *testing.T) {
+	agent := NewAgent(Persona{Name: "Andy", SystemPrompt: "You are a product owner."})
+	if agent.systemPrompt() != "You are a product owner." {
+		t.Errorf("Expected the persona prompt for an agent without teammates, got %q", agent.systemPrompt())
+	}
+
+	agent.SetTeammates([]Teammate{{Name: "Ada", Role: "Architect"}, {Name: "Quinn", Role: "QA"}})
+	prompt := agent.systemPrompt()
+	if !strings.HasPrefix(prompt, "You are a product owner.\n# Team") {
+		t.Errorf("Expected the team after the persona prompt, got %q", prompt)
+	}
+	for _, line := range []string{"- Ada (Architect)", "- Quinn (QA)"} {
+		if !strings.Contains(prompt, line) {
+			t.Errorf("Expected %q in the prompt, got %q", line, prompt)
+		}
+	}
+	if len(agent.Teammates()) != 2 {
+		t.Errorf("Expected 2 teammates, got %d", len(agent.Teammates()))
+	}
+}
@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"time"
+
+	"goproduct/internal/llm"
+)
+
+// closeThreadType is the type of the message asking the agent to forget a thread
+const closeThreadType = "close_thread"
+
+// CloseThread forgets the history of a conversation thread once the messages queued
+// before it are answered; a later message in the thread starts it afresh. The default
+// thread, "", cannot be closed.
+func (a *Agent) CloseThread(thread string) {
+	if thread == "" {
+		return
+	}
+	a._messages <- Message{Type: closeThreadType, Thread: thread, Created: time.Now()}
+}
+
+// useThread makes the chat history that of the thread, keeping the history of the
+// thread it was for; it is only called by the worker
+func (a *Agent) useThread(thread string) {
+	if thread == a.thread {
+		return
+	}
+	if a.threads == nil {
+		a.threads = make(map[string][]llm.Message)
+	}
+	if len(a._history) > 0 {
+		a.threads[a.thread] = a._history
+	}
+	a._history = a.threads[thread]
+	delete(a.threads, thread)
+	a.thread = thread
+	a.logger.Debug("Switched chat thread", "thread", thread, "history_length", len(a._history))
+}
+
+// dropThread forgets the history of a thread; it is only called by the worker
+func (a *Agent) dropThread(thread string) {
+	delete(a.threads, thread)
+	if thread == a.thread {
+		a._history = nil
+	}
+	a.logger.Info("Chat thread closed", "thread", thread)
+}
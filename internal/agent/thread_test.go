@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/llm"
+)
+
+// historyLLM answers with the user turns of the history it was given
+type historyLLM struct {
+	MockLLM
+}
+
+func (m *historyLLM) GenerateChat(ctx context.Context, messages []llm.Message) (string, error) {
+	var turns []string
+	for _, message := range messages {
+		if message.Role == "user" {
+			turns = append(turns, message.Content)
+		}
+	}
+	return strings.Join(turns, "|"), nil
+}
+
+func TestThreads(t *testing.T) {
+	agent := NewAgent(Persona{Name: "TestAgent", LanguageModels: LanguageModels{Default: &historyLLM{}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agent.Start(ctx)
+	defer agent.Stop()
+
+	// ask sends a message in a thread and returns the reply
+	ask := func(thread, content string) string {
+		t.Helper()
+		msg := Message{Id: content, Content: content, From: "TestUser", Type: "chat", Thread: thread, ResponseReady: make(chan Message, 1)}
+		agent.HandleExternalMessage(msg)
+		select {
+		case reply := <-msg.ResponseReady:
+			return reply.Content
+		case <-time.After(time.Second):
+			t.Fatalf("Expected a reply to %q", content)
+			return ""
+		}
+	}
+
+	ask("", "roadmap")
+	ask("pricing", "tiers")
+	if got := ask("", "dates"); got != "roadmap|dates" {
+		t.Errorf("Expected the default thread to keep its own history, got %q", got)
+	}
+	if got := ask("pricing", "discounts"); got != "tiers|discounts" {
+		t.Errorf("Expected the pricing thread to keep its own history, got %q", got)
+	}
+
+	agent.CloseThread("pricing")
+	agent.CloseThread("")
+	if got := ask("pricing", "again"); got != "again" {
+		t.Errorf("Expected a closed thread to start afresh, got %q", got)
+	}
+	if got := ask("", "owners"); got != "roadmap|dates|owners" {
+		t.Errorf("Expected the default thread not to be closed, got %q", got)
+	}
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"errors"
+
+	"goproduct/internal/llm"
+	"goproduct/internal/tools"
+)
+
+// DefaultMaxToolSteps bounds the tool calls an agent makes while answering one message
+const DefaultMaxToolSteps = 5
+
+// errToolLimit tells the model it must answer without further tool calls
+var errToolLimit = errors.New("tool call limit reached, answer the user now without tools")
+
+// SetTools gives the agent tools it may call while answering; they are described in its
+// system prompt. A nil registry takes the tools away.
+func (a *Agent) SetTools(registry *tools.Registry) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.tools = registry
+}
+
+// toolRegistry returns the agent's tools, nil if it has none
+func (a *Agent) toolRegistry() *tools.Registry {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.tools
+}
+
+// generate asks the model to answer a message, running the tools it calls on the way.
+// Tool calls and their results are only part of this exchange, not of the chat history.
+func (a *Agent) generate(ctx context.Context, msg Message, messages []llm.Message) (string, error) {
+	model := a.Persona.LanguageModels.Default
+	registry := a.toolRegistry()
+	if planner := a.reasoningLoop(); planner != nil {
+		if registry == nil {
+			registry = tools.NewRegistry()
+		}
+		return a.plan(ctx, planner, registry, msg, messages)
+	}
+	if registry == nil {
+		fitted, err := a.fitContext(msg, messages)
+		if err != nil {
+			return "", err
+		}
+		if msg.OnToken != nil && a.streamable() {
+			return llm.GenerateChatStream(ctx, model, fitted, func(token llm.StreamToken) error {
+				if token.Text != "" {
+					msg.OnToken(token.Text)
+				}
+				return nil
+			})
+		}
+		return model.GenerateChat(ctx, fitted)
+	}
+
+	ctx = tools.WithCaller(ctx, tools.Caller{AgentName: a.Persona.Name, SenderID: msg.From, MessageID: msg.Id})
+	working := append([]llm.Message(nil), messages...)
+	for step := 0; ; step++ {
+		fitted, err := a.fitContext(msg, working)
+		if err != nil {
+			return "", err
+		}
+		response, err := model.GenerateChat(ctx, fitted)
+		if err != nil {
+			return "", err
+		}
+		call, found, parseErr := tools.ParseCall(response)
+		if !found {
+			return response, nil
+		}
+		if step > DefaultMaxToolSteps {
+			a.logger.Warn("Agent kept calling tools past the limit", "message_id", msg.Id, "tool", call.Name)
+			return tools.StripCall(response), nil
+		}
+
+		var result string
+		switch {
+		case parseErr != nil:
+			err = parseErr
+		case step == DefaultMaxToolSteps:
+			err = errToolLimit
+		default:
+			result, err = registry.Call(ctx, call)
+			a.logger.Debug("Agent called tool", "message_id", msg.Id, "tool", call.Name, "error", err)
+		}
+		working = append(working,
+			llm.Message{Role: "assistant", Content: response},
+			llm.Message{Role: "user", Content: tools.FormatResult(call.Name, result, err)},
+		)
+	}
+}
+
+// streamable reports whether the model's answer is the reply as written, so that it can be
+// streamed to the sender: no after hooks or outbound guardrails change it afterwards
+func (a *Agent) streamable() bool {
+	return len(a.hookChains().After) == 0 && a.guardrailPipeline().Len() == 0
+}
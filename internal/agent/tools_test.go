@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"goproduct/internal/llm"
+	"goproduct/internal/tools"
+)
+
+// scriptedLLM replies with its answers in turn and records the chats it saw
+type scriptedLLM struct {
+	answers []string
+	chats   [][]llm.Message
+	mu      sync.Mutex
+}
+
+func (m *scriptedLLM) GenerateChat(ctx context.Context, messages []llm.Message) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.chats = append(m.chats, messages)
+	answer := m.answers[0]
+	if len(m.answers) > 1 {
+		m.answers = m.answers[1:]
+	}
+	return answer, nil
+}
+
+func (m *scriptedLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return m.GenerateChat(ctx, []llm.Message{{Role: "user", Content: prompt}})
+}
+
+func TestAgentCallsTools(t *testing.T) {
+	model := &scriptedLLM{answers: []string{
+		`<tool_call>{"name": "lookup", "arguments": {"topic": "launch"}}</tool_call>`,
+		"The launch is in March.",
+	}}
+	agent := NewAgent(Persona{Name: "TestAgent", LanguageModels: LanguageModels{Default: model}})
+	var caller tools.Caller
+	agent.SetTools(tools.NewRegistry(tools.Tool{
+		Name:        "lookup",
+		Description: "Look up a topic",
+		Handler: func(ctx context.Context, args tools.Arguments) (string, error) {
+			caller = tools.CallerFrom(ctx)
+			return "launch: March", nil
+		},
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agent.Start(ctx)
+	defer agent.Stop()
+
+	msg := agent.Chat("TestUser", "When is the launch?")
+	response := <-msg.ResponseReady
+	if response.Content != "The launch is in March." {
+		t.Errorf("Expected the final answer, got %q", response.Content)
+	}
+	if caller.AgentName != "TestAgent" || caller.SenderID != "TestUser" {
+		t.Errorf("Unexpected caller: %+v", caller)
+	}
+
+	model.mu.Lock()
+	defer model.mu.Unlock()
+	if len(model.chats) != 2 {
+		t.Fatalf("Expected 2 model calls, got %d", len(model.chats))
+	}
+	if !strings.Contains(model.chats[0][0].Content, "- lookup: Look up a topic") {
+		t.Error("Expected the system prompt to describe the tools")
+	}
+	last := model.chats[1][len(model.chats[1])-1]
+	if !strings.Contains(last.Content, "launch: March") {
+		t.Errorf("Expected the tool result to be handed back, got %q", last.Content)
+	}
+	for _, m := range agent._history[1:] {
+		if strings.Contains(m.Content, "tool_") {
+			t.Errorf("Tool exchange leaked into the history: %q", m.Content)
+		}
+	}
+}
+
+func TestAgentStopsCallingToolsAtTheLimit(t *testing.T) {
+	model := &scriptedLLM{answers: []string{`Checking. <tool_call>{"name": "lookup", "arguments": {}}</tool_call>`}}
+	agent := NewAgent(Persona{Name: "TestAgent", LanguageModels: LanguageModels{Default: model}})
+	calls := 0
+	agent.SetTools(tools.NewRegistry(tools.Tool{
+		Name:    "lookup",
+		Handler: func(ctx context.Context, args tools.Arguments) (string, error) { calls++; return "", nil },
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agent.Start(ctx)
+	defer agent.Stop()
+
+	response := <-agent.Chat("TestUser", "Anything?").ResponseReady
+	if response.Content != "Checking." {
+		t.Errorf("Expected the response without the call, got %q", response.Content)
+	}
+	if calls != DefaultMaxToolSteps {
+		t.Errorf("Expected %d tool calls, got %d", DefaultMaxToolSteps, calls)
+	}
+}
+
+func TestAgentStreamsReply(t *testing.T) {
+	agent := NewAgent(Persona{Name: "TestAgent", LanguageModels: LanguageModels{Default: &scriptedLLM{answers: []string{"Hello there."}}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agent.Start(ctx)
+	defer agent.Stop()
+
+	var mu sync.Mutex
+	var streamed strings.Builder
+	msg := Message{
+		Id:            "streamed",
+		Content:       "Hi",
+		From:          "TestUser",
+		Type:          "chat",
+		ResponseReady: make(chan Message, 1),
+		OnToken: func(text string) {
+			mu.Lock()
+			defer mu.Unlock()
+			streamed.WriteString(text)
+		},
+	}
+	agent.HandleExternalMessage(msg)
+	response := <-msg.ResponseReady
+	mu.Lock()
+	defer mu.Unlock()
+	if response.Content != "Hello there." || streamed.String() != "Hello there." {
+		t.Errorf("Expected the reply to be streamed, got %q streamed for %q", streamed.String(), response.Content)
+	}
+
+	// An after hook may change the reply, which is then not streamed
+	streamed.Reset()
+	agent.AddHooks(Hooks{After: []AfterHook{func(ctx context.Context, msg Message, response string) (string, error) {
+		return strings.ToUpper(response), nil
+	}}})
+	msg.Id, msg.ResponseReady = "hooked", make(chan Message, 1)
+	mu.Unlock()
+	agent.HandleExternalMessage(msg)
+	response = <-msg.ResponseReady
+	mu.Lock()
+	if response.Content != "HELLO THERE." || streamed.Len() != 0 {
+		t.Errorf("Expected an after hook to stop the streaming, got %q streamed for %q", streamed.String(), response.Content)
+	}
+}
@@ -0,0 +1,138 @@
+// Package auth establishes which human entity a connection belongs to. Providers check
+// credentials (local passwords, OIDC, GitHub OAuth) and return an Identity; the
+// Authenticator maps the identity to an entity ID and issues a session with access and
+// refresh tokens.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by providers and sessions
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrUnknownProvider    = errors.New("unknown authentication provider")
+	ErrUnknownIdentity    = errors.New("identity is not mapped to an entity")
+	ErrInvalidToken       = errors.New("invalid or revoked token")
+	ErrTokenExpired       = errors.New("token expired")
+)
+
+// Identity is a user as established by a provider
+type Identity struct {
+	Provider string // Name of the provider that established the identity
+	Subject  string // Stable user ID at the provider
+	Email    string // Email address, if the provider shares it
+	Name     string // Display or login name
+	EntityID string // Entity ID, set by providers that know it (e.g. local users)
+}
+
+// Key returns the identity as "provider:subject"
+func (i Identity) Key() string {
+	return i.Provider + ":" + i.Subject
+}
+
+// Credentials are what a user presents to a provider. Password providers use Username
+// and Password; OAuth providers use the Code returned to the redirect URL.
+type Credentials struct {
+	Username string
+	Password string
+	Code     string
+}
+
+// Provider checks credentials and returns the identity they belong to
+type Provider interface {
+	Name() string
+	Authenticate(ctx context.Context, credentials Credentials) (Identity, error)
+}
+
+// RedirectProvider is a provider that authenticates through a browser redirect: the
+// gateway sends the user to AuthCodeURL and passes the returned code to Authenticate
+type RedirectProvider interface {
+	Provider
+	AuthCodeURL(ctx context.Context, state string) (string, error)
+}
+
+// IdentityMap maps identities to human entity IDs
+type IdentityMap struct {
+	Identities map[string]string // "provider:subject" or email address to entity ID
+	AllowEmail bool              // Map by email address; only safe with providers that verify emails
+}
+
+// Resolve returns the entity ID of the identity
+func (m IdentityMap) Resolve(identity Identity) (string, error) {
+	if identity.EntityID != "" {
+		return identity.EntityID, nil
+	}
+	if id, ok := m.Identities[identity.Key()]; ok {
+		return id, nil
+	}
+	if m.AllowEmail && identity.Email != "" {
+		if id, ok := m.Identities[strings.ToLower(identity.Email)]; ok {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownIdentity, identity.Key())
+}
+
+// Authenticator logs users in through its providers and manages their sessions
+type Authenticator struct {
+	providers map[string]Provider
+	sessions  *SessionManager
+	resolve   func(Identity) (string, error)
+}
+
+// NewAuthenticator creates an authenticator that maps identities to entities with
+// resolve and issues sessions from the session manager
+func NewAuthenticator(sessions *SessionManager, resolve func(Identity) (string, error), providers ...Provider) *Authenticator {
+	a := &Authenticator{
+		providers: make(map[string]Provider),
+		sessions:  sessions,
+		resolve:   resolve,
+	}
+	for _, provider := range providers {
+		a.providers[provider.Name()] = provider
+	}
+	return a
+}
+
+// Provider returns the provider with the given name
+func (a *Authenticator) Provider(name string) (Provider, bool) {
+	provider, ok := a.providers[name]
+	return provider, ok
+}
+
+// Login authenticates the credentials with the named provider and starts a session
+// for the entity the identity maps to
+func (a *Authenticator) Login(ctx context.Context, provider string, credentials Credentials) (Session, error) {
+	p, ok := a.providers[provider]
+	if !ok {
+		return Session{}, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+	identity, err := p.Authenticate(ctx, credentials)
+	if err != nil {
+		return Session{}, err
+	}
+	entityID, err := a.resolve(identity)
+	if err != nil {
+		return Session{}, err
+	}
+	return a.sessions.Create(identity, entityID)
+}
+
+// Validate returns the session of an access token
+func (a *Authenticator) Validate(accessToken string) (Session, error) {
+	return a.sessions.Validate(accessToken)
+}
+
+// Refresh exchanges a refresh token for a new session
+func (a *Authenticator) Refresh(refreshToken string) (Session, error) {
+	return a.sessions.Refresh(refreshToken)
+}
+
+// Logout ends the session of an access token
+func (a *Authenticator) Logout(accessToken string) error {
+	return a.sessions.Revoke(accessToken)
+}
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocalProvider(t *testing.T) *LocalProvider {
+	t.Helper()
+	p := NewLocalProvider()
+	p.iterations = 1000 // Keep tests fast
+	if err := p.SetPassword("ana", "correct horse", "human-ana"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+	return p
+}
+
+func TestLocalProvider(t *testing.T) {
+	p := newTestLocalProvider(t)
+	ctx := context.Background()
+
+	identity, err := p.Authenticate(ctx, Credentials{Username: "ana", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if identity.EntityID != "human-ana" || identity.Key() != "local:ana" {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+
+	if _, err := p.Authenticate(ctx, Credentials{Username: "ana", Password: "wrong"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for a wrong password, got %v", err)
+	}
+	if _, err := p.Authenticate(ctx, Credentials{Username: "bob", Password: "correct horse"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for an unknown user, got %v", err)
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	sessions := NewSessionManager(time.Minute, time.Hour)
+	sessions.now = func() time.Time { return now }
+	auth := NewAuthenticator(sessions, IdentityMap{}.Resolve, newTestLocalProvider(t))
+
+	session, err := auth.Login(context.Background(), ProviderLocal, Credentials{Username: "ana", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if session.EntityID != "human-ana" || session.AccessToken == "" || session.RefreshToken == "" {
+		t.Fatalf("Unexpected session: %+v", session)
+	}
+	if validated, err := auth.Validate(session.AccessToken); err != nil || validated.EntityID != "human-ana" || validated.AccessToken != "" {
+		t.Errorf("Expected a valid session without tokens, got %+v, %v", validated, err)
+	}
+
+	// Access tokens expire; refresh rotates both tokens
+	now = now.Add(2 * time.Minute)
+	if _, err := auth.Validate(session.AccessToken); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+	refreshed, err := auth.Refresh(session.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, err := auth.Refresh(session.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the old refresh token to be rejected, got %v", err)
+	}
+	if _, err := auth.Validate(refreshed.AccessToken); err != nil {
+		t.Errorf("Expected the new access token to be valid, got %v", err)
+	}
+
+	if err := auth.Logout(refreshed.AccessToken); err != nil {
+		t.Errorf("Logout failed: %v", err)
+	}
+	if _, err := auth.Validate(refreshed.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken after logout, got %v", err)
+	}
+	if _, err := auth.Refresh(refreshed.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the refresh token to be revoked by logout, got %v", err)
+	}
+
+	if _, err := auth.Login(context.Background(), "saml", Credentials{}); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("Expected ErrUnknownProvider, got %v", err)
+	}
+}
+
+func TestIdentityMap(t *testing.T) {
+	m := IdentityMap{Identities: map[string]string{"github:42": "human-ana", "bob@example.com": "human-bob"}}
+
+	if id, err := m.Resolve(Identity{Provider: ProviderGitHub, Subject: "42"}); err != nil || id != "human-ana" {
+		t.Errorf("Expected human-ana, got %q, %v", id, err)
+	}
+	if _, err := m.Resolve(Identity{Provider: ProviderOIDC, Subject: "x", Email: "Bob@example.com"}); !errors.Is(err, ErrUnknownIdentity) {
+		t.Errorf("Expected emails to be ignored unless allowed, got %v", err)
+	}
+	m.AllowEmail = true
+	if id, err := m.Resolve(Identity{Provider: ProviderOIDC, Subject: "x", Email: "Bob@example.com"}); err != nil || id != "human-bob" {
+		t.Errorf("Expected human-bob, got %q, %v", id, err)
+	}
+}
+
+func TestGitHubProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.FormValue("code") != "good" {
+				w.Write([]byte(`{"error": "bad_verification_code"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token": "gh-token", "token_type": "bearer"}`))
+		case "/api/user":
+			if r.Header.Get("Authorization") != "Bearer gh-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{}`))
+				return
+			}
+			w.Write([]byte(`{"id": 42, "login": "ana", "email": "ana@example.com"}`))
+		}
+	}))
+	defer server.Close()
+
+	p := NewGitHubProvider(OAuthConfig{ClientID: "client", ClientSecret: "secret", RedirectURL: "http://localhost/callback"},
+		WithGitHubEndpoints(server.URL+"/authorize", server.URL+"/token", server.URL+"/api"))
+
+	url, _ := p.AuthCodeURL(context.Background(), "xyz")
+	if !strings.HasPrefix(url, server.URL+"/authorize?") || !strings.Contains(url, "state=xyz") {
+		t.Errorf("Unexpected authorization URL: %s", url)
+	}
+
+	identity, err := p.Authenticate(context.Background(), Credentials{Code: "good"})
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if identity.Key() != "github:42" || identity.Name != "ana" || identity.Email != "ana@example.com" {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+	if _, err := p.Authenticate(context.Background(), Credentials{Code: "bad"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for a bad code, got %v", err)
+	}
+}
+
+func TestOIDCProvider(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var server *httptest.Server
+	sign := func(claims map[string]interface{}) string {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1"})
+		payload, _ := json.Marshal(claims)
+		signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		digest := sha256.Sum256([]byte(signed))
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+	audience := "client"
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 server.URL,
+				"authorization_endpoint": server.URL + "/authorize",
+				"token_endpoint":         server.URL + "/token",
+				"jwks_uri":               server.URL + "/keys",
+			})
+		case "/keys":
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		case "/token":
+			idToken := sign(map[string]interface{}{
+				"iss":            server.URL,
+				"sub":            "user-7",
+				"aud":            audience,
+				"exp":            time.Now().Add(time.Hour).Unix(),
+				"email":          "ana@example.com",
+				"email_verified": true,
+				"name":           "Ana",
+			})
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "id_token": idToken})
+		}
+	}))
+	defer server.Close()
+
+	p := NewOIDCProvider("corp", server.URL, OAuthConfig{ClientID: "client", RedirectURL: "http://localhost/callback"})
+
+	url, err := p.AuthCodeURL(context.Background(), "xyz")
+	if err != nil || !strings.HasPrefix(url, server.URL+"/authorize?") || !strings.Contains(url, "scope=openid+email+profile") {
+		t.Errorf("Unexpected authorization URL: %s, %v", url, err)
+	}
+
+	identity, err := p.Authenticate(context.Background(), Credentials{Code: "code"})
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if identity.Key() != "corp:user-7" || identity.Email != "ana@example.com" || identity.Name != "Ana" {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+
+	// Tokens for another client are rejected
+	audience = "someone-else"
+	if _, err := p.Authenticate(context.Background(), Credentials{Code: "code"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for a foreign audience, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProviderGitHub is the name of the GitHub OAuth provider
+const ProviderGitHub = "github"
+
+// GitHub endpoints
+const (
+	DefaultGitHubAuthURL  = "https://github.com/login/oauth/authorize"
+	DefaultGitHubTokenURL = "https://github.com/login/oauth/access_token"
+	DefaultGitHubAPIURL   = "https://api.github.com"
+)
+
+// GitHubProvider authenticates users with GitHub OAuth. The identity's subject is the
+// numeric GitHub user ID, which unlike the login never changes.
+type GitHubProvider struct {
+	config   OAuthConfig
+	authURL  string
+	tokenURL string
+	apiURL   string
+	client   *http.Client
+}
+
+// GitHubOption configures a GitHubProvider
+type GitHubOption func(*GitHubProvider)
+
+// WithGitHubEndpoints replaces the GitHub endpoints, e.g. for GitHub Enterprise
+func WithGitHubEndpoints(authURL, tokenURL, apiURL string) GitHubOption {
+	return func(p *GitHubProvider) {
+		p.authURL = authURL
+		p.tokenURL = tokenURL
+		p.apiURL = strings.TrimRight(apiURL, "/")
+	}
+}
+
+// NewGitHubProvider creates a GitHub provider for the OAuth app
+func NewGitHubProvider(config OAuthConfig, options ...GitHubOption) *GitHubProvider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"read:user", "user:email"}
+	}
+	p := &GitHubProvider{
+		config:   config,
+		authURL:  DefaultGitHubAuthURL,
+		tokenURL: DefaultGitHubTokenURL,
+		apiURL:   DefaultGitHubAPIURL,
+		client:   &http.Client{Timeout: defaultHTTPTimeout},
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// Name returns ProviderGitHub
+func (p *GitHubProvider) Name() string {
+	return ProviderGitHub
+}
+
+// AuthCodeURL returns the GitHub authorization URL to send the user to
+func (p *GitHubProvider) AuthCodeURL(ctx context.Context, state string) (string, error) {
+	return p.config.authCodeURL(p.authURL, state, p.config.Scopes), nil
+}
+
+// Authenticate exchanges the code for an access token and looks up the GitHub user
+func (p *GitHubProvider) Authenticate(ctx context.Context, credentials Credentials) (Identity, error) {
+	token, err := p.config.exchange(ctx, p.client, p.tokenURL, credentials.Code)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.apiURL+"/user", nil)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to create GitHub user request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+	status, err := doJSON(p.client, req, &user)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to get GitHub user: %w", err)
+	}
+	if status != http.StatusOK || user.ID == 0 {
+		return Identity{}, fmt.Errorf("failed to get GitHub user: status %d", status)
+	}
+
+	name := user.Name
+	if name == "" {
+		name = user.Login
+	}
+	return Identity{
+		Provider: ProviderGitHub,
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Email:    user.Email,
+		Name:     name,
+	}, nil
+}
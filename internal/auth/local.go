@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ProviderLocal is the name of the local password provider
+const ProviderLocal = "local"
+
+// Password hashing parameters
+const (
+	passwordIterations = 600000
+	passwordKeyLength  = 32
+	passwordSaltLength = 16
+)
+
+// localUser is a user of the local provider
+type localUser struct {
+	entityID string
+	salt     []byte
+	hash     []byte
+}
+
+// LocalProvider authenticates users with passwords hashed with PBKDF2-SHA256
+type LocalProvider struct {
+	users      map[string]localUser
+	iterations int
+	mu         sync.RWMutex
+}
+
+// NewLocalProvider creates a local provider without users
+func NewLocalProvider() *LocalProvider {
+	return &LocalProvider{users: make(map[string]localUser), iterations: passwordIterations}
+}
+
+// Name returns ProviderLocal
+func (p *LocalProvider) Name() string {
+	return ProviderLocal
+}
+
+// SetPassword creates the user, or changes their password, and maps them to the entity
+func (p *LocalProvider) SetPassword(username, password, entityID string) error {
+	if username == "" || password == "" {
+		return errors.New("username and password cannot be empty")
+	}
+	salt := make([]byte, passwordSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	hash, err := pbkdf2.Key(sha256.New, password, salt, p.iterations, passwordKeyLength)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.users[username] = localUser{entityID: entityID, salt: salt, hash: hash}
+	return nil
+}
+
+// RemoveUser deletes the user
+func (p *LocalProvider) RemoveUser(username string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.users, username)
+}
+
+// Authenticate checks the username and password
+func (p *LocalProvider) Authenticate(ctx context.Context, credentials Credentials) (Identity, error) {
+	p.mu.RLock()
+	user, ok := p.users[credentials.Username]
+	p.mu.RUnlock()
+	if !ok {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	hash, err := pbkdf2.Key(sha256.New, credentials.Password, user.salt, p.iterations, passwordKeyLength)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+	if subtle.ConstantTimeCompare(hash, user.hash) != 1 {
+		return Identity{}, ErrInvalidCredentials
+	}
+	return Identity{
+		Provider: ProviderLocal,
+		Subject:  credentials.Username,
+		Name:     credentials.Username,
+		EntityID: user.entityID,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultHTTPTimeout limits requests to identity providers
+const defaultHTTPTimeout = 15 * time.Second
+
+// OAuthConfig is the client registration shared by the OAuth providers
+type OAuthConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string   // Where the provider sends the user back with a code
+	Scopes       []string // Requested scopes, provider defaults if empty
+}
+
+// tokenResponse is the token endpoint response of OAuth 2.0 and OIDC
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// authCodeURL builds the URL that starts an authorization code flow
+func (c OAuthConfig) authCodeURL(endpoint, state string, scopes []string) string {
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {c.ClientID},
+		"redirect_uri":  {c.RedirectURL},
+		"scope":         {strings.Join(scopes, " ")},
+		"state":         {state},
+	}
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+	return endpoint + separator + params.Encode()
+}
+
+// exchange trades an authorization code for tokens at the token endpoint
+func (c OAuthConfig) exchange(ctx context.Context, client *http.Client, endpoint, code string) (tokenResponse, error) {
+	if code == "" {
+		return tokenResponse{}, ErrInvalidCredentials
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.RedirectURL},
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token tokenResponse
+	status, err := doJSON(client, req, &token)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("token exchange failed: %w", err)
+	}
+	if token.Error != "" {
+		// Expired, reused or forged codes are rejected with an error payload
+		return tokenResponse{}, fmt.Errorf("%w: %s %s", ErrInvalidCredentials, token.Error, token.ErrorDescription)
+	}
+	if status != http.StatusOK {
+		return tokenResponse{}, fmt.Errorf("token exchange failed with status %d", status)
+	}
+	return token, nil
+}
+
+// doJSON sends the request and decodes the JSON response into v
+func doJSON(client *http.Client, req *http.Request, v interface{}) (int, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to parse response with status %d: %w", resp.StatusCode, err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProviderOIDC is the default name of an OpenID Connect provider
+const ProviderOIDC = "oidc"
+
+// oidcClockSkew is the leeway allowed when checking token expiry
+const oidcClockSkew = time.Minute
+
+// oidcDiscovery is the part of the provider configuration document that is used
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// jsonWebKey is an RSA key of a key set
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// OIDCProvider authenticates users with an OpenID Connect identity provider using the
+// authorization code flow. ID tokens must be signed with RS256.
+type OIDCProvider struct {
+	name      string
+	issuer    string
+	config    OAuthConfig
+	client    *http.Client
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+	mu        sync.Mutex
+}
+
+// NewOIDCProvider creates a provider for the issuer, e.g. "https://accounts.google.com".
+// The configuration is discovered on first use.
+func NewOIDCProvider(name, issuer string, config OAuthConfig) *OIDCProvider {
+	if name == "" {
+		name = ProviderOIDC
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &OIDCProvider{
+		name:   name,
+		issuer: strings.TrimRight(issuer, "/"),
+		config: config,
+		client: &http.Client{Timeout: defaultHTTPTimeout},
+	}
+}
+
+// Name returns the provider name
+func (p *OIDCProvider) Name() string {
+	return p.name
+}
+
+// AuthCodeURL returns the authorization URL to send the user to
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return p.config.authCodeURL(discovery.AuthorizationEndpoint, state, p.config.Scopes), nil
+}
+
+// Authenticate exchanges the code for tokens and verifies the ID token
+func (p *OIDCProvider) Authenticate(ctx context.Context, credentials Credentials) (Identity, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+	token, err := p.config.exchange(ctx, p.client, discovery.TokenEndpoint, credentials.Code)
+	if err != nil {
+		return Identity{}, err
+	}
+	if token.IDToken == "" {
+		return Identity{}, errors.New("token response has no ID token")
+	}
+
+	claims, err := p.verify(ctx, token.IDToken)
+	if err != nil {
+		return Identity{}, err
+	}
+	identity := Identity{Provider: p.name, Subject: claims.Subject, Name: claims.Name}
+	// Unverified emails could be claimed by anyone
+	if claims.EmailVerified {
+		identity.Email = claims.Email
+	}
+	return identity, nil
+}
+
+// idTokenClaims are the ID token claims that are used
+type idTokenClaims struct {
+	Issuer        string          `json:"iss"`
+	Subject       string          `json:"sub"`
+	Audience      json.RawMessage `json:"aud"`
+	Expiry        int64           `json:"exp"`
+	Email         string          `json:"email"`
+	EmailVerified bool            `json:"email_verified"`
+	Name          string          `json:"name"`
+}
+
+// verify checks the signature and claims of an ID token
+func (p *OIDCProvider) verify(ctx context.Context, idToken string) (idTokenClaims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return idTokenClaims{}, errors.New("malformed ID token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return idTokenClaims{}, fmt.Errorf("malformed ID token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return idTokenClaims{}, fmt.Errorf("unsupported ID token algorithm %q", header.Alg)
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return idTokenClaims{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return idTokenClaims{}, fmt.Errorf("malformed ID token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return idTokenClaims{}, fmt.Errorf("%w: ID token signature does not verify", ErrInvalidCredentials)
+	}
+
+	var claims idTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return idTokenClaims{}, fmt.Errorf("malformed ID token claims: %w", err)
+	}
+	if claims.Issuer != p.issuer {
+		return idTokenClaims{}, fmt.Errorf("%w: ID token issued by %s", ErrInvalidCredentials, claims.Issuer)
+	}
+	if !audienceContains(claims.Audience, p.config.ClientID) {
+		return idTokenClaims{}, fmt.Errorf("%w: ID token not issued for this client", ErrInvalidCredentials)
+	}
+	if time.Unix(claims.Expiry, 0).Add(oidcClockSkew).Before(time.Now()) {
+		return idTokenClaims{}, fmt.Errorf("%w: ID token expired", ErrInvalidCredentials)
+	}
+	if claims.Subject == "" {
+		return idTokenClaims{}, errors.New("ID token has no subject")
+	}
+	return claims, nil
+}
+
+// discover loads the provider configuration once
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery request: %w", err)
+	}
+	var discovery oidcDiscovery
+	status, err := doJSON(p.client, req, &discovery)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("OIDC discovery failed with status %d", status)
+	}
+	if discovery.Issuer != p.issuer {
+		return nil, fmt.Errorf("OIDC discovery returned issuer %s, expected %s", discovery.Issuer, p.issuer)
+	}
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// key returns the signing key with the given ID, reloading the key set once if it is
+// unknown so that key rotation is picked up
+func (p *OIDCProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := p.loadKeys(ctx); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown ID token signing key %q", ErrInvalidCredentials, kid)
+}
+
+// loadKeys fetches the provider's key set
+func (p *OIDCProvider) loadKeys(ctx context.Context) error {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", discovery.JWKSURI, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create key set request: %w", err)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	status, err := doJSON(p.client, req, &set)
+	if err != nil {
+		return fmt.Errorf("failed to load OIDC key set: %w", err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("failed to load OIDC key set: status %d", status)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = keys
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// audienceContains reports whether the "aud" claim, a string or a list, contains the client
+func audienceContains(raw json.RawMessage, clientID string) bool {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single == clientID
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		for _, audience := range list {
+			if audience == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
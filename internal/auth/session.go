@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Session lifetimes
+const (
+	DefaultAccessTTL  = 15 * time.Minute
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+// tokenBytes is the number of random bytes in a token
+const tokenBytes = 32
+
+// Session is an authenticated connection of a human entity. The tokens are only
+// returned when the session is created or refreshed; the manager keeps their hashes.
+type Session struct {
+	EntityID         string
+	Identity         Identity
+	AccessToken      string
+	RefreshToken     string
+	ExpiresAt        time.Time // When the access token expires
+	RefreshExpiresAt time.Time // When the refresh token expires
+}
+
+// session is the stored state of a session
+type session struct {
+	entityID         string
+	identity         Identity
+	accessHash       string
+	refreshHash      string
+	expiresAt        time.Time
+	refreshExpiresAt time.Time
+}
+
+// SessionManager issues, validates, refreshes and revokes sessions in memory
+type SessionManager struct {
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	byAccess   map[string]*session
+	byRefresh  map[string]*session
+	now        func() time.Time
+	mu         sync.Mutex
+}
+
+// NewSessionManager creates a session manager. Non-positive lifetimes select
+// DefaultAccessTTL and DefaultRefreshTTL.
+func NewSessionManager(accessTTL, refreshTTL time.Duration) *SessionManager {
+	if accessTTL <= 0 {
+		accessTTL = DefaultAccessTTL
+	}
+	if refreshTTL <= 0 {
+		refreshTTL = DefaultRefreshTTL
+	}
+	return &SessionManager{
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		byAccess:   make(map[string]*session),
+		byRefresh:  make(map[string]*session),
+		now:        time.Now,
+	}
+}
+
+// Create starts a session for the entity
+func (m *SessionManager) Create(identity Identity, entityID string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep()
+	s := &session{entityID: entityID, identity: identity}
+	return m.issue(s)
+}
+
+// Validate returns the session of an access token
+func (m *SessionManager) Validate(accessToken string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.byAccess[hashToken(accessToken)]
+	if !ok {
+		return Session{}, ErrInvalidToken
+	}
+	if !m.now().Before(s.expiresAt) {
+		return Session{}, ErrTokenExpired
+	}
+	return s.public(), nil
+}
+
+// Refresh rotates both tokens of the session a refresh token belongs to. The old
+// tokens stop working.
+func (m *SessionManager) Refresh(refreshToken string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.byRefresh[hashToken(refreshToken)]
+	if !ok {
+		return Session{}, ErrInvalidToken
+	}
+	if !m.now().Before(s.refreshExpiresAt) {
+		m.remove(s)
+		return Session{}, ErrTokenExpired
+	}
+	m.remove(s)
+	return m.issue(s)
+}
+
+// Revoke ends the session an access token belongs to
+func (m *SessionManager) Revoke(accessToken string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.byAccess[hashToken(accessToken)]
+	if !ok {
+		return ErrInvalidToken
+	}
+	m.remove(s)
+	return nil
+}
+
+// RevokeEntity ends every session of the entity and returns how many were ended
+func (m *SessionManager) RevokeEntity(entityID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revoked := 0
+	for _, s := range m.byAccess {
+		if s.entityID == entityID {
+			m.remove(s)
+			revoked++
+		}
+	}
+	return revoked
+}
+
+// issue gives the session new tokens (must be called with lock held)
+func (m *SessionManager) issue(s *session) (Session, error) {
+	access, err := newToken()
+	if err != nil {
+		return Session{}, err
+	}
+	refresh, err := newToken()
+	if err != nil {
+		return Session{}, err
+	}
+
+	now := m.now()
+	s.accessHash = hashToken(access)
+	s.refreshHash = hashToken(refresh)
+	s.expiresAt = now.Add(m.accessTTL)
+	s.refreshExpiresAt = now.Add(m.refreshTTL)
+	m.byAccess[s.accessHash] = s
+	m.byRefresh[s.refreshHash] = s
+
+	result := s.public()
+	result.AccessToken = access
+	result.RefreshToken = refresh
+	return result, nil
+}
+
+// remove drops a session (must be called with lock held)
+func (m *SessionManager) remove(s *session) {
+	delete(m.byAccess, s.accessHash)
+	delete(m.byRefresh, s.refreshHash)
+}
+
+// sweep drops sessions whose refresh token expired (must be called with lock held)
+func (m *SessionManager) sweep() {
+	now := m.now()
+	for _, s := range m.byRefresh {
+		if !now.Before(s.refreshExpiresAt) {
+			m.remove(s)
+		}
+	}
+}
+
+// public returns the session without tokens
+func (s *session) public() Session {
+	return Session{
+		EntityID:         s.entityID,
+		Identity:         s.identity,
+		ExpiresAt:        s.expiresAt,
+		RefreshExpiresAt: s.refreshExpiresAt,
+	}
+}
+
+// newToken returns a random token
+func newToken() (string, error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashToken returns the hash under which a token is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,253 @@
+package chat
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/logging"
+)
+
+// SuggestionProvider supplies autocomplete suggestions for the word being typed
+type SuggestionProvider interface {
+	// Suggest returns up to limit suggestions for the given prefix, best first
+	Suggest(prefix string, limit int) []string
+}
+
+// TopicRecorder is implemented by suggestion providers that learn from the conversation
+type TopicRecorder interface {
+	// RecordTopic records text the user sent so its topics can be suggested later
+	RecordTopic(text string)
+}
+
+// KnowledgeSuggestionProvider suggests knowledge entry titles and tags as well as
+// topics from recent messages. Typing a tag (e.g. "roadmap") also offers the titles
+// of entries carrying that tag, such as known initiative names.
+type KnowledgeSuggestionProvider struct {
+	store        knowledge.Store
+	refreshEvery time.Duration
+	maxTopics    int
+
+	mutex       sync.RWMutex
+	refreshedAt time.Time
+	titles      []string            // Entry titles
+	tags        []string            // Distinct entry tags
+	titlesByTag map[string][]string // Lower-cased tag to titles of entries with that tag
+	topics      []string            // Recent conversation topics, most recent first
+	logger      *logging.Logger
+}
+
+// NewKnowledgeSuggestionProvider creates a suggestion provider backed by a knowledge store.
+// The index of titles and tags is rebuilt at most once per refreshEvery.
+func NewKnowledgeSuggestionProvider(store knowledge.Store, refreshEvery time.Duration) *KnowledgeSuggestionProvider {
+	if refreshEvery <= 0 {
+		refreshEvery = 30 * time.Second
+	}
+	return &KnowledgeSuggestionProvider{
+		store:        store,
+		refreshEvery: refreshEvery,
+		maxTopics:    50,
+		titlesByTag:  make(map[string][]string),
+		logger:       logging.Get().Component("chat"),
+	}
+}
+
+// entryTitle returns the title of a knowledge entry, if it has one
+func entryTitle(entry knowledge.Entry) string {
+	for _, key := range []string{"title", "name", "initiative", "conversation_title"} {
+		if title := strings.TrimSpace(entry.Metadata[key]); title != "" {
+			return title
+		}
+	}
+	return ""
+}
+
+// refresh rebuilds the title and tag index from the store when it is stale
+func (k *KnowledgeSuggestionProvider) refresh() {
+	k.mutex.RLock()
+	fresh := time.Since(k.refreshedAt) < k.refreshEvery
+	k.mutex.RUnlock()
+	if fresh || k.store == nil {
+		return
+	}
+
+	entries, err := k.store.SearchRecords(knowledge.Filter{})
+	if err != nil {
+		k.logger.Warn("Failed to load knowledge for suggestions", "error", err)
+		return
+	}
+
+	titleSet := make(map[string]bool)
+	tagSet := make(map[string]bool)
+	titlesByTag := make(map[string][]string)
+	titles := make([]string, 0)
+	tags := make([]string, 0)
+
+	for _, entry := range entries {
+		title := entryTitle(entry)
+		if title != "" && !titleSet[title] {
+			titleSet[title] = true
+			titles = append(titles, title)
+		}
+		for _, tag := range entry.Tags {
+			tag = strings.TrimSpace(tag)
+			if tag == "" {
+				continue
+			}
+			if !tagSet[tag] {
+				tagSet[tag] = true
+				tags = append(tags, tag)
+			}
+			if title != "" {
+				key := strings.ToLower(tag)
+				titlesByTag[key] = appendUnique(titlesByTag[key], title)
+			}
+		}
+	}
+
+	sort.Strings(titles)
+	sort.Strings(tags)
+	for _, tagged := range titlesByTag {
+		sort.Strings(tagged)
+	}
+
+	k.mutex.Lock()
+	k.titles = titles
+	k.tags = tags
+	k.titlesByTag = titlesByTag
+	k.refreshedAt = time.Now()
+	k.mutex.Unlock()
+}
+
+// RecordTopic records the significant words of a message as recent topics
+func (k *KnowledgeSuggestionProvider) RecordTopic(text string) {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_'
+	})
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	for _, word := range words {
+		// Short words are rarely worth completing
+		if len(word) < 5 {
+			continue
+		}
+		// Move the word to the front, keeping the list free of duplicates
+		for i, topic := range k.topics {
+			if strings.EqualFold(topic, word) {
+				k.topics = append(k.topics[:i], k.topics[i+1:]...)
+				break
+			}
+		}
+		k.topics = append([]string{word}, k.topics...)
+	}
+	if len(k.topics) > k.maxTopics {
+		k.topics = k.topics[:k.maxTopics]
+	}
+}
+
+// Suggest returns suggestions for the prefix: matching titles first, then titles of
+// entries tagged with the prefix, then matching tags and finally recent topics
+func (k *KnowledgeSuggestionProvider) Suggest(prefix string, limit int) []string {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" || limit <= 0 {
+		return nil
+	}
+	k.refresh()
+
+	lower := strings.ToLower(prefix)
+	result := make([]string, 0, limit)
+	add := func(candidate string) bool {
+		if !strings.EqualFold(candidate, prefix) {
+			result = appendUnique(result, candidate)
+		}
+		return len(result) >= limit
+	}
+
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	for _, title := range k.titles {
+		if hasWordPrefix(title, lower) && add(title) {
+			return result
+		}
+	}
+	for _, title := range k.titlesByTag[lower] {
+		if add(title) {
+			return result
+		}
+	}
+	for _, tag := range k.tags {
+		if strings.HasPrefix(strings.ToLower(tag), lower) && add(tag) {
+			return result
+		}
+	}
+	for _, topic := range k.topics {
+		if strings.HasPrefix(strings.ToLower(topic), lower) && add(topic) {
+			return result
+		}
+	}
+	return result
+}
+
+// hasWordPrefix checks if any word of s starts with the lower-cased prefix
+func hasWordPrefix(s, lowerPrefix string) bool {
+	lowerS := strings.ToLower(s)
+	if strings.HasPrefix(lowerS, lowerPrefix) {
+		return true
+	}
+	for _, word := range strings.Fields(lowerS) {
+		if strings.HasPrefix(word, lowerPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendUnique appends value unless it is already present
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// suggestionCompleter adapts a SuggestionProvider to the readline AutoCompleter interface.
+// Readline can only append to the line, so suggestions that extend the current word
+// complete it in place and other suggestions are appended after it.
+type suggestionCompleter struct {
+	provider SuggestionProvider
+	limit    int
+}
+
+// Do returns the completion candidates for the word before the cursor
+func (s *suggestionCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	if pos > len(line) {
+		pos = len(line)
+	}
+	start := pos
+	for start > 0 && !unicode.IsSpace(line[start-1]) {
+		start--
+	}
+	word := string(line[start:pos])
+	if word == "" {
+		return nil, 0
+	}
+
+	wordRunes := []rune(word)
+	candidates := make([][]rune, 0)
+	for _, suggestion := range s.provider.Suggest(word, s.limit) {
+		runes := []rune(suggestion)
+		if len(runes) >= len(wordRunes) && strings.EqualFold(string(runes[:len(wordRunes)]), word) {
+			candidates = append(candidates, runes[len(wordRunes):])
+		} else {
+			candidates = append(candidates, []rune(" "+suggestion))
+		}
+	}
+	return candidates, len(wordRunes)
+}
@@ -0,0 +1,82 @@
+package chat
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"goproduct/internal/knowledge"
+)
+
+func newSuggestionStore(t *testing.T) knowledge.Store {
+	store, _ := knowledge.NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	entries := []knowledge.Entry{
+		{ID: "1", Category: knowledge.CategoryFact, Tags: []string{"roadmap", "initiative"}, Metadata: map[string]string{"title": "Project Phoenix"}},
+		{ID: "2", Category: knowledge.CategoryFact, Tags: []string{"roadmap"}, Metadata: map[string]string{"title": "Mobile Relaunch"}},
+		{ID: "3", Category: knowledge.CategoryDecision, Tags: []string{"pricing"}, Metadata: map[string]string{}},
+	}
+	for _, entry := range entries {
+		if err := store.AddRecord(entry); err != nil {
+			t.Fatalf("Failed to add record: %v", err)
+		}
+	}
+	return store
+}
+
+func TestKnowledgeSuggestionProvider_Suggest(t *testing.T) {
+	store := newSuggestionStore(t)
+	defer store.Close()
+
+	provider := NewKnowledgeSuggestionProvider(store, time.Minute)
+
+	// A tag offers the titles of entries carrying it
+	got := provider.Suggest("roadmap", 10)
+	want := []string{"Mobile Relaunch", "Project Phoenix"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Suggest(roadmap) = %v, want %v", got, want)
+	}
+
+	// Title words and tags match by prefix
+	if got := provider.Suggest("pho", 10); !reflect.DeepEqual(got, []string{"Project Phoenix"}) {
+		t.Errorf("Suggest(pho) = %v", got)
+	}
+	if got := provider.Suggest("pri", 10); !reflect.DeepEqual(got, []string{"pricing"}) {
+		t.Errorf("Suggest(pri) = %v", got)
+	}
+
+	// Recent topics are suggested too
+	provider.RecordTopic("Can we discuss the onboarding funnel?")
+	if got := provider.Suggest("onb", 10); !reflect.DeepEqual(got, []string{"onboarding"}) {
+		t.Errorf("Suggest(onb) = %v", got)
+	}
+
+	if got := provider.Suggest("roadmap", 1); len(got) != 1 {
+		t.Errorf("Expected limit to be respected, got %v", got)
+	}
+}
+
+func TestSuggestionCompleter_Do(t *testing.T) {
+	store := newSuggestionStore(t)
+	defer store.Close()
+
+	completer := &suggestionCompleter{provider: NewKnowledgeSuggestionProvider(store, time.Minute), limit: 10}
+
+	line := []rune("status of pro")
+	candidates, length := completer.Do(line, len(line))
+	if length != 3 {
+		t.Errorf("Expected completion length 3, got %d", length)
+	}
+	if len(candidates) != 1 || string(candidates[0]) != "ject Phoenix" {
+		t.Errorf("Unexpected candidates: %q", candidates)
+	}
+
+	line = []rune("roadmap")
+	candidates, _ = completer.Do(line, len(line))
+	if len(candidates) != 2 || string(candidates[0]) != " Mobile Relaunch" {
+		t.Errorf("Expected tag suggestions to be appended, got %q", candidates)
+	}
+}
@@ -0,0 +1,48 @@
+package chat
+
+import (
+	"fmt"
+
+	"goproduct/internal/entity"
+)
+
+// cancelPending stops waiting for the reply to the most recently sent message and has the
+// agent stop answering it, rather than leaving the user to wait for the timeout. It
+// returns the notice for the user.
+func (c *EnhancedChat) cancelPending() string {
+	c.mutex.Lock()
+	var latest string
+	for id, sentAt := range c.pendingMsgs {
+		if latest == "" || sentAt.After(c.pendingMsgs[latest]) {
+			latest = id
+		}
+	}
+	if latest == "" {
+		c.mutex.Unlock()
+		return "No message is awaiting a reply."
+	}
+	wait := c.msgCancelMap[latest]
+	delete(c.pendingMsgs, latest)
+	delete(c.msgCancelMap, latest)
+	pendingCount := len(c.pendingMsgs)
+	waiting := c.waitingLocked()
+	c.mutex.Unlock()
+
+	// The language model call is cancelled by the agent; the wait here ends with it
+	answering := false
+	if canceller, ok := c.agent.(entity.MessageCanceller); ok && wait.attemptID != "" {
+		answering = canceller.CancelMessage(wait.attemptID)
+	}
+	if wait.cancel != nil {
+		wait.cancel()
+	}
+	c.typing().update(waiting)
+
+	c.logger.Info("Message cancelled", "message_id", latest, "agent_answering", answering, "pending_count", pendingCount)
+	c.tracer.Info("Message %s cancelled", latest)
+	notice := fmt.Sprintf("Cancelled message [%s].", latest[:8])
+	if pendingCount > 0 {
+		notice += fmt.Sprintf(" %d more awaiting a reply.", pendingCount)
+	}
+	return notice
+}
@@ -0,0 +1,69 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/entity"
+	"goproduct/internal/messaging"
+	"goproduct/internal/tracing"
+)
+
+// cancellingAgent never replies and records the messages it is asked to stop answering
+type cancellingAgent struct {
+	*entity.CliHumanEntity
+	cancelled chan string
+}
+
+func (a *cancellingAgent) CancelMessage(messageID string) bool {
+	a.cancelled <- messageID
+	return true
+}
+
+func TestCancelPending(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	agent := &cancellingAgent{CliHumanEntity: entity.NewCliHumanEntity("Andy", bus), cancelled: make(chan string, 2)}
+	c := NewEnhancedChat(entity.NewCliHumanEntity("User", bus), agent, bus, tracing.NewMemoryTracer())
+	out := &syncBuffer{}
+
+	if reply := c.cancelPending(); reply != "No message is awaiting a reply." {
+		t.Errorf("Expected nothing to cancel, got %q", reply)
+	}
+
+	c.processInput("Draft the roadmap", out)
+	time.Sleep(5 * time.Millisecond)
+	c.processInput("And the budget", out)
+	c.mutex.RLock()
+	var latest string
+	for id, sentAt := range c.pendingMsgs {
+		if latest == "" || sentAt.After(c.pendingMsgs[latest]) {
+			latest = id
+		}
+	}
+	c.mutex.RUnlock()
+
+	c.processInput("/cancel", out)
+	if !strings.Contains(out.String(), "Cancelled message ["+latest[:8]+"]. 1 more awaiting a reply.") {
+		t.Errorf("Expected the last message cancelled, got %q", out.String())
+	}
+	if id := <-agent.cancelled; id != latest {
+		t.Errorf("Expected the agent to stop answering %s, got %s", latest, id)
+	}
+
+	c.processInput("/cancel", out)
+	<-agent.cancelled
+	c.mutex.RLock()
+	pending, waits := len(c.pendingMsgs), len(c.msgCancelMap)
+	c.mutex.RUnlock()
+	if pending != 0 || waits != 0 {
+		t.Errorf("Expected no pending messages left, got %d and %d waits", pending, waits)
+	}
+
+	// The cancelled waits end without an out of office notice
+	time.Sleep(50 * time.Millisecond)
+	if strings.Contains(out.String(), "out of office") {
+		t.Errorf("Expected no notice for cancelled messages, got %q", out.String())
+	}
+	c.cancel()
+}
@@ -10,20 +10,16 @@ import (
 	"time"
 
 	"goproduct/internal/agent"
+	"goproduct/internal/commands"
+	"goproduct/internal/markdown"
 
 	"github.com/manifoldco/promptui"
 )
 
-// Command represents a special chat command
-type Command struct {
-	Name        string
-	Description string
-	Handler     func() string
-}
-
 // Chat represents the chat interface
 type Chat struct {
-	commands    map[string]Command
+	commands    *commands.Registry
+	markdown    *markdown.Renderer // Formats replies when the output is a terminal
 	prompt      *promptui.Prompt
 	agent       *agent.Agent
 	ctx         context.Context
@@ -36,7 +32,8 @@ type Chat struct {
 func NewChat(agent *agent.Agent) *Chat {
 	ctx, cancel := context.WithCancel(context.Background())
 	chat := &Chat{
-		commands:    make(map[string]Command),
+		commands:    commands.NewRegistry(),
+		markdown:    markdown.New(markdown.WithColor(markdown.IsTerminal(os.Stdout))),
 		agent:       agent,
 		ctx:         ctx,
 		cancel:      cancel,
@@ -64,52 +61,27 @@ func NewChat(agent *agent.Agent) *Chat {
 
 // registerCommands registers the default commands
 func (c *Chat) registerCommands() {
-	c.commands["help()"] = Command{
-		Name:        "help()",
-		Description: "Show available commands",
-		Handler: func() string {
-			var sb strings.Builder
-			sb.WriteString("Available commands:\n")
-			for _, cmd := range c.commands {
-				sb.WriteString(fmt.Sprintf("  %s - %s\n", cmd.Name, cmd.Description))
-			}
-			return sb.String()
-		},
-	}
-
-	c.commands["exit()"] = Command{
-		Name:        "exit()",
-		Description: "Exit the application",
-		Handler: func() string {
-			// Clean up resources before exit
-			c.cancel()
-			c.agent.Stop()
-			fmt.Println("Goodbye!")
-			os.Exit(0)
-			return ""
-		},
-	}
-
-	c.commands["quit()"] = Command{
-		Name:        "quit()",
+	c.commands.Register(commands.Command{
+		Name:        "exit",
+		Aliases:     []string{"quit"},
 		Description: "Exit the application",
-		Handler: func() string {
+		Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
 			// Clean up resources before exit
 			c.cancel()
 			c.agent.Stop()
 			fmt.Println("Goodbye!")
 			os.Exit(0)
-			return ""
+			return "", nil
 		},
-	}
+	})
 
-	c.commands["now()"] = Command{
-		Name:        "now()",
+	c.commands.Register(commands.Command{
+		Name:        "now",
 		Description: "Show current date and time",
-		Handler: func() string {
-			return fmt.Sprintf("Current time: %s", time.Now().Format("Monday, January 2, 2006 at 3:04:05 PM MST"))
+		Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+			return fmt.Sprintf("Current time: %s", time.Now().Format("Monday, January 2, 2006 at 3:04:05 PM MST")), nil
 		},
-	}
+	})
 }
 
 // displayPendingMessages shows status of pending messages
@@ -131,7 +103,7 @@ func (c *Chat) processResponse(msg agent.Message) {
 			return
 		case response := <-msg.ResponseReady:
 			// Print the response immediately
-			fmt.Printf("Agent [%s]: %s\n\n", msgId[:8], response.Content)
+			fmt.Printf("Agent [%s]: %s\n\n", msgId[:8], c.markdown.Render(response.Content))
 
 			// Mark message as done
 			delete(c.pendingMsgs, msgId)
@@ -164,7 +136,7 @@ func (c *Chat) Start() {
 	}()
 
 	fmt.Println("Welcome to the Chat Interface!")
-	fmt.Println("Type help() for available commands")
+	fmt.Println("Type /help for available commands")
 	fmt.Println("Press Ctrl+C to exit")
 	fmt.Println()
 
@@ -199,8 +171,11 @@ func (c *Chat) Start() {
 
 		// Check if input is a command
 		trimmedInput := strings.TrimSpace(result)
-		if command, exists := c.commands[trimmedInput]; exists {
-			response := command.Handler()
+		if inv, isCommand := c.commands.Parse(trimmedInput); isCommand {
+			response, err := c.commands.Execute(c.ctx, inv)
+			if err != nil {
+				response = err.Error()
+			}
 			if response != "" {
 				fmt.Println(response)
 			}
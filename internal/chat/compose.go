@@ -0,0 +1,187 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"goproduct/internal/entity"
+	"goproduct/internal/messaging"
+)
+
+// composeTimeout bounds how long the agent may take to draft a message
+const composeTimeout = 60 * time.Second
+
+// composeDraft is a message drafted by the agent awaiting the user's confirmation
+type composeDraft struct {
+	recipient   entity.Entity
+	instruction string
+	content     string
+}
+
+// RegisterContact makes an entity addressable by name with "tell <name> ..." requests
+func (c *EnhancedChat) RegisterContact(contact entity.Entity) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.contacts[strings.ToLower(contact.Name())] = contact
+	c.logger.Debug("Contact registered", "name", contact.Name(), "entity_id", contact.ID())
+}
+
+// contactList returns the names of the registered contacts in sorted order
+func (c *EnhancedChat) contactList() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	names := make([]string, 0, len(c.contacts))
+	for _, contact := range c.contacts {
+		names = append(names, contact.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseComposeRequest extracts the contact and instruction from input such as
+// "tell the design agent we're slipping the date". The longest matching contact name wins.
+func (c *EnhancedChat) parseComposeRequest(input string) (entity.Entity, string, bool) {
+	lower := strings.ToLower(input)
+	if !strings.HasPrefix(lower, "tell ") {
+		return nil, "", false
+	}
+	rest := strings.TrimSpace(input[len("tell "):])
+	restLower := strings.ToLower(rest)
+	if strings.HasPrefix(restLower, "the ") {
+		rest = strings.TrimSpace(rest[len("the "):])
+		restLower = strings.ToLower(rest)
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var match entity.Entity
+	matchLen := 0
+	for name, contact := range c.contacts {
+		if len(name) <= matchLen || !strings.HasPrefix(restLower, name) {
+			continue
+		}
+		// Require a word boundary after the name
+		if len(restLower) > len(name) && restLower[len(name)] != ' ' && restLower[len(name)] != ',' && restLower[len(name)] != ':' {
+			continue
+		}
+		match = contact
+		matchLen = len(name)
+	}
+	if match == nil {
+		return nil, "", false
+	}
+
+	instruction := strings.TrimLeft(rest[matchLen:], " ,:")
+	if strings.HasPrefix(strings.ToLower(instruction), "that ") {
+		instruction = instruction[len("that "):]
+	}
+	return match, strings.TrimSpace(instruction), true
+}
+
+// handleCompose handles "tell <contact> ..." requests and draft confirmations.
+// Returns true if the input was consumed.
+func (c *EnhancedChat) handleCompose(input string, out io.Writer) bool {
+	c.mutex.Lock()
+	draft := c.pendingDraft
+	c.mutex.Unlock()
+
+	if draft != nil {
+		// Commands such as /exit keep working while a draft is pending, whatever their
+		// arguments; only other input answers the draft
+		if c.commands.IsCommand(input) {
+			return false
+		}
+		c.confirmDraft(draft, input, out)
+		return true
+	}
+
+	recipient, instruction, ok := c.parseComposeRequest(input)
+	if !ok {
+		return false
+	}
+
+	drafter, ok := c.agent.(entity.MessageDrafter)
+	if !ok {
+		fmt.Fprintf(out, "%s can't draft messages.\n\n", c.agent.Name())
+		return true
+	}
+	if instruction == "" {
+		fmt.Fprintf(out, "What should I tell %s?\n\n", recipient.Name())
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, composeTimeout)
+	defer cancel()
+
+	c.logger.Info("Drafting message on behalf of user",
+		"sender_id", c.human.ID(),
+		"recipient_id", recipient.ID(),
+		"recipient_name", recipient.Name())
+
+	content, err := drafter.DraftMessage(ctx, c.human.Name(), recipient.Name(), instruction)
+	if err != nil {
+		c.logger.Error("Failed to draft message", "error", err, "recipient_id", recipient.ID())
+		c.tracer.Error("Failed to draft message for %s: %v", recipient.Name(), err)
+		fmt.Fprintf(out, "%s: Sorry, I couldn't draft that message: %v\n\n", c.agent.Name(), err)
+		return true
+	}
+
+	c.mutex.Lock()
+	c.pendingDraft = &composeDraft{recipient: recipient, instruction: instruction, content: content}
+	c.mutex.Unlock()
+
+	fmt.Fprintf(out, "%s: Here's a draft to %s:\n\n%s\n\nSend it? (yes/no)\n", c.agent.Name(), recipient.Name(), content)
+	return true
+}
+
+// confirmDraft sends or discards the pending draft based on the user's answer
+func (c *EnhancedChat) confirmDraft(draft *composeDraft, answer string, out io.Writer) {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "yes", "y", "send":
+		c.mutex.Lock()
+		c.pendingDraft = nil
+		c.mutex.Unlock()
+
+		msg := messaging.NewTextMessage(c.human.ID(), []string{draft.recipient.ID()}, draft.content).WithCorrelation()
+		msg.Metadata["composed_by"] = c.agent.ID()
+		msg.Metadata["composed_by_name"] = c.agent.Name()
+		msg.Metadata["on_behalf_of"] = c.human.ID()
+		msg.Metadata["on_behalf_of_name"] = c.human.Name()
+
+		if err := c.messageBus.Publish(msg); err != nil {
+			c.logger.Error("Failed to send composed message", "error", err, "recipient_id", draft.recipient.ID())
+			c.tracer.Error("Failed to send composed message to %s: %v", draft.recipient.Name(), err)
+			fmt.Fprintf(out, "Failed to send message: %v\n\n", err)
+			return
+		}
+
+		// Audit trail: who asked, who drafted, who received and what was sent
+		c.logger.Info("Composed message sent on behalf of user",
+			"message_id", msg.ID,
+			"sender_id", c.human.ID(),
+			"composed_by", c.agent.ID(),
+			"recipient_id", draft.recipient.ID(),
+			"instruction", draft.instruction,
+			"content_length", len(msg.Content))
+		c.tracer.Info("Composed message %s sent from %s to %s (drafted by %s)",
+			msg.ID, c.human.Name(), draft.recipient.Name(), c.agent.Name())
+		fmt.Fprintf(out, "Message sent to %s [%s]\n\n", draft.recipient.Name(), msg.ID[:8])
+
+	case "no", "n", "cancel":
+		c.mutex.Lock()
+		c.pendingDraft = nil
+		c.mutex.Unlock()
+
+		c.logger.Info("Composed message discarded", "recipient_id", draft.recipient.ID(), "instruction", draft.instruction)
+		fmt.Fprintln(out, "Draft discarded.")
+		fmt.Fprintln(out)
+
+	default:
+		fmt.Fprintf(out, "Please answer yes to send the draft to %s or no to discard it.\n", draft.recipient.Name())
+	}
+}
@@ -3,25 +3,34 @@ package chat
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"github.com/chzyer/readline"
+	"github.com/google/uuid"
 	"github.com/manifoldco/promptui"
 	"io"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"goproduct/internal/commands"
 	"goproduct/internal/entity"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
 	"goproduct/internal/logging"
+	"goproduct/internal/markdown"
 	"goproduct/internal/messaging"
+	"goproduct/internal/presence"
 	"goproduct/internal/tracing"
 )
 
 // EnhancedChat represents a chat interface that uses the messaging system
 type EnhancedChat struct {
-	commands     map[string]Command
+	commands     *commands.Registry
 	human        *entity.CliHumanEntity
 	agent        entity.Entity
 	messageBus   messaging.MessageBus
@@ -30,37 +39,116 @@ type EnhancedChat struct {
 	prompt       *promptui.Prompt
 	ctx          context.Context
 	cancel       context.CancelFunc
-	pendingMsgs  map[string]bool
+	pendingMsgs  map[string]time.Time    // Messages awaiting a reply by ID, with when they were sent
+	msgCancelMap map[string]pendingReply // Waits for the replies to pending messages by message ID
+	timeout      time.Duration           // How long to wait for a reply, 0 for the default
+	retries      int                     // Times a message is sent again when not answered in time, negative for the default
+	fallback     string                  // Printed in place of a reply that never came
 	responses    chan struct{}
-	msgCancelMap map[string]chan struct{} // Map of message ID to cancellation channels
-	mutex        sync.RWMutex             // Protect pendingMsgs and msgCancelMap maps
+	contacts     map[string]entity.Entity // Entities addressable by lower-cased name for compose requests
+	pendingDraft *composeDraft            // Drafted message awaiting confirmation
+	suggestions  SuggestionProvider       // Optional autocomplete suggestions for interactive input
+	store        knowledge.Store          // Optional store receiving conversation mode summaries
+	activeMode   *modeSession             // Running conversation mode, if any
+	usage        *llm.UsageLedger         // Optional ledger reported by usage()
+	out          io.Writer                // Output of the running chat, for asynchronous notices
+	markdown     *markdown.Renderer       // Formats replies when the output is a terminal
+	indicator    *typingIndicator         // Shows that the agent is thinking; nil when the output is not a terminal
+	streaming    int                      // Replies being printed as the agent writes them, which hide the indicator
+	session      string                   // Conversation ID of the chat transcript
+	turns        int                      // Messages in the transcript of the session
+	sessionLog   []sessionMessage         // Messages of the session, written by /export
+	threads      []*chatThread            // Open threads, the main thread first
+	thread       *chatThread              // Thread new messages go to
+	threadCount  int                      // Threads started, numbering them
+	presence     *presence.Registry       // Optional presence of the agent, warned about before sending
+	health       healthCheck              // Optional health of the application, shown by /health
+	resume       bool                     // Continue the last session on start
+	shutdown     func()                   // Stops the application when the user quits, nil to close the tracer only
+	mutex        sync.RWMutex             // Protect pendingMsgs, msgCancelMap, contacts, pendingDraft, activeMode, out, markdown, indicator, streaming, session, turns, sessionLog, threads, presence and health
 	IsTestMode   bool                     // Explicitly tracks if running in test mode
 }
 
+// Defaults of the wait for the agent's replies
+const (
+	DefaultReplyTimeout  = 60 * time.Second
+	DefaultReplyRetries  = 1
+	DefaultFallbackReply = "I'm out of office today. If you need immediate assistance, please contact Tom Reynolds."
+)
+
+// EnhancedChatOption configures an EnhancedChat
+type EnhancedChatOption func(*EnhancedChat)
+
+// WithReplyTimeout sets how long to wait for the agent's reply to a message before sending
+// it again or giving up
+func WithReplyTimeout(timeout time.Duration) EnhancedChatOption {
+	return func(c *EnhancedChat) {
+		c.timeout = timeout
+	}
+}
+
+// WithReplyRetries sets how many times a message is sent again when the agent does not
+// reply in time; 0 gives up after the first wait
+func WithReplyRetries(retries int) EnhancedChatOption {
+	return func(c *EnhancedChat) {
+		c.retries = retries
+	}
+}
+
+// WithShutdown sets what stops the application before the process exits on Ctrl+C, in
+// place of closing the chat's tracer; it is then also left to close the tracer when the
+// user quits with /exit
+func WithShutdown(shutdown func()) EnhancedChatOption {
+	return func(c *EnhancedChat) {
+		c.shutdown = shutdown
+	}
+}
+
+// WithFallbackReply sets the text shown as the agent's reply when it never replied
+func WithFallbackReply(text string) EnhancedChatOption {
+	return func(c *EnhancedChat) {
+		c.fallback = text
+	}
+}
+
 // NewEnhancedChat creates a new enhanced chat interface
 func NewEnhancedChat(
 	human *entity.CliHumanEntity,
 	agent entity.Entity,
 	bus messaging.MessageBus,
 	tracer *tracing.EnhancedTracer,
+	opts ...EnhancedChatOption,
 ) *EnhancedChat {
 	ctx, cancel := context.WithCancel(context.Background())
+	mainThread := &chatThread{Number: 1, Topic: "main", Started: time.Now()}
 
 	chat := &EnhancedChat{
-		commands:     make(map[string]Command),
+		commands:     commands.NewRegistry(),
 		human:        human,
 		agent:        agent,
 		messageBus:   bus,
 		tracer:       tracer,
-		logger:       logging.Get(), // Use the application logger
+		logger:       logging.Get().Component("chat"), // Use the application logger
 		ctx:          ctx,
 		cancel:       cancel,
-		pendingMsgs:  make(map[string]bool),
+		pendingMsgs:  make(map[string]time.Time),
+		msgCancelMap: make(map[string]pendingReply),
+		threads:      []*chatThread{mainThread},
+		thread:       mainThread,
+		threadCount:  1,
+		retries:      -1,
+		fallback:     DefaultFallbackReply,
 		responses:    make(chan struct{}, 10),
-		msgCancelMap: make(map[string]chan struct{}),
+		contacts:     make(map[string]entity.Entity),
+		session:      uuid.New().String(),
 		IsTestMode:   false, // Default to production mode
 	}
 
+	// Apply options
+	for _, opt := range opts {
+		opt(chat)
+	}
+
 	// Register default commands
 	chat.registerCommands()
 
@@ -69,50 +157,140 @@ func NewEnhancedChat(
 
 // registerCommands registers the default commands
 func (c *EnhancedChat) registerCommands() {
-	c.commands["help()"] = Command{
-		Name:        "help()",
-		Description: "Show available commands",
-		Handler: func() string {
-			var sb strings.Builder
-			sb.WriteString("Available commands:\n")
-			for _, cmd := range c.commands {
-				sb.WriteString(fmt.Sprintf("  %s - %s\n", cmd.Name, cmd.Description))
-			}
-			return sb.String()
+	defaults := []commands.Command{
+		{
+			Name:        "exit",
+			Aliases:     []string{"quit"},
+			Description: "Exit the application",
+			Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+				// Clean up resources before exit
+				c.cancel()
+				if c.shutdown == nil {
+					c.tracer.Close()
+				}
+				return "Goodbye!", commands.ErrQuit
+			},
 		},
-	}
-
-	c.commands["exit()"] = Command{
-		Name:        "exit()",
-		Description: "Exit the application",
-		Handler: func() string {
-			// Clean up resources before exit
-			c.cancel()
-			c.tracer.Close()
-			return "Goodbye!"
+		{
+			Name:        "compose",
+			Description: "Explain how to have the agent draft a message for you",
+			Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+				contacts := c.contactList()
+				if len(contacts) == 0 {
+					return "No contacts are available to message.", nil
+				}
+				return fmt.Sprintf("Type \"tell <contact> <what to say>\" and I'll draft the message for your approval.\nContacts: %s", strings.Join(contacts, ", ")), nil
+			},
 		},
-	}
-
-	c.commands["quit()"] = Command{
-		Name:        "quit()",
-		Description: "Exit the application",
-		Handler: func() string {
-			// Clean up resources before exit
-			c.cancel()
-			c.tracer.Close()
-			return "Goodbye!"
+		{
+			Name:        "selfcheck",
+			Description: "Quiz the agent on its most important knowledge and report discrepancies",
+			Handler:     reply(c.selfCheck),
+		},
+		{
+			Name:        "language",
+			Usage:       "[code]",
+			Description: "Show the agent's language, or switch it",
+			Help:        "e.g. /language es, or /language pt-BR",
+			MaxArgs:     1,
+			Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+				if len(inv.Args) == 0 {
+					return c.language(""), nil
+				}
+				return c.language(inv.Args[0]), nil
+			},
+		},
+		{
+			Name:        "image",
+			Usage:       "<path> [question]",
+			Description: "Show the agent an image file, such as a screenshot, with a question about it",
+			Help:        "e.g. /image mockup.png Is this layout clear?\nWithout a question the agent is asked what it makes of the image.",
+			MinArgs:     1,
+			MaxArgs:     -1,
+			Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+				c.sendImage(inv.Args[0], inv.TextAfter(1), inv.Out)
+				return "", nil
+			},
+		},
+		{
+			Name:        "edit",
+			Description: "Write a message in your editor ($VISUAL or $EDITOR) and send it",
+			Help:        `Ctrl+E opens the line you are typing in the editor; for short pastes, wrap the lines in """ instead.`,
+			Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+				// The editor needs a terminal
+				if c.IsTestMode {
+					return "The editor is not available.", nil
+				}
+				c.composeInEditor("", inv.Out)
+				return "", nil
+			},
 		},
+		{
+			Name:        "cancel",
+			Description: "Stop waiting for the reply to your last message",
+			Help:        "The agent stops answering it too. Pressing Esc twice does the same.",
+			Handler:     reply(c.cancelPending),
+		},
+		{
+			Name:        "history",
+			Description: "Show your most recent messages, to pick up a conversation after a restart",
+			Handler:     reply(c.history),
+		},
+		c.exportCommand(),
+		c.threadCommand(),
+		{
+			Name:        "presence",
+			Description: "Show which agents are online, busy or offline",
+			Handler:     reply(c.presenceReport),
+		},
+		{
+			Name:        "health",
+			Description: "Show whether the language model, the knowledge store, the message bus and the other components work",
+			Handler:     reply(c.healthReport),
+		},
+		{
+			Name:        "usage",
+			Description: "Show the tokens and cost of language model calls by model, agent and conversation",
+			Handler:     reply(c.usageReport),
+		},
+		{
+			Name:        "now",
+			Description: "Show current date and time",
+			Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+				return fmt.Sprintf("Current time: %s", time.Now().Format("Monday, January 2, 2006 at 3:04:05 PM MST")), nil
+			},
+		},
+	}
+	defaults = append(defaults, c.knowledgeCommands()...)
+	defaults = append(defaults, c.levelCommands()...)
+	for _, command := range defaults {
+		if err := c.RegisterCommand(command); err != nil {
+			c.logger.Error("Failed to register command", "command", command.Name, "error", err)
+		}
 	}
 
-	c.commands["now()"] = Command{
-		Name:        "now()",
-		Description: "Show current date and time",
-		Handler: func() string {
-			return fmt.Sprintf("Current time: %s", time.Now().Format("Monday, January 2, 2006 at 3:04:05 PM MST"))
-		},
+	c.RegisterMode(StandupMode())
+	c.RegisterMode(TriageMode())
+}
+
+// RegisterCommand adds a command to the chat, replacing any command with the same name,
+// so that other modules can contribute their own
+func (c *EnhancedChat) RegisterCommand(command commands.Command) error {
+	return c.commands.Register(command)
+}
+
+// reply adapts a command without arguments that cannot fail to a command handler
+func reply(handler func() string) commands.Handler {
+	return func(ctx context.Context, inv commands.Invocation) (string, error) {
+		return handler(), nil
 	}
 }
 
+// SetSuggestionProvider enables tab autocomplete in interactive mode using the given provider
+func (c *EnhancedChat) SetSuggestionProvider(provider SuggestionProvider) {
+	c.suggestions = provider
+}
+
 // displayPendingMessages shows the count of pending messages
 func (c *EnhancedChat) displayPendingMessages() {
 	c.mutex.RLock()
@@ -125,7 +303,10 @@ func (c *EnhancedChat) displayPendingMessages() {
 
 	if pendingCount > 0 {
 		c.logger.Debug("Pending messages check", "count", pendingCount, "message_ids", strings.Join(pendingIDs, ","))
-		fmt.Printf("Pending messages: %d\n", pendingCount)
+		// The typing indicator already shows the count
+		if c.typing() == nil {
+			fmt.Printf("Pending messages: %d\n", pendingCount)
+		}
 	}
 }
 
@@ -160,8 +341,18 @@ func (c *EnhancedChat) StartWithIO(in io.Reader, out io.Writer) error {
 	}
 	c.prompt = prompt
 
-	// Start the human entity
+	c.mutex.Lock()
+	c.out = out
+	// Replies are formatted for terminals only, so that tests and piped output get the text as it is
+	c.markdown = markdown.New(markdown.WithColor(!c.IsTestMode && markdown.IsTerminal(out)))
+	if !c.IsTestMode && markdown.IsTerminal(out) {
+		c.indicator = newTypingIndicator(c.agent.Name(), lineStatus(out))
+	}
+	c.mutex.Unlock()
+
+	// Start the human entity; besides replies it receives the results of scheduled tasks
 	c.logger.Info("Enhanced chat interface starting")
+	c.human.SetGeneralMessageHandler(c.receive)
 	if err := c.human.Start(); err != nil {
 		c.logger.Error("Failed to start human entity", "error", err)
 		c.tracer.Error("Failed to start human entity: %v", err)
@@ -177,19 +368,28 @@ func (c *EnhancedChat) StartWithIO(in io.Reader, out io.Writer) error {
 		go func() {
 			<-sigChan
 			fmt.Fprintln(out, "\nGoodbye!")
-			// Clean up resources before exit
+			// Clean up resources before exit; deferred calls do not run after os.Exit
 			c.cancel()
-			c.tracer.Close()
+			if c.shutdown != nil {
+				c.shutdown()
+			} else {
+				c.tracer.Close()
+			}
 			os.Exit(0)
 		}()
 	}
 
 	c.tracer.Info("Enhanced Chat Interface started")
 	fmt.Fprintln(out, "Welcome to the Enhanced Chat Interface!")
-	fmt.Fprintln(out, "Type help() for available commands")
+	fmt.Fprintln(out, "Type /help for available commands")
+	fmt.Fprintln(out, `Wrap multi-line messages in """, or press Ctrl+E to write one in your editor`)
 	fmt.Fprintln(out, "Press Ctrl+C to exit")
 	fmt.Fprintln(out)
 
+	if c.resume {
+		c.resumeSession(out)
+	}
+
 	// Display pending messages status periodically
 	go func() {
 		ticker := time.NewTicker(5 * time.Second)
@@ -220,16 +420,23 @@ func (c *EnhancedChat) StartWithIO(in io.Reader, out io.Writer) error {
 	// Check if it's a test with pre-supplied input or regular interactive mode
 	if in != os.Stdin {
 		// Test mode with pre-supplied inputs using scanner
+		var multiline multilineInput
 		for scanner.Scan() {
-			result := scanner.Text()
+			line := scanner.Text()
 
 			// Display user input
-			fmt.Fprintf(out, "User: %s\n", result)
+			fmt.Fprintf(out, "User: %s\n", line)
+
+			// Lines of a fenced message are sent together
+			result, complete := multiline.add(line)
+			if !complete {
+				continue
+			}
 
 			// Process command or message
 			continueRunning := c.processInput(result, out)
 
-			// Exit if command handler returned false (e.g. exit() was called)
+			// Exit if command handler returned false (e.g. /exit was run)
 			if !continueRunning {
 				return nil
 			}
@@ -239,8 +446,91 @@ func (c *EnhancedChat) StartWithIO(in io.Reader, out io.Writer) error {
 			return err
 		}
 		return nil
+	} else if c.suggestions != nil {
+		// Interactive mode using readline directly, which supports tab autocomplete.
+		// Ctrl+E ends the line and opens it in the user's editor; Esc twice cancels the
+		// wait for the last reply.
+		prompt := c.human.Name() + ": "
+		var editRequested atomic.Bool
+		var currentPrompt atomic.Value
+		currentPrompt.Store(prompt)
+		var rl *readline.Instance
+		rl, err := readline.NewEx(&readline.Config{
+			Prompt:       prompt,
+			AutoComplete: &suggestionCompleter{provider: c.suggestions, limit: 10},
+			Stdin:        nopCloser{in},
+			Stdout:       out,
+			FuncFilterInputRune: func(r rune) (rune, bool) {
+				switch r {
+				case readline.CharLineEnd:
+					editRequested.Store(true)
+					return readline.CharEnter, true
+				case readline.CharEsc:
+					// Esc reaches here when pressed twice, the first one starting a key sequence
+					fmt.Fprintln(rl.Stdout(), c.cancelPending())
+					return r, false
+				}
+				return r, true
+			},
+		})
+		if err != nil {
+			c.logger.Error("Failed to create readline prompt", "error", err)
+			c.tracer.Error("Failed to create readline prompt: %v", err)
+			return err
+		}
+		defer rl.Close()
+
+		// The typing indicator is drawn in the prompt, so that it does not overwrite what the
+		// user is typing
+		setPrompt := func(p string) {
+			currentPrompt.Store(p)
+			rl.SetPrompt(p)
+		}
+		if c.typing() != nil {
+			c.setTypingIndicator(newTypingIndicator(c.agent.Name(), func(status string) {
+				p := currentPrompt.Load().(string)
+				if status != "" {
+					p = status + "  " + p
+				}
+				rl.SetPrompt(p)
+				rl.Refresh()
+			}))
+		}
+
+		var multiline multilineInput
+		for {
+			c.logger.Debug("Waiting for user input")
+			result, err := rl.Readline()
+			if err != nil {
+				if err == readline.ErrInterrupt || err == io.EOF {
+					return nil
+				}
+				c.logger.Error("Prompt failed", "error", err)
+				c.tracer.Error("Prompt failed: %v", err)
+				fmt.Fprintf(out, "Prompt failed: %v\n", err)
+				return err
+			}
+
+			if editRequested.Swap(false) {
+				c.composeInEditor(multiline.flush(result), out)
+				setPrompt(prompt)
+				continue
+			}
+			input, complete := multiline.add(result)
+			if !complete {
+				setPrompt(continuationPrompt)
+				continue
+			}
+			setPrompt(prompt)
+
+			c.logger.Debug("User input received", "content_length", len(input))
+			if !c.processInput(input, out) {
+				return nil
+			}
+		}
 	} else {
 		// Interactive mode using promptui
+		var multiline multilineInput
 		for {
 			// Get input using promptui
 			c.logger.Debug("Waiting for user input")
@@ -253,8 +543,15 @@ func (c *EnhancedChat) StartWithIO(in io.Reader, out io.Writer) error {
 				return err
 			}
 
-			c.logger.Debug("User input received", "content_length", len(result))
-			continueRunning := c.processInput(result, out)
+			input, complete := multiline.add(result)
+			if !complete {
+				c.prompt.Label = continuationPrompt
+				continue
+			}
+			c.prompt.Label = c.human.Name()
+
+			c.logger.Debug("User input received", "content_length", len(input))
+			continueRunning := c.processInput(input, out)
 
 			// If processInput returns false, exit the app
 			if !continueRunning {
@@ -270,199 +567,299 @@ func (c *EnhancedChat) StartWithIO(in io.Reader, out io.Writer) error {
 func (c *EnhancedChat) processInput(result string, out io.Writer) bool {
 	// Check if input is a command
 	trimmedInput := strings.TrimSpace(result)
-	if command, exists := c.commands[trimmedInput]; exists {
-		c.logger.Info("Command executed", "command", trimmedInput)
-		c.tracer.Info("Command executed: %s", trimmedInput)
-		response := command.Handler()
-		if response != "" {
-			fmt.Fprintln(out, response)
-		}
 
-		// Special handling for exit/quit commands
-		if trimmedInput == "exit()" || trimmedInput == "quit()" {
-			return false // Signal to exit the app
-		}
+	// A running conversation mode takes the answers to its questions
+	if c.handleMode(trimmedInput, out) {
+		return true
+	}
 
-		// For other commands, continue processing
+	// Drafting on the user's behalf takes precedence while a draft awaits confirmation
+	if c.handleCompose(trimmedInput, out) {
 		return true
-	} else if trimmedInput != "" {
+	}
+
+	if inv, isCommand := c.commands.Parse(trimmedInput); isCommand {
+		return c.runCommand(inv, out)
+	}
+
+	if trimmedInput != "" {
 		c.logger.Info("Processing user message", "content_length", len(trimmedInput))
-		// Create a message using the messaging system
-		c.logger.Debug("Preparing to send message", "recipient", c.agent.ID(), "recipient_name", c.agent.Name())
-		msg, err := c.human.SendMessage(
-			[]string{c.agent.ID()},
-			messaging.ContentTypeText,
-			[]byte(trimmedInput),
-		)
+		msg := messaging.NewMessage(c.human.ID(), []string{c.agent.ID()}, messaging.ContentTypeText, []byte(trimmedInput))
+		c.send(msg, trimmedInput, out)
+	}
 
-		if err != nil {
-			c.logger.Error("Failed to send message", "error", err)
-			c.tracer.Error("Failed to send message: %v", err)
-			fmt.Fprintf(out, "Failed to send message: %v\n", err)
-			return true // Continue processing despite message error
-		}
+	return true
+}
 
-		c.logger.Info("User message sent to agent", "message_id", msg.ID, "recipient", c.agent.ID(), "recipient_name", c.agent.Name())
+// runCommand runs a command and prints its reply or error.
+// Returns false if the command ends the chat.
+func (c *EnhancedChat) runCommand(inv commands.Invocation, out io.Writer) bool {
+	c.logger.Info("Command executed", "command", inv.Name, "args", strings.Join(inv.Args, " "))
+	c.tracer.Info("Command executed: %s%s", commands.Prefix, inv.Name)
+	inv.Out = out
+	response, err := c.commands.Execute(c.ctx, inv)
+	if response != "" {
+		fmt.Fprintln(out, response)
+	}
+	if errors.Is(err, commands.ErrQuit) {
+		return false
+	}
+	if err != nil {
+		// e.g. "Usage: /image <path> [question]"
+		c.logger.Warn("Command failed", "command", inv.Name, "error", err)
+		fmt.Fprintln(out, capitalize(err.Error()))
+	}
+	return true
+}
 
-		c.tracer.Debug("Message sent: %s", msg.ID)
+// send sends a user message with the given text to the agent and prints its reply once it arrives
+func (c *EnhancedChat) send(msg messaging.Message, text string, out io.Writer) {
+	// The message is sent as a request so the agent's reply comes back to this chat; all
+	// that comes of it shares its correlation ID
+	msg = msg.WithCorrelation()
+	c.logger.Debug("Preparing to send message", "recipient", c.agent.ID(), "recipient_name", c.agent.Name(),
+		"correlation_id", msg.Correlation())
+
+	// Let the suggestion provider learn the topics of the conversation
+	if recorder, ok := c.suggestions.(TopicRecorder); ok {
+		recorder.RecordTopic(text)
+	}
 
-		// Register a handler for the response
-		c.logger.Debug("Registering response handler", "message_id", msg.ID)
-		c.human.RegisterMessageHandler(msg.ID, func(response messaging.Message) {
-			// Check if this is a response to our original message
-			originalMsgID := msg.ID
-			if respOrigID, exists := response.Metadata["original_id"]; exists && respOrigID != "" {
-				c.logger.Debug("Response references original message", "original_id", respOrigID, "response_id", response.ID)
-				originalMsgID = respOrigID
-			}
+	c.threadMessage(msg)
+	notice := c.presenceNotice()
+	ctx, cancel := context.WithTimeout(c.ctx, c.replyTimeout())
+	c.recordMessage(sessionMessage{ID: msg.ID, Speaker: c.human.Name(), Role: "user", Text: text, Timestamp: msg.Timestamp})
+	c.mutex.Lock()
+	c.pendingMsgs[msg.ID] = time.Now()
+	c.msgCancelMap[msg.ID] = pendingReply{cancel: cancel, attemptID: msg.ID}
+	waiting := c.waitingLocked()
+	c.mutex.Unlock()
+	c.logger.Debug("Message added to pending queue", "message_id", msg.ID)
+	c.recordTranscript(c.human.ID(), "user", text)
+
+	// Show the message ID so user can track it
+	c.typing().clear()
+	if notice != "" {
+		fmt.Fprintln(out, notice)
+	}
+	fmt.Fprintf(out, "Message sent [%s]\n", msg.ID[:8])
+	c.typing().update(waiting)
 
-			// Check if message is still pending or was already handled by timeout
-			c.mutex.Lock()
-			_, stillPending := c.pendingMsgs[msg.ID]
-			c.mutex.Unlock()
+	go c.awaitResponse(ctx, msg, out)
+}
 
-			if !stillPending {
-				c.logger.Warn("Received LLM response for already handled message",
-					"message_id", msg.ID,
-					"response_id", response.ID)
-				return // Don't process this message further, but no return value expected
-			}
+// pendingReply is the wait for the reply to a message
+type pendingReply struct {
+	cancel    context.CancelFunc // Stops waiting
+	attemptID string             // ID the message was last sent with, which the agent answers
+}
 
-			// Print the response
-			c.logger.Info("Agent response received",
-				"original_message_id", originalMsgID,
-				"response_id", response.ID,
-				"sender", response.SenderID,
-				"content_length", len(response.Content))
-			c.tracer.Debug("Response received for message %s, response ID: %s", originalMsgID, response.ID)
-			c.logger.Debug("Displaying response to user", "message_id", originalMsgID, "sender_name", c.agent.Name())
-			fmt.Fprintf(out, "%s: %s\n\n", c.agent.Name(), string(response.Content))
-
-			// Mark message as done
-			c.mutex.Lock()
-			// Cancel any pending timeout handlers for this message
-			if cancelCh, exists := c.msgCancelMap[msg.ID]; exists {
-				c.logger.Debug("Cancelling timeout handler", "message_id", msg.ID)
-				close(cancelCh) // Signal cancellation to timeout handler
-				delete(c.msgCancelMap, msg.ID)
-			}
-			delete(c.pendingMsgs, msg.ID)
-			c.logger.Info("Message conversation complete", "message_id", msg.ID, "pending_count", len(c.pendingMsgs))
-			c.mutex.Unlock()
-			c.logger.Debug("Message marked as complete", "message_id", msg.ID)
+// replyTimeout returns how long to wait for the agent's reply to a message
+func (c *EnhancedChat) replyTimeout() time.Duration {
+	switch {
+	case c.timeout > 0:
+		return c.timeout
+	case c.IsTestMode:
+		// Almost immediate timeout for tests
+		return 200 * time.Millisecond
+	}
+	return DefaultReplyTimeout
+}
 
-			// Signal that we got a response
-			select {
-			case c.responses <- struct{}{}:
-				c.logger.Debug("Response notification sent", "message_id", msg.ID)
-			default: // Don't block if channel full
-				c.logger.Debug("Response notification channel full", "message_id", msg.ID)
-			}
-		})
+// replyRetries returns how many times a message is sent again when not answered in time
+func (c *EnhancedChat) replyRetries() int {
+	switch {
+	case c.retries >= 0:
+		return c.retries
+	case c.IsTestMode:
+		// Tests expect the fallback after a single wait
+		return 0
+	}
+	return DefaultReplyRetries
+}
 
-		// Add to pending messages and create a cancellation channel
-		cancelCh := make(chan struct{})
-		c.mutex.Lock()
-		c.pendingMsgs[msg.ID] = true
-		c.msgCancelMap[msg.ID] = cancelCh
-		c.mutex.Unlock()
-		c.logger.Debug("Message added to pending queue with cancellation channel", "message_id", msg.ID)
-
-		// Additional debugging
-		c.logger.Debug("Waiting for response", "message_id", msg.ID, "agent", c.agent.Name())
-
-		// Show the message ID so user can track it
-		fmt.Fprintf(out, "Message sent [%s]\n", msg.ID[:8])
-
-		// Set up a timeout to clear the message if no response received (last resort fallback)
-		go func(msgID string, writer io.Writer, cancelChannel <-chan struct{}) {
-			// Check if we're in test mode - read the explicit flag instead of checking stdin
-			timeoutSeconds := 60
-			if c.IsTestMode {
-				// Almost immediate timeout for tests (200ms)
-				timeoutSeconds = 0
-				// Use a timer instead of sleep to properly handle cancellations
-				timer := time.NewTimer(200 * time.Millisecond)
-				select {
-				case <-cancelChannel:
-					// Response already received - exit immediately
-					timer.Stop()
-					c.logger.Debug("Test timeout handler cancelled - response already received", "message_id", msgID)
-					return
-
-				case <-timer.C:
-					// Timer expired, check if message is still pending
-					c.mutex.Lock()
-					_, stillPending := c.pendingMsgs[msgID]
-					c.mutex.Unlock()
-
-					if stillPending {
-						c.logger.Debug("Test timeout triggered for message", "message_id", msgID)
-						fmt.Fprintf(writer, "%s: I'm out of office today. If you need immediate assistance, please contact Tom Reynolds.\n\n", c.agent.Name())
-
-						c.mutex.Lock()
-						delete(c.pendingMsgs, msgID)
-						delete(c.msgCancelMap, msgID)
-						c.mutex.Unlock()
-
-						c.logger.Debug("Message marked as complete by test timeout handler", "message_id", msgID)
-						return // Exit the goroutine immediately in test mode
-					}
-				}
-			}
+// typing returns the typing indicator, nil if there is none
+func (c *EnhancedChat) typing() *typingIndicator {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.indicator
+}
 
-			timeoutAt := time.Now().Add(time.Duration(timeoutSeconds) * time.Second)
-			c.logger.Debug("Setting up message timeout handler",
-				"message_id", msgID,
-				"timeout_seconds", timeoutSeconds,
-				"timeout_at", timeoutAt,
-				"test_mode", c.IsTestMode)
+// setTypingIndicator replaces the typing indicator
+func (c *EnhancedChat) setTypingIndicator(indicator *typingIndicator) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.indicator = indicator
+}
 
-			// Use a timer and select to properly handle cancellations
-			timer := time.NewTimer(time.Duration(timeoutSeconds) * time.Second)
-			select {
-			case <-cancelChannel:
-				// Response already received - cancel the timeout
-				timer.Stop()
-				c.logger.Debug("Timeout handler cancelled - response already received", "message_id", msgID)
-				return
+// renderReply formats the markdown of a reply for the chat's output
+func (c *EnhancedChat) renderReply(text string) string {
+	c.mutex.RLock()
+	renderer := c.markdown
+	c.mutex.RUnlock()
+	return renderer.Render(text)
+}
 
-			case <-timer.C:
-				// Timer expired, check if message is still pending
-				c.mutex.Lock()
-				_, stillPending := c.pendingMsgs[msgID]
-				c.mutex.Unlock()
-
-				if stillPending {
-					c.logger.Warn("Message response timed out", "message_id", msgID, "elapsed_seconds", timeoutSeconds)
-					c.logger.Warn("Message timeout triggered - no response received after timeout", "message_id", msgID)
-					// Create a fake response as if it came from the agent
-					fmt.Fprintf(writer, "%s: I'm out of office today. If you need immediate assistance, please contact Tom Reynolds.\n\n", c.agent.Name())
-
-					// Remove from pending messages
-					c.mutex.Lock()
-					delete(c.pendingMsgs, msgID)
-					delete(c.msgCancelMap, msgID)
-					c.mutex.Unlock()
-					c.logger.Debug("Message marked as complete by timeout handler", "message_id", msgID)
-
-					// Signal that we handled the message
-					select {
-					case c.responses <- struct{}{}:
-						c.logger.Debug("Response notification sent from timeout handler", "message_id", msgID)
-					default: // Don't block if channel full
-						c.logger.Debug("Response notification channel full in timeout handler", "message_id", msgID)
-					}
-				} else {
-					c.logger.Debug("Timeout handler found message already processed", "message_id", msgID)
-				}
-			}
-		}(msg.ID, out, cancelCh)
+// awaitResponse sends a user message to the agent and prints the reply, or an out of
+// office notice if none arrives before the context's deadline (a last resort fallback).
+// Nothing is printed if the wait is cancelled, see cancelPending. A reply the agent
+// streams is printed as it is written, as plain text rather than formatted markdown.
+func (c *EnhancedChat) awaitResponse(ctx context.Context, msg messaging.Message, out io.Writer) {
+	timeout := c.replyTimeout()
+	c.logger.Debug("Waiting for response", "message_id", msg.ID, "agent", c.agent.Name(), "timeout", timeout, "test_mode", c.IsTestMode)
+	stream := &replyStream{}
+	response, err := c.request(ctx, msg, out, func(partial messaging.Message) {
+		c.printPartial(stream, msg, string(partial.Content), out)
+	})
+
+	c.mutex.Lock()
+	sentAt := c.pendingMsgs[msg.ID]
+	delete(c.pendingMsgs, msg.ID)
+	if wait, exists := c.msgCancelMap[msg.ID]; exists {
+		delete(c.msgCancelMap, msg.ID)
+		wait.cancel()
+	}
+	if stream.printed != "" {
+		c.streaming--
+	}
+	pendingCount := len(c.pendingMsgs)
+	waiting := c.waitingLocked()
+	c.mutex.Unlock()
+
+	// Stop or clear the typing indicator before the reply is printed
+	c.typing().update(waiting)
+	if stream.printed != "" && (err != nil || !strings.HasPrefix(string(response.Content), stream.printed)) {
+		// The reply did not end as it started; it is printed again in full
+		fmt.Fprint(out, "\n")
+		stream.printed = ""
+	}
 
-		// Continue processing for regular messages
-		return true
+	switch {
+	case err == nil:
+		originalMsgID := msg.ID
+		if respOrigID, exists := response.Metadata["original_id"]; exists && respOrigID != "" {
+			c.logger.Debug("Response references original message", "original_id", respOrigID, "response_id", response.ID)
+			originalMsgID = respOrigID
+		}
+		c.logger.Info("Agent response received",
+			"original_message_id", originalMsgID,
+			"response_id", response.ID,
+			"sender", response.SenderID,
+			"content_length", len(response.Content))
+		c.tracer.Debug("Response received for message %s, response ID: %s", originalMsgID, response.ID)
+		if stream.printed != "" {
+			fmt.Fprintf(out, "%s\n\n", strings.TrimPrefix(string(response.Content), stream.printed))
+		} else {
+			fmt.Fprintf(out, "%s: %s\n\n", c.replyName(msg), c.renderReply(string(response.Content)))
+		}
+		c.recordTranscript(c.agent.ID(), "assistant", string(response.Content))
+		c.recordMessage(sessionMessage{ID: response.ID, InReplyTo: msg.ID, Speaker: c.agent.Name(), Role: "assistant",
+			Text: string(response.Content), Timestamp: time.Now(), LatencyMS: time.Since(sentAt).Milliseconds()})
+		c.logger.Info("Message conversation complete", "message_id", msg.ID, "pending_count", pendingCount)
+
+	case errors.Is(err, context.DeadlineExceeded):
+		c.logger.Warn("Message response timed out", "message_id", msg.ID, "timeout", timeout, "retries", c.replyRetries())
+		fmt.Fprintf(out, "%s: %s\n\n", c.replyName(msg), c.fallback)
+		c.recordMessage(sessionMessage{ID: uuid.New().String(), InReplyTo: msg.ID, Speaker: c.agent.Name(), Role: "assistant",
+			Text: c.fallback, Timestamp: time.Now(), LatencyMS: time.Since(sentAt).Milliseconds(), TimedOut: true})
+
+	case c.ctx.Err() != nil:
+		// The chat is shutting down
+		return
+
+	case errors.Is(err, context.Canceled):
+		// Cancelled by the user, who was told so
+		c.logger.Info("Message response cancelled", "message_id", msg.ID)
+
+	default:
+		c.logger.Error("Failed to send message", "error", err)
+		c.tracer.Error("Failed to send message: %v", err)
+		fmt.Fprintf(out, "Failed to send message: %v\n", err)
+		return
 	}
 
-	// Default case (empty input or unhandled case)
-	return true
+	// Signal that the message was handled
+	select {
+	case c.responses <- struct{}{}:
+	default: // Don't block if channel full
+	}
+}
+
+// request sends a message to the agent and waits for the reply, passing the partial
+// replies of an agent that streams it to partial. A message not answered in time is sent
+// again under a new ID, up to the number of retries, after the agent is asked to stop
+// answering the earlier attempt.
+func (c *EnhancedChat) request(ctx context.Context, msg messaging.Message, out io.Writer, partial func(messaging.Message)) (messaging.Message, error) {
+	attempt := msg
+	for retry := 1; ; retry++ {
+		response, err := messaging.RequestStream(ctx, c.messageBus, attempt, partial)
+		if !errors.Is(err, context.DeadlineExceeded) || retry > c.replyRetries() {
+			return response, err
+		}
+		if canceller, ok := c.agent.(entity.MessageCanceller); ok {
+			canceller.CancelMessage(attempt.ID)
+		}
+
+		attempt.ID = uuid.New().String()
+		attempt.CorrelationID = ""
+		attempt.Timestamp = time.Now()
+		var pending bool
+		if ctx, pending = c.rewait(msg.ID, attempt.ID); !pending {
+			// Cancelled by the user in the meantime
+			return response, err
+		}
+
+		c.logger.Warn("Message response timed out, sending it again", "message_id", msg.ID, "attempt_id", attempt.ID, "retry", retry)
+		c.typing().clear()
+		fmt.Fprintf(out, "No reply to [%s] yet, asking %s again.\n", msg.ID[:8], c.agent.Name())
+	}
+}
+
+// replyStream is a reply printed as the agent writes it
+type replyStream struct {
+	printed string // Text of the reply printed so far
+}
+
+// printPartial prints what a partial reply adds to the text of the reply printed so far,
+// starting the reply on the first. A partial reply that does not add to it, having
+// arrived out of order, is ignored.
+func (c *EnhancedChat) printPartial(stream *replyStream, msg messaging.Message, text string, out io.Writer) {
+	if len(text) <= len(stream.printed) || !strings.HasPrefix(text, stream.printed) {
+		return
+	}
+	if stream.printed == "" {
+		c.mutex.Lock()
+		c.streaming++
+		waiting := c.waitingLocked()
+		c.mutex.Unlock()
+		c.typing().update(waiting)
+		c.typing().clear()
+		fmt.Fprintf(out, "%s: ", c.replyName(msg))
+	}
+	fmt.Fprint(out, text[len(stream.printed):])
+	stream.printed = text
+}
+
+// waitingLocked returns the number of replies the typing indicator shows are awaited:
+// none while a reply is printed as it is written, which the indicator would draw over
+func (c *EnhancedChat) waitingLocked() int {
+	if c.streaming > 0 {
+		return 0
+	}
+	return len(c.pendingMsgs)
+}
+
+// rewait starts a new wait for the reply to a pending message, sent again under the
+// attempt ID. It returns false if the message is no longer pending.
+func (c *EnhancedChat) rewait(messageID, attemptID string) (context.Context, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	wait, pending := c.msgCancelMap[messageID]
+	if !pending {
+		return nil, false
+	}
+	wait.cancel()
+	ctx, cancel := context.WithTimeout(c.ctx, c.replyTimeout())
+	c.msgCancelMap[messageID] = pendingReply{cancel: cancel, attemptID: attemptID}
+	return ctx, true
 }
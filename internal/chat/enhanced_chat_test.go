@@ -0,0 +1,104 @@
+package chat
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"goproduct/internal/entity"
+	"goproduct/internal/messaging"
+	"goproduct/internal/tracing"
+)
+
+func TestReplyRetry(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	agent := &cancellingAgent{CliHumanEntity: entity.NewCliHumanEntity("Andy", bus), cancelled: make(chan string, 2)}
+
+	// The agent misses the first attempt and answers the next
+	var attempts atomic.Int32
+	bus.Subscribe(agent.ID(), func(msg messaging.Message) error {
+		if attempts.Add(1) == 1 {
+			return nil
+		}
+		return bus.Publish(messaging.NewTextReplyMessage(agent.ID(), msg, "Roadmap drafted."))
+	})
+
+	c := NewEnhancedChat(entity.NewCliHumanEntity("User", bus), agent, bus, tracing.NewMemoryTracer(),
+		WithReplyTimeout(50*time.Millisecond), WithReplyRetries(1))
+	c.IsTestMode = true
+	out := &syncBuffer{}
+
+	c.processInput("Draft the roadmap", out)
+	select {
+	case <-c.responses:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the message to be handled")
+	}
+
+	got := out.String()
+	if !strings.Contains(got, "yet, asking Andy again.") || !strings.Contains(got, "Roadmap drafted.") {
+		t.Errorf("Expected the message sent again and answered, got %q", got)
+	}
+	if strings.Contains(got, DefaultFallbackReply) {
+		t.Errorf("Expected no fallback once answered, got %q", got)
+	}
+	if len(agent.cancelled) != 1 {
+		t.Errorf("Expected the agent to stop answering the first attempt, got %d cancellations", len(agent.cancelled))
+	}
+	c.cancel()
+}
+
+func TestReplyFallback(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	agent := entity.NewCliHumanEntity("Andy", bus)
+	c := NewEnhancedChat(entity.NewCliHumanEntity("User", bus), agent, bus, tracing.NewMemoryTracer(),
+		WithReplyTimeout(20*time.Millisecond), WithReplyRetries(0), WithFallbackReply("Back on Monday."))
+	c.IsTestMode = true
+	out := &syncBuffer{}
+
+	c.processInput("Draft the roadmap", out)
+	select {
+	case <-c.responses:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the message to be handled")
+	}
+	if got := out.String(); !strings.Contains(got, "Andy: Back on Monday.") || strings.Contains(got, "again") {
+		t.Errorf("Expected the fallback after a single wait, got %q", got)
+	}
+	c.cancel()
+}
+
+func TestReplyStreamed(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	bus.SetDeliveryMode(messaging.DeliveryOrdered)
+	agent := entity.NewCliHumanEntity("Andy", bus)
+
+	// The agent streams its reply, one partial reply arriving out of order
+	bus.Subscribe(agent.ID(), func(msg messaging.Message) error {
+		if msg.Metadata[messaging.MetadataStream] != "true" {
+			return bus.Publish(messaging.NewTextReplyMessage(agent.ID(), msg, "Not streamed."))
+		}
+		for _, text := range []string{"Roadmap", "Roadmap dra", "Road"} {
+			partial := messaging.NewTextReplyMessage(agent.ID(), msg, text)
+			partial.Metadata[messaging.MetadataPartial] = "true"
+			bus.Publish(partial)
+		}
+		return bus.Publish(messaging.NewTextReplyMessage(agent.ID(), msg, "Roadmap drafted."))
+	})
+
+	c := NewEnhancedChat(entity.NewCliHumanEntity("User", bus), agent, bus, tracing.NewMemoryTracer())
+	c.IsTestMode = true
+	out := &syncBuffer{}
+
+	c.processInput("Draft the roadmap", out)
+	select {
+	case <-c.responses:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the message to be handled")
+	}
+	if got := out.String(); !strings.HasSuffix(got, "Andy: Roadmap drafted.\n\n") || strings.Count(got, "Roadmap") != 1 {
+		t.Errorf("Expected the reply printed once as it was written, got %q", got)
+	}
+	c.cancel()
+}
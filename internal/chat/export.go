@@ -0,0 +1,192 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goproduct/internal/commands"
+)
+
+// Formats of /export
+const (
+	ExportMarkdown = "markdown"
+	ExportJSON     = "json"
+	ExportHTML     = "html"
+)
+
+// sessionMessage is a message of the chat session as exported by /export
+type sessionMessage struct {
+	ID        string    `json:"id"`
+	InReplyTo string    `json:"in_reply_to,omitempty"` // ID of the user message a reply answers
+	Speaker   string    `json:"speaker"`
+	Role      string    `json:"role"` // "user" or "assistant"
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp"`
+	LatencyMS int64     `json:"latency_ms,omitempty"` // Time from the user message to the reply
+	TimedOut  bool      `json:"timed_out,omitempty"`  // The fallback was shown as no reply came
+}
+
+// latency returns the time the reply took
+func (m sessionMessage) latency() time.Duration {
+	return time.Duration(m.LatencyMS) * time.Millisecond
+}
+
+// sessionExport is the conversation written by /export in JSON
+type sessionExport struct {
+	Title      string           `json:"title"`
+	Session    string           `json:"session"`
+	User       string           `json:"user"`
+	Agent      string           `json:"agent"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Messages   []sessionMessage `json:"messages"`
+}
+
+// exportCommand returns the /export command
+func (c *EnhancedChat) exportCommand() commands.Command {
+	return commands.Command{
+		Name:        "export",
+		Usage:       "[markdown|json|html] <path>",
+		Description: "Write the conversation, with message IDs, timestamps and reply times, to a file",
+		Help:        "e.g. /export json roadmap-chat.json\nWithout a format it is chosen from the file extension, markdown by default.",
+		MinArgs:     1,
+		MaxArgs:     2,
+		Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+			path, format := inv.Args[len(inv.Args)-1], exportFormat(inv.Args[len(inv.Args)-1])
+			if len(inv.Args) == 2 {
+				format = strings.ToLower(inv.Args[0])
+			}
+			return c.export(format, path)
+		},
+	}
+}
+
+// exportFormat returns the format of an export file by its extension
+func exportFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ExportJSON
+	case ".html", ".htm":
+		return ExportHTML
+	}
+	return ExportMarkdown
+}
+
+// recordMessage adds a message to the session exported by /export
+func (c *EnhancedChat) recordMessage(message sessionMessage) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.sessionLog = append(c.sessionLog, message)
+}
+
+// export writes the messages of the session to the file at the path in the format
+func (c *EnhancedChat) export(format, path string) (string, error) {
+	c.mutex.RLock()
+	conversation := sessionExport{
+		Title:      "Chat with " + c.agent.Name(),
+		Session:    c.session,
+		User:       c.human.Name(),
+		Agent:      c.agent.Name(),
+		ExportedAt: time.Now(),
+		Messages:   append([]sessionMessage{}, c.sessionLog...),
+	}
+	c.mutex.RUnlock()
+	if len(conversation.Messages) == 0 {
+		return "No messages to export yet.", nil
+	}
+
+	var data []byte
+	var err error
+	switch format {
+	case ExportMarkdown, "md":
+		data = []byte(exportMarkdown(conversation))
+	case ExportJSON:
+		data, err = json.MarshalIndent(conversation, "", "  ")
+	case ExportHTML:
+		data, err = exportHTML(conversation)
+	default:
+		return "", fmt.Errorf("unknown export format %q, use markdown, json or html", format)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to format the conversation: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to export the conversation: %w", err)
+	}
+
+	c.logger.Info("Chat exported", "path", path, "format", format, "messages", len(conversation.Messages))
+	return fmt.Sprintf("Exported %d messages to %s.", len(conversation.Messages), path), nil
+}
+
+// exportMarkdown formats the conversation as markdown, a section per message
+func exportMarkdown(conversation sessionExport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", conversation.Title)
+	fmt.Fprintf(&sb, "Session `%s` between %s and %s, exported %s.\n", conversation.Session, conversation.User,
+		conversation.Agent, conversation.ExportedAt.Format(time.RFC1123))
+	for _, message := range conversation.Messages {
+		fmt.Fprintf(&sb, "\n## %s · %s\n\n", message.Speaker, message.Timestamp.Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(&sb, "_Message `%s`", message.ID)
+		if message.InReplyTo != "" {
+			fmt.Fprintf(&sb, ", in reply to `%s` after %s", message.InReplyTo, message.latency().Round(time.Millisecond))
+		}
+		if message.TimedOut {
+			sb.WriteString(", no reply in time")
+		}
+		sb.WriteString("_\n\n")
+		sb.WriteString(message.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// exportPage lays out the conversation as a standalone web page
+var exportPage = template.Must(template.New("export").Funcs(template.FuncMap{
+	"when": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; color: #222; }
+.message { border-left: 4px solid #ccc; margin: 1em 0; padding: 0.5em 1em; }
+.assistant { border-color: #4a90d9; background: #f4f8fc; }
+.meta { color: #777; font-size: 0.85em; }
+.text { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">Session <code>{{.Session}}</code> between {{.User}} and {{.Agent}}, exported {{when .ExportedAt}}.</p>
+{{range .Messages}}<div class="message {{.Role}}" id="{{.ID}}">
+<p class="meta"><strong>{{.Speaker}}</strong> · {{when .Timestamp}} · <code>{{.ID}}</code>{{if .InReplyTo}} · in reply to <a href="#{{.InReplyTo}}"><code>{{.InReplyTo}}</code></a> after {{.Latency}}{{end}}{{if .TimedOut}} · no reply in time{{end}}</p>
+<div class="text">{{.Text}}</div>
+</div>
+{{end}}</body>
+</html>
+`))
+
+// exportHTML formats the conversation as a web page
+func exportHTML(conversation sessionExport) ([]byte, error) {
+	type pageMessage struct {
+		sessionMessage
+		Latency time.Duration
+	}
+	messages := make([]pageMessage, 0, len(conversation.Messages))
+	for _, message := range conversation.Messages {
+		messages = append(messages, pageMessage{message, message.latency().Round(time.Millisecond)})
+	}
+
+	var sb strings.Builder
+	err := exportPage.Execute(&sb, struct {
+		sessionExport
+		Messages []pageMessage
+	}{conversation, messages})
+	return []byte(sb.String()), err
+}
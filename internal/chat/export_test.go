@@ -0,0 +1,78 @@
+package chat
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/entity"
+	"goproduct/internal/messaging"
+	"goproduct/internal/tracing"
+)
+
+func TestExport(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	agent := entity.NewCliHumanEntity("Andy", bus)
+	bus.Subscribe(agent.ID(), func(msg messaging.Message) error {
+		return bus.Publish(messaging.NewTextReplyMessage(agent.ID(), msg, "Use <b>tags</b> & **markdown**."))
+	})
+	c := NewEnhancedChat(entity.NewCliHumanEntity("User", bus), agent, bus, tracing.NewMemoryTracer())
+	c.IsTestMode = true
+	out := &syncBuffer{}
+	dir := t.TempDir()
+
+	c.processInput("/export "+filepath.Join(dir, "empty.md"), out)
+	if !strings.Contains(out.String(), "No messages to export yet.") {
+		t.Errorf("Expected nothing to export, got %q", out.String())
+	}
+
+	c.processInput("How should notes be formatted?", out)
+	select {
+	case <-c.responses:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the message to be answered")
+	}
+
+	for _, command := range []string{"/export chat.json", "/export markdown chat.txt", "/export chat.html", "/export pdf chat.pdf"} {
+		c.processInput(strings.Replace(command, "chat.", filepath.Join(dir, "chat."), 1), out)
+	}
+	if !strings.Contains(out.String(), "Exported 2 messages to") {
+		t.Errorf("Expected the export reported, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), `Unknown export format "pdf"`) {
+		t.Errorf("Expected an unknown format rejected, got %q", out.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "chat.json"))
+	if err != nil {
+		t.Fatalf("Failed to read the JSON export: %v", err)
+	}
+	var exported sessionExport
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatalf("Failed to parse the JSON export: %v", err)
+	}
+	if len(exported.Messages) != 2 || exported.Agent != "Andy" {
+		t.Fatalf("Expected two messages with Andy, got %+v", exported)
+	}
+	question, answer := exported.Messages[0], exported.Messages[1]
+	if question.Role != "user" || question.Text != "How should notes be formatted?" || question.ID == "" {
+		t.Errorf("Unexpected question: %+v", question)
+	}
+	if answer.Role != "assistant" || answer.InReplyTo != question.ID || answer.Timestamp.Before(question.Timestamp) {
+		t.Errorf("Expected the answer to reply to the question, got %+v", answer)
+	}
+
+	markdown, _ := os.ReadFile(filepath.Join(dir, "chat.txt"))
+	if !strings.Contains(string(markdown), "## Andy · ") || !strings.Contains(string(markdown), "in reply to `"+question.ID+"` after ") {
+		t.Errorf("Unexpected markdown export:\n%s", markdown)
+	}
+
+	html, _ := os.ReadFile(filepath.Join(dir, "chat.html"))
+	if !strings.Contains(string(html), "Use &lt;b&gt;tags&lt;/b&gt; &amp; **markdown**.") || !strings.Contains(string(html), `href="#`+question.ID+`"`) {
+		t.Errorf("Expected the reply escaped and linked to the question, got:\n%s", html)
+	}
+	c.cancel()
+}
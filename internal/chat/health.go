@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"goproduct/internal/common"
+)
+
+// healthTimeout bounds the checks run by /health
+const healthTimeout = 10 * time.Second
+
+// healthCheck returns the health of the application
+type healthCheck func(ctx context.Context) common.HealthReport
+
+// SetHealth lets the chat report the health of the application's components with /health
+func (c *EnhancedChat) SetHealth(health func(ctx context.Context) common.HealthReport) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.health = health
+}
+
+// healthReport lists the components and whether they work
+func (c *EnhancedChat) healthReport() string {
+	c.mutex.RLock()
+	health := c.health
+	c.mutex.RUnlock()
+	if health == nil {
+		return "Health is not tracked."
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, healthTimeout)
+	defer cancel()
+	report := health(ctx)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Health: %s\n", report.Status)
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tSTATUS\tDETAILS")
+	for _, component := range report.Components {
+		details := component.Error
+		if component.Optional {
+			details = strings.TrimSpace("optional " + details)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", component.Name, component.Status, details)
+	}
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
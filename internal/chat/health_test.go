@@ -0,0 +1,41 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"goproduct/internal/common"
+	"goproduct/internal/entity"
+	"goproduct/internal/messaging"
+	"goproduct/internal/tracing"
+)
+
+func TestHealthReport(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	c := NewEnhancedChat(entity.NewCliHumanEntity("User", bus), entity.NewCliHumanEntity("Andy", bus), bus, tracing.NewMemoryTracer())
+	c.IsTestMode = true
+	defer c.cancel()
+	out := &syncBuffer{}
+
+	c.processInput("/health", out)
+	if !strings.Contains(out.String(), "Health is not tracked.") {
+		t.Errorf("Expected health not tracked, got %q", out.String())
+	}
+
+	runtime, _ := common.NewRuntimeContext(common.RuntimeOptions{})
+	runtime.Register(common.Component{Name: "message bus"})
+	runtime.Register(common.Component{Name: "admin server", Optional: true, Start: func(context.Context) error {
+		return errors.New("socket in use")
+	}})
+	runtime.Start(context.Background())
+	c.SetHealth(runtime.Health)
+
+	c.processInput("/health", out)
+	for _, want := range []string{"Health: degraded", "message bus   up", "admin server  down    optional socket in use"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in the report, got %q", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"goproduct/internal/messaging"
+)
+
+// historyLimit is how many messages /history shows
+const historyLimit = 10
+
+// history lists the most recent messages sent or received by the human, oldest first:
+// from the chat transcript if there is a knowledge store, from the message bus otherwise
+func (c *EnhancedChat) history() string {
+	if c.store != nil {
+		return c.transcriptHistory()
+	}
+	messages, err := c.messageBus.GetHistory(c.human.ID(), time.Time{}, historyLimit)
+	if errors.Is(err, messaging.ErrHistoryDisabled) {
+		return "Message history is not enabled."
+	}
+	if err != nil {
+		c.logger.Error("Failed to load message history", "error", err)
+		return fmt.Sprintf("Failed to load message history: %v", err)
+	}
+	if len(messages) == 0 {
+		return "No recent messages."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Recent messages:\n")
+	for _, msg := range messages {
+		content := string(msg.Content)
+		if msg.ContentType != messaging.ContentTypeText {
+			content = fmt.Sprintf("(%s)", msg.ContentType)
+		}
+		sb.WriteString(fmt.Sprintf("  [%s] %s: %s\n", msg.Timestamp.Format("15:04"), c.senderName(msg.SenderID), content))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// senderName returns the display name of a message sender, or its ID if unknown
+func (c *EnhancedChat) senderName(senderID string) string {
+	switch senderID {
+	case c.human.ID():
+		return c.human.Name()
+	case c.agent.ID():
+		return c.agent.Name()
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for _, contact := range c.contacts {
+		if contact.ID() == senderID {
+			return contact.Name()
+		}
+	}
+	return senderID
+}
@@ -0,0 +1,35 @@
+package chat
+
+import (
+	"fmt"
+	"io"
+
+	"goproduct/internal/llm"
+	"goproduct/internal/messaging"
+)
+
+// defaultImageQuestion is asked about an image sent without a question
+const defaultImageQuestion = "What do you make of this image?"
+
+// sendImage sends an image file with a question about it to the agent. Agents whose model
+// cannot see images get the file name in its place.
+func (c *EnhancedChat) sendImage(path, question string, out io.Writer) {
+	image, err := llm.LoadImage(path)
+	if err != nil {
+		c.logger.Warn("Failed to load image", "path", path, "error", err)
+		fmt.Fprintf(out, "Could not attach the image: %v\n", err)
+		return
+	}
+	if question == "" {
+		question = defaultImageQuestion
+	}
+
+	attachment := messaging.Attachment{Name: image.AltText, MediaType: image.MediaType, Data: image.Data}
+	msg, err := messaging.NewMultipartMessage(c.human.ID(), []string{c.agent.ID()}, question, []messaging.Attachment{attachment})
+	if err != nil {
+		fmt.Fprintf(out, "Could not attach the image: %v\n", err)
+		return
+	}
+	c.logger.Info("Processing user message with image", "content_length", len(question), "image", image.AltText, "image_bytes", len(image.Data))
+	c.send(msg, question, out)
+}
@@ -0,0 +1,125 @@
+package chat
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// spinnerFrames are drawn in turn by the typing indicator
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// clearLine moves to the start of the terminal line and erases it
+const clearLine = "\r\x1b[K"
+
+// typingIndicator shows a spinner and "<agent> is thinking…" while messages await a
+// reply. It is driven by the number of pending messages and is nil-safe, a nil indicator
+// showing nothing, as in test mode or when the output is not a terminal.
+type typingIndicator struct {
+	name     string
+	interval time.Duration
+	draw     func(status string) // Shows the status, or clears it when empty
+
+	mutex   sync.Mutex // Protect the fields below and serialize drawing
+	pending int        // Messages awaiting a reply
+	since   time.Time  // When the oldest pending message was sent
+	hidden  bool       // Cleared for output; drawn again on the next tick
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// newTypingIndicator creates an indicator for the agent that draws with the function
+func newTypingIndicator(name string, draw func(status string)) *typingIndicator {
+	return &typingIndicator{name: name, interval: 100 * time.Millisecond, draw: draw}
+}
+
+// lineStatus draws the status on the current line of a terminal
+func lineStatus(out io.Writer) func(status string) {
+	return func(status string) {
+		fmt.Fprint(out, clearLine+status)
+	}
+}
+
+// update sets the number of messages awaiting a reply, starting the spinner when there are
+// some and stopping it when there are none. Output about to be written is not drawn over.
+func (t *typingIndicator) update(pending int) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	previous := t.pending
+	t.pending = pending
+	switch {
+	case previous == 0 && pending > 0:
+		t.since = time.Now()
+		t.hidden = false
+		t.stop = make(chan struct{})
+		t.done = make(chan struct{})
+		go t.run(t.stop, t.done)
+	case previous > 0 && pending == 0:
+		stop, done := t.stop, t.done
+		t.mutex.Unlock()
+		close(stop)
+		<-done
+		return
+	case pending > 0:
+		// A reply is about to be printed while others are still awaited
+		t.clearLocked()
+	}
+	t.mutex.Unlock()
+}
+
+// clear erases the status so that output can be written; it is drawn again on the next
+// tick if replies are still awaited
+func (t *typingIndicator) clear() {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.pending > 0 {
+		t.clearLocked()
+	}
+}
+
+// clearLocked erases the status; the mutex must be held
+func (t *typingIndicator) clearLocked() {
+	if !t.hidden {
+		t.draw("")
+		t.hidden = true
+	}
+}
+
+// run draws the spinner until stopped, then clears it
+func (t *typingIndicator) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for frame := 0; ; frame++ {
+		t.mutex.Lock()
+		t.draw(t.status(frame))
+		t.hidden = false
+		t.mutex.Unlock()
+
+		select {
+		case <-stop:
+			t.mutex.Lock()
+			t.clearLocked()
+			t.mutex.Unlock()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// status returns the text drawn for a frame of the spinner; the mutex must be held
+func (t *typingIndicator) status(frame int) string {
+	status := fmt.Sprintf("%s %s is thinking… %ds", spinnerFrames[frame%len(spinnerFrames)], t.name,
+		int(time.Since(t.since).Seconds()))
+	if t.pending > 1 {
+		status += fmt.Sprintf(" (%d messages)", t.pending)
+	}
+	return status
+}
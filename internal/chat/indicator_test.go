@@ -0,0 +1,84 @@
+package chat
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goproduct/internal/knowledge"
+)
+
+// statusRecorder records what a typing indicator draws
+type statusRecorder struct {
+	mutex    sync.Mutex
+	statuses []string
+}
+
+func (r *statusRecorder) draw(status string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.statuses = append(r.statuses, status)
+}
+
+func (r *statusRecorder) last() (string, int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.statuses) == 0 {
+		return "", 0
+	}
+	return r.statuses[len(r.statuses)-1], len(r.statuses)
+}
+
+func TestTypingIndicator(t *testing.T) {
+	recorder := &statusRecorder{}
+	indicator := newTypingIndicator("Andy", recorder.draw)
+	indicator.interval = 5 * time.Millisecond
+
+	indicator.update(1)
+	time.Sleep(20 * time.Millisecond)
+	status, _ := recorder.last()
+	if !strings.Contains(status, "Andy is thinking…") || strings.Contains(status, "messages") {
+		t.Errorf("Expected the agent to be thinking, got %q", status)
+	}
+
+	indicator.update(2)
+	time.Sleep(20 * time.Millisecond)
+	if status, _ := recorder.last(); !strings.HasSuffix(status, "(2 messages)") {
+		t.Errorf("Expected the pending messages to be counted, got %q", status)
+	}
+
+	// A reply printed while another is awaited clears the status until the next tick
+	indicator.update(1)
+	time.Sleep(20 * time.Millisecond)
+	if status, _ := recorder.last(); !strings.Contains(status, "thinking") {
+		t.Errorf("Expected the status to be drawn again, got %q", status)
+	}
+
+	indicator.update(0)
+	status, count := recorder.last()
+	if status != "" {
+		t.Errorf("Expected the status to be cleared once no reply is awaited, got %q", status)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, after := recorder.last(); after != count {
+		t.Error("Expected nothing to be drawn after the indicator stopped")
+	}
+
+	// Without a terminal there is no indicator
+	var none *typingIndicator
+	none.update(1)
+	none.clear()
+}
+
+func TestTypingIndicatorSuppressedInTestMode(t *testing.T) {
+	c, _ := newTranscriptTestChat(knowledge.Store(nil))
+	c.IsTestMode = true
+	out := &syncBuffer{}
+	if err := c.StartWithIO(strings.NewReader("/now\n"), out); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if c.typing() != nil || strings.Contains(out.String(), "thinking") {
+		t.Errorf("Expected no typing indicator in test mode, got %q", out.String())
+	}
+}
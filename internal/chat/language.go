@@ -0,0 +1,33 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"goproduct/internal/entity"
+)
+
+// language switches the agent to the given language, or describes the current one if
+// the language is empty
+func (c *EnhancedChat) language(language string) string {
+	selector, ok := c.agent.(entity.LanguageSelector)
+	if !ok || len(selector.Languages()) == 0 {
+		return fmt.Sprintf("%s only speaks one language.", c.agent.Name())
+	}
+
+	if language == "" {
+		current := selector.Language()
+		if current == "" {
+			current = "default"
+		}
+		return fmt.Sprintf("%s is responding in: %s\nAvailable: %s", c.agent.Name(), current, strings.Join(selector.Languages(), ", "))
+	}
+
+	if err := selector.SetLanguage(language); err != nil {
+		c.logger.Warn("Failed to switch language", "language", language, "error", err)
+		return fmt.Sprintf("Can't switch language: %v", err)
+	}
+	c.logger.Info("Agent language switched", "agent_id", c.agent.ID(), "language", selector.Language())
+	c.tracer.Info("Agent language switched to %s", selector.Language())
+	return fmt.Sprintf("%s will now respond in %s.", c.agent.Name(), selector.Language())
+}
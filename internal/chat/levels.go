@@ -0,0 +1,91 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"goproduct/internal/commands"
+	"goproduct/internal/logging"
+	"goproduct/internal/tracing"
+)
+
+// levelCommands returns /loglevel and /tracelevel, which change how much the application
+// log and the trace log record without a restart
+func (c *EnhancedChat) levelCommands() []commands.Command {
+	return []commands.Command{
+		{
+			Name:        "loglevel",
+			Usage:       "[component] [debug|info|warn|error|reset]",
+			Description: "Show the levels of the application log, or change them",
+			Help:        "e.g. /loglevel info sets the level of every component, /loglevel llm debug that of the language model only and /loglevel llm reset removes it.",
+			MaxArgs:     2,
+			Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+				switch len(inv.Args) {
+				case 0:
+					return c.logLevels(), nil
+				case 1:
+					return c.setLogLevel("", inv.Args[0])
+				default:
+					return c.setLogLevel(inv.Args[0], inv.Args[1])
+				}
+			},
+		},
+		{
+			Name:        "tracelevel",
+			Usage:       "[error|warning|info|debug|verbose]",
+			Description: "Show the level of the trace log, or change it",
+			MaxArgs:     1,
+			Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+				if len(inv.Args) == 0 {
+					return fmt.Sprintf("Trace level: %s", c.tracer.Level()), nil
+				}
+				level, err := tracing.ParseLevel(inv.Args[0])
+				if err != nil {
+					return "", err
+				}
+				c.tracer.SetLevel(level)
+				c.logger.Info("Trace level changed", "level", level)
+				return fmt.Sprintf("Trace level set to %s.", level), nil
+			},
+		},
+	}
+}
+
+// logLevels lists the level of the application log and of the components with their own
+func (c *EnhancedChat) logLevels() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Log level: %s", strings.ToLower(c.logger.Level().String()))
+	levels := c.logger.ComponentLevels()
+	components := make([]string, 0, len(levels))
+	for component := range levels {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	for _, component := range components {
+		fmt.Fprintf(&sb, "\n  %s: %s", component, strings.ToLower(levels[component].String()))
+	}
+	return sb.String()
+}
+
+// setLogLevel sets the level of a component, or of the application log if the component
+// is empty; "reset" removes the level of a component
+func (c *EnhancedChat) setLogLevel(component, name string) (string, error) {
+	if component != "" && strings.EqualFold(name, "reset") {
+		c.logger.ResetComponentLevel(component)
+		return fmt.Sprintf("Log level of %s reset to %s.", component, strings.ToLower(c.logger.Level().String())), nil
+	}
+	level, err := logging.ParseLevel(name)
+	if err != nil {
+		return "", err
+	}
+	if component == "" {
+		c.logger.SetLevel(level)
+		c.logger.Info("Log level changed", "level", level)
+		return fmt.Sprintf("Log level set to %s.", strings.ToLower(level.String())), nil
+	}
+	c.logger.SetComponentLevel(component, level)
+	c.logger.Info("Log level changed", "target", component, "level", level)
+	return fmt.Sprintf("Log level of %s set to %s.", component, strings.ToLower(level.String())), nil
+}
@@ -0,0 +1,51 @@
+package chat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"goproduct/internal/entity"
+	"goproduct/internal/logging"
+	"goproduct/internal/messaging"
+	"goproduct/internal/tracing"
+)
+
+func TestLevelCommands(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	tracer := tracing.NewMemoryTracer()
+	c := NewEnhancedChat(entity.NewCliHumanEntity("User", bus), entity.NewCliHumanEntity("Andy", bus), bus, tracer)
+	c.IsTestMode = true
+	defer c.cancel()
+	var log bytes.Buffer
+	logger := logging.New(&log, logging.DefaultOptions())
+	c.logger = logger.Component("chat")
+	out := &syncBuffer{}
+
+	c.processInput("/loglevel warn", out)
+	c.processInput("/loglevel llm debug", out)
+	if logger.Level() != logging.LevelWarn || logger.ComponentLevel("llm") != logging.LevelDebug {
+		t.Errorf("Expected the levels to change, got %s and %v", logger.Level(), logger.ComponentLevels())
+	}
+	c.processInput("/loglevel", out)
+	if !strings.Contains(out.String(), "Log level: warn\n  llm: debug") {
+		t.Errorf("Expected the levels to be listed, got %q", out.String())
+	}
+	c.processInput("/loglevel llm reset", out)
+	if _, ok := logger.ComponentLevels()["llm"]; ok {
+		t.Error("Expected the level of llm to be removed")
+	}
+	c.processInput("/loglevel chatty", out)
+	if !strings.Contains(out.String(), `log level "chatty"`) {
+		t.Errorf("Expected an unknown level to be reported, got %q", out.String())
+	}
+
+	c.processInput("/tracelevel error", out)
+	if tracer.Level() != tracing.LevelError {
+		t.Errorf("Expected the trace level to change, got %s", tracer.Level())
+	}
+	c.processInput("/tracelevel", out)
+	if !strings.Contains(out.String(), "Trace level: error") {
+		t.Errorf("Expected the trace level to be shown, got %q", out.String())
+	}
+}
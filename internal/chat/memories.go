@@ -0,0 +1,250 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+
+	"goproduct/internal/commands"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/tools"
+)
+
+// Limits of the knowledge commands
+const (
+	maxRecallRows   = 10 // Search results shown by /recall
+	maxMemoryRows   = 20 // Facts listed by /memories
+	shortIDLength   = 8  // Characters of an entry ID shown in tables, enough to /forget it by
+	maxContentWidth = 60 // Characters of an entry's content shown in tables
+)
+
+// errNoStore is returned by the knowledge commands when the chat has no knowledge store
+var errNoStore = errors.New("no knowledge store is available")
+
+// knowledgeCommands returns the commands managing the knowledge store from the chat
+func (c *EnhancedChat) knowledgeCommands() []commands.Command {
+	return []commands.Command{
+		{
+			Name:        "remember",
+			Usage:       "<text>",
+			Description: "Store a fact in the knowledge store",
+			Help:        "e.g. /remember The beta launches on March 3rd",
+			MinArgs:     1,
+			MaxArgs:     -1,
+			Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+				return c.remember(inv.TextAfter(0))
+			},
+		},
+		{
+			Name:        "recall",
+			Usage:       "<query>",
+			Description: "Search the knowledge store",
+			Help:        "e.g. /recall beta launch\nThe IDs shown can be given to /forget.",
+			MinArgs:     1,
+			MaxArgs:     -1,
+			Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+				return c.recall(inv.TextAfter(0))
+			},
+		},
+		{
+			Name:        "forget",
+			Usage:       "<id>",
+			Description: "Delete a fact from the knowledge store",
+			Help:        "The ID may be shortened to the characters shown by /recall or /memories.\nForgotten facts are soft deleted and can be restored.",
+			MinArgs:     1,
+			MaxArgs:     1,
+			Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+				return c.forget(inv.Args[0])
+			},
+		},
+		{
+			Name:        "memories",
+			Description: "List the facts remembered by you and the agents, newest first",
+			Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+				return c.memories()
+			},
+		},
+	}
+}
+
+// remember stores the text as a fact owned by the user
+func (c *EnhancedChat) remember(text string) (string, error) {
+	if c.store == nil {
+		return "", errNoStore
+	}
+	now := time.Now()
+	entry := knowledge.Entry{
+		ID:          uuid.New().String(),
+		Category:    knowledge.CategoryFact,
+		ContentType: knowledge.ContentTypeText,
+		Content:     []byte(text),
+		Importance:  knowledge.ImportanceMedium,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		SourceID:    c.human.ID(),
+		SourceType:  "chat",
+		OwnerID:     c.human.ID(),
+		OwnerType:   "human",
+		SubjectIDs:  []string{},
+		Tags:        []string{tools.RememberedTag},
+		References:  []knowledge.Reference{},
+		Metadata:    map[string]string{},
+		Provenance:  []knowledge.ProvenanceStep{knowledge.FromCommand(commands.Prefix+"remember", c.human.ID())},
+	}
+	if err := c.store.AddRecord(entry); err != nil {
+		return "", fmt.Errorf("failed to remember: %w", err)
+	}
+	if err := c.store.Flush(); err != nil {
+		return "", fmt.Errorf("failed to save knowledge: %w", err)
+	}
+	c.logger.Info("Fact remembered from chat", "id", entry.ID)
+	return fmt.Sprintf("Remembered as [%s].", shortID(entry.ID)), nil
+}
+
+// recall returns a table of the entries best matching the query
+func (c *EnhancedChat) recall(query string) (string, error) {
+	if c.store == nil {
+		return "", errNoStore
+	}
+	results, err := c.store.FullTextSearch(query, knowledge.WithSearchLimit(maxRecallRows))
+	if err != nil {
+		return "", fmt.Errorf("failed to search knowledge: %w", err)
+	}
+	if len(results) == 0 {
+		return fmt.Sprintf("Nothing found for %q.", query), nil
+	}
+
+	var sb strings.Builder
+	table := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tCATEGORY\tUPDATED\tSCORE\tCONTENT")
+	for _, result := range results {
+		entry := result.Entry
+		fmt.Fprintf(table, "%s\t%s\t%s\t%.2f\t%s\n", shortID(entry.ID), entry.Category,
+			entry.UpdatedAt.Format("2006-01-02"), result.Score, contentPreview(entry))
+	}
+	table.Flush()
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// forget soft deletes the fact with the ID, or the only fact whose ID starts with it
+func (c *EnhancedChat) forget(id string) (string, error) {
+	if c.store == nil {
+		return "", errNoStore
+	}
+	entry, err := c.findEntry(id)
+	if err != nil {
+		return "", err
+	}
+	// Like the agents' forget tool, only facts can be forgotten; messages, decisions and
+	// actions are the record of what happened
+	if entry.Category != knowledge.CategoryFact {
+		return "", fmt.Errorf("entry [%s] is a %s, only facts can be forgotten", shortID(entry.ID), entry.Category)
+	}
+	if err := c.store.DeleteRecord(entry.ID); err != nil {
+		return "", fmt.Errorf("failed to forget [%s]: %w", shortID(entry.ID), err)
+	}
+	if err := c.store.Flush(); err != nil {
+		return "", fmt.Errorf("failed to save knowledge: %w", err)
+	}
+	c.logger.Info("Fact forgotten from chat", "id", entry.ID)
+	return fmt.Sprintf("Forgot [%s] %s", shortID(entry.ID), contentPreview(entry)), nil
+}
+
+// findEntry returns the entry with the ID, or the only one whose ID starts with it
+func (c *EnhancedChat) findEntry(id string) (knowledge.Entry, error) {
+	id = strings.ToLower(strings.Trim(id, "[]"))
+	if entry, err := c.store.GetRecord(id); err == nil {
+		return entry, nil
+	}
+
+	entries, err := c.store.SearchRecords(knowledge.Filter{})
+	if err != nil {
+		return knowledge.Entry{}, fmt.Errorf("failed to search knowledge: %w", err)
+	}
+	var matches []knowledge.Entry
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.ID, id) {
+			matches = append(matches, entry)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return knowledge.Entry{}, fmt.Errorf("no entry [%s]", id)
+	case 1:
+		return matches[0], nil
+	default:
+		return knowledge.Entry{}, fmt.Errorf("%d entries start with [%s], type more of the ID", len(matches), id)
+	}
+}
+
+// memories returns a table of the most recently remembered facts
+func (c *EnhancedChat) memories() (string, error) {
+	if c.store == nil {
+		return "", errNoStore
+	}
+	filter, err := knowledge.Query().
+		Where("Category", "=", knowledge.CategoryFact).
+		And("Tags", "CONTAINS", tools.RememberedTag).
+		OrderBy("UpdatedAt").Desc().
+		Limit(maxMemoryRows).
+		Build()
+	if err != nil {
+		return "", err
+	}
+	entries, err := c.store.SearchRecords(filter)
+	if err != nil {
+		return "", fmt.Errorf("failed to list memories: %w", err)
+	}
+	if len(entries) == 0 {
+		return "Nothing remembered yet, try /remember <text>.", nil
+	}
+
+	var sb strings.Builder
+	table := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tUPDATED\tBY\tCONTENT")
+	for _, entry := range entries {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", shortID(entry.ID), entry.UpdatedAt.Format("2006-01-02"),
+			c.ownerName(entry), contentPreview(entry))
+	}
+	table.Flush()
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// ownerName returns the name of the entity that owns an entry. Agents own their facts by
+// name, people by an ID that changes between runs, so only the start of it is shown.
+func (c *EnhancedChat) ownerName(entry knowledge.Entry) string {
+	if entry.OwnerType != "human" {
+		return entry.OwnerID
+	}
+	if name := c.senderName(entry.OwnerID); name != entry.OwnerID {
+		return name
+	}
+	return shortID(entry.OwnerID)
+}
+
+// shortID returns the start of an entry ID shown in tables
+func shortID(id string) string {
+	if len(id) > shortIDLength {
+		return id[:shortIDLength]
+	}
+	return id
+}
+
+// contentPreview returns the content of an entry on one line, shortened to fit a table
+func contentPreview(entry knowledge.Entry) string {
+	if entry.ContentType != knowledge.ContentTypeText && entry.ContentType != knowledge.ContentTypeMarkdown {
+		if title := entryTitle(entry); title != "" {
+			return title
+		}
+	}
+	text := strings.Join(strings.Fields(string(entry.Content)), " ")
+	if runes := []rune(text); len(runes) > maxContentWidth {
+		return string(runes[:maxContentWidth-3]) + "..."
+	}
+	return text
+}
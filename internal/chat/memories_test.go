@@ -0,0 +1,70 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"goproduct/internal/knowledge"
+)
+
+func TestKnowledgeCommands(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	c, _ := newTranscriptTestChat(store)
+	run := func(line string) string {
+		out := &syncBuffer{}
+		if !c.processInput(line, out) {
+			t.Fatalf("Expected %q not to end the chat", line)
+		}
+		return out.String()
+	}
+
+	reply := run("/remember The beta launches on March 3rd")
+	if !strings.HasPrefix(reply, "Remembered as [") {
+		t.Fatalf("Expected the fact to be remembered, got %q", reply)
+	}
+	id := reply[len("Remembered as [") : len("Remembered as [")+shortIDLength]
+	run("/remember Pricing stays at $20 per seat")
+
+	recall := run("/recall beta launch")
+	lines := strings.Split(strings.TrimSpace(recall), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[0], "CONTENT") {
+		t.Fatalf("Expected a table with one result, got:\n%s", recall)
+	}
+	if !strings.HasPrefix(lines[1], id) || !strings.Contains(lines[1], "fact") || !strings.Contains(lines[1], "The beta launches on March 3rd") {
+		t.Errorf("Expected the remembered fact in the results, got %q", lines[1])
+	}
+	if reply := run("/recall onboarding"); !strings.Contains(reply, `Nothing found for "onboarding".`) {
+		t.Errorf("Expected no results, got %q", reply)
+	}
+
+	memories := run("/memories")
+	if !strings.Contains(memories, "User") || strings.Index(memories, "Pricing") > strings.Index(memories, "The beta") {
+		t.Errorf("Expected the facts newest first, got:\n%s", memories)
+	}
+
+	if reply := run("/forget " + id); !strings.Contains(reply, "Forgot ["+id+"] The beta launches") {
+		t.Errorf("Expected the fact to be forgotten, got %q", reply)
+	}
+	if memories := run("/memories"); strings.Contains(memories, "The beta") {
+		t.Errorf("Expected the forgotten fact to be gone, got:\n%s", memories)
+	}
+	if reply := run("/forget " + id); !strings.Contains(reply, "No entry ["+id+"]") {
+		t.Errorf("Expected an error for a forgotten fact, got %q", reply)
+	}
+
+	store.AddRecord(knowledge.Entry{ID: "decision-1", Category: knowledge.CategoryDecision, Content: []byte("Ship it")})
+	if reply := run("/forget decision"); !strings.Contains(reply, "only facts can be forgotten") {
+		t.Errorf("Expected decisions to be kept, got %q", reply)
+	}
+	if reply := run("/remember"); !strings.Contains(reply, "Usage: /remember <text>") {
+		t.Errorf("Expected a usage error, got %q", reply)
+	}
+
+	c.SetKnowledgeStore(nil)
+	if reply := run("/memories"); !strings.Contains(reply, "No knowledge store is available") {
+		t.Errorf("Expected an error without a store, got %q", reply)
+	}
+}
@@ -0,0 +1,319 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"goproduct/internal/commands"
+	"goproduct/internal/knowledge"
+)
+
+// ModeSection is one scripted question of a conversation mode
+type ModeSection struct {
+	Title    string        // Short name shown in the summary, e.g. "Blockers"
+	Question string        // Question asked when the section starts
+	Timebox  time.Duration // Time allowed for an answer before moving on, 0 for no limit
+	MaxWords int           // Longest accepted answer in words, 0 for no limit
+}
+
+// ConversationMode is a structured, time-boxed interaction such as a standup or triage.
+// The chat asks each section's question in turn and records a summary at the end.
+type ConversationMode struct {
+	Name        string        // Command name without the slash, e.g. "standup"
+	Description string        // Shown by /help
+	Sections    []ModeSection // Questions asked in order
+	Tags        []string      // Tags of the stored summary entry
+}
+
+// ModeAnswer is the outcome of one section of a conversation mode
+type ModeAnswer struct {
+	Title    string `json:"title"`
+	Question string `json:"question"`
+	Answer   string `json:"answer,omitempty"`
+	Skipped  bool   `json:"skipped,omitempty"`  // The user skipped the section
+	TimedOut bool   `json:"timedOut,omitempty"` // The timebox ran out before an answer
+}
+
+// ModeSummary is the structured result of a completed conversation mode
+type ModeSummary struct {
+	Mode        string       `json:"mode"`
+	StartedAt   time.Time    `json:"startedAt"`
+	CompletedAt time.Time    `json:"completedAt"`
+	Sections    []ModeAnswer `json:"sections"`
+}
+
+// StandupMode returns the daily standup mode
+func StandupMode() ConversationMode {
+	return ConversationMode{
+		Name:        "standup",
+		Description: "Run a time-boxed daily standup",
+		Tags:        []string{"standup"},
+		Sections: []ModeSection{
+			{Title: "Yesterday", Question: "What did you get done since the last standup?", Timebox: 2 * time.Minute, MaxWords: 60},
+			{Title: "Today", Question: "What are you working on today?", Timebox: 2 * time.Minute, MaxWords: 60},
+			{Title: "Blockers", Question: "Is anything blocking you?", Timebox: time.Minute, MaxWords: 40},
+		},
+	}
+}
+
+// TriageMode returns the issue triage mode
+func TriageMode() ConversationMode {
+	return ConversationMode{
+		Name:        "triage",
+		Description: "Triage an incoming issue step by step",
+		Tags:        []string{"triage"},
+		Sections: []ModeSection{
+			{Title: "Issue", Question: "What is the issue, in one or two sentences?", Timebox: 2 * time.Minute, MaxWords: 50},
+			{Title: "Impact", Question: "Who is affected and how badly?", Timebox: 2 * time.Minute, MaxWords: 50},
+			{Title: "Urgency", Question: "How urgent is it (now, this week, later)?", Timebox: time.Minute, MaxWords: 20},
+			{Title: "Owner", Question: "Who should own it?", Timebox: time.Minute, MaxWords: 10},
+			{Title: "Next step", Question: "What is the next concrete step?", Timebox: 2 * time.Minute, MaxWords: 40},
+		},
+	}
+}
+
+// modeSession tracks a running conversation mode
+type modeSession struct {
+	mode      ConversationMode
+	section   int // Index of the current section
+	answers   []ModeAnswer
+	startedAt time.Time
+	timer     *time.Timer
+}
+
+// SetKnowledgeStore sets the store that receives the chat transcript and the summaries
+// of completed conversation modes
+func (c *EnhancedChat) SetKnowledgeStore(store knowledge.Store) {
+	c.store = store
+}
+
+// RegisterMode makes a conversation mode available as the "/<name>" command
+func (c *EnhancedChat) RegisterMode(mode ConversationMode) {
+	err := c.RegisterCommand(commands.Command{
+		Name:        mode.Name,
+		Description: mode.Description,
+		Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+			return c.startMode(mode), nil
+		},
+	})
+	if err != nil {
+		c.logger.Error("Failed to register conversation mode", "mode", mode.Name, "error", err)
+	}
+}
+
+// startMode starts a conversation mode and returns its introduction and first question
+func (c *EnhancedChat) startMode(mode ConversationMode) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeMode != nil {
+		return fmt.Sprintf("A %s is already in progress. Answer the question, or type \"cancel\" to end it.", c.activeMode.mode.Name)
+	}
+	if len(mode.Sections) == 0 {
+		return fmt.Sprintf("The %s mode has no questions.", mode.Name)
+	}
+
+	c.activeMode = &modeSession{mode: mode, startedAt: time.Now()}
+	c.logger.Info("Conversation mode started", "mode", mode.Name)
+	c.tracer.Info("Conversation mode %s started", mode.Name)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Starting %s: %d questions. Keep answers short; type \"skip\" to skip a question or \"cancel\" to stop.\n", mode.Name, len(mode.Sections)))
+	sb.WriteString(c.askSectionLocked())
+	return sb.String()
+}
+
+// askSectionLocked starts the timebox of the current section and returns its question
+// (must be called with mutex held)
+func (c *EnhancedChat) askSectionLocked() string {
+	session := c.activeMode
+	section := session.mode.Sections[session.section]
+
+	if section.Timebox > 0 {
+		index := session.section
+		session.timer = time.AfterFunc(section.Timebox, func() {
+			c.timeoutSection(session, index)
+		})
+	}
+
+	question := fmt.Sprintf("[%d/%d] %s: %s", session.section+1, len(session.mode.Sections), section.Title, section.Question)
+	if section.Timebox > 0 {
+		question += fmt.Sprintf(" (%s)", section.Timebox)
+	}
+	return question
+}
+
+// timeoutSection moves on when a section's timebox runs out before it was answered
+func (c *EnhancedChat) timeoutSection(session *modeSession, index int) {
+	c.mutex.Lock()
+	if c.activeMode != session || session.section != index {
+		// Answered, skipped or cancelled in the meantime
+		c.mutex.Unlock()
+		return
+	}
+	section := session.mode.Sections[index]
+	c.logger.Info("Conversation mode section timed out", "mode", session.mode.Name, "section", section.Title)
+	text := fmt.Sprintf("Time's up for %s, moving on.\n", section.Title)
+	text += c.recordAnswerLocked(ModeAnswer{Title: section.Title, Question: section.Question, TimedOut: true})
+	out := c.out
+	c.mutex.Unlock()
+
+	if out != nil {
+		c.typing().clear()
+		fmt.Fprintln(out, text)
+	}
+}
+
+// recordAnswerLocked records the answer of the current section and returns the next
+// question, or the summary if it was the last one (must be called with mutex held)
+func (c *EnhancedChat) recordAnswerLocked(answer ModeAnswer) string {
+	session := c.activeMode
+	if session.timer != nil {
+		session.timer.Stop()
+		session.timer = nil
+	}
+	session.answers = append(session.answers, answer)
+	session.section++
+
+	if session.section < len(session.mode.Sections) {
+		return c.askSectionLocked()
+	}
+
+	c.activeMode = nil
+	return c.finishMode(session)
+}
+
+// handleMode passes input to the running conversation mode.
+// Returns true if the input was consumed.
+func (c *EnhancedChat) handleMode(input string, out io.Writer) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	session := c.activeMode
+	if session == nil {
+		return false
+	}
+	// Commands such as /exit keep working during a mode
+	if c.commands.IsCommand(input) {
+		return false
+	}
+
+	section := session.mode.Sections[session.section]
+	switch strings.ToLower(input) {
+	case "":
+		return true
+	case "cancel":
+		if session.timer != nil {
+			session.timer.Stop()
+		}
+		c.activeMode = nil
+		c.logger.Info("Conversation mode cancelled", "mode", session.mode.Name)
+		fmt.Fprintf(out, "%s cancelled. Back to free-form chat.\n\n", capitalize(session.mode.Name))
+		return true
+	case "skip":
+		fmt.Fprintln(out, c.recordAnswerLocked(ModeAnswer{Title: section.Title, Question: section.Question, Skipped: true}))
+		return true
+	}
+
+	if words := len(strings.Fields(input)); section.MaxWords > 0 && words > section.MaxWords {
+		fmt.Fprintf(out, "That's %d words; please keep it under %d.\n", words, section.MaxWords)
+		return true
+	}
+
+	fmt.Fprintln(out, c.recordAnswerLocked(ModeAnswer{Title: section.Title, Question: section.Question, Answer: input}))
+	return true
+}
+
+// finishMode builds the summary of a completed mode, stores it and returns it as text
+func (c *EnhancedChat) finishMode(session *modeSession) string {
+	summary := ModeSummary{
+		Mode:        session.mode.Name,
+		StartedAt:   session.startedAt,
+		CompletedAt: time.Now(),
+		Sections:    session.answers,
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n%s summary:\n", capitalize(summary.Mode)))
+	for _, answer := range summary.Sections {
+		switch {
+		case answer.Skipped:
+			sb.WriteString(fmt.Sprintf("  %s: (skipped)\n", answer.Title))
+		case answer.TimedOut:
+			sb.WriteString(fmt.Sprintf("  %s: (no answer in time)\n", answer.Title))
+		default:
+			sb.WriteString(fmt.Sprintf("  %s: %s\n", answer.Title, answer.Answer))
+		}
+	}
+
+	if c.store != nil {
+		id, err := c.storeModeSummary(session.mode, summary)
+		if err != nil {
+			c.logger.Error("Failed to store conversation mode summary", "mode", summary.Mode, "error", err)
+			c.tracer.Error("Failed to store %s summary: %v", summary.Mode, err)
+			sb.WriteString(fmt.Sprintf("Couldn't save the summary: %v\n", err))
+		} else {
+			sb.WriteString(fmt.Sprintf("Saved to knowledge [%s].\n", id[:8]))
+		}
+	}
+
+	c.logger.Info("Conversation mode completed", "mode", summary.Mode, "sections", len(summary.Sections))
+	c.tracer.Info("Conversation mode %s completed", summary.Mode)
+	sb.WriteString("Back to free-form chat.\n")
+	return sb.String()
+}
+
+// storeModeSummary saves the summary as a knowledge entry and returns its ID
+func (c *EnhancedChat) storeModeSummary(mode ConversationMode, summary ModeSummary) (string, error) {
+	content, err := json.Marshal(summary)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal summary: %w", err)
+	}
+
+	entry := knowledge.Entry{
+		ID:          uuid.New().String(),
+		Category:    knowledge.CategoryAction,
+		ContentType: knowledge.ContentTypeJSON,
+		Content:     content,
+		Importance:  knowledge.ImportanceMedium,
+		SourceID:    c.human.ID(),
+		SourceType:  "chat",
+		OwnerID:     c.human.ID(),
+		OwnerType:   "human",
+		SubjectIDs:  []string{c.human.ID()},
+		SubjectType: "human",
+		Tags:        append([]string{"conversation-mode"}, mode.Tags...),
+		References:  []knowledge.Reference{},
+		Metadata: map[string]string{
+			"title": fmt.Sprintf("%s %s", capitalize(mode.Name), summary.StartedAt.Format("2006-01-02")),
+			"mode":  mode.Name,
+		},
+		Provenance: []knowledge.ProvenanceStep{{
+			Origin:    knowledge.OriginConversation,
+			ActorID:   c.human.ID(),
+			ActorType: "human",
+			Command:   commands.Prefix + mode.Name,
+		}},
+	}
+	if err := c.store.AddRecord(entry); err != nil {
+		return "", err
+	}
+	if err := c.store.Flush(); err != nil {
+		return "", err
+	}
+	return entry.ID, nil
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
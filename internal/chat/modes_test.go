@@ -0,0 +1,148 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goproduct/internal/entity"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/messaging"
+	"goproduct/internal/tracing"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes from timers
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+func newModeTestChat(t *testing.T) (*EnhancedChat, knowledge.Store, *syncBuffer) {
+	bus := messaging.NewMemoryMessageBus()
+	human := entity.NewCliHumanEntity("User", bus)
+	agent := entity.NewCliHumanEntity("Andy", bus)
+	c := NewEnhancedChat(human, agent, bus, tracing.NewMemoryTracer())
+
+	store, _ := knowledge.NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	c.SetKnowledgeStore(store)
+
+	out := &syncBuffer{}
+	c.out = out
+	return c, store, out
+}
+
+func TestStandupMode(t *testing.T) {
+	c, store, out := newModeTestChat(t)
+
+	inputs := []string{
+		"standup()",
+		"Finished the billing migration",
+		"now()", // Commands still work during a mode
+		strings.Repeat("word ", 61),
+		"Pairing on the onboarding flow",
+		"skip",
+	}
+	for _, input := range inputs {
+		if !c.processInput(input, out) {
+			t.Fatalf("Unexpected exit after %q", input)
+		}
+	}
+
+	output := out.String()
+	expected := []string{
+		"Starting standup: 3 questions",
+		"[1/3] Yesterday:",
+		"Current time:",
+		"[2/3] Today:",
+		"That's 61 words; please keep it under 60.",
+		"[3/3] Blockers:",
+		"Standup summary:",
+		"Yesterday: Finished the billing migration",
+		"Today: Pairing on the onboarding flow",
+		"Blockers: (skipped)",
+		"Saved to knowledge",
+		"Back to free-form chat.",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Output missing %q:\n%s", want, output)
+		}
+	}
+
+	entries, _ := store.SearchRecords(knowledge.Filter{})
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 summary entry, got %d", len(entries))
+	}
+	var summary ModeSummary
+	if err := json.Unmarshal(entries[0].Content, &summary); err != nil {
+		t.Fatalf("Failed to decode summary: %v", err)
+	}
+	if summary.Mode != "standup" || len(summary.Sections) != 3 || !summary.Sections[2].Skipped {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if entries[0].Metadata["mode"] != "standup" {
+		t.Errorf("Expected mode metadata, got %v", entries[0].Metadata)
+	}
+
+	if c.activeMode != nil {
+		t.Error("Expected the mode to be finished")
+	}
+}
+
+func TestModeTimeboxAndCancel(t *testing.T) {
+	c, store, out := newModeTestChat(t)
+	c.RegisterMode(ConversationMode{
+		Name:        "quick",
+		Description: "Quick check",
+		Sections: []ModeSection{
+			{Title: "First", Question: "Anything?", Timebox: 20 * time.Millisecond},
+			{Title: "Second", Question: "Anything else?"},
+		},
+	})
+
+	c.processInput("quick()", out)
+	c.processInput("triage()", out)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), "[2/2] Second") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.processInput("cancel", out)
+
+	output := out.String()
+	for _, want := range []string{
+		"A quick is already in progress.",
+		"Time's up for First, moving on.",
+		"[2/2] Second",
+		"Quick cancelled. Back to free-form chat.",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Output missing %q:\n%s", want, output)
+		}
+	}
+
+	// Cancelled modes are not stored
+	entries, _ := store.SearchRecords(knowledge.Filter{})
+	if len(entries) != 0 {
+		t.Errorf("Expected no summary entries, got %d", len(entries))
+	}
+}
@@ -0,0 +1,131 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// fence opens and closes a multi-line message, e.g. pasted requirements or code
+const fence = `"""`
+
+// continuationPrompt is shown for the lines of a fenced message after the first
+const continuationPrompt = "... "
+
+// multilineInput collects the lines of messages fenced with triple quotes, so that
+// pasted text is sent as one message rather than one per line
+type multilineInput struct {
+	lines []string
+	open  bool // A fence was opened and not closed yet
+}
+
+// add takes a line of input and returns the complete input once there is one: the line
+// itself, or the lines between the fences. Lines inside the fences are kept as they are,
+// indentation included.
+func (m *multilineInput) add(line string) (string, bool) {
+	if !m.open {
+		rest, opened := strings.CutPrefix(strings.TrimSpace(line), fence)
+		if !opened {
+			return line, true
+		}
+		if text, closed := strings.CutSuffix(rest, fence); closed {
+			return text, true
+		}
+		m.open = true
+		m.lines = m.lines[:0]
+		if rest != "" {
+			m.lines = append(m.lines, rest)
+		}
+		return "", false
+	}
+
+	if text, closed := strings.CutSuffix(strings.TrimRight(line, " \t"), fence); closed {
+		if strings.TrimSpace(text) != "" {
+			m.lines = append(m.lines, text)
+		}
+		m.open = false
+		return strings.Join(m.lines, "\n"), true
+	}
+	m.lines = append(m.lines, line)
+	return "", false
+}
+
+// flush returns the lines collected so far and the line, ending the fenced message
+func (m *multilineInput) flush(line string) string {
+	lines := m.lines
+	if !m.open {
+		lines = nil
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	m.open = false
+	m.lines = nil
+	return strings.Join(lines, "\n")
+}
+
+// editorCommand returns the user's editor, from $VISUAL or $EDITOR, or vi
+func editorCommand() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(name)); editor != "" {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+// editMessage opens the user's editor on a temporary file holding the initial text and
+// returns what was saved, trimmed. The editor command runs in the shell, so it can have
+// arguments, e.g. EDITOR="code --wait".
+func editMessage(initial string, stdin io.Reader, stdout io.Writer) (string, error) {
+	file, err := os.CreateTemp("", "message-*.md")
+	if err != nil {
+		return "", fmt.Errorf("failed to create message file: %w", err)
+	}
+	path := file.Name()
+	defer os.Remove(path)
+	_, err = file.WriteString(initial)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write message file: %w", err)
+	}
+
+	editor := editorCommand()
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stdout
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", editor, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read message file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// errEmptyMessage is returned when the editor was closed without writing a message
+var errEmptyMessage = errors.New("message is empty, nothing sent")
+
+// composeInEditor has the user write a message in their editor, starting from the
+// initial text, and sends it to the agent
+func (c *EnhancedChat) composeInEditor(initial string, out io.Writer) {
+	text, err := editMessage(initial, os.Stdin, out)
+	if err == nil && text == "" {
+		err = errEmptyMessage
+	}
+	if err != nil {
+		c.logger.Warn("Message not composed in editor", "error", err)
+		fmt.Fprintln(out, err)
+		return
+	}
+	c.logger.Debug("Message composed in editor", "content_length", len(text))
+	c.processInput(text, out)
+}
@@ -0,0 +1,61 @@
+package chat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMultilineInput(t *testing.T) {
+	var m multilineInput
+	lines := []string{
+		`"""Requirements:`,
+		"- Export reports as CSV",
+		"    func export() {}",
+		"",
+		`- Schedule them weekly"""`,
+	}
+	var got string
+	for i, line := range lines {
+		text, complete := m.add(line)
+		if complete != (i == len(lines)-1) {
+			t.Fatalf("Unexpected completion after line %d", i)
+		}
+		got = text
+	}
+	want := "Requirements:\n- Export reports as CSV\n    func export() {}\n\n- Schedule them weekly"
+	if got != want {
+		t.Errorf("Expected the fenced lines as one message, got %q", got)
+	}
+
+	if text, complete := m.add("Hello"); !complete || text != "Hello" {
+		t.Errorf("Expected a plain line to be complete, got %q", text)
+	}
+	if text, complete := m.add(`"""One line"""`); !complete || text != "One line" {
+		t.Errorf("Expected a fenced line to be complete, got %q", text)
+	}
+
+	m.add(`"""`)
+	m.add("draft")
+	if text := m.flush("more"); text != "draft\nmore" || m.open {
+		t.Errorf("Expected flush to end the fenced message, got %q", text)
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", `printf 'Line one\nLine two\n' >>`)
+
+	text, err := editMessage("Draft:\n", strings.NewReader(""), &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Failed to edit message: %v", err)
+	}
+	if text != "Draft:\nLine one\nLine two" {
+		t.Errorf("Expected the saved message, got %q", text)
+	}
+
+	t.Setenv("EDITOR", "false")
+	if _, err := editMessage("", strings.NewReader(""), &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error when the editor fails")
+	}
+}
@@ -0,0 +1,64 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"goproduct/internal/presence"
+)
+
+// SetPresence lets the chat tell whether the agent is reachable before a message is sent
+// to it, and list the presence of entities with /presence
+func (c *EnhancedChat) SetPresence(registry *presence.Registry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.presence = registry
+}
+
+// presenceNotice returns a warning for a message about to be sent if the agent is offline,
+// or busy with messages other than the user's, and "" otherwise
+func (c *EnhancedChat) presenceNotice() string {
+	c.mutex.RLock()
+	registry, pending := c.presence, len(c.pendingMsgs)
+	c.mutex.RUnlock()
+	if registry == nil {
+		return ""
+	}
+
+	entry := registry.Get(c.agent.ID())
+	switch {
+	case entry.Status == presence.StatusOffline && entry.LastSeen.IsZero():
+		return fmt.Sprintf("%s is offline; the reply may not come.", c.agent.Name())
+	case entry.Status == presence.StatusOffline:
+		return fmt.Sprintf("%s appears to be offline (last heard from %s ago); the reply may not come.",
+			c.agent.Name(), time.Since(entry.LastSeen).Round(time.Second))
+	case entry.Status == presence.StatusBusy && pending == 0:
+		return fmt.Sprintf("%s is busy with other messages; the reply may take a while.", c.agent.Name())
+	}
+	return ""
+}
+
+// presenceReport lists the entities publishing heartbeats and whether they are reachable
+func (c *EnhancedChat) presenceReport() string {
+	c.mutex.RLock()
+	registry := c.presence
+	c.mutex.RUnlock()
+	if registry == nil {
+		return "Presence is not tracked."
+	}
+	entries := registry.Entries()
+	if len(entries) == 0 {
+		return "No entity has reported its presence yet."
+	}
+
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tLAST HEARD")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s ago\n", entry.Name, entry.Status, time.Since(entry.LastSeen).Round(time.Second))
+	}
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,80 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/entity"
+	"goproduct/internal/messaging"
+	"goproduct/internal/presence"
+	"goproduct/internal/tracing"
+)
+
+func TestPresenceNotice(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	agent := entity.NewCliHumanEntity("Andy", bus)
+	c := NewEnhancedChat(entity.NewCliHumanEntity("User", bus), agent, bus, tracing.NewMemoryTracer())
+	c.IsTestMode = true
+	defer c.cancel()
+	out := &syncBuffer{}
+
+	c.processInput("/presence", out)
+	if !strings.Contains(out.String(), "Presence is not tracked.") {
+		t.Errorf("Expected presence not tracked, got %q", out.String())
+	}
+
+	registry := presence.NewRegistry(bus)
+	if err := registry.Start(); err != nil {
+		t.Fatalf("Failed to start registry: %v", err)
+	}
+	defer registry.Stop()
+	c.SetPresence(registry)
+
+	// waitFor waits until the agent has the status in the registry
+	waitFor := func(want presence.Status) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for registry.Get(agent.ID()).Status != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the agent %s, got %+v", want, registry.Get(agent.ID()))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// The agent publishes no heartbeat yet, so nothing is known of it
+	c.processInput("Hello", out)
+	if strings.Contains(out.String(), "Andy") {
+		t.Errorf("Expected no notice for an agent without heartbeats, got %q", out.String())
+	}
+
+	heartbeat := presence.NewHeartbeat(bus, agent, presence.WithInterval(10*time.Millisecond))
+	heartbeat.Start(context.Background())
+	agent.SetStatus(entity.StatusBusy)
+	waitFor(presence.StatusBusy)
+	c.processInput("/presence", out)
+	if !strings.Contains(out.String(), "Andy  busy") {
+		t.Errorf("Expected Andy listed as busy, got %q", out.String())
+	}
+
+	// Busy with other messages once the first one timed out, then with the chat's own
+	select {
+	case <-c.responses:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the first message to time out")
+	}
+	c.processInput("Still there?", out)
+	c.processInput("Hurry up", out)
+	if got := strings.Count(out.String(), "Andy is busy with other messages"); got != 1 {
+		t.Errorf("Expected one busy notice, before the chat had pending messages, got %q", out.String())
+	}
+
+	heartbeat.Stop()
+	waitFor(presence.StatusOffline)
+	c.processInput("Hello again", out)
+	if !strings.Contains(out.String(), "Andy appears to be offline (last heard from") {
+		t.Errorf("Expected an offline notice, got %q", out.String())
+	}
+}
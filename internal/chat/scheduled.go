@@ -0,0 +1,38 @@
+package chat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"goproduct/internal/messaging"
+	"goproduct/internal/scheduler"
+)
+
+// receive handles the messages sent to the user outside of replies, printing the results
+// of the tasks the agent was scheduled to do
+func (c *EnhancedChat) receive(msg messaging.Message) {
+	taskID := msg.Metadata[scheduler.MetadataTaskID]
+	if taskID == "" {
+		c.logger.Debug("Ignoring message that is not a reply", "message_id", msg.ID, "sender", msg.SenderID)
+		return
+	}
+	text, err := msg.TextContent()
+	if err != nil {
+		c.logger.Warn("Ignoring task result that is not text", "task_id", taskID, "error", err)
+		return
+	}
+
+	c.mutex.RLock()
+	out := c.out
+	c.mutex.RUnlock()
+	if out == nil {
+		return
+	}
+	name := c.senderName(msg.SenderID)
+	c.logger.Info("Task result received", "task_id", taskID, "sender", msg.SenderID)
+	c.typing().clear()
+	fmt.Fprintf(out, "%s (scheduled: %s): %s\n\n", name, msg.Metadata[scheduler.MetadataTaskPrompt], c.renderReply(text))
+	c.recordMessage(sessionMessage{ID: uuid.New().String(), Speaker: name, Role: "assistant", Text: text, Timestamp: time.Now()})
+}
@@ -0,0 +1,35 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"goproduct/internal/entity"
+	"goproduct/internal/messaging"
+	"goproduct/internal/scheduler"
+	"goproduct/internal/tracing"
+)
+
+func TestScheduledTaskResult(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	human := entity.NewCliHumanEntity("User", bus)
+	agent := entity.NewCliHumanEntity("Andy", bus)
+	c := NewEnhancedChat(human, agent, bus, tracing.NewMemoryTracer())
+	c.IsTestMode = true
+	defer c.cancel()
+	out := &syncBuffer{}
+	c.out = out
+
+	c.receive(messaging.NewTextMessage("someone", []string{human.ID()}, "Not a task"))
+	result := messaging.NewTextMessage(agent.ID(), []string{human.ID()}, "Two decisions today.")
+	result.Metadata[scheduler.MetadataTaskID] = "1a2b3c4d"
+	result.Metadata[scheduler.MetadataTaskPrompt] = "Summarize today's decisions"
+	c.receive(result)
+
+	if got := out.String(); got != "Andy (scheduled: Summarize today's decisions): Two decisions today.\n\n" {
+		t.Errorf("Expected only the task result printed, got %q", got)
+	}
+	if len(c.sessionLog) != 1 || !strings.Contains(c.sessionLog[0].Text, "Two decisions") {
+		t.Errorf("Expected the result in the session, got %+v", c.sessionLog)
+	}
+}
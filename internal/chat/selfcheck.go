@@ -0,0 +1,66 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"goproduct/internal/agent"
+	"goproduct/internal/entity"
+)
+
+// selfCheckTimeout bounds how long a self-check may take; each question takes three model calls
+const selfCheckTimeout = 5 * time.Minute
+
+// selfCheck runs the agent's self-check and formats the report
+func (c *EnhancedChat) selfCheck() string {
+	checker, ok := c.agent.(entity.SelfChecker)
+	if !ok {
+		return fmt.Sprintf("%s can't run a self-check.", c.agent.Name())
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, selfCheckTimeout)
+	defer cancel()
+
+	c.logger.Info("Self-check started", "agent_id", c.agent.ID())
+	results, err := checker.SelfCheck(ctx, agent.DefaultSelfCheckQuestions)
+	if err != nil {
+		c.logger.Error("Self-check failed", "error", err, "completed", len(results))
+		c.tracer.Error("Self-check failed after %d questions: %v", len(results), err)
+		if len(results) == 0 {
+			return fmt.Sprintf("Self-check failed: %v", err)
+		}
+	}
+	if len(results) == 0 {
+		return "No high-importance knowledge to check."
+	}
+
+	passed := 0
+	var sb strings.Builder
+	sb.WriteString("Self-check results:\n")
+	for i, result := range results {
+		status := "PASS"
+		if result.Passed() {
+			passed++
+		} else {
+			status = "FAIL"
+		}
+		sb.WriteString(fmt.Sprintf("%d. [%s] %s\n", i+1, status, result.Question))
+		sb.WriteString(fmt.Sprintf("   Entry: %s\n", result.EntryID))
+		if !result.Passed() {
+			sb.WriteString(fmt.Sprintf("   Answer: %s\n", result.Answer))
+			for _, issue := range result.Issues {
+				sb.WriteString(fmt.Sprintf("   - %s\n", issue))
+			}
+		}
+	}
+	sb.WriteString(fmt.Sprintf("%d of %d questions passed", passed, len(results)))
+	if err != nil {
+		sb.WriteString(fmt.Sprintf(" (stopped early: %v)", err))
+	}
+
+	c.logger.Info("Self-check finished", "agent_id", c.agent.ID(), "passed", passed, "total", len(results))
+	c.tracer.Info("Self-check: %d of %d questions passed", passed, len(results))
+	return sb.String()
+}
@@ -0,0 +1,185 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+
+	"goproduct/internal/commands"
+	"goproduct/internal/entity"
+	"goproduct/internal/messaging"
+)
+
+// threadUsage lists the subcommands of /thread
+const threadUsage = "new [topic] | list | switch <thread> | close [thread]"
+
+// chatThread is a topic of conversation with the agent. Messages carry its ID in their
+// metadata and agents keep a separate history for each thread.
+type chatThread struct {
+	ID       string // Empty for the main thread
+	Number   int    // Refers to the thread in commands; the main thread is 1
+	Topic    string
+	Started  time.Time
+	Messages int // Messages sent in the thread
+}
+
+// label names the thread for the user, e.g. `2 "pricing"`
+func (t *chatThread) label() string {
+	return fmt.Sprintf("%d %q", t.Number, t.Topic)
+}
+
+// threadCommand returns the /thread command
+func (c *EnhancedChat) threadCommand() commands.Command {
+	return commands.Command{
+		Name:        "thread",
+		Usage:       threadUsage,
+		Description: "Keep several topics apart, each with its own context for the agent",
+		Help: "e.g. /thread new pricing, then /thread switch 1 to go back to the main thread\n" +
+			"Threads are given by number or topic. Replies in other threads are labelled with theirs.",
+		MinArgs: 1,
+		MaxArgs: -1,
+		Handler: func(ctx context.Context, inv commands.Invocation) (string, error) {
+			switch strings.ToLower(inv.Args[0]) {
+			case "new":
+				return c.newThread(inv.TextAfter(1)), nil
+			case "list":
+				if len(inv.Args) == 1 {
+					return c.listThreads(), nil
+				}
+			case "switch":
+				if len(inv.Args) > 1 {
+					return c.switchThread(inv.TextAfter(1))
+				}
+			case "close":
+				return c.closeThread(inv.TextAfter(1))
+			}
+			return "", fmt.Errorf("%w: %sthread %s", commands.ErrUsage, commands.Prefix, threadUsage)
+		},
+	}
+}
+
+// newThread starts a thread and makes it the current one
+func (c *EnhancedChat) newThread(topic string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.threadCount++
+	if topic == "" {
+		topic = fmt.Sprintf("Thread %d", c.threadCount)
+	}
+	thread := &chatThread{ID: uuid.New().String(), Number: c.threadCount, Topic: topic, Started: time.Now()}
+	c.threads = append(c.threads, thread)
+	c.thread = thread
+	c.logger.Info("Chat thread started", "thread", thread.ID, "topic", topic)
+	return fmt.Sprintf("Started thread %s; your messages go to it. /thread switch 1 returns to the main thread.", thread.label())
+}
+
+// listThreads lists the threads, marking the current one
+func (c *EnhancedChat) listThreads() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\t#\tTOPIC\tMESSAGES\tSTARTED")
+	for _, thread := range c.threads {
+		current := ""
+		if thread == c.thread {
+			current = "*"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\n", current, thread.Number, thread.Topic, thread.Messages, thread.Started.Format("15:04"))
+	}
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// switchThread makes a thread the current one
+func (c *EnhancedChat) switchThread(ref string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	thread, err := c.findThreadLocked(ref)
+	if err != nil {
+		return "", err
+	}
+	c.thread = thread
+	c.logger.Info("Chat thread switched", "thread", thread.ID, "topic", thread.Topic)
+	return fmt.Sprintf("Switched to thread %s.", thread.label()), nil
+}
+
+// closeThread ends a thread, the current one if ref is empty, and has the agent forget
+// its context. The main thread cannot be closed.
+func (c *EnhancedChat) closeThread(ref string) (string, error) {
+	c.mutex.Lock()
+	thread := c.thread
+	if ref != "" {
+		var err error
+		if thread, err = c.findThreadLocked(ref); err != nil {
+			c.mutex.Unlock()
+			return "", err
+		}
+	}
+	if thread.ID == "" {
+		c.mutex.Unlock()
+		return "", errors.New("the main thread cannot be closed")
+	}
+	for i, t := range c.threads {
+		if t == thread {
+			c.threads = append(c.threads[:i], c.threads[i+1:]...)
+			break
+		}
+	}
+	notice := fmt.Sprintf("Closed thread %s.", thread.label())
+	if c.thread == thread {
+		c.thread = c.threads[0]
+		notice += " Back to the main thread."
+	}
+	c.mutex.Unlock()
+
+	if closer, ok := c.agent.(entity.ThreadCloser); ok {
+		closer.CloseThread(thread.ID)
+	}
+	c.logger.Info("Chat thread closed", "thread", thread.ID, "topic", thread.Topic)
+	return notice, nil
+}
+
+// findThreadLocked returns the thread with the number or topic; the mutex must be held
+func (c *EnhancedChat) findThreadLocked(ref string) (*chatThread, error) {
+	number, err := strconv.Atoi(ref)
+	for _, thread := range c.threads {
+		if (err == nil && thread.Number == number) || strings.EqualFold(thread.Topic, ref) {
+			return thread, nil
+		}
+	}
+	return nil, fmt.Errorf("no thread %q, see /thread list", ref)
+}
+
+// threadMessage puts a message in the current thread
+func (c *EnhancedChat) threadMessage(msg messaging.Message) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.thread.Messages++
+	if c.thread.ID != "" {
+		msg.Metadata[messaging.MetadataThreadID] = c.thread.ID
+	}
+}
+
+// replyName returns the name a reply to the message is shown with: the agent's, and the
+// thread of the message if it is no longer the current one
+func (c *EnhancedChat) replyName(msg messaging.Message) string {
+	threadID := msg.Metadata[messaging.MetadataThreadID]
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.thread.ID == threadID {
+		return c.agent.Name()
+	}
+	for _, thread := range c.threads {
+		if thread.ID == threadID {
+			return fmt.Sprintf("%s [thread %s]", c.agent.Name(), thread.label())
+		}
+	}
+	return fmt.Sprintf("%s [closed thread]", c.agent.Name())
+}
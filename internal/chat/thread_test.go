@@ -0,0 +1,88 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/entity"
+	"goproduct/internal/messaging"
+	"goproduct/internal/tracing"
+)
+
+// threadAgent answers with the thread of each message and records the threads closed
+type threadAgent struct {
+	*entity.CliHumanEntity
+	closed []string
+}
+
+func (a *threadAgent) CloseThread(threadID string) {
+	a.closed = append(a.closed, threadID)
+}
+
+func TestThreads(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	agent := &threadAgent{CliHumanEntity: entity.NewCliHumanEntity("Andy", bus)}
+	threads := make(chan string, 10)
+	bus.Subscribe(agent.ID(), func(msg messaging.Message) error {
+		threads <- msg.Metadata[messaging.MetadataThreadID]
+		return bus.Publish(messaging.NewTextReplyMessage(agent.ID(), msg, "Noted."))
+	})
+	c := NewEnhancedChat(entity.NewCliHumanEntity("User", bus), agent, bus, tracing.NewMemoryTracer())
+	c.IsTestMode = true
+	out := &syncBuffer{}
+
+	// say sends a message and returns the thread it was sent in once answered
+	say := func(text string) string {
+		t.Helper()
+		c.processInput(text, out)
+		select {
+		case <-c.responses:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %q to be answered", text)
+		}
+		return <-threads
+	}
+
+	if thread := say("Draft the roadmap"); thread != "" {
+		t.Errorf("Expected the main thread first, got %q", thread)
+	}
+	c.processInput("/thread new Pricing tiers", out)
+	pricing := say("Three tiers?")
+	if pricing == "" {
+		t.Fatal("Expected the message in the new thread")
+	}
+
+	c.processInput("/thread switch 1", out)
+	if thread := say("Add dates"); thread != "" {
+		t.Errorf("Expected the main thread after switching back, got %q", thread)
+	}
+	c.processInput("/thread switch pricing TIERS", out)
+	if thread := say("And discounts?"); thread != pricing {
+		t.Errorf("Expected the pricing thread by topic, got %q", thread)
+	}
+
+	c.processInput("/thread list", out)
+	if !strings.Contains(out.String(), "*  2  Pricing tiers  2") {
+		t.Errorf("Expected the pricing thread current with 2 messages, got %q", out.String())
+	}
+
+	c.processInput("/thread close 1", out)
+	c.processInput("/thread close", out)
+	c.processInput("/thread switch 2", out)
+	c.processInput("/thread rename x", out)
+	for _, want := range []string{
+		"The main thread cannot be closed",
+		`Closed thread 2 "Pricing tiers". Back to the main thread.`,
+		`No thread "2", see /thread list`,
+		"Usage: /thread new [topic]",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q, got %q", want, out.String())
+		}
+	}
+	if len(agent.closed) != 1 || agent.closed[0] != pricing {
+		t.Errorf("Expected the agent to forget the pricing thread, got %q", agent.closed)
+	}
+	c.cancel()
+}
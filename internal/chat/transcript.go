@@ -0,0 +1,183 @@
+package chat
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"goproduct/internal/conversations"
+	"goproduct/internal/entity"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
+)
+
+// TranscriptTag is the tag of the knowledge entries recording the chat transcript
+const TranscriptTag = "chat-transcript"
+
+// transcriptSource tells chat transcripts apart from imported conversations
+const transcriptSource = "cli"
+
+// SetResume makes the chat continue the last session with the agent when it starts:
+// the end of its transcript is shown, the agent gets its turns back and new messages
+// are added to it
+func (c *EnhancedChat) SetResume(resume bool) {
+	c.resume = resume
+}
+
+// recordTranscript adds a message of the session to the transcript in the knowledge
+// store, if there is one. Entity IDs change between runs, so participants are named.
+func (c *EnhancedChat) recordTranscript(senderID, role, text string) {
+	if c.store == nil {
+		return
+	}
+	speaker := c.senderName(senderID)
+	c.mutex.Lock()
+	session, index := c.session, c.turns
+	c.turns++
+	c.mutex.Unlock()
+
+	ownerType := "human"
+	if role == "assistant" {
+		ownerType = "agent"
+	}
+	now := time.Now()
+	entry := knowledge.Entry{
+		ID:          uuid.New().String(),
+		Category:    knowledge.CategoryMessage,
+		ContentType: knowledge.ContentTypeText,
+		Content:     []byte(text),
+		Importance:  knowledge.ImportanceLow,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		SourceID:    session,
+		SourceType:  "chat",
+		OwnerID:     speaker,
+		OwnerType:   ownerType,
+		SubjectIDs:  []string{c.human.Name(), c.agent.Name()},
+		Tags:        []string{TranscriptTag},
+		References:  []knowledge.Reference{},
+		Metadata: map[string]string{
+			conversations.MetadataConversationID:    session,
+			conversations.MetadataConversationTitle: "Chat with " + c.agent.Name(),
+			conversations.MetadataSource:            transcriptSource,
+			conversations.MetadataSpeaker:           speaker,
+			conversations.MetadataRole:              role,
+			conversations.MetadataMessageIndex:      strconv.Itoa(index),
+		},
+	}
+	if err := c.store.AddRecord(entry); err != nil {
+		c.logger.Error("Failed to record chat transcript", "session", session, "error", err)
+		return
+	}
+	if err := c.store.Flush(); err != nil {
+		c.logger.Error("Failed to flush chat transcript", "session", session, "error", err)
+	}
+}
+
+// lastSession returns the most recent session with the agent other than the current
+// one, false if there is none
+func (c *EnhancedChat) lastSession() (conversations.Conversation, bool, error) {
+	if c.store == nil {
+		return conversations.Conversation{}, false, nil
+	}
+	sessions, err := conversations.NewRepository(c.store).ListConversations(c.human.Name(), conversations.TimeRange{})
+	if err != nil {
+		return conversations.Conversation{}, false, err
+	}
+	c.mutex.RLock()
+	current := c.session
+	c.mutex.RUnlock()
+	for _, session := range sessions {
+		if session.Source == transcriptSource && session.ID != current && slices.Contains(session.Participants, c.agent.Name()) {
+			return session, true, nil
+		}
+	}
+	return conversations.Conversation{}, false, nil
+}
+
+// resumeSession continues the last session with the agent and shows the end of it
+func (c *EnhancedChat) resumeSession(out io.Writer) {
+	session, found, err := c.lastSession()
+	if err != nil {
+		c.logger.Error("Failed to find the last chat session", "error", err)
+		fmt.Fprintf(out, "Can't resume the last session: %v\n\n", err)
+		return
+	}
+	if !found {
+		fmt.Fprintln(out, "No earlier session to resume.")
+		fmt.Fprintln(out)
+		return
+	}
+	thread, err := conversations.NewRepository(c.store).GetThread(session.ID, conversations.Page{})
+	if err != nil {
+		c.logger.Error("Failed to load the last chat session", "session", session.ID, "error", err)
+		fmt.Fprintf(out, "Can't resume the last session: %v\n\n", err)
+		return
+	}
+
+	// Later messages continue the transcript of the session
+	c.mutex.Lock()
+	c.session = session.ID
+	c.turns = thread.Total
+	c.mutex.Unlock()
+
+	if restorer, ok := c.agent.(entity.HistoryRestorer); ok {
+		turns := make([]llm.Message, 0, len(thread.Messages))
+		for _, message := range thread.Messages {
+			turns = append(turns, llm.Message{Role: message.Role, Content: message.Text})
+		}
+		restorer.RestoreHistory(turns)
+	}
+	c.logger.Info("Chat session resumed", "session", session.ID, "messages", thread.Total)
+	c.tracer.Info("Chat session %s resumed with %d messages", session.ID, thread.Total)
+
+	fmt.Fprintf(out, "Resuming the session of %s (%d messages):\n", session.StartedAt.Format("Monday, January 2 at 15:04"), thread.Total)
+	fmt.Fprintln(out, formatTranscript(thread.Messages, historyLimit))
+	fmt.Fprintln(out)
+}
+
+// transcriptHistory lists the end of the current session's transcript, or of the last
+// session if nothing was said yet
+func (c *EnhancedChat) transcriptHistory() string {
+	c.mutex.RLock()
+	current, turns := c.session, c.turns
+	c.mutex.RUnlock()
+
+	header := "Recent messages:"
+	if turns == 0 {
+		session, found, err := c.lastSession()
+		if err != nil {
+			c.logger.Error("Failed to find the last chat session", "error", err)
+			return fmt.Sprintf("Failed to load message history: %v", err)
+		}
+		if !found {
+			return "No recent messages."
+		}
+		current = session.ID
+		header = fmt.Sprintf("Last session, %s (start with --resume to continue it):", session.StartedAt.Format("Monday, January 2 at 15:04"))
+	}
+
+	thread, err := conversations.NewRepository(c.store).GetThread(current, conversations.Page{})
+	if err != nil {
+		c.logger.Error("Failed to load chat transcript", "session", current, "error", err)
+		return fmt.Sprintf("Failed to load message history: %v", err)
+	}
+	return header + "\n" + formatTranscript(thread.Messages, historyLimit)
+}
+
+// formatTranscript lists up to limit of the last messages, oldest first
+func formatTranscript(messages []conversations.Message, limit int) string {
+	if len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	var sb strings.Builder
+	for _, message := range messages {
+		sb.WriteString(fmt.Sprintf("  [%s] %s: %s\n", message.Timestamp.Format("15:04"), message.Speaker, message.Text))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
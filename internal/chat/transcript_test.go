@@ -0,0 +1,71 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"goproduct/internal/entity"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
+	"goproduct/internal/messaging"
+	"goproduct/internal/tracing"
+)
+
+// restoringAgent records the history it is given to restore
+type restoringAgent struct {
+	*entity.CliHumanEntity
+	restored []llm.Message
+}
+
+func (a *restoringAgent) RestoreHistory(messages []llm.Message) {
+	a.restored = messages
+}
+
+// newTranscriptTestChat creates a chat of a new run against the store, with new entity IDs
+func newTranscriptTestChat(store knowledge.Store) (*EnhancedChat, *restoringAgent) {
+	bus := messaging.NewMemoryMessageBus()
+	agent := &restoringAgent{CliHumanEntity: entity.NewCliHumanEntity("Andy", bus)}
+	c := NewEnhancedChat(entity.NewCliHumanEntity("User", bus), agent, bus, tracing.NewMemoryTracer())
+	c.SetKnowledgeStore(store)
+	return c, agent
+}
+
+func TestResumeSession(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	first, _ := newTranscriptTestChat(store)
+	first.recordTranscript(first.human.ID(), "user", "Which metric matters most for onboarding?")
+	first.recordTranscript(first.agent.ID(), "assistant", "Activation within the first week.")
+
+	second, agent := newTranscriptTestChat(store)
+	if history := second.history(); !strings.Contains(history, "Last session") || !strings.Contains(history, "User: Which metric") {
+		t.Errorf("Expected history() to show the last session, got %q", history)
+	}
+
+	out := &syncBuffer{}
+	second.resumeSession(out)
+	if !strings.Contains(out.String(), "(2 messages)") || !strings.Contains(out.String(), "Andy: Activation within the first week.") {
+		t.Errorf("Expected the transcript of the last session, got %q", out.String())
+	}
+	if len(agent.restored) != 2 || agent.restored[0].Role != "user" || agent.restored[1].Content != "Activation within the first week." {
+		t.Errorf("Expected the agent to get its turns back, got %+v", agent.restored)
+	}
+
+	// The resumed session goes on in the same transcript
+	second.recordTranscript(second.human.ID(), "user", "And for retention?")
+	if second.session != first.session {
+		t.Errorf("Expected the session %s to continue, got %s", first.session, second.session)
+	}
+	if history := second.history(); !strings.Contains(history, "Recent messages:") || !strings.Contains(history, "And for retention?") {
+		t.Errorf("Expected the continued session in history(), got %q", history)
+	}
+
+	third, _ := newTranscriptTestChat(knowledge.Store(nil))
+	third.resumeSession(out)
+	if !strings.Contains(out.String(), "No earlier session to resume.") {
+		t.Errorf("Expected no session without a store, got %q", out.String())
+	}
+}
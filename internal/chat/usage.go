@@ -0,0 +1,77 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"goproduct/internal/llm"
+)
+
+// SetUsageLedger sets the ledger the /usage command reports on
+func (c *EnhancedChat) SetUsageLedger(ledger *llm.UsageLedger) {
+	c.usage = ledger
+}
+
+// usageReport formats the language model usage since the start of the session
+func (c *EnhancedChat) usageReport() string {
+	if c.usage == nil {
+		return "Usage is not tracked."
+	}
+	report := c.usage.Report()
+	if report.Total.Calls == 0 {
+		return "No language model calls yet."
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Language model usage since %s:\n", report.Since.Format("15:04:05")))
+	sb.WriteString(fmt.Sprintf("  Total: %s\n", formatUsage(report.Total)))
+	sections := []struct {
+		title  string
+		totals map[string]llm.UsageTotals
+	}{
+		{"By model", report.ByModel},
+		{"By agent", report.ByEntity},
+		{"By conversation", report.ByConversation},
+	}
+	for _, section := range sections {
+		sb.WriteString(section.title + ":\n")
+		keys := make([]string, 0, len(section.totals))
+		for key := range section.totals {
+			keys = append(keys, key)
+		}
+		// Biggest consumers first
+		sort.Slice(keys, func(i, j int) bool {
+			return section.totals[keys[i]].TotalTokens > section.totals[keys[j]].TotalTokens
+		})
+		for _, key := range keys {
+			sb.WriteString(fmt.Sprintf("  %s: %s\n", c.usageName(key), formatUsage(section.totals[key])))
+		}
+	}
+	if report.Total.EstimatedCalls > 0 {
+		sb.WriteString(fmt.Sprintf("Tokens of %d calls are estimated; their provider did not report usage.", report.Total.EstimatedCalls))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// usageName names the key of a usage total: the user for their entity ID, "(none)" for
+// calls without one
+func (c *EnhancedChat) usageName(key string) string {
+	switch key {
+	case "":
+		return "(none)"
+	case c.human.ID():
+		return c.human.Name()
+	}
+	return key
+}
+
+// formatUsage formats the totals of a usage report line
+func formatUsage(totals llm.UsageTotals) string {
+	calls := "calls"
+	if totals.Calls == 1 {
+		calls = "call"
+	}
+	return fmt.Sprintf("%d %s, %d tokens (%d prompt, %d completion), $%.4f",
+		totals.Calls, calls, totals.TotalTokens, totals.PromptTokens, totals.CompletionTokens, totals.Cost)
+}
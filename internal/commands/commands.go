@@ -0,0 +1,277 @@
+// Package commands parses and runs the commands typed at a chat prompt, such as
+// "/language es" or "/image mockup.png Is this clear?". Commands are registered with a
+// Registry, so any module can contribute its own; the registry validates their
+// arguments and answers "/help" and "/help <command>".
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Prefix starts a command
+const Prefix = "/"
+
+// ErrUnknownCommand is returned when running a command that is not registered
+var ErrUnknownCommand = errors.New("unknown command")
+
+// ErrUsage is returned when a command is given the wrong number of arguments, or
+// arguments its handler rejects
+var ErrUsage = errors.New("usage")
+
+// ErrQuit is returned by a command that ends the chat, along with its farewell
+var ErrQuit = errors.New("quit")
+
+// Invocation is a command as typed
+type Invocation struct {
+	Name string    // Command name as typed, without the prefix; may be an alias
+	Args []string  // Arguments, split on spaces unless quoted
+	Raw  string    // Text after the command name, as typed
+	Out  io.Writer // Output of the chat, for commands that report later; may be nil
+
+	ends []int // Offset in Raw of the end of each argument
+}
+
+// TextAfter returns the text typed after the first n arguments, e.g. the question after
+// an image path, with its quotes and spacing kept
+func (inv Invocation) TextAfter(n int) string {
+	if n <= 0 {
+		return strings.TrimSpace(inv.Raw)
+	}
+	if n > len(inv.ends) {
+		return ""
+	}
+	return strings.TrimSpace(inv.Raw[inv.ends[n-1]:])
+}
+
+// Handler runs a command and returns its reply
+type Handler func(ctx context.Context, inv Invocation) (string, error)
+
+// Command is a command typed as "/name arguments"
+type Command struct {
+	Name        string   // Word that invokes the command, e.g. "language"
+	Aliases     []string // Other words that invoke it, e.g. "quit" for "exit"
+	Usage       string   // Arguments, e.g. "<code>"; empty if there are none
+	Description string   // One-line description listed by /help
+	Help        string   // Details shown by /help <name>, e.g. examples
+	MinArgs     int      // Fewest arguments the command takes
+	MaxArgs     int      // Most arguments the command takes; negative for no limit
+	Handler     Handler  // Runs the command
+}
+
+// usage returns the command line showing the command's arguments
+func (c Command) usage() string {
+	if c.Usage == "" {
+		return Prefix + c.Name
+	}
+	return Prefix + c.Name + " " + c.Usage
+}
+
+// namePattern is what command names and aliases look like
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// legacyPattern matches the call syntax commands were typed with before the prefix,
+// e.g. "language(es)" or "image(mockup.png) Is this clear?"
+var legacyPattern = regexp.MustCompile(`^([a-z][a-z0-9_-]*)\(([^)]*)\)(.*)$`)
+
+// Registry holds the commands of a chat. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	commands map[string]Command // By name
+	aliases  map[string]string  // Command names by alias
+}
+
+// NewRegistry creates a registry; the help command is built in
+func NewRegistry() *Registry {
+	r := &Registry{
+		commands: make(map[string]Command),
+		aliases:  make(map[string]string),
+	}
+	r.Register(Command{
+		Name:        "help",
+		Usage:       "[command]",
+		Description: "List the commands, or show the details of one",
+		MaxArgs:     1,
+		Handler:     r.help,
+	})
+	return r
+}
+
+// Register adds a command, replacing any command with the same name
+func (r *Registry) Register(command Command) error {
+	command.Name = strings.ToLower(strings.TrimPrefix(command.Name, Prefix))
+	if !namePattern.MatchString(command.Name) {
+		return fmt.Errorf("invalid command name %q", command.Name)
+	}
+	if command.Handler == nil {
+		return fmt.Errorf("command %s has no handler", command.Name)
+	}
+	command.Aliases = append([]string(nil), command.Aliases...)
+	for i, alias := range command.Aliases {
+		command.Aliases[i] = strings.ToLower(alias)
+		if !namePattern.MatchString(command.Aliases[i]) {
+			return fmt.Errorf("invalid alias %q of command %s", alias, command.Name)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if previous, exists := r.commands[command.Name]; exists {
+		for _, alias := range previous.Aliases {
+			delete(r.aliases, alias)
+		}
+	}
+	r.commands[command.Name] = command
+	for _, alias := range command.Aliases {
+		r.aliases[alias] = command.Name
+	}
+	return nil
+}
+
+// Lookup returns the command with the name or alias, ignoring case and the prefix
+func (r *Registry) Lookup(name string) (Command, bool) {
+	name = strings.ToLower(strings.TrimPrefix(name, Prefix))
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if target, isAlias := r.aliases[name]; isAlias {
+		name = target
+	}
+	command, exists := r.commands[name]
+	return command, exists
+}
+
+// Commands returns the registered commands sorted by name
+func (r *Registry) Commands() []Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	commands := make([]Command, 0, len(r.commands))
+	for _, command := range r.commands {
+		commands = append(commands, command)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// Parse reads a command from a line of input. Lines starting with the prefix are
+// commands, known or not; so are calls of registered commands in the older syntax, e.g.
+// "exit()" or "language(es)". Other lines are not.
+func (r *Registry) Parse(line string) (Invocation, bool) {
+	line = strings.TrimSpace(line)
+	if body, ok := strings.CutPrefix(line, Prefix); ok {
+		name, raw, _ := strings.Cut(body, " ")
+		if name == "" {
+			return Invocation{}, false
+		}
+		return newInvocation(name, raw), true
+	}
+
+	match := legacyPattern.FindStringSubmatch(line)
+	if match == nil {
+		return Invocation{}, false
+	}
+	if _, exists := r.Lookup(match[1]); !exists {
+		return Invocation{}, false
+	}
+	inner := strings.Trim(strings.TrimSpace(match[2]), `"'`)
+	if strings.ContainsAny(inner, " \t") {
+		inner = strconv.Quote(inner)
+	}
+	return newInvocation(match[1], strings.TrimSpace(inner+" "+match[3])), true
+}
+
+// IsCommand reports whether a line of input is a command
+func (r *Registry) IsCommand(line string) bool {
+	_, ok := r.Parse(line)
+	return ok
+}
+
+// Execute runs a parsed command after checking its number of arguments
+func (r *Registry) Execute(ctx context.Context, inv Invocation) (string, error) {
+	command, exists := r.Lookup(inv.Name)
+	if !exists {
+		return "", fmt.Errorf("%w %s%s, type %shelp for the list of commands", ErrUnknownCommand, Prefix, inv.Name, Prefix)
+	}
+	if len(inv.Args) < command.MinArgs || (command.MaxArgs >= 0 && len(inv.Args) > command.MaxArgs) {
+		return "", fmt.Errorf("%w: %s", ErrUsage, command.usage())
+	}
+	return command.Handler(ctx, inv)
+}
+
+// help lists the commands, or describes the one named in the arguments
+func (r *Registry) help(ctx context.Context, inv Invocation) (string, error) {
+	var sb strings.Builder
+	if len(inv.Args) == 1 {
+		command, exists := r.Lookup(inv.Args[0])
+		if !exists {
+			return "", fmt.Errorf("%w %s%s", ErrUnknownCommand, Prefix, strings.TrimPrefix(inv.Args[0], Prefix))
+		}
+		sb.WriteString(fmt.Sprintf("%s\n  %s\n", command.usage(), command.Description))
+		if command.Help != "" {
+			for _, line := range strings.Split(strings.TrimSpace(command.Help), "\n") {
+				sb.WriteString("  " + line + "\n")
+			}
+		}
+		if len(command.Aliases) > 0 {
+			sb.WriteString(fmt.Sprintf("  Also: %s%s\n", Prefix, strings.Join(command.Aliases, ", "+Prefix)))
+		}
+		return strings.TrimSuffix(sb.String(), "\n"), nil
+	}
+
+	sb.WriteString("Available commands:\n")
+	for _, command := range r.Commands() {
+		sb.WriteString(fmt.Sprintf("  %s - %s\n", command.usage(), command.Description))
+	}
+	sb.WriteString(fmt.Sprintf("Type %shelp <command> for details.", Prefix))
+	return sb.String(), nil
+}
+
+// newInvocation splits the text after a command name into arguments
+func newInvocation(name, raw string) Invocation {
+	inv := Invocation{Name: name, Raw: raw}
+	inv.Args, inv.ends = splitArgs(raw)
+	return inv
+}
+
+// splitArgs splits text on spaces, keeping text in double or single quotes together, and
+// returns the arguments with the offset in the text of the end of each
+func splitArgs(text string) ([]string, []int) {
+	var args []string
+	var ends []int
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	for i, ch := range text {
+		switch {
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(ch)
+		case (ch == '"' || ch == '\'') && !inArg:
+			// Quotes only open at the start of an argument, so "what's" is one word
+			quote = ch
+			inArg = true
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inArg {
+				args = append(args, current.String())
+				ends = append(ends, i)
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(ch)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+		ends = append(ends, len(text))
+	}
+	return args, ends
+}
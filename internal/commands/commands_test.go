@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// echo replies with the arguments it was given, one per line
+func echo(ctx context.Context, inv Invocation) (string, error) {
+	return strings.Join(inv.Args, "\n"), nil
+}
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r := NewRegistry()
+	commands := []Command{
+		{Name: "exit", Aliases: []string{"Quit"}, Description: "Exit", Handler: func(ctx context.Context, inv Invocation) (string, error) {
+			return "Goodbye!", ErrQuit
+		}},
+		{Name: "language", Usage: "[code]", Description: "Switch language", MaxArgs: 1, Handler: echo},
+		{Name: "image", Usage: "<path> [question]", Description: "Send an image", Help: "e.g. /image mockup.png Is this clear?", MinArgs: 1, MaxArgs: -1,
+			Handler: func(ctx context.Context, inv Invocation) (string, error) {
+				return inv.Args[0] + "|" + inv.TextAfter(1), nil
+			}},
+	}
+	for _, command := range commands {
+		if err := r.Register(command); err != nil {
+			t.Fatalf("Failed to register %s: %v", command.Name, err)
+		}
+	}
+	return r
+}
+
+func TestParseAndExecute(t *testing.T) {
+	r := newTestRegistry(t)
+	tests := map[string]string{
+		"/language es":                              "es",
+		"language(es)":                              "es",
+		`/image "my mockup.png" What's unclear?`:    "my mockup.png|What's unclear?",
+		"image(mockup.png) Is this  layout clear?":  "mockup.png|Is this  layout clear?",
+		"/IMAGE shot.png":                           "shot.png|",
+		`image("my mockup.png") Too busy?`:          "my mockup.png|Too busy?",
+		"/language 'pt BR'":                         "pt BR",
+		"/image 'unterminated quote.png and more'x": "unterminated quote.png and morex|",
+	}
+	for line, want := range tests {
+		inv, ok := r.Parse(line)
+		if !ok {
+			t.Errorf("Expected %q to be a command", line)
+			continue
+		}
+		got, err := r.Execute(context.Background(), inv)
+		if err != nil || got != want {
+			t.Errorf("%q: expected %q, got %q (%v)", line, want, got, err)
+		}
+	}
+
+	for _, line := range []string{"Hello", "what about now()?", "unknown(arg)", "/", ""} {
+		if r.IsCommand(line) {
+			t.Errorf("Expected %q not to be a command", line)
+		}
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	r := newTestRegistry(t)
+	run := func(line string) (string, error) {
+		inv, _ := r.Parse(line)
+		return r.Execute(context.Background(), inv)
+	}
+
+	if _, err := run("/image"); !errors.Is(err, ErrUsage) || !strings.Contains(err.Error(), "/image <path> [question]") {
+		t.Errorf("Expected a usage error for a missing argument, got %v", err)
+	}
+	if _, err := run("/language es fr"); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected a usage error for an extra argument, got %v", err)
+	}
+	if _, err := run("/deploy"); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("Expected an unknown command error, got %v", err)
+	}
+	if reply, err := run("/quit"); !errors.Is(err, ErrQuit) || reply != "Goodbye!" {
+		t.Errorf("Expected the alias to quit, got %q %v", reply, err)
+	}
+	if reply, err := run("exit()"); !errors.Is(err, ErrQuit) || reply != "Goodbye!" {
+		t.Errorf("Expected the older syntax to quit, got %q %v", reply, err)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	r := newTestRegistry(t)
+	inv, _ := r.Parse("/help")
+	list, err := r.Execute(context.Background(), inv)
+	if err != nil {
+		t.Fatalf("Failed to run help: %v", err)
+	}
+	for _, want := range []string{"/exit - Exit", "/help [command]", "/image <path> [question] - Send an image", "/language [code]"} {
+		if !strings.Contains(list, want) {
+			t.Errorf("Expected the list to contain %q, got:\n%s", want, list)
+		}
+	}
+	if strings.Index(list, "/exit") > strings.Index(list, "/language") {
+		t.Error("Expected the commands sorted by name")
+	}
+
+	inv, _ = r.Parse("/help /exit")
+	details, _ := r.Execute(context.Background(), inv)
+	if !strings.Contains(details, "Also: /quit") {
+		t.Errorf("Expected the aliases in the details, got:\n%s", details)
+	}
+	inv, _ = r.Parse("/help image")
+	details, _ = r.Execute(context.Background(), inv)
+	if !strings.Contains(details, "e.g. /image mockup.png Is this clear?") {
+		t.Errorf("Expected the help text in the details, got:\n%s", details)
+	}
+}
+
+func TestRegisterValidates(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(Command{Name: "two words", Handler: echo}); err == nil {
+		t.Error("Expected an error for a name with a space")
+	}
+	if err := r.Register(Command{Name: "noop"}); err == nil {
+		t.Error("Expected an error for a command without a handler")
+	}
+	if err := r.Register(Command{Name: "/Stats", Aliases: []string{"st"}, Handler: echo}); err != nil {
+		t.Fatalf("Failed to register a command: %v", err)
+	}
+	if _, ok := r.Lookup("ST"); !ok {
+		t.Error("Expected the command to be found by its alias, ignoring case")
+	}
+	r.Register(Command{Name: "stats", Handler: echo})
+	if _, ok := r.Lookup("st"); ok {
+		t.Error("Expected the alias to go with the replaced command")
+	}
+}
@@ -0,0 +1,215 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"goproduct/internal/logging"
+)
+
+// Component is a part of the application whose lifecycle the runtime context manages: it
+// is started with the others by Start, in the order registered, stopped by Shutdown and
+// reported on by Health. Each function is optional.
+type Component struct {
+	Name     string
+	Stage    ShutdownStage // Stage of Shutdown stopping the component, StageEntities by default
+	Optional bool          // A component failing to start is left stopped rather than failing Start
+	Start    func(ctx context.Context) error
+	Stop     func(ctx context.Context) error
+	Health   func(ctx context.Context) error // Why the running component does not work, nil if it does
+}
+
+// componentState is where a component is in its lifecycle
+type componentState int
+
+const (
+	componentRegistered componentState = iota
+	componentRunning
+	componentFailed
+	componentStopped
+)
+
+// component is a registered component and its state
+type component struct {
+	Component
+	state componentState
+	err   error // Why the component failed to start
+}
+
+// HealthStatus is the health of a component or of the application
+type HealthStatus string
+
+const (
+	HealthUp       HealthStatus = "up"       // Everything works
+	HealthDegraded HealthStatus = "degraded" // Optional components do not work
+	HealthDown     HealthStatus = "down"     // A required component does not work
+)
+
+// ComponentHealth is the health of a component
+type ComponentHealth struct {
+	Name     string       `json:"name"`
+	Status   HealthStatus `json:"status"`
+	Optional bool         `json:"optional,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// HealthReport is the health of the application, from the health of its components
+type HealthReport struct {
+	Status     HealthStatus      `json:"status"`
+	Components []ComponentHealth `json:"components"`
+}
+
+// Register adds a component, started by the next call to Start after those registered
+// before it. Component names are unique.
+func (r *RuntimeContext) Register(c Component) error {
+	if c.Name == "" {
+		return errors.New("component name is required")
+	}
+
+	r._sync.Lock()
+	defer r._sync.Unlock()
+
+	for _, registered := range r._components {
+		if registered.Name == c.Name {
+			return fmt.Errorf("component %s is already registered", c.Name)
+		}
+	}
+	registered := &component{Component: c}
+	r._components = append(r._components, registered)
+	r._started = false
+	r._steps = append(r._steps, shutdownStep{stage: c.Stage, name: c.Name, stop: func(ctx context.Context) error {
+		return r.stopComponent(ctx, registered)
+	}})
+	return nil
+}
+
+// Start starts the components registered since the last call, in the order registered.
+// It stops at the first required component that fails to start and returns its error;
+// the components already started keep running until Shutdown.
+func (r *RuntimeContext) Start(ctx context.Context) error {
+	for _, c := range r.componentsIn(componentRegistered) {
+		var err error
+		if c.Start != nil {
+			err = c.Start(ctx)
+		}
+
+		r._sync.Lock()
+		if err != nil {
+			c.state, c.err = componentFailed, err
+		} else {
+			c.state = componentRunning
+		}
+		r._sync.Unlock()
+
+		switch {
+		case err == nil:
+			logging.Get().Debug("Component started", "component", c.Name)
+		case c.Optional:
+			logging.Get().Warn("Optional component not started", "component", c.Name, "error", err)
+		default:
+			return fmt.Errorf("start %s: %w", c.Name, err)
+		}
+	}
+
+	r._sync.Lock()
+	r._started = len(r.componentsInLocked(componentRegistered)) == 0
+	r._sync.Unlock()
+	return nil
+}
+
+// Ready reports whether the components registered have been started, and the runtime
+// context is not shutting down
+func (r *RuntimeContext) Ready() bool {
+	r._sync.Lock()
+	defer r._sync.Unlock()
+
+	return r._started && !r._stopping
+}
+
+// Running reports whether the named component has started and not stopped
+func (r *RuntimeContext) Running(name string) bool {
+	r._sync.Lock()
+	defer r._sync.Unlock()
+
+	for _, c := range r._components {
+		if c.Name == name {
+			return c.state == componentRunning
+		}
+	}
+	return false
+}
+
+// Health checks the components, in the order registered. The application is down if a
+// required component is, degraded if only optional ones are.
+func (r *RuntimeContext) Health(ctx context.Context) HealthReport {
+	report := HealthReport{Status: HealthUp}
+	for _, c := range r.componentsIn(componentRegistered, componentRunning, componentFailed, componentStopped) {
+		health := ComponentHealth{Name: c.Name, Status: HealthUp, Optional: c.Optional}
+		if err := r.componentError(ctx, c); err != nil {
+			health.Status, health.Error = HealthDown, err.Error()
+			if !c.Optional {
+				report.Status = HealthDown
+			} else if report.Status == HealthUp {
+				report.Status = HealthDegraded
+			}
+		}
+		report.Components = append(report.Components, health)
+	}
+	return report
+}
+
+// componentError returns why a component does not work, nil if it does
+func (r *RuntimeContext) componentError(ctx context.Context, c *component) error {
+	r._sync.Lock()
+	state, err := c.state, c.err
+	r._sync.Unlock()
+
+	switch state {
+	case componentRegistered:
+		return errors.New("not started")
+	case componentFailed:
+		return err
+	case componentStopped:
+		return errors.New("stopped")
+	}
+	if c.Health == nil {
+		return nil
+	}
+	return c.Health(ctx)
+}
+
+// componentsIn returns the components in one of the states, in the order registered
+func (r *RuntimeContext) componentsIn(states ...componentState) []*component {
+	r._sync.Lock()
+	defer r._sync.Unlock()
+
+	return r.componentsInLocked(states...)
+}
+
+// componentsInLocked is componentsIn with the lock held
+func (r *RuntimeContext) componentsInLocked(states ...componentState) []*component {
+	var components []*component
+	for _, c := range r._components {
+		for _, state := range states {
+			if c.state == state {
+				components = append(components, c)
+				break
+			}
+		}
+	}
+	return components
+}
+
+// stopComponent stops a running component
+func (r *RuntimeContext) stopComponent(ctx context.Context, c *component) error {
+	r._sync.Lock()
+	running := c.state == componentRunning
+	c.state = componentStopped
+	r._sync.Unlock()
+
+	if !running || c.Stop == nil {
+		return nil
+	}
+	return c.Stop(ctx)
+}
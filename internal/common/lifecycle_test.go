@@ -0,0 +1,109 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// recorder records the lifecycle calls of components
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) component(name string, startErr error) Component {
+	return Component{
+		Name: name,
+		Start: func(context.Context) error {
+			r.calls = append(r.calls, "start "+name)
+			return startErr
+		},
+		Stop: func(context.Context) error {
+			r.calls = append(r.calls, "stop "+name)
+			return nil
+		},
+	}
+}
+
+func TestStartInOrderAndStopInReverse(t *testing.T) {
+	runtime, _ := NewRuntimeContext(RuntimeOptions{})
+	r := &recorder{}
+	for _, name := range []string{"store", "agents", "scheduler"} {
+		if err := runtime.Register(r.component(name, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := runtime.Register(r.component("agents", nil)); err == nil {
+		t.Error("Expected registering a component twice to fail")
+	}
+
+	if err := runtime.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if !runtime.Running("agents") || runtime.Running("unknown") {
+		t.Error("Expected only the registered components to be running")
+	}
+	if err := runtime.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	want := []string{"start store", "start agents", "start scheduler", "stop scheduler", "stop agents", "stop store"}
+	if !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, r.calls)
+	}
+	if runtime.Running("agents") {
+		t.Error("Expected the components to be stopped")
+	}
+}
+
+func TestStartFailure(t *testing.T) {
+	runtime, _ := NewRuntimeContext(RuntimeOptions{})
+	r := &recorder{}
+	optional := r.component("admin", errors.New("socket in use"))
+	optional.Optional = true
+	runtime.Register(optional)
+	runtime.Register(r.component("agents", nil))
+	runtime.Register(r.component("bridge", errors.New("port in use")))
+	runtime.Register(r.component("scheduler", nil))
+
+	err := runtime.Start(context.Background())
+	if err == nil || err.Error() != "start bridge: port in use" {
+		t.Errorf("Expected the required component's error, got %v", err)
+	}
+	runtime.Shutdown(context.Background())
+
+	// Components that did not start are not stopped
+	want := []string{"start admin", "start agents", "start bridge", "stop agents"}
+	if !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, r.calls)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	runtime, _ := NewRuntimeContext(RuntimeOptions{})
+	var storeErr error
+	runtime.Register(Component{Name: "store", Health: func(context.Context) error { return storeErr }})
+	runtime.Register(Component{Name: "admin", Optional: true, Start: func(context.Context) error {
+		return errors.New("socket in use")
+	}})
+
+	if report := runtime.Health(context.Background()); report.Status != HealthDown || report.Components[0].Error != "not started" {
+		t.Errorf("Expected components not started to be down, got %+v", report)
+	}
+
+	runtime.Start(context.Background())
+	report := runtime.Health(context.Background())
+	want := HealthReport{Status: HealthDegraded, Components: []ComponentHealth{
+		{Name: "store", Status: HealthUp},
+		{Name: "admin", Status: HealthDown, Optional: true, Error: "socket in use"},
+	}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("Expected %+v, got %+v", want, report)
+	}
+
+	storeErr = errors.New("closed")
+	if report := runtime.Health(context.Background()); report.Status != HealthDown || report.Components[0].Error != "closed" {
+		t.Errorf("Expected a failing health check to take the application down, got %+v", report)
+	}
+}
@@ -2,27 +2,43 @@ package common
 
 import (
 	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
 	"goproduct/internal/messaging"
 	"sync"
+	"time"
 )
 
 type RuntimeContext struct {
-	_ops        RuntimeOptions
-	_memory     knowledge.Store
-	_messageBus messaging.MessageBus
-	_sync       *sync.Mutex
+	_ops           RuntimeOptions
+	_memory        knowledge.Store
+	_messageBus    messaging.MessageBus
+	_languageModel llm.LanguageModel
+	_sync          *sync.Mutex
+	_components    []*component
+	_started       bool // Start has started every component registered so far
+	_stopping      bool // Shutdown has begun
+	_steps         []shutdownStep
+	_shutdown      sync.Once
+	_stopped       error
 }
 
 type RuntimeOptions struct {
-	Memory     knowledge.Store
-	MessageBus messaging.MessageBus
+	Memory        knowledge.Store
+	MessageBus    messaging.MessageBus
+	LanguageModel llm.LanguageModel
+	// Bound of each step of Shutdown, DefaultShutdownTimeout if zero
+	ShutdownTimeout time.Duration
 }
 
 func NewRuntimeContext(opt RuntimeOptions) (*RuntimeContext, error) {
 	rv := new(RuntimeContext)
 	rv._sync = new(sync.Mutex)
 	rv._ops = opt
+	if rv._ops.ShutdownTimeout <= 0 {
+		rv._ops.ShutdownTimeout = DefaultShutdownTimeout
+	}
 	rv._memory = opt.Memory
+	rv._languageModel = opt.LanguageModel
 
 	// Initialize message bus
 	if opt.MessageBus != nil {
@@ -64,3 +80,18 @@ func (r *RuntimeContext) SetMessageBus(m messaging.MessageBus) error {
 	r._messageBus = m
 	return nil
 }
+
+func (r *RuntimeContext) GetLanguageModel() (llm.LanguageModel, error) {
+	r._sync.Lock()
+	defer r._sync.Unlock()
+
+	return r._languageModel, nil
+}
+
+func (r *RuntimeContext) SetLanguageModel(m llm.LanguageModel) error {
+	r._sync.Lock()
+	defer r._sync.Unlock()
+
+	r._languageModel = m
+	return nil
+}
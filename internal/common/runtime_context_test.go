@@ -0,0 +1,105 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goproduct/internal/messaging"
+)
+
+// closingBus records the message bus steps of shutdown
+type closingBus struct {
+	*messaging.MemoryMessageBus
+	record func(string)
+}
+
+func (b closingBus) Drain(ctx context.Context) error {
+	b.record("drain bus")
+	return b.MemoryMessageBus.Drain(ctx)
+}
+
+func (b closingBus) Close() error {
+	b.record("close bus")
+	return nil
+}
+
+func TestShutdownRunsStagesInOrder(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+	step := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			record(name)
+			return nil
+		}
+	}
+
+	runtime, err := NewRuntimeContext(RuntimeOptions{
+		MessageBus: closingBus{MemoryMessageBus: messaging.NewMemoryMessageBus(), record: record},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.OnShutdown(StageLoggers, "logger", step("logger"))
+	runtime.OnShutdown(StageTracers, "tracer", step("tracer"))
+	runtime.OnShutdown(StageEntities, "janitor", step("janitor"))
+	runtime.OnShutdown(StageEntities, "team", step("team"))
+
+	if err := runtime.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	want := []string{"team", "janitor", "drain bus", "close bus", "tracer", "logger"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("Expected shutdown order %v, got %v", want, order)
+	}
+
+	// Only the first call stops anything
+	if err := runtime.Shutdown(context.Background()); err != nil {
+		t.Errorf("Second Shutdown failed: %v", err)
+	}
+	if len(order) != len(want) {
+		t.Errorf("Expected the second Shutdown to do nothing, got %v", order)
+	}
+}
+
+func TestShutdownMovesOnPastFailedSteps(t *testing.T) {
+	runtime, err := NewRuntimeContext(RuntimeOptions{ShutdownTimeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := false
+	runtime.OnShutdown(StageTracers, "tracer", func(context.Context) error {
+		closed = true
+		return nil
+	})
+	stuck := make(chan struct{})
+	defer close(stuck)
+	runtime.OnShutdown(StageEntities, "stuck", func(context.Context) error {
+		<-stuck // Ignores its context
+		return nil
+	})
+	runtime.OnShutdown(StageEntities, "broken", func(context.Context) error {
+		return errors.New("broken")
+	})
+
+	start := time.Now()
+	err = runtime.Shutdown(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the stuck step to time out, Shutdown took %s", elapsed)
+	}
+	if !closed {
+		t.Error("Expected the tracer to be closed after the failed steps")
+	}
+	if err == nil || !strings.Contains(err.Error(), "broken") || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the errors of both failed steps, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"goproduct/internal/logging"
+)
+
+// DefaultShutdownTimeout bounds each step of Shutdown unless the runtime options set
+// another bound
+const DefaultShutdownTimeout = 5 * time.Second
+
+// ShutdownStage orders the steps of Shutdown: the steps of a stage run after those of
+// the stages before it, so that nothing is stopped while something else still uses it
+type ShutdownStage int
+
+// Shutdown stages in the order they run
+const (
+	StageEntities ShutdownStage = iota // Entities and services stop taking work
+	StageBus                           // The message bus delivers what is in flight and closes
+	StageStores                        // Knowledge stores write pending changes and close
+	StageTracers                       // Tracers write their buffered events and close
+	StageLoggers                       // Loggers close last, so that every step before can log
+)
+
+// String returns the name of the stage
+func (s ShutdownStage) String() string {
+	switch s {
+	case StageEntities:
+		return "entities"
+	case StageBus:
+		return "bus"
+	case StageStores:
+		return "stores"
+	case StageTracers:
+		return "tracers"
+	case StageLoggers:
+		return "loggers"
+	}
+	return fmt.Sprintf("stage %d", int(s))
+}
+
+// drainer is a message bus that can wait for the deliveries in flight
+type drainer interface {
+	Drain(ctx context.Context) error
+}
+
+// shutdownStep is something Shutdown stops
+type shutdownStep struct {
+	stage ShutdownStage
+	name  string
+	stop  func(ctx context.Context) error
+}
+
+// OnShutdown adds a step to Shutdown. Steps of the same stage run in the reverse order of
+// being added, like deferred calls, so that what started last stops first; stop should
+// return when the context is done, though Shutdown moves on without it.
+func (r *RuntimeContext) OnShutdown(stage ShutdownStage, name string, stop func(ctx context.Context) error) {
+	r._sync.Lock()
+	defer r._sync.Unlock()
+
+	r._steps = append(r._steps, shutdownStep{stage: stage, name: name, stop: stop})
+}
+
+// Shutdown stops what was added with OnShutdown and the runtime context's own message
+// bus and memory store, stage by stage: entities, then the bus, which is drained of the
+// deliveries in flight and closed, the stores, which are flushed and closed, tracers and
+// loggers. Each step is bounded by the shutdown timeout of the options and by the
+// deadline of ctx; a step that fails or runs out of time does not stop the next ones, and
+// the errors are returned together. Shutdown runs once; later calls return its result.
+func (r *RuntimeContext) Shutdown(ctx context.Context) error {
+	r._shutdown.Do(func() {
+		r._sync.Lock()
+		r._stopping = true
+		r._sync.Unlock()
+		r._stopped = r.shutdown(ctx)
+	})
+	return r._stopped
+}
+
+// shutdown runs the shutdown steps in stage order
+func (r *RuntimeContext) shutdown(ctx context.Context) error {
+	steps := r.shutdownSteps()
+	logger := logging.Get()
+	var errs []error
+	for stage := StageEntities; stage <= StageLoggers; stage++ {
+		for i := len(steps) - 1; i >= 0; i-- {
+			step := steps[i]
+			if step.stage != stage {
+				continue
+			}
+			if stage < StageLoggers {
+				logger.Debug("Shutting down", "stage", stage, "step", step.name)
+			}
+			if err := r.runStep(ctx, step); err != nil {
+				if stage < StageLoggers {
+					logger.Warn("Shutdown step failed", "stage", stage, "step", step.name, "error", err)
+				}
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shutdownSteps returns the steps added with OnShutdown, after those stopping the message
+// bus and memory store
+func (r *RuntimeContext) shutdownSteps() []shutdownStep {
+	r._sync.Lock()
+	defer r._sync.Unlock()
+
+	var steps []shutdownStep
+	if bus := r._messageBus; bus != nil {
+		steps = append(steps, shutdownStep{stage: StageBus, name: "message bus", stop: func(ctx context.Context) error {
+			var errs []error
+			if d, ok := bus.(drainer); ok {
+				errs = append(errs, d.Drain(ctx))
+			}
+			if c, ok := bus.(io.Closer); ok {
+				errs = append(errs, c.Close())
+			}
+			return errors.Join(errs...)
+		}})
+	}
+	if store := r._memory; store != nil {
+		steps = append(steps, shutdownStep{stage: StageStores, name: "memory store", stop: func(ctx context.Context) error {
+			if err := store.Flush(); err != nil {
+				store.Close()
+				return err
+			}
+			return store.Close()
+		}})
+	}
+	return append(steps, r._steps...)
+}
+
+// runStep runs a step within the shutdown timeout
+func (r *RuntimeContext) runStep(ctx context.Context, step shutdownStep) error {
+	ctx, cancel := context.WithTimeout(ctx, r._ops.ShutdownTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- step.stop(ctx)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s: %w", step.name, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: not stopped: %w", step.name, ctx.Err())
+	}
+}
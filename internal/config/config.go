@@ -0,0 +1,570 @@
+// Package config loads the application configuration: a YAML file in the data directory,
+// overridden by environment variables, on top of built-in defaults. Every setting that
+// has an environment variable names it in its env tag.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	"goproduct/internal/logging"
+	"goproduct/internal/tracing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Store backends
+const (
+	StoreFile   = "file"   // Write-ahead logged file in the data directory
+	StoreS3     = "s3"     // Objects in an S3-compatible bucket
+	StoreMemory = "memory" // Nothing persisted
+)
+
+// Config is the configuration of the application
+type Config struct {
+	LLM        LLMConfig        `yaml:"llm"`
+	Store      StoreConfig      `yaml:"store"`
+	Paths      PathsConfig      `yaml:"paths"`
+	Logging    LoggingConfig    `yaml:"logging"`
+	Timeouts   TimeoutsConfig   `yaml:"timeouts"`
+	Bus        BusConfig        `yaml:"bus"`
+	Agent      AgentConfig      `yaml:"agent"`
+	Chat       ChatConfig       `yaml:"chat"`
+	Server     ServerConfig     `yaml:"server"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Intervals  IntervalsConfig  `yaml:"intervals"`
+	Guardrails GuardrailsConfig `yaml:"guardrails"`
+}
+
+// LLMConfig selects the language model. Provider-specific settings, such as api_key or
+// keep_alive, go in the provider's section; settings in neither come from the provider's
+// environment variables, e.g. OPENAI_API_KEY.
+type LLMConfig struct {
+	Provider    string  `yaml:"provider" env:"LLM_TYPE"` // Any registered provider, e.g. "openai", "anthropic", "ollama", "lmstudio" or "echo"
+	Model       string  `yaml:"model" env:"LLM_MODEL"`   // Provider default if empty
+	Endpoint    string  `yaml:"endpoint" env:"LLM_ENDPOINT"`
+	Temperature float32 `yaml:"temperature" env:"LLM_TEMPERATURE"` // Provider default if 0
+	MaxTokens   int     `yaml:"max_tokens" env:"LLM_MAX_TOKENS"`   // Provider default if 0
+
+	Providers map[string]map[string]string `yaml:"providers"` // Settings by provider name
+	Fallbacks []string                     `yaml:"fallbacks"` // Providers asked in order when the provider fails or times out
+	Race      bool                         `yaml:"race"`      // Ask the provider and its fallbacks at once, the first answer wins
+
+	Cache     LLMCacheConfig     `yaml:"cache"`
+	RateLimit LLMRateLimitConfig `yaml:"rate_limit"`
+}
+
+// LLMRateLimitConfig bounds the calls to the language model, so that a runaway agent loop
+// cannot hammer a paid API or overload a local server
+type LLMRateLimitConfig struct {
+	RequestsPerMinute int           `yaml:"requests_per_minute" env:"LLM_REQUESTS_PER_MINUTE"` // 0 for no limit
+	TokensPerMinute   int           `yaml:"tokens_per_minute" env:"LLM_TOKENS_PER_MINUTE"`     // 0 for no limit
+	Reject            bool          `yaml:"reject"`                                            // Reject calls over the limit instead of queueing them
+	MaxWait           time.Duration `yaml:"max_wait"`                                          // Longest a queued call waits before it is rejected, a minute if 0
+}
+
+// LLMCacheConfig caches the responses of the language model; repeated requests, as in
+// tests and demos, are answered without calling the provider
+type LLMCacheConfig struct {
+	TTL     time.Duration `yaml:"ttl" env:"LLM_CACHE_TTL"`         // How long a response is reused; 0 disables the cache
+	Size    int           `yaml:"size"`                            // Responses held in memory, 1000 if 0
+	Persist bool          `yaml:"persist" env:"LLM_CACHE_PERSIST"` // Keep responses in the knowledge store across runs
+}
+
+// ProviderSettings returns the settings of a provider: its section, overridden for the
+// selected provider by the top-level model, endpoint, temperature and max tokens that are set
+func (c LLMConfig) ProviderSettings(provider string) map[string]string {
+	settings := make(map[string]string)
+	for key, value := range c.Providers[provider] {
+		settings[key] = value
+	}
+	if provider != c.Provider {
+		return settings
+	}
+	if c.Model != "" {
+		settings["model"] = c.Model
+	}
+	if c.Endpoint != "" {
+		settings["endpoint"] = c.Endpoint
+	}
+	if c.Temperature != 0 {
+		settings["temperature"] = strconv.FormatFloat(float64(c.Temperature), 'g', -1, 32)
+	}
+	if c.MaxTokens != 0 {
+		settings["max_tokens"] = strconv.Itoa(c.MaxTokens)
+	}
+	return settings
+}
+
+// StoreConfig selects the knowledge store
+type StoreConfig struct {
+	Backend  string   `yaml:"backend" env:"KNOWLEDGE_BACKEND"` // StoreFile, StoreS3 or StoreMemory; StoreS3 if a bucket is set, StoreFile otherwise
+	File     string   `yaml:"file" env:"KNOWLEDGE_FILE"`       // File of the StoreFile backend, in the data directory if empty
+	Backfill bool     `yaml:"backfill" env:"KNOWLEDGE_BACKFILL"`
+	S3       S3Config `yaml:"s3"`
+}
+
+// S3Config locates the bucket of the StoreS3 backend
+type S3Config struct {
+	Bucket          string `yaml:"bucket" env:"KNOWLEDGE_S3_BUCKET"`
+	Prefix          string `yaml:"prefix" env:"KNOWLEDGE_S3_PREFIX"`
+	Endpoint        string `yaml:"endpoint" env:"KNOWLEDGE_S3_ENDPOINT"`
+	Region          string `yaml:"region" env:"KNOWLEDGE_S3_REGION"`
+	AccessKeyID     string `yaml:"access_key_id" env:"AWS_ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secret_access_key" env:"AWS_SECRET_ACCESS_KEY"`
+	SessionToken    string `yaml:"session_token" env:"AWS_SESSION_TOKEN"`
+}
+
+// PathsConfig places the log files; empty paths are in the data directory
+type PathsConfig struct {
+	AppLog   string `yaml:"app_log" env:"APP_LOG"`
+	TraceLog string `yaml:"trace_log" env:"TRACE_LOG"`
+}
+
+// LoggingConfig formats and rotates the application log. Levels are "debug", "info",
+// "warn" or "error".
+type LoggingConfig struct {
+	Format     string            `yaml:"format" env:"LOG_FORMAT"`           // "text" or "json"
+	Level      string            `yaml:"level" env:"LOG_LEVEL"`             // Level of the components without their own
+	Components map[string]string `yaml:"components"`                        // Levels of components, e.g. llm: debug
+	MaxSizeMB  int               `yaml:"max_size_mb" env:"LOG_MAX_SIZE_MB"` // Rotates the log at this size; 0 never rotates
+	MaxAge     time.Duration     `yaml:"max_age" env:"LOG_MAX_AGE"`         // Removes older rotated logs; 0 keeps them
+	MaxBackups int               `yaml:"max_backups" env:"LOG_MAX_BACKUPS"` // Rotated logs kept; 0 keeps them all
+}
+
+// TimeoutsConfig bounds how long operations may take
+type TimeoutsConfig struct {
+	LLMRequest time.Duration `yaml:"llm_request" env:"LLM_TIMEOUT"`       // One request to the language model
+	ChatReply  time.Duration `yaml:"chat_reply" env:"CHAT_REPLY_TIMEOUT"` // The agent's reply to a chat message, per attempt
+	Shutdown   time.Duration `yaml:"shutdown" env:"SHUTDOWN_TIMEOUT"`     // Stopping the application on exit or Ctrl+C
+}
+
+// IntervalsConfig sets how often background work runs
+type IntervalsConfig struct {
+	TraceFlush    time.Duration `yaml:"trace_flush"`
+	StoreFlush    time.Duration `yaml:"store_flush"`
+	AccessFlush   time.Duration `yaml:"access_flush"`
+	Suggestions   time.Duration `yaml:"suggestions"`
+	Expiry        time.Duration `yaml:"expiry" env:"KNOWLEDGE_EXPIRY_INTERVAL"`
+	QualityReport time.Duration `yaml:"quality_report"`
+	Heartbeat     time.Duration `yaml:"heartbeat"` // How often agents announce their presence
+}
+
+// BusConfig selects the message bus; without NATS or Redis, entities share one process
+type BusConfig struct {
+	NatsURL  string `yaml:"nats_url" env:"NATS_URL"`
+	RedisURL string `yaml:"redis_url" env:"REDIS_URL"`
+	GRPCAddr string `yaml:"grpc_addr" env:"GRPC_ADDR"` // Serves the bus to other languages, see bus.proto
+	History  int    `yaml:"history"`                   // Messages kept per entity for GetHistory
+}
+
+// AgentConfig tunes the agent
+type AgentConfig struct {
+	Persona       string `yaml:"persona" env:"PERSONA"`
+	ContextBudget int    `yaml:"context_budget" env:"CONTEXT_BUDGET"`       // Tokens of history before older turns are summarized
+	PlanSteps     int    `yaml:"plan_steps" env:"AGENT_PLAN_STEPS"`         // Actions of the reasoning loop before the agent must answer; 0 answers without the loop
+	MaxConcurrent int    `yaml:"max_concurrent" env:"AGENT_MAX_CONCURRENT"` // Messages answered at once; 0 for no limit
+	InboxSize     int    `yaml:"inbox_size" env:"AGENT_INBOX_SIZE"`         // Messages waiting their turn before the agent answers it is busy
+}
+
+// ChatConfig tunes the chat prompt
+type ChatConfig struct {
+	ReplyRetries  int    `yaml:"reply_retries" env:"CHAT_REPLY_RETRIES"` // Times a message is sent again when not answered in time
+	FallbackReply string `yaml:"fallback_reply"`                         // Shown when the agent never replied, a built-in notice if empty
+}
+
+// GuardrailsConfig checks the messages sent to the agent and its replies. Actions are
+// "block", "redact" or "warn"; directions are "inbound", "outbound" or "both".
+type GuardrailsConfig struct {
+	MaxInputLength  int            `yaml:"max_input_length" env:"GUARDRAILS_MAX_INPUT_LENGTH"` // Characters of a message, 0 for no limit
+	MaxOutputLength int            `yaml:"max_output_length"`                                  // Characters of a reply, 0 for no limit
+	LengthAction    string         `yaml:"length_action"`                                      // Action on messages over a length limit, "block" if empty
+	Denylist        []DenylistRule `yaml:"denylist"`
+	Moderation      ModerationRule `yaml:"moderation"`
+	FailClosed      bool           `yaml:"fail_closed"` // Block messages when a check fails, e.g. the moderation model is down
+}
+
+// DenylistRule acts on messages matching a regular expression
+type DenylistRule struct {
+	Pattern   string `yaml:"pattern"`   // Go syntax, e.g. '(?i)password\s*[:=]\s*\S+'
+	Action    string `yaml:"action"`    // "block" if empty
+	Direction string `yaml:"direction"` // "both" if empty
+}
+
+// ModerationRule asks the language model whether messages contain harmful content
+type ModerationRule struct {
+	Direction  string   `yaml:"direction" env:"GUARDRAILS_MODERATION"` // Messages moderated; empty turns moderation off
+	Action     string   `yaml:"action"`                                // "block" if empty
+	Categories []string `yaml:"categories"`                            // Kinds of content flagged, the moderator's defaults if empty
+}
+
+// ServerConfig configures the HTTP API
+type ServerConfig struct {
+	Addr  string     `yaml:"addr" env:"SERVE_ADDR"`   // Serves the API instead of the chat prompt when set
+	Token string     `yaml:"token" env:"SERVE_TOKEN"` // Bearer token of the API; empty accepts every request
+	Auth  AuthConfig `yaml:"auth"`
+}
+
+// AuthConfig signs web users in under /api/auth. With any provider configured, API and
+// WebSocket requests need the access token of a session instead of the server token.
+type AuthConfig struct {
+	Users      map[string]AuthUserConfig `yaml:"users"`       // Local users by username
+	OIDC       []OIDCConfig              `yaml:"oidc"`        // OpenID Connect providers, e.g. Google
+	GitHub     OAuthClientConfig         `yaml:"github"`      // GitHub OAuth app; none without a client_id
+	Identities map[string]string         `yaml:"identities"`  // "provider:subject" or email address to entity ID
+	AllowEmail bool                      `yaml:"allow_email"` // Map identities by email address; only with providers that verify emails
+	AccessTTL  time.Duration             `yaml:"access_ttl"`  // Lifetime of access tokens; 0 for 15 minutes
+	RefreshTTL time.Duration             `yaml:"refresh_ttl"` // Lifetime of refresh tokens; 0 for 30 days
+}
+
+// AuthUserConfig is a local user, signing in with a password as an entity
+type AuthUserConfig struct {
+	Password string `yaml:"password"`
+	EntityID string `yaml:"entity_id"`
+}
+
+// OAuthClientConfig is the registration of the application with an OAuth provider
+type OAuthClientConfig struct {
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"` // e.g. https://host/api/auth/github/callback
+	Scopes       []string `yaml:"scopes"`
+}
+
+// OIDCConfig is an OpenID Connect provider; its name is the {provider} of its sign-in URLs
+type OIDCConfig struct {
+	Name              string `yaml:"name"`
+	Issuer            string `yaml:"issuer"` // e.g. https://accounts.google.com
+	OAuthClientConfig `yaml:",inline"`
+}
+
+// Enabled reports whether any provider is configured
+func (a AuthConfig) Enabled() bool {
+	return len(a.Users) > 0 || len(a.OIDC) > 0 || a.GitHub.ClientID != ""
+}
+
+// TracingConfig sets the level of the trace events and exports them to an OpenTelemetry
+// collector, besides the trace log
+type TracingConfig struct {
+	Level        string            `yaml:"level" env:"TRACE_LEVEL"`                         // "error", "warning", "info", "debug" or "verbose"
+	OTLPEndpoint string            `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"` // OTLP/HTTP collector, e.g. http://localhost:4318; empty exports nothing
+	ServiceName  string            `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+	Headers      map[string]string `yaml:"headers"` // Sent with every export, e.g. an authorization header
+}
+
+// Default returns the built-in configuration
+func Default() Config {
+	return Config{
+		LLM: LLMConfig{
+			Provider: "lmstudio",
+			Providers: map[string]map[string]string{
+				"lmstudio": {"model": "gemma-3-4b-it", "max_tokens": "4096"},
+			},
+		},
+		Logging: LoggingConfig{
+			Format:     "text",
+			Level:      "debug",
+			MaxSizeMB:  10,
+			MaxAge:     7 * 24 * time.Hour,
+			MaxBackups: 5,
+		},
+		Timeouts: TimeoutsConfig{
+			LLMRequest: 60 * time.Second,
+			ChatReply:  60 * time.Second,
+			Shutdown:   15 * time.Second,
+		},
+		Intervals: IntervalsConfig{
+			TraceFlush:    5 * time.Second,
+			StoreFlush:    5 * time.Second,
+			AccessFlush:   30 * time.Second,
+			Suggestions:   30 * time.Second,
+			Expiry:        time.Minute,
+			QualityReport: 24 * time.Hour,
+			Heartbeat:     10 * time.Second,
+		},
+		Bus:     BusConfig{History: 1000},
+		Agent:   AgentConfig{Persona: "Andy", ContextBudget: 8000, MaxConcurrent: 4, InboxSize: 32},
+		Chat:    ChatConfig{ReplyRetries: 1},
+		Tracing: TracingConfig{Level: "debug", ServiceName: "goproduct"},
+	}
+}
+
+// Load returns the default configuration, updated with the YAML file at the path and then
+// with the environment. A missing file is not an error.
+func Load(path string) (Config, error) {
+	config := Default()
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return config, fmt.Errorf("failed to read configuration: %w", err)
+	default:
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("failed to parse configuration %s: %w", path, err)
+		}
+	}
+	if err := config.ApplyEnv(os.LookupEnv); err != nil {
+		return config, err
+	}
+	if config.Store.Backend == "" {
+		config.Store.Backend = StoreFile
+		if config.Store.S3.Bucket != "" {
+			config.Store.Backend = StoreS3
+		}
+	}
+	return config, config.Validate()
+}
+
+// ApplyEnv overrides settings with the environment variables named in their env tags;
+// lookup is usually os.LookupEnv. Empty variables are ignored.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	return applyEnv(reflect.ValueOf(c).Elem(), lookup)
+}
+
+// applyEnv sets the fields of the struct value from the environment
+func applyEnv(value reflect.Value, lookup func(string) (string, bool)) error {
+	for i := 0; i < value.NumField(); i++ {
+		field, info := value.Field(i), value.Type().Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		name := info.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		raw, ok := lookup(name)
+		if !ok || raw == "" {
+			continue
+		}
+		if err := setField(field, raw); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", name, raw, err)
+		}
+	}
+	return nil
+}
+
+// setField parses the text into the field
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case reflect.Float32:
+		f, err := strconv.ParseFloat(raw, 32)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// Validate checks that the settings are usable
+func (c Config) Validate() error {
+	var errs []error
+	switch c.Store.Backend {
+	case StoreFile, StoreMemory:
+	case StoreS3:
+		if c.Store.S3.Bucket == "" {
+			errs = append(errs, errors.New("store s3 needs a bucket"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown store backend %q", c.Store.Backend))
+	}
+	if c.Bus.NatsURL != "" && c.Bus.RedisURL != "" {
+		errs = append(errs, errors.New("bus can use NATS or Redis, not both"))
+	}
+	if c.Bus.History < 0 {
+		errs = append(errs, errors.New("bus history cannot be negative"))
+	}
+	for _, fallback := range c.LLM.Fallbacks {
+		if fallback == "" || fallback == c.LLM.Provider {
+			errs = append(errs, fmt.Errorf("llm fallback %q must name another provider", fallback))
+		}
+	}
+	if c.LLM.Race && len(c.LLM.Fallbacks) == 0 {
+		errs = append(errs, errors.New("llm race needs fallbacks"))
+	}
+	if c.LLM.Temperature < 0 || c.LLM.Temperature > 2 {
+		errs = append(errs, fmt.Errorf("llm temperature %v is not between 0 and 2", c.LLM.Temperature))
+	}
+	if limit := c.LLM.RateLimit; limit.RequestsPerMinute < 0 || limit.TokensPerMinute < 0 || limit.MaxWait < 0 {
+		errs = append(errs, errors.New("llm rate_limit settings cannot be negative"))
+	}
+	if c.LLM.Cache.TTL < 0 || c.LLM.Cache.Size < 0 {
+		errs = append(errs, errors.New("llm cache ttl and size cannot be negative"))
+	}
+	errs = append(errs, c.Guardrails.validate()...)
+	if c.Agent.ContextBudget < 0 {
+		errs = append(errs, errors.New("agent context_budget cannot be negative"))
+	}
+	if c.Agent.PlanSteps < 0 {
+		errs = append(errs, errors.New("agent plan_steps cannot be negative"))
+	}
+	if c.Agent.MaxConcurrent < 0 || c.Agent.InboxSize < 0 {
+		errs = append(errs, errors.New("agent max_concurrent and inbox_size cannot be negative"))
+	}
+	if c.Chat.ReplyRetries < 0 {
+		errs = append(errs, errors.New("chat reply_retries cannot be negative"))
+	}
+	errs = append(errs, c.Logging.validate()...)
+	errs = append(errs, c.Server.Auth.validate()...)
+	if _, err := tracing.ParseLevel(c.Tracing.Level); err != nil {
+		errs = append(errs, fmt.Errorf("tracing level: %w", err))
+	}
+	if endpoint := c.Tracing.OTLPEndpoint; endpoint != "" {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing otlp_endpoint %q is not an http or https URL", endpoint))
+		}
+	}
+	intervals := map[string]time.Duration{
+		"timeouts llm_request":     c.Timeouts.LLMRequest,
+		"timeouts chat_reply":      c.Timeouts.ChatReply,
+		"timeouts shutdown":        c.Timeouts.Shutdown,
+		"intervals trace_flush":    c.Intervals.TraceFlush,
+		"intervals store_flush":    c.Intervals.StoreFlush,
+		"intervals access_flush":   c.Intervals.AccessFlush,
+		"intervals suggestions":    c.Intervals.Suggestions,
+		"intervals expiry":         c.Intervals.Expiry,
+		"intervals quality_report": c.Intervals.QualityReport,
+		"intervals heartbeat":      c.Intervals.Heartbeat,
+	}
+	for name, d := range intervals {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks the format, levels and rotation of the log
+func (c LoggingConfig) validate() []error {
+	var errs []error
+	if _, err := logging.ParseFormat(c.Format); err != nil {
+		errs = append(errs, fmt.Errorf("logging format: %w", err))
+	}
+	if _, err := logging.ParseLevel(c.Level); err != nil {
+		errs = append(errs, fmt.Errorf("logging level: %w", err))
+	}
+	for component, level := range c.Components {
+		if _, err := logging.ParseLevel(level); err != nil {
+			errs = append(errs, fmt.Errorf("logging level of %s: %w", component, err))
+		}
+	}
+	if c.MaxSizeMB < 0 || c.MaxAge < 0 || c.MaxBackups < 0 {
+		errs = append(errs, errors.New("logging max_size_mb, max_age and max_backups cannot be negative"))
+	}
+	return errs
+}
+
+// Options returns the logger options of the settings, which are assumed valid
+func (c LoggingConfig) Options() logging.Options {
+	options := logging.DefaultOptions()
+	options.Format, _ = logging.ParseFormat(c.Format)
+	options.Level, _ = logging.ParseLevel(c.Level)
+	options.Components = make(map[string]logging.LogLevel, len(c.Components))
+	for component, level := range c.Components {
+		options.Components[component], _ = logging.ParseLevel(level)
+	}
+	options.Rotation = logging.RotationOptions{
+		MaxSize:    int64(c.MaxSizeMB) * 1024 * 1024,
+		MaxAge:     c.MaxAge,
+		MaxBackups: c.MaxBackups,
+	}
+	return options
+}
+
+// validate checks that the providers can sign users in
+func (a AuthConfig) validate() []error {
+	var errs []error
+	for username, user := range a.Users {
+		if user.Password == "" || user.EntityID == "" {
+			errs = append(errs, fmt.Errorf("server auth user %q needs a password and an entity_id", username))
+		}
+	}
+	names := map[string]bool{"local": len(a.Users) > 0, "github": a.GitHub.ClientID != ""}
+	for _, provider := range a.OIDC {
+		if provider.Name == "" || names[provider.Name] {
+			errs = append(errs, fmt.Errorf("server auth oidc provider %q needs a unique name", provider.Name))
+		}
+		names[provider.Name] = true
+		if u, err := url.Parse(provider.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("server auth oidc issuer %q is not an http or https URL", provider.Issuer))
+		}
+		if provider.ClientID == "" || provider.RedirectURL == "" {
+			errs = append(errs, fmt.Errorf("server auth oidc provider %q needs a client_id and a redirect_url", provider.Name))
+		}
+	}
+	if a.GitHub.ClientID != "" && a.GitHub.RedirectURL == "" {
+		errs = append(errs, errors.New("server auth github needs a redirect_url"))
+	}
+	if a.AccessTTL < 0 || a.RefreshTTL < 0 {
+		errs = append(errs, errors.New("server auth access_ttl and refresh_ttl cannot be negative"))
+	}
+	return errs
+}
+
+// validate checks the guardrail rules
+func (g GuardrailsConfig) validate() []error {
+	var errs []error
+	if g.MaxInputLength < 0 || g.MaxOutputLength < 0 {
+		errs = append(errs, errors.New("guardrails length limits cannot be negative"))
+	}
+	errs = append(errs, checkGuardrailRule("length_action", g.LengthAction, "")...)
+	for _, rule := range g.Denylist {
+		if _, err := regexp.Compile(rule.Pattern); err != nil || rule.Pattern == "" {
+			errs = append(errs, fmt.Errorf("guardrails denylist pattern %q is not a valid regular expression", rule.Pattern))
+		}
+		errs = append(errs, checkGuardrailRule("denylist", rule.Action, rule.Direction)...)
+	}
+	if g.Moderation.Direction != "" {
+		errs = append(errs, checkGuardrailRule("moderation", g.Moderation.Action, g.Moderation.Direction)...)
+	}
+	return errs
+}
+
+// checkGuardrailRule checks the action and direction of a guardrail rule, either of which may be empty
+func checkGuardrailRule(name, action, direction string) []error {
+	var errs []error
+	switch action {
+	case "", "block", "redact", "warn":
+	default:
+		errs = append(errs, fmt.Errorf("guardrails %s action %q must be block, redact or warn", name, action))
+	}
+	switch direction {
+	case "", "inbound", "outbound", "both":
+	default:
+		errs = append(errs, fmt.Errorf("guardrails %s direction %q must be inbound, outbound or both", name, direction))
+	}
+	return errs
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/logging"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	config, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load defaults: %v", err)
+	}
+	if config.Store.Backend != StoreFile || config.Intervals.Expiry != time.Minute || config.Agent.Persona != "Andy" {
+		t.Errorf("Unexpected defaults: %+v", config)
+	}
+}
+
+func TestLoadFileAndEnvironment(t *testing.T) {
+	path := writeConfig(t, `
+llm:
+  provider: ollama
+  model: mistral
+store:
+  s3:
+    bucket: knowledge
+intervals:
+  expiry: 5m
+bus:
+  history: 50
+`)
+	t.Setenv("LLM_MODEL", "llama3")
+	t.Setenv("KNOWLEDGE_BACKFILL", "true")
+	t.Setenv("CONTEXT_BUDGET", "2000")
+	t.Setenv("CHAT_REPLY_RETRIES", "3")
+	t.Setenv("KNOWLEDGE_EXPIRY_INTERVAL", "")
+	t.Setenv("LOG_FORMAT", "json")
+	t.Setenv("LOG_MAX_SIZE_MB", "1")
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if config.LLM.Provider != "ollama" || config.LLM.Model != "llama3" {
+		t.Errorf("Expected the environment to override the model, got %+v", config.LLM)
+	}
+	if config.Store.Backend != StoreS3 || !config.Store.Backfill {
+		t.Errorf("Expected the S3 backend with backfill, got %+v", config.Store)
+	}
+	if config.Intervals.Expiry != 5*time.Minute || config.Intervals.StoreFlush != 5*time.Second {
+		t.Errorf("Unexpected intervals: %+v", config.Intervals)
+	}
+	if config.Bus.History != 50 || config.Agent.ContextBudget != 2000 {
+		t.Errorf("Unexpected settings: %+v %+v", config.Bus, config.Agent)
+	}
+	if config.Chat.ReplyRetries != 3 || config.Timeouts.ChatReply != time.Minute {
+		t.Errorf("Unexpected chat settings: %+v %+v", config.Chat, config.Timeouts)
+	}
+	if options := config.Logging.Options(); options.Format != logging.FormatJSON || options.Level != logging.LevelDebug || options.Rotation.MaxSize != 1024*1024 {
+		t.Errorf("Unexpected logging options: %+v", options)
+	}
+}
+
+func TestLoadRejectsInvalidSettings(t *testing.T) {
+	tests := map[string]string{
+		"unknown store backend": "store:\n  backend: tape\n",
+		"needs a bucket":        "store:\n  backend: s3\n",
+		"not both":              "bus:\n  nats_url: nats://localhost\n  redis_url: redis://localhost\n",
+		"must be positive":      "intervals:\n  expiry: 0s\n",
+		"failed to parse":       "llm: [",
+		"another provider":      "llm:\n  provider: ollama\n  fallbacks: [ollama]\n",
+		"race needs fallbacks":  "llm:\n  race: true\n",
+		"not a valid regular":   "guardrails:\n  denylist:\n    - pattern: \"(\"\n",
+		"block, redact or warn": "guardrails:\n  moderation:\n    direction: inbound\n    action: delete\n",
+		"chat_reply must be":    "timeouts:\n  chat_reply: -1s\n",
+		"shutdown must be":      "timeouts:\n  shutdown: 0s\n",
+		"reply_retries cannot":  "chat:\n  reply_retries: -1\n",
+		"heartbeat must be":     "intervals:\n  heartbeat: 0s\n",
+		"plan_steps cannot":     "agent:\n  plan_steps: -1\n",
+		"inbox_size cannot":     "agent:\n  inbox_size: -1\n",
+		"not an http or https":  "tracing:\n  otlp_endpoint: localhost:4318\n",
+		"unknown log format":    "logging:\n  format: xml\n",
+		"logging level of llm":  "logging:\n  components:\n    llm: verbose\n",
+		"max_backups cannot":    "logging:\n  max_backups: -1\n",
+		"unknown trace level":   "tracing:\n  level: chatty\n",
+		"needs a password":      "server:\n  auth:\n    users:\n      alice:\n        entity_id: alice\n",
+		"needs a unique name":   "server:\n  auth:\n    oidc:\n      - issuer: https://accounts.google.com\n        client_id: app\n        redirect_url: https://host/cb\n",
+	}
+	for want, content := range tests {
+		if _, err := Load(writeConfig(t, content)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error containing %q, got %v", want, err)
+		}
+	}
+
+	t.Setenv("CONTEXT_BUDGET", "lots")
+	if _, err := Load(writeConfig(t, "")); err == nil || !strings.Contains(err.Error(), "CONTEXT_BUDGET") {
+		t.Errorf("Expected an invalid environment variable error, got %v", err)
+	}
+}
+
+func TestProviderSettings(t *testing.T) {
+	path := writeConfig(t, `
+llm:
+  provider: openai
+  temperature: 0.2
+  providers:
+    openai:
+      api_key: sk-test
+      model: gpt-4o-mini
+`)
+	t.Setenv("LLM_MODEL", "")
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	settings := config.LLM.ProviderSettings("openai")
+	if settings["api_key"] != "sk-test" || settings["model"] != "gpt-4o-mini" || settings["temperature"] != "0.2" {
+		t.Errorf("Unexpected provider settings: %v", settings)
+	}
+	if config.LLM.Providers["lmstudio"]["model"] != "gemma-3-4b-it" {
+		t.Errorf("Expected the default lmstudio section to stay, got %v", config.LLM.Providers)
+	}
+
+	config.LLM.Model = "gpt-4o"
+	if model := config.LLM.ProviderSettings("openai")["model"]; model != "gpt-4o" {
+		t.Errorf("Expected the top-level model to win, got %q", model)
+	}
+}
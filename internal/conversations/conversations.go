@@ -0,0 +1,258 @@
+// Package conversations answers questions about the conversations stored as
+// knowledge.CategoryMessage entries, so UI layers do not have to build knowledge
+// filters over message metadata themselves.
+package conversations
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"goproduct/internal/knowledge"
+)
+
+// Metadata keys of message entries, as written by the importer
+const (
+	MetadataConversationID    = "conversation_id"
+	MetadataConversationTitle = "conversation_title"
+	MetadataSource            = "source"
+	MetadataSpeaker           = "speaker"
+	MetadataRole              = "role"
+	MetadataMessageIndex      = "message_index"
+)
+
+// TimeRange limits results to a period. A zero From or To leaves that side open.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// contains reports whether t lies within the range
+func (r TimeRange) contains(t time.Time) bool {
+	return (r.From.IsZero() || !t.Before(r.From)) && (r.To.IsZero() || !t.After(r.To))
+}
+
+// Page selects a slice of a thread. A zero Limit returns everything after Offset.
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// Conversation summarizes one conversation
+type Conversation struct {
+	ID            string    // Conversation ID
+	Title         string    // Conversation title or channel name
+	Source        string    // Tool the conversation came from, e.g. "slack"
+	Participants  []string  // Entity IDs taking part
+	StartedAt     time.Time // Time of the first message
+	LastMessageAt time.Time // Time of the last message
+	MessageCount  int       // Number of messages
+}
+
+// Message is one utterance of a conversation
+type Message struct {
+	ID             string    // Knowledge entry ID
+	ConversationID string    // Conversation the message belongs to
+	Index          int       // Position in the conversation
+	Speaker        string    // Speaker as named by the source tool
+	Role           string    // "user", "assistant" or "system"
+	OwnerID        string    // Entity that sent the message
+	Text           string    // Message text
+	Timestamp      time.Time // When the message was sent
+}
+
+// Thread is a page of the messages of a conversation
+type Thread struct {
+	Conversation Conversation
+	Messages     []Message // Messages of the page in chronological order
+	Total        int       // Messages in the whole conversation
+	HasMore      bool      // More messages follow the page
+}
+
+// Repository runs conversation queries against a knowledge store
+type Repository struct {
+	store knowledge.Store
+}
+
+// NewRepository creates a repository reading from the given store
+func NewRepository(store knowledge.Store) *Repository {
+	return &Repository{store: store}
+}
+
+// ListConversations returns the conversations the participant took part in with at
+// least one message in the time range, most recently active first. An empty
+// participant lists all conversations.
+func (r *Repository) ListConversations(participant string, timeRange TimeRange) ([]Conversation, error) {
+	query := knowledge.Query().Where("Category", "=", knowledge.CategoryMessage)
+	if participant != "" {
+		query = query.Or(
+			knowledge.Query().Where("SubjectIDs", "CONTAINS", participant),
+			knowledge.Query().Where("OwnerID", "=", participant),
+		)
+	}
+	entries, err := r.search(query)
+	if err != nil {
+		return nil, err
+	}
+
+	grouped := make(map[string][]Message)
+	titles := make(map[string]knowledge.Entry)
+	active := make(map[string]bool)
+	for _, entry := range entries {
+		message := toMessage(entry)
+		grouped[message.ConversationID] = append(grouped[message.ConversationID], message)
+		titles[message.ConversationID] = entry
+		if timeRange.contains(message.Timestamp) {
+			active[message.ConversationID] = true
+		}
+	}
+
+	result := make([]Conversation, 0, len(active))
+	for id := range active {
+		result = append(result, summarize(titles[id], grouped[id]))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].LastMessageAt.Equal(result[j].LastMessageAt) {
+			return result[i].LastMessageAt.After(result[j].LastMessageAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// GetThread returns a page of the messages of a conversation in chronological order
+func (r *Repository) GetThread(conversationID string, page Page) (Thread, error) {
+	if page.Offset < 0 || page.Limit < 0 {
+		return Thread{}, fmt.Errorf("invalid page: offset %d, limit %d", page.Offset, page.Limit)
+	}
+	entries, err := r.search(knowledge.Query().
+		Where("Category", "=", knowledge.CategoryMessage).
+		WhereMetadata(MetadataConversationID, conversationID))
+	if err != nil {
+		return Thread{}, err
+	}
+	if len(entries) == 0 {
+		return Thread{}, fmt.Errorf("conversation %s not found", conversationID)
+	}
+
+	messages := make([]Message, 0, len(entries))
+	for _, entry := range entries {
+		messages = append(messages, toMessage(entry))
+	}
+	sortMessages(messages)
+
+	thread := Thread{Conversation: summarize(entries[0], messages), Total: len(messages)}
+	start := min(page.Offset, len(messages))
+	end := len(messages)
+	if page.Limit > 0 {
+		end = min(start+page.Limit, len(messages))
+	}
+	thread.Messages = messages[start:end]
+	thread.HasMore = end < len(messages)
+	return thread, nil
+}
+
+// SearchUtterances returns the messages matching the text, best match first. A
+// non-empty speaker keeps the messages sent by that entity or source speaker. An empty
+// text returns all messages of the speaker in chronological order.
+func (r *Repository) SearchUtterances(text, speaker string) ([]Message, error) {
+	if text == "" && speaker == "" {
+		return nil, errors.New("search needs a text or a speaker")
+	}
+
+	if text == "" {
+		entries, err := r.search(knowledge.Query().
+			Where("Category", "=", knowledge.CategoryMessage).
+			Or(
+				knowledge.Query().Where("OwnerID", "=", speaker),
+				knowledge.Query().WhereMetadata(MetadataSpeaker, speaker),
+			))
+		if err != nil {
+			return nil, err
+		}
+		messages := make([]Message, 0, len(entries))
+		for _, entry := range entries {
+			messages = append(messages, toMessage(entry))
+		}
+		sortMessages(messages)
+		return messages, nil
+	}
+
+	results, err := r.store.FullTextSearch(text, knowledge.WithSearchCategories(knowledge.CategoryMessage))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	messages := make([]Message, 0, len(results))
+	for _, result := range results {
+		message := toMessage(result.Entry)
+		if speaker != "" && message.OwnerID != speaker && message.Speaker != speaker {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// search runs the query against the store
+func (r *Repository) search(query *knowledge.QueryBuilder) ([]knowledge.Entry, error) {
+	filter, err := query.Build()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := r.store.SearchRecords(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	return entries, nil
+}
+
+// toMessage converts a message entry
+func toMessage(entry knowledge.Entry) Message {
+	index, _ := strconv.Atoi(entry.Metadata[MetadataMessageIndex])
+	conversationID := entry.Metadata[MetadataConversationID]
+	if conversationID == "" {
+		conversationID = entry.SourceID
+	}
+	return Message{
+		ID:             entry.ID,
+		ConversationID: conversationID,
+		Index:          index,
+		Speaker:        entry.Metadata[MetadataSpeaker],
+		Role:           entry.Metadata[MetadataRole],
+		OwnerID:        entry.OwnerID,
+		Text:           string(entry.Content),
+		Timestamp:      entry.CreatedAt,
+	}
+}
+
+// sortMessages orders messages by time, then by their position in the conversation
+func sortMessages(messages []Message) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		if !messages[i].Timestamp.Equal(messages[j].Timestamp) {
+			return messages[i].Timestamp.Before(messages[j].Timestamp)
+		}
+		return messages[i].Index < messages[j].Index
+	})
+}
+
+// summarize builds the summary of a conversation from one of its entries and its messages
+func summarize(entry knowledge.Entry, messages []Message) Conversation {
+	conversation := Conversation{
+		ID:           toMessage(entry).ConversationID,
+		Title:        entry.Metadata[MetadataConversationTitle],
+		Source:       entry.Metadata[MetadataSource],
+		Participants: entry.SubjectIDs,
+		MessageCount: len(messages),
+	}
+	for _, message := range messages {
+		if conversation.StartedAt.IsZero() || message.Timestamp.Before(conversation.StartedAt) {
+			conversation.StartedAt = message.Timestamp
+		}
+		if message.Timestamp.After(conversation.LastMessageAt) {
+			conversation.LastMessageAt = message.Timestamp
+		}
+	}
+	return conversation
+}
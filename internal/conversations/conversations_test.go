@@ -0,0 +1,123 @@
+package conversations
+
+import (
+	"testing"
+	"time"
+
+	"goproduct/internal/importer"
+	"goproduct/internal/knowledge"
+)
+
+var base = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+	store, _ := knowledge.NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	owners := importer.OwnerMap{
+		Speakers:     map[string]string{"U1": "alice", "U2": "bob"},
+		DefaultAgent: "agent",
+	}
+	_, err := importer.NewImporter(store, owners).Import([]importer.Conversation{
+		{
+			ID: "c1", Title: "Pricing", Source: importer.SourceSlack, CreatedAt: base,
+			Utterances: []importer.Utterance{
+				{ID: "1", Speaker: "U1", Role: "user", Text: "What should the pricing tiers be?", Timestamp: base},
+				{ID: "2", Speaker: "U2", Role: "user", Text: "Three tiers, with a free plan", Timestamp: base.Add(time.Minute)},
+				{ID: "3", Speaker: "U1", Role: "user", Text: "Agreed on the free plan", Timestamp: base.Add(2 * time.Minute)},
+			},
+		},
+		{
+			ID: "c2", Title: "Hiring", Source: importer.SourceSlack, CreatedAt: base.Add(48 * time.Hour),
+			Utterances: []importer.Utterance{
+				{ID: "1", Speaker: "U2", Role: "user", Text: "We need a designer", Timestamp: base.Add(48 * time.Hour)},
+				{ID: "2", Speaker: "bot", Role: "assistant", Text: "I drafted the job post", Timestamp: base.Add(49 * time.Hour)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to import conversations: %v", err)
+	}
+	return NewRepository(store)
+}
+
+func TestListConversations(t *testing.T) {
+	repo := newTestRepository(t)
+
+	all, err := repo.ListConversations("", TimeRange{})
+	if err != nil {
+		t.Fatalf("ListConversations failed: %v", err)
+	}
+	if len(all) != 2 || all[0].ID != "c2" || all[1].ID != "c1" {
+		t.Fatalf("Expected c2 then c1, got %+v", all)
+	}
+	if all[1].Title != "Pricing" || all[1].MessageCount != 3 || !all[1].StartedAt.Equal(base) || !all[1].LastMessageAt.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("Unexpected summary: %+v", all[1])
+	}
+
+	alice, _ := repo.ListConversations("alice", TimeRange{})
+	if len(alice) != 1 || alice[0].ID != "c1" {
+		t.Errorf("Expected only c1 for alice, got %+v", alice)
+	}
+
+	recent, _ := repo.ListConversations("bob", TimeRange{From: base.Add(24 * time.Hour)})
+	if len(recent) != 1 || recent[0].ID != "c2" {
+		t.Errorf("Expected only c2 in the range, got %+v", recent)
+	}
+}
+
+func TestGetThread(t *testing.T) {
+	repo := newTestRepository(t)
+
+	thread, err := repo.GetThread("c1", Page{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetThread failed: %v", err)
+	}
+	if thread.Total != 3 || !thread.HasMore || len(thread.Messages) != 2 {
+		t.Fatalf("Unexpected thread: %+v", thread)
+	}
+	if thread.Messages[0].Speaker != "U1" || thread.Messages[1].OwnerID != "bob" || thread.Conversation.Title != "Pricing" {
+		t.Errorf("Unexpected messages: %+v", thread.Messages)
+	}
+
+	thread, _ = repo.GetThread("c1", Page{Offset: 2, Limit: 2})
+	if len(thread.Messages) != 1 || thread.HasMore || thread.Messages[0].Text != "Agreed on the free plan" {
+		t.Errorf("Unexpected last page: %+v", thread)
+	}
+
+	if _, err := repo.GetThread("missing", Page{}); err == nil {
+		t.Error("Expected error for an unknown conversation")
+	}
+	if _, err := repo.GetThread("c1", Page{Offset: -1}); err == nil {
+		t.Error("Expected error for a negative offset")
+	}
+}
+
+func TestSearchUtterances(t *testing.T) {
+	repo := newTestRepository(t)
+
+	messages, err := repo.SearchUtterances("free plan", "")
+	if err != nil {
+		t.Fatalf("SearchUtterances failed: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Errorf("Expected 2 messages about the free plan, got %+v", messages)
+	}
+
+	messages, _ = repo.SearchUtterances("free plan", "alice")
+	if len(messages) != 1 || messages[0].Text != "Agreed on the free plan" {
+		t.Errorf("Expected alice's message, got %+v", messages)
+	}
+
+	messages, _ = repo.SearchUtterances("", "U2")
+	if len(messages) != 2 || messages[0].ConversationID != "c1" || messages[1].ConversationID != "c2" {
+		t.Errorf("Expected bob's messages in order, got %+v", messages)
+	}
+
+	if _, err := repo.SearchUtterances("", ""); err == nil {
+		t.Error("Expected error without text or speaker")
+	}
+}
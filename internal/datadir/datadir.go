@@ -0,0 +1,203 @@
+// Package datadir manages the on-disk layout of an application profile: knowledge,
+// logs and traces live in a versioned directory structure that is migrated on startup.
+package datadir
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultPath is the data directory used when none is given
+const DefaultPath = "./data"
+
+// LayoutVersion is the layout written by this version of the application
+const LayoutVersion = 1
+
+// layoutFile records the layout version of a data directory
+const layoutFile = "layout.json"
+
+// Subdirectories of the current layout
+const (
+	knowledgeDir = "knowledge"
+	logsDir      = "logs"
+	legacyDir    = "legacy" // Legacy files that could not be moved because the new file already existed
+)
+
+// layoutInfo is the content of the layout file
+type layoutInfo struct {
+	Version    int       `json:"version"`
+	MigratedAt time.Time `json:"migratedAt"`
+}
+
+// Migration describes a file moved during a layout migration
+type Migration struct {
+	From string // Old path
+	To   string // New path
+}
+
+// migrations upgrade a data directory from the version at their index to the next one
+var migrations = []func(d *Dir) ([]Migration, error){
+	migrateLegacyFiles, // 0 -> 1: ad-hoc files in the root of the data directory
+}
+
+// Dir is a data directory holding one independent profile
+type Dir struct {
+	root string
+}
+
+// New returns the data directory at root. It does not touch the file system; call
+// Prepare before using the paths.
+func New(root string) *Dir {
+	if root == "" {
+		root = DefaultPath
+	}
+	return &Dir{root: root}
+}
+
+// Root returns the data directory itself
+func (d *Dir) Root() string {
+	return d.root
+}
+
+// KnowledgeFile returns the path of the file-based knowledge store
+func (d *Dir) KnowledgeFile() string {
+	return filepath.Join(d.root, knowledgeDir, "memories.json")
+}
+
+// AppLog returns the path of the application log
+func (d *Dir) AppLog() string {
+	return filepath.Join(d.root, logsDir, "app.log")
+}
+
+// TraceLog returns the path of the trace log
+func (d *Dir) TraceLog() string {
+	return filepath.Join(d.root, logsDir, "trace.log")
+}
+
+// AdminSocket returns the path of the local socket serving admin commands
+func (d *Dir) AdminSocket() string {
+	return filepath.Join(d.root, "admin.sock")
+}
+
+// ExportProfiles returns the path of the optional file defining export redaction profiles
+func (d *Dir) ExportProfiles() string {
+	return filepath.Join(d.root, "export_profiles.json")
+}
+
+// TeamConfig returns the path of the optional file grouping agents and routing
+// conversations between them
+func (d *Dir) TeamConfig() string {
+	return filepath.Join(d.root, "team.json")
+}
+
+// Config returns the path of the optional configuration file
+func (d *Dir) Config() string {
+	return filepath.Join(d.root, "config.yaml")
+}
+
+// Personas returns the directory of the persona files that add to or override the
+// built-in personas
+func (d *Dir) Personas() string {
+	return filepath.Join(d.root, "personas")
+}
+
+// Prompts returns the directory of the prompt templates that add to or override the
+// built-in templates; its subdirectories hold the overrides of single personas
+func (d *Dir) Prompts() string {
+	return filepath.Join(d.root, "prompts")
+}
+
+// Version returns the layout version of the directory, 0 for a legacy or new directory
+func (d *Dir) Version() (int, error) {
+	data, err := os.ReadFile(filepath.Join(d.root, layoutFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read data directory layout: %w", err)
+	}
+
+	var info layoutInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return 0, fmt.Errorf("failed to parse data directory layout: %w", err)
+	}
+	return info.Version, nil
+}
+
+// Prepare creates the directory structure and migrates files of older layouts into it.
+// It returns the files that were moved.
+func (d *Dir) Prepare() ([]Migration, error) {
+	version, err := d.Version()
+	if err != nil {
+		return nil, err
+	}
+	if version > LayoutVersion {
+		return nil, fmt.Errorf("data directory %s has layout version %d, newer than the supported %d", d.root, version, LayoutVersion)
+	}
+
+	for _, dir := range []string{d.root, filepath.Join(d.root, knowledgeDir), filepath.Join(d.root, logsDir)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create data directory %s: %w", dir, err)
+		}
+	}
+
+	moved := make([]Migration, 0)
+	for ; version < LayoutVersion; version++ {
+		done, err := migrations[version](d)
+		moved = append(moved, done...)
+		if err != nil {
+			return moved, fmt.Errorf("failed to migrate data directory from layout version %d: %w", version, err)
+		}
+	}
+
+	if err := d.writeVersion(); err != nil {
+		return moved, err
+	}
+	return moved, nil
+}
+
+// writeVersion records the current layout version
+func (d *Dir) writeVersion() error {
+	data, err := json.MarshalIndent(layoutInfo{Version: LayoutVersion, MigratedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal data directory layout: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(d.root, layoutFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write data directory layout: %w", err)
+	}
+	return nil
+}
+
+// migrateLegacyFiles moves the files of the original ad-hoc layout into their subdirectories
+func migrateLegacyFiles(d *Dir) ([]Migration, error) {
+	legacy := []Migration{
+		{From: filepath.Join(d.root, "memories.json"), To: d.KnowledgeFile()},
+		{From: filepath.Join(d.root, "app.log"), To: d.AppLog()},
+		{From: filepath.Join(d.root, "trace.log"), To: d.TraceLog()},
+	}
+
+	moved := make([]Migration, 0, len(legacy))
+	for _, migration := range legacy {
+		if _, err := os.Stat(migration.From); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		// Never overwrite data: park the legacy file if the new one already exists
+		if _, err := os.Stat(migration.To); err == nil {
+			migration.To = filepath.Join(d.root, legacyDir, filepath.Base(migration.From))
+			if err := os.MkdirAll(filepath.Dir(migration.To), 0755); err != nil {
+				return moved, fmt.Errorf("failed to create legacy directory: %w", err)
+			}
+		}
+
+		if err := os.Rename(migration.From, migration.To); err != nil {
+			return moved, fmt.Errorf("failed to move %s: %w", migration.From, err)
+		}
+		moved = append(moved, migration)
+	}
+	return moved, nil
+}
@@ -0,0 +1,104 @@
+package datadir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestPrepareNewDirectory(t *testing.T) {
+	dir := New(filepath.Join(t.TempDir(), "profile"))
+
+	moved, err := dir.Prepare()
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if len(moved) != 0 {
+		t.Errorf("Expected nothing to migrate, got %v", moved)
+	}
+	if version, _ := dir.Version(); version != LayoutVersion {
+		t.Errorf("Expected layout version %d, got %d", LayoutVersion, version)
+	}
+	for _, path := range []string{filepath.Dir(dir.KnowledgeFile()), filepath.Dir(dir.AppLog())} {
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			t.Errorf("Expected directory %s to exist", path)
+		}
+	}
+}
+
+func TestPrepareMigratesLegacyFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "memories.json"), `{"records":{}}`)
+	writeFile(t, filepath.Join(root, "app.log"), "old log")
+	writeFile(t, filepath.Join(root, "trace.log"), "old trace")
+
+	dir := New(root)
+	moved, err := dir.Prepare()
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if len(moved) != 3 {
+		t.Errorf("Expected 3 migrated files, got %v", moved)
+	}
+	if content := readFile(t, dir.KnowledgeFile()); content != `{"records":{}}` {
+		t.Errorf("Unexpected knowledge file content: %q", content)
+	}
+	if content := readFile(t, dir.TraceLog()); content != "old trace" {
+		t.Errorf("Unexpected trace log content: %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(root, "memories.json")); !os.IsNotExist(err) {
+		t.Error("Expected legacy knowledge file to be moved")
+	}
+
+	// Running again is a no-op
+	moved, err = dir.Prepare()
+	if err != nil || len(moved) != 0 {
+		t.Errorf("Expected second Prepare to do nothing, got %v, %v", moved, err)
+	}
+}
+
+func TestPrepareKeepsExistingFiles(t *testing.T) {
+	root := t.TempDir()
+	dir := New(root)
+	writeFile(t, filepath.Join(root, "app.log"), "legacy")
+	writeFile(t, dir.AppLog(), "current")
+
+	moved, err := dir.Prepare()
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if len(moved) != 1 || moved[0].To != filepath.Join(root, "legacy", "app.log") {
+		t.Errorf("Expected legacy log to be parked, got %v", moved)
+	}
+	if content := readFile(t, dir.AppLog()); content != "current" {
+		t.Errorf("Expected current log to be kept, got %q", content)
+	}
+}
+
+func TestPrepareRejectsNewerLayout(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "layout.json"), `{"version": 99}`)
+
+	if _, err := New(root).Prepare(); err == nil {
+		t.Error("Expected error for a newer layout version")
+	}
+}
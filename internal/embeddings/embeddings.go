@@ -0,0 +1,204 @@
+package embeddings
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Error types for vector operations
+var (
+	ErrEmptyVector       = errors.New("vector is empty")
+	ErrDimensionMismatch = errors.New("vector dimensions do not match")
+	ErrZeroVector        = errors.New("vector has zero magnitude")
+)
+
+// Match is a single similarity search hit
+type Match struct {
+	ID    string  // Identifier the vector was added with
+	Score float32 // Cosine similarity to the query, from -1 to 1
+}
+
+// CosineSimilarity returns the cosine similarity of two vectors of equal length.
+// It returns 0 if either vector has zero magnitude.
+func CosineSimilarity(a, b []float32) (float32, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("%w: %d != %d", ErrDimensionMismatch, len(a), len(b))
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0, nil
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB))), nil
+}
+
+// Normalize returns a copy of the vector scaled to unit length
+func Normalize(v []float32) ([]float32, error) {
+	if len(v) == 0 {
+		return nil, ErrEmptyVector
+	}
+
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return nil, ErrZeroVector
+	}
+
+	norm := math.Sqrt(sum)
+	result := make([]float32, len(v))
+	for i, x := range v {
+		result[i] = float32(float64(x) / norm)
+	}
+	return result, nil
+}
+
+// Index is an in-memory cosine-similarity index. Vectors are normalized when added,
+// so a search is a dot product per entry. All vectors must have the same dimensions,
+// which are fixed by the first vector added. It is safe for concurrent use.
+type Index struct {
+	mu         sync.RWMutex
+	vectors    map[string][]float32
+	dimensions int
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		vectors: make(map[string][]float32),
+	}
+}
+
+// Dimensions returns the vector dimensions of the index, 0 while it is empty
+func (x *Index) Dimensions() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.dimensions
+}
+
+// Len returns the number of vectors in the index
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.vectors)
+}
+
+// Validate checks if the vector could be added to the index for an ID
+func (x *Index) Validate(id string, vector []float32) error {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.validate(id, vector)
+}
+
+// validate checks a vector against the index dimensions (must be called with lock held).
+// An empty id validates a query rather than a vector to be stored.
+func (x *Index) validate(id string, vector []float32) error {
+	if len(vector) == 0 {
+		return ErrEmptyVector
+	}
+	zero := true
+	for _, v := range vector {
+		if v != 0 {
+			zero = false
+			break
+		}
+	}
+	if zero {
+		return ErrZeroVector
+	}
+
+	// Replacing the only vector may change the dimensions
+	dimensions := x.dimensions
+	if _, replacing := x.vectors[id]; replacing && len(x.vectors) == 1 {
+		dimensions = 0
+	}
+	if dimensions != 0 && len(vector) != dimensions {
+		return fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, dimensions, len(vector))
+	}
+	return nil
+}
+
+// Add adds or replaces the vector for an ID
+func (x *Index) Add(id string, vector []float32) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if err := x.validate(id, vector); err != nil {
+		return err
+	}
+	normalized, err := Normalize(vector)
+	if err != nil {
+		return err
+	}
+
+	x.vectors[id] = normalized
+	x.dimensions = len(vector)
+	return nil
+}
+
+// Remove removes the vector for an ID, if present
+func (x *Index) Remove(id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	delete(x.vectors, id)
+	if len(x.vectors) == 0 {
+		x.dimensions = 0
+	}
+}
+
+// Search returns up to topK IDs most similar to the query, best first.
+// A topK of 0 or less returns all matches.
+func (x *Index) Search(query []float32, topK int) ([]Match, error) {
+	return x.SearchFunc(query, topK, nil)
+}
+
+// SearchFunc is like Search but only considers IDs accepted by the keep function
+func (x *Index) SearchFunc(query []float32, topK int, keep func(id string) bool) ([]Match, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	if len(x.vectors) == 0 {
+		return []Match{}, nil
+	}
+	if err := x.validate("", query); err != nil {
+		return nil, err
+	}
+	normalized, err := Normalize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]Match, 0, len(x.vectors))
+	for id, vector := range x.vectors {
+		if keep != nil && !keep(id) {
+			continue
+		}
+		var dot float32
+		for i := range vector {
+			dot += vector[i] * normalized[i]
+		}
+		matches = append(matches, Match{ID: id, Score: dot})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	if topK > 0 && len(matches) > topK {
+		matches = matches[:topK]
+	}
+	return matches, nil
+}
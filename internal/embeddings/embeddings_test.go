@@ -0,0 +1,104 @@
+package embeddings
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float32
+	}{
+		{"identical", []float32{1, 2, 3}, []float32{1, 2, 3}, 1},
+		{"scaled", []float32{1, 2, 3}, []float32{2, 4, 6}, 1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"opposite", []float32{1, 1}, []float32{-1, -1}, -1},
+		{"zero vector", []float32{0, 0}, []float32{1, 1}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CosineSimilarity(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if math.Abs(float64(got-tt.want)) > 1e-6 {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := CosineSimilarity([]float32{1}, []float32{1, 2}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	v, err := Normalize([]float32{3, 4})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if math.Abs(float64(v[0])-0.6) > 1e-6 || math.Abs(float64(v[1])-0.8) > 1e-6 {
+		t.Errorf("Expected [0.6 0.8], got %v", v)
+	}
+
+	if _, err := Normalize(nil); !errors.Is(err, ErrEmptyVector) {
+		t.Errorf("Expected ErrEmptyVector, got %v", err)
+	}
+	if _, err := Normalize([]float32{0, 0}); !errors.Is(err, ErrZeroVector) {
+		t.Errorf("Expected ErrZeroVector, got %v", err)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	index := NewIndex()
+
+	if err := index.Add("x", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Failed to add vector: %v", err)
+	}
+	index.Add("xy", []float32{1, 1, 0})
+	index.Add("y", []float32{0, 1, 0})
+	index.Add("neg", []float32{-1, 0, 0})
+
+	if index.Dimensions() != 3 || index.Len() != 4 {
+		t.Fatalf("Expected 4 vectors of 3 dimensions, got %d of %d", index.Len(), index.Dimensions())
+	}
+
+	matches, err := index.Search([]float32{2, 0.1, 0}, 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(matches) != 2 || matches[0].ID != "x" || matches[1].ID != "xy" {
+		t.Errorf("Expected [x xy], got %+v", matches)
+	}
+
+	matches, _ = index.SearchFunc([]float32{1, 0, 0}, 0, func(id string) bool { return id != "x" })
+	if len(matches) != 3 || matches[0].ID != "xy" || matches[2].ID != "neg" {
+		t.Errorf("Expected [xy y neg] without x, got %+v", matches)
+	}
+
+	if err := index.Add("bad", []float32{1, 2}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	if err := index.Add("zero", []float32{0, 0, 0}); !errors.Is(err, ErrZeroVector) {
+		t.Errorf("Expected ErrZeroVector, got %v", err)
+	}
+	if _, err := index.Search([]float32{1, 2}, 1); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch for query, got %v", err)
+	}
+
+	// Dimensions reset once the index is empty or its only vector is replaced
+	for _, id := range []string{"x", "xy", "y"} {
+		index.Remove(id)
+	}
+	if err := index.Add("neg", []float32{1, 2}); err != nil {
+		t.Errorf("Expected replacing the only vector to allow new dimensions, got %v", err)
+	}
+	index.Remove("neg")
+	if index.Dimensions() != 0 {
+		t.Errorf("Expected dimensions to reset, got %d", index.Dimensions())
+	}
+}
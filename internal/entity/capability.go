@@ -1,7 +1,10 @@
 package entity
 
 import (
+	"context"
+	"goproduct/internal/agent"
 	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
 )
 
 // MessageHandler is a capability for entities that can process messages
@@ -57,3 +60,48 @@ type ChatParticipant interface {
 	// ActiveChats returns the list of active chats
 	ActiveChats() []string
 }
+
+// MessageDrafter is a capability for entities that can draft messages on behalf of others
+type MessageDrafter interface {
+	// DraftMessage drafts a message the sender will send to the recipient, following the instruction
+	DraftMessage(ctx context.Context, senderName, recipientName, instruction string) (string, error)
+}
+
+// SelfChecker is a capability for entities that can audit their own knowledge
+type SelfChecker interface {
+	// SelfCheck quizzes the entity on up to count important knowledge entries
+	SelfCheck(ctx context.Context, count int) ([]agent.SelfCheckResult, error)
+}
+
+// LanguageSelector is a capability for entities that can converse in several languages
+type LanguageSelector interface {
+	// Language returns the language the entity currently responds in
+	Language() string
+
+	// Languages returns the languages the entity can respond in
+	Languages() []string
+
+	// SetLanguage switches the language the entity responds in
+	SetLanguage(language string) error
+}
+
+// HistoryRestorer is a capability for entities that can continue an earlier conversation
+type HistoryRestorer interface {
+	// RestoreHistory puts the user and assistant turns of an earlier session back into the
+	// entity's conversation context
+	RestoreHistory(messages []llm.Message)
+}
+
+// MessageCanceller is a capability for entities whose answers to messages can be cancelled
+type MessageCanceller interface {
+	// CancelMessage stops answering the message with the ID, returning whether an answer
+	// was being generated
+	CancelMessage(messageID string) bool
+}
+
+// ThreadCloser is a capability for entities that keep a separate context per conversation
+// thread, see messaging.MetadataThreadID
+type ThreadCloser interface {
+	// CloseThread forgets the context of the thread
+	CloseThread(threadID string)
+}
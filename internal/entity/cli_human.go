@@ -35,7 +35,7 @@ type CliHumanEntity struct {
 func NewCliHumanEntity(name string, bus messaging.MessageBus) *CliHumanEntity {
 	now := time.Now()
 	ctx, cancel := context.WithCancel(context.Background())
-	logger := logging.Get()
+	logger := logging.Get().Component("entity")
 
 	return &CliHumanEntity{
 		id:                   uuid.New().String(),
@@ -148,6 +148,8 @@ func (c *CliHumanEntity) Type() EntityType {
 }
 
 func (c *CliHumanEntity) Status() EntityStatus {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return c.status
 }
 
@@ -294,7 +296,7 @@ func (c *CliHumanEntity) ReceiveMessage(msg messaging.Message) error {
 
 func (c *CliHumanEntity) SendMessage(recipients []string, contentType string, content []byte) (messaging.Message, error) {
 	// Create and send the message
-	msg := messaging.NewMessage(c.id, recipients, contentType, content)
+	msg := messaging.NewMessage(c.id, recipients, contentType, content).WithCorrelation()
 	c.logger.Debug("Human sending message",
 		"entity_id", c.id,
 		"message_id", msg.ID,
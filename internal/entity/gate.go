@@ -0,0 +1,67 @@
+package entity
+
+import "sync"
+
+// Defaults of the concurrency gate of a product agent
+const (
+	DefaultMaxConcurrent = 4  // Messages handed to the agent at once
+	DefaultInboxSize     = 32 // Messages waiting for their turn
+)
+
+// MetadataBusy marks the reply to a message turned away because the agent had too many
+// messages to answer already
+const MetadataBusy = "busy"
+
+// gate bounds the messages a product agent answers at once and those waiting their turn,
+// so that a flood of messages does not become as many language model calls
+type gate struct {
+	slots    chan struct{} // Held by the messages being answered
+	capacity int           // Messages answered or waiting at most
+	mutex    sync.Mutex
+	admitted int // Messages answered or waiting
+}
+
+// newGate creates a gate answering limit messages at once with inbox more waiting, or nil
+// for no limit if limit is not positive
+func newGate(limit, inbox int) *gate {
+	if limit <= 0 {
+		return nil
+	}
+	return &gate{
+		slots:    make(chan struct{}, limit),
+		capacity: limit + max(inbox, 0),
+	}
+}
+
+// admit takes a message in, false if the agent is saturated. An admitted message waits
+// for its turn with wait and must be released.
+func (g *gate) admit() bool {
+	if g == nil {
+		return true
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.admitted >= g.capacity {
+		return false
+	}
+	g.admitted++
+	return true
+}
+
+// wait blocks until an admitted message may be answered
+func (g *gate) wait() {
+	if g != nil {
+		g.slots <- struct{}{}
+	}
+}
+
+// release frees the place of an answered message
+func (g *gate) release() {
+	if g == nil {
+		return
+	}
+	<-g.slots
+	g.mutex.Lock()
+	g.admitted--
+	g.mutex.Unlock()
+}
@@ -2,8 +2,12 @@ package entity
 
 import (
 	"context"
+	"fmt"
 	"goproduct/internal/agent"
+	"goproduct/internal/llm"
 	"goproduct/internal/messaging"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,12 +25,38 @@ type ProductAgentEntity struct {
 	messageBus messaging.MessageBus
 	roles      map[Role]bool
 	metadata   Metadata
+	team       *AgentTeam   // Team routing the agent's conversations, nil when on its own
+	gate       *gate        // Bounds the messages answered at once and waiting, nil for no limit
+	mutex      sync.RWMutex // Protects status, read by heartbeats
+}
+
+// partialInterval is the least time between the partial replies of a streamed reply
+const partialInterval = 50 * time.Millisecond
+
+// ProductAgentOption configures a ProductAgentEntity
+type ProductAgentOption func(*ProductAgentEntity)
+
+// WithAgentEntityID gives the agent a stable entity ID instead of a random one, so it can
+// be addressed by configuration, e.g. "architect"
+func WithAgentEntityID(id string) ProductAgentOption {
+	return func(p *ProductAgentEntity) {
+		p.id = id
+	}
+}
+
+// WithConcurrency sets how many messages the agent answers at once and how many more wait
+// for their turn; messages beyond that are answered that the agent is busy. A limit that
+// is not positive takes every message. DefaultMaxConcurrent and DefaultInboxSize by default.
+func WithConcurrency(limit, inbox int) ProductAgentOption {
+	return func(p *ProductAgentEntity) {
+		p.gate = newGate(limit, inbox)
+	}
 }
 
 // NewProductAgentEntity creates a new product agent entity
-func NewProductAgentEntity(agent *agent.Agent, bus messaging.MessageBus) *ProductAgentEntity {
+func NewProductAgentEntity(agent *agent.Agent, bus messaging.MessageBus, opts ...ProductAgentOption) *ProductAgentEntity {
 	now := time.Now()
-	return &ProductAgentEntity{
+	p := &ProductAgentEntity{
 		id:         uuid.New().String(),
 		name:       agent.Persona.Name,
 		status:     StatusActive,
@@ -36,7 +66,12 @@ func NewProductAgentEntity(agent *agent.Agent, bus messaging.MessageBus) *Produc
 		messageBus: bus,
 		roles:      map[Role]bool{RoleDeveloper: true},
 		metadata:   make(Metadata),
+		gate:       newGate(DefaultMaxConcurrent, DefaultInboxSize),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
 }
 
 // Start initializes the product agent and subscribes to messages
@@ -44,58 +79,165 @@ func (p *ProductAgentEntity) Start(ctx context.Context) error {
 	// Start the underlying agent
 	p.agent.Start(ctx)
 
-	// Subscribe to messages
+	// Subscribe to messages; the team may hand them to another agent
 	return p.messageBus.Subscribe(p.id, func(msg messaging.Message) error {
-		// Convert to agent message
-		agentMsg := agent.Message{
-			Id:            msg.ID,
-			Content:       string(msg.Content),
-			Created:       msg.Timestamp,
-			From:          msg.SenderID,
-			To:            []string{p.name},
-			Type:          "chat",
-			ResponseReady: make(chan agent.Message, 1),
+		if p.team != nil && p.team.route(p, msg) {
+			return nil
 		}
+		p.process(msg)
+		return nil
+	})
+}
+
+// process answers a message with the underlying agent, replying through the message bus.
+// A message that finds the agent saturated is answered that it is busy.
+func (p *ProductAgentEntity) process(msg messaging.Message) {
+	if !p.gate.admit() {
+		p.replyBusy(msg)
+		return
+	}
+
+	// Convert to agent message
+	agentMsg := agent.Message{
+		Id:            msg.ID,
+		Content:       messageText(msg),
+		Images:        messageImages(msg),
+		Created:       msg.Timestamp,
+		From:          msg.SenderID,
+		To:            []string{p.name},
+		Type:          "chat",
+		ResponseReady: make(chan agent.Message, 1),
+		Thread:        msg.Metadata[messaging.MetadataThreadID],
+		CorrelationID: msg.Correlation(),
+	}
+	if msg.Metadata[messaging.MetadataStream] == "true" {
+		agentMsg.OnToken = p.partialReplies(msg)
+	}
 
-		// Process the message using the underlying agent
-		go func() {
-			p.agent.HandleExternalMessage(agentMsg)
-
-			// Wait for response with a timeout
-			select {
-			case response := <-agentMsg.ResponseReady:
-				// Send response back through message bus
-				responseMsg := messaging.NewTextMessage(
-					p.id,
-					[]string{msg.SenderID},
-					response.Content,
-				)
-
-				// Set as reply to original message
-				responseMsg = responseMsg.WithReplyTo(msg.ID)
-
-				// Set the original_id metadata field if agent set OriginalId
-				if response.OriginalId != "" {
-					responseMsg.Metadata["original_id"] = response.OriginalId
-				}
-
-				// Send response
-				p.messageBus.Publish(responseMsg)
-
-			case <-time.After(30 * time.Second):
-				// If no response after timeout, send a fallback message
-				responseMsg := messaging.NewTextMessage(
-					p.id,
-					[]string{msg.SenderID},
-					"I seem to be having technical difficulties. Please try again later or contact Tom Reynolds.",
-				)
-				responseMsg = responseMsg.WithReplyTo(msg.ID)
-				p.messageBus.Publish(responseMsg)
+	// Process the message using the underlying agent once it is its turn
+	go func() {
+		p.gate.wait()
+		p.agent.HandleExternalMessage(agentMsg)
+
+		// Wait for response with a timeout
+		select {
+		case response := <-agentMsg.ResponseReady:
+			p.gate.release()
+
+			// Send response back through message bus, as reply to the original message
+			responseMsg := messaging.NewTextReplyMessage(p.id, msg, response.Content)
+
+			// Set the original_id metadata field if agent set OriginalId
+			if response.OriginalId != "" {
+				responseMsg.Metadata["original_id"] = response.OriginalId
 			}
-		}()
 
-		return nil
-	})
+			// Tell the sender which agent the conversation was handed off from
+			if from, ok := msg.Metadata[MetadataHandoffFrom]; ok {
+				responseMsg.Metadata[MetadataHandoffFrom] = from
+			}
+
+			// Send response
+			p.messageBus.Publish(responseMsg)
+
+		case <-time.After(30 * time.Second):
+			// If no response after timeout, send a fallback message; the agent stops
+			// answering so the message does not hold its turn any longer
+			p.agent.Cancel(msg.ID)
+			p.gate.release()
+			responseMsg := messaging.NewTextReplyMessage(
+				p.id,
+				msg,
+				"I seem to be having technical difficulties. Please try again later or contact Tom Reynolds.",
+			)
+			p.messageBus.Publish(responseMsg)
+		}
+	}()
+}
+
+// partialReplies returns the token handler sending the reply to a message as it is
+// written, in partial replies holding the text so far, at most one per partialInterval;
+// the reply itself carries what the last one missed
+func (p *ProductAgentEntity) partialReplies(msg messaging.Message) func(text string) {
+	var (
+		written strings.Builder
+		last    time.Time
+	)
+	return func(text string) {
+		written.WriteString(text)
+		if time.Since(last) < partialInterval {
+			return
+		}
+		last = time.Now()
+		partial := messaging.NewTextReplyMessage(p.id, msg, written.String())
+		partial.Metadata[messaging.MetadataPartial] = "true"
+		p.messageBus.Publish(partial)
+	}
+}
+
+// replyBusy tells the sender of a message that the agent has too many messages to answer
+// to take it
+func (p *ProductAgentEntity) replyBusy(msg messaging.Message) {
+	reply := messaging.NewTextReplyMessage(p.id, msg,
+		fmt.Sprintf("%s is busy with other messages, please try again shortly.", p.name))
+	reply.Metadata[MetadataBusy] = "true"
+	p.messageBus.Publish(reply)
+}
+
+// DraftMessage drafts a message on behalf of another entity using the underlying agent
+func (p *ProductAgentEntity) DraftMessage(ctx context.Context, senderName, recipientName, instruction string) (string, error) {
+	return p.agent.DraftMessage(ctx, senderName, recipientName, instruction)
+}
+
+// SelfCheck quizzes the underlying agent on its most important knowledge
+func (p *ProductAgentEntity) SelfCheck(ctx context.Context, count int) ([]agent.SelfCheckResult, error) {
+	return p.agent.SelfCheck(ctx, count)
+}
+
+// Language returns the language the underlying agent responds in
+func (p *ProductAgentEntity) Language() string {
+	return p.agent.Language()
+}
+
+// Languages returns the languages the underlying agent has system prompts for
+func (p *ProductAgentEntity) Languages() []string {
+	return p.agent.Languages()
+}
+
+// SetLanguage switches the system prompt of the underlying agent to the given language
+func (p *ProductAgentEntity) SetLanguage(language string) error {
+	return p.agent.SetLanguage(language)
+}
+
+// RestoreHistory puts the turns of an earlier session back into the chat history of the underlying agent
+func (p *ProductAgentEntity) RestoreHistory(messages []llm.Message) {
+	p.agent.RestoreHistory(messages)
+}
+
+// CancelMessage stops the underlying agent answering a message. A message handed off to
+// another agent of the team is cancelled there.
+func (p *ProductAgentEntity) CancelMessage(messageID string) bool {
+	if p.team != nil {
+		for _, member := range p.team.Agents() {
+			if member != p && member.agent.Cancel(messageID) {
+				return true
+			}
+		}
+	}
+	return p.agent.Cancel(messageID)
+}
+
+// CloseThread has the underlying agent, and the other agents of its team, forget the
+// history of a conversation thread
+func (p *ProductAgentEntity) CloseThread(threadID string) {
+	if p.team != nil {
+		for _, member := range p.team.Agents() {
+			if member != p {
+				member.agent.CloseThread(threadID)
+			}
+		}
+	}
+	p.agent.CloseThread(threadID)
 }
 
 // Shutdown stops the product agent
@@ -117,11 +259,21 @@ func (p *ProductAgentEntity) Type() EntityType {
 	return EntityTypeAgent
 }
 
+// Status returns the status of the entity; an active agent is busy while it answers
+// messages
 func (p *ProductAgentEntity) Status() EntityStatus {
-	return p.status
+	p.mutex.RLock()
+	status := p.status
+	p.mutex.RUnlock()
+	if status == StatusActive && p.agent.Busy() {
+		return StatusBusy
+	}
+	return status
 }
 
 func (p *ProductAgentEntity) SetStatus(status EntityStatus) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
 	p.status = status
 	p.updatedAt = time.Now()
 	return nil
@@ -191,3 +343,37 @@ func (p *ProductAgentEntity) SendMessage(recipients []string, contentType string
 	err := p.messageBus.Publish(msg)
 	return msg, err
 }
+
+// messageText returns the text of a message, the text part of a multipart message
+func messageText(msg messaging.Message) string {
+	if msg.ContentType == messaging.ContentTypeMultipart {
+		if text, err := msg.TextContent(); err == nil {
+			return text
+		}
+	}
+	return string(msg.Content)
+}
+
+// messageImages returns the image attachments of a multipart message for the agent's
+// language model; other attachments are not passed on
+func messageImages(msg messaging.Message) []llm.Image {
+	if msg.ContentType != messaging.ContentTypeMultipart {
+		return nil
+	}
+	content, err := msg.MultipartContent()
+	if err != nil {
+		return nil
+	}
+	var images []llm.Image
+	for _, attachment := range content.Attachments {
+		if !strings.HasPrefix(attachment.MediaType, "image/") {
+			continue
+		}
+		altText := attachment.AltText
+		if altText == "" {
+			altText = attachment.Name
+		}
+		images = append(images, llm.Image{MediaType: attachment.MediaType, Data: attachment.Data, AltText: altText})
+	}
+	return images
+}
@@ -0,0 +1,89 @@
+package entity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"goproduct/internal/agent"
+	"goproduct/internal/llm"
+	"goproduct/internal/messaging"
+)
+
+// heldLLM answers once released
+type heldLLM struct {
+	agent.MockLLM
+	release chan struct{}
+}
+
+func (m *heldLLM) GenerateChat(ctx context.Context, messages []llm.Message) (string, error) {
+	<-m.release
+	return m.MockLLM.GenerateChat(ctx, messages)
+}
+
+func TestProductAgentConcurrencyGate(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	model := &heldLLM{release: make(chan struct{})}
+	andy := NewProductAgentEntity(agent.NewAgent(agent.Persona{Name: "Andy", LanguageModels: agent.LanguageModels{Default: model}}),
+		bus, WithConcurrency(1, 1))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := andy.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	replies := make(chan messaging.Message, 10)
+	bus.Subscribe("user", func(msg messaging.Message) error { replies <- msg; return nil })
+
+	// One message is answered, one waits and the third is turned away
+	for _, text := range []string{"one", "two", "three"} {
+		bus.Publish(messaging.NewTextMessage("user", []string{andy.ID()}, text))
+	}
+	next := func() messaging.Message {
+		t.Helper()
+		select {
+		case reply := <-replies:
+			return reply
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for a reply")
+			return messaging.Message{}
+		}
+	}
+	if busy := next(); busy.Metadata[MetadataBusy] != "true" || string(busy.Content) != "Andy is busy with other messages, please try again shortly." {
+		t.Errorf("Expected a busy reply first, got %q %v", busy.Content, busy.Metadata)
+	}
+
+	close(model.release)
+	for i := 0; i < 2; i++ {
+		if reply := next(); reply.Metadata[MetadataBusy] != "" {
+			t.Errorf("Expected an answer, got %q", reply.Content)
+		}
+	}
+
+	// Once answered, messages are taken again
+	bus.Publish(messaging.NewTextMessage("user", []string{andy.ID()}, "four"))
+	if reply := next(); string(reply.Content) != "ECHO: ECHO: four" {
+		t.Errorf("Expected an answer after the gate emptied, got %q", reply.Content)
+	}
+}
+
+func TestProductAgentStreamsPartialReplies(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	bus.SetDeliveryMode(messaging.DeliveryOrdered)
+	andy := NewProductAgentEntity(agent.NewAgent(agent.Persona{Name: "Andy", LanguageModels: agent.LanguageModels{Default: &agent.MockLLM{}}}), bus)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := andy.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	var partials []string
+	reply, err := messaging.RequestStream(ctx, bus, messaging.NewTextMessage("user", []string{andy.ID()}, "hello"), func(partial messaging.Message) {
+		partials = append(partials, string(partial.Content))
+	})
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if len(partials) != 1 || partials[0] != string(reply.Content) {
+		t.Errorf("Expected the reply %q to be streamed first, got %q", reply.Content, partials)
+	}
+}
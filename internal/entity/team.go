@@ -0,0 +1,295 @@
+package entity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"goproduct/internal/agent"
+	"goproduct/internal/logging"
+	"goproduct/internal/messaging"
+)
+
+// MetadataHandoffFrom is the message metadata key naming the agent a conversation was
+// handed off from; replies of the agent that took over carry it too
+const MetadataHandoffFrom = "handoff_from"
+
+// metadataConversationID is the message metadata key of the conversation a message belongs to
+const metadataConversationID = "conversation_id"
+
+// AnyAgent matches every agent in the From of a route
+const AnyAgent = "*"
+
+// TeamConfig describes how the agents of a team are grouped and how conversations move
+// between them. Agents are referred to by entity ID or, case-insensitively, by name.
+type TeamConfig struct {
+	Groups []TeamGroup `json:"groups"`
+	Routes []TeamRoute `json:"routes"`
+}
+
+// TeamGroup is a bus group of agents, addressed by its ID
+type TeamGroup struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+// TeamRoute hands a conversation from one agent to another when a message matches
+type TeamRoute struct {
+	From  string `json:"from"`  // Agent holding the conversation, or "*" for any
+	Match string `json:"match"` // Regular expression the message text must match
+	To    string `json:"to"`    // Agent taking over the conversation
+}
+
+// LoadTeamConfig reads a team configuration from a JSON file. A missing file is an
+// empty configuration.
+func LoadTeamConfig(path string) (TeamConfig, error) {
+	var config TeamConfig
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to read team configuration: %w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse team configuration: %w", err)
+	}
+	return config, nil
+}
+
+// route is a compiled TeamRoute, resolved to entity IDs on Start
+type route struct {
+	TeamRoute
+	match    *regexp.Regexp
+	fromID   string // Empty for any agent
+	targetID string
+}
+
+// AgentTeam runs several agents, e.g. a product owner, an architect and QA, on one
+// message bus. Each agent is addressed by its entity ID or through the team's groups.
+// Routes hand a conversation over: when a direct message to the agent holding a
+// conversation matches one of its routes, the target agent answers it and holds the
+// conversation from then on, so later messages to the first agent reach the target too.
+// A conversation is a sender's messages with the same conversation_id metadata.
+type AgentTeam struct {
+	bus     messaging.MessageBus
+	config  TeamConfig
+	agents  map[string]*ProductAgentEntity // Agents by entity ID
+	routes  []route
+	holders map[string]string // Agent holding each handed off conversation
+	logger  *logging.Logger
+	mu      sync.Mutex // Protects agents and holders
+}
+
+// NewAgentTeam creates a team on the bus; agents are added with Register before Start
+func NewAgentTeam(bus messaging.MessageBus, config TeamConfig) (*AgentTeam, error) {
+	t := &AgentTeam{
+		bus:     bus,
+		config:  config,
+		agents:  make(map[string]*ProductAgentEntity),
+		holders: make(map[string]string),
+		logger:  logging.Get().Component("entity"),
+	}
+	for i, r := range config.Routes {
+		match, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("route %d has an invalid match: %w", i+1, err)
+		}
+		t.routes = append(t.routes, route{TeamRoute: r, match: match})
+	}
+	return t, nil
+}
+
+// Register adds an agent to the team
+func (t *AgentTeam) Register(member *ProductAgentEntity) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, exists := t.agents[member.ID()]; exists {
+		return fmt.Errorf("agent %s is already registered", member.ID())
+	}
+	member.team = t
+	t.agents[member.ID()] = member
+	return nil
+}
+
+// Agent returns the agent with the entity ID or name
+func (t *AgentTeam) Agent(ref string) (*ProductAgentEntity, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lookup(ref)
+}
+
+// Agents returns the agents of the team sorted by name
+func (t *AgentTeam) Agents() []*ProductAgentEntity {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	agents := make([]*ProductAgentEntity, 0, len(t.agents))
+	for _, member := range t.agents {
+		agents = append(agents, member)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Name() < agents[j].Name() })
+	return agents
+}
+
+// Start resolves the configuration against the registered agents, tells each agent who
+// its teammates are, creates the groups and starts the agents
+func (t *AgentTeam) Start(ctx context.Context) error {
+	t.mu.Lock()
+	for i := range t.routes {
+		r := &t.routes[i]
+		if r.From != AnyAgent {
+			from, ok := t.lookup(r.From)
+			if !ok {
+				t.mu.Unlock()
+				return fmt.Errorf("route %d is from unknown agent %q", i+1, r.From)
+			}
+			r.fromID = from.ID()
+		}
+		target, ok := t.lookup(r.To)
+		if !ok {
+			t.mu.Unlock()
+			return fmt.Errorf("route %d is to unknown agent %q", i+1, r.To)
+		}
+		r.targetID = target.ID()
+	}
+	groups := make(map[string][]string, len(t.config.Groups))
+	for _, group := range t.config.Groups {
+		for _, ref := range group.Members {
+			member, ok := t.lookup(ref)
+			if !ok {
+				t.mu.Unlock()
+				return fmt.Errorf("group %s has unknown member %q", group.ID, ref)
+			}
+			groups[group.ID] = append(groups[group.ID], member.ID())
+		}
+	}
+	agents := make([]*ProductAgentEntity, 0, len(t.agents))
+	for _, member := range t.agents {
+		agents = append(agents, member)
+	}
+	t.mu.Unlock()
+
+	for _, member := range agents {
+		var teammates []agent.Teammate
+		for _, other := range agents {
+			if other != member {
+				teammates = append(teammates, agent.Teammate{Name: other.Name(), Role: other.agent.Persona.Role})
+			}
+		}
+		member.agent.SetTeammates(teammates)
+	}
+	for _, group := range t.config.Groups {
+		if err := t.bus.CreateGroup(group.ID, group.Name, groups[group.ID]); err != nil {
+			return fmt.Errorf("failed to create group %s: %w", group.ID, err)
+		}
+	}
+	for _, member := range agents {
+		if err := member.Start(ctx); err != nil {
+			return fmt.Errorf("failed to start agent %s: %w", member.Name(), err)
+		}
+	}
+	t.logger.Info("Agent team started", "agents", len(agents), "groups", len(t.config.Groups), "routes", len(t.routes))
+	return nil
+}
+
+// Shutdown stops the agents and removes the groups
+func (t *AgentTeam) Shutdown() error {
+	var errs []error
+	for _, member := range t.Agents() {
+		if err := member.Shutdown(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, group := range t.config.Groups {
+		if err := t.bus.RemoveGroup(group.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Handoff hands a conversation of the sender to an agent, as a route would
+func (t *AgentTeam) Handoff(senderID, conversationID, to string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	target, ok := t.lookup(to)
+	if !ok {
+		return fmt.Errorf("unknown agent %q", to)
+	}
+	t.holders[conversationKey(senderID, conversationID)] = target.ID()
+	t.logger.Info("Conversation handed off", "sender", senderID, "conversation_id", conversationID, "to", target.ID())
+	return nil
+}
+
+// Holder returns the agent holding a conversation of the sender, if it was handed off
+func (t *AgentTeam) Holder(senderID, conversationID string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	holder, ok := t.holders[conversationKey(senderID, conversationID)]
+	return holder, ok
+}
+
+// route decides which agent answers a message delivered to the recipient. It reports
+// whether another agent took the message, in which case the recipient must not answer.
+// Messages that reached the recipient through a group or pattern are its own to answer.
+func (t *AgentTeam) route(recipient *ProductAgentEntity, msg messaging.Message) bool {
+	if !slices.Contains(msg.Recipients, recipient.ID()) {
+		return false
+	}
+	key := conversationKey(msg.SenderID, msg.Metadata[metadataConversationID])
+	text := messageText(msg)
+
+	t.mu.Lock()
+	holderID := recipient.ID()
+	if holder, ok := t.holders[key]; ok {
+		holderID = holder
+	}
+	for _, r := range t.routes {
+		if (r.fromID == "" || r.fromID == holderID) && r.targetID != holderID && r.match.MatchString(text) {
+			t.logger.Info("Conversation routed", "sender", msg.SenderID, "message_id", msg.ID, "from", holderID, "to", r.targetID, "match", r.Match)
+			holderID = r.targetID
+			t.holders[key] = holderID
+			break
+		}
+	}
+	holder := t.agents[holderID]
+	t.mu.Unlock()
+
+	if holder == nil || holder == recipient {
+		return false
+	}
+	handed := msg
+	handed.Metadata = make(map[string]string, len(msg.Metadata)+1)
+	for k, v := range msg.Metadata {
+		handed.Metadata[k] = v
+	}
+	handed.Metadata[MetadataHandoffFrom] = recipient.ID()
+	holder.process(handed)
+	return true
+}
+
+// lookup finds an agent by entity ID, then by name; the caller holds the lock
+func (t *AgentTeam) lookup(ref string) (*ProductAgentEntity, bool) {
+	if member, ok := t.agents[ref]; ok {
+		return member, true
+	}
+	for _, member := range t.agents {
+		if strings.EqualFold(member.Name(), ref) {
+			return member, true
+		}
+	}
+	return nil, false
+}
+
+// conversationKey identifies a conversation of a sender
+func conversationKey(senderID, conversationID string) string {
+	return senderID + "\x00" + conversationID
+}
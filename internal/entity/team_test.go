@@ -0,0 +1,168 @@
+package entity
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goproduct/internal/agent"
+	"goproduct/internal/messaging"
+)
+
+// newTestTeam starts a product owner, an architect and QA on a memory bus
+func newTestTeam(t *testing.T, config TeamConfig) (*messaging.MemoryMessageBus, *AgentTeam) {
+	t.Helper()
+	bus := messaging.NewMemoryMessageBus()
+	team, err := NewAgentTeam(bus, config)
+	if err != nil {
+		t.Fatalf("NewAgentTeam failed: %v", err)
+	}
+	for _, persona := range []agent.Persona{
+		{Name: "Andy", Role: "Product Owner"},
+		{Name: "Ada", Role: "Architect"},
+		{Name: "Quinn", Role: "QA"},
+	} {
+		persona.LanguageModels.Default = &agent.MockLLM{}
+		member := NewProductAgentEntity(agent.NewAgent(persona), bus, WithAgentEntityID(persona.Role))
+		if err := team.Register(member); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := team.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	return bus, team
+}
+
+// ask sends a message from the user and waits for the reply
+func ask(t *testing.T, bus *messaging.MemoryMessageBus, replies chan messaging.Message, to, text string) messaging.Message {
+	t.Helper()
+	msg := messaging.NewTextMessage("user", []string{to}, text)
+	msg.Metadata[metadataConversationID] = "c1"
+	if err := bus.Publish(msg); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	select {
+	case reply := <-replies:
+		return reply
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timeout waiting for the reply to %q", text)
+		return messaging.Message{}
+	}
+}
+
+func TestAgentTeamRoutesConversations(t *testing.T) {
+	bus, team := newTestTeam(t, TeamConfig{
+		Routes: []TeamRoute{
+			{From: "andy", Match: `(?i)architecture`, To: "Ada"},
+			{From: AnyAgent, Match: `(?i)\btest`, To: "QA"},
+			{From: "Architect", Match: `(?i)backlog`, To: "Product Owner"},
+		},
+	})
+	replies := make(chan messaging.Message, 10)
+	if err := bus.Subscribe("user", func(msg messaging.Message) error { replies <- msg; return nil }); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if reply := ask(t, bus, replies, "Product Owner", "hello"); reply.SenderID != "Product Owner" {
+		t.Errorf("Expected the product owner to answer, got %s", reply.SenderID)
+	}
+
+	// A matching route hands the conversation to the architect, who keeps it
+	reply := ask(t, bus, replies, "Product Owner", "what about the architecture?")
+	if reply.SenderID != "Architect" || reply.Metadata[MetadataHandoffFrom] != "Product Owner" {
+		t.Errorf("Expected the architect to answer a handoff, got %s from %q", reply.SenderID, reply.Metadata[MetadataHandoffFrom])
+	}
+	if reply.Metadata[metadataConversationID] != "c1" {
+		t.Errorf("Expected the reply in the conversation, got %v", reply.Metadata)
+	}
+	if reply := ask(t, bus, replies, "Product Owner", "and the database?"); reply.SenderID != "Architect" {
+		t.Errorf("Expected the architect to keep the conversation, got %s", reply.SenderID)
+	}
+	if holder, _ := team.Holder("user", "c1"); holder != "Architect" {
+		t.Errorf("Expected the architect to hold the conversation, got %q", holder)
+	}
+
+	// Routes of the holder apply, and routes from any agent apply to all
+	if reply := ask(t, bus, replies, "Product Owner", "add it to the backlog"); reply.SenderID != "Product Owner" {
+		t.Errorf("Expected the conversation back with the product owner, got %s", reply.SenderID)
+	}
+	if reply := ask(t, bus, replies, "Product Owner", "how do we test this?"); reply.SenderID != "QA" {
+		t.Errorf("Expected QA to take the conversation, got %s", reply.SenderID)
+	}
+
+	if err := team.Handoff("user", "c1", "andy"); err != nil {
+		t.Fatalf("Handoff failed: %v", err)
+	}
+	if reply := ask(t, bus, replies, "QA", "thanks"); reply.SenderID != "Product Owner" {
+		t.Errorf("Expected the product owner after a manual handoff, got %s", reply.SenderID)
+	}
+	if err := team.Handoff("user", "c1", "nobody"); err == nil {
+		t.Error("Expected an error handing off to an unknown agent")
+	}
+}
+
+func TestAgentTeamGroupsAndTeammates(t *testing.T) {
+	bus, team := newTestTeam(t, TeamConfig{
+		Groups: []TeamGroup{{ID: "leads", Name: "Leads", Members: []string{"Andy", "Architect"}}},
+	})
+	replies := make(chan messaging.Message, 10)
+	if err := bus.Subscribe("user", func(msg messaging.Message) error { replies <- msg; return nil }); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if err := bus.Publish(messaging.NewTextMessage("user", []string{"leads"}, "status?")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	senders := map[string]bool{}
+	for range 2 {
+		select {
+		case reply := <-replies:
+			senders[reply.SenderID] = true
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for group replies")
+		}
+	}
+	if !senders["Product Owner"] || !senders["Architect"] {
+		t.Errorf("Expected both leads to answer, got %v", senders)
+	}
+
+	ada, ok := team.Agent("ada")
+	if !ok {
+		t.Fatal("Expected to find the architect by name")
+	}
+	if teammates := ada.agent.Teammates(); len(teammates) != 2 {
+		t.Errorf("Expected the architect to know 2 teammates, got %v", teammates)
+	}
+	if err := team.Shutdown(); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+	if _, err := bus.GetGroup("leads"); err == nil {
+		t.Error("Expected the group to be removed on shutdown")
+	}
+}
+
+func TestAgentTeamConfigErrors(t *testing.T) {
+	if _, err := NewAgentTeam(messaging.NewMemoryMessageBus(), TeamConfig{Routes: []TeamRoute{{From: AnyAgent, Match: "(", To: "qa"}}}); err == nil {
+		t.Error("Expected an error for an invalid match")
+	}
+
+	team, _ := NewAgentTeam(messaging.NewMemoryMessageBus(), TeamConfig{Routes: []TeamRoute{{From: AnyAgent, Match: "x", To: "nobody"}}})
+	if err := team.Start(context.Background()); err == nil {
+		t.Error("Expected an error for a route to an unknown agent")
+	}
+
+	path := filepath.Join(t.TempDir(), "team.json")
+	if config, err := LoadTeamConfig(path); err != nil || len(config.Routes) != 0 {
+		t.Errorf("Expected an empty configuration for a missing file, got %v, %v", config, err)
+	}
+	os.WriteFile(path, []byte(`{"routes":[{"from":"*","match":"test","to":"qa"}]}`), 0600)
+	config, err := LoadTeamConfig(path)
+	if err != nil || len(config.Routes) != 1 || config.Routes[0].To != "qa" {
+		t.Errorf("Expected the route from the file, got %v, %v", config, err)
+	}
+}
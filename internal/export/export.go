@@ -0,0 +1,80 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"goproduct/internal/knowledge"
+)
+
+// Document is the file written by an export
+type Document struct {
+	Profile    string    `json:"profile"`
+	ExportedAt time.Time `json:"exported_at"`
+	Entries    []Item    `json:"entries"`
+}
+
+// Item is one exported entry. Text content is written as a string so the export is
+// readable without tooling.
+type Item struct {
+	ID         string                     `json:"id"`
+	Category   string                     `json:"category"`
+	CreatedAt  time.Time                  `json:"created_at"`
+	UpdatedAt  time.Time                  `json:"updated_at"`
+	OwnerID    string                     `json:"owner_id,omitempty"`
+	SubjectIDs []string                   `json:"subject_ids,omitempty"`
+	Tags       []string                   `json:"tags,omitempty"`
+	Content    string                     `json:"content"`
+	Metadata   map[string]string          `json:"metadata,omitempty"`
+	Provenance []knowledge.ProvenanceStep `json:"provenance,omitempty"`
+}
+
+// Export writes the entries matching the filter to w as a JSON document, redacted by
+// the profile. Conversations are exported by filtering on knowledge.CategoryMessage.
+// The report lists what was redacted; it is for the exporter and not part of the
+// document.
+func Export(w io.Writer, store knowledge.Store, filter knowledge.Filter, profile Profile) (Report, error) {
+	redactor, err := NewRedactor(profile)
+	if err != nil {
+		return Report{}, err
+	}
+
+	records, err := store.SearchRecords(filter)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to load entries to export: %w", err)
+	}
+	entries, report := redactor.Redact(records)
+
+	document := Document{
+		Profile:    profile.Name,
+		ExportedAt: time.Now(),
+		Entries:    make([]Item, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		item := Item{
+			ID:         entry.ID,
+			Category:   entry.Category,
+			CreatedAt:  entry.CreatedAt,
+			UpdatedAt:  entry.UpdatedAt,
+			OwnerID:    entry.OwnerID,
+			SubjectIDs: entry.SubjectIDs,
+			Tags:       entry.Tags,
+			Content:    string(entry.Content),
+			Metadata:   entry.Metadata,
+			Provenance: entry.Provenance,
+		}
+		if entry.ContentType == knowledge.ContentTypeBinary {
+			item.Content = "(binary content)"
+		}
+		document.Entries = append(document.Entries, item)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return report, fmt.Errorf("failed to write export: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,168 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goproduct/internal/knowledge"
+)
+
+func TestSensitivity(t *testing.T) {
+	tests := []struct {
+		tags     []string
+		expected string
+	}{
+		{nil, SensitivityPublic},
+		{[]string{"roadmap"}, SensitivityPublic},
+		{[]string{"sensitivity:internal"}, SensitivityInternal},
+		{[]string{"sensitivity:internal", "sensitivity:confidential"}, SensitivityConfidential},
+		{[]string{"sensitivity:secret"}, SensitivityRestricted},
+	}
+	for _, test := range tests {
+		if level := Sensitivity(test.tags); level != test.expected {
+			t.Errorf("Sensitivity(%v) = %s, expected %s", test.tags, level, test.expected)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	entries := []knowledge.Entry{
+		{
+			ID:          "a",
+			ContentType: knowledge.ContentTypeText,
+			Content:     []byte("Maria Lopez approved a budget of $1.2 million; maria wants EUR 300k more."),
+			OwnerID:     "Maria Lopez",
+			Metadata:    map[string]string{"speaker": "Tom"},
+			Provenance:  []knowledge.ProvenanceStep{{ActorID: "maria"}},
+		},
+		{ID: "b", ContentType: knowledge.ContentTypeText, Content: []byte("Launch in May"), Tags: []string{"sensitivity:confidential"}},
+		{ID: "c", ContentType: knowledge.ContentTypeBinary, Content: []byte{0x01}},
+		{ID: "d", ContentType: knowledge.ContentTypeText, Content: []byte("Revenue grew 20 million dollars"), Tags: []string{"sensitivity:internal"}},
+	}
+
+	redactor, err := NewRedactor(Profile{
+		Name:            "test",
+		Names:           []string{"Maria", "Maria Lopez", "Tom"},
+		RedactFinancial: true,
+		MaxSensitivity:  SensitivityInternal,
+	})
+	if err != nil {
+		t.Fatalf("NewRedactor failed: %v", err)
+	}
+	result, report := redactor.Redact(entries)
+
+	if len(result) != 2 || result[0].ID != "a" || result[1].ID != "d" {
+		t.Fatalf("Expected entries a and d, got %v", result)
+	}
+	expected := "[REDACTED NAME] approved a budget of [REDACTED AMOUNT]; [REDACTED NAME] wants [REDACTED AMOUNT] more."
+	if string(result[0].Content) != expected {
+		t.Errorf("Unexpected content: %q", result[0].Content)
+	}
+	if result[0].OwnerID != RedactedName || result[0].Metadata["speaker"] != RedactedName {
+		t.Errorf("Expected owner and metadata to be redacted, got %q and %v", result[0].OwnerID, result[0].Metadata)
+	}
+	if result[0].Provenance != nil {
+		t.Error("Expected provenance to be removed")
+	}
+	if string(result[1].Content) != "Revenue grew [REDACTED AMOUNT]" {
+		t.Errorf("Unexpected content: %q", result[1].Content)
+	}
+	if string(entries[0].Content) == expected || entries[0].Metadata["speaker"] != "Tom" {
+		t.Error("Expected the input entries to be unchanged")
+	}
+
+	if report.Names["Maria Lopez"] != 2 || report.Names["Maria"] != 1 || report.Names["Tom"] != 1 {
+		t.Errorf("Unexpected name counts: %v", report.Names)
+	}
+	if report.Amounts != 3 {
+		t.Errorf("Expected 3 amounts, got %d", report.Amounts)
+	}
+	if len(report.Withheld) != 2 || report.Withheld[0].ID != "b" || report.Withheld[1].ID != "c" {
+		t.Errorf("Unexpected withheld entries: %v", report.Withheld)
+	}
+	if report.Exported != 2 || report.ProvenanceRemoved != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if summary := report.String(); !strings.Contains(summary, "Withheld b: sensitivity confidential") {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+}
+
+func TestRedactInternalProfileKeepsText(t *testing.T) {
+	entries := []knowledge.Entry{
+		{ID: "a", ContentType: knowledge.ContentTypeText, Content: []byte("Budget is $500"), Tags: []string{"sensitivity:confidential"}},
+		{ID: "b", ContentType: knowledge.ContentTypeText, Content: []byte("Secret"), Tags: []string{"sensitivity:restricted"}},
+	}
+	redactor, err := NewRedactor(Profiles()[ProfileInternal])
+	if err != nil {
+		t.Fatalf("NewRedactor failed: %v", err)
+	}
+	result, report := redactor.Redact(entries)
+	if len(result) != 1 || string(result[0].Content) != "Budget is $500" {
+		t.Errorf("Expected entry a unchanged, got %v", result)
+	}
+	if len(report.Withheld) != 1 || report.Names == nil || report.Amounts != 0 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestExport(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	store.AddRecord(knowledge.Entry{ID: "m1", Category: knowledge.CategoryMessage, ContentType: knowledge.ContentTypeText, Content: []byte("Ask Dana about the $40k invoice")})
+	store.AddRecord(knowledge.Entry{ID: "m2", Category: knowledge.CategoryMessage, ContentType: knowledge.ContentTypeText, Content: []byte("Margins"), Tags: []string{"sensitivity:internal"}})
+
+	profile := Profiles()[ProfilePartner]
+	profile.Names = []string{"Dana"}
+
+	var buf bytes.Buffer
+	report, err := Export(&buf, store, knowledge.Filter{}, profile)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	var document Document
+	if err := json.Unmarshal(buf.Bytes(), &document); err != nil {
+		t.Fatalf("Failed to parse export: %v", err)
+	}
+	if document.Profile != ProfilePartner || len(document.Entries) != 1 {
+		t.Fatalf("Unexpected document: %+v", document)
+	}
+	if document.Entries[0].Content != "Ask [REDACTED NAME] about the [REDACTED AMOUNT] invoice" {
+		t.Errorf("Unexpected content: %q", document.Entries[0].Content)
+	}
+	if report.Exported != 1 || len(report.Withheld) != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.json")
+
+	profiles, err := LoadProfiles(path)
+	if err != nil || len(profiles) != 2 {
+		t.Fatalf("Expected built-in profiles for a missing file, got %v, %v", profiles, err)
+	}
+
+	content := `[{"name": "partner", "names": ["Dana"], "redact_financial": true}, {"name": "board", "max_sensitivity": "restricted"}]`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write profiles: %v", err)
+	}
+	profiles, err = LoadProfiles(path)
+	if err != nil {
+		t.Fatalf("LoadProfiles failed: %v", err)
+	}
+	if len(profiles) != 3 || len(profiles[ProfilePartner].Names) != 1 || profiles["board"].MaxSensitivity != SensitivityRestricted {
+		t.Errorf("Unexpected profiles: %v", profiles)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"name": "bad", "max_sensitivity": "secret"}]`), 0644); err != nil {
+		t.Fatalf("Failed to write profiles: %v", err)
+	}
+	if _, err := LoadProfiles(path); err == nil {
+		t.Error("Expected error for an unknown sensitivity level")
+	}
+}
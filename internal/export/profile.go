@@ -0,0 +1,117 @@
+// Package export writes knowledge entries and conversations out for sharing. Exports
+// pass through a redaction profile that strips what must not leave the organization
+// and reports what was removed.
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Sensitivity levels, from least to most sensitive. Entries carry their level as a
+// "sensitivity:<level>" tag; entries without one are treated as public.
+const (
+	SensitivityPublic       = "public"
+	SensitivityInternal     = "internal"
+	SensitivityConfidential = "confidential"
+	SensitivityRestricted   = "restricted"
+)
+
+// SensitivityTagPrefix prefixes the sensitivity level in entry tags
+const SensitivityTagPrefix = "sensitivity:"
+
+// sensitivityLevels orders the sensitivity levels
+var sensitivityLevels = []string{SensitivityPublic, SensitivityInternal, SensitivityConfidential, SensitivityRestricted}
+
+// Built-in profile names
+const (
+	ProfileInternal = "internal" // Everything up to confidential, nothing redacted
+	ProfilePartner  = "partner"  // Public entries only, names and financial figures redacted
+)
+
+// Profile describes what an export must not contain
+type Profile struct {
+	Name            string   `json:"name"`
+	Names           []string `json:"names"`            // Stakeholder names replaced in all text
+	RedactFinancial bool     `json:"redact_financial"` // Replace currency amounts in all text
+	MaxSensitivity  string   `json:"max_sensitivity"`  // Entries tagged above this level are withheld, SensitivityPublic if empty
+	KeepProvenance  bool     `json:"keep_provenance"`  // Keep provenance history; it may hold earlier, unredacted content
+}
+
+// Profiles returns the built-in redaction profiles
+func Profiles() map[string]Profile {
+	return map[string]Profile{
+		ProfileInternal: {Name: ProfileInternal, MaxSensitivity: SensitivityConfidential, KeepProvenance: true},
+		ProfilePartner:  {Name: ProfilePartner, RedactFinancial: true, MaxSensitivity: SensitivityPublic},
+	}
+}
+
+// LoadProfiles returns the built-in profiles merged with the profiles in the JSON file
+// at path, a list of Profile objects. Profiles in the file replace built-in profiles of
+// the same name. A missing file yields just the built-in profiles.
+func LoadProfiles(path string) (map[string]Profile, error) {
+	profiles := Profiles()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export profiles: %w", err)
+	}
+
+	var loaded []Profile
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("failed to parse export profiles: %w", err)
+	}
+	for _, profile := range loaded {
+		if profile.Name == "" {
+			return nil, errors.New("export profile without a name")
+		}
+		if err := profile.Validate(); err != nil {
+			return nil, err
+		}
+		profiles[profile.Name] = profile
+	}
+	return profiles, nil
+}
+
+// Validate checks that the profile's sensitivity level is known
+func (p Profile) Validate() error {
+	if p.MaxSensitivity != "" && sensitivityRank(p.MaxSensitivity) < 0 {
+		return fmt.Errorf("export profile %q has unknown sensitivity level %q", p.Name, p.MaxSensitivity)
+	}
+	return nil
+}
+
+// sensitivityRank returns the position of a level in sensitivityLevels, -1 if unknown
+func sensitivityRank(level string) int {
+	for i, known := range sensitivityLevels {
+		if strings.EqualFold(level, known) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Sensitivity returns the highest sensitivity level among the tags, SensitivityPublic if
+// there is none. Unknown levels count as SensitivityRestricted so a typo never leaks.
+func Sensitivity(tags []string) string {
+	rank := 0
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, SensitivityTagPrefix) {
+			continue
+		}
+		level := sensitivityRank(strings.TrimPrefix(tag, SensitivityTagPrefix))
+		if level < 0 {
+			level = len(sensitivityLevels) - 1
+		}
+		if level > rank {
+			rank = level
+		}
+	}
+	return sensitivityLevels[rank]
+}
@@ -0,0 +1,185 @@
+package export
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"goproduct/internal/knowledge"
+)
+
+// Replacement text for redacted content
+const (
+	RedactedName   = "[REDACTED NAME]"
+	RedactedAmount = "[REDACTED AMOUNT]"
+)
+
+// financialPattern matches currency amounts such as "$1,200", "€3.5M", "USD 500k" or
+// "2 million dollars"
+var financialPattern = func() *regexp.Regexp {
+	amount := `\d[\d,]*(?:\.\d+)?(?:\s?(?:k|m|mm|bn|thousand|million|billion)\b)?`
+	return regexp.MustCompile(`(?i)[$€£¥]\s?` + amount +
+		`|\b(?:usd|eur|gbp|chf|jpy)\s?` + amount +
+		`|\b` + amount + `\s?(?:usd|eur|gbp|chf|jpy|dollars?|euros?|pounds?)\b`)
+}()
+
+// Withheld is an entry left out of an export
+type Withheld struct {
+	ID     string // Entry ID
+	Reason string // Why the entry was left out
+}
+
+// Report records what a redaction profile removed from an export
+type Report struct {
+	Profile           string         // Name of the profile applied
+	Exported          int            // Entries included in the export
+	Names             map[string]int // Replacements per stakeholder name
+	Amounts           int            // Financial figures replaced
+	Withheld          []Withheld     // Entries left out
+	ProvenanceRemoved int            // Entries whose provenance history was stripped
+}
+
+// Redacted reports whether anything was removed from the export
+func (r Report) Redacted() bool {
+	return len(r.Names) > 0 || r.Amounts > 0 || len(r.Withheld) > 0 || r.ProvenanceRemoved > 0
+}
+
+// String returns a human-readable summary of the report
+func (r Report) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Export profile %q: %d entries exported\n", r.Profile, r.Exported))
+	if !r.Redacted() {
+		sb.WriteString("Nothing was redacted\n")
+		return sb.String()
+	}
+
+	names := make([]string, 0, len(r.Names))
+	for name := range r.Names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("Name %q replaced %d times\n", name, r.Names[name]))
+	}
+	if r.Amounts > 0 {
+		sb.WriteString(fmt.Sprintf("Financial figures replaced: %d\n", r.Amounts))
+	}
+	if r.ProvenanceRemoved > 0 {
+		sb.WriteString(fmt.Sprintf("Provenance history removed from %d entries\n", r.ProvenanceRemoved))
+	}
+	for _, withheld := range r.Withheld {
+		sb.WriteString(fmt.Sprintf("Withheld %s: %s\n", withheld.ID, withheld.Reason))
+	}
+	return sb.String()
+}
+
+// Redactor applies a profile to entries
+type Redactor struct {
+	profile Profile
+	names   *regexp.Regexp // nil if the profile has no names
+}
+
+// NewRedactor compiles the profile into a redactor
+func NewRedactor(profile Profile) (*Redactor, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	r := &Redactor{profile: profile}
+
+	names := make([]string, 0, len(profile.Names))
+	for _, name := range profile.Names {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, regexp.QuoteMeta(name))
+		}
+	}
+	if len(names) > 0 {
+		// Longest first so "Ann Lee" wins over "Ann"
+		sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+		r.names = regexp.MustCompile(`(?i)\b(?:` + strings.Join(names, "|") + `)\b`)
+	}
+	return r, nil
+}
+
+// Redact returns the entries that may be exported under the profile, with their text
+// redacted, and a report of what was removed. The given entries are not modified.
+func (r *Redactor) Redact(entries []knowledge.Entry) ([]knowledge.Entry, Report) {
+	report := Report{Profile: r.profile.Name, Names: make(map[string]int)}
+	maxRank := sensitivityRank(r.profile.MaxSensitivity)
+	if maxRank < 0 {
+		maxRank = 0
+	}
+	rewritesText := r.names != nil || r.profile.RedactFinancial
+
+	result := make([]knowledge.Entry, 0, len(entries))
+	for _, entry := range entries {
+		if level := Sensitivity(entry.Tags); sensitivityRank(level) > maxRank {
+			report.Withheld = append(report.Withheld, Withheld{ID: entry.ID, Reason: "sensitivity " + level})
+			continue
+		}
+		if rewritesText && entry.ContentType == knowledge.ContentTypeBinary {
+			report.Withheld = append(report.Withheld, Withheld{ID: entry.ID, Reason: "binary content cannot be redacted"})
+			continue
+		}
+
+		redacted := entry
+		redacted.Content = []byte(r.text(string(entry.Content), &report))
+		redacted.OwnerID = r.text(entry.OwnerID, &report)
+		redacted.SubjectIDs = r.texts(entry.SubjectIDs, &report)
+		redacted.Tags = r.texts(entry.Tags, &report)
+		if entry.Metadata != nil {
+			redacted.Metadata = make(map[string]string, len(entry.Metadata))
+			for key, value := range entry.Metadata {
+				redacted.Metadata[key] = r.text(value, &report)
+			}
+		}
+		// Embeddings are derived from the unredacted content
+		redacted.Embedding = nil
+		if !r.profile.KeepProvenance && len(entry.Provenance) > 0 {
+			redacted.Provenance = nil
+			report.ProvenanceRemoved++
+		}
+		result = append(result, redacted)
+	}
+	report.Exported = len(result)
+	return result, report
+}
+
+// text redacts one string, counting the replacements in the report
+func (r *Redactor) text(s string, report *Report) string {
+	if r.names != nil {
+		s = r.names.ReplaceAllStringFunc(s, func(match string) string {
+			report.Names[r.canonicalName(match)]++
+			return RedactedName
+		})
+	}
+	if r.profile.RedactFinancial {
+		s = financialPattern.ReplaceAllStringFunc(s, func(string) string {
+			report.Amounts++
+			return RedactedAmount
+		})
+	}
+	return s
+}
+
+// texts redacts a list of strings
+func (r *Redactor) texts(values []string, report *Report) []string {
+	if values == nil {
+		return nil
+	}
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = r.text(value, report)
+	}
+	return result
+}
+
+// canonicalName returns the profile's spelling of a matched name
+func (r *Redactor) canonicalName(match string) string {
+	for _, name := range r.profile.Names {
+		if strings.EqualFold(strings.TrimSpace(name), match) {
+			return strings.TrimSpace(name)
+		}
+	}
+	return match
+}
@@ -0,0 +1,94 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Denylist objects to text matching any of its regular expressions
+type Denylist struct {
+	name     string
+	patterns []*regexp.Regexp
+}
+
+// NewDenylist creates a denylist of regular expressions in Go syntax; prefix a pattern
+// with (?i) to ignore case. The name identifies it in decisions, "denylist" if empty.
+func NewDenylist(name string, patterns ...string) (*Denylist, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("denylist needs at least one pattern")
+	}
+	if name == "" {
+		name = "denylist"
+	}
+	d := &Denylist{name: name}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid denylist pattern %q: %w", pattern, err)
+		}
+		d.patterns = append(d.patterns, re)
+	}
+	return d, nil
+}
+
+// Name returns the name of the denylist
+func (d *Denylist) Name() string {
+	return d.name
+}
+
+// Check returns a violation for each pattern the text matches, spanning its matches
+func (d *Denylist) Check(ctx context.Context, text string) ([]Violation, error) {
+	var violations []Violation
+	for _, re := range d.patterns {
+		matches := re.FindAllStringIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		violation := Violation{Reason: fmt.Sprintf("matches denied pattern %s", re)}
+		for _, match := range matches {
+			violation.Spans = append(violation.Spans, Span{Start: match[0], End: match[1]})
+		}
+		violations = append(violations, violation)
+	}
+	return violations, nil
+}
+
+// MaxLength objects to text longer than a number of characters; redacting it replaces
+// the excess
+type MaxLength struct {
+	limit int
+}
+
+// NewMaxLength creates a check limiting texts to the number of characters
+func NewMaxLength(limit int) *MaxLength {
+	return &MaxLength{limit: limit}
+}
+
+// Name returns "max-length"
+func (m *MaxLength) Name() string {
+	return "max-length"
+}
+
+// Check returns a violation spanning the characters past the limit
+func (m *MaxLength) Check(ctx context.Context, text string) ([]Violation, error) {
+	length := utf8.RuneCountInString(text)
+	if length <= m.limit {
+		return nil, nil
+	}
+
+	// Find the byte offset of the first character past the limit
+	offset, count := 0, 0
+	for offset = range text {
+		if count == m.limit {
+			break
+		}
+		count++
+	}
+	return []Violation{{
+		Reason: fmt.Sprintf("%d characters, more than the limit of %d", length, m.limit),
+		Spans:  []Span{{Start: offset, End: len(text)}},
+	}}, nil
+}
@@ -0,0 +1,324 @@
+// Package guardrails checks the messages users send to agents and the replies agents
+// send back. A Pipeline runs checks, such as regex denylists, length limits and
+// moderation by a language model, and acts on what they find: it blocks the message,
+// redacts the offending text or only warns. Every decision is traced.
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"goproduct/internal/logging"
+	"goproduct/internal/tracing"
+)
+
+// Direction tells whether a message goes to an agent or comes from one
+type Direction string
+
+const (
+	// Inbound identifies messages sent to an agent
+	Inbound Direction = "inbound"
+	// Outbound identifies replies sent by an agent
+	Outbound Direction = "outbound"
+)
+
+// Action is what a pipeline does with a message a check objects to
+type Action string
+
+const (
+	// ActionBlock stops the message
+	ActionBlock Action = "block"
+	// ActionRedact replaces the offending text and lets the message through
+	ActionRedact Action = "redact"
+	// ActionWarn lets the message through unchanged, only tracing the finding
+	ActionWarn Action = "warn"
+)
+
+// ParseAction returns the action with the given name, ignoring case
+func ParseAction(name string) (Action, error) {
+	switch action := Action(strings.ToLower(name)); action {
+	case ActionBlock, ActionRedact, ActionWarn:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown guardrail action %q (use block, redact or warn)", name)
+	}
+}
+
+// DefaultRedaction replaces redacted text
+const DefaultRedaction = "[redacted]"
+
+// Span is the byte range [Start, End) of a text
+type Span struct {
+	Start int
+	End   int
+}
+
+// Violation is something a check objects to in a text
+type Violation struct {
+	Reason string // Why the text violates the check, e.g. "matches denied pattern"
+	Spans  []Span // Offending parts of the text; nil for all of it
+}
+
+// Check inspects texts for one kind of problem
+type Check interface {
+	// Name identifies the check in decisions and traces, e.g. "denylist"
+	Name() string
+	// Check returns what it objects to in the text, nothing if the text passes
+	Check(ctx context.Context, text string) ([]Violation, error)
+}
+
+// Decision records the action taken on a violation
+type Decision struct {
+	Check     string
+	Action    Action
+	Direction Direction
+	Reason    string
+}
+
+// Result is the outcome of inspecting a message
+type Result struct {
+	Text      string     // The message, redacted where checks asked for it
+	Blocked   bool       // A check blocked the message
+	Decisions []Decision // The actions taken, in order; empty if every check passed
+}
+
+// Reason returns the reason the message was blocked, "" if it was not
+func (r Result) Reason() string {
+	for _, decision := range r.Decisions {
+		if decision.Action == ActionBlock {
+			return decision.Reason
+		}
+	}
+	return ""
+}
+
+// rule runs a check on the messages in some directions
+type rule struct {
+	check      Check
+	action     Action
+	directions []Direction // Empty for both directions
+}
+
+// applies reports whether the rule checks messages in the direction
+func (r rule) applies(direction Direction) bool {
+	if len(r.directions) == 0 {
+		return true
+	}
+	for _, d := range r.directions {
+		if d == direction {
+			return true
+		}
+	}
+	return false
+}
+
+// Pipeline runs checks on messages in the order they were added; a blocking check
+// ends the inspection, and later checks see the text redacted by earlier ones. A nil
+// pipeline lets every message through. It is safe for concurrent use once created.
+type Pipeline struct {
+	rules      []rule
+	redaction  string
+	failClosed bool // Block messages when a check fails instead of skipping the check
+	tracer     tracing.Tracer
+	logger     *logging.Logger
+}
+
+// Option is a function that configures a Pipeline
+type Option func(*Pipeline)
+
+// NewPipeline creates a pipeline; without rules it lets every message through
+func NewPipeline(options ...Option) *Pipeline {
+	p := &Pipeline{
+		redaction: DefaultRedaction,
+		tracer:    tracing.NewNoopTracer(),
+		logger:    logging.Get().Component("guardrails"),
+	}
+
+	// Apply options
+	for _, option := range options {
+		option(p)
+	}
+
+	return p
+}
+
+// WithRule runs the check on messages in the directions, both if none are given, and
+// takes the action on what it finds
+func WithRule(check Check, action Action, directions ...Direction) Option {
+	return func(p *Pipeline) {
+		p.rules = append(p.rules, rule{check: check, action: action, directions: directions})
+	}
+}
+
+// WithRedaction sets the text that replaces redacted text, DefaultRedaction by default
+func WithRedaction(replacement string) Option {
+	return func(p *Pipeline) {
+		p.redaction = replacement
+	}
+}
+
+// WithFailClosed blocks messages a check fails on, e.g. when the moderation model is
+// down, instead of skipping the check
+func WithFailClosed(failClosed bool) Option {
+	return func(p *Pipeline) {
+		p.failClosed = failClosed
+	}
+}
+
+// WithTracer sets the tracer receiving a trace event for every decision
+func WithTracer(tracer tracing.Tracer) Option {
+	return func(p *Pipeline) {
+		p.tracer = tracer
+	}
+}
+
+// Len returns the number of checks in the pipeline
+func (p *Pipeline) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.rules)
+}
+
+// Inspect runs the checks for the direction on a message from the sender, e.g. a user
+// ID for inbound messages or an agent name for outbound ones
+func (p *Pipeline) Inspect(ctx context.Context, direction Direction, sender, text string) Result {
+	result := Result{Text: text}
+	if p == nil {
+		return result
+	}
+
+	checked := 0
+	for _, r := range p.rules {
+		if !r.applies(direction) {
+			continue
+		}
+		checked++
+		violations, err := r.check.Check(ctx, result.Text)
+		if err != nil {
+			p.traceError(ctx, direction, sender, r.check.Name(), err)
+			if p.failClosed {
+				reason := fmt.Sprintf("check failed: %v", err)
+				result.Blocked = true
+				result.Decisions = append(result.Decisions, Decision{Check: r.check.Name(), Action: ActionBlock, Direction: direction, Reason: reason})
+				return result
+			}
+			continue
+		}
+		if len(violations) == 0 {
+			continue
+		}
+
+		for _, violation := range violations {
+			decision := Decision{Check: r.check.Name(), Action: r.action, Direction: direction, Reason: violation.Reason}
+			result.Decisions = append(result.Decisions, decision)
+			p.traceDecision(ctx, sender, decision)
+		}
+		switch r.action {
+		case ActionBlock:
+			result.Blocked = true
+			return result
+		case ActionRedact:
+			result.Text = p.redact(result.Text, violations)
+		}
+	}
+
+	if len(result.Decisions) == 0 {
+		p.trace(ctx, tracing.LevelDebug, direction, sender, fmt.Sprintf("%s message passed %d checks", direction, checked), map[string]interface{}{
+			"checks": checked,
+		})
+	}
+	return result
+}
+
+// redact replaces the spans of the violations, or the whole text if a violation has none
+func (p *Pipeline) redact(text string, violations []Violation) string {
+	var spans []Span
+	for _, violation := range violations {
+		if violation.Spans == nil {
+			return p.redaction
+		}
+		for _, span := range violation.Spans {
+			if span.Start >= 0 && span.End <= len(text) && span.Start < span.End {
+				spans = append(spans, span)
+			}
+		}
+	}
+
+	// Merge overlapping spans so that each part of the text is replaced once
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	var sb strings.Builder
+	last := 0
+	for i := 0; i < len(spans); i++ {
+		span := spans[i]
+		for i+1 < len(spans) && spans[i+1].Start <= span.End {
+			span.End = max(span.End, spans[i+1].End)
+			i++
+		}
+		sb.WriteString(text[last:span.Start])
+		sb.WriteString(p.redaction)
+		last = span.End
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// traceDecision records a trace event for a decision
+func (p *Pipeline) traceDecision(ctx context.Context, sender string, decision Decision) {
+	level := tracing.LevelInfo
+	if decision.Action == ActionBlock {
+		level = tracing.LevelWarning
+	}
+	p.logger.Debug("Guardrail decision",
+		"check", decision.Check,
+		"action", decision.Action,
+		"direction", decision.Direction,
+		"sender", sender,
+		"reason", decision.Reason)
+	p.trace(ctx, level, decision.Direction, sender,
+		fmt.Sprintf("%s %s message: %s", decision.Check, actionVerb(decision.Action), decision.Reason),
+		map[string]interface{}{
+			"check":  decision.Check,
+			"action": string(decision.Action),
+			"reason": decision.Reason,
+		})
+}
+
+// traceError records a trace event for a check that failed
+func (p *Pipeline) traceError(ctx context.Context, direction Direction, sender, check string, err error) {
+	p.logger.Warn("Guardrail check failed", "check", check, "direction", direction, "error", err)
+	p.trace(ctx, tracing.LevelError, direction, sender, fmt.Sprintf("%s check failed: %v", check, err), map[string]interface{}{
+		"check":       check,
+		"error":       err.Error(),
+		"fail_closed": p.failClosed,
+	})
+}
+
+// trace records a trace event
+func (p *Pipeline) trace(ctx context.Context, level tracing.Level, direction Direction, sender, message string, metadata map[string]interface{}) {
+	metadata["direction"] = string(direction)
+	p.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentGuardrails,
+		Operation: tracing.OperationInspect,
+		Level:     level,
+		SourceID:  sender,
+		Message:   message,
+		Metadata:  tracing.Correlate(ctx, metadata),
+	})
+}
+
+// actionVerb describes an action in a trace message, e.g. "blocked"
+func actionVerb(action Action) string {
+	switch action {
+	case ActionBlock:
+		return "blocked"
+	case ActionRedact:
+		return "redacted"
+	default:
+		return "flagged"
+	}
+}
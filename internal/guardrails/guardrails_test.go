@@ -0,0 +1,148 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"goproduct/internal/llm"
+	"goproduct/internal/tracing"
+)
+
+// recordingTracer keeps the events it traces
+type recordingTracer struct {
+	mu     sync.Mutex
+	events []tracing.Event
+}
+
+func (t *recordingTracer) Trace(event tracing.Event) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+	return nil
+}
+
+func (t *recordingTracer) Flush() error                 { return nil }
+func (t *recordingTracer) Close() error                 { return nil }
+func (t *recordingTracer) SetLevel(level tracing.Level) {}
+
+// replyLLM replies to every chat with its reply, or fails with its error
+type replyLLM struct {
+	reply string
+	err   error
+}
+
+func (m replyLLM) GenerateChat(ctx context.Context, messages []llm.Message) (string, error) {
+	return m.reply, m.err
+}
+
+func (m replyLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return m.reply, m.err
+}
+
+func mustDenylist(t *testing.T, patterns ...string) *Denylist {
+	t.Helper()
+	denylist, err := NewDenylist("", patterns...)
+	if err != nil {
+		t.Fatalf("Failed to create denylist: %v", err)
+	}
+	return denylist
+}
+
+func TestPipelineActions(t *testing.T) {
+	tracer := &recordingTracer{}
+	pipeline := NewPipeline(
+		WithRule(mustDenylist(t, `(?i)password:\s*\S+`, `\d{4}-\d{4}`), ActionRedact),
+		WithRule(mustDenylist(t, `(?i)competitor`), ActionWarn, Inbound),
+		WithRule(mustDenylist(t, `(?i)drop table`), ActionBlock),
+		WithTracer(tracer),
+	)
+
+	result := pipeline.Inspect(context.Background(), Inbound, "user", "Password: hunter2 and card 1234-5678, like our competitor")
+	if result.Blocked || result.Text != "[redacted] and card [redacted], like our competitor" {
+		t.Errorf("Expected the secrets redacted, got %+v", result)
+	}
+	if len(result.Decisions) != 3 || result.Decisions[2].Action != ActionWarn {
+		t.Errorf("Expected two redactions and a warning, got %+v", result.Decisions)
+	}
+	if len(tracer.events) != 3 || tracer.events[0].Component != tracing.ComponentGuardrails || tracer.events[0].SourceID != "user" {
+		t.Errorf("Expected a trace event for every decision, got %+v", tracer.events)
+	}
+
+	result = pipeline.Inspect(context.Background(), Outbound, "Andy", "Ask our competitor to DROP TABLE users")
+	if !result.Blocked || !strings.Contains(result.Reason(), "drop table") {
+		t.Errorf("Expected the reply blocked, got %+v", result)
+	}
+	if len(result.Decisions) != 1 {
+		t.Errorf("Expected the inbound-only warning to be skipped, got %+v", result.Decisions)
+	}
+
+	tracer.events = nil
+	if result := pipeline.Inspect(context.Background(), Inbound, "user", "Hello"); result.Text != "Hello" || len(result.Decisions) != 0 {
+		t.Errorf("Expected a clean message to pass, got %+v", result)
+	}
+	if len(tracer.events) != 1 || tracer.events[0].Level != tracing.LevelDebug {
+		t.Errorf("Expected a debug event for a passed message, got %+v", tracer.events)
+	}
+
+	var none *Pipeline
+	if result := none.Inspect(context.Background(), Inbound, "user", "DROP TABLE"); result.Blocked {
+		t.Error("Expected a nil pipeline to let messages through")
+	}
+}
+
+func TestMaxLength(t *testing.T) {
+	pipeline := NewPipeline(WithRule(NewMaxLength(5), ActionRedact), WithRedaction("…"))
+	if result := pipeline.Inspect(context.Background(), Inbound, "user", "héllo wörld"); result.Text != "héllo…" {
+		t.Errorf("Expected the text cut after 5 characters, got %q", result.Text)
+	}
+	if result := pipeline.Inspect(context.Background(), Inbound, "user", "héllo"); len(result.Decisions) != 0 {
+		t.Errorf("Expected a text at the limit to pass, got %+v", result.Decisions)
+	}
+}
+
+func TestModerator(t *testing.T) {
+	flagged := NewModerator(replyLLM{reply: `{"flagged": true, "category": "harassment", "reason": "insults a colleague"}`})
+	pipeline := NewPipeline(WithRule(flagged, ActionRedact))
+	result := pipeline.Inspect(context.Background(), Inbound, "user", "You are useless")
+	if result.Text != DefaultRedaction || !strings.Contains(result.Decisions[0].Reason, "harassment: insults a colleague") {
+		t.Errorf("Expected the whole message redacted, got %+v", result)
+	}
+
+	clean := NewModerator(replyLLM{reply: "```json\n{\"flagged\": false}\n```"})
+	if result := NewPipeline(WithRule(clean, ActionBlock)).Inspect(context.Background(), Inbound, "user", "Hi"); result.Blocked {
+		t.Errorf("Expected an unflagged message to pass, got %+v", result)
+	}
+}
+
+func TestPipelineCheckFailures(t *testing.T) {
+	down := NewModerator(replyLLM{err: errors.New("connection refused")})
+	tracer := &recordingTracer{}
+
+	open := NewPipeline(WithRule(down, ActionBlock), WithTracer(tracer))
+	if result := open.Inspect(context.Background(), Inbound, "user", "Hi"); result.Blocked {
+		t.Error("Expected a failed check to be skipped")
+	}
+	if len(tracer.events) == 0 || tracer.events[0].Level != tracing.LevelError {
+		t.Errorf("Expected the failure traced, got %+v", tracer.events)
+	}
+
+	closed := NewPipeline(WithRule(down, ActionWarn), WithFailClosed(true))
+	if result := closed.Inspect(context.Background(), Inbound, "user", "Hi"); !result.Blocked {
+		t.Error("Expected a failed check to block when failing closed")
+	}
+}
+
+func TestNewDenylistErrors(t *testing.T) {
+	if _, err := NewDenylist("secrets"); err == nil {
+		t.Error("Expected an error without patterns")
+	}
+	if _, err := NewDenylist("secrets", "("); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+	if _, err := ParseAction("delete"); err == nil {
+		t.Error("Expected an error for an unknown action")
+	}
+}
@@ -0,0 +1,100 @@
+package guardrails
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"goproduct/internal/llm"
+)
+
+// DefaultModerationCategories are the kinds of content a Moderator flags by default
+var DefaultModerationCategories = []string{"harassment", "hate", "self-harm", "sexual content", "violence", "illegal activity"}
+
+// moderationPrompt asks the model to classify the message that follows
+const moderationPrompt = `You are a content moderator. Decide whether the next message contains any of: %s.
+Messages about product development, even critical or blunt ones, are not flagged.`
+
+// moderationSchema is the verdict the model replies with
+var moderationSchema = llm.JSONSchema{
+	"type": "object",
+	"properties": map[string]any{
+		"flagged":  map[string]any{"type": "boolean"},
+		"category": map[string]any{"type": "string"},
+		"reason":   map[string]any{"type": "string"},
+	},
+	"required": []any{"flagged"},
+}
+
+// moderationVerdict is the reply of the model
+type moderationVerdict struct {
+	Flagged  bool   `json:"flagged"`
+	Category string `json:"category"`
+	Reason   string `json:"reason"`
+}
+
+// Moderator asks a language model whether text contains harmful content. Redacting a
+// flagged text replaces all of it.
+type Moderator struct {
+	model      llm.LanguageModel
+	categories []string
+}
+
+// ModeratorOption is a function that configures a Moderator
+type ModeratorOption func(*Moderator)
+
+// NewModerator creates a check moderating texts with the model
+func NewModerator(model llm.LanguageModel, options ...ModeratorOption) *Moderator {
+	m := &Moderator{
+		model:      model,
+		categories: DefaultModerationCategories,
+	}
+
+	// Apply options
+	for _, option := range options {
+		option(m)
+	}
+
+	return m
+}
+
+// WithModerationCategories sets the kinds of content that are flagged
+func WithModerationCategories(categories ...string) ModeratorOption {
+	return func(m *Moderator) {
+		m.categories = categories
+	}
+}
+
+// Name returns "moderation"
+func (m *Moderator) Name() string {
+	return "moderation"
+}
+
+// Check returns a violation if the model flags the text
+func (m *Moderator) Check(ctx context.Context, text string) ([]Violation, error) {
+	messages := []llm.Message{
+		{Role: "system", Content: fmt.Sprintf(moderationPrompt, strings.Join(m.categories, ", "))},
+		{Role: "user", Content: text},
+	}
+	raw, err := llm.GenerateStructured(ctx, m.model, messages, moderationSchema, llm.WithSchemaName("moderation"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to moderate message: %w", err)
+	}
+	var verdict moderationVerdict
+	if err := json.Unmarshal(raw, &verdict); err != nil {
+		return nil, fmt.Errorf("failed to parse moderation verdict: %w", err)
+	}
+	if !verdict.Flagged {
+		return nil, nil
+	}
+
+	reason := "flagged by moderation"
+	if verdict.Category != "" {
+		reason += " as " + verdict.Category
+	}
+	if verdict.Reason != "" {
+		reason += ": " + verdict.Reason
+	}
+	return []Violation{{Reason: reason}}, nil
+}
@@ -0,0 +1,60 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"goproduct/internal/common"
+)
+
+// Reporter reports the health of the application, e.g. a common.RuntimeContext
+type Reporter interface {
+	Health(ctx context.Context) common.HealthReport
+	Ready() bool
+}
+
+// readiness is the body of /readyz
+type readiness struct {
+	Ready bool `json:"ready"`
+	common.HealthReport
+}
+
+// HealthHandler serves /healthz: the health of every component, with status 200 unless a
+// required component is down
+func HealthHandler(reporter Reporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := reporter.Health(r.Context())
+		status := http.StatusOK
+		if report.Status == common.HealthDown {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	})
+}
+
+// ReadyHandler serves /readyz: status 200 once the components have started and while
+// no required component is down, so that traffic is only sent to an application able to
+// answer; 503 before, while shutting down or when down
+func ReadyHandler(reporter Reporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := readiness{Ready: reporter.Ready()}
+		if body.Ready {
+			body.HealthReport = reporter.Health(r.Context())
+			body.Ready = body.Status != common.HealthDown
+		}
+		status := http.StatusOK
+		if !body.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, body)
+	})
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
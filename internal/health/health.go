@@ -0,0 +1,108 @@
+// Package health checks whether the parts of the application work: the language model
+// is reachable, the knowledge store is open and the message bus delivers messages. The
+// checks are the Health functions of the runtime context's components, whose report is
+// served on /healthz and /readyz in server mode and shown by /health in the chat.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
+	"goproduct/internal/messaging"
+
+	"github.com/google/uuid"
+)
+
+// DefaultInterval is how often checks that cost something, such as a request to the
+// language model provider, run at most
+const DefaultInterval = 30 * time.Second
+
+// probeTimeout bounds the delivery of the bus check's message when the context has no
+// deadline
+const probeTimeout = 5 * time.Second
+
+// Check returns why something does not work, nil if it does
+type Check func(ctx context.Context) error
+
+// LanguageModel checks that the provider of a language model, or of the model it
+// decorates, is reachable. Models that cannot be pinged, such as the mock models, are
+// taken to be reachable.
+func LanguageModel(model llm.LanguageModel) Check {
+	return func(ctx context.Context) error {
+		pinger, ok := llm.PingerFrom(model)
+		if !ok {
+			return nil
+		}
+		return pinger.Ping(ctx)
+	}
+}
+
+// Store checks that a knowledge store is open and answers
+func Store(store knowledge.Store) Check {
+	return func(ctx context.Context) error {
+		if opener, ok := store.(interface{ IsOpen() bool }); ok && !opener.IsOpen() {
+			return errors.New("store is closed")
+		}
+		_, err := store.Info()
+		return err
+	}
+}
+
+// Bus checks that a message bus delivers messages, sending one to a probe subscribed for
+// the check. The message is ephemeral, kept out of the bus history.
+func Bus(bus messaging.MessageBus) Check {
+	return func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, probeTimeout)
+			defer cancel()
+		}
+
+		probeID := "health-probe-" + uuid.New().String()
+		delivered := make(chan struct{}, 1)
+		err := bus.Subscribe(probeID, func(msg messaging.Message) error {
+			select {
+			case delivered <- struct{}{}:
+			default:
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to subscribe: %w", err)
+		}
+		defer bus.Unsubscribe(probeID)
+
+		if err := bus.Publish(messaging.NewTextMessage(probeID, []string{probeID}, "ping").Ephemeral()); err != nil {
+			return fmt.Errorf("failed to publish: %w", err)
+		}
+		select {
+		case <-delivered:
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("message not delivered: %w", ctx.Err())
+		}
+	}
+}
+
+// Cached runs a check at most once per interval and reports its last result in between,
+// so that frequent probes do not load what is checked
+func Cached(check Check, every time.Duration) Check {
+	var mu sync.Mutex
+	var checked time.Time
+	var last error
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !checked.IsZero() && time.Since(checked) < every {
+			return last
+		}
+		last = check(ctx)
+		checked = time.Now()
+		return last
+	}
+}
@@ -0,0 +1,97 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"goproduct/internal/common"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/llm"
+	"goproduct/internal/messaging"
+)
+
+func TestChecks(t *testing.T) {
+	store, err := knowledge.NewMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Open()
+	if err := Store(store)(context.Background()); err != nil {
+		t.Errorf("Expected an open store to be healthy, got %v", err)
+	}
+	store.Close()
+	if err := Store(store)(context.Background()); err == nil {
+		t.Error("Expected a closed store to be unhealthy")
+	}
+
+	if err := Bus(messaging.NewMemoryMessageBus())(context.Background()); err != nil {
+		t.Errorf("Expected the bus to deliver the probe, got %v", err)
+	}
+
+	if err := LanguageModel(llm.NewEchoLLM(0))(context.Background()); err != nil {
+		t.Errorf("Expected a model that cannot be pinged to be taken as reachable, got %v", err)
+	}
+}
+
+func TestCached(t *testing.T) {
+	calls := 0
+	check := Cached(func(context.Context) error {
+		calls++
+		return errors.New("unreachable")
+	}, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if err := check(context.Background()); err == nil || err.Error() != "unreachable" {
+			t.Errorf("Expected the result of the check, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the check to run once per interval, ran %d times", calls)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	runtime, _ := common.NewRuntimeContext(common.RuntimeOptions{})
+	var busErr error
+	runtime.Register(common.Component{Name: "message bus", Health: func(context.Context) error { return busErr }})
+
+	get := func(handler http.Handler) (int, map[string]interface{}) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+		var body map[string]interface{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Invalid body %q: %v", recorder.Body.String(), err)
+		}
+		return recorder.Code, body
+	}
+
+	if code, body := get(ReadyHandler(runtime)); code != http.StatusServiceUnavailable || body["ready"] != false {
+		t.Errorf("Expected not ready before start, got %d %v", code, body)
+	}
+	runtime.Start(context.Background())
+	if code, body := get(ReadyHandler(runtime)); code != http.StatusOK || body["ready"] != true || body["status"] != "up" {
+		t.Errorf("Expected ready once started, got %d %v", code, body)
+	}
+	if code, body := get(HealthHandler(runtime)); code != http.StatusOK || len(body["components"].([]interface{})) != 1 {
+		t.Errorf("Expected the health of the components, got %d %v", code, body)
+	}
+
+	busErr = errors.New("disconnected")
+	if code, _ := get(HealthHandler(runtime)); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when a component is down, got %d", code)
+	}
+	if code, _ := get(ReadyHandler(runtime)); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready when a component is down, got %d", code)
+	}
+
+	busErr = nil
+	runtime.Shutdown(context.Background())
+	if code, _ := get(ReadyHandler(runtime)); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready after shutdown, got %d", code)
+	}
+}
@@ -0,0 +1,127 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// chatGPTConversation mirrors a conversation in a ChatGPT conversations.json export
+type chatGPTConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     float64                `json:"create_time"`
+	CurrentNode    string                 `json:"current_node"`
+	Mapping        map[string]chatGPTNode `json:"mapping"`
+}
+
+// chatGPTNode is a node in the message tree of a ChatGPT conversation
+type chatGPTNode struct {
+	ID      string          `json:"id"`
+	Parent  string          `json:"parent"`
+	Message *chatGPTMessage `json:"message"`
+}
+
+// chatGPTMessage is a message attached to a ChatGPT conversation node
+type chatGPTMessage struct {
+	ID     string `json:"id"`
+	Author struct {
+		Role string `json:"role"`
+		Name string `json:"name"`
+	} `json:"author"`
+	CreateTime *float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+	} `json:"content"`
+}
+
+// ParseChatGPTExport parses the conversations.json file from a ChatGPT data export.
+// Only the active branch of each conversation (ending at current_node) is imported.
+func ParseChatGPTExport(r io.Reader) ([]Conversation, error) {
+	var raw []chatGPTConversation
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse ChatGPT export: %w", err)
+	}
+
+	conversations := make([]Conversation, 0, len(raw))
+	for _, rc := range raw {
+		id := rc.ConversationID
+		if id == "" {
+			id = rc.ID
+		}
+
+		conv := Conversation{
+			ID:        id,
+			Title:     rc.Title,
+			Source:    SourceChatGPT,
+			CreatedAt: unixSeconds(rc.CreateTime),
+		}
+
+		// Walk from the current node back to the root, then reverse
+		path := make([]chatGPTNode, 0)
+		visited := make(map[string]bool)
+		for nodeID := rc.CurrentNode; nodeID != "" && !visited[nodeID]; {
+			visited[nodeID] = true
+			node, ok := rc.Mapping[nodeID]
+			if !ok {
+				break
+			}
+			path = append(path, node)
+			nodeID = node.Parent
+		}
+
+		for i := len(path) - 1; i >= 0; i-- {
+			msg := path[i].Message
+			if msg == nil {
+				continue
+			}
+
+			role := msg.Author.Role
+			if role != "user" && role != "assistant" {
+				// Skip system and tool messages
+				continue
+			}
+
+			u := Utterance{
+				ID:      msg.ID,
+				Speaker: role,
+				Role:    role,
+				Text:    chatGPTText(msg.Content.Parts),
+			}
+			if msg.CreateTime != nil {
+				u.Timestamp = unixSeconds(*msg.CreateTime)
+			}
+			conv.Utterances = append(conv.Utterances, u)
+		}
+
+		conversations = append(conversations, conv)
+	}
+
+	return conversations, nil
+}
+
+// chatGPTText joins the text parts of a ChatGPT message, ignoring non-text parts
+func chatGPTText(parts []json.RawMessage) string {
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		var text string
+		if err := json.Unmarshal(part, &text); err == nil && text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// unixSeconds converts fractional unix seconds to a time
+func unixSeconds(seconds float64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
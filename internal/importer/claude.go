@@ -0,0 +1,78 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// claudeConversation mirrors a conversation in a Claude conversations.json export
+type claudeConversation struct {
+	UUID         string          `json:"uuid"`
+	Name         string          `json:"name"`
+	CreatedAt    time.Time       `json:"created_at"`
+	ChatMessages []claudeMessage `json:"chat_messages"`
+}
+
+// claudeMessage is a single message in a Claude conversation export
+type claudeMessage struct {
+	UUID      string    `json:"uuid"`
+	Text      string    `json:"text"`
+	Sender    string    `json:"sender"`
+	CreatedAt time.Time `json:"created_at"`
+	Content   []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+// ParseClaudeExport parses the conversations.json file from a Claude data export
+func ParseClaudeExport(r io.Reader) ([]Conversation, error) {
+	var raw []claudeConversation
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse Claude export: %w", err)
+	}
+
+	conversations := make([]Conversation, 0, len(raw))
+	for _, rc := range raw {
+		conv := Conversation{
+			ID:        rc.UUID,
+			Title:     rc.Name,
+			Source:    SourceClaude,
+			CreatedAt: rc.CreatedAt,
+		}
+
+		for _, msg := range rc.ChatMessages {
+			role := "user"
+			if msg.Sender == "assistant" {
+				role = "assistant"
+			}
+
+			text := msg.Text
+			if text == "" {
+				// Newer exports carry the text in content blocks
+				parts := make([]string, 0, len(msg.Content))
+				for _, block := range msg.Content {
+					if block.Type == "text" && block.Text != "" {
+						parts = append(parts, block.Text)
+					}
+				}
+				text = strings.Join(parts, "\n")
+			}
+
+			conv.Utterances = append(conv.Utterances, Utterance{
+				ID:        msg.UUID,
+				Speaker:   msg.Sender,
+				Role:      role,
+				Text:      text,
+				Timestamp: msg.CreatedAt,
+			})
+		}
+
+		conversations = append(conversations, conv)
+	}
+
+	return conversations, nil
+}
@@ -0,0 +1,234 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/logging"
+	"os"
+	"strings"
+	"time"
+)
+
+// Source constants identify the tool a conversation was exported from
+const (
+	SourceChatGPT = "chatgpt"
+	SourceClaude  = "claude"
+	SourceSlack   = "slack"
+)
+
+// Utterance represents a single message in an imported conversation
+type Utterance struct {
+	ID        string    // Identifier of the message in the source tool
+	Speaker   string    // Identifier of the speaker in the source tool (user ID, role, etc.)
+	Role      string    // Normalized role: "user", "assistant", "system"
+	Text      string    // Message text
+	Timestamp time.Time // When the message was sent
+}
+
+// Conversation represents a conversation parsed from an export
+type Conversation struct {
+	ID         string      // Identifier of the conversation in the source tool
+	Title      string      // Conversation title or channel name
+	Source     string      // Source tool: "chatgpt", "claude", "slack"
+	CreatedAt  time.Time   // When the conversation started
+	Utterances []Utterance // Messages in chronological order
+}
+
+// OwnerMap maps speakers from the source tool to entity IDs in this system
+type OwnerMap struct {
+	Speakers     map[string]string // Explicit speaker ID to entity ID mappings
+	DefaultHuman string            // Entity ID used for unmapped human speakers
+	DefaultAgent string            // Entity ID used for unmapped assistant speakers
+}
+
+// Resolve returns the entity ID and type owning an utterance
+func (o OwnerMap) Resolve(u Utterance) (string, string) {
+	if id, ok := o.Speakers[u.Speaker]; ok && id != "" {
+		if u.Role == "assistant" {
+			return id, "agent"
+		}
+		return id, "human"
+	}
+
+	if u.Role == "assistant" {
+		if o.DefaultAgent != "" {
+			return o.DefaultAgent, "agent"
+		}
+		return u.Speaker, "agent"
+	}
+
+	if o.DefaultHuman != "" {
+		return o.DefaultHuman, "human"
+	}
+	return u.Speaker, "human"
+}
+
+// Result summarizes an import run
+type Result struct {
+	JobID         string // Ingestion job ID recorded in entry provenance
+	Conversations int    // Number of conversations processed
+	Imported      int    // Number of entries written to the store
+	Duplicates    int    // Number of entries skipped because they already existed
+	Skipped       int    // Number of utterances skipped because they were empty
+}
+
+// Importer converts parsed conversations into knowledge entries
+type Importer struct {
+	store  knowledge.Store
+	owners OwnerMap
+	logger *logging.Logger
+}
+
+// NewImporter creates a new importer writing into the given store
+func NewImporter(store knowledge.Store, owners OwnerMap) *Importer {
+	return &Importer{
+		store:  store,
+		owners: owners,
+		logger: logging.Get().Component("importer"),
+	}
+}
+
+// Import writes the conversations into the store as message entries.
+// Entry IDs are derived from the source, conversation and message IDs so that
+// importing the same export twice does not create duplicates.
+func (i *Importer) Import(conversations []Conversation) (Result, error) {
+	result := Result{
+		JobID:         fmt.Sprintf("import-%d", time.Now().UnixNano()),
+		Conversations: len(conversations),
+	}
+
+	entries := make([]knowledge.Entry, 0)
+	seen := make(map[string]bool)
+
+	for _, conv := range conversations {
+		participants := i.participants(conv)
+
+		for idx, u := range conv.Utterances {
+			if strings.TrimSpace(u.Text) == "" {
+				result.Skipped++
+				continue
+			}
+
+			messageID := u.ID
+			if messageID == "" {
+				messageID = fmt.Sprintf("%d", idx)
+			}
+			id := EntryID(conv.Source, conv.ID, messageID)
+
+			// Deduplicate within this run and against existing records
+			if seen[id] {
+				result.Duplicates++
+				continue
+			}
+			seen[id] = true
+			if _, err := i.store.GetRecord(id); err == nil {
+				result.Duplicates++
+				continue
+			}
+
+			ownerID, ownerType := i.owners.Resolve(u)
+			timestamp := u.Timestamp
+			if timestamp.IsZero() {
+				timestamp = conv.CreatedAt
+			}
+
+			provenance := knowledge.FromIngestion(result.JobID)
+			provenance.ConversationID = conv.ID
+			provenance.MessageID = messageID
+			provenance.Details = map[string]string{"source": conv.Source}
+
+			entries = append(entries, knowledge.Entry{
+				ID:          id,
+				Category:    knowledge.CategoryMessage,
+				ContentType: knowledge.ContentTypeText,
+				Content:     []byte(u.Text),
+				Importance:  knowledge.ImportanceLow,
+				CreatedAt:   timestamp,
+				UpdatedAt:   timestamp,
+				SourceID:    conv.ID,
+				SourceType:  "import",
+				OwnerID:     ownerID,
+				OwnerType:   ownerType,
+				SubjectIDs:  participants,
+				SubjectType: "conversation",
+				Tags:        []string{"imported", conv.Source},
+				References:  []knowledge.Reference{},
+				Metadata: map[string]string{
+					"conversation_id":    conv.ID,
+					"conversation_title": conv.Title,
+					"source":             conv.Source,
+					"speaker":            u.Speaker,
+					"role":               u.Role,
+					"message_index":      fmt.Sprintf("%d", idx),
+				},
+				Provenance: []knowledge.ProvenanceStep{provenance},
+			})
+		}
+	}
+
+	if len(entries) > 0 {
+		if err := i.store.LoadRecords(entries...); err != nil {
+			return result, fmt.Errorf("failed to load imported records: %w", err)
+		}
+		if err := i.store.Flush(); err != nil {
+			return result, fmt.Errorf("failed to flush imported records: %w", err)
+		}
+	}
+	result.Imported = len(entries)
+
+	i.logger.Info("Conversation import complete",
+		"job_id", result.JobID,
+		"conversations", result.Conversations,
+		"imported", result.Imported,
+		"duplicates", result.Duplicates,
+		"skipped", result.Skipped)
+
+	return result, nil
+}
+
+// participants returns the distinct entity IDs taking part in a conversation
+func (i *Importer) participants(conv Conversation) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+	for _, u := range conv.Utterances {
+		id, _ := i.owners.Resolve(u)
+		if id != "" && !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// ParseFile parses an export of the source tool: the conversations.json of a ChatGPT or
+// Claude export, or the zip archive of a Slack export
+func ParseFile(source, path string) ([]Conversation, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export: %w", err)
+	}
+	defer file.Close()
+
+	switch source {
+	case SourceChatGPT:
+		return ParseChatGPTExport(file)
+	case SourceClaude:
+		return ParseClaudeExport(file)
+	case SourceSlack:
+		info, err := file.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat export: %w", err)
+		}
+		return ParseSlackExport(file, info.Size())
+	default:
+		return nil, fmt.Errorf("unknown export format %q, use %s, %s or %s", source, SourceChatGPT, SourceClaude, SourceSlack)
+	}
+}
+
+// EntryID returns the deterministic knowledge entry ID for an imported message
+func EntryID(source, conversationID, messageID string) string {
+	sum := sha256.Sum256([]byte(source + "\x00" + conversationID + "\x00" + messageID))
+	return "import-" + hex.EncodeToString(sum[:12])
+}
@@ -0,0 +1,184 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"goproduct/internal/knowledge"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const chatGPTExport = `[{
+	"id": "conv-1",
+	"title": "Planning",
+	"create_time": 1700000000.5,
+	"current_node": "n3",
+	"mapping": {
+		"root": {"id": "root", "parent": "", "message": null},
+		"n1": {"id": "n1", "parent": "root", "message": {"id": "m1", "author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]}}},
+		"n2": {"id": "n2", "parent": "n1", "message": {"id": "m2", "author": {"role": "user"}, "create_time": 1700000001, "content": {"content_type": "text", "parts": ["What should we build?"]}}},
+		"n2b": {"id": "n2b", "parent": "n2", "message": {"id": "m2b", "author": {"role": "assistant"}, "create_time": 1700000002, "content": {"content_type": "text", "parts": ["Abandoned branch"]}}},
+		"n3": {"id": "n3", "parent": "n2", "message": {"id": "m3", "author": {"role": "assistant"}, "create_time": 1700000003, "content": {"content_type": "text", "parts": ["A product agent."]}}}
+	}
+}]`
+
+const claudeExport = `[{
+	"uuid": "c-1",
+	"name": "Roadmap",
+	"created_at": "2024-01-01T10:00:00Z",
+	"chat_messages": [
+		{"uuid": "u-1", "text": "Draft the roadmap", "sender": "human", "created_at": "2024-01-01T10:00:00Z"},
+		{"uuid": "u-2", "text": "", "sender": "assistant", "created_at": "2024-01-01T10:00:05Z", "content": [{"type": "text", "text": "Here is a draft."}]}
+	]
+}]`
+
+func TestParseChatGPTExport(t *testing.T) {
+	convs, err := ParseChatGPTExport(strings.NewReader(chatGPTExport))
+	if err != nil {
+		t.Fatalf("Failed to parse ChatGPT export: %v", err)
+	}
+	if len(convs) != 1 {
+		t.Fatalf("Expected 1 conversation, got %d", len(convs))
+	}
+
+	conv := convs[0]
+	if conv.Title != "Planning" || conv.Source != SourceChatGPT {
+		t.Errorf("Unexpected conversation header: %+v", conv)
+	}
+	if len(conv.Utterances) != 2 {
+		t.Fatalf("Expected 2 utterances on the active branch, got %d: %+v", len(conv.Utterances), conv.Utterances)
+	}
+	if conv.Utterances[0].Text != "What should we build?" || conv.Utterances[1].Text != "A product agent." {
+		t.Errorf("Unexpected utterances: %+v", conv.Utterances)
+	}
+}
+
+func TestParseClaudeExport(t *testing.T) {
+	convs, err := ParseClaudeExport(strings.NewReader(claudeExport))
+	if err != nil {
+		t.Fatalf("Failed to parse Claude export: %v", err)
+	}
+	if len(convs) != 1 || len(convs[0].Utterances) != 2 {
+		t.Fatalf("Unexpected parse result: %+v", convs)
+	}
+	if convs[0].Utterances[1].Role != "assistant" || convs[0].Utterances[1].Text != "Here is a draft." {
+		t.Errorf("Expected assistant text from content blocks, got %+v", convs[0].Utterances[1])
+	}
+}
+
+func TestParseSlackExport(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := map[string]string{
+		"channels.json":           `[{"id": "C1", "name": "product"}]`,
+		"users.json":              `[{"id": "U1", "name": "alex"}]`,
+		"product/2024-01-02.json": `[{"type": "message", "user": "U1", "text": "second", "ts": "1704200000.000200"}]`,
+		"product/2024-01-01.json": `[{"type": "message", "user": "U1", "text": "first", "ts": "1704100000.000100"},
+			{"type": "message", "subtype": "channel_join", "user": "U2", "text": "joined", "ts": "1704100001.000100"},
+			{"type": "message", "subtype": "bot_message", "bot_id": "B1", "text": "bot says hi", "ts": "1704100002.000100"}]`,
+	}
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	convs, err := ParseSlackExport(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to parse Slack export: %v", err)
+	}
+	if len(convs) != 1 {
+		t.Fatalf("Expected 1 conversation, got %d", len(convs))
+	}
+
+	conv := convs[0]
+	if conv.ID != "C1" || conv.Title != "#product" {
+		t.Errorf("Unexpected conversation header: %+v", conv)
+	}
+	if len(conv.Utterances) != 3 {
+		t.Fatalf("Expected 3 utterances, got %d: %+v", len(conv.Utterances), conv.Utterances)
+	}
+	if conv.Utterances[0].Text != "first" || conv.Utterances[2].Text != "second" {
+		t.Errorf("Expected chronological order, got %+v", conv.Utterances)
+	}
+	if conv.Utterances[1].Role != "assistant" {
+		t.Errorf("Expected bot message to have assistant role, got %q", conv.Utterances[1].Role)
+	}
+}
+
+func TestImporter_DeduplicatesAndMapsOwners(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	convs, err := ParseChatGPTExport(strings.NewReader(chatGPTExport))
+	if err != nil {
+		t.Fatalf("Failed to parse ChatGPT export: %v", err)
+	}
+
+	imp := NewImporter(store, OwnerMap{
+		Speakers:     map[string]string{"user": "human-alex"},
+		DefaultAgent: "agent-andy",
+	})
+
+	result, err := imp.Import(convs)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if result.Imported != 2 || result.Duplicates != 0 {
+		t.Errorf("Expected 2 imported and 0 duplicates, got %+v", result)
+	}
+
+	entry, err := store.GetRecord(EntryID(SourceChatGPT, "conv-1", "m2"))
+	if err != nil {
+		t.Fatalf("Failed to get imported record: %v", err)
+	}
+	if entry.OwnerID != "human-alex" || entry.OwnerType != "human" {
+		t.Errorf("Expected owner human-alex/human, got %s/%s", entry.OwnerID, entry.OwnerType)
+	}
+	if len(entry.Provenance) == 0 || entry.Provenance[0].Origin != knowledge.OriginIngestion {
+		t.Errorf("Expected ingestion provenance, got %+v", entry.Provenance)
+	}
+
+	agentEntry, err := store.GetRecord(EntryID(SourceChatGPT, "conv-1", "m3"))
+	if err != nil {
+		t.Fatalf("Failed to get imported record: %v", err)
+	}
+	if agentEntry.OwnerID != "agent-andy" || agentEntry.OwnerType != "agent" {
+		t.Errorf("Expected owner agent-andy/agent, got %s/%s", agentEntry.OwnerID, agentEntry.OwnerType)
+	}
+
+	// Importing the same export again must not create duplicates
+	result, err = imp.Import(convs)
+	if err != nil {
+		t.Fatalf("Failed to re-import: %v", err)
+	}
+	if result.Imported != 0 || result.Duplicates != 2 {
+		t.Errorf("Expected 0 imported and 2 duplicates on re-import, got %+v", result)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.json")
+	if err := os.WriteFile(path, []byte(claudeExport), 0600); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+
+	convs, err := ParseFile(SourceClaude, path)
+	if err != nil || len(convs) != 1 || convs[0].Source != SourceClaude {
+		t.Errorf("Expected the Claude conversation, got %+v, %v", convs, err)
+	}
+	if _, err := ParseFile(SourceClaude, filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected a missing export to fail")
+	}
+	if _, err := ParseFile("teams", path); err == nil || !strings.Contains(err.Error(), "unknown export format") {
+		t.Errorf("Expected an unknown format to be rejected, got %v", err)
+	}
+}
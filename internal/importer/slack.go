@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// slackChannel mirrors an entry of channels.json in a Slack export
+type slackChannel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// slackMessage mirrors a message in a Slack export day file
+type slackMessage struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype"`
+	User     string `json:"user"`
+	BotID    string `json:"bot_id"`
+	Text     string `json:"text"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts"`
+}
+
+// ParseSlackExport parses a Slack workspace export archive (zip).
+// Each channel becomes one conversation with its messages in chronological order.
+func ParseSlackExport(r io.ReaderAt, size int64) ([]Conversation, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Slack export: %w", err)
+	}
+
+	// Index channel IDs by name when channels.json is present
+	channelIDs := make(map[string]string)
+	for _, file := range archive.File {
+		if file.Name != "channels.json" {
+			continue
+		}
+		var channels []slackChannel
+		if err := readZipJSON(file, &channels); err != nil {
+			return nil, err
+		}
+		for _, ch := range channels {
+			channelIDs[ch.Name] = ch.ID
+		}
+	}
+
+	byChannel := make(map[string]*Conversation)
+	for _, file := range archive.File {
+		dir, name := path.Split(file.Name)
+		dir = strings.TrimSuffix(dir, "/")
+		if dir == "" || strings.Contains(dir, "/") || !strings.HasSuffix(name, ".json") {
+			// Day files live in a single directory level named after the channel
+			continue
+		}
+
+		var messages []slackMessage
+		if err := readZipJSON(file, &messages); err != nil {
+			return nil, err
+		}
+
+		conv, ok := byChannel[dir]
+		if !ok {
+			id := channelIDs[dir]
+			if id == "" {
+				id = dir
+			}
+			conv = &Conversation{ID: id, Title: "#" + dir, Source: SourceSlack}
+			byChannel[dir] = conv
+		}
+
+		for _, msg := range messages {
+			if msg.Type != "message" || msg.Subtype == "channel_join" || msg.Subtype == "channel_leave" {
+				continue
+			}
+
+			role := "user"
+			speaker := msg.User
+			if msg.BotID != "" || msg.Subtype == "bot_message" {
+				role = "assistant"
+				if speaker == "" {
+					speaker = msg.BotID
+				}
+			}
+
+			conv.Utterances = append(conv.Utterances, Utterance{
+				ID:        msg.TS,
+				Speaker:   speaker,
+				Role:      role,
+				Text:      msg.Text,
+				Timestamp: slackTimestamp(msg.TS),
+			})
+		}
+	}
+
+	// Produce a stable, chronologically ordered result
+	names := make([]string, 0, len(byChannel))
+	for name := range byChannel {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	conversations := make([]Conversation, 0, len(names))
+	for _, name := range names {
+		conv := byChannel[name]
+		sort.SliceStable(conv.Utterances, func(i, j int) bool {
+			return conv.Utterances[i].Timestamp.Before(conv.Utterances[j].Timestamp)
+		})
+		if len(conv.Utterances) > 0 {
+			conv.CreatedAt = conv.Utterances[0].Timestamp
+		}
+		conversations = append(conversations, *conv)
+	}
+
+	return conversations, nil
+}
+
+// readZipJSON decodes a JSON file from a zip archive
+func readZipJSON(file *zip.File, v interface{}) error {
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s in Slack export: %w", file.Name, err)
+	}
+	defer rc.Close()
+
+	if err := json.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s in Slack export: %w", file.Name, err)
+	}
+	return nil
+}
+
+// slackTimestamp converts a Slack "seconds.micros" timestamp to a time
+func slackTimestamp(ts string) time.Time {
+	seconds, err := strconv.ParseFloat(ts, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return unixSeconds(seconds)
+}
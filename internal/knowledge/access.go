@@ -0,0 +1,205 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Access is a number of times retrieval surfaced a record
+type Access struct {
+	ID    string    // Record that was surfaced
+	Count int       // Number of times, at least 1
+	At    time.Time // Latest time it was surfaced
+}
+
+// applyAccess adds the access to the record's statistics
+func applyAccess(record Entry, access Access) Entry {
+	count := access.Count
+	if count < 1 {
+		count = 1
+	}
+	record.AccessCount += count
+	if access.At.After(record.LastAccessedAt) {
+		record.LastAccessedAt = access.At
+	}
+	return record
+}
+
+// keepAccessStats carries the access statistics of the stored record over to an update,
+// so callers holding an older copy of the record do not reset them
+func keepAccessStats(existing, record Entry) Entry {
+	record.AccessCount = existing.AccessCount
+	record.LastAccessedAt = existing.LastAccessedAt
+	return record
+}
+
+// usageBoost returns the factor a search score is multiplied with for the record's usage
+func usageBoost(record Entry, weight float64) float64 {
+	if weight <= 0 || record.AccessCount <= 0 {
+		return 1
+	}
+	return 1 + weight*math.Log1p(float64(record.AccessCount))
+}
+
+// totalAccessCount sums the access counts of the records
+func totalAccessCount(records map[string]Entry) int {
+	total := 0
+	for _, record := range records {
+		total += record.AccessCount
+	}
+	return total
+}
+
+// MostAccessed returns the active records retrieval surfaced most often, most used first.
+// Records that were never surfaced are left out.
+func MostAccessed(store Store, limit int) ([]Entry, error) {
+	records, err := store.SearchRecords(Filter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search records: %w", err)
+	}
+
+	used := make([]Entry, 0)
+	for _, record := range records {
+		if record.AccessCount > 0 {
+			used = append(used, record)
+		}
+	}
+	sort.Slice(used, func(i, j int) bool {
+		if used[i].AccessCount != used[j].AccessCount {
+			return used[i].AccessCount > used[j].AccessCount
+		}
+		return used[i].LastAccessedAt.After(used[j].LastAccessedAt)
+	})
+	if limit > 0 && len(used) > limit {
+		used = used[:limit]
+	}
+	return used, nil
+}
+
+// AccessTracker collects retrieval accesses in memory and writes them to the store in
+// batches, so surfacing an entry does not cost a store write each time
+type AccessTracker struct {
+	store    Store
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]Access
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+}
+
+// NewAccessTracker creates a tracker that writes pending accesses every interval
+func NewAccessTracker(store Store, interval time.Duration) *AccessTracker {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &AccessTracker{
+		store:    store,
+		interval: interval,
+		pending:  make(map[string]Access),
+	}
+}
+
+// Record notes that retrieval surfaced the records now
+func (a *AccessTracker) Record(ids ...string) {
+	now := time.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, id := range ids {
+		access := a.pending[id]
+		access.ID = id
+		access.Count++
+		access.At = now
+		a.pending[id] = access
+	}
+}
+
+// Pending returns the number of records with accesses not yet written to the store
+func (a *AccessTracker) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.pending)
+}
+
+// Flush writes the pending accesses to the store in a single batch
+func (a *AccessTracker) Flush() error {
+	a.mu.Lock()
+	if len(a.pending) == 0 {
+		a.mu.Unlock()
+		return nil
+	}
+	accesses := make([]Access, 0, len(a.pending))
+	for _, access := range a.pending {
+		accesses = append(accesses, access)
+	}
+	a.pending = make(map[string]Access)
+	a.mu.Unlock()
+
+	if err := a.store.RecordAccess(accesses...); err != nil {
+		// Keep the accesses for the next attempt
+		a.mu.Lock()
+		for _, access := range accesses {
+			pending := a.pending[access.ID]
+			pending.ID = access.ID
+			pending.Count += access.Count
+			if access.At.After(pending.At) {
+				pending.At = access.At
+			}
+			a.pending[access.ID] = pending
+		}
+		a.mu.Unlock()
+		return fmt.Errorf("failed to record knowledge accesses: %w", err)
+	}
+	return nil
+}
+
+// Start writes pending accesses on the tracker's interval until the context is done or
+// Stop is called
+func (a *AccessTracker) Start(ctx context.Context) {
+	a.mu.Lock()
+	if a.stopCh != nil {
+		a.mu.Unlock()
+		return
+	}
+	a.stopCh = make(chan struct{})
+	a.doneCh = make(chan struct{})
+	stopCh, doneCh := a.stopCh, a.doneCh
+	a.mu.Unlock()
+
+	go func() {
+		defer close(doneCh)
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				// Failed writes stay pending and are retried on the next tick
+				a.Flush()
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduled writes and writes what is still pending
+func (a *AccessTracker) Stop() error {
+	a.mu.Lock()
+	stopCh, doneCh := a.stopCh, a.doneCh
+	a.stopCh, a.doneCh = nil, nil
+	a.mu.Unlock()
+
+	if stopCh != nil {
+		close(stopCh)
+		<-doneCh
+	}
+	return a.Flush()
+}
@@ -0,0 +1,119 @@
+package knowledge
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testRecordAccess(t *testing.T, store Store) {
+	err := store.LoadRecords(
+		Entry{ID: "go", Category: CategoryFact, Content: []byte("backend language is Go")},
+		Entry{ID: "rust", Category: CategoryFact, Content: []byte("cli language is Rust")},
+		Entry{ID: "unused", Category: CategoryFact, Content: []byte("nobody asks about this")},
+	)
+	if err != nil {
+		t.Fatalf("Failed to load records: %v", err)
+	}
+	before, _ := store.GetRecord("rust")
+
+	at := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	err = store.RecordAccess(Access{ID: "rust", Count: 3, At: at}, Access{ID: "go", Count: 1, At: at}, Access{ID: "purged", Count: 1, At: at})
+	if err != nil {
+		t.Fatalf("Failed to record access: %v", err)
+	}
+
+	rust, _ := store.GetRecord("rust")
+	if rust.AccessCount != 3 || !rust.LastAccessedAt.Equal(at) {
+		t.Errorf("Expected 3 accesses at %v, got %d at %v", at, rust.AccessCount, rust.LastAccessedAt)
+	}
+	if !rust.UpdatedAt.Equal(before.UpdatedAt) || len(rust.Provenance) != len(before.Provenance) {
+		t.Error("Expected access not to change UpdatedAt or provenance")
+	}
+
+	// Updates from an older copy keep the statistics
+	before.Content = []byte("cli language is Rust 2024")
+	if err := store.UpdateRecord(before); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	if rust, _ = store.GetRecord("rust"); rust.AccessCount != 3 {
+		t.Errorf("Expected update to keep access count 3, got %d", rust.AccessCount)
+	}
+
+	used, err := MostAccessed(store, 5)
+	if err != nil {
+		t.Fatalf("MostAccessed failed: %v", err)
+	}
+	if len(used) != 2 || used[0].ID != "rust" || used[1].ID != "go" {
+		t.Errorf("Expected [rust go], got %v", entryIDs(used))
+	}
+
+	// Usage lifts otherwise equal matches
+	results, _ := store.FullTextSearch("language", WithSearchUsageBoost(1))
+	if ids := resultIDs(results); len(ids) != 2 || ids[0] != "rust" {
+		t.Errorf("Expected rust first with usage boost, got %v", ids)
+	}
+
+	info, _ := store.Info()
+	if info["access_count"] != "4" {
+		t.Errorf("Expected access_count 4 in info, got %q", info["access_count"])
+	}
+}
+
+func entryIDs(entries []Entry) []string {
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func TestMemoryStoreRecordAccess(t *testing.T) {
+	store, _ := NewMemoryStore()
+	store.Open()
+	testRecordAccess(t, store)
+}
+
+func TestFileStoreRecordAccess(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "knowledge.json")
+	store, _ := NewFileStore(filename)
+	store.Open()
+	testRecordAccess(t, store)
+	store.Close()
+
+	reopened, _ := NewFileStore(filename)
+	reopened.Open()
+	defer reopened.Close()
+	if rust, _ := reopened.GetRecord("rust"); rust.AccessCount != 3 {
+		t.Errorf("Expected access count to be persisted, got %d", rust.AccessCount)
+	}
+}
+
+func TestAccessTrackerBatchesWrites(t *testing.T) {
+	store, _ := NewMemoryStore()
+	store.Open()
+	store.AddRecord(Entry{ID: "a", Category: CategoryFact})
+	store.AddRecord(Entry{ID: "b", Category: CategoryFact})
+
+	tracker := NewAccessTracker(store, time.Hour)
+	tracker.Record("a", "b")
+	tracker.Record("a")
+
+	if a, _ := store.GetRecord("a"); a.AccessCount != 0 {
+		t.Errorf("Expected accesses to be buffered, got count %d", a.AccessCount)
+	}
+	if tracker.Pending() != 2 {
+		t.Errorf("Expected 2 pending records, got %d", tracker.Pending())
+	}
+
+	tracker.Start(t.Context())
+	if err := tracker.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if a, _ := store.GetRecord("a"); a.AccessCount != 2 || a.LastAccessedAt.IsZero() {
+		t.Errorf("Expected 2 accesses after stop, got %d at %v", a.AccessCount, a.LastAccessedAt)
+	}
+	if tracker.Pending() != 0 {
+		t.Errorf("Expected nothing pending, got %d", tracker.Pending())
+	}
+}
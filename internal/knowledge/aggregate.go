@@ -0,0 +1,49 @@
+package knowledge
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// GroupByTags groups records by each of their tags; a record with several tags is
+// counted once per tag
+const GroupByTags = "Tags"
+
+// metadataGroupPrefix groups records by a metadata value, e.g. "Metadata.team"
+const metadataGroupPrefix = "Metadata."
+
+// validateGroupBy checks that records can be grouped by the field: a string field such
+// as Category or OwnerID, Tags, or a metadata key written as "Metadata.<key>"
+func validateGroupBy(groupBy string) error {
+	if groupBy == GroupByTags {
+		return nil
+	}
+	if strings.HasPrefix(groupBy, metadataGroupPrefix) {
+		if groupBy == metadataGroupPrefix {
+			return fmt.Errorf("cannot group by %s: missing metadata key", groupBy)
+		}
+		return nil
+	}
+	if kind, ok := entryFieldKind(groupBy); !ok || kind != reflect.String {
+		return fmt.Errorf("cannot group by %s", groupBy)
+	}
+	return nil
+}
+
+// groupKeys returns the groups a record counts towards; records without a value count
+// towards the empty group
+func groupKeys(record Entry, groupBy string) []string {
+	switch {
+	case groupBy == GroupByTags:
+		return record.Tags
+	case strings.HasPrefix(groupBy, metadataGroupPrefix):
+		return []string{record.Metadata[strings.TrimPrefix(groupBy, metadataGroupPrefix)]}
+	case groupBy == "Category":
+		return []string{record.Category}
+	case groupBy == "OwnerID":
+		return []string{record.OwnerID}
+	default:
+		return []string{reflect.ValueOf(record).FieldByName(groupBy).String()}
+	}
+}
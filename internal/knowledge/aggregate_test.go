@@ -0,0 +1,75 @@
+package knowledge
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testCountAndAggregate(t *testing.T, store Store) {
+	store.AddRecords(
+		Entry{ID: "a", Category: CategoryFact, OwnerID: "andy", Tags: []string{"backend", "go"}, Metadata: map[string]string{"team": "core"}},
+		Entry{ID: "b", Category: CategoryFact, OwnerID: "bea", Tags: []string{"backend"}},
+		Entry{ID: "c", Category: CategoryDecision, OwnerID: "andy", Metadata: map[string]string{"team": "core"}},
+		Entry{ID: "d", Category: CategoryMessage, OwnerID: "bea"},
+	)
+	store.DeleteRecord("d")
+
+	facts, _ := Query().Where("Category", "=", CategoryFact).Limit(1).Build()
+	if count, err := store.CountRecords(facts); err != nil || count != 2 {
+		t.Errorf("Expected 2 facts regardless of the limit, got %d, %v", count, err)
+	}
+	all, _ := Query().IncludeDeleted().Build()
+	if count, _ := store.CountRecords(all); count != 4 {
+		t.Errorf("Expected 4 records including deleted, got %d", count)
+	}
+	if count, _ := store.CountRecords(Filter{}); count != 3 {
+		t.Errorf("Expected 3 active records, got %d", count)
+	}
+
+	tests := []struct {
+		groupBy  string
+		filter   Filter
+		expected map[string]int
+	}{
+		{"Category", Filter{}, map[string]int{CategoryFact: 2, CategoryDecision: 1}},
+		{"OwnerID", all, map[string]int{"andy": 2, "bea": 2}},
+		{GroupByTags, Filter{}, map[string]int{"backend": 2, "go": 1}},
+		{"Metadata.team", Filter{}, map[string]int{"core": 2, "": 1}},
+		{"Category", facts, map[string]int{CategoryFact: 2}},
+	}
+	for _, tt := range tests {
+		counts, err := store.Aggregate(tt.filter, tt.groupBy)
+		if err != nil {
+			t.Fatalf("Aggregate by %s failed: %v", tt.groupBy, err)
+		}
+		if !reflect.DeepEqual(counts, tt.expected) {
+			t.Errorf("Aggregate by %s: expected %v, got %v", tt.groupBy, tt.expected, counts)
+		}
+	}
+
+	for _, groupBy := range []string{"Importance", "Color", "Metadata."} {
+		if _, err := store.Aggregate(Filter{}, groupBy); err == nil {
+			t.Errorf("Expected error when grouping by %q", groupBy)
+		}
+	}
+}
+
+func TestMemoryStoreCountAndAggregate(t *testing.T) {
+	store, _ := NewMemoryStore()
+	store.Open()
+	testCountAndAggregate(t, store)
+}
+
+func TestIndexedMemoryStoreCountAndAggregate(t *testing.T) {
+	store, _ := NewIndexedMemoryStore()
+	store.Open()
+	testCountAndAggregate(t, store)
+}
+
+func TestFileStoreCountAndAggregate(t *testing.T) {
+	store, _ := NewFileStore(filepath.Join(t.TempDir(), "knowledge.json"))
+	store.Open()
+	defer store.Close()
+	testCountAndAggregate(t, store)
+}
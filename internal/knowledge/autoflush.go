@@ -0,0 +1,103 @@
+package knowledge
+
+import (
+	"sync"
+	"time"
+)
+
+// FileStoreOption configures a FileStore
+type FileStoreOption func(*FileStore)
+
+// WithAutoFlush flushes the store on a background goroutine every interval, and as soon
+// as maxDirtyRecords record changes are pending, so changes reach disk without explicit
+// Flush calls. A non-positive maxDirtyRecords flushes on the interval only. The
+// goroutine runs while the store is open; Close stops it and writes what is left.
+func WithAutoFlush(interval time.Duration, maxDirtyRecords int) FileStoreOption {
+	return func(f *FileStore) {
+		if interval <= 0 {
+			interval = 5 * time.Second
+		}
+		f.autoFlush = &autoFlusher{
+			interval:        interval,
+			maxDirtyRecords: maxDirtyRecords,
+			trigger:         make(chan struct{}, 1),
+		}
+	}
+}
+
+// autoFlusher runs the background flushes of a FileStore
+type autoFlusher struct {
+	interval        time.Duration
+	maxDirtyRecords int
+	trigger         chan struct{} // Signals that enough changes are pending
+
+	mu      sync.Mutex
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	lastErr error // Error of the last background flush, nil once one succeeds
+}
+
+// start launches the background flushes unless they are running
+func (a *autoFlusher) start(flush func() error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stopCh != nil {
+		return
+	}
+	a.stopCh = make(chan struct{})
+	a.doneCh = make(chan struct{})
+	stopCh, doneCh := a.stopCh, a.doneCh
+
+	go func() {
+		defer close(doneCh)
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+			case <-a.trigger:
+			}
+			// A failed flush leaves the store dirty, so it is retried on the next tick
+			err := flush()
+			a.mu.Lock()
+			a.lastErr = err
+			a.mu.Unlock()
+		}
+	}()
+}
+
+// stop ends the background flushes and waits for one in progress to finish
+func (a *autoFlusher) stop() {
+	a.mu.Lock()
+	stopCh, doneCh := a.stopCh, a.doneCh
+	a.stopCh, a.doneCh = nil, nil
+	a.mu.Unlock()
+
+	if stopCh != nil {
+		close(stopCh)
+		<-doneCh
+	}
+}
+
+// changed notes pending record changes and wakes the goroutine once there are enough
+// (must be called with the store lock held)
+func (a *autoFlusher) changed(dirtyRecords int) {
+	if a.maxDirtyRecords <= 0 || dirtyRecords < a.maxDirtyRecords {
+		return
+	}
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+		// A flush is already requested
+	}
+}
+
+// err returns the error of the last background flush
+func (a *autoFlusher) err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.lastErr
+}
@@ -0,0 +1,121 @@
+package knowledge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitFor polls the condition until it holds or a second passes
+func waitFor(t *testing.T, condition func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+// persistedRecords opens the file in a separate store and counts its records
+func persistedRecords(filename string) int {
+	store, _ := NewFileStore(filename)
+	if err := store.Open(); err != nil {
+		return -1
+	}
+	defer store.Close()
+	count, _ := store.CountRecords(Filter{})
+	return count
+}
+
+func TestFileStoreAutoFlushInterval(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "knowledge.json")
+	store, _ := NewFileStore(filename, WithAutoFlush(20*time.Millisecond, 0))
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	store.AddRecord(Entry{ID: "a", Content: []byte("alpha")})
+	if !waitFor(t, func() bool { return persistedRecords(filename) == 1 }) {
+		t.Error("Expected the record to be flushed in the background")
+	}
+}
+
+func TestFileStoreAutoFlushMaxDirtyRecords(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "knowledge.json")
+	store, _ := NewWALFileStore(filename, 100, WithAutoFlush(time.Hour, 3))
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	store.AddRecords(Entry{ID: "a"}, Entry{ID: "b"})
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(filename + ".wal"); err == nil {
+		t.Error("Expected no flush below the dirty record limit")
+	}
+
+	store.AddRecord(Entry{ID: "c"})
+	if !waitFor(t, func() bool { info, _ := store.Info(); return info["dirty_records"] == "0" }) {
+		t.Fatal("Expected a flush once the dirty record limit was reached")
+	}
+	reopened := openWALStore(t, filename, 100)
+	defer reopened.Close()
+	if count, _ := reopened.CountRecords(Filter{}); count != 3 {
+		t.Errorf("Expected 3 records in the log, got %d", count)
+	}
+}
+
+func TestFileStoreAutoFlushRetriesFailures(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "knowledge.json")
+	store, _ := NewFileStore(filename, WithAutoFlush(10*time.Millisecond, 0))
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	// A directory in place of the temp file makes every write fail
+	if err := os.Mkdir(filename+".tmp", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	store.AddRecord(Entry{ID: "a"})
+	if !waitFor(t, func() bool { info, _ := store.Info(); return info["auto_flush_error"] != "" }) {
+		t.Fatal("Expected the background flush error to be reported")
+	}
+
+	os.Remove(filename + ".tmp")
+	if !waitFor(t, func() bool {
+		info, _ := store.Info()
+		return info["auto_flush_error"] == "" && info["is_dirty"] == "false"
+	}) {
+		t.Error("Expected the flush to be retried and the error cleared")
+	}
+}
+
+func TestFileStoreAutoFlushClose(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "knowledge.json")
+	store, _ := NewFileStore(filename, WithAutoFlush(time.Hour, 0))
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	store.AddRecord(Entry{ID: "a"})
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if count := persistedRecords(filename); count != 1 {
+		t.Errorf("Expected Close to write pending changes, got %d records", count)
+	}
+
+	// Reopening restarts the background flushes
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+	if info, _ := store.Info(); info["auto_flush_interval"] != "1h0m0s" {
+		t.Errorf("Unexpected auto flush info: %v", info)
+	}
+}
@@ -0,0 +1,27 @@
+package knowledge
+
+import "fmt"
+
+// validateBatchIDs checks that every ID of a batch is set and appears only once
+func validateBatchIDs(ids []string) error {
+	seenIDs := make(map[string]bool, len(ids))
+	for i, id := range ids {
+		if id == "" {
+			return fmt.Errorf("record at index %d must have an ID", i)
+		}
+		if seenIDs[id] {
+			return fmt.Errorf("duplicate record ID found in input: %s", id)
+		}
+		seenIDs[id] = true
+	}
+	return nil
+}
+
+// recordIDs returns the IDs of the records in order
+func recordIDs(records []Entry) []string {
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = record.ID
+	}
+	return ids
+}
@@ -0,0 +1,112 @@
+package knowledge
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func testBatchOperations(t *testing.T, store Store) {
+	records := make([]Entry, 0, 100)
+	for i := 0; i < 100; i++ {
+		records = append(records, Entry{ID: fmt.Sprintf("r%d", i), Category: CategoryFact, Content: []byte(fmt.Sprintf("fact number %d", i))})
+	}
+	if err := store.AddRecords(records...); err != nil {
+		t.Fatalf("Failed to add records: %v", err)
+	}
+	all, _ := store.SearchRecords(Filter{})
+	if len(all) != 100 {
+		t.Fatalf("Expected 100 records, got %d", len(all))
+	}
+	if all[0].CreatedAt.IsZero() || len(all[0].Provenance) != 1 || all[0].Provenance[0].Operation != ProvenanceOpAdd {
+		t.Errorf("Expected timestamps and add provenance to be set, got %+v", all[0])
+	}
+
+	// A batch with an existing ID is rejected as a whole
+	err := store.AddRecords(Entry{ID: "new", Category: CategoryFact}, Entry{ID: "r1", Category: CategoryFact})
+	if err == nil {
+		t.Error("Expected error when adding an existing record")
+	}
+	if _, err := store.GetRecord("new"); err == nil {
+		t.Error("Expected no record of a rejected batch to be added")
+	}
+	if err := store.AddRecords(Entry{ID: "dup"}, Entry{ID: "dup"}); err == nil {
+		t.Error("Expected error for duplicate IDs in a batch")
+	}
+
+	// Updates
+	first, _ := store.GetRecord("r0")
+	second, _ := store.GetRecord("r1")
+	first.Content = []byte("updated zero")
+	second.Content = []byte("updated one")
+	if err := store.UpdateRecords(first, second); err != nil {
+		t.Fatalf("Failed to update records: %v", err)
+	}
+	if updated, _ := store.GetRecord("r1"); string(updated.Content) != "updated one" {
+		t.Errorf("Expected updated content, got %q", updated.Content)
+	}
+	if results, _ := store.FullTextSearch("updated"); len(results) != 2 {
+		t.Errorf("Expected updated records to be re-indexed, got %d results", len(results))
+	}
+
+	first.Content = []byte("should not be applied")
+	if err := store.UpdateRecords(first, Entry{ID: "missing"}); err == nil {
+		t.Error("Expected error when updating a missing record")
+	}
+	if unchanged, _ := store.GetRecord("r0"); string(unchanged.Content) != "updated zero" {
+		t.Errorf("Expected rejected batch not to be applied, got %q", unchanged.Content)
+	}
+
+	// Deletes
+	if err := store.DeleteRecords("r2", "missing"); err == nil {
+		t.Error("Expected error when deleting a missing record")
+	}
+	if _, err := store.GetRecord("r2"); err != nil {
+		t.Error("Expected rejected delete batch not to delete anything")
+	}
+	if err := store.DeleteRecords("r2", "r3", "r4"); err != nil {
+		t.Fatalf("Failed to delete records: %v", err)
+	}
+	all, _ = store.SearchRecords(Filter{})
+	if len(all) != 97 {
+		t.Errorf("Expected 97 active records, got %d", len(all))
+	}
+	if err := store.RestoreRecord("r3"); err != nil {
+		t.Errorf("Expected batch-deleted record to be restorable: %v", err)
+	}
+
+	// Empty batches are no-ops
+	if err := store.AddRecords(); err != nil {
+		t.Errorf("Expected empty batch to succeed, got %v", err)
+	}
+}
+
+func TestMemoryStoreBatchOperations(t *testing.T) {
+	store, _ := NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	testBatchOperations(t, store)
+}
+
+func TestFileStoreBatchOperations(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "knowledge.json")
+	store, _ := NewFileStore(filename)
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	testBatchOperations(t, store)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	reopened, _ := NewFileStore(filename)
+	if err := reopened.Open(); err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	all, _ := reopened.SearchRecords(Filter{})
+	if len(all) != 98 {
+		t.Errorf("Expected 98 records after reopening, got %d", len(all))
+	}
+}
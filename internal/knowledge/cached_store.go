@@ -0,0 +1,224 @@
+package knowledge
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Cache defaults
+const (
+	DefaultCacheSize = 1000
+	DefaultCacheTTL  = 5 * time.Minute
+)
+
+// CacheStats are the counters of a CachedStore
+type CacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"` // Entries dropped to make room, not counting expiry or invalidation
+	Size      int   `json:"size"`      // Entries currently cached
+}
+
+// cachedRecord is an entry of the LRU list
+type cachedRecord struct {
+	id        string
+	record    Entry
+	expiresAt time.Time
+}
+
+// CachedStore wraps a store with an LRU cache of GetRecord results. Writes through the
+// CachedStore invalidate the records they touch; writes made directly to the wrapped
+// store are picked up once the cached copy expires.
+type CachedStore struct {
+	Store
+	size    int
+	ttl     time.Duration
+	items   map[string]*list.Element
+	lru     *list.List // Front is the most recently used
+	version uint64     // Incremented by every invalidation, so a racing read does not cache a stale record
+	stats   CacheStats
+	mu      sync.Mutex
+}
+
+// NewCachedStore wraps the store with a cache of up to size records, each kept for at
+// most ttl. Non-positive values select DefaultCacheSize and DefaultCacheTTL.
+func NewCachedStore(store Store, size int, ttl time.Duration) *CachedStore {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &CachedStore{
+		Store: store,
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// GetRecord returns the cached record if present, otherwise reads it from the wrapped store
+func (c *CachedStore) GetRecord(id string) (Entry, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	if element, ok := c.items[id]; ok {
+		cached := element.Value.(*cachedRecord)
+		if now.Before(cached.expiresAt) {
+			c.lru.MoveToFront(element)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return cached.record, nil
+		}
+		c.removeElement(element)
+	}
+	c.stats.Misses++
+	version := c.version
+	c.mu.Unlock()
+
+	record, err := c.Store.GetRecord(id)
+	if err != nil {
+		return record, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version == version {
+		c.put(id, record, now.Add(c.ttl))
+	}
+	return record, nil
+}
+
+// Stats returns the cache counters
+func (c *CachedStore) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
+}
+
+// UpdateRecord updates the record in the wrapped store and drops it from the cache
+func (c *CachedStore) UpdateRecord(record Entry) error {
+	defer c.invalidate(record.ID)
+	return c.Store.UpdateRecord(record)
+}
+
+// UpdateRecords updates the records in the wrapped store and drops them from the cache
+func (c *CachedStore) UpdateRecords(records ...Entry) error {
+	defer c.invalidate(recordIDs(records)...)
+	return c.Store.UpdateRecords(records...)
+}
+
+// DeleteRecord soft-deletes the record in the wrapped store and drops it from the cache
+func (c *CachedStore) DeleteRecord(id string) error {
+	defer c.invalidate(id)
+	return c.Store.DeleteRecord(id)
+}
+
+// DeleteRecords soft-deletes the records in the wrapped store and drops them from the cache
+func (c *CachedStore) DeleteRecords(ids ...string) error {
+	defer c.invalidate(ids...)
+	return c.Store.DeleteRecords(ids...)
+}
+
+// RestoreRecord restores the record in the wrapped store and drops it from the cache
+func (c *CachedStore) RestoreRecord(id string) error {
+	defer c.invalidate(id)
+	return c.Store.RestoreRecord(id)
+}
+
+// PurgeRecord removes the record from the wrapped store and the cache
+func (c *CachedStore) PurgeRecord(id string) error {
+	defer c.invalidate(id)
+	return c.Store.PurgeRecord(id)
+}
+
+// RecordAccess records the accesses in the wrapped store and drops the records from the
+// cache, as their access statistics changed
+func (c *CachedStore) RecordAccess(accesses ...Access) error {
+	ids := make([]string, 0, len(accesses))
+	for _, access := range accesses {
+		ids = append(ids, access.ID)
+	}
+	defer c.invalidate(ids...)
+	return c.Store.RecordAccess(accesses...)
+}
+
+// LoadRecords loads the records into the wrapped store and drops them from the cache
+func (c *CachedStore) LoadRecords(records ...Entry) error {
+	defer c.invalidate(recordIDs(records)...)
+	return c.Store.LoadRecords(records...)
+}
+
+// Open opens the wrapped store with an empty cache
+func (c *CachedStore) Open() error {
+	c.clear()
+	return c.Store.Open()
+}
+
+// Close closes the wrapped store and empties the cache
+func (c *CachedStore) Close() error {
+	c.clear()
+	return c.Store.Close()
+}
+
+// Info returns the wrapped store's information with the cache counters added
+func (c *CachedStore) Info() (map[string]string, error) {
+	info, err := c.Store.Info()
+	if err != nil {
+		return nil, err
+	}
+	stats := c.Stats()
+	info["cache_size"] = fmt.Sprintf("%d", stats.Size)
+	info["cache_capacity"] = fmt.Sprintf("%d", c.size)
+	info["cache_hits"] = fmt.Sprintf("%d", stats.Hits)
+	info["cache_misses"] = fmt.Sprintf("%d", stats.Misses)
+	info["cache_evictions"] = fmt.Sprintf("%d", stats.Evictions)
+	return info, nil
+}
+
+// invalidate drops the records from the cache
+func (c *CachedStore) invalidate(ids ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	for _, id := range ids {
+		if element, ok := c.items[id]; ok {
+			c.removeElement(element)
+		}
+	}
+}
+
+// clear empties the cache
+func (c *CachedStore) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	c.items = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+// put caches a record, evicting the least recently used one if the cache is full
+// (must be called with lock held)
+func (c *CachedStore) put(id string, record Entry, expiresAt time.Time) {
+	if element, ok := c.items[id]; ok {
+		element.Value = &cachedRecord{id: id, record: record, expiresAt: expiresAt}
+		c.lru.MoveToFront(element)
+		return
+	}
+	for c.lru.Len() >= c.size {
+		c.removeElement(c.lru.Back())
+		c.stats.Evictions++
+	}
+	c.items[id] = c.lru.PushFront(&cachedRecord{id: id, record: record, expiresAt: expiresAt})
+}
+
+// removeElement drops an element from the cache (must be called with lock held)
+func (c *CachedStore) removeElement(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.items, element.Value.(*cachedRecord).id)
+}
@@ -0,0 +1,104 @@
+package knowledge
+
+import (
+	"testing"
+	"time"
+)
+
+// countingStore counts the GetRecord calls reaching the wrapped store
+type countingStore struct {
+	Store
+	gets int
+}
+
+func (c *countingStore) GetRecord(id string) (Entry, error) {
+	c.gets++
+	return c.Store.GetRecord(id)
+}
+
+func newCachedTestStore(t *testing.T, size int, ttl time.Duration) (*CachedStore, *countingStore) {
+	t.Helper()
+	memory, _ := NewMemoryStore()
+	backend := &countingStore{Store: memory}
+	cached := NewCachedStore(backend, size, ttl)
+	if err := cached.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	cached.AddRecords(
+		Entry{ID: "a", Content: []byte("alpha")},
+		Entry{ID: "b", Content: []byte("beta")},
+		Entry{ID: "c", Content: []byte("gamma")},
+	)
+	return cached, backend
+}
+
+func TestCachedStoreHitsAndInvalidation(t *testing.T) {
+	store, backend := newCachedTestStore(t, 10, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if record, err := store.GetRecord("a"); err != nil || string(record.Content) != "alpha" {
+			t.Fatalf("Unexpected record: %+v, %v", record, err)
+		}
+	}
+	if backend.gets != 1 {
+		t.Errorf("Expected 1 read of the wrapped store, got %d", backend.gets)
+	}
+
+	// Writes through the cache invalidate
+	record, _ := store.GetRecord("a")
+	record.Content = []byte("alpha two")
+	store.UpdateRecord(record)
+	if record, _ := store.GetRecord("a"); string(record.Content) != "alpha two" {
+		t.Errorf("Expected the updated record, got %q", record.Content)
+	}
+
+	store.DeleteRecord("a")
+	if _, err := store.GetRecord("a"); err == nil {
+		t.Error("Expected a deleted record not to be served from the cache")
+	}
+	store.RestoreRecord("a")
+	store.GetRecord("a")
+	store.RecordAccess(Access{ID: "a", Count: 2, At: time.Now()})
+	if record, _ := store.GetRecord("a"); record.AccessCount != 2 {
+		t.Errorf("Expected fresh access statistics, got %d", record.AccessCount)
+	}
+	store.PurgeRecord("a")
+	if _, err := store.GetRecord("a"); err == nil {
+		t.Error("Expected a purged record not to be served from the cache")
+	}
+
+	stats := store.Stats()
+	if stats.Hits != 3 || stats.Misses != 6 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	info, _ := store.Info()
+	if info["cache_hits"] != "3" || info["implementation"] != "MemoryStore" {
+		t.Errorf("Unexpected info: %v", info)
+	}
+}
+
+func TestCachedStoreEvictionAndExpiry(t *testing.T) {
+	store, backend := newCachedTestStore(t, 2, time.Minute)
+
+	store.GetRecord("a")
+	store.GetRecord("b")
+	store.GetRecord("a") // a is now the most recently used
+	store.GetRecord("c") // evicts b
+	backend.gets = 0
+	store.GetRecord("a")
+	store.GetRecord("b")
+	if backend.gets != 1 {
+		t.Errorf("Expected only the evicted record to be read again, got %d reads", backend.gets)
+	}
+	if stats := store.Stats(); stats.Evictions != 2 || stats.Size != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	expiring, backend := newCachedTestStore(t, 10, 10*time.Millisecond)
+	expiring.GetRecord("a")
+	time.Sleep(20 * time.Millisecond)
+	expiring.GetRecord("a")
+	if backend.gets != 2 {
+		t.Errorf("Expected an expired record to be read again, got %d reads", backend.gets)
+	}
+}
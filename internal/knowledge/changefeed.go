@@ -0,0 +1,133 @@
+package knowledge
+
+import (
+	"sync"
+	"time"
+)
+
+// ChangeType describes what happened to a record
+type ChangeType string
+
+// ChangeType constants
+const (
+	ChangeAdd     ChangeType = "add"     // Record was added
+	ChangeUpdate  ChangeType = "update"  // Record was updated
+	ChangeDelete  ChangeType = "delete"  // Record was soft-deleted
+	ChangeRestore ChangeType = "restore" // Soft-deleted record was restored
+	ChangePurge   ChangeType = "purge"   // Record was permanently removed
+)
+
+// WatchBufferSize is the number of events buffered for each watcher
+const WatchBufferSize = 64
+
+// ChangeEvent is a single change delivered to watchers
+type ChangeEvent struct {
+	Type      ChangeType `json:"type"`
+	Entry     Entry      `json:"entry"`            // Record after the change; the removed record for purges
+	Timestamp time.Time  `json:"timestamp"`        // When the change happened
+	Diff      string     `json:"diff,omitempty"`   // Word diff of the content for updates that changed it, see ContentDiff
+	Missed    int        `json:"missed,omitempty"` // Events dropped before this one because the watcher fell behind
+}
+
+// CancelFunc stops a watch and closes its channel
+type CancelFunc func()
+
+// watcher is a single subscription to a change feed
+type watcher struct {
+	filter FilterGroup
+	events chan ChangeEvent
+	missed int // Dropped events not yet reported
+}
+
+// changeFeed fans out record changes to watchers. Publishing never blocks: when a
+// watcher's buffer is full the oldest buffered event is dropped and the number of
+// dropped events is reported with the next delivered one, so slow consumers know to
+// re-read the store instead of stalling writers.
+type changeFeed struct {
+	mu       sync.Mutex
+	nextID   int
+	watchers map[int]*watcher
+}
+
+// newChangeFeed creates an empty change feed
+func newChangeFeed() *changeFeed {
+	return &changeFeed{watchers: make(map[int]*watcher)}
+}
+
+// watch registers a watcher for records matching the filter's root group
+func (c *changeFeed) watch(filter Filter) (<-chan ChangeEvent, CancelFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.nextID
+	c.nextID++
+	w := &watcher{filter: filter.RootGroup, events: make(chan ChangeEvent, WatchBufferSize)}
+	c.watchers[id] = w
+
+	var once sync.Once
+	return w.events, func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if _, ok := c.watchers[id]; ok {
+				delete(c.watchers, id)
+				close(w.events)
+			}
+		})
+	}
+}
+
+// publish delivers a change to every watcher whose filter matches the record
+func (c *changeFeed) publish(changeType ChangeType, record Entry, matches func(Entry, FilterGroup) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.watchers) == 0 {
+		return
+	}
+
+	now := time.Now()
+	diff := ""
+	if changeType == ChangeUpdate {
+		diff = contentDiffOf(record)
+	}
+	for _, w := range c.watchers {
+		if !matches(record, w.filter) {
+			continue
+		}
+		w.send(ChangeEvent{Type: changeType, Entry: record, Timestamp: now, Diff: diff})
+	}
+}
+
+// closeAll closes the channels of all watchers, e.g. when the store is closed
+func (c *changeFeed) closeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, w := range c.watchers {
+		delete(c.watchers, id)
+		close(w.events)
+	}
+}
+
+// send delivers an event without blocking, dropping the oldest buffered event if needed
+// (must be called with the feed's mutex held)
+func (w *watcher) send(event ChangeEvent) {
+	for {
+		event.Missed = w.missed
+		select {
+		case w.events <- event:
+			w.missed = 0
+			return
+		default:
+		}
+
+		// Buffer full: make room by dropping the oldest event
+		select {
+		case dropped := <-w.events:
+			w.missed += 1 + dropped.Missed
+		default:
+			// The consumer drained the buffer in the meantime
+		}
+	}
+}
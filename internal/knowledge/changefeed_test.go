@@ -0,0 +1,134 @@
+package knowledge
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nextEvent reads one event or fails the test after a timeout
+func nextEvent(t *testing.T, events <-chan ChangeEvent) ChangeEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("Watch channel closed unexpectedly")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for change event")
+	}
+	return ChangeEvent{}
+}
+
+func testWatch(t *testing.T, store Store) {
+	facts, cancel := store.Watch(Filter{RootGroup: FilterGroup{
+		Operator:   OpAnd,
+		Conditions: []Condition{{Field: "Category", Operator: "=", Value: CategoryFact}},
+	}})
+	defer cancel()
+	all, cancelAll := store.Watch(Filter{})
+
+	store.AddRecord(Entry{ID: "fact", Category: CategoryFact, Content: []byte("Go backend")})
+	store.AddRecord(Entry{ID: "msg", Category: CategoryMessage, Content: []byte("Hello")})
+	record, _ := store.GetRecord("fact")
+	record.Content = []byte("Go and Rust backend")
+	store.UpdateRecord(record)
+	store.DeleteRecord("fact")
+	store.RestoreRecord("fact")
+	store.PurgeRecord("fact")
+
+	expected := []ChangeType{ChangeAdd, ChangeUpdate, ChangeDelete, ChangeRestore, ChangePurge}
+	for _, changeType := range expected {
+		event := nextEvent(t, facts)
+		if event.Type != changeType || event.Entry.ID != "fact" {
+			t.Errorf("Expected %s of fact, got %s of %s", changeType, event.Type, event.Entry.ID)
+		}
+	}
+	select {
+	case event := <-facts:
+		t.Errorf("Expected no event for other categories, got %s of %s", event.Type, event.Entry.ID)
+	default:
+	}
+
+	if event := nextEvent(t, all); event.Entry.ID != "fact" {
+		t.Errorf("Expected first event for fact, got %s", event.Entry.ID)
+	}
+	if event := nextEvent(t, all); event.Type != ChangeAdd || event.Entry.ID != "msg" {
+		t.Errorf("Expected add of msg, got %s of %s", event.Type, event.Entry.ID)
+	}
+
+	// Cancelling closes the channel and stops delivery
+	cancelAll()
+	cancelAll()
+	store.LoadRecords(Entry{ID: "loaded", Category: CategoryFact})
+	for range all {
+	}
+	if event := nextEvent(t, facts); event.Type != ChangeAdd || event.Entry.ID != "loaded" {
+		t.Errorf("Expected add of loaded, got %s of %s", event.Type, event.Entry.ID)
+	}
+}
+
+func TestMemoryStoreWatch(t *testing.T) {
+	store, _ := NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	testWatch(t, store)
+}
+
+func TestFileStoreWatch(t *testing.T) {
+	store, _ := NewFileStore(filepath.Join(t.TempDir(), "knowledge.json"))
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	testWatch(t, store)
+}
+
+func TestWatchBackpressure(t *testing.T) {
+	store, _ := NewMemoryStore()
+	store.Open()
+	events, cancel := store.Watch(Filter{})
+	defer cancel()
+
+	// Writers are never blocked by a consumer that does not read
+	const total = WatchBufferSize * 3
+	for i := 0; i < total; i++ {
+		if err := store.AddRecord(Entry{ID: fmt.Sprintf("r%d", i), Category: CategoryFact}); err != nil {
+			t.Fatalf("Failed to add record: %v", err)
+		}
+	}
+
+	received, missed := 0, 0
+	var last ChangeEvent
+	for received < WatchBufferSize {
+		last = nextEvent(t, events)
+		received++
+		missed += last.Missed
+	}
+	if received+missed != total {
+		t.Errorf("Expected received plus missed events to be %d, got %d + %d", total, received, missed)
+	}
+	if last.Entry.ID != fmt.Sprintf("r%d", total-1) {
+		t.Errorf("Expected the newest event to be kept, got %s", last.Entry.ID)
+	}
+}
+
+func TestWatchEndsOnClose(t *testing.T) {
+	store, _ := NewMemoryStore()
+	store.Open()
+	events, cancel := store.Watch(Filter{})
+	store.Close()
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("Expected channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for channel to close")
+	}
+	cancel() // Safe after the store closed the watch
+}
@@ -0,0 +1,141 @@
+package knowledge
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+// DetailContentDiff is the provenance detail holding the content diff of an update
+const DetailContentDiff = "contentDiff"
+
+// maxDiffCells bounds the work of a word diff; larger changes are shown as a replacement
+const maxDiffCells = 1_000_000
+
+// ContentDiff returns a compact word diff of two texts in the style of git's word diff:
+// unchanged text is kept, removed words are written as [-old-] and added ones as {+new+}.
+// An empty string means the texts are equal.
+func ContentDiff(before, after string) string {
+	if before == after {
+		return ""
+	}
+	a, b := diffTokens(before), diffTokens(after)
+
+	// Most edits to a fact touch a few words; skip the common prefix and suffix
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.Join(a[:prefix], ""))
+	writeWordDiff(&sb, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	sb.WriteString(strings.Join(a[len(a)-suffix:], ""))
+	return sb.String()
+}
+
+// writeWordDiff writes the diff of the changed middle parts of two texts
+func writeWordDiff(sb *strings.Builder, a, b []string) {
+	if len(a)*len(b) > maxDiffCells {
+		writeChange(sb, a, b)
+		return
+	}
+
+	// Longest common subsequence table, filled from the end
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var removed, added []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			writeChange(sb, removed, added)
+			removed, added = nil, nil
+			sb.WriteString(a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			added = append(added, b[j])
+			j++
+		default:
+			removed = append(removed, a[i])
+			i++
+		}
+	}
+	writeChange(sb, removed, added)
+}
+
+// writeChange writes a run of removed and added tokens
+func writeChange(sb *strings.Builder, removed, added []string) {
+	if len(removed) > 0 {
+		sb.WriteString("[-" + strings.Join(removed, "") + "-]")
+	}
+	if len(added) > 0 {
+		sb.WriteString("{+" + strings.Join(added, "") + "+}")
+	}
+}
+
+// diffTokens splits text into words and the whitespace between them, so joining the
+// tokens gives back the text
+func diffTokens(text string) []string {
+	tokens := make([]string, 0)
+	start, inSpace := 0, false
+	for i, r := range text {
+		space := unicode.IsSpace(r)
+		if i > 0 && space != inSpace {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+		inSpace = space
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+// withContentDiff adds the content diff of an update to the record's latest provenance
+// step. Binary content and unchanged content get no diff.
+func withContentDiff(existing, record Entry) Entry {
+	if bytes.Equal(existing.Content, record.Content) || len(record.Provenance) == 0 ||
+		existing.ContentType == ContentTypeBinary || record.ContentType == ContentTypeBinary {
+		return record
+	}
+
+	last := &record.Provenance[len(record.Provenance)-1]
+	details := make(map[string]string, len(last.Details)+1)
+	for key, value := range last.Details {
+		details[key] = value
+	}
+	details[DetailContentDiff] = ContentDiff(string(existing.Content), string(record.Content))
+	last.Details = details
+	return record
+}
+
+// contentDiffOf returns the content diff recorded by the record's latest update, if any
+func contentDiffOf(record Entry) string {
+	if len(record.Provenance) == 0 {
+		return ""
+	}
+	last := record.Provenance[len(record.Provenance)-1]
+	if last.Operation != ProvenanceOpUpdate && last.Operation != ProvenanceOpLoad {
+		return ""
+	}
+	return last.Details[DetailContentDiff]
+}
@@ -0,0 +1,93 @@
+package knowledge
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContentDiff(t *testing.T) {
+	tests := []struct {
+		before, after, expected string
+	}{
+		{"same", "same", ""},
+		{"Ship on Friday", "Ship on Tuesday", "Ship on [-Friday-]{+Tuesday+}"},
+		{"We use Go", "We use Go and Rust", "We use Go{+ and Rust+}"},
+		{"The old API is deprecated", "The API is deprecated", "The [-old -]API is deprecated"},
+		{"a b c d", "a x c y", "a [-b-]{+x+} c [-d-]{+y+}"},
+		{"", "new fact", "{+new fact+}"},
+		{"line one\nline two", "line one\nline 2", "line one\nline [-two-]{+2+}"},
+		{"café ouvert", "café fermé", "café [-ouvert-]{+fermé+}"},
+	}
+	for _, tt := range tests {
+		if got := ContentDiff(tt.before, tt.after); got != tt.expected {
+			t.Errorf("ContentDiff(%q, %q) = %q, expected %q", tt.before, tt.after, got, tt.expected)
+		}
+	}
+}
+
+func testContentDiffOnUpdate(t *testing.T, store Store) {
+	store.AddRecord(Entry{ID: "release", Category: CategoryFact, Content: []byte("Releases happen on Friday")})
+
+	events, cancel := store.Watch(Filter{RootGroup: FilterGroup{Operator: OpAnd}})
+	defer cancel()
+
+	record, _ := store.GetRecord("release")
+	record.Content = []byte("Releases happen on Tuesday")
+	if err := store.UpdateRecord(record); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+
+	expected := "Releases happen on [-Friday-]{+Tuesday+}"
+	updated, _ := store.GetRecord("release")
+	last := updated.Provenance[len(updated.Provenance)-1]
+	if last.Details[DetailContentDiff] != expected {
+		t.Errorf("Expected diff %q in history, got %v", expected, last.Details)
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != ChangeUpdate || event.Diff != expected {
+			t.Errorf("Expected update event with diff, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for change event")
+	}
+
+	// Updates that leave the content alone carry no diff
+	updated.Tags = []string{"schedule"}
+	store.UpdateRecords(updated)
+	select {
+	case event := <-events:
+		if event.Diff != "" {
+			t.Errorf("Expected no diff for a tag change, got %q", event.Diff)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for change event")
+	}
+
+	// Bulk loads of existing records are diffed too, new ones are not
+	store.LoadRecords(Entry{ID: "release", Content: []byte("Releases happen on Monday")}, Entry{ID: "new", Content: []byte("New")})
+	loaded, _ := store.GetRecord("release")
+	if diff := loaded.Provenance[len(loaded.Provenance)-1].Details[DetailContentDiff]; !strings.Contains(diff, "{+Monday+}") {
+		t.Errorf("Expected load diff, got %q", diff)
+	}
+	fresh, _ := store.GetRecord("new")
+	if _, ok := fresh.Provenance[0].Details[DetailContentDiff]; ok {
+		t.Error("Expected no diff for a new record")
+	}
+}
+
+func TestMemoryStoreContentDiff(t *testing.T) {
+	store, _ := NewMemoryStore()
+	store.Open()
+	testContentDiffOnUpdate(t, store)
+}
+
+func TestFileStoreContentDiff(t *testing.T) {
+	store, _ := NewFileStore(filepath.Join(t.TempDir(), "knowledge.json"))
+	store.Open()
+	defer store.Close()
+	testContentDiffOnUpdate(t, store)
+}
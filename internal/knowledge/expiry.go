@@ -0,0 +1,213 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"goproduct/internal/tracing"
+)
+
+// ExpiryMode defines what happens to records once their ExpiresAt has passed
+type ExpiryMode string
+
+// ExpiryMode constants
+const (
+	ExpirySoftDelete ExpiryMode = "delete" // Soft-delete expired records so they can still be restored
+	ExpiryPurge      ExpiryMode = "purge"  // Permanently remove expired records, including soft-deleted ones
+)
+
+// ExpiryJanitor periodically removes expired records from a store. It only uses the
+// Store interface, so it works with every store implementation.
+type ExpiryJanitor struct {
+	store    Store
+	interval time.Duration
+	mode     ExpiryMode
+	tracer   tracing.Tracer
+	now      func() time.Time // Replaceable for tests
+
+	runMu  sync.Mutex // Serializes sweeps
+	mu     sync.Mutex
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewExpiryJanitor creates a janitor that sweeps the store every interval
+func NewExpiryJanitor(store Store, interval time.Duration, mode ExpiryMode) *ExpiryJanitor {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if mode != ExpiryPurge {
+		mode = ExpirySoftDelete
+	}
+	return &ExpiryJanitor{
+		store:    store,
+		interval: interval,
+		mode:     mode,
+		tracer:   tracing.NewNoopTracer(),
+		now:      time.Now,
+	}
+}
+
+// SetTracer sets the tracer receiving an event for each expired record
+func (j *ExpiryJanitor) SetTracer(tracer tracing.Tracer) {
+	if tracer == nil {
+		tracer = tracing.NewNoopTracer()
+	}
+	j.tracer = tracer
+}
+
+// Start runs sweeps on the janitor's interval until the context is done or Stop is called
+func (j *ExpiryJanitor) Start(ctx context.Context) {
+	j.mu.Lock()
+	if j.stopCh != nil {
+		j.mu.Unlock()
+		return
+	}
+	j.stopCh = make(chan struct{})
+	j.doneCh = make(chan struct{})
+	stopCh, doneCh := j.stopCh, j.doneCh
+	j.mu.Unlock()
+
+	go func() {
+		defer close(doneCh)
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				// Failures are traced by ExpireNow and retried on the next tick
+				j.ExpireNow()
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduled sweeps and waits for an in-progress sweep to finish
+func (j *ExpiryJanitor) Stop() {
+	j.mu.Lock()
+	stopCh, doneCh := j.stopCh, j.doneCh
+	j.stopCh, j.doneCh = nil, nil
+	j.mu.Unlock()
+
+	if stopCh != nil {
+		close(stopCh)
+		<-doneCh
+	}
+}
+
+// ExpireNow removes all records whose ExpiresAt has passed and returns their IDs.
+// Records without an expiry time never expire. Purges go through PurgeWithCitations,
+// so citations to purged records are reconciled and records that approved decisions
+// depend on are kept.
+func (j *ExpiryJanitor) ExpireNow() ([]string, error) {
+	j.runMu.Lock()
+	defer j.runMu.Unlock()
+
+	now := j.now()
+	candidates, err := j.store.SearchRecords(Filter{IncludeDeleted: j.mode == ExpiryPurge})
+	if err != nil {
+		j.traceError("Failed to search for expired records", err)
+		return nil, fmt.Errorf("failed to search for expired records: %w", err)
+	}
+
+	due := make(map[string]Entry)
+	ids := make([]string, 0)
+	for _, record := range candidates {
+		if record.ExpiresAt.IsZero() || record.ExpiresAt.After(now) {
+			continue
+		}
+		due[record.ID] = record
+		ids = append(ids, record.ID)
+	}
+
+	var expired []string
+	if j.mode == ExpiryPurge {
+		expired, err = j.purge(ids, now)
+	} else {
+		expired, err = j.softDelete(ids)
+	}
+
+	for _, id := range expired {
+		record := due[id]
+		j.tracer.Trace(tracing.Event{
+			Timestamp: now,
+			Component: tracing.ComponentMemory,
+			Operation: tracing.OperationExpire,
+			Level:     tracing.LevelInfo,
+			ObjectID:  record.ID,
+			Message:   "Knowledge record expired",
+			Metadata: map[string]interface{}{
+				"mode":      string(j.mode),
+				"category":  record.Category,
+				"expiresAt": record.ExpiresAt,
+			},
+		})
+	}
+	if err != nil {
+		return expired, err
+	}
+
+	// Persist the sweep right away for stores that buffer writes
+	if len(expired) > 0 {
+		if err := j.store.Flush(); err != nil {
+			j.traceError("Failed to flush store after expiry", err)
+			return expired, fmt.Errorf("failed to flush store after expiry: %w", err)
+		}
+	}
+
+	return expired, nil
+}
+
+// softDelete soft-deletes the records and returns the IDs of those deleted
+func (j *ExpiryJanitor) softDelete(ids []string) ([]string, error) {
+	expired := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if err := j.store.DeleteRecord(id); err != nil {
+			j.traceError(fmt.Sprintf("Failed to expire record %s", id), err)
+			return expired, fmt.Errorf("failed to expire record %s: %w", id, err)
+		}
+		expired = append(expired, id)
+	}
+	return expired, nil
+}
+
+// purge permanently removes the records and returns the IDs of those purged
+func (j *ExpiryJanitor) purge(ids []string, now time.Time) ([]string, error) {
+	result, err := PurgeWithCitations(j.store, ids, false)
+	if errors.Is(err, ErrOrphanedDecision) {
+		// Expected while decisions cite the records; they are retried on every sweep
+		j.tracer.Trace(tracing.Event{
+			Timestamp: now,
+			Component: tracing.ComponentMemory,
+			Operation: tracing.OperationExpire,
+			Level:     tracing.LevelWarning,
+			Message:   fmt.Sprintf("Kept %d expired records cited by approved decisions", len(result.Blocked)),
+			Metadata:  map[string]interface{}{"blocked": result.Blocked},
+		})
+		return result.Purged, nil
+	}
+	if err != nil {
+		j.traceError("Failed to purge expired records", err)
+		return result.Purged, fmt.Errorf("failed to purge expired records: %w", err)
+	}
+	return result.Purged, nil
+}
+
+// traceError records a failed sweep
+func (j *ExpiryJanitor) traceError(message string, err error) {
+	j.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMemory,
+		Operation: tracing.OperationExpire,
+		Level:     tracing.LevelError,
+		Message:   fmt.Sprintf("%s: %v", message, err),
+	})
+}
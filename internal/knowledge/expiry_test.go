@@ -0,0 +1,136 @@
+package knowledge
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"goproduct/internal/tracing"
+)
+
+// recordingTracer collects trace events for assertions
+type recordingTracer struct {
+	mu     sync.Mutex
+	events []tracing.Event
+}
+
+func (r *recordingTracer) Trace(event tracing.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordingTracer) Flush() error                 { return nil }
+func (r *recordingTracer) Close() error                 { return nil }
+func (r *recordingTracer) SetLevel(level tracing.Level) {}
+
+func newExpiryTestStore(t *testing.T, now time.Time) *MemoryStore {
+	store, _ := NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	err := store.LoadRecords(
+		Entry{ID: "expired", Category: CategoryFact, ExpiresAt: now.Add(-time.Minute)},
+		Entry{ID: "expires-now", Category: CategoryFact, ExpiresAt: now},
+		Entry{ID: "future", Category: CategoryFact, ExpiresAt: now.Add(time.Hour)},
+		Entry{ID: "forever", Category: CategoryFact},
+		Entry{ID: "deleted-expired", Category: CategoryFact, ExpiresAt: now.Add(-time.Hour)},
+	)
+	if err != nil {
+		t.Fatalf("Failed to load records: %v", err)
+	}
+	store.DeleteRecord("deleted-expired")
+	return store
+}
+
+func TestExpiryJanitorSoftDelete(t *testing.T) {
+	now := time.Now()
+	store := newExpiryTestStore(t, now)
+	tracer := &recordingTracer{}
+
+	janitor := NewExpiryJanitor(store, time.Minute, ExpirySoftDelete)
+	janitor.SetTracer(tracer)
+	janitor.now = func() time.Time { return now }
+
+	expired, err := janitor.ExpireNow()
+	if err != nil {
+		t.Fatalf("ExpireNow failed: %v", err)
+	}
+	sort.Strings(expired)
+	if len(expired) != 2 || expired[0] != "expired" || expired[1] != "expires-now" {
+		t.Errorf("Expected [expired expires-now], got %v", expired)
+	}
+
+	// Expired records are soft-deleted and can be restored
+	if _, err := store.GetRecord("expired"); err == nil {
+		t.Error("Expected expired record to be deleted")
+	}
+	if err := store.RestoreRecord("expired"); err != nil {
+		t.Errorf("Expected soft-deleted record to be restorable: %v", err)
+	}
+	for _, id := range []string{"future", "forever"} {
+		if _, err := store.GetRecord(id); err != nil {
+			t.Errorf("Expected %s to be kept: %v", id, err)
+		}
+	}
+
+	if len(tracer.events) != 2 {
+		t.Fatalf("Expected 2 trace events, got %d", len(tracer.events))
+	}
+	for _, event := range tracer.events {
+		if event.Operation != tracing.OperationExpire || event.Component != tracing.ComponentMemory {
+			t.Errorf("Unexpected trace event: %+v", event)
+		}
+	}
+}
+
+func TestExpiryJanitorPurge(t *testing.T) {
+	now := time.Now()
+	store := newExpiryTestStore(t, now)
+
+	janitor := NewExpiryJanitor(store, time.Minute, ExpiryPurge)
+	janitor.now = func() time.Time { return now }
+
+	expired, err := janitor.ExpireNow()
+	if err != nil {
+		t.Fatalf("ExpireNow failed: %v", err)
+	}
+	if len(expired) != 3 {
+		t.Errorf("Expected 3 purged records including the soft-deleted one, got %v", expired)
+	}
+
+	deleted, _ := store.SearchRecords(Filter{OnlyDeleted: true})
+	if len(deleted) != 0 {
+		t.Errorf("Expected no deleted records left, got %d", len(deleted))
+	}
+	if err := store.RestoreRecord("expired"); err == nil {
+		t.Error("Expected purged record not to be restorable")
+	}
+
+	// A second sweep has nothing left to do
+	expired, _ = janitor.ExpireNow()
+	if len(expired) != 0 {
+		t.Errorf("Expected nothing to expire, got %v", expired)
+	}
+}
+
+func TestExpiryJanitorSchedule(t *testing.T) {
+	store, _ := NewMemoryStore()
+	store.Open()
+	store.AddRecord(Entry{ID: "short-lived", Category: CategoryFact, ExpiresAt: time.Now().Add(20 * time.Millisecond)})
+
+	janitor := NewExpiryJanitor(store, 10*time.Millisecond, ExpirySoftDelete)
+	janitor.Start(t.Context())
+	defer janitor.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := store.GetRecord("short-lived"); err != nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Expected the janitor to expire the record")
+}
@@ -0,0 +1,310 @@
+package knowledge
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Fields a MemoryStore can keep secondary indexes on
+const (
+	IndexCategory  = "Category"  // Equality conditions
+	IndexOwnerID   = "OwnerID"   // Equality conditions
+	IndexTags      = "Tags"      // CONTAINS conditions
+	IndexCreatedAt = "CreatedAt" // Equality and range conditions
+)
+
+// IndexableFields lists every field that can be indexed
+var IndexableFields = []string{IndexCategory, IndexOwnerID, IndexTags, IndexCreatedAt}
+
+// idSet is a set of record IDs
+type idSet map[string]struct{}
+
+// indexedValues are the indexed field values of one record, kept for removal
+type indexedValues struct {
+	category  string
+	ownerID   string
+	tags      []string
+	createdAt time.Time
+}
+
+// timeKey is one record in the CreatedAt index
+type timeKey struct {
+	at time.Time
+	id string
+}
+
+// fieldIndex holds secondary indexes over active and deleted records, used to narrow
+// SearchRecords to candidate records before the filter is evaluated on them. It is not
+// safe for concurrent use; stores guard it with their own lock.
+type fieldIndex struct {
+	fields     map[string]bool
+	categories map[string]idSet
+	owners     map[string]idSet
+	tags       map[string]idSet
+	created    []timeKey // Sorted by time, then ID
+	values     map[string]indexedValues
+}
+
+// newFieldIndex creates an empty index on the given fields, all indexable fields if none
+func newFieldIndex(fields ...string) (*fieldIndex, error) {
+	if len(fields) == 0 {
+		fields = IndexableFields
+	}
+	idx := &fieldIndex{
+		fields:     make(map[string]bool),
+		categories: make(map[string]idSet),
+		owners:     make(map[string]idSet),
+		tags:       make(map[string]idSet),
+		values:     make(map[string]indexedValues),
+	}
+	for _, field := range fields {
+		switch field {
+		case IndexCategory, IndexOwnerID, IndexTags, IndexCreatedAt:
+			idx.fields[field] = true
+		default:
+			return nil, fmt.Errorf("field %s cannot be indexed", field)
+		}
+	}
+	return idx, nil
+}
+
+// rebuild returns an index on the same fields over the given records. A nil index stays nil.
+func (idx *fieldIndex) rebuild(records, deleted map[string]Entry) *fieldIndex {
+	if idx == nil {
+		return nil
+	}
+	fields := make([]string, 0, len(idx.fields))
+	for field := range idx.fields {
+		fields = append(fields, field)
+	}
+	rebuilt, _ := newFieldIndex(fields...) // Fields were validated when idx was created
+	for _, set := range []map[string]Entry{records, deleted} {
+		for _, record := range set {
+			rebuilt.add(record)
+		}
+	}
+	return rebuilt
+}
+
+// indexedFields returns the indexed fields in sorted order
+func (idx *fieldIndex) indexedFields() []string {
+	if idx == nil {
+		return nil
+	}
+	fields := make([]string, 0, len(idx.fields))
+	for field := range idx.fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// add indexes a record, replacing any previous version of it. A nil index ignores it.
+func (idx *fieldIndex) add(record Entry) {
+	if idx == nil {
+		return
+	}
+	idx.remove(record.ID)
+
+	values := indexedValues{category: record.Category, ownerID: record.OwnerID, createdAt: record.CreatedAt}
+	if idx.fields[IndexCategory] {
+		addToSet(idx.categories, record.Category, record.ID)
+	}
+	if idx.fields[IndexOwnerID] {
+		addToSet(idx.owners, record.OwnerID, record.ID)
+	}
+	if idx.fields[IndexTags] {
+		values.tags = append([]string(nil), record.Tags...)
+		for _, tag := range record.Tags {
+			addToSet(idx.tags, tag, record.ID)
+		}
+	}
+	if idx.fields[IndexCreatedAt] {
+		key := timeKey{at: record.CreatedAt, id: record.ID}
+		// Records usually arrive in creation order, which makes this an append
+		i := sort.Search(len(idx.created), func(i int) bool { return !idx.created[i].less(key) })
+		idx.created = append(idx.created, timeKey{})
+		copy(idx.created[i+1:], idx.created[i:])
+		idx.created[i] = key
+	}
+	idx.values[record.ID] = values
+}
+
+// remove drops a record from the index. A nil index ignores it.
+func (idx *fieldIndex) remove(id string) {
+	if idx == nil {
+		return
+	}
+	values, exists := idx.values[id]
+	if !exists {
+		return
+	}
+	removeFromSet(idx.categories, values.category, id)
+	removeFromSet(idx.owners, values.ownerID, id)
+	for _, tag := range values.tags {
+		removeFromSet(idx.tags, tag, id)
+	}
+	if idx.fields[IndexCreatedAt] {
+		key := timeKey{at: values.createdAt, id: id}
+		i := sort.Search(len(idx.created), func(i int) bool { return !idx.created[i].less(key) })
+		if i < len(idx.created) && idx.created[i].id == id {
+			idx.created = append(idx.created[:i], idx.created[i+1:]...)
+		}
+	}
+	delete(idx.values, id)
+}
+
+// candidates returns the IDs of the records that can match the group, or false if the
+// indexes cannot narrow it down and every record has to be checked. The candidates are
+// a superset of the matches; the filter still has to be evaluated on each of them.
+func (idx *fieldIndex) candidates(group FilterGroup) (idSet, bool) {
+	if idx == nil || (len(group.Conditions) == 0 && len(group.Groups) == 0) {
+		return nil, false
+	}
+
+	switch group.Operator {
+	case OpAnd:
+		// Any indexed child narrows the result; the smallest set is the best start
+		var result idSet
+		found := false
+		narrow := func(set idSet) {
+			if !found {
+				result, found = set, true
+				return
+			}
+			result = intersect(result, set)
+		}
+		for _, condition := range group.Conditions {
+			if set, ok := idx.lookup(condition); ok {
+				narrow(set)
+			}
+		}
+		for _, subgroup := range group.Groups {
+			if set, ok := idx.candidates(subgroup); ok {
+				narrow(set)
+			}
+		}
+		return result, found
+
+	case OpOr:
+		// Every child has to be indexed, or an unindexed one could match anything
+		result := make(idSet)
+		for _, condition := range group.Conditions {
+			set, ok := idx.lookup(condition)
+			if !ok {
+				return nil, false
+			}
+			for id := range set {
+				result[id] = struct{}{}
+			}
+		}
+		for _, subgroup := range group.Groups {
+			set, ok := idx.candidates(subgroup)
+			if !ok {
+				return nil, false
+			}
+			for id := range set {
+				result[id] = struct{}{}
+			}
+		}
+		return result, true
+
+	default:
+		return nil, false
+	}
+}
+
+// lookup returns the IDs of the records matching a single condition, or false if the
+// condition cannot be answered from an index
+func (idx *fieldIndex) lookup(condition Condition) (idSet, bool) {
+	if !idx.fields[condition.Field] {
+		return nil, false
+	}
+
+	switch condition.Field {
+	case IndexCategory, IndexOwnerID:
+		value, ok := condition.Value.(string)
+		if !ok || condition.Operator != "=" {
+			return nil, false
+		}
+		if condition.Field == IndexCategory {
+			return idx.categories[value], true
+		}
+		return idx.owners[value], true
+
+	case IndexTags:
+		value, ok := condition.Value.(string)
+		if !ok || condition.Operator != "CONTAINS" {
+			return nil, false
+		}
+		return idx.tags[value], true
+
+	case IndexCreatedAt:
+		value, ok := condition.Value.(time.Time)
+		if !ok {
+			return nil, false
+		}
+		before := func(i int) bool { return !idx.created[i].at.Before(value) } // First at >= value
+		after := func(i int) bool { return idx.created[i].at.After(value) }    // First at > value
+		var from, to int
+		switch condition.Operator {
+		case "=":
+			from, to = sort.Search(len(idx.created), before), sort.Search(len(idx.created), after)
+		case ">":
+			from, to = sort.Search(len(idx.created), after), len(idx.created)
+		case ">=":
+			from, to = sort.Search(len(idx.created), before), len(idx.created)
+		case "<":
+			from, to = 0, sort.Search(len(idx.created), before)
+		case "<=":
+			from, to = 0, sort.Search(len(idx.created), after)
+		default:
+			return nil, false
+		}
+		set := make(idSet, to-from)
+		for _, key := range idx.created[from:to] {
+			set[key.id] = struct{}{}
+		}
+		return set, true
+	}
+	return nil, false
+}
+
+// less orders time keys by time, then ID
+func (k timeKey) less(other timeKey) bool {
+	if !k.at.Equal(other.at) {
+		return k.at.Before(other.at)
+	}
+	return k.id < other.id
+}
+
+// addToSet adds an ID to the set stored under key
+func addToSet(sets map[string]idSet, key, id string) {
+	if sets[key] == nil {
+		sets[key] = make(idSet)
+	}
+	sets[key][id] = struct{}{}
+}
+
+// removeFromSet removes an ID from the set stored under key, dropping empty sets
+func removeFromSet(sets map[string]idSet, key, id string) {
+	delete(sets[key], id)
+	if len(sets[key]) == 0 {
+		delete(sets, key)
+	}
+}
+
+// intersect returns the IDs present in both sets
+func intersect(a, b idSet) idSet {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	result := make(idSet, len(a))
+	for id := range a {
+		if _, ok := b[id]; ok {
+			result[id] = struct{}{}
+		}
+	}
+	return result
+}
@@ -0,0 +1,180 @@
+package knowledge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var indexTestStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// indexTestRecords returns records spread over categories, owners, tags and creation days
+func indexTestRecords(count int) []Entry {
+	categories := []string{CategoryFact, CategoryMessage, CategoryDecision, CategoryAction}
+	records := make([]Entry, 0, count)
+	for i := 0; i < count; i++ {
+		records = append(records, Entry{
+			ID:          fmt.Sprintf("rec-%06d", i),
+			OwnerID:     fmt.Sprintf("owner-%d", i%10),
+			Category:    categories[i%len(categories)],
+			Tags:        []string{fmt.Sprintf("tag-%d", i%7), "all"},
+			Content:     []byte(fmt.Sprintf("record %d", i)),
+			ContentType: ContentTypeText,
+			CreatedAt:   indexTestStart.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	return records
+}
+
+func sortedIDs(records []Entry) string {
+	ids := recordIDs(records)
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestIndexedMemoryStoreMatchesScan(t *testing.T) {
+	plain, _ := NewMemoryStore()
+	indexed, err := NewIndexedMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create indexed store: %v", err)
+	}
+	for _, store := range []*MemoryStore{plain, indexed} {
+		store.Open()
+		if err := store.AddRecords(indexTestRecords(200)...); err != nil {
+			t.Fatalf("Failed to add records: %v", err)
+		}
+		// Exercise index maintenance on every write path
+		store.DeleteRecord("rec-000004")
+		store.PurgeRecord("rec-000008")
+		updated, _ := store.GetRecord("rec-000012")
+		updated.Category = CategoryDecision
+		updated.Tags = []string{"moved"}
+		store.UpdateRecord(updated)
+		store.LoadRecords(Entry{ID: "rec-000016", OwnerID: "owner-x", Category: CategoryFact, CreatedAt: indexTestStart})
+	}
+
+	day := func(n int) time.Time { return indexTestStart.Add(time.Duration(n) * 24 * time.Hour) }
+	and := func(conditions ...Condition) FilterGroup {
+		return FilterGroup{Operator: OpAnd, Conditions: conditions}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+	}{
+		{"category", Filter{RootGroup: and(Condition{Field: "Category", Operator: "=", Value: CategoryDecision})}},
+		{"owner and tag", Filter{RootGroup: and(
+			Condition{Field: "OwnerID", Operator: "=", Value: "owner-3"},
+			Condition{Field: "Tags", Operator: "CONTAINS", Value: "tag-2"})}},
+		{"moved tag", Filter{RootGroup: and(Condition{Field: "Tags", Operator: "CONTAINS", Value: "moved"})}},
+		{"created range", Filter{RootGroup: and(
+			Condition{Field: "CreatedAt", Operator: ">=", Value: day(2)},
+			Condition{Field: "CreatedAt", Operator: "<", Value: day(3)})}},
+		{"created exact", Filter{RootGroup: and(Condition{Field: "CreatedAt", Operator: "=", Value: indexTestStart})}},
+		{"created after", Filter{RootGroup: and(Condition{Field: "CreatedAt", Operator: ">", Value: day(8)})}},
+		{"created up to", Filter{RootGroup: and(Condition{Field: "CreatedAt", Operator: "<=", Value: day(1)})}},
+		{"indexed and unindexed", Filter{RootGroup: and(
+			Condition{Field: "Category", Operator: "=", Value: CategoryFact},
+			Condition{Field: "Content", Operator: "CONTAINS", Value: "1"})}},
+		{"or", Filter{RootGroup: FilterGroup{Operator: OpOr, Conditions: []Condition{
+			{Field: "OwnerID", Operator: "=", Value: "owner-1"},
+			{Field: "Tags", Operator: "CONTAINS", Value: "tag-6"}}}}},
+		{"or with unindexed", Filter{RootGroup: FilterGroup{Operator: OpOr, Conditions: []Condition{
+			{Field: "OwnerID", Operator: "=", Value: "owner-1"},
+			{Field: "Content", Operator: "=", Value: "record 5"}}}}},
+		{"not", Filter{RootGroup: FilterGroup{Operator: OpNot, Conditions: []Condition{
+			{Field: "Tags", Operator: "CONTAINS", Value: "all"}}}}},
+		{"nested", Filter{RootGroup: FilterGroup{Operator: OpAnd,
+			Conditions: []Condition{{Field: "Category", Operator: "=", Value: CategoryFact}},
+			Groups: []FilterGroup{{Operator: OpOr, Conditions: []Condition{
+				{Field: "OwnerID", Operator: "=", Value: "owner-2"},
+				{Field: "OwnerID", Operator: "=", Value: "owner-x"}}}}}}},
+		{"include deleted", Filter{IncludeDeleted: true, RootGroup: and(Condition{Field: "OwnerID", Operator: "=", Value: "owner-4"})}},
+		{"only deleted", Filter{OnlyDeleted: true, RootGroup: and(Condition{Field: "Category", Operator: "=", Value: CategoryFact})}},
+		{"unknown value", Filter{RootGroup: and(Condition{Field: "Category", Operator: "=", Value: "nope"})}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, _ := plain.SearchRecords(tt.filter)
+			got, err := indexed.SearchRecords(tt.filter)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if sortedIDs(got) != sortedIDs(expected) {
+				t.Errorf("Indexed search returned %d records, scan returned %d", len(got), len(expected))
+			}
+		})
+	}
+
+	// Ordering and paging apply to indexed searches too
+	paged, _ := indexed.SearchRecords(Filter{
+		RootGroup: and(Condition{Field: "OwnerID", Operator: "=", Value: "owner-0"}),
+		OrderBy:   "CreatedAt", OrderDir: "DESC", Limit: 2, Offset: 1,
+	})
+	if len(paged) != 2 || paged[0].ID != "rec-000180" || paged[1].ID != "rec-000170" {
+		t.Errorf("Unexpected page: %s", sortedIDs(paged))
+	}
+}
+
+func TestIndexedMemoryStoreReopen(t *testing.T) {
+	store, _ := NewIndexedMemoryStore(IndexCategory)
+	store.Open()
+	store.AddRecords(indexTestRecords(8)...)
+
+	info, _ := store.Info()
+	if info["indexed_fields"] != IndexCategory {
+		t.Errorf("Expected indexed_fields %q, got %q", IndexCategory, info["indexed_fields"])
+	}
+
+	store.Close()
+	store.Open()
+	store.AddRecords(indexTestRecords(4)...)
+	results, _ := store.SearchRecords(Filter{RootGroup: FilterGroup{Operator: OpAnd,
+		Conditions: []Condition{{Field: "Category", Operator: "=", Value: CategoryFact}}}})
+	if len(results) != 1 {
+		t.Errorf("Expected 1 record after reopening, got %d", len(results))
+	}
+
+	if _, err := NewIndexedMemoryStore("Content"); err == nil {
+		t.Error("Expected error for a field that cannot be indexed")
+	}
+}
+
+// benchmarkSearch runs the filter against a store with 100k records
+func benchmarkSearch(b *testing.B, indexed bool, group FilterGroup) {
+	store, _ := NewMemoryStore()
+	if indexed {
+		store, _ = NewIndexedMemoryStore()
+	}
+	store.Open()
+	if err := store.AddRecords(indexTestRecords(100000)...); err != nil {
+		b.Fatalf("Failed to add records: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.SearchRecords(Filter{RootGroup: group}); err != nil {
+			b.Fatalf("Search failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkSearchRecords(b *testing.B) {
+	filters := map[string]FilterGroup{
+		"Owner": {Operator: OpAnd, Conditions: []Condition{
+			{Field: "OwnerID", Operator: "=", Value: "owner-3"}}},
+		"CategoryAndTag": {Operator: OpAnd, Conditions: []Condition{
+			{Field: "Category", Operator: "=", Value: CategoryDecision},
+			{Field: "Tags", Operator: "CONTAINS", Value: "tag-5"}}},
+		"CreatedAtRange": {Operator: OpAnd, Conditions: []Condition{
+			{Field: "CreatedAt", Operator: ">=", Value: indexTestStart.Add(1000 * time.Hour)},
+			{Field: "CreatedAt", Operator: "<", Value: indexTestStart.Add(1100 * time.Hour)}}},
+	}
+	for name, group := range filters {
+		b.Run(name+"/Scan", func(b *testing.B) { benchmarkSearch(b, false, group) })
+		b.Run(name+"/Indexed", func(b *testing.B) { benchmarkSearch(b, true, group) })
+	}
+}
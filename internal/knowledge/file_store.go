@@ -12,6 +12,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"goproduct/internal/embeddings"
 )
 
 // FileStore data structure
@@ -25,12 +27,18 @@ type FileStore struct {
 	filename    string
 	records     map[string]Entry
 	deletedRecs map[string]Entry
+	index       *invertedIndex    // Full-text index over active and deleted records
+	vectors     *embeddings.Index // Embedding index over active and deleted records
+	feed        *changeFeed       // Watchers of record changes
 	isDirty     bool
+	dirtyCount  int            // Record changes since the last flush
+	autoFlush   *autoFlusher   // Background flushes, nil unless WithAutoFlush is used
+	wal         *writeAheadLog // Append-only log of changes since the last snapshot, nil in snapshot-only mode
 	mu          sync.RWMutex
 }
 
 // NewFileStore creates new file-based knowledge store
-func NewFileStore(filename string) (*FileStore, error) {
+func NewFileStore(filename string, opts ...FileStoreOption) (*FileStore, error) {
 	// Ensure directory exists
 	dir := filepath.Dir(filename)
 	if dir != "." {
@@ -43,9 +51,28 @@ func NewFileStore(filename string) (*FileStore, error) {
 		filename:    filename,
 		records:     make(map[string]Entry),
 		deletedRecs: make(map[string]Entry),
+		index:       newInvertedIndex(),
+		vectors:     embeddings.NewIndex(),
+		feed:        newChangeFeed(),
 		isDirty:     false,
 	}
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	return store, nil
+}
 
+// NewWALFileStore creates a file-based knowledge store that appends changes to a
+// write-ahead log next to the file instead of rewriting the whole file on every Flush.
+// The log is folded into the file once it holds compactAfter entries, and when the
+// store is closed. Open replays the log, so changes flushed before a crash survive.
+func NewWALFileStore(filename string, compactAfter int, opts ...FileStoreOption) (*FileStore, error) {
+	store, err := NewFileStore(filename, opts...)
+	if err != nil {
+		return nil, err
+	}
+	store.wal = newWriteAheadLog(filename+".wal", compactAfter)
 	return store, nil
 }
 
@@ -59,6 +86,12 @@ func (f *FileStore) Open() error {
 		// File doesn't exist yet, initialize empty store
 		f.records = make(map[string]Entry)
 		f.deletedRecs = make(map[string]Entry)
+		if err := f.replayWAL(); err != nil {
+			return err
+		}
+		f.index = buildInvertedIndex(f.records, f.deletedRecs)
+		f.vectors = buildVectorIndex(f.records, f.deletedRecs)
+		f.startAutoFlush()
 		return nil
 	}
 
@@ -77,18 +110,38 @@ func (f *FileStore) Open() error {
 	// Copy data to store
 	f.records = fileData.Records
 	f.deletedRecs = fileData.DeletedRecs
+	if f.records == nil {
+		f.records = make(map[string]Entry)
+	}
+	if f.deletedRecs == nil {
+		f.deletedRecs = make(map[string]Entry)
+	}
+
+	// Apply changes logged after the snapshot was written
+	if err := f.replayWAL(); err != nil {
+		return err
+	}
+	f.index = buildInvertedIndex(f.records, f.deletedRecs)
+	f.vectors = buildVectorIndex(f.records, f.deletedRecs)
 	f.isDirty = false
+	f.dirtyCount = 0
+	f.startAutoFlush()
 
 	return nil
 }
 
 // Close flushes data to disk and releases resources
 func (f *FileStore) Close() error {
+	// Stop background flushes first; one in progress finishes before the final flush
+	if f.autoFlush != nil {
+		f.autoFlush.stop()
+	}
+
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	// If there are changes, flush to disk
-	if f.isDirty {
+	// If there are changes, flush to disk; in WAL mode fold the log into the file
+	if f.isDirty || (f.wal != nil && f.wal.count > 0) {
 		if err := f.flush(); err != nil {
 			return err
 		}
@@ -97,19 +150,33 @@ func (f *FileStore) Close() error {
 	// Clear in-knowledge data
 	f.records = nil
 	f.deletedRecs = nil
+	f.index = nil
+	f.vectors = nil
 
+	// End all watches so consumers stop reading
+	f.feed.closeAll()
 	return nil
 }
 
+// IsOpen reports whether the store holds its records, from Open to Close
+func (f *FileStore) IsOpen() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.records != nil
+}
+
 // Flush writes current data to disk if needed
 func (f *FileStore) Flush() error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	if f.isDirty {
-		return f.flush()
+	if !f.isDirty {
+		return nil
 	}
-	return nil
+	if f.wal != nil {
+		return f.appendWAL()
+	}
+	return f.flush()
 }
 
 // internal flush method (must be called with lock held)
@@ -138,6 +205,14 @@ func (f *FileStore) flush() error {
 	}
 
 	f.isDirty = false
+	f.dirtyCount = 0
+
+	// The snapshot now holds everything the log did
+	if f.wal != nil {
+		if err := f.wal.reset(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -156,6 +231,11 @@ func (f *FileStore) AddRecord(record Entry) error {
 		return fmt.Errorf("knowledge record with ID %s already exists", record.ID)
 	}
 
+	// Index the embedding first so an invalid vector leaves the store unchanged
+	if err := indexEmbedding(f.vectors, record); err != nil {
+		return err
+	}
+
 	// Set timestamps if not set
 	now := time.Now()
 	if record.CreatedAt.IsZero() {
@@ -164,10 +244,13 @@ func (f *FileStore) AddRecord(record Entry) error {
 	if record.UpdatedAt.IsZero() {
 		record.UpdatedAt = now
 	}
+	record.Provenance = recordProvenance(nil, record, ProvenanceOpAdd, now)
 
 	// Add to records
 	f.records[record.ID] = record
-	f.isDirty = true
+	f.index.add(record)
+	f.feed.publish(ChangeAdd, record, f.matchesFilter)
+	f.markDirty(record.ID)
 
 	return nil
 }
@@ -191,16 +274,27 @@ func (f *FileStore) UpdateRecord(record Entry) error {
 	defer f.mu.Unlock()
 
 	// Check if record exists
-	if _, exists := f.records[record.ID]; !exists {
+	existing, exists := f.records[record.ID]
+	if !exists {
 		return fmt.Errorf("knowledge record with ID %s not found", record.ID)
 	}
 
+	// Index the embedding first so an invalid vector leaves the store unchanged
+	if err := indexEmbedding(f.vectors, record); err != nil {
+		return err
+	}
+
 	// Update timestamp
 	record.UpdatedAt = time.Now()
+	record = keepAccessStats(existing, record)
+	record.Provenance = recordProvenance(existing.Provenance, record, ProvenanceOpUpdate, record.UpdatedAt)
+	record = withContentDiff(existing, record)
 
 	// Update record
 	f.records[record.ID] = record
-	f.isDirty = true
+	f.index.add(record)
+	f.feed.publish(ChangeUpdate, record, f.matchesFilter)
+	f.markDirty(record.ID)
 
 	return nil
 }
@@ -219,11 +313,143 @@ func (f *FileStore) DeleteRecord(id string) error {
 	// Move record to deleted records
 	f.deletedRecs[id] = record
 	delete(f.records, id)
-	f.isDirty = true
+	f.feed.publish(ChangeDelete, record, f.matchesFilter)
+	f.markDirty(id)
 
 	return nil
 }
 
+// AddRecords adds a batch of records under a single lock. Either all records are added
+// or, if any of them is invalid or already exists, none are. The store is marked dirty
+// once, so the next Flush writes the whole batch in a single write.
+func (f *FileStore) AddRecords(records ...Entry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	// Validate the whole batch before changing anything
+	if err := validateBatchIDs(recordIDs(records)); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if _, exists := f.records[record.ID]; exists {
+			return fmt.Errorf("knowledge record with ID %s already exists", record.ID)
+		}
+	}
+	if err := validateEmbeddings(f.vectors, records); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, record := range records {
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = now
+		}
+		if record.UpdatedAt.IsZero() {
+			record.UpdatedAt = now
+		}
+		record.Provenance = recordProvenance(nil, record, ProvenanceOpAdd, now)
+
+		f.records[record.ID] = record
+		f.index.add(record)
+		indexEmbedding(f.vectors, record) // Already validated
+		f.feed.publish(ChangeAdd, record, f.matchesFilter)
+	}
+
+	f.markDirty(recordIDs(records)...)
+	return nil
+}
+
+// UpdateRecords updates a batch of existing records under a single lock. Either all
+// records are updated or, if any of them is invalid or missing, none are.
+func (f *FileStore) UpdateRecords(records ...Entry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	// Validate the whole batch before changing anything
+	if err := validateBatchIDs(recordIDs(records)); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if _, exists := f.records[record.ID]; !exists {
+			return fmt.Errorf("knowledge record with ID %s not found", record.ID)
+		}
+	}
+	if err := validateEmbeddings(f.vectors, records); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, record := range records {
+		record.UpdatedAt = now
+		record = keepAccessStats(f.records[record.ID], record)
+		record.Provenance = recordProvenance(f.records[record.ID].Provenance, record, ProvenanceOpUpdate, now)
+		record = withContentDiff(f.records[record.ID], record)
+
+		f.records[record.ID] = record
+		f.index.add(record)
+		indexEmbedding(f.vectors, record) // Already validated
+		f.feed.publish(ChangeUpdate, record, f.matchesFilter)
+	}
+
+	f.markDirty(recordIDs(records)...)
+	return nil
+}
+
+// DeleteRecords soft-deletes a batch of records under a single lock. Either all records
+// are deleted or, if any of them is missing, none are.
+func (f *FileStore) DeleteRecords(ids ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// Validate the whole batch before changing anything
+	if err := validateBatchIDs(ids); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, exists := f.records[id]; !exists {
+			return fmt.Errorf("knowledge record with ID %s not found", id)
+		}
+	}
+
+	for _, id := range ids {
+		record := f.records[id]
+		f.deletedRecs[id] = record
+		delete(f.records, id)
+		f.feed.publish(ChangeDelete, record, f.matchesFilter)
+	}
+
+	f.markDirty(ids...)
+	return nil
+}
+
+// RecordAccess adds retrieval counts to records without changing UpdatedAt or provenance.
+// Accesses to records that no longer exist are ignored. The counts are persisted by the
+// next Flush.
+func (f *FileStore) RecordAccess(accesses ...Access) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, access := range accesses {
+		if record, exists := f.records[access.ID]; exists {
+			f.records[access.ID] = applyAccess(record, access)
+			f.markDirty(access.ID)
+		}
+	}
+	return nil
+}
+
 // RestoreRecord restores a deleted record
 func (f *FileStore) RestoreRecord(id string) error {
 	f.mu.Lock()
@@ -236,9 +462,11 @@ func (f *FileStore) RestoreRecord(id string) error {
 	}
 
 	// Move record back to active records
+	record.Provenance = recordProvenance(record.Provenance, record, ProvenanceOpRestore, time.Now())
 	f.records[id] = record
 	delete(f.deletedRecs, id)
-	f.isDirty = true
+	f.feed.publish(ChangeRestore, record, f.matchesFilter)
+	f.markDirty(id)
 
 	return nil
 }
@@ -249,8 +477,8 @@ func (f *FileStore) PurgeRecord(id string) error {
 	defer f.mu.Unlock()
 
 	// Check if record exists in either active or deleted records
-	_, existsActive := f.records[id]
-	_, existsDeleted := f.deletedRecs[id]
+	activeRecord, existsActive := f.records[id]
+	deletedRecord, existsDeleted := f.deletedRecs[id]
 
 	if !existsActive && !existsDeleted {
 		return fmt.Errorf("knowledge record with ID %s not found", id)
@@ -259,11 +487,15 @@ func (f *FileStore) PurgeRecord(id string) error {
 	// Remove from appropriate map
 	if existsActive {
 		delete(f.records, id)
+		f.feed.publish(ChangePurge, activeRecord, f.matchesFilter)
 	} else {
 		delete(f.deletedRecs, id)
+		f.feed.publish(ChangePurge, deletedRecord, f.matchesFilter)
 	}
+	f.index.remove(id)
+	f.vectors.Remove(id)
 
-	f.isDirty = true
+	f.markDirty(id)
 	return nil
 }
 
@@ -323,6 +555,90 @@ func (f *FileStore) SearchRecords(filter Filter) ([]Entry, error) {
 	return results, nil
 }
 
+// FullTextSearch returns records matching the natural-language query, most relevant first
+func (f *FileStore) FullTextSearch(query string, opts ...SearchOption) ([]SearchResult, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	options := applySearchOptions(opts)
+	scores := f.index.search(query, options.MatchAll)
+	return rankSearchResults(scores, func(id string) (Entry, bool, bool) {
+		if record, exists := f.records[id]; exists {
+			return record, false, true
+		}
+		record, exists := f.deletedRecs[id]
+		return record, true, exists
+	}, options), nil
+}
+
+// SearchSimilar returns up to topK active records whose embeddings are most similar to the vector
+func (f *FileStore) SearchSimilar(vector []float32, topK int) ([]SearchResult, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return searchSimilar(f.vectors, vector, topK, func(id string) (Entry, bool) {
+		record, exists := f.records[id]
+		return record, exists
+	})
+}
+
+// CountRecords returns the number of records matching the filter, ignoring its limit and offset
+func (f *FileStore) CountRecords(filter Filter) (int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	count := 0
+	f.eachMatch(filter, func(Entry) { count++ })
+	return count, nil
+}
+
+// Aggregate counts the records matching the filter per value of the groupBy field,
+// ignoring the filter's limit and offset. See GroupByTags for grouping by tag and use
+// "Metadata.<key>" to group by a metadata value.
+func (f *FileStore) Aggregate(filter Filter, groupBy string) (map[string]int, error) {
+	if err := validateGroupBy(groupBy); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	counts := make(map[string]int)
+	f.eachMatch(filter, func(record Entry) {
+		for _, key := range groupKeys(record, groupBy) {
+			counts[key]++
+		}
+	})
+	return counts, nil
+}
+
+// eachMatch calls fn for every record matching the filter, without copying them into a
+// result set (must be called with lock held)
+func (f *FileStore) eachMatch(filter Filter, fn func(record Entry)) {
+	if !filter.OnlyDeleted {
+		for _, record := range f.records {
+			if f.matchesFilter(record, filter.RootGroup) {
+				fn(record)
+			}
+		}
+	}
+	if filter.IncludeDeleted || filter.OnlyDeleted {
+		for _, record := range f.deletedRecs {
+			if f.matchesFilter(record, filter.RootGroup) {
+				fn(record)
+			}
+		}
+	}
+}
+
+// Watch streams changes to records matching the filter's conditions until the returned
+// CancelFunc is called or the store is closed. Deletions and purges are delivered with
+// the removed record. Slow consumers lose the oldest events rather than blocking writes;
+// see ChangeEvent.Missed.
+func (f *FileStore) Watch(filter Filter) (<-chan ChangeEvent, CancelFunc) {
+	return f.feed.watch(filter)
+}
+
 // matchesFilter checks if a record matches the filter group
 func (f *FileStore) matchesFilter(record Entry, group FilterGroup) bool {
 	// Default to AND if no operator specified
@@ -391,6 +707,11 @@ func (f *FileStore) matchesCondition(record Entry, condition Condition) bool {
 		return f.matchesMetadata(record.Metadata, condition)
 	}
 
+	// Special handling for provenance chains
+	if condition.Field == "Provenance" {
+		return matchesProvenance(record.Provenance, condition)
+	}
+
 	// Special handling for time fields with direct access (more accurate than reflection)
 	switch condition.Field {
 	case "CreatedAt":
@@ -729,25 +1050,20 @@ func (f *FileStore) LoadRecords(records ...Entry) error {
 	}
 
 	// First validate that all records have IDs and there are no duplicates in the input
-	seenIDs := make(map[string]bool)
-	for i, record := range records {
-		// Validate record has an ID
-		if record.ID == "" {
-			return fmt.Errorf("record at index %d must have an ID", i)
-		}
+	if err := validateBatchIDs(recordIDs(records)); err != nil {
+		return err
+	}
 
-		// Check for duplicate IDs in the input
-		if seenIDs[record.ID] {
-			return fmt.Errorf("duplicate record ID found in input: %s", record.ID)
-		}
-		seenIDs[record.ID] = true
+	// Validate embeddings against the index and each other before changing anything
+	if err := validateEmbeddings(f.vectors, records); err != nil {
+		return err
 	}
 
 	// Process all records (add new ones, update existing ones)
 	now := time.Now()
 	for _, record := range records {
 		// Check if record exists (update) or not (add)
-		_, exists := f.records[record.ID]
+		existing, exists := f.records[record.ID]
 
 		// Set timestamps appropriately
 		if !exists {
@@ -761,13 +1077,24 @@ func (f *FileStore) LoadRecords(records ...Entry) error {
 		if record.UpdatedAt.IsZero() {
 			record.UpdatedAt = now
 		}
+		record.Provenance = recordProvenance(existing.Provenance, record, ProvenanceOpLoad, now)
+		if exists {
+			record = withContentDiff(existing, record)
+		}
 
 		// Store the record (add or update)
 		f.records[record.ID] = record
+		f.index.add(record)
+		indexEmbedding(f.vectors, record) // Already validated
+		if exists {
+			f.feed.publish(ChangeUpdate, record, f.matchesFilter)
+		} else {
+			f.feed.publish(ChangeAdd, record, f.matchesFilter)
+		}
 	}
 
 	// Mark the store as dirty since we've modified records
-	f.isDirty = true
+	f.markDirty(recordIDs(records)...)
 
 	return nil
 }
@@ -786,7 +1113,27 @@ func (f *FileStore) Info() (map[string]string, error) {
 	info["file_name"] = filepath.Base(f.filename)
 	info["record_count"] = fmt.Sprintf("%d", len(f.records))
 	info["deleted_count"] = fmt.Sprintf("%d", len(f.deletedRecs))
+	info["access_count"] = fmt.Sprintf("%d", totalAccessCount(f.records))
 	info["is_dirty"] = fmt.Sprintf("%t", f.isDirty)
+	if f.wal != nil {
+		info["wal_path"] = f.wal.path
+		info["wal_entries"] = fmt.Sprintf("%d", f.wal.count)
+	}
+	if f.autoFlush != nil {
+		info["auto_flush_interval"] = f.autoFlush.interval.String()
+		info["dirty_records"] = fmt.Sprintf("%d", f.dirtyCount)
+		if err := f.autoFlush.err(); err != nil {
+			info["auto_flush_error"] = err.Error()
+		}
+	}
 
 	return info, nil
 }
+
+// startAutoFlush starts the background flushes if the store has them
+// (must be called with lock held)
+func (f *FileStore) startAutoFlush() {
+	if f.autoFlush != nil {
+		f.autoFlush.start(f.Flush)
+	}
+}
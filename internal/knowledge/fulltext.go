@@ -0,0 +1,283 @@
+package knowledge
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchOptions controls a full-text search
+type SearchOptions struct {
+	Limit          int      // Maximum number of results, 0 for no limit
+	Categories     []string // Only return entries in these categories, empty for all
+	MatchAll       bool     // Require every query term to match instead of any
+	IncludeDeleted bool     // Whether to include soft-deleted records
+	UsageBoost     float64  // Weight of access statistics in the ranking, 0 to rank on text alone
+}
+
+// SearchOption is a function that configures a full-text search
+type SearchOption func(*SearchOptions)
+
+// WithSearchLimit limits the number of results
+func WithSearchLimit(limit int) SearchOption {
+	return func(o *SearchOptions) {
+		o.Limit = limit
+	}
+}
+
+// WithSearchCategories restricts results to the given categories
+func WithSearchCategories(categories ...string) SearchOption {
+	return func(o *SearchOptions) {
+		o.Categories = append(o.Categories, categories...)
+	}
+}
+
+// WithSearchMatchAll requires every query term to be present in a result
+func WithSearchMatchAll() SearchOption {
+	return func(o *SearchOptions) {
+		o.MatchAll = true
+	}
+}
+
+// WithSearchIncludeDeleted includes soft-deleted records in the results
+func WithSearchIncludeDeleted() SearchOption {
+	return func(o *SearchOptions) {
+		o.IncludeDeleted = true
+	}
+}
+
+// WithSearchUsageBoost ranks frequently surfaced entries higher. A weight of 0.1 lifts an
+// entry surfaced ten times by about a quarter.
+func WithSearchUsageBoost(weight float64) SearchOption {
+	return func(o *SearchOptions) {
+		o.UsageBoost = weight
+	}
+}
+
+// SearchResult is a single full-text search hit
+type SearchResult struct {
+	Entry Entry   `json:"entry" xml:"entry" yaml:"entry"` // The matching entry
+	Score float64 `json:"score" xml:"score" yaml:"score"` // Relevance score, higher is better
+}
+
+// stopWords are common words left out of the index
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "do": true, "does": true, "for": true, "from": true, "how": true, "in": true,
+	"is": true, "it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "we": true, "what": true, "when": true,
+	"where": true, "which": true, "who": true, "why": true, "with": true,
+}
+
+// tokenize splits text into normalized index terms: lower-cased, stop words removed
+// and simple plural suffixes stripped so "deployments" matches "deployment"
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if stopWords[word] {
+			continue
+		}
+		terms = append(terms, stem(word))
+	}
+	return terms
+}
+
+// stem strips common English plural suffixes
+func stem(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us"):
+		return word[:len(word)-1]
+	default:
+		return word
+	}
+}
+
+// searchableText returns the text of an entry that is indexed for full-text search:
+// textual content, tags and metadata values. Outbox intents are not searchable.
+func searchableText(entry Entry) string {
+	if entry.Category == CategoryOutbox {
+		return ""
+	}
+	var sb strings.Builder
+	if entry.ContentType != ContentTypeBinary {
+		sb.Write(entry.Content)
+	}
+	for _, tag := range entry.Tags {
+		sb.WriteString(" ")
+		sb.WriteString(tag)
+	}
+	for _, value := range entry.Metadata {
+		sb.WriteString(" ")
+		sb.WriteString(value)
+	}
+	return sb.String()
+}
+
+// invertedIndex maps terms to the entries containing them and ranks matches with BM25.
+// It is not safe for concurrent use; stores guard it with their own lock.
+type invertedIndex struct {
+	postings    map[string]map[string]int // Term to entry ID to term frequency
+	docTerms    map[string][]string       // Entry ID to its distinct terms, for removal
+	docLengths  map[string]int            // Entry ID to number of terms
+	totalLength int
+}
+
+// newInvertedIndex creates an empty index
+func newInvertedIndex() *invertedIndex {
+	return &invertedIndex{
+		postings:   make(map[string]map[string]int),
+		docTerms:   make(map[string][]string),
+		docLengths: make(map[string]int),
+	}
+}
+
+// add indexes an entry, replacing any previous version of it
+func (idx *invertedIndex) add(entry Entry) {
+	idx.remove(entry.ID)
+
+	terms := tokenize(searchableText(entry))
+	frequencies := make(map[string]int)
+	for _, term := range terms {
+		frequencies[term]++
+	}
+
+	distinct := make([]string, 0, len(frequencies))
+	for term, count := range frequencies {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[string]int)
+		}
+		idx.postings[term][entry.ID] = count
+		distinct = append(distinct, term)
+	}
+	idx.docTerms[entry.ID] = distinct
+	idx.docLengths[entry.ID] = len(terms)
+	idx.totalLength += len(terms)
+}
+
+// remove drops an entry from the index
+func (idx *invertedIndex) remove(id string) {
+	terms, exists := idx.docTerms[id]
+	if !exists {
+		return
+	}
+	for _, term := range terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	idx.totalLength -= idx.docLengths[id]
+	delete(idx.docTerms, id)
+	delete(idx.docLengths, id)
+}
+
+// search returns the BM25 score of every entry matching the query. A nil index matches nothing.
+func (idx *invertedIndex) search(query string, matchAll bool) map[string]float64 {
+	const k1, b = 1.2, 0.75
+
+	terms := tokenize(query)
+	scores := make(map[string]float64)
+	if idx == nil || len(terms) == 0 || len(idx.docLengths) == 0 {
+		return scores
+	}
+
+	// Repeated query terms should not count twice
+	unique := make([]string, 0, len(terms))
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+
+	docCount := float64(len(idx.docLengths))
+	avgLength := float64(idx.totalLength) / docCount
+	if avgLength == 0 {
+		avgLength = 1
+	}
+
+	matched := make(map[string]int)
+	for _, term := range unique {
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		idf := math.Log(1 + (docCount-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+		for id, frequency := range postings {
+			tf := float64(frequency)
+			norm := tf * (k1 + 1) / (tf + k1*(1-b+b*float64(idx.docLengths[id])/avgLength))
+			scores[id] += idf * norm
+			matched[id]++
+		}
+	}
+
+	if matchAll {
+		for id := range scores {
+			if matched[id] < len(unique) {
+				delete(scores, id)
+			}
+		}
+	}
+	return scores
+}
+
+// rankSearchResults turns index scores into results ordered by relevance, applying the options.
+// lookup returns the entry for an ID and whether it is deleted.
+func rankSearchResults(scores map[string]float64, lookup func(id string) (Entry, bool, bool), options SearchOptions) []SearchResult {
+	categories := make(map[string]bool, len(options.Categories))
+	for _, category := range options.Categories {
+		categories[category] = true
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		entry, deleted, ok := lookup(id)
+		if !ok || (deleted && !options.IncludeDeleted) {
+			continue
+		}
+		if len(categories) > 0 && !categories[entry.Category] {
+			continue
+		}
+		results = append(results, SearchResult{Entry: entry, Score: score * usageBoost(entry, options.UsageBoost)})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Entry.ID < results[j].Entry.ID
+	})
+
+	if options.Limit > 0 && len(results) > options.Limit {
+		results = results[:options.Limit]
+	}
+	return results
+}
+
+// applySearchOptions builds search options from the option functions
+func applySearchOptions(opts []SearchOption) SearchOptions {
+	options := SearchOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// buildInvertedIndex indexes all entries of the given record maps
+func buildInvertedIndex(recordSets ...map[string]Entry) *invertedIndex {
+	idx := newInvertedIndex()
+	for _, records := range recordSets {
+		for _, record := range records {
+			idx.add(record)
+		}
+	}
+	return idx
+}
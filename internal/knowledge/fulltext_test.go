@@ -0,0 +1,136 @@
+package knowledge
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("What are the Deployment policies for the iOS apps?")
+	want := []string{"deployment", "policy", "ios", "app"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func fullTextEntries() []Entry {
+	return []Entry{
+		{ID: "deploy", Category: CategoryFact, ContentType: ContentTypeText, Content: []byte("Production deployments happen on Tuesdays after the release review")},
+		{ID: "stack", Category: CategoryFact, ContentType: ContentTypeText, Content: []byte("The backend is written in Go and deployed on Kubernetes")},
+		{ID: "meeting", Category: CategoryMessage, ContentType: ContentTypeText, Content: []byte("Let's move the release review to Monday")},
+		{ID: "tagged", Category: CategoryDecision, ContentType: ContentTypeText, Content: []byte("Adopt feature flags"), Tags: []string{"deployment"}, Metadata: map[string]string{"title": "Flag rollout"}},
+		{ID: "blob", Category: CategoryFact, ContentType: ContentTypeBinary, Content: []byte("deployment")},
+	}
+}
+
+func resultIDs(results []SearchResult) []string {
+	ids := make([]string, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.Entry.ID)
+	}
+	return ids
+}
+
+func testFullTextSearch(t *testing.T, store Store) {
+	if err := store.LoadRecords(fullTextEntries()...); err != nil {
+		t.Fatalf("Failed to load records: %v", err)
+	}
+
+	// Plural and case differences still match, binary content is not indexed
+	results, err := store.FullTextSearch("when do DEPLOYMENT happen")
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	ids := resultIDs(results)
+	if len(ids) != 2 || ids[0] != "deploy" || ids[1] != "tagged" {
+		t.Errorf("Expected [deploy tagged], got %v", ids)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("Expected results ordered by score, got %v and %v", results[0].Score, results[1].Score)
+	}
+
+	// Metadata values are searchable
+	results, _ = store.FullTextSearch("flag rollout")
+	if ids := resultIDs(results); len(ids) != 1 || ids[0] != "tagged" {
+		t.Errorf("Expected [tagged], got %v", ids)
+	}
+
+	// Options
+	results, _ = store.FullTextSearch("release review", WithSearchCategories(CategoryMessage))
+	if ids := resultIDs(results); len(ids) != 1 || ids[0] != "meeting" {
+		t.Errorf("Expected [meeting] with category filter, got %v", ids)
+	}
+	results, _ = store.FullTextSearch("release Tuesdays", WithSearchMatchAll())
+	if ids := resultIDs(results); len(ids) != 1 || ids[0] != "deploy" {
+		t.Errorf("Expected [deploy] with match all, got %v", ids)
+	}
+	results, _ = store.FullTextSearch("release review", WithSearchLimit(1))
+	if len(results) != 1 {
+		t.Errorf("Expected 1 result with limit, got %d", len(results))
+	}
+	results, _ = store.FullTextSearch("the of and")
+	if len(results) != 0 {
+		t.Errorf("Expected no results for stop words only, got %v", resultIDs(results))
+	}
+
+	// Updates replace the indexed text
+	entry, _ := store.GetRecord("stack")
+	entry.Content = []byte("The backend is written in Rust")
+	if err := store.UpdateRecord(entry); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	if results, _ = store.FullTextSearch("kubernetes"); len(results) != 0 {
+		t.Errorf("Expected no results for replaced text, got %v", resultIDs(results))
+	}
+	if results, _ = store.FullTextSearch("rust"); len(results) != 1 {
+		t.Errorf("Expected updated text to be found, got %v", resultIDs(results))
+	}
+
+	// Deleted records are only returned on request, purged ones never
+	store.DeleteRecord("meeting")
+	if results, _ = store.FullTextSearch("monday"); len(results) != 0 {
+		t.Errorf("Expected deleted record to be hidden, got %v", resultIDs(results))
+	}
+	if results, _ = store.FullTextSearch("monday", WithSearchIncludeDeleted()); len(results) != 1 {
+		t.Errorf("Expected deleted record with include deleted, got %v", resultIDs(results))
+	}
+	store.PurgeRecord("meeting")
+	if results, _ = store.FullTextSearch("monday", WithSearchIncludeDeleted()); len(results) != 0 {
+		t.Errorf("Expected purged record to be gone, got %v", resultIDs(results))
+	}
+}
+
+func TestMemoryStoreFullTextSearch(t *testing.T) {
+	store, _ := NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	testFullTextSearch(t, store)
+}
+
+func TestFileStoreFullTextSearch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "knowledge.json")
+	store, _ := NewFileStore(filename)
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	testFullTextSearch(t, store)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	// The index is rebuilt when the file is loaded
+	reopened, _ := NewFileStore(filename)
+	if err := reopened.Open(); err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	results, err := reopened.FullTextSearch("tuesday deployment")
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if ids := resultIDs(results); len(ids) == 0 || ids[0] != "deploy" {
+		t.Errorf("Expected deploy first after reopening, got %v", ids)
+	}
+}
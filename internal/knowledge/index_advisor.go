@@ -0,0 +1,173 @@
+package knowledge
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultIndexAdvisorMinShare is the share of searches a field has to be filtered on
+// before the IndexAdvisor suggests indexing it
+const DefaultIndexAdvisorMinShare = 0.1
+
+// IndexSuggestion is a field worth indexing, with the searches that would benefit
+type IndexSuggestion struct {
+	Field       string        `json:"field"`
+	Searches    int           `json:"searches"`     // Recorded searches with a condition the index could answer
+	Share       float64       `json:"share"`        // Searches as a share of all recorded searches
+	AvgDuration time.Duration `json:"avg_duration"` // Average duration of those searches without the index
+}
+
+// fieldUsage is what the advisor recorded about one field
+type fieldUsage struct {
+	searches int
+	duration time.Duration
+}
+
+// IndexAdvisor wraps a store and records which fields searches filter on, to suggest
+// the secondary indexes a MemoryStore should keep as query patterns evolve. Only
+// conditions an index could answer are counted: equality on Category and OwnerID,
+// CONTAINS on Tags and comparisons on CreatedAt, outside of OR and NOT groups.
+type IndexAdvisor struct {
+	Store
+	searches int
+	fields   map[string]*fieldUsage
+	mu       sync.Mutex
+}
+
+// NewIndexAdvisor wraps the store with an advisor
+func NewIndexAdvisor(store Store) *IndexAdvisor {
+	return &IndexAdvisor{Store: store, fields: make(map[string]*fieldUsage)}
+}
+
+// SearchRecords searches the wrapped store and records the filter
+func (a *IndexAdvisor) SearchRecords(filter Filter) ([]Entry, error) {
+	defer a.observe(filter, time.Now())
+	return a.Store.SearchRecords(filter)
+}
+
+// CountRecords counts in the wrapped store and records the filter
+func (a *IndexAdvisor) CountRecords(filter Filter) (int, error) {
+	defer a.observe(filter, time.Now())
+	return a.Store.CountRecords(filter)
+}
+
+// Aggregate aggregates in the wrapped store and records the filter
+func (a *IndexAdvisor) Aggregate(filter Filter, groupBy string) (map[string]int, error) {
+	defer a.observe(filter, time.Now())
+	return a.Store.Aggregate(filter, groupBy)
+}
+
+// Suggestions returns the unindexed fields filtered on by at least minShare of the
+// recorded searches, most used first. A non-positive minShare selects
+// DefaultIndexAdvisorMinShare.
+func (a *IndexAdvisor) Suggestions(minShare float64) []IndexSuggestion {
+	if minShare <= 0 {
+		minShare = DefaultIndexAdvisorMinShare
+	}
+	indexed := make(map[string]bool)
+	if memory, ok := a.Store.(*MemoryStore); ok {
+		for _, field := range memory.IndexedFields() {
+			indexed[field] = true
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	suggestions := make([]IndexSuggestion, 0)
+	for field, usage := range a.fields {
+		share := float64(usage.searches) / float64(a.searches)
+		if indexed[field] || share < minShare {
+			continue
+		}
+		suggestions = append(suggestions, IndexSuggestion{
+			Field:       field,
+			Searches:    usage.searches,
+			Share:       share,
+			AvgDuration: usage.duration / time.Duration(usage.searches),
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Searches != suggestions[j].Searches {
+			return suggestions[i].Searches > suggestions[j].Searches
+		}
+		return suggestions[i].Field < suggestions[j].Field
+	})
+	return suggestions
+}
+
+// Apply creates the suggested indexes on the wrapped MemoryStore and returns the
+// fields that were indexed
+func (a *IndexAdvisor) Apply(minShare float64) ([]string, error) {
+	memory, ok := a.Store.(*MemoryStore)
+	if !ok {
+		return nil, errors.New("indexes can only be created on a MemoryStore")
+	}
+
+	fields := make([]string, 0)
+	for _, suggestion := range a.Suggestions(minShare) {
+		fields = append(fields, suggestion.Field)
+	}
+	if err := memory.AddIndexes(fields...); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// Reset forgets the recorded searches, e.g. after indexes were created
+func (a *IndexAdvisor) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.searches = 0
+	a.fields = make(map[string]*fieldUsage)
+}
+
+// observe records a search that started at the given time
+func (a *IndexAdvisor) observe(filter Filter, started time.Time) {
+	took := time.Since(started)
+	fields := make(map[string]bool)
+	indexableConditions(filter.RootGroup, fields)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.searches++
+	for field := range fields {
+		usage, ok := a.fields[field]
+		if !ok {
+			usage = &fieldUsage{}
+			a.fields[field] = usage
+		}
+		usage.searches++
+		usage.duration += took
+	}
+}
+
+// indexableConditions adds the fields of the group's conditions that an index could
+// answer to fields. Only AND groups are followed: an OR group narrows nothing unless
+// every alternative is indexed, and a NOT group never does.
+func indexableConditions(group FilterGroup, fields map[string]bool) {
+	if group.Operator != OpAnd {
+		return
+	}
+	for _, condition := range group.Conditions {
+		switch condition.Field {
+		case IndexCategory, IndexOwnerID:
+			if _, ok := condition.Value.(string); ok && condition.Operator == "=" {
+				fields[condition.Field] = true
+			}
+		case IndexTags:
+			if _, ok := condition.Value.(string); ok && condition.Operator == "CONTAINS" {
+				fields[condition.Field] = true
+			}
+		case IndexCreatedAt:
+			if _, ok := condition.Value.(time.Time); ok && comparisonOperators[condition.Operator] && condition.Operator != "!=" && condition.Operator != "CONTAINS" {
+				fields[condition.Field] = true
+			}
+		}
+	}
+	for _, subgroup := range group.Groups {
+		indexableConditions(subgroup, fields)
+	}
+}
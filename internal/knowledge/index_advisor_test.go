@@ -0,0 +1,72 @@
+package knowledge
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIndexAdvisor(t *testing.T) {
+	memory, err := NewIndexedMemoryStore(IndexCategory)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	memory.AddRecords(
+		Entry{ID: "a", Category: CategoryFact, OwnerID: "alice", Tags: []string{"backend"}, CreatedAt: time.Now()},
+		Entry{ID: "b", Category: CategoryDecision, OwnerID: "bob", Tags: []string{"frontend"}, CreatedAt: time.Now()},
+	)
+	advisor := NewIndexAdvisor(memory)
+
+	byOwner := Filter{RootGroup: FilterGroup{Operator: OpAnd, Conditions: []Condition{
+		{Field: "Category", Operator: "=", Value: CategoryFact},
+		{Field: "OwnerID", Operator: "=", Value: "alice"},
+	}}}
+	for i := 0; i < 8; i++ {
+		advisor.SearchRecords(byOwner)
+	}
+	byTag, _ := Query().Where("Tags", "CONTAINS", "backend").Build()
+	advisor.CountRecords(byTag)
+	// Conditions under OR cannot use an index
+	either, _ := Query().Or(Query().Where("OwnerID", "=", "bob"), Query().Where("OwnerID", "=", "carol")).Build()
+	advisor.Aggregate(either, "Category")
+
+	suggestions := advisor.Suggestions(0.05)
+	if len(suggestions) != 2 || suggestions[0].Field != IndexOwnerID || suggestions[1].Field != IndexTags {
+		t.Fatalf("Expected OwnerID then Tags, got %+v", suggestions)
+	}
+	if suggestions[0].Searches != 8 || suggestions[0].Share != 0.8 {
+		t.Errorf("Unexpected OwnerID suggestion: %+v", suggestions[0])
+	}
+
+	// A higher threshold drops less used fields
+	if suggestions := advisor.Suggestions(0.5); len(suggestions) != 1 {
+		t.Errorf("Expected only OwnerID above half of the searches, got %+v", suggestions)
+	}
+
+	fields, err := advisor.Apply(0.5)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if !reflect.DeepEqual(fields, []string{IndexOwnerID}) {
+		t.Errorf("Expected OwnerID to be indexed, got %v", fields)
+	}
+	if indexed := memory.IndexedFields(); !reflect.DeepEqual(indexed, []string{IndexCategory, IndexOwnerID}) {
+		t.Errorf("Unexpected indexed fields: %v", indexed)
+	}
+	if results, _ := advisor.SearchRecords(byOwner); len(results) != 1 || results[0].ID != "a" {
+		t.Errorf("Expected the new index to answer the search, got %v", results)
+	}
+
+	advisor.Reset()
+	if suggestions := advisor.Suggestions(0); len(suggestions) != 0 {
+		t.Errorf("Expected no suggestions after a reset, got %+v", suggestions)
+	}
+}
+
+func TestIndexAdvisorApplyRequiresMemoryStore(t *testing.T) {
+	memory, _ := NewMemoryStore()
+	advisor := NewIndexAdvisor(NewCachedStore(memory, 0, 0))
+	if _, err := advisor.Apply(0); err == nil {
+		t.Error("Expected error for a store that cannot be indexed")
+	}
+}
@@ -0,0 +1,195 @@
+package knowledge
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Citation integrity conventions
+const (
+	StatusApproved          = "approved"         // Metadata "status" of decisions that must keep their citations
+	TagSummary              = "summary"          // Tag of entries that summarize the records they reference
+	MetadataPurgedCitations = "purged_citations" // Comma-separated IDs of cited records that were purged without a summary
+)
+
+// ErrOrphanedDecision is returned when a purge would leave an approved decision citing
+// a record that no longer exists and has no summary to point to instead
+var ErrOrphanedDecision = errors.New("purge would orphan approved decision records")
+
+// CitationChange describes how a citation to a purged record was reconciled
+type CitationChange struct {
+	EntryID   string `json:"entryId"`             // Record holding the citation
+	PurgedID  string `json:"purgedId"`            // Purged record it cited
+	SummaryID string `json:"summaryId,omitempty"` // Summary it cites instead; empty if the citation was annotated
+}
+
+// PurgeResult is the outcome of PurgeWithCitations
+type PurgeResult struct {
+	Purged    []string         `json:"purged"`              // Records that were purged
+	Blocked   []string         `json:"blocked,omitempty"`   // Records kept because purging them would orphan approved decisions
+	Rewritten []CitationChange `json:"rewritten,omitempty"` // Citations now pointing to a summary
+	Annotated []CitationChange `json:"annotated,omitempty"` // Citations removed and recorded in MetadataPurgedCitations
+}
+
+// PurgeWithCitations permanently removes records, typically conversation messages that
+// fell out of retention, and reconciles citations to them in the remaining records.
+// A citation is rewritten to point to a summary of the purged record (an entry tagged
+// TagSummary that references it) or, if there is none, removed and recorded in the
+// citing entry's MetadataPurgedCitations.
+//
+// Unless force is set, records cited by approved decisions without a summary to point
+// to are not purged; they are listed in the result's Blocked and ErrOrphanedDecision is
+// returned after the other records were purged.
+func PurgeWithCitations(store Store, ids []string, force bool) (PurgeResult, error) {
+	result := PurgeResult{Purged: []string{}}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	// Soft-deleted records keep their citations; they are checked again if restored
+	records, err := store.SearchRecords(Filter{})
+	if err != nil {
+		return result, fmt.Errorf("failed to load records for citation check: %w", err)
+	}
+
+	purging := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		purging[id] = true
+	}
+
+	// Summaries that survive the purge, by the records they summarize
+	summaries := make(map[string]Entry)
+	for _, record := range records {
+		if purging[record.ID] || !hasTag(record, TagSummary) {
+			continue
+		}
+		for _, ref := range record.References {
+			if _, exists := summaries[ref.ID]; !exists {
+				summaries[ref.ID] = record
+			}
+		}
+	}
+
+	// Approved decisions block purges they would be orphaned by
+	if !force {
+		blocked := make(map[string]bool)
+		for _, record := range records {
+			if purging[record.ID] || !isApprovedDecision(record) {
+				continue
+			}
+			for _, ref := range record.References {
+				if _, hasSummary := summaries[ref.ID]; purging[ref.ID] && !hasSummary {
+					blocked[ref.ID] = true
+				}
+			}
+		}
+		for id := range blocked {
+			delete(purging, id)
+			result.Blocked = append(result.Blocked, id)
+		}
+		sort.Strings(result.Blocked)
+	}
+
+	// Reconcile citations of the remaining records
+	now := time.Now()
+	updates := make([]Entry, 0)
+	for _, record := range records {
+		if purging[record.ID] {
+			continue
+		}
+
+		changed := false
+		references := make([]Reference, 0, len(record.References))
+		annotated := make([]string, 0)
+		for _, ref := range record.References {
+			if !purging[ref.ID] {
+				references = append(references, ref)
+				continue
+			}
+			changed = true
+			if summary, ok := summaries[ref.ID]; ok && summary.ID != record.ID {
+				if !hasReference(references, summary.ID) {
+					references = append(references, Reference{ID: summary.ID, Type: summary.Category})
+				}
+				result.Rewritten = append(result.Rewritten, CitationChange{EntryID: record.ID, PurgedID: ref.ID, SummaryID: summary.ID})
+			} else {
+				annotated = append(annotated, ref.ID)
+				result.Annotated = append(result.Annotated, CitationChange{EntryID: record.ID, PurgedID: ref.ID})
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		record.References = references
+		if len(annotated) > 0 {
+			metadata := make(map[string]string, len(record.Metadata)+1)
+			for key, value := range record.Metadata {
+				metadata[key] = value
+			}
+			if previous := metadata[MetadataPurgedCitations]; previous != "" {
+				annotated = append(strings.Split(previous, ","), annotated...)
+			}
+			metadata[MetadataPurgedCitations] = strings.Join(annotated, ",")
+			record.Metadata = metadata
+		}
+		record.Provenance = append(record.Provenance, ProvenanceStep{
+			Origin:    OriginSystem,
+			ActorType: "system",
+			Timestamp: now,
+			Details:   map[string]string{"reason": "citation reconciliation"},
+		})
+		updates = append(updates, record)
+	}
+
+	if err := store.UpdateRecords(updates...); err != nil {
+		return result, fmt.Errorf("failed to update citing records: %w", err)
+	}
+
+	for _, id := range ids {
+		if !purging[id] {
+			continue
+		}
+		if err := store.PurgeRecord(id); err != nil {
+			return result, fmt.Errorf("failed to purge record %s: %w", id, err)
+		}
+		result.Purged = append(result.Purged, id)
+	}
+
+	if err := store.Flush(); err != nil {
+		return result, fmt.Errorf("failed to flush store after purge: %w", err)
+	}
+
+	if len(result.Blocked) > 0 {
+		return result, fmt.Errorf("%w: %s", ErrOrphanedDecision, strings.Join(result.Blocked, ", "))
+	}
+	return result, nil
+}
+
+// isApprovedDecision reports whether the record is a decision whose citations must be kept
+func isApprovedDecision(record Entry) bool {
+	return record.Category == CategoryDecision && record.Metadata["status"] == StatusApproved
+}
+
+// hasTag reports whether the record has the tag
+func hasTag(record Entry, tag string) bool {
+	for _, t := range record.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// hasReference reports whether the references contain the ID
+func hasReference(references []Reference, id string) bool {
+	for _, ref := range references {
+		if ref.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package knowledge
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newIntegrityTestStore(t *testing.T) *MemoryStore {
+	store, _ := NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	err := store.LoadRecords(
+		Entry{ID: "m1", Category: CategoryMessage, Content: []byte("We should ship on Friday")},
+		Entry{ID: "m2", Category: CategoryMessage, Content: []byte("Agreed, Friday it is")},
+		Entry{ID: "m3", Category: CategoryMessage, Content: []byte("Use Postgres")},
+		Entry{ID: "summary", Category: CategoryFact, Tags: []string{TagSummary},
+			References: []Reference{{ID: "m1", Type: CategoryMessage}, {ID: "m2", Type: CategoryMessage}}},
+		Entry{ID: "ship", Category: CategoryDecision, Metadata: map[string]string{"status": StatusApproved},
+			References: []Reference{{ID: "m1", Type: CategoryMessage}, {ID: "m2", Type: CategoryMessage}}},
+		Entry{ID: "db", Category: CategoryDecision, Metadata: map[string]string{"status": StatusApproved},
+			References: []Reference{{ID: "m3", Type: CategoryMessage}}},
+		Entry{ID: "note", Category: CategoryFact, References: []Reference{{ID: "m3", Type: CategoryMessage}}},
+	)
+	if err != nil {
+		t.Fatalf("Failed to load records: %v", err)
+	}
+	return store
+}
+
+func TestPurgeWithCitationsRewritesToSummary(t *testing.T) {
+	store := newIntegrityTestStore(t)
+
+	result, err := PurgeWithCitations(store, []string{"m1", "m2"}, false)
+	if err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	if len(result.Purged) != 2 {
+		t.Errorf("Expected 2 purged records, got %v", result.Purged)
+	}
+
+	ship, _ := store.GetRecord("ship")
+	if len(ship.References) != 1 || ship.References[0].ID != "summary" || ship.References[0].Type != CategoryFact {
+		t.Errorf("Expected decision to cite the summary once, got %+v", ship.References)
+	}
+	if last := ship.Provenance[len(ship.Provenance)-1]; last.Origin != OriginSystem || last.Details["reason"] != "citation reconciliation" {
+		t.Errorf("Expected reconciliation provenance, got %+v", last)
+	}
+
+	// The summary itself keeps a note of what it summarized
+	summary, _ := store.GetRecord("summary")
+	if len(summary.References) != 0 || summary.Metadata[MetadataPurgedCitations] != "m1,m2" {
+		t.Errorf("Expected summary citations to be annotated, got %+v %v", summary.References, summary.Metadata)
+	}
+}
+
+func TestPurgeWithCitationsBlocksOrphanedDecisions(t *testing.T) {
+	store := newIntegrityTestStore(t)
+
+	result, err := PurgeWithCitations(store, []string{"m1", "m3"}, false)
+	if !errors.Is(err, ErrOrphanedDecision) {
+		t.Fatalf("Expected ErrOrphanedDecision, got %v", err)
+	}
+	if len(result.Blocked) != 1 || result.Blocked[0] != "m3" {
+		t.Errorf("Expected m3 to be blocked, got %v", result.Blocked)
+	}
+	if len(result.Purged) != 1 || result.Purged[0] != "m1" {
+		t.Errorf("Expected only m1 to be purged, got %v", result.Purged)
+	}
+	if _, err := store.GetRecord("m3"); err != nil {
+		t.Error("Expected blocked record to be kept")
+	}
+	if note, _ := store.GetRecord("note"); len(note.References) != 1 {
+		t.Errorf("Expected citations of a blocked record to be kept, got %+v", note.References)
+	}
+}
+
+func TestPurgeWithCitationsForced(t *testing.T) {
+	store := newIntegrityTestStore(t)
+
+	result, err := PurgeWithCitations(store, []string{"m3"}, true)
+	if err != nil {
+		t.Fatalf("Forced purge failed: %v", err)
+	}
+	if len(result.Annotated) != 2 {
+		t.Errorf("Expected 2 annotated citations, got %+v", result.Annotated)
+	}
+	db, _ := store.GetRecord("db")
+	if len(db.References) != 0 || db.Metadata[MetadataPurgedCitations] != "m3" || db.Metadata["status"] != StatusApproved {
+		t.Errorf("Expected decision citation to be annotated, got %+v %v", db.References, db.Metadata)
+	}
+}
+
+func TestExpiryJanitorPurgeKeepsCitedRecords(t *testing.T) {
+	now := time.Now()
+	store := newIntegrityTestStore(t)
+	for _, id := range []string{"m1", "m3"} {
+		record, _ := store.GetRecord(id)
+		record.ExpiresAt = now.Add(-time.Minute)
+		store.UpdateRecord(record)
+	}
+
+	janitor := NewExpiryJanitor(store, time.Minute, ExpiryPurge)
+	janitor.now = func() time.Time { return now }
+	expired, err := janitor.ExpireNow()
+	if err != nil {
+		t.Fatalf("ExpireNow failed: %v", err)
+	}
+	if len(expired) != 1 || expired[0] != "m1" {
+		t.Errorf("Expected only m1 to expire, got %v", expired)
+	}
+}
@@ -39,23 +39,27 @@ type Reference struct {
 
 // Entry represents a single knowledge entry in the system
 type Entry struct {
-	ID          string            `json:"id" xml:"id" yaml:"id"`                            // Unique identifier
-	Category    string            `json:"category" xml:"category" yaml:"category"`          // High-level category: "fact", "message", "decision", "action"
-	ContentType string            `json:"contentType" xml:"contentType" yaml:"contentType"` // MIME type: "application/json", "text/plain", etc.
-	Content     []byte            `json:"content" xml:"content" yaml:"content"`             // The actual content in binary form
-	Importance  int               `json:"importance" xml:"importance" yaml:"importance"`    // Importance level: 1 (low) to 3 (high)
-	CreatedAt   time.Time         `json:"createdAt" xml:"createdAt" yaml:"createdAt"`       // When this knowledge was created
-	UpdatedAt   time.Time         `json:"updatedAt" xml:"updatedAt" yaml:"updatedAt"`       // When this knowledge was last modified
-	ExpiresAt   time.Time         `json:"expiresAt" xml:"expiresAt" yaml:"expiresAt"`       // When this knowledge will expire
-	SourceID    string            `json:"sourceId" xml:"sourceId" yaml:"sourceId"`          // Where this knowledge came from
-	SourceType  string            `json:"sourceType" xml:"sourceType" yaml:"sourceType"`    // Type of source: "chat", "api", "observation", etc.
-	OwnerID     string            `json:"ownerId" xml:"ownerId" yaml:"ownerId"`             // Who created/owns this knowledge
-	OwnerType   string            `json:"ownerType" xml:"ownerType" yaml:"ownerType"`       // Type of owner: "agent", "human", "company", "product", "tool"
-	SubjectIDs  []string          `json:"subjectIds" xml:"subjectIds" yaml:"subjectIds"`    // Who/what this knowledge is about (can be multiple)
-	SubjectType string            `json:"subjectType" xml:"subjectType" yaml:"subjectType"` // Type of subject: "human", "project", "company", etc.
-	Tags        []string          `json:"tags" xml:"tags" yaml:"tags"`                      // Quick categorization for indexing/retrieval
-	References  []Reference       `json:"references" xml:"references" yaml:"references"`    // Other knowledge IDs this knowledge references
-	Metadata    map[string]string `json:"metadata" xml:"metadata" yaml:"metadata"`          // Flexible key-value pairs for additional context
+	ID             string            `json:"id" xml:"id" yaml:"id"`                                      // Unique identifier
+	Category       string            `json:"category" xml:"category" yaml:"category"`                    // High-level category: "fact", "message", "decision", "action"
+	ContentType    string            `json:"contentType" xml:"contentType" yaml:"contentType"`           // MIME type: "application/json", "text/plain", etc.
+	Content        []byte            `json:"content" xml:"content" yaml:"content"`                       // The actual content in binary form
+	Importance     int               `json:"importance" xml:"importance" yaml:"importance"`              // Importance level: 1 (low) to 3 (high)
+	CreatedAt      time.Time         `json:"createdAt" xml:"createdAt" yaml:"createdAt"`                 // When this knowledge was created
+	UpdatedAt      time.Time         `json:"updatedAt" xml:"updatedAt" yaml:"updatedAt"`                 // When this knowledge was last modified
+	ExpiresAt      time.Time         `json:"expiresAt" xml:"expiresAt" yaml:"expiresAt"`                 // When this knowledge will expire
+	SourceID       string            `json:"sourceId" xml:"sourceId" yaml:"sourceId"`                    // Where this knowledge came from
+	SourceType     string            `json:"sourceType" xml:"sourceType" yaml:"sourceType"`              // Type of source: "chat", "api", "observation", etc.
+	OwnerID        string            `json:"ownerId" xml:"ownerId" yaml:"ownerId"`                       // Who created/owns this knowledge
+	OwnerType      string            `json:"ownerType" xml:"ownerType" yaml:"ownerType"`                 // Type of owner: "agent", "human", "company", "product", "tool"
+	SubjectIDs     []string          `json:"subjectIds" xml:"subjectIds" yaml:"subjectIds"`              // Who/what this knowledge is about (can be multiple)
+	SubjectType    string            `json:"subjectType" xml:"subjectType" yaml:"subjectType"`           // Type of subject: "human", "project", "company", etc.
+	Tags           []string          `json:"tags" xml:"tags" yaml:"tags"`                                // Quick categorization for indexing/retrieval
+	References     []Reference       `json:"references" xml:"references" yaml:"references"`              // Other knowledge IDs this knowledge references
+	Metadata       map[string]string `json:"metadata" xml:"metadata" yaml:"metadata"`                    // Flexible key-value pairs for additional context
+	Provenance     []ProvenanceStep  `json:"provenance" xml:"provenance" yaml:"provenance"`              // How this knowledge was created and changed, oldest first
+	Embedding      []float32         `json:"embedding,omitempty" xml:"embedding" yaml:"embedding"`       // Optional vector representation of the content for similarity search
+	AccessCount    int               `json:"accessCount,omitempty" xml:"accessCount" yaml:"accessCount"` // How often retrieval surfaced this knowledge, see RecordAccess
+	LastAccessedAt time.Time         `json:"lastAccessedAt" xml:"lastAccessedAt" yaml:"lastAccessedAt"`  // When retrieval last surfaced this knowledge
 }
 
 // FilterOperator defines the type of logical operation to perform
@@ -95,16 +99,25 @@ type Filter struct {
 
 // Store interface for knowledge storage
 type Store interface {
-	AddRecord(record Entry) error                 // Add a record ot the storage
-	GetRecord(id string) (Entry, error)           // Retrieve record by ID
-	UpdateRecord(record Entry) error              // Update record
-	DeleteRecord(id string) error                 // Delete a record, this is soft delete
-	RestoreRecord(id string) error                // Un-delete a record
-	PurgeRecord(id string) error                  // Permanent deletion
-	SearchRecords(filter Filter) ([]Entry, error) // Generic, full search
-	LoadRecords(records ...Entry) error           // Bulk load records, updating existing ones and adding new ones
-	Open() error                                  // Open/Load datastore
-	Flush() error                                 // Write any pending data to the storage, no-op in some providers such as knowledge
-	Close() error                                 // Closes storage (files/db connections)
-	Info() (map[string]string, error)             // Provides implementation specific information
+	AddRecord(record Entry) error                                              // Add a record ot the storage
+	AddRecords(records ...Entry) error                                         // Add a batch of records; all or none are added
+	GetRecord(id string) (Entry, error)                                        // Retrieve record by ID
+	UpdateRecord(record Entry) error                                           // Update record
+	UpdateRecords(records ...Entry) error                                      // Update a batch of existing records; all or none are updated
+	DeleteRecord(id string) error                                              // Delete a record, this is soft delete
+	DeleteRecords(ids ...string) error                                         // Soft delete a batch of records; all or none are deleted
+	RestoreRecord(id string) error                                             // Un-delete a record
+	PurgeRecord(id string) error                                               // Permanent deletion
+	SearchRecords(filter Filter) ([]Entry, error)                              // Generic, full search
+	CountRecords(filter Filter) (int, error)                                   // Number of matching records, ignoring limit and offset
+	Aggregate(filter Filter, groupBy string) (map[string]int, error)           // Number of matching records per Category, OwnerID, tag, "Metadata.<key>", ...
+	FullTextSearch(query string, opts ...SearchOption) ([]SearchResult, error) // Ranked natural-language search over content, tags and metadata
+	SearchSimilar(vector []float32, topK int) ([]SearchResult, error)          // Records with the most similar embeddings, best first
+	RecordAccess(accesses ...Access) error                                     // Add retrieval counts without changing UpdatedAt or provenance
+	Watch(filter Filter) (<-chan ChangeEvent, CancelFunc)                      // Stream adds, updates, deletions, restores and purges of matching records
+	LoadRecords(records ...Entry) error                                        // Bulk load records, updating existing ones and adding new ones
+	Open() error                                                               // Open/Load datastore
+	Flush() error                                                              // Write any pending data to the storage, no-op in some providers such as knowledge
+	Close() error                                                              // Closes storage (files/db connections)
+	Info() (map[string]string, error)                                          // Provides implementation specific information
 }
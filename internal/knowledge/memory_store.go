@@ -9,12 +9,18 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"goproduct/internal/embeddings"
 )
 
 // MemoryStore implements Store interface using in-memory storage
 type MemoryStore struct {
 	records     map[string]Entry
 	deletedRecs map[string]Entry
+	index       *invertedIndex    // Full-text index over active and deleted records
+	vectors     *embeddings.Index // Embedding index over active and deleted records
+	feed        *changeFeed       // Watchers of record changes
+	fields      *fieldIndex       // Optional secondary indexes over active and deleted records, nil when off
 	mu          sync.RWMutex
 }
 
@@ -23,10 +29,51 @@ func NewMemoryStore() (*MemoryStore, error) {
 	store := &MemoryStore{
 		records:     make(map[string]Entry),
 		deletedRecs: make(map[string]Entry),
+		index:       newInvertedIndex(),
+		vectors:     embeddings.NewIndex(),
+		feed:        newChangeFeed(),
 	}
 	return store, nil
 }
 
+// NewIndexedMemoryStore creates an in-memory knowledge store that keeps secondary indexes
+// on the given fields (see IndexableFields; all of them if none are given). SearchRecords
+// uses them to avoid scanning every record, at the cost of extra memory and slightly
+// slower writes.
+func NewIndexedMemoryStore(fields ...string) (*MemoryStore, error) {
+	index, err := newFieldIndex(fields...)
+	if err != nil {
+		return nil, err
+	}
+	store, _ := NewMemoryStore()
+	store.fields = index
+	return store, nil
+}
+
+// AddIndexes starts keeping secondary indexes on the fields, in addition to the ones
+// already kept, and builds them over the current records
+func (m *MemoryStore) AddIndexes(fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index, err := newFieldIndex(append(m.fields.indexedFields(), fields...)...)
+	if err != nil {
+		return err
+	}
+	m.fields = index.rebuild(m.records, m.deletedRecs)
+	return nil
+}
+
+// IndexedFields returns the fields with secondary indexes in sorted order
+func (m *MemoryStore) IndexedFields() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.fields.indexedFields()
+}
+
 // Open initializes the memory store
 func (m *MemoryStore) Open() error {
 	m.mu.Lock()
@@ -39,9 +86,19 @@ func (m *MemoryStore) Open() error {
 	if m.deletedRecs == nil {
 		m.deletedRecs = make(map[string]Entry)
 	}
+	m.index = buildInvertedIndex(m.records, m.deletedRecs)
+	m.vectors = buildVectorIndex(m.records, m.deletedRecs)
+	m.fields = m.fields.rebuild(m.records, m.deletedRecs)
 	return nil
 }
 
+// IsOpen reports whether the store holds its records, from Open to Close
+func (m *MemoryStore) IsOpen() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.records != nil
+}
+
 // Close releases resources (no-op for memory store)
 func (m *MemoryStore) Close() error {
 	m.mu.Lock()
@@ -50,6 +107,12 @@ func (m *MemoryStore) Close() error {
 	// Clear data
 	m.records = nil
 	m.deletedRecs = nil
+	m.index = nil
+	m.vectors = nil
+	m.fields = m.fields.rebuild(nil, nil) // Keep the indexed fields for a reopen
+
+	// End all watches so consumers stop reading
+	m.feed.closeAll()
 	return nil
 }
 
@@ -74,6 +137,11 @@ func (m *MemoryStore) AddRecord(record Entry) error {
 		return fmt.Errorf("knowledge record with ID %s already exists", record.ID)
 	}
 
+	// Index the embedding first so an invalid vector leaves the store unchanged
+	if err := indexEmbedding(m.vectors, record); err != nil {
+		return err
+	}
+
 	// Set timestamps if not set
 	now := time.Now()
 	if record.CreatedAt.IsZero() {
@@ -82,9 +150,13 @@ func (m *MemoryStore) AddRecord(record Entry) error {
 	if record.UpdatedAt.IsZero() {
 		record.UpdatedAt = now
 	}
+	record.Provenance = recordProvenance(nil, record, ProvenanceOpAdd, now)
 
 	// Add to records
 	m.records[record.ID] = record
+	m.index.add(record)
+	m.fields.add(record)
+	m.feed.publish(ChangeAdd, record, m.matchesFilter)
 	return nil
 }
 
@@ -107,17 +179,30 @@ func (m *MemoryStore) UpdateRecord(record Entry) error {
 	defer m.mu.Unlock()
 
 	// Check if record exists
-	if _, exists := m.records[record.ID]; !exists {
+	existing, exists := m.records[record.ID]
+	if !exists {
 		return fmt.Errorf("knowledge record with ID %s not found", record.ID)
 	}
 
+	// Index the embedding first so an invalid vector leaves the store unchanged
+	if err := indexEmbedding(m.vectors, record); err != nil {
+		return err
+	}
+
 	// Update timestamp
+	now := time.Now()
 	if record.UpdatedAt.IsZero() {
-		record.UpdatedAt = time.Now()
+		record.UpdatedAt = now
 	}
+	record = keepAccessStats(existing, record)
+	record.Provenance = recordProvenance(existing.Provenance, record, ProvenanceOpUpdate, now)
+	record = withContentDiff(existing, record)
 
 	// Update record
 	m.records[record.ID] = record
+	m.index.add(record)
+	m.fields.add(record)
+	m.feed.publish(ChangeUpdate, record, m.matchesFilter)
 	return nil
 }
 
@@ -135,6 +220,133 @@ func (m *MemoryStore) DeleteRecord(id string) error {
 	// Move to deleted records
 	m.deletedRecs[id] = record
 	delete(m.records, id)
+	m.feed.publish(ChangeDelete, record, m.matchesFilter)
+	return nil
+}
+
+// AddRecords adds a batch of records under a single lock. Either all records are added
+// or, if any of them is invalid or already exists, none are.
+func (m *MemoryStore) AddRecords(records ...Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	// Validate the whole batch before changing anything
+	if err := validateBatchIDs(recordIDs(records)); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if _, exists := m.records[record.ID]; exists {
+			return fmt.Errorf("knowledge record with ID %s already exists", record.ID)
+		}
+	}
+	if err := validateEmbeddings(m.vectors, records); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, record := range records {
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = now
+		}
+		if record.UpdatedAt.IsZero() {
+			record.UpdatedAt = now
+		}
+		record.Provenance = recordProvenance(nil, record, ProvenanceOpAdd, now)
+
+		m.records[record.ID] = record
+		m.index.add(record)
+		m.fields.add(record)
+		indexEmbedding(m.vectors, record) // Already validated
+		m.feed.publish(ChangeAdd, record, m.matchesFilter)
+	}
+	return nil
+}
+
+// UpdateRecords updates a batch of existing records under a single lock. Either all
+// records are updated or, if any of them is invalid or missing, none are.
+func (m *MemoryStore) UpdateRecords(records ...Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	// Validate the whole batch before changing anything
+	if err := validateBatchIDs(recordIDs(records)); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if _, exists := m.records[record.ID]; !exists {
+			return fmt.Errorf("knowledge record with ID %s not found", record.ID)
+		}
+	}
+	if err := validateEmbeddings(m.vectors, records); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, record := range records {
+		if record.UpdatedAt.IsZero() {
+			record.UpdatedAt = now
+		}
+		record = keepAccessStats(m.records[record.ID], record)
+		record.Provenance = recordProvenance(m.records[record.ID].Provenance, record, ProvenanceOpUpdate, now)
+		record = withContentDiff(m.records[record.ID], record)
+
+		m.records[record.ID] = record
+		m.index.add(record)
+		m.fields.add(record)
+		indexEmbedding(m.vectors, record) // Already validated
+		m.feed.publish(ChangeUpdate, record, m.matchesFilter)
+	}
+	return nil
+}
+
+// DeleteRecords soft-deletes a batch of records under a single lock. Either all records
+// are deleted or, if any of them is missing, none are.
+func (m *MemoryStore) DeleteRecords(ids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// Validate the whole batch before changing anything
+	if err := validateBatchIDs(ids); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, exists := m.records[id]; !exists {
+			return fmt.Errorf("knowledge record with ID %s not found", id)
+		}
+	}
+
+	for _, id := range ids {
+		record := m.records[id]
+		m.deletedRecs[id] = record
+		delete(m.records, id)
+		m.feed.publish(ChangeDelete, record, m.matchesFilter)
+	}
+	return nil
+}
+
+// RecordAccess adds retrieval counts to records without changing UpdatedAt or provenance.
+// Accesses to records that no longer exist are ignored.
+func (m *MemoryStore) RecordAccess(accesses ...Access) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, access := range accesses {
+		if record, exists := m.records[access.ID]; exists {
+			m.records[access.ID] = applyAccess(record, access)
+		}
+	}
 	return nil
 }
 
@@ -150,8 +362,10 @@ func (m *MemoryStore) RestoreRecord(id string) error {
 	}
 
 	// Move to active records
+	record.Provenance = recordProvenance(record.Provenance, record, ProvenanceOpRestore, time.Now())
 	m.records[id] = record
 	delete(m.deletedRecs, id)
+	m.feed.publish(ChangeRestore, record, m.matchesFilter)
 	return nil
 }
 
@@ -161,8 +375,8 @@ func (m *MemoryStore) PurgeRecord(id string) error {
 	defer m.mu.Unlock()
 
 	// Check if record exists in either active or deleted records
-	_, existsActive := m.records[id]
-	_, existsDeleted := m.deletedRecs[id]
+	activeRecord, existsActive := m.records[id]
+	deletedRecord, existsDeleted := m.deletedRecs[id]
 
 	if !existsActive && !existsDeleted {
 		return fmt.Errorf("knowledge record with ID %s not found", id)
@@ -171,9 +385,14 @@ func (m *MemoryStore) PurgeRecord(id string) error {
 	// Remove from appropriate map
 	if existsActive {
 		delete(m.records, id)
+		m.feed.publish(ChangePurge, activeRecord, m.matchesFilter)
 	} else {
 		delete(m.deletedRecs, id)
+		m.feed.publish(ChangePurge, deletedRecord, m.matchesFilter)
 	}
+	m.index.remove(id)
+	m.fields.remove(id)
+	m.vectors.Remove(id)
 	return nil
 }
 
@@ -184,6 +403,14 @@ func (m *MemoryStore) SearchRecords(filter Filter) ([]Entry, error) {
 
 	results := make([]Entry, 0)
 
+	// Narrow the search to the records the indexes allow to match
+	if filter.RootGroup.Operator != "" {
+		if candidates, ok := m.fields.candidates(filter.RootGroup); ok {
+			results = m.searchCandidates(candidates, filter)
+			return m.orderAndPage(results, filter), nil
+		}
+	}
+
 	// Process active records first (unless we only want deleted records)
 	if !filter.OnlyDeleted {
 		for _, record := range m.records {
@@ -210,6 +437,31 @@ func (m *MemoryStore) SearchRecords(filter Filter) ([]Entry, error) {
 		}
 	}
 
+	return m.orderAndPage(results, filter), nil
+}
+
+// searchCandidates evaluates the filter on the candidate records only
+func (m *MemoryStore) searchCandidates(candidates idSet, filter Filter) []Entry {
+	results := make([]Entry, 0, len(candidates))
+	for id := range candidates {
+		record, active := m.records[id]
+		if !active {
+			var deleted bool
+			if record, deleted = m.deletedRecs[id]; !deleted || !(filter.IncludeDeleted || filter.OnlyDeleted) {
+				continue
+			}
+		} else if filter.OnlyDeleted {
+			continue
+		}
+		if m.matchesFilter(record, filter.RootGroup) {
+			results = append(results, record)
+		}
+	}
+	return results
+}
+
+// orderAndPage sorts the results and applies the filter's limit and offset
+func (m *MemoryStore) orderAndPage(results []Entry, filter Filter) []Entry {
 	// Sort results if order is specified
 	if filter.OrderBy != "" {
 		m.sortRecords(results, filter.OrderBy, filter.OrderDir)
@@ -231,7 +483,100 @@ func (m *MemoryStore) SearchRecords(filter Filter) ([]Entry, error) {
 		results = results[start:end]
 	}
 
-	return results, nil
+	return results
+}
+
+// CountRecords returns the number of records matching the filter, ignoring its limit and offset
+func (m *MemoryStore) CountRecords(filter Filter) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count := 0
+	m.eachMatch(filter, func(Entry) { count++ })
+	return count, nil
+}
+
+// Aggregate counts the records matching the filter per value of the groupBy field,
+// ignoring the filter's limit and offset. See GroupByTags for grouping by tag and use
+// "Metadata.<key>" to group by a metadata value.
+func (m *MemoryStore) Aggregate(filter Filter, groupBy string) (map[string]int, error) {
+	if err := validateGroupBy(groupBy); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+	m.eachMatch(filter, func(record Entry) {
+		for _, key := range groupKeys(record, groupBy) {
+			counts[key]++
+		}
+	})
+	return counts, nil
+}
+
+// eachMatch calls fn for every record matching the filter, without copying them into a
+// result set (must be called with lock held)
+func (m *MemoryStore) eachMatch(filter Filter, fn func(record Entry)) {
+	if filter.RootGroup.Operator != "" {
+		if candidates, ok := m.fields.candidates(filter.RootGroup); ok {
+			for _, record := range m.searchCandidates(candidates, filter) {
+				fn(record)
+			}
+			return
+		}
+	}
+
+	if !filter.OnlyDeleted {
+		for _, record := range m.records {
+			if filter.RootGroup.Operator == "" || m.matchesFilter(record, filter.RootGroup) {
+				fn(record)
+			}
+		}
+	}
+	if filter.IncludeDeleted || filter.OnlyDeleted {
+		for _, record := range m.deletedRecs {
+			if filter.RootGroup.Operator == "" || m.matchesFilter(record, filter.RootGroup) {
+				fn(record)
+			}
+		}
+	}
+}
+
+// FullTextSearch returns records matching the natural-language query, most relevant first
+func (m *MemoryStore) FullTextSearch(query string, opts ...SearchOption) ([]SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	options := applySearchOptions(opts)
+	scores := m.index.search(query, options.MatchAll)
+	return rankSearchResults(scores, func(id string) (Entry, bool, bool) {
+		if record, exists := m.records[id]; exists {
+			return record, false, true
+		}
+		record, exists := m.deletedRecs[id]
+		return record, true, exists
+	}, options), nil
+}
+
+// SearchSimilar returns up to topK active records whose embeddings are most similar to the vector
+func (m *MemoryStore) SearchSimilar(vector []float32, topK int) ([]SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return searchSimilar(m.vectors, vector, topK, func(id string) (Entry, bool) {
+		record, exists := m.records[id]
+		return record, exists
+	})
+}
+
+// Watch streams changes to records matching the filter's conditions until the returned
+// CancelFunc is called or the store is closed. Deletions and purges are delivered with
+// the removed record. Slow consumers lose the oldest events rather than blocking writes;
+// see ChangeEvent.Missed.
+func (m *MemoryStore) Watch(filter Filter) (<-chan ChangeEvent, CancelFunc) {
+	return m.feed.watch(filter)
 }
 
 // matchesFilter checks if a record matches the filter group
@@ -316,6 +661,11 @@ func (m *MemoryStore) matchesCondition(record Entry, condition Condition) bool {
 		return m.matchesMetadata(record.Metadata, condition)
 	}
 
+	// Special handling for provenance chains
+	if condition.Field == "Provenance" {
+		return matchesProvenance(record.Provenance, condition)
+	}
+
 	// Special handling for time fields - direct comparison without reflection
 	switch condition.Field {
 	case "CreatedAt":
@@ -369,6 +719,13 @@ func (m *MemoryStore) matchesCondition(record Entry, condition Condition) bool {
 func (m *MemoryStore) matchesMetadata(metadata map[string]string, condition Condition) bool {
 	// Condition value should be a map for metadata comparison
 	metaCondition, ok := condition.Value.(map[string]interface{})
+	if pairs, isStrings := condition.Value.(map[string]string); isStrings {
+		// As used by FileStore and the query builder
+		metaCondition, ok = make(map[string]interface{}, len(pairs)), true
+		for key, value := range pairs {
+			metaCondition[key] = value
+		}
+	}
 	if !ok {
 		// Try string key as direct lookup
 		if key, ok := condition.Value.(string); ok {
@@ -598,25 +955,20 @@ func (m *MemoryStore) LoadRecords(records ...Entry) error {
 	}
 
 	// First validate that all records have IDs and there are no duplicates in the input
-	seenIDs := make(map[string]bool)
-	for i, record := range records {
-		// Validate record has an ID
-		if record.ID == "" {
-			return fmt.Errorf("record at index %d must have an ID", i)
-		}
+	if err := validateBatchIDs(recordIDs(records)); err != nil {
+		return err
+	}
 
-		// Check for duplicate IDs in the input
-		if seenIDs[record.ID] {
-			return fmt.Errorf("duplicate record ID found in input: %s", record.ID)
-		}
-		seenIDs[record.ID] = true
+	// Validate embeddings against the index and each other before changing anything
+	if err := validateEmbeddings(m.vectors, records); err != nil {
+		return err
 	}
 
 	// Process all records (add new ones, update existing ones)
 	now := time.Now()
 	for _, record := range records {
 		// Check if record exists (update) or not (add)
-		_, exists := m.records[record.ID]
+		existing, exists := m.records[record.ID]
 
 		// Set timestamps appropriately
 		if !exists {
@@ -630,9 +982,21 @@ func (m *MemoryStore) LoadRecords(records ...Entry) error {
 		if record.UpdatedAt.IsZero() {
 			record.UpdatedAt = now
 		}
+		record.Provenance = recordProvenance(existing.Provenance, record, ProvenanceOpLoad, now)
+		if exists {
+			record = withContentDiff(existing, record)
+		}
 
 		// Store the record (add or update)
 		m.records[record.ID] = record
+		m.index.add(record)
+		m.fields.add(record)
+		indexEmbedding(m.vectors, record) // Already validated
+		if exists {
+			m.feed.publish(ChangeUpdate, record, m.matchesFilter)
+		} else {
+			m.feed.publish(ChangeAdd, record, m.matchesFilter)
+		}
 	}
 
 	return nil
@@ -649,7 +1013,11 @@ func (m *MemoryStore) Info() (map[string]string, error) {
 	info["implementation"] = "MemoryStore"
 	info["record_count"] = fmt.Sprintf("%d", len(m.records))
 	info["deleted_count"] = fmt.Sprintf("%d", len(m.deletedRecs))
+	info["access_count"] = fmt.Sprintf("%d", totalAccessCount(m.records))
 	info["persistent"] = "false"
+	if m.fields != nil {
+		info["indexed_fields"] = strings.Join(m.fields.indexedFields(), ",")
+	}
 
 	return info, nil
 }
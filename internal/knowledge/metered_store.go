@@ -0,0 +1,163 @@
+package knowledge
+
+import (
+	"time"
+
+	"goproduct/internal/metrics"
+)
+
+// Metrics of the knowledge stores
+var (
+	storeLatency = metrics.Default().Histogram("store_operation_seconds",
+		"Latency of knowledge store operations, by store and operation", metrics.DefaultBuckets, "store", "operation")
+	storeErrors = metrics.Default().Counter("store_operation_errors_total",
+		"Knowledge store operations that failed, by store and operation", "store", "operation")
+)
+
+// MeteredStore wraps a store, recording the latency and failures of its operations in
+// the default metrics registry under the store's name. Watches are passed through
+// unmeasured.
+type MeteredStore struct {
+	Store
+	name string
+}
+
+// NewMeteredStore wraps the store, labelling its metrics with the name, e.g. the backend
+func NewMeteredStore(store Store, name string) *MeteredStore {
+	return &MeteredStore{Store: store, name: name}
+}
+
+// Unwrap returns the wrapped store
+func (m *MeteredStore) Unwrap() Store {
+	return m.Store
+}
+
+// IsOpen reports whether the wrapped store is open, true if it cannot tell
+func (m *MeteredStore) IsOpen() bool {
+	if opener, ok := m.Store.(interface{ IsOpen() bool }); ok {
+		return opener.IsOpen()
+	}
+	return true
+}
+
+// observe records an operation started at start, once it has returned err
+func (m *MeteredStore) observe(operation string, start time.Time, err *error) {
+	storeLatency.ObserveSince(start, m.name, operation)
+	if *err != nil {
+		storeErrors.Inc(m.name, operation)
+	}
+}
+
+// AddRecord adds the record in the wrapped store, timing it
+func (m *MeteredStore) AddRecord(record Entry) (err error) {
+	defer m.observe("add", time.Now(), &err)
+	return m.Store.AddRecord(record)
+}
+
+// AddRecords adds the records in the wrapped store, timing it
+func (m *MeteredStore) AddRecords(records ...Entry) (err error) {
+	defer m.observe("add_batch", time.Now(), &err)
+	return m.Store.AddRecords(records...)
+}
+
+// GetRecord reads the record in the wrapped store, timing it
+func (m *MeteredStore) GetRecord(id string) (record Entry, err error) {
+	defer m.observe("get", time.Now(), &err)
+	return m.Store.GetRecord(id)
+}
+
+// UpdateRecord updates the record in the wrapped store, timing it
+func (m *MeteredStore) UpdateRecord(record Entry) (err error) {
+	defer m.observe("update", time.Now(), &err)
+	return m.Store.UpdateRecord(record)
+}
+
+// UpdateRecords updates the records in the wrapped store, timing it
+func (m *MeteredStore) UpdateRecords(records ...Entry) (err error) {
+	defer m.observe("update_batch", time.Now(), &err)
+	return m.Store.UpdateRecords(records...)
+}
+
+// DeleteRecord soft-deletes the record in the wrapped store, timing it
+func (m *MeteredStore) DeleteRecord(id string) (err error) {
+	defer m.observe("delete", time.Now(), &err)
+	return m.Store.DeleteRecord(id)
+}
+
+// DeleteRecords soft-deletes the records in the wrapped store, timing it
+func (m *MeteredStore) DeleteRecords(ids ...string) (err error) {
+	defer m.observe("delete_batch", time.Now(), &err)
+	return m.Store.DeleteRecords(ids...)
+}
+
+// RestoreRecord restores the soft-deleted record in the wrapped store, timing it
+func (m *MeteredStore) RestoreRecord(id string) (err error) {
+	defer m.observe("restore", time.Now(), &err)
+	return m.Store.RestoreRecord(id)
+}
+
+// PurgeRecord removes the record for good in the wrapped store, timing it
+func (m *MeteredStore) PurgeRecord(id string) (err error) {
+	defer m.observe("purge", time.Now(), &err)
+	return m.Store.PurgeRecord(id)
+}
+
+// SearchRecords returns the records matching the filter in the wrapped store, timing it
+func (m *MeteredStore) SearchRecords(filter Filter) (records []Entry, err error) {
+	defer m.observe("search", time.Now(), &err)
+	return m.Store.SearchRecords(filter)
+}
+
+// CountRecords counts the records matching the filter in the wrapped store, timing it
+func (m *MeteredStore) CountRecords(filter Filter) (count int, err error) {
+	defer m.observe("count", time.Now(), &err)
+	return m.Store.CountRecords(filter)
+}
+
+// Aggregate counts the records matching the filter by a field in the wrapped store, timing it
+func (m *MeteredStore) Aggregate(filter Filter, groupBy string) (counts map[string]int, err error) {
+	defer m.observe("aggregate", time.Now(), &err)
+	return m.Store.Aggregate(filter, groupBy)
+}
+
+// FullTextSearch searches the records by text in the wrapped store, timing it
+func (m *MeteredStore) FullTextSearch(query string, opts ...SearchOption) (results []SearchResult, err error) {
+	defer m.observe("full_text_search", time.Now(), &err)
+	return m.Store.FullTextSearch(query, opts...)
+}
+
+// SearchSimilar searches the records by vector in the wrapped store, timing it
+func (m *MeteredStore) SearchSimilar(vector []float32, topK int) (results []SearchResult, err error) {
+	defer m.observe("search_similar", time.Now(), &err)
+	return m.Store.SearchSimilar(vector, topK)
+}
+
+// RecordAccess records reads of the records in the wrapped store, timing it
+func (m *MeteredStore) RecordAccess(accesses ...Access) (err error) {
+	defer m.observe("record_access", time.Now(), &err)
+	return m.Store.RecordAccess(accesses...)
+}
+
+// LoadRecords loads the records as they are in the wrapped store, timing it
+func (m *MeteredStore) LoadRecords(records ...Entry) (err error) {
+	defer m.observe("load", time.Now(), &err)
+	return m.Store.LoadRecords(records...)
+}
+
+// Open opens the wrapped store, timing it
+func (m *MeteredStore) Open() (err error) {
+	defer m.observe("open", time.Now(), &err)
+	return m.Store.Open()
+}
+
+// Flush writes the pending changes of the wrapped store, timing it
+func (m *MeteredStore) Flush() (err error) {
+	defer m.observe("flush", time.Now(), &err)
+	return m.Store.Flush()
+}
+
+// Close closes the wrapped store, timing it
+func (m *MeteredStore) Close() (err error) {
+	defer m.observe("close", time.Now(), &err)
+	return m.Store.Close()
+}
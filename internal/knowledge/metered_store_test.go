@@ -0,0 +1,30 @@
+package knowledge
+
+import "testing"
+
+func TestMeteredStoreRecordsOperations(t *testing.T) {
+	memory, _ := NewMemoryStore()
+	store := NewMeteredStore(memory, "test")
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	adds := storeLatency.Count("test", "add")
+	failures := storeErrors.Value("test", "get")
+	if err := store.AddRecord(Entry{ID: "a", Content: []byte("alpha")}); err != nil {
+		t.Fatalf("Failed to add record: %v", err)
+	}
+	if _, err := store.GetRecord("missing"); err == nil {
+		t.Fatal("Expected an error reading a missing record")
+	}
+
+	if got := storeLatency.Count("test", "add"); got != adds+1 {
+		t.Errorf("Expected one more timed add, got %d after %d", got, adds)
+	}
+	if got := storeErrors.Value("test", "get"); got != failures+1 {
+		t.Errorf("Expected one more failed get, got %v after %v", got, failures)
+	}
+	if store.Unwrap() != memory || !store.IsOpen() {
+		t.Errorf("Expected the wrapped store, open")
+	}
+}
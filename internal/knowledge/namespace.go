@@ -0,0 +1,380 @@
+package knowledge
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MetadataNamespace is the metadata key holding the namespace of a record in the
+// backing store of a NamespacedStore
+const MetadataNamespace = "namespace"
+
+// NamespaceSeparator separates the namespace from the record ID in the backing store
+const NamespaceSeparator = "/"
+
+// namespaceOverfetch is how many more results than requested are fetched from the
+// backing store for searches that cannot be scoped by a filter
+const namespaceOverfetch = 4
+
+// NamespacedStore gives one agent an isolated set of records in a store shared with
+// others. Records are stored with their ID prefixed by the namespace and the namespace
+// in their metadata; both are removed again before records are returned, so callers
+// see the records exactly as they wrote them. Records of other namespaces are never
+// returned, counted or changed.
+//
+// The backing store is shared: Open and Close are left to its owner, while Flush
+// flushes it for every namespace.
+type NamespacedStore struct {
+	store     Store
+	namespace string
+	prefix    string
+}
+
+// NewNamespacedStore returns the namespace of the shared store
+func NewNamespacedStore(store Store, namespace string) (*NamespacedStore, error) {
+	if namespace == "" {
+		return nil, errors.New("namespace cannot be empty")
+	}
+	if strings.Contains(namespace, NamespaceSeparator) {
+		return nil, fmt.Errorf("namespace %q cannot contain %q", namespace, NamespaceSeparator)
+	}
+	return &NamespacedStore{store: store, namespace: namespace, prefix: namespace + NamespaceSeparator}, nil
+}
+
+// Namespace returns the name of the namespace
+func (n *NamespacedStore) Namespace() string {
+	return n.namespace
+}
+
+// AddRecord adds a record to the namespace
+func (n *NamespacedStore) AddRecord(record Entry) error {
+	return n.store.AddRecord(n.wrap(record))
+}
+
+// AddRecords adds a batch of records to the namespace; all or none are added
+func (n *NamespacedStore) AddRecords(records ...Entry) error {
+	return n.store.AddRecords(n.wrapAll(records)...)
+}
+
+// GetRecord retrieves a record of the namespace by ID
+func (n *NamespacedStore) GetRecord(id string) (Entry, error) {
+	record, err := n.store.GetRecord(n.prefix + id)
+	if err != nil {
+		return Entry{}, err
+	}
+	if !n.owns(record) {
+		return Entry{}, fmt.Errorf("knowledge record with ID %s not found", id)
+	}
+	return n.unwrap(record), nil
+}
+
+// UpdateRecord updates a record of the namespace
+func (n *NamespacedStore) UpdateRecord(record Entry) error {
+	return n.store.UpdateRecord(n.wrap(record))
+}
+
+// UpdateRecords updates a batch of records of the namespace; all or none are updated
+func (n *NamespacedStore) UpdateRecords(records ...Entry) error {
+	return n.store.UpdateRecords(n.wrapAll(records)...)
+}
+
+// DeleteRecord soft-deletes a record of the namespace
+func (n *NamespacedStore) DeleteRecord(id string) error {
+	return n.store.DeleteRecord(n.prefix + id)
+}
+
+// DeleteRecords soft-deletes a batch of records of the namespace; all or none are deleted
+func (n *NamespacedStore) DeleteRecords(ids ...string) error {
+	return n.store.DeleteRecords(n.prefixAll(ids)...)
+}
+
+// RestoreRecord restores a soft-deleted record of the namespace
+func (n *NamespacedStore) RestoreRecord(id string) error {
+	return n.store.RestoreRecord(n.prefix + id)
+}
+
+// PurgeRecord permanently removes a record of the namespace
+func (n *NamespacedStore) PurgeRecord(id string) error {
+	return n.store.PurgeRecord(n.prefix + id)
+}
+
+// PurgeNamespace permanently removes every record of the namespace, including
+// soft-deleted ones, and returns how many were removed. Other namespaces are not
+// touched.
+func (n *NamespacedStore) PurgeNamespace() (int, error) {
+	filter := n.scope(Filter{IncludeDeleted: true})
+	records, err := n.store.SearchRecords(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, record := range records {
+		if err := n.store.PurgeRecord(record.ID); err != nil {
+			return purged, fmt.Errorf("failed to purge namespace %s: %w", n.namespace, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// SearchRecords searches the records of the namespace
+func (n *NamespacedStore) SearchRecords(filter Filter) ([]Entry, error) {
+	records, err := n.store.SearchRecords(n.scope(filter))
+	if err != nil {
+		return nil, err
+	}
+	return n.unwrapAll(records), nil
+}
+
+// CountRecords counts the matching records of the namespace
+func (n *NamespacedStore) CountRecords(filter Filter) (int, error) {
+	return n.store.CountRecords(n.scope(filter))
+}
+
+// Aggregate counts the matching records of the namespace per group
+func (n *NamespacedStore) Aggregate(filter Filter, groupBy string) (map[string]int, error) {
+	return n.store.Aggregate(n.scope(filter), groupBy)
+}
+
+// FullTextSearch searches the content of the records of the namespace. The backing
+// store ranks all namespaces together, so more results are fetched than requested
+// until enough belong to this namespace.
+func (n *NamespacedStore) FullTextSearch(query string, opts ...SearchOption) ([]SearchResult, error) {
+	var options SearchOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Limit <= 0 {
+		results, err := n.store.FullTextSearch(query, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return n.ownResults(results, 0), nil
+	}
+
+	for fetch := options.Limit * namespaceOverfetch; ; fetch *= 2 {
+		fetchOpts := append(append(make([]SearchOption, 0, len(opts)+1), opts...), WithSearchLimit(fetch))
+		results, err := n.store.FullTextSearch(query, fetchOpts...)
+		if err != nil {
+			return nil, err
+		}
+		own := n.ownResults(results, options.Limit)
+		if len(own) >= options.Limit || len(results) < fetch {
+			return own, nil
+		}
+	}
+}
+
+// SearchSimilar returns the records of the namespace with the most similar embeddings
+func (n *NamespacedStore) SearchSimilar(vector []float32, topK int) ([]SearchResult, error) {
+	if topK <= 0 {
+		results, err := n.store.SearchSimilar(vector, topK)
+		if err != nil {
+			return nil, err
+		}
+		return n.ownResults(results, 0), nil
+	}
+
+	for fetch := topK * namespaceOverfetch; ; fetch *= 2 {
+		results, err := n.store.SearchSimilar(vector, fetch)
+		if err != nil {
+			return nil, err
+		}
+		own := n.ownResults(results, topK)
+		if len(own) >= topK || len(results) < fetch {
+			return own, nil
+		}
+	}
+}
+
+// RecordAccess adds retrieval counts to records of the namespace
+func (n *NamespacedStore) RecordAccess(accesses ...Access) error {
+	prefixed := make([]Access, len(accesses))
+	for i, access := range accesses {
+		access.ID = n.prefix + access.ID
+		prefixed[i] = access
+	}
+	return n.store.RecordAccess(prefixed...)
+}
+
+// Watch streams changes to matching records of the namespace
+func (n *NamespacedStore) Watch(filter Filter) (<-chan ChangeEvent, CancelFunc) {
+	events, cancel := n.store.Watch(n.scope(filter))
+
+	out := make(chan ChangeEvent, WatchBufferSize)
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+		for event := range events {
+			event.Entry = n.unwrap(event.Entry)
+			select {
+			case out <- event:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			close(done)
+			cancel()
+		})
+	}
+}
+
+// LoadRecords bulk loads records into the namespace
+func (n *NamespacedStore) LoadRecords(records ...Entry) error {
+	return n.store.LoadRecords(n.wrapAll(records)...)
+}
+
+// Open is a no-op: the shared backing store is opened by its owner
+func (n *NamespacedStore) Open() error {
+	return nil
+}
+
+// Flush writes pending data of the backing store, for all namespaces
+func (n *NamespacedStore) Flush() error {
+	return n.store.Flush()
+}
+
+// Close is a no-op: the shared backing store is closed by its owner
+func (n *NamespacedStore) Close() error {
+	return nil
+}
+
+// Info describes the namespace. Statistics of the backing store are left out since
+// they cover other namespaces.
+func (n *NamespacedStore) Info() (map[string]string, error) {
+	backing, err := n.store.Info()
+	if err != nil {
+		return nil, err
+	}
+	count, err := n.CountRecords(Filter{})
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := n.CountRecords(Filter{OnlyDeleted: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"implementation": "NamespacedStore",
+		"namespace":      n.namespace,
+		"backing_store":  backing["implementation"],
+		"record_count":   fmt.Sprintf("%d", count),
+		"deleted_count":  fmt.Sprintf("%d", deleted),
+	}, nil
+}
+
+// scope restricts the filter to the records of the namespace
+func (n *NamespacedStore) scope(filter Filter) Filter {
+	filter.RootGroup = FilterGroup{
+		Operator: OpAnd,
+		Conditions: []Condition{
+			{Field: "Metadata", Operator: "=", Value: map[string]string{MetadataNamespace: n.namespace}},
+		},
+		Groups: []FilterGroup{filter.RootGroup},
+	}
+	return filter
+}
+
+// owns reports whether a record of the backing store belongs to the namespace
+func (n *NamespacedStore) owns(record Entry) bool {
+	return strings.HasPrefix(record.ID, n.prefix) && record.Metadata[MetadataNamespace] == n.namespace
+}
+
+// wrap converts a record to its form in the backing store
+func (n *NamespacedStore) wrap(record Entry) Entry {
+	record.ID = n.prefix + record.ID
+
+	metadata := make(map[string]string, len(record.Metadata)+1)
+	for key, value := range record.Metadata {
+		metadata[key] = value
+	}
+	metadata[MetadataNamespace] = n.namespace
+	record.Metadata = metadata
+
+	if record.References != nil {
+		references := make([]Reference, len(record.References))
+		for i, reference := range record.References {
+			reference.ID = n.prefix + reference.ID
+			references[i] = reference
+		}
+		record.References = references
+	}
+	return record
+}
+
+// unwrap converts a record of the backing store back to the form it was written in
+func (n *NamespacedStore) unwrap(record Entry) Entry {
+	record.ID = strings.TrimPrefix(record.ID, n.prefix)
+
+	if record.Metadata != nil {
+		metadata := make(map[string]string, len(record.Metadata))
+		for key, value := range record.Metadata {
+			if key != MetadataNamespace {
+				metadata[key] = value
+			}
+		}
+		record.Metadata = metadata
+	}
+
+	if record.References != nil {
+		references := make([]Reference, len(record.References))
+		for i, reference := range record.References {
+			reference.ID = strings.TrimPrefix(reference.ID, n.prefix)
+			references[i] = reference
+		}
+		record.References = references
+	}
+	return record
+}
+
+// wrapAll converts records to their form in the backing store
+func (n *NamespacedStore) wrapAll(records []Entry) []Entry {
+	wrapped := make([]Entry, len(records))
+	for i, record := range records {
+		wrapped[i] = n.wrap(record)
+	}
+	return wrapped
+}
+
+// unwrapAll converts records of the backing store back to the form they were written in
+func (n *NamespacedStore) unwrapAll(records []Entry) []Entry {
+	unwrapped := make([]Entry, len(records))
+	for i, record := range records {
+		unwrapped[i] = n.unwrap(record)
+	}
+	return unwrapped
+}
+
+// prefixAll converts record IDs to their form in the backing store
+func (n *NamespacedStore) prefixAll(ids []string) []string {
+	prefixed := make([]string, len(ids))
+	for i, id := range ids {
+		prefixed[i] = n.prefix + id
+	}
+	return prefixed
+}
+
+// ownResults keeps the search results of the namespace, at most limit if positive
+func (n *NamespacedStore) ownResults(results []SearchResult, limit int) []SearchResult {
+	own := make([]SearchResult, 0, len(results))
+	for _, result := range results {
+		if !n.owns(result.Entry) {
+			continue
+		}
+		result.Entry = n.unwrap(result.Entry)
+		own = append(own, result)
+		if limit > 0 && len(own) == limit {
+			break
+		}
+	}
+	return own
+}
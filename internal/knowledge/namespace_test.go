@@ -0,0 +1,137 @@
+package knowledge
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// NamespacedStore must be usable wherever a Store is
+var _ Store = (*NamespacedStore)(nil)
+
+func TestNamespacedStoreMemory(t *testing.T) {
+	store, _ := NewMemoryStore()
+	testNamespacedStore(t, store)
+}
+
+func TestNamespacedStoreFile(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "knowledge.json"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	testNamespacedStore(t, store)
+}
+
+func testNamespacedStore(t *testing.T, backing Store) {
+	if err := backing.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer backing.Close()
+
+	alice, err := NewNamespacedStore(backing, "alice")
+	if err != nil {
+		t.Fatalf("NewNamespacedStore failed: %v", err)
+	}
+	bob, _ := NewNamespacedStore(backing, "bob")
+
+	// The same IDs can be used in both namespaces
+	for _, ns := range []*NamespacedStore{alice, bob} {
+		err := ns.AddRecords(
+			Entry{ID: "1", Category: CategoryFact, Content: []byte(ns.Namespace() + " likes deployment pipelines"), Metadata: map[string]string{"team": "core"}},
+			Entry{ID: "2", Category: CategoryDecision, Content: []byte(ns.Namespace() + " chose postgres"), References: []Reference{{ID: "1", Type: CategoryFact}}},
+		)
+		if err != nil {
+			t.Fatalf("AddRecords failed: %v", err)
+		}
+	}
+	bob.AddRecord(Entry{ID: "3", Category: CategoryFact, Content: []byte("bob only")})
+
+	record, err := alice.GetRecord("1")
+	if err != nil {
+		t.Fatalf("GetRecord failed: %v", err)
+	}
+	if record.ID != "1" || string(record.Content) != "alice likes deployment pipelines" {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+	if _, ok := record.Metadata[MetadataNamespace]; ok || record.Metadata["team"] != "core" {
+		t.Errorf("Expected the namespace to be hidden from metadata, got %v", record.Metadata)
+	}
+	if record, _ := alice.GetRecord("2"); len(record.References) != 1 || record.References[0].ID != "1" {
+		t.Errorf("Expected references within the namespace, got %v", record.References)
+	}
+	if _, err := alice.GetRecord("3"); err == nil {
+		t.Error("Expected bob's record not to be visible to alice")
+	}
+	if raw, err := backing.GetRecord("alice/1"); err != nil || raw.Metadata[MetadataNamespace] != "alice" {
+		t.Errorf("Expected the record to be stored under the namespace, got %+v, %v", raw, err)
+	}
+
+	// Search, count and aggregate are scoped
+	results, err := alice.SearchRecords(Filter{RootGroup: FilterGroup{Operator: OpAnd, Conditions: []Condition{{Field: "Category", Operator: "=", Value: CategoryFact}}}})
+	if err != nil || len(results) != 1 || results[0].ID != "1" {
+		t.Errorf("Expected alice's fact, got %v, %v", results, err)
+	}
+	if count, _ := bob.CountRecords(Filter{}); count != 3 {
+		t.Errorf("Expected 3 records for bob, got %d", count)
+	}
+	if groups, _ := alice.Aggregate(Filter{}, "Category"); groups[CategoryFact] != 1 || groups[CategoryDecision] != 1 {
+		t.Errorf("Unexpected aggregate: %v", groups)
+	}
+	found, err := alice.FullTextSearch("deployment pipelines", WithSearchLimit(5))
+	if err != nil || len(found) != 1 || found[0].Entry.ID != "1" {
+		t.Errorf("Expected alice's entry from full-text search, got %v, %v", found, err)
+	}
+
+	// Writes by ID stay in the namespace
+	if err := alice.DeleteRecord("1"); err != nil {
+		t.Fatalf("DeleteRecord failed: %v", err)
+	}
+	if _, err := bob.GetRecord("1"); err != nil {
+		t.Errorf("Expected bob's record to be unaffected, got %v", err)
+	}
+	if err := alice.RestoreRecord("1"); err != nil {
+		t.Errorf("RestoreRecord failed: %v", err)
+	}
+
+	// Watchers only see their namespace
+	events, cancel := alice.Watch(Filter{})
+	defer cancel()
+	bob.UpdateRecord(Entry{ID: "3", Category: CategoryFact, Content: []byte("bob changed")})
+	alice.UpdateRecord(Entry{ID: "2", Category: CategoryDecision, Content: []byte("alice chose sqlite")})
+	select {
+	case event := <-events:
+		if event.Type != ChangeUpdate || event.Entry.ID != "2" {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected a change event")
+	}
+
+	// Purging a namespace leaves the others alone
+	alice.DeleteRecord("2")
+	purged, err := alice.PurgeNamespace()
+	if err != nil || purged != 2 {
+		t.Errorf("Expected 2 purged records, got %d, %v", purged, err)
+	}
+	if count, _ := alice.CountRecords(Filter{IncludeDeleted: true}); count != 0 {
+		t.Errorf("Expected alice's namespace to be empty, got %d", count)
+	}
+	if count, _ := bob.CountRecords(Filter{}); count != 3 {
+		t.Errorf("Expected bob's records to remain, got %d", count)
+	}
+
+	info, err := bob.Info()
+	if err != nil || info["namespace"] != "bob" || info["record_count"] != "3" {
+		t.Errorf("Unexpected info: %v, %v", info, err)
+	}
+}
+
+func TestNewNamespacedStoreValidation(t *testing.T) {
+	store, _ := NewMemoryStore()
+	if _, err := NewNamespacedStore(store, ""); err == nil {
+		t.Error("Expected error for an empty namespace")
+	}
+	if _, err := NewNamespacedStore(store, "a/b"); err == nil {
+		t.Error("Expected error for a namespace containing the separator")
+	}
+}
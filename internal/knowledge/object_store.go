@@ -0,0 +1,330 @@
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"goproduct/internal/objectstore"
+)
+
+// DefaultObjectStoreShards is the number of shard objects records are spread over
+const DefaultObjectStoreShards = 64
+
+// objectManifestVersion is the manifest format written by this version
+const objectManifestVersion = 1
+
+// objectManifest describes the shards of an ObjectStore. It is written after the
+// shards, so it only ever lists shards that were completely written.
+type objectManifest struct {
+	Version   int                    `json:"version"`
+	Shards    int                    `json:"shards"`
+	UpdatedAt time.Time              `json:"updatedAt"`
+	Objects   map[string]shardDigest `json:"objects"` // Shard object key to its contents
+}
+
+// shardDigest summarizes one shard object
+type shardDigest struct {
+	Records   int       `json:"records"`
+	Deleted   int       `json:"deleted"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ObjectStore keeps knowledge in object storage such as S3, MinIO or GCS, for
+// deployments without a durable local disk. Records are spread over a fixed number of
+// JSON shard objects by a hash of their ID, in the same format as the FileStore file,
+// and a manifest lists the shards. All records are held in memory for searching; Flush
+// rewrites only the shards that changed, then the manifest.
+type ObjectStore struct {
+	*MemoryStore
+	storage  objectstore.Storage
+	prefix   string
+	shards   int
+	manifest objectManifest
+	dirty    map[int]struct{} // Shards changed since the last flush
+	dirtyMu  sync.Mutex
+	flushMu  sync.Mutex // Serializes flushes
+}
+
+// NewObjectStore creates a store keeping its objects under prefix in the storage. The
+// number of shards is fixed when the first manifest is written; an existing store keeps
+// its own. A non-positive shards selects DefaultObjectStoreShards.
+func NewObjectStore(storage objectstore.Storage, prefix string, shards int) (*ObjectStore, error) {
+	if shards <= 0 {
+		shards = DefaultObjectStoreShards
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	memory, err := NewMemoryStore()
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectStore{
+		MemoryStore: memory,
+		storage:     storage,
+		prefix:      prefix,
+		shards:      shards,
+		dirty:       make(map[int]struct{}),
+	}, nil
+}
+
+// Open loads the manifest and every shard it lists
+func (o *ObjectStore) Open() error {
+	ctx := context.Background()
+
+	o.manifest = objectManifest{Version: objectManifestVersion, Shards: o.shards, Objects: make(map[string]shardDigest)}
+	data, err := o.storage.Get(ctx, o.manifestKey())
+	switch {
+	case errors.Is(err, objectstore.ErrNotFound):
+		// New store
+	case err != nil:
+		return fmt.Errorf("failed to read knowledge manifest: %w", err)
+	default:
+		if err := json.Unmarshal(data, &o.manifest); err != nil {
+			return fmt.Errorf("failed to parse knowledge manifest: %w", err)
+		}
+		if o.manifest.Version > objectManifestVersion {
+			return fmt.Errorf("knowledge manifest has version %d, newer than the supported %d", o.manifest.Version, objectManifestVersion)
+		}
+		if o.manifest.Shards <= 0 {
+			return fmt.Errorf("knowledge manifest has invalid shard count %d", o.manifest.Shards)
+		}
+		if o.manifest.Objects == nil {
+			o.manifest.Objects = make(map[string]shardDigest)
+		}
+		o.shards = o.manifest.Shards
+	}
+
+	records := make(map[string]Entry)
+	deleted := make(map[string]Entry)
+	for key := range o.manifest.Objects {
+		data, err := o.storage.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to read knowledge shard %s: %w", key, err)
+		}
+		var shard fileData
+		if err := json.Unmarshal(data, &shard); err != nil {
+			return fmt.Errorf("failed to parse knowledge shard %s: %w", key, err)
+		}
+		for id, record := range shard.Records {
+			records[id] = record
+		}
+		for id, record := range shard.DeletedRecs {
+			deleted[id] = record
+		}
+	}
+
+	o.MemoryStore.mu.Lock()
+	o.MemoryStore.records = records
+	o.MemoryStore.deletedRecs = deleted
+	o.MemoryStore.mu.Unlock()
+
+	o.dirtyMu.Lock()
+	o.dirty = make(map[int]struct{})
+	o.dirtyMu.Unlock()
+
+	// Rebuilds the search indexes over the loaded records
+	return o.MemoryStore.Open()
+}
+
+// Flush writes the changed shards, then the manifest
+func (o *ObjectStore) Flush() error {
+	o.flushMu.Lock()
+	defer o.flushMu.Unlock()
+
+	o.dirtyMu.Lock()
+	dirty := o.dirty
+	o.dirty = make(map[int]struct{})
+	o.dirtyMu.Unlock()
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	// Snapshot the changed shards
+	shards := make(map[int]*fileData, len(dirty))
+	for shard := range dirty {
+		shards[shard] = &fileData{Records: make(map[string]Entry), DeletedRecs: make(map[string]Entry)}
+	}
+	o.MemoryStore.mu.RLock()
+	for id, record := range o.MemoryStore.records {
+		if data, ok := shards[o.shardOf(id)]; ok {
+			data.Records[id] = record
+		}
+	}
+	for id, record := range o.MemoryStore.deletedRecs {
+		if data, ok := shards[o.shardOf(id)]; ok {
+			data.DeletedRecs[id] = record
+		}
+	}
+	o.MemoryStore.mu.RUnlock()
+
+	ctx := context.Background()
+	now := time.Now()
+	for shard, data := range shards {
+		key := o.shardKey(shard)
+		if len(data.Records) == 0 && len(data.DeletedRecs) == 0 {
+			delete(o.manifest.Objects, key)
+			continue
+		}
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			o.markShardsDirty(dirty)
+			return fmt.Errorf("failed to marshal knowledge shard %s: %w", key, err)
+		}
+		if err := o.storage.Put(ctx, key, encoded); err != nil {
+			o.markShardsDirty(dirty)
+			return fmt.Errorf("failed to write knowledge shard %s: %w", key, err)
+		}
+		o.manifest.Objects[key] = shardDigest{Records: len(data.Records), Deleted: len(data.DeletedRecs), UpdatedAt: now}
+	}
+
+	o.manifest.UpdatedAt = now
+	encoded, err := json.MarshalIndent(o.manifest, "", "  ")
+	if err != nil {
+		o.markShardsDirty(dirty)
+		return fmt.Errorf("failed to marshal knowledge manifest: %w", err)
+	}
+	if err := o.storage.Put(ctx, o.manifestKey(), encoded); err != nil {
+		o.markShardsDirty(dirty)
+		return fmt.Errorf("failed to write knowledge manifest: %w", err)
+	}
+
+	// Shards that became empty are no longer listed and can go
+	for shard, data := range shards {
+		if len(data.Records) == 0 && len(data.DeletedRecs) == 0 {
+			if err := o.storage.Delete(ctx, o.shardKey(shard)); err != nil {
+				return fmt.Errorf("failed to delete empty knowledge shard: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Close flushes pending changes and releases the records
+func (o *ObjectStore) Close() error {
+	if err := o.Flush(); err != nil {
+		return err
+	}
+	return o.MemoryStore.Close()
+}
+
+// AddRecord adds a record
+func (o *ObjectStore) AddRecord(record Entry) error {
+	return o.written(o.MemoryStore.AddRecord(record), record.ID)
+}
+
+// AddRecords adds a batch of records; all or none are added
+func (o *ObjectStore) AddRecords(records ...Entry) error {
+	return o.written(o.MemoryStore.AddRecords(records...), recordIDs(records)...)
+}
+
+// UpdateRecord updates a record
+func (o *ObjectStore) UpdateRecord(record Entry) error {
+	return o.written(o.MemoryStore.UpdateRecord(record), record.ID)
+}
+
+// UpdateRecords updates a batch of records; all or none are updated
+func (o *ObjectStore) UpdateRecords(records ...Entry) error {
+	return o.written(o.MemoryStore.UpdateRecords(records...), recordIDs(records)...)
+}
+
+// DeleteRecord soft-deletes a record
+func (o *ObjectStore) DeleteRecord(id string) error {
+	return o.written(o.MemoryStore.DeleteRecord(id), id)
+}
+
+// DeleteRecords soft-deletes a batch of records; all or none are deleted
+func (o *ObjectStore) DeleteRecords(ids ...string) error {
+	return o.written(o.MemoryStore.DeleteRecords(ids...), ids...)
+}
+
+// RestoreRecord restores a soft-deleted record
+func (o *ObjectStore) RestoreRecord(id string) error {
+	return o.written(o.MemoryStore.RestoreRecord(id), id)
+}
+
+// PurgeRecord permanently removes a record
+func (o *ObjectStore) PurgeRecord(id string) error {
+	return o.written(o.MemoryStore.PurgeRecord(id), id)
+}
+
+// RecordAccess adds retrieval counts to records
+func (o *ObjectStore) RecordAccess(accesses ...Access) error {
+	ids := make([]string, len(accesses))
+	for i, access := range accesses {
+		ids[i] = access.ID
+	}
+	return o.written(o.MemoryStore.RecordAccess(accesses...), ids...)
+}
+
+// LoadRecords bulk loads records, updating existing ones and adding new ones
+func (o *ObjectStore) LoadRecords(records ...Entry) error {
+	return o.written(o.MemoryStore.LoadRecords(records...), recordIDs(records)...)
+}
+
+// Info provides information about the store and its objects
+func (o *ObjectStore) Info() (map[string]string, error) {
+	info, err := o.MemoryStore.Info()
+	if err != nil {
+		return nil, err
+	}
+	o.flushMu.Lock()
+	objects := len(o.manifest.Objects)
+	o.flushMu.Unlock()
+	o.dirtyMu.Lock()
+	dirty := len(o.dirty)
+	o.dirtyMu.Unlock()
+
+	info["implementation"] = "ObjectStore"
+	info["persistent"] = "true"
+	info["prefix"] = o.prefix
+	info["shards"] = fmt.Sprintf("%d", o.shards)
+	info["shard_objects"] = fmt.Sprintf("%d", objects)
+	info["dirty_shards"] = fmt.Sprintf("%d", dirty)
+	return info, nil
+}
+
+// written marks the shards of the records dirty if the write succeeded
+func (o *ObjectStore) written(err error, ids ...string) error {
+	if err != nil {
+		return err
+	}
+	o.dirtyMu.Lock()
+	defer o.dirtyMu.Unlock()
+	for _, id := range ids {
+		o.dirty[o.shardOf(id)] = struct{}{}
+	}
+	return nil
+}
+
+// markShardsDirty puts shards back on the dirty list after a failed flush
+func (o *ObjectStore) markShardsDirty(shards map[int]struct{}) {
+	o.dirtyMu.Lock()
+	defer o.dirtyMu.Unlock()
+	for shard := range shards {
+		o.dirty[shard] = struct{}{}
+	}
+}
+
+// shardOf returns the shard a record ID belongs to
+func (o *ObjectStore) shardOf(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(o.shards))
+}
+
+// shardKey returns the object key of a shard
+func (o *ObjectStore) shardKey(shard int) string {
+	return fmt.Sprintf("%sshards/%04d.json", o.prefix, shard)
+}
+
+// manifestKey returns the object key of the manifest
+func (o *ObjectStore) manifestKey() string {
+	return o.prefix + "manifest.json"
+}
@@ -0,0 +1,78 @@
+//go:build integration
+
+package knowledge
+
+import (
+	"testing"
+
+	"goproduct/internal/integration"
+)
+
+// newIntegrationObjectStore opens an ObjectStore on the MinIO service under a prefix
+// of its own
+func newIntegrationObjectStore(t *testing.T) *ObjectStore {
+	t.Helper()
+	storage := integration.S3(t)
+	store, err := NewObjectStore(storage, integration.Prefix(t, storage), 8)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	return store
+}
+
+func TestObjectStoreIntegrationConformance(t *testing.T) {
+	tests := map[string]func(*testing.T, Store){
+		"BatchOperations":   testBatchOperations,
+		"CountAndAggregate": testCountAndAggregate,
+		"Watch":             testWatch,
+		"FullTextSearch":    testFullTextSearch,
+		"RecordAccess":      testRecordAccess,
+		"SearchSimilar":     testSearchSimilar,
+		"QueryBuilder":      testQueryBuilder,
+		"Outbox":            testOutbox,
+		"Namespaces":        testNamespacedStore,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := newIntegrationObjectStore(t)
+			test(t, store)
+			if err := store.Close(); err != nil {
+				t.Fatalf("Failed to close store: %v", err)
+			}
+		})
+	}
+}
+
+func TestObjectStoreIntegrationReopen(t *testing.T) {
+	storage := integration.S3(t)
+	prefix := integration.Prefix(t, storage)
+
+	store, _ := NewObjectStore(storage, prefix, 8)
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if err := store.AddRecord(Entry{ID: id, Category: CategoryFact, Content: []byte("fact " + id)}); err != nil {
+			t.Fatalf("AddRecord failed: %v", err)
+		}
+	}
+	store.DeleteRecord("b")
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	reopened, _ := NewObjectStore(storage, prefix, 8)
+	if err := reopened.Open(); err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if count, _ := reopened.CountRecords(Filter{}); count != 2 {
+		t.Errorf("Expected 2 records after reopening, got %d", count)
+	}
+	if err := reopened.RestoreRecord("b"); err != nil {
+		t.Errorf("Expected the soft-deleted record to survive: %v", err)
+	}
+}
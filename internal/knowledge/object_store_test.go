@@ -0,0 +1,153 @@
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"goproduct/internal/objectstore"
+)
+
+// failingStorage fails every Put once failPuts is set
+type failingStorage struct {
+	objectstore.Storage
+	failPuts bool
+}
+
+func (f *failingStorage) Put(ctx context.Context, key string, data []byte) error {
+	if f.failPuts {
+		return errors.New("storage unavailable")
+	}
+	return f.Storage.Put(ctx, key, data)
+}
+
+func newTestObjectStore(t *testing.T, storage objectstore.Storage) *ObjectStore {
+	t.Helper()
+	store, err := NewObjectStore(storage, "knowledge", 8)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	return store
+}
+
+func TestObjectStoreBatchOperations(t *testing.T) {
+	store := newTestObjectStore(t, objectstore.NewMemory())
+	testBatchOperations(t, store)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+}
+
+func TestObjectStoreCountAndAggregate(t *testing.T) {
+	store := newTestObjectStore(t, objectstore.NewMemory())
+	defer store.Close()
+	testCountAndAggregate(t, store)
+}
+
+func TestObjectStoreWatch(t *testing.T) {
+	store := newTestObjectStore(t, objectstore.NewMemory())
+	defer store.Close()
+	testWatch(t, store)
+}
+
+func TestObjectStorePersistence(t *testing.T) {
+	storage := objectstore.NewMemory()
+	store := newTestObjectStore(t, storage)
+
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if err := store.AddRecord(Entry{ID: id, Category: CategoryFact, Content: []byte("fact " + id)}); err != nil {
+			t.Fatalf("AddRecord failed: %v", err)
+		}
+	}
+	store.DeleteRecord("b")
+	store.PurgeRecord("e")
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// The manifest lists exactly the shard objects that exist
+	data, err := storage.Get(context.Background(), "knowledge/manifest.json")
+	if err != nil {
+		t.Fatalf("Expected a manifest: %v", err)
+	}
+	var manifest objectManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+	shards, _ := storage.List(context.Background(), "knowledge/shards/")
+	if manifest.Shards != 8 || len(manifest.Objects) != len(shards) {
+		t.Errorf("Manifest lists %d objects, %d exist", len(manifest.Objects), len(shards))
+	}
+	records, deleted := 0, 0
+	for _, digest := range manifest.Objects {
+		records += digest.Records
+		deleted += digest.Deleted
+	}
+	if records != 3 || deleted != 1 {
+		t.Errorf("Expected 3 records and 1 deleted in the manifest, got %d and %d", records, deleted)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// A new store over the same objects sees the same state, including soft deletes
+	reopened := newTestObjectStore(t, storage)
+	defer reopened.Close()
+	if record, err := reopened.GetRecord("a"); err != nil || string(record.Content) != "fact a" {
+		t.Errorf("Expected record a after reopening, got %+v, %v", record, err)
+	}
+	if _, err := reopened.GetRecord("b"); err == nil {
+		t.Error("Expected record b to stay deleted")
+	}
+	if err := reopened.RestoreRecord("b"); err != nil {
+		t.Errorf("Expected record b to be restorable: %v", err)
+	}
+	if _, err := reopened.GetRecord("e"); err == nil {
+		t.Error("Expected record e to stay purged")
+	}
+	if results, _ := reopened.FullTextSearch("fact"); len(results) != 4 {
+		t.Errorf("Expected the search index to be rebuilt, got %d results", len(results))
+	}
+}
+
+func TestObjectStoreFlushFailureKeepsChanges(t *testing.T) {
+	storage := &failingStorage{Storage: objectstore.NewMemory()}
+	store := newTestObjectStore(t, storage)
+
+	store.AddRecord(Entry{ID: "a", Content: []byte("alpha")})
+	storage.failPuts = true
+	if err := store.Flush(); err == nil {
+		t.Fatal("Expected the flush to fail")
+	}
+
+	storage.failPuts = false
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	reopened := newTestObjectStore(t, storage)
+	if _, err := reopened.GetRecord("a"); err != nil {
+		t.Errorf("Expected the retried flush to write the record: %v", err)
+	}
+}
+
+func TestObjectStoreKeepsShardCount(t *testing.T) {
+	storage := objectstore.NewMemory()
+	store := newTestObjectStore(t, storage)
+	store.AddRecord(Entry{ID: "a", Content: []byte("alpha")})
+	store.Close()
+
+	reopened, _ := NewObjectStore(storage, "knowledge", 128)
+	if err := reopened.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	if info, _ := reopened.Info(); info["shards"] != "8" {
+		t.Errorf("Expected the stored shard count to win, got %s", info["shards"])
+	}
+	if _, err := reopened.GetRecord("a"); err != nil {
+		t.Errorf("Expected record a: %v", err)
+	}
+}
@@ -0,0 +1,294 @@
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CategoryOutbox is the category of side-effect intents stored alongside knowledge.
+// They are bookkeeping rather than knowledge and are left out of full-text search.
+const CategoryOutbox = "outbox"
+
+// Outbox intent statuses
+const (
+	OutboxPending = "pending" // Waiting to be dispatched or retried
+	OutboxDone    = "done"    // Dispatched successfully, never run again
+	OutboxFailed  = "failed"  // Gave up after the maximum number of attempts
+)
+
+// Outbox dispatch defaults
+const (
+	DefaultOutboxMaxAttempts = 5
+	DefaultOutboxBackoff     = 30 * time.Second
+)
+
+// outboxIDPrefix namespaces intent record IDs by idempotency key
+const outboxIDPrefix = "outbox-"
+
+// OutboxIntent is a side effect, such as creating a ticket or sending an email, that must
+// happen if and only if the knowledge written with it was stored
+type OutboxIntent struct {
+	Key           string            `json:"key"`                 // Idempotency key; an intent with the same key is only ever written and run once
+	Action        string            `json:"action"`              // Handler to run, e.g. "jira.create_ticket"
+	Payload       map[string]string `json:"payload,omitempty"`   // Parameters for the handler
+	Status        string            `json:"status"`              // OutboxPending, OutboxDone or OutboxFailed
+	Attempts      int               `json:"attempts"`            // Number of dispatch attempts so far
+	LastError     string            `json:"lastError,omitempty"` // Error of the last failed attempt
+	NextAttemptAt time.Time         `json:"nextAttemptAt"`       // Earliest time of the next attempt
+	CreatedAt     time.Time         `json:"createdAt"`           // When the intent was written
+	CompletedAt   time.Time         `json:"completedAt"`         // When the intent was dispatched successfully
+}
+
+// OutboxHandler performs the side effect of an intent. It receives the intent's key so it
+// can pass it on to external systems that support idempotency keys: an intent whose
+// handler succeeded but whose completion could not be recorded is run again.
+type OutboxHandler func(ctx context.Context, intent OutboxIntent) error
+
+// WriteWithOutbox stores the records and the side-effect intents in a single batch, so
+// either all of them are stored or none are. Writing an intent whose key was written
+// before fails the whole batch.
+func WriteWithOutbox(store Store, records []Entry, intents ...OutboxIntent) error {
+	batch := make([]Entry, 0, len(records)+len(intents))
+	batch = append(batch, records...)
+
+	now := time.Now()
+	for _, intent := range intents {
+		if intent.Key == "" || intent.Action == "" {
+			return errors.New("outbox intent must have a key and an action")
+		}
+		intent.Status = OutboxPending
+		intent.Attempts = 0
+		intent.CreatedAt = now
+		intent.NextAttemptAt = now
+		entry, err := outboxEntry(intent)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, entry)
+	}
+	return store.AddRecords(batch...)
+}
+
+// outboxEntry converts an intent to the record it is stored as
+func outboxEntry(intent OutboxIntent) (Entry, error) {
+	content, err := json.Marshal(intent)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to marshal outbox intent %s: %w", intent.Key, err)
+	}
+	return Entry{
+		ID:          outboxIDPrefix + intent.Key,
+		Category:    CategoryOutbox,
+		Content:     content,
+		ContentType: ContentTypeJSON,
+		Metadata:    map[string]string{"action": intent.Action, "status": intent.Status},
+	}, nil
+}
+
+// OutboxDispatcher runs the pending intents of a store with retries and exponential backoff
+type OutboxDispatcher struct {
+	store       Store
+	interval    time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	handlers    map[string]OutboxHandler
+	onError     func(intent OutboxIntent, err error)
+
+	dispatchMu sync.Mutex // Serializes dispatch runs so an intent is never run twice at once
+	mu         sync.Mutex // Protects handlers, stopCh and doneCh
+	stopCh     chan struct{}
+	doneCh     chan struct{}
+}
+
+// NewOutboxDispatcher creates a dispatcher that checks the store for due intents on the
+// given interval, one minute if not positive
+func NewOutboxDispatcher(store Store, interval time.Duration) *OutboxDispatcher {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &OutboxDispatcher{
+		store:       store,
+		interval:    interval,
+		maxAttempts: DefaultOutboxMaxAttempts,
+		backoff:     DefaultOutboxBackoff,
+		handlers:    make(map[string]OutboxHandler),
+		onError:     func(OutboxIntent, error) {},
+	}
+}
+
+// SetRetryPolicy sets the attempts after which an intent is marked failed and the delay
+// before the first retry, which doubles with every further attempt
+func (d *OutboxDispatcher) SetRetryPolicy(maxAttempts int, backoff time.Duration) {
+	if maxAttempts > 0 {
+		d.maxAttempts = maxAttempts
+	}
+	if backoff > 0 {
+		d.backoff = backoff
+	}
+}
+
+// Handle registers the handler for an action
+func (d *OutboxDispatcher) Handle(action string, handler OutboxHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[action] = handler
+}
+
+// OnError sets a callback for failed attempts
+func (d *OutboxDispatcher) OnError(handler func(intent OutboxIntent, err error)) {
+	if handler != nil {
+		d.onError = handler
+	}
+}
+
+// Pending returns the intents that have not completed or failed, oldest first
+func (d *OutboxDispatcher) Pending() ([]OutboxIntent, error) {
+	intents, err := outboxIntents(d.store)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]OutboxIntent, 0, len(intents))
+	for _, intent := range intents {
+		if intent.Status == OutboxPending {
+			pending = append(pending, intent)
+		}
+	}
+	return pending, nil
+}
+
+// Dispatch runs every pending intent that is due and returns the number that succeeded.
+// Failed attempts are recorded on the intent and reported to the OnError callback.
+func (d *OutboxDispatcher) Dispatch(ctx context.Context) (int, error) {
+	d.dispatchMu.Lock()
+	defer d.dispatchMu.Unlock()
+
+	pending, err := d.Pending()
+	if err != nil {
+		return 0, err
+	}
+
+	succeeded := 0
+	for _, intent := range pending {
+		if ctx.Err() != nil {
+			return succeeded, ctx.Err()
+		}
+		now := time.Now()
+		if intent.NextAttemptAt.After(now) {
+			continue
+		}
+
+		d.mu.Lock()
+		handler, ok := d.handlers[intent.Action]
+		d.mu.Unlock()
+
+		var runErr error
+		if ok {
+			runErr = handler(ctx, intent)
+		} else {
+			runErr = fmt.Errorf("no handler for outbox action %s", intent.Action)
+		}
+
+		intent.Attempts++
+		if runErr == nil {
+			intent.Status = OutboxDone
+			intent.LastError = ""
+			intent.CompletedAt = time.Now()
+			succeeded++
+		} else {
+			intent.LastError = runErr.Error()
+			intent.NextAttemptAt = now.Add(d.backoff << (intent.Attempts - 1))
+			if intent.Attempts >= d.maxAttempts {
+				intent.Status = OutboxFailed
+			}
+			d.onError(intent, runErr)
+		}
+
+		entry, err := outboxEntry(intent)
+		if err != nil {
+			return succeeded, err
+		}
+		if err := d.store.UpdateRecord(entry); err != nil {
+			return succeeded, fmt.Errorf("failed to record outbox intent %s: %w", intent.Key, err)
+		}
+	}
+	return succeeded, nil
+}
+
+// Start dispatches due intents on the dispatcher's interval until the context is done or
+// Stop is called
+func (d *OutboxDispatcher) Start(ctx context.Context) {
+	d.mu.Lock()
+	if d.stopCh != nil {
+		d.mu.Unlock()
+		return
+	}
+	d.stopCh = make(chan struct{})
+	d.doneCh = make(chan struct{})
+	stopCh, doneCh := d.stopCh, d.doneCh
+	d.mu.Unlock()
+
+	go func() {
+		defer close(doneCh)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if _, err := d.Dispatch(ctx); err != nil && ctx.Err() == nil {
+					d.onError(OutboxIntent{}, err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduled dispatches and waits for an in-progress one to finish
+func (d *OutboxDispatcher) Stop() {
+	d.mu.Lock()
+	stopCh, doneCh := d.stopCh, d.doneCh
+	d.stopCh, d.doneCh = nil, nil
+	d.mu.Unlock()
+
+	if stopCh != nil {
+		close(stopCh)
+		<-doneCh
+	}
+}
+
+// outboxIntents returns every intent in the store, oldest first
+func outboxIntents(store Store) ([]OutboxIntent, error) {
+	records, err := store.SearchRecords(Filter{
+		RootGroup: FilterGroup{
+			Operator:   OpAnd,
+			Conditions: []Condition{{Field: "Category", Operator: "=", Value: CategoryOutbox}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load outbox: %w", err)
+	}
+
+	intents := make([]OutboxIntent, 0, len(records))
+	for _, record := range records {
+		var intent OutboxIntent
+		if err := json.Unmarshal(record.Content, &intent); err != nil {
+			return nil, fmt.Errorf("corrupt outbox intent %s: %w", record.ID, err)
+		}
+		intents = append(intents, intent)
+	}
+	sort.Slice(intents, func(i, j int) bool {
+		if !intents[i].CreatedAt.Equal(intents[j].CreatedAt) {
+			return intents[i].CreatedAt.Before(intents[j].CreatedAt)
+		}
+		return intents[i].Key < intents[j].Key
+	})
+	return intents, nil
+}
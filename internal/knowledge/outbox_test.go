@@ -0,0 +1,124 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testOutbox(t *testing.T, store Store) {
+	decision := Entry{ID: "d1", Category: CategoryDecision, Content: []byte("Ship the beta on Friday")}
+	ticket := OutboxIntent{Key: "ticket-d1", Action: "jira.create_ticket", Payload: map[string]string{"summary": "Ship the beta"}}
+	email := OutboxIntent{Key: "email-d1", Action: "email.send"}
+
+	if err := WriteWithOutbox(store, []Entry{decision}, ticket, email); err != nil {
+		t.Fatalf("Failed to write with outbox: %v", err)
+	}
+
+	// The same key is only ever written once, and a rejected batch stores nothing
+	err := WriteWithOutbox(store, []Entry{{ID: "d2", Category: CategoryDecision}}, ticket)
+	if err == nil {
+		t.Error("Expected error when writing an intent key twice")
+	}
+	if _, err := store.GetRecord("d2"); err == nil {
+		t.Error("Expected the knowledge of a rejected batch not to be stored")
+	}
+
+	// Intents are not knowledge
+	if results, _ := store.FullTextSearch("jira ticket pending"); len(results) != 0 {
+		t.Errorf("Expected intents to be left out of full-text search, got %d results", len(results))
+	}
+
+	dispatcher := NewOutboxDispatcher(store, time.Hour)
+	dispatcher.SetRetryPolicy(2, time.Millisecond)
+	var failures []string
+	dispatcher.OnError(func(intent OutboxIntent, err error) {
+		failures = append(failures, intent.Key)
+	})
+
+	created := 0
+	dispatcher.Handle("jira.create_ticket", func(ctx context.Context, intent OutboxIntent) error {
+		if intent.Payload["summary"] != "Ship the beta" {
+			t.Errorf("Unexpected payload: %v", intent.Payload)
+		}
+		created++
+		return nil
+	})
+	dispatcher.Handle("email.send", func(ctx context.Context, intent OutboxIntent) error {
+		return errors.New("smtp unavailable")
+	})
+
+	ctx := context.Background()
+	succeeded, err := dispatcher.Dispatch(ctx)
+	if err != nil || succeeded != 1 {
+		t.Fatalf("Expected 1 successful dispatch, got %d, %v", succeeded, err)
+	}
+
+	// Completed intents never run again; failed ones are retried after the backoff
+	time.Sleep(5 * time.Millisecond)
+	if _, err := dispatcher.Dispatch(ctx); err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+	if created != 1 {
+		t.Errorf("Expected the ticket to be created exactly once, got %d", created)
+	}
+	if len(failures) != 2 || failures[0] != "email-d1" {
+		t.Errorf("Expected two failed email attempts, got %v", failures)
+	}
+
+	intents, err := outboxIntents(store)
+	if err != nil {
+		t.Fatalf("Failed to load intents: %v", err)
+	}
+	statuses := make(map[string]OutboxIntent)
+	for _, intent := range intents {
+		statuses[intent.Key] = intent
+	}
+	if done := statuses["ticket-d1"]; done.Status != OutboxDone || done.Attempts != 1 || done.CompletedAt.IsZero() {
+		t.Errorf("Unexpected ticket intent: %+v", done)
+	}
+	if failed := statuses["email-d1"]; failed.Status != OutboxFailed || failed.Attempts != 2 || failed.LastError != "smtp unavailable" {
+		t.Errorf("Unexpected email intent: %+v", failed)
+	}
+	if pending, _ := dispatcher.Pending(); len(pending) != 0 {
+		t.Errorf("Expected no pending intents, got %d", len(pending))
+	}
+}
+
+func TestMemoryStoreOutbox(t *testing.T) {
+	store, _ := NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	testOutbox(t, store)
+}
+
+func TestFileStoreOutbox(t *testing.T) {
+	store, _ := NewFileStore(filepath.Join(t.TempDir(), "knowledge.json"))
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	testOutbox(t, store)
+}
+
+func TestOutboxUnknownAction(t *testing.T) {
+	store, _ := NewMemoryStore()
+	store.Open()
+	WriteWithOutbox(store, nil, OutboxIntent{Key: "k", Action: "unknown"})
+
+	dispatcher := NewOutboxDispatcher(store, time.Hour)
+	if succeeded, _ := dispatcher.Dispatch(context.Background()); succeeded != 0 {
+		t.Errorf("Expected no successful dispatch, got %d", succeeded)
+	}
+	pending, _ := dispatcher.Pending()
+	if len(pending) != 1 || pending[0].Attempts != 1 || pending[0].NextAttemptAt.Before(time.Now()) {
+		t.Errorf("Expected the intent to wait for a retry, got %+v", pending)
+	}
+
+	if err := WriteWithOutbox(store, nil, OutboxIntent{Action: "unknown"}); err == nil {
+		t.Error("Expected error for an intent without a key")
+	}
+}
@@ -0,0 +1,244 @@
+package knowledge
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Provenance origin constants describe how a knowledge entry came to exist
+const (
+	OriginConversation = "conversation" // Learned from a conversation between entities
+	OriginGeneration   = "generation"   // Produced by an LLM generation
+	OriginIngestion    = "ingestion"    // Imported by an ingestion or import job
+	OriginCommand      = "command"      // Created by an explicit human command
+	OriginSystem       = "system"       // Written by the system itself (seeding, migrations, maintenance)
+)
+
+// Provenance operation constants describe which write path recorded a step
+const (
+	ProvenanceOpAdd     = "add"     // Record was added
+	ProvenanceOpUpdate  = "update"  // Record was updated
+	ProvenanceOpLoad    = "load"    // Record was bulk loaded
+	ProvenanceOpRestore = "restore" // Record was restored after a soft delete
+)
+
+// ProvenanceStep records a single step in the history of how an entry was created or changed
+type ProvenanceStep struct {
+	Operation      string            `json:"operation" xml:"operation" yaml:"operation"`                          // Write path that recorded this step: "add", "update", "load", "restore"
+	Origin         string            `json:"origin" xml:"origin" yaml:"origin"`                                   // How the knowledge was obtained: "conversation", "generation", "ingestion", "command", "system"
+	ActorID        string            `json:"actorId,omitempty" xml:"actorId" yaml:"actorId"`                      // Entity that caused this step
+	ActorType      string            `json:"actorType,omitempty" xml:"actorType" yaml:"actorType"`                // Type of the actor: "agent", "human", "system", etc.
+	ConversationID string            `json:"conversationId,omitempty" xml:"conversationId" yaml:"conversationId"` // Conversation the knowledge came from
+	MessageID      string            `json:"messageId,omitempty" xml:"messageId" yaml:"messageId"`                // Message the knowledge came from
+	Model          string            `json:"model,omitempty" xml:"model" yaml:"model"`                            // LLM model that generated the knowledge
+	JobID          string            `json:"jobId,omitempty" xml:"jobId" yaml:"jobId"`                            // Ingestion job that imported the knowledge
+	Command        string            `json:"command,omitempty" xml:"command" yaml:"command"`                      // Human command that created the knowledge
+	Timestamp      time.Time         `json:"timestamp" xml:"timestamp" yaml:"timestamp"`                          // When this step happened
+	Details        map[string]string `json:"details,omitempty" xml:"details" yaml:"details"`                      // Additional free-form context
+}
+
+// FromConversation creates a provenance step for knowledge learned in a conversation
+func FromConversation(conversationID, messageID string) ProvenanceStep {
+	return ProvenanceStep{Origin: OriginConversation, ConversationID: conversationID, MessageID: messageID, Timestamp: time.Now()}
+}
+
+// FromGeneration creates a provenance step for knowledge produced by an LLM
+func FromGeneration(model, conversationID string) ProvenanceStep {
+	return ProvenanceStep{Origin: OriginGeneration, Model: model, ConversationID: conversationID, Timestamp: time.Now()}
+}
+
+// FromIngestion creates a provenance step for knowledge imported by an ingestion job
+func FromIngestion(jobID string) ProvenanceStep {
+	return ProvenanceStep{Origin: OriginIngestion, JobID: jobID, Timestamp: time.Now()}
+}
+
+// FromCommand creates a provenance step for knowledge created by a human command
+func FromCommand(command, humanID string) ProvenanceStep {
+	return ProvenanceStep{Origin: OriginCommand, Command: command, ActorID: humanID, ActorType: "human", Timestamp: time.Now()}
+}
+
+// originFromSourceType maps an entry's SourceType to a provenance origin
+func originFromSourceType(sourceType string) string {
+	switch strings.ToLower(sourceType) {
+	case "chat", "conversation", "message":
+		return OriginConversation
+	case "llm", "generation":
+		return OriginGeneration
+	case "import", "ingestion", "api":
+		return OriginIngestion
+	case "command", "cli":
+		return OriginCommand
+	default:
+		return OriginSystem
+	}
+}
+
+// recordProvenance returns the provenance chain to store for a write of record.
+// Steps supplied by the caller that are not in the existing chain are appended and
+// completed with operation, actor and timestamp, whether the caller extended a copy of
+// the chain or supplied only its new steps; otherwise an automatic step is appended so
+// every write path leaves a trace.
+func recordProvenance(existing []ProvenanceStep, record Entry, operation string, now time.Time) []ProvenanceStep {
+	chain := make([]ProvenanceStep, 0, len(existing)+1)
+	chain = append(chain, existing...)
+
+	added := false
+	for _, step := range record.Provenance {
+		if slices.ContainsFunc(existing, step.equal) {
+			continue
+		}
+		if step.Operation == "" {
+			step.Operation = operation
+		}
+		if step.Origin == "" {
+			step.Origin = originFromSourceType(record.SourceType)
+		}
+		if step.ActorID == "" {
+			step.ActorID = record.OwnerID
+			step.ActorType = record.OwnerType
+		}
+		if step.Timestamp.IsZero() {
+			step.Timestamp = now
+		}
+		chain = append(chain, step)
+		added = true
+	}
+	if added {
+		return chain
+	}
+
+	step := ProvenanceStep{
+		Operation: operation,
+		Origin:    originFromSourceType(record.SourceType),
+		ActorID:   record.OwnerID,
+		ActorType: record.OwnerType,
+		Timestamp: now,
+	}
+	if record.SourceID != "" {
+		step.Details = map[string]string{"sourceId": record.SourceID}
+	}
+	return append(chain, step)
+}
+
+// equal reports whether two steps record the same thing at the same time
+func (s ProvenanceStep) equal(other ProvenanceStep) bool {
+	return s.Operation == other.Operation && s.Origin == other.Origin &&
+		s.ActorID == other.ActorID && s.ActorType == other.ActorType &&
+		s.ConversationID == other.ConversationID && s.MessageID == other.MessageID &&
+		s.Model == other.Model && s.JobID == other.JobID && s.Command == other.Command &&
+		s.Timestamp.Equal(other.Timestamp) && maps.Equal(s.Details, other.Details)
+}
+
+// matchesProvenance checks if any step in the chain matches the condition.
+// The condition value is a map of ProvenanceStep field names to expected values,
+// e.g. map[string]string{"Origin": OriginGeneration, "Model": "gpt-4o"}.
+func matchesProvenance(chain []ProvenanceStep, condition Condition) bool {
+	var want map[string]string
+	switch v := condition.Value.(type) {
+	case map[string]string:
+		want = v
+	case map[string]interface{}:
+		want = make(map[string]string, len(v))
+		for k, val := range v {
+			want[k] = fmt.Sprintf("%v", val)
+		}
+	case string:
+		// A plain string matches the origin of any step
+		want = map[string]string{"Origin": v}
+	default:
+		return false
+	}
+
+	found := false
+	for _, step := range chain {
+		if provenanceStepMatches(step, want) {
+			found = true
+			break
+		}
+	}
+
+	switch condition.Operator {
+	case "CONTAINS", "=":
+		return found
+	case "!=", "NOT CONTAINS":
+		return !found
+	default:
+		return false
+	}
+}
+
+// provenanceStepMatches checks if a single step has all of the wanted field values
+func provenanceStepMatches(step ProvenanceStep, want map[string]string) bool {
+	for field, value := range want {
+		var actual string
+		switch field {
+		case "Operation":
+			actual = step.Operation
+		case "Origin":
+			actual = step.Origin
+		case "ActorID":
+			actual = step.ActorID
+		case "ActorType":
+			actual = step.ActorType
+		case "ConversationID":
+			actual = step.ConversationID
+		case "MessageID":
+			actual = step.MessageID
+		case "Model":
+			actual = step.Model
+		case "JobID":
+			actual = step.JobID
+		case "Command":
+			actual = step.Command
+		default:
+			actual = step.Details[field]
+		}
+		if actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Explain returns a human-readable description of the entry's provenance chain,
+// answering "why does the agent believe this?"
+func (e Entry) Explain() string {
+	if len(e.Provenance) == 0 {
+		return fmt.Sprintf("No provenance recorded for %s", e.ID)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Provenance for %s:\n", e.ID))
+	for i, step := range e.Provenance {
+		sb.WriteString(fmt.Sprintf("  %d. %s via %s", i+1, step.Operation, step.Origin))
+		if step.ActorID != "" {
+			sb.WriteString(fmt.Sprintf(" by %s", step.ActorID))
+			if step.ActorType != "" {
+				sb.WriteString(fmt.Sprintf(" (%s)", step.ActorType))
+			}
+		}
+		if step.ConversationID != "" {
+			sb.WriteString(fmt.Sprintf(", conversation %s", step.ConversationID))
+		}
+		if step.MessageID != "" {
+			sb.WriteString(fmt.Sprintf(", message %s", step.MessageID))
+		}
+		if step.Model != "" {
+			sb.WriteString(fmt.Sprintf(", model %s", step.Model))
+		}
+		if step.JobID != "" {
+			sb.WriteString(fmt.Sprintf(", job %s", step.JobID))
+		}
+		if step.Command != "" {
+			sb.WriteString(fmt.Sprintf(", command %q", step.Command))
+		}
+		if !step.Timestamp.IsZero() {
+			sb.WriteString(fmt.Sprintf(" at %s", step.Timestamp.Format(time.RFC3339)))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,169 @@
+package knowledge
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProvenance_WritePaths(t *testing.T) {
+	fileStore, err := NewFileStore(filepath.Join(t.TempDir(), "knowledge.json"))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	memoryStore, _ := NewMemoryStore()
+
+	stores := map[string]Store{
+		"MemoryStore": memoryStore,
+		"FileStore":   fileStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if err := store.Open(); err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+			defer store.Close()
+
+			// Add with caller-supplied provenance
+			record := Entry{
+				ID:         "prov-1",
+				Category:   CategoryFact,
+				Content:    []byte("We use Go"),
+				OwnerID:    "agent-1",
+				OwnerType:  "agent",
+				SourceType: "chat",
+				Provenance: []ProvenanceStep{FromGeneration("gpt-4o", "conv-1")},
+			}
+			if err := store.AddRecord(record); err != nil {
+				t.Fatalf("Failed to add record: %v", err)
+			}
+
+			added, _ := store.GetRecord("prov-1")
+			if len(added.Provenance) != 1 {
+				t.Fatalf("Expected 1 provenance step, got %d", len(added.Provenance))
+			}
+			step := added.Provenance[0]
+			if step.Operation != ProvenanceOpAdd || step.Origin != OriginGeneration || step.Model != "gpt-4o" {
+				t.Errorf("Unexpected provenance step: %+v", step)
+			}
+			if step.ActorID != "agent-1" {
+				t.Errorf("Expected actor to default to owner, got %q", step.ActorID)
+			}
+
+			// Update without touching provenance appends an automatic step
+			added.Content = []byte("We use Go 1.24")
+			if err := store.UpdateRecord(added); err != nil {
+				t.Fatalf("Failed to update record: %v", err)
+			}
+			updated, _ := store.GetRecord("prov-1")
+			if len(updated.Provenance) != 2 {
+				t.Fatalf("Expected 2 provenance steps, got %d", len(updated.Provenance))
+			}
+			if updated.Provenance[1].Operation != ProvenanceOpUpdate || updated.Provenance[1].Origin != OriginConversation {
+				t.Errorf("Unexpected update step: %+v", updated.Provenance[1])
+			}
+
+			// Delete and restore records a restore step
+			_ = store.DeleteRecord("prov-1")
+			if err := store.RestoreRecord("prov-1"); err != nil {
+				t.Fatalf("Failed to restore record: %v", err)
+			}
+			restored, _ := store.GetRecord("prov-1")
+			if len(restored.Provenance) != 3 || restored.Provenance[2].Operation != ProvenanceOpRestore {
+				t.Errorf("Expected restore step, got %+v", restored.Provenance)
+			}
+
+			// Bulk loading records a load step
+			if err := store.LoadRecords(Entry{ID: "prov-2", SourceType: "import"}); err != nil {
+				t.Fatalf("Failed to load records: %v", err)
+			}
+			loaded, _ := store.GetRecord("prov-2")
+			if len(loaded.Provenance) != 1 || loaded.Provenance[0].Operation != ProvenanceOpLoad || loaded.Provenance[0].Origin != OriginIngestion {
+				t.Errorf("Unexpected load provenance: %+v", loaded.Provenance)
+			}
+
+			// Provenance is queryable
+			results, err := store.SearchRecords(Filter{
+				RootGroup: FilterGroup{
+					Operator: OpAnd,
+					Conditions: []Condition{
+						{Field: "Provenance", Operator: "CONTAINS", Value: map[string]string{"Origin": OriginGeneration, "ConversationID": "conv-1"}},
+					},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to search records: %v", err)
+			}
+			if len(results) != 1 || results[0].ID != "prov-1" {
+				t.Errorf("Expected to find prov-1 by provenance, got %d results", len(results))
+			}
+
+			results, _ = store.SearchRecords(Filter{
+				RootGroup: FilterGroup{
+					Operator:   OpAnd,
+					Conditions: []Condition{{Field: "Provenance", Operator: "CONTAINS", Value: OriginIngestion}},
+				},
+			})
+			if len(results) != 1 || results[0].ID != "prov-2" {
+				t.Errorf("Expected to find prov-2 by origin, got %d results", len(results))
+			}
+
+			explanation := restored.Explain()
+			if !strings.Contains(explanation, "model gpt-4o") || !strings.Contains(explanation, "restore") {
+				t.Errorf("Unexpected explanation: %s", explanation)
+			}
+		})
+	}
+}
+
+func TestProvenance_CallerStepsAppendedToLongerChain(t *testing.T) {
+	fileStore, err := NewFileStore(filepath.Join(t.TempDir(), "knowledge.json"))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	memoryStore, _ := NewMemoryStore()
+
+	stores := map[string]Store{
+		"MemoryStore": memoryStore,
+		"FileStore":   fileStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if err := store.Open(); err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+			defer store.Close()
+
+			record := Entry{ID: "prov-1", Category: CategoryFact, Content: []byte("We use Go"), OwnerID: "agent-1", OwnerType: "agent"}
+			if err := store.AddRecord(record); err != nil {
+				t.Fatalf("Failed to add record: %v", err)
+			}
+			record.Content = []byte("We use Go 1.24")
+			if err := store.UpdateRecord(record); err != nil {
+				t.Fatalf("Failed to update record: %v", err)
+			}
+
+			// A single new step, shorter than the stored chain of two, is still kept
+			record.Provenance = []ProvenanceStep{FromGeneration("gpt-4o", "conv-1")}
+			if err := store.UpdateRecord(record); err != nil {
+				t.Fatalf("Failed to update record: %v", err)
+			}
+			updated, _ := store.GetRecord("prov-1")
+			if len(updated.Provenance) != 3 || updated.Provenance[2].Model != "gpt-4o" || updated.Provenance[2].Operation != ProvenanceOpUpdate {
+				t.Fatalf("Expected the caller's step to be appended, got %+v", updated.Provenance)
+			}
+
+			// A copy of the stored chain extended with a step gets that step only
+			updated.Provenance = append(updated.Provenance, FromCommand("/remember", "human-1"))
+			if err := store.UpdateRecord(updated); err != nil {
+				t.Fatalf("Failed to update record: %v", err)
+			}
+			extended, _ := store.GetRecord("prov-1")
+			if len(extended.Provenance) != 4 || extended.Provenance[3].Origin != OriginCommand {
+				t.Errorf("Expected the extended chain to gain one step, got %+v", extended.Provenance)
+			}
+		})
+	}
+}
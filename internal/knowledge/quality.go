@@ -0,0 +1,455 @@
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// QualityOptions configures the checks performed by a quality report
+type QualityOptions struct {
+	StaleAfter          time.Duration // Entries not updated for this long are reported as stale
+	DuplicateThreshold  float64       // Word-shingle similarity (0-1) at which two entries are near-duplicates
+	MaxIssuesPerSection int           // Maximum number of issues listed per section, 0 for no limit
+}
+
+// DefaultQualityOptions returns the default quality report options
+func DefaultQualityOptions() QualityOptions {
+	return QualityOptions{
+		StaleAfter:          90 * 24 * time.Hour,
+		DuplicateThreshold:  0.85,
+		MaxIssuesPerSection: 50,
+	}
+}
+
+// QualityIssue describes a problem with a single entry
+type QualityIssue struct {
+	EntryID string `json:"entryId"`           // Entry with the problem
+	Detail  string `json:"detail"`            // Human-readable description of the problem
+	Related string `json:"related,omitempty"` // Related entry, e.g. the duplicate or missing reference
+}
+
+// QualityReport summarizes the health of a knowledge store
+type QualityReport struct {
+	GeneratedAt          time.Time      `json:"generatedAt"`
+	TotalEntries         int            `json:"totalEntries"`
+	Stale                []QualityIssue `json:"stale"`
+	MissingTags          []QualityIssue `json:"missingTags"`
+	MissingOwner         []QualityIssue `json:"missingOwner"`
+	NearDuplicates       []QualityIssue `json:"nearDuplicates"`
+	DanglingReferences   []QualityIssue `json:"danglingReferences"`
+	ValidationFailures   []QualityIssue `json:"validationFailures"`
+	CategoryDistribution map[string]int `json:"categoryDistribution"`
+	CategoryChanges      map[string]int `json:"categoryChanges,omitempty"` // Change in count per category since the previous report
+	Truncated            bool           `json:"truncated,omitempty"`       // Whether any section was cut at MaxIssuesPerSection
+}
+
+// IssueCount returns the total number of issues in the report
+func (r QualityReport) IssueCount() int {
+	return len(r.Stale) + len(r.MissingTags) + len(r.MissingOwner) +
+		len(r.NearDuplicates) + len(r.DanglingReferences) + len(r.ValidationFailures)
+}
+
+// GenerateQualityReport inspects all active entries of the store and reports quality problems.
+// If previous is not nil, category distribution changes are computed against it.
+func GenerateQualityReport(store Store, options QualityOptions, previous *QualityReport) (QualityReport, error) {
+	entries, err := store.SearchRecords(Filter{})
+	if err != nil {
+		return QualityReport{}, fmt.Errorf("failed to load entries for quality report: %w", err)
+	}
+	deleted, err := store.SearchRecords(Filter{OnlyDeleted: true})
+	if err != nil {
+		return QualityReport{}, fmt.Errorf("failed to load deleted entries for quality report: %w", err)
+	}
+
+	// Stable ordering keeps reports comparable between runs
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+
+	now := time.Now()
+	report := QualityReport{
+		GeneratedAt:          now,
+		TotalEntries:         len(entries),
+		CategoryDistribution: make(map[string]int),
+	}
+
+	active := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		active[entry.ID] = true
+	}
+	deletedIDs := make(map[string]bool, len(deleted))
+	for _, entry := range deleted {
+		deletedIDs[entry.ID] = true
+	}
+
+	// add appends an issue to a section, respecting the per-section limit
+	add := func(section *[]QualityIssue, issue QualityIssue) {
+		if options.MaxIssuesPerSection > 0 && len(*section) >= options.MaxIssuesPerSection {
+			report.Truncated = true
+			return
+		}
+		*section = append(*section, issue)
+	}
+
+	for _, entry := range entries {
+		report.CategoryDistribution[entry.Category]++
+
+		if options.StaleAfter > 0 && !entry.UpdatedAt.IsZero() && now.Sub(entry.UpdatedAt) > options.StaleAfter {
+			add(&report.Stale, QualityIssue{
+				EntryID: entry.ID,
+				Detail:  fmt.Sprintf("not updated for %d days", int(now.Sub(entry.UpdatedAt).Hours()/24)),
+			})
+		}
+
+		if len(entry.Tags) == 0 {
+			add(&report.MissingTags, QualityIssue{EntryID: entry.ID, Detail: "entry has no tags"})
+		}
+
+		if entry.OwnerID == "" {
+			add(&report.MissingOwner, QualityIssue{EntryID: entry.ID, Detail: "entry has no owner"})
+		}
+
+		for _, ref := range entry.References {
+			switch {
+			case active[ref.ID]:
+				// Reference is fine
+			case deletedIDs[ref.ID]:
+				add(&report.DanglingReferences, QualityIssue{EntryID: entry.ID, Detail: "references a deleted entry", Related: ref.ID})
+			default:
+				add(&report.DanglingReferences, QualityIssue{EntryID: entry.ID, Detail: "references a missing entry", Related: ref.ID})
+			}
+		}
+
+		for _, problem := range validateEntry(entry) {
+			add(&report.ValidationFailures, QualityIssue{EntryID: entry.ID, Detail: problem})
+		}
+	}
+
+	for _, pair := range findNearDuplicates(entries, options.DuplicateThreshold) {
+		add(&report.NearDuplicates, pair)
+	}
+
+	if previous != nil {
+		report.CategoryChanges = make(map[string]int)
+		for category, count := range report.CategoryDistribution {
+			if delta := count - previous.CategoryDistribution[category]; delta != 0 {
+				report.CategoryChanges[category] = delta
+			}
+		}
+		for category, count := range previous.CategoryDistribution {
+			if _, exists := report.CategoryDistribution[category]; !exists {
+				report.CategoryChanges[category] = -count
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// validateEntry returns the problems that make an entry invalid
+func validateEntry(entry Entry) []string {
+	problems := make([]string, 0)
+
+	switch entry.Category {
+	case CategoryFact, CategoryMessage, CategoryDecision, CategoryAction:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown category %q", entry.Category))
+	}
+
+	if len(entry.Content) == 0 {
+		problems = append(problems, "content is empty")
+	} else if entry.ContentType == ContentTypeJSON && !json.Valid(entry.Content) {
+		problems = append(problems, "content is not valid JSON")
+	}
+
+	if entry.Importance < ImportanceNone || entry.Importance > ImportanceCritical {
+		problems = append(problems, fmt.Sprintf("importance %d is out of range", entry.Importance))
+	}
+
+	if !entry.ExpiresAt.IsZero() && !entry.CreatedAt.IsZero() && entry.ExpiresAt.Before(entry.CreatedAt) {
+		problems = append(problems, "expires before it was created")
+	}
+
+	return problems
+}
+
+// findNearDuplicates compares text entries within each category and returns pairs
+// whose word-shingle similarity is at or above the threshold
+func findNearDuplicates(entries []Entry, threshold float64) []QualityIssue {
+	if threshold <= 0 {
+		return nil
+	}
+
+	type candidate struct {
+		id       string
+		shingles map[string]bool
+	}
+	byCategory := make(map[string][]candidate)
+	categories := make([]string, 0)
+	for _, entry := range entries {
+		if entry.ContentType != "" && entry.ContentType != ContentTypeText && entry.ContentType != ContentTypeMarkdown {
+			continue
+		}
+		shingles := contentShingles(string(entry.Content))
+		if len(shingles) == 0 {
+			continue
+		}
+		if _, exists := byCategory[entry.Category]; !exists {
+			categories = append(categories, entry.Category)
+		}
+		byCategory[entry.Category] = append(byCategory[entry.Category], candidate{id: entry.ID, shingles: shingles})
+	}
+	sort.Strings(categories)
+
+	issues := make([]QualityIssue, 0)
+	for _, category := range categories {
+		group := byCategory[category]
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				similarity := jaccard(group[i].shingles, group[j].shingles)
+				if similarity >= threshold {
+					issues = append(issues, QualityIssue{
+						EntryID: group[i].id,
+						Detail:  fmt.Sprintf("%.0f%% similar content", similarity*100),
+						Related: group[j].id,
+					})
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// contentShingles returns the set of normalized word pairs in the text.
+// Single-word texts produce a single shingle.
+func contentShingles(text string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	shingles := make(map[string]bool)
+	if len(words) == 1 {
+		shingles[words[0]] = true
+	}
+	for i := 0; i+1 < len(words); i++ {
+		shingles[words[i]+" "+words[i+1]] = true
+	}
+	return shingles
+}
+
+// jaccard returns the Jaccard similarity of two sets
+func jaccard(a, b map[string]bool) float64 {
+	intersection := 0
+	for k := range a {
+		if b[k] {
+			intersection++
+		}
+	}
+	union := len(a) + len(b) - intersection
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
+
+// Digest returns a short plain-text summary of the report suitable for delivery to humans
+func (r QualityReport) Digest() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Knowledge quality report (%s)\n", r.GeneratedAt.Format(time.RFC1123)))
+	sb.WriteString(fmt.Sprintf("%d entries, %d issues\n", r.TotalEntries, r.IssueCount()))
+
+	sections := []struct {
+		name   string
+		issues []QualityIssue
+	}{
+		{"Stale entries", r.Stale},
+		{"Missing tags", r.MissingTags},
+		{"Missing owner", r.MissingOwner},
+		{"Near-duplicates", r.NearDuplicates},
+		{"Dangling references", r.DanglingReferences},
+		{"Validation failures", r.ValidationFailures},
+	}
+	for _, section := range sections {
+		if len(section.issues) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n%s (%d):\n", section.name, len(section.issues)))
+		for i, issue := range section.issues {
+			// Keep the digest short; the full list is available through the API
+			if i == 5 {
+				sb.WriteString(fmt.Sprintf("  ... and %d more\n", len(section.issues)-5))
+				break
+			}
+			if issue.Related != "" {
+				sb.WriteString(fmt.Sprintf("  - %s: %s (%s)\n", issue.EntryID, issue.Detail, issue.Related))
+			} else {
+				sb.WriteString(fmt.Sprintf("  - %s: %s\n", issue.EntryID, issue.Detail))
+			}
+		}
+	}
+
+	categories := make([]string, 0, len(r.CategoryDistribution))
+	for category := range r.CategoryDistribution {
+		categories = append(categories, category)
+	}
+	for category := range r.CategoryChanges {
+		if _, exists := r.CategoryDistribution[category]; !exists {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+
+	sb.WriteString("\nCategories:\n")
+	for _, category := range categories {
+		line := fmt.Sprintf("  %s: %d", category, r.CategoryDistribution[category])
+		if delta := r.CategoryChanges[category]; delta != 0 {
+			line += fmt.Sprintf(" (%+d)", delta)
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	if r.Truncated {
+		sb.WriteString("\nSome sections were truncated.\n")
+	}
+	return sb.String()
+}
+
+// QualityReporter periodically generates quality reports and delivers them as digests.
+// It also serves the latest report as JSON over HTTP for the admin UI.
+type QualityReporter struct {
+	store    Store
+	options  QualityOptions
+	interval time.Duration
+	deliver  func(report QualityReport)
+	onError  func(err error)
+
+	mu     sync.RWMutex
+	latest *QualityReport
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewQualityReporter creates a reporter that generates a report every interval and passes it to deliver
+func NewQualityReporter(store Store, interval time.Duration, options QualityOptions, deliver func(report QualityReport)) *QualityReporter {
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	return &QualityReporter{
+		store:    store,
+		options:  options,
+		interval: interval,
+		deliver:  deliver,
+		onError:  func(err error) {},
+	}
+}
+
+// OnError sets a callback for errors encountered by scheduled runs
+func (q *QualityReporter) OnError(handler func(err error)) {
+	if handler != nil {
+		q.onError = handler
+	}
+}
+
+// Start runs the reporter on its schedule until the context is done or Stop is called
+func (q *QualityReporter) Start(ctx context.Context) {
+	q.mu.Lock()
+	if q.stopCh != nil {
+		q.mu.Unlock()
+		return
+	}
+	q.stopCh = make(chan struct{})
+	q.doneCh = make(chan struct{})
+	stopCh, doneCh := q.stopCh, q.doneCh
+	q.mu.Unlock()
+
+	go func() {
+		defer close(doneCh)
+		ticker := time.NewTicker(q.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if _, err := q.Run(); err != nil {
+					q.onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduled runs and waits for an in-progress run to finish
+func (q *QualityReporter) Stop() {
+	q.mu.Lock()
+	stopCh, doneCh := q.stopCh, q.doneCh
+	q.stopCh, q.doneCh = nil, nil
+	q.mu.Unlock()
+
+	if stopCh != nil {
+		close(stopCh)
+		<-doneCh
+	}
+}
+
+// Run generates a report immediately, stores it as the latest and delivers it
+func (q *QualityReporter) Run() (QualityReport, error) {
+	q.mu.RLock()
+	previous := q.latest
+	q.mu.RUnlock()
+
+	report, err := GenerateQualityReport(q.store, q.options, previous)
+	if err != nil {
+		return QualityReport{}, err
+	}
+
+	q.mu.Lock()
+	q.latest = &report
+	q.mu.Unlock()
+
+	if q.deliver != nil {
+		q.deliver(report)
+	}
+	return report, nil
+}
+
+// Latest returns the most recent report, if one has been generated
+func (q *QualityReporter) Latest() (QualityReport, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.latest == nil {
+		return QualityReport{}, false
+	}
+	return *q.latest, true
+}
+
+// ServeHTTP serves the latest report as JSON. A report is generated on demand if none
+// exists yet or if the request is a POST.
+func (q *QualityReporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, ok := q.Latest()
+	if !ok || r.Method == http.MethodPost {
+		var err error
+		report, err = q.Run()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
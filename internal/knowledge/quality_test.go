@@ -0,0 +1,221 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newQualityTestStore(t *testing.T) *MemoryStore {
+	store, err := NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create memory store: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	old := time.Now().Add(-200 * 24 * time.Hour)
+	entries := []Entry{
+		{ID: "fresh", Category: CategoryFact, ContentType: ContentTypeText, Content: []byte("We deploy the web app on Fridays"), OwnerID: "u1", Tags: []string{"ops"}},
+		{ID: "stale", Category: CategoryFact, ContentType: ContentTypeText, Content: []byte("Legacy billing runs on the old cluster"), OwnerID: "u1", Tags: []string{"billing"}, UpdatedAt: old, CreatedAt: old},
+		{ID: "untagged", Category: CategoryDecision, ContentType: ContentTypeText, Content: []byte("Use Go for the backend services"), OwnerID: "u1"},
+		{ID: "orphan", Category: CategoryDecision, ContentType: ContentTypeText, Content: []byte("Adopt trunk based development"), Tags: []string{"process"}},
+		{ID: "dup", Category: CategoryFact, ContentType: ContentTypeText, Content: []byte("We deploy the web app on Fridays!"), OwnerID: "u1", Tags: []string{"ops"}},
+		{ID: "badjson", Category: CategoryAction, ContentType: ContentTypeJSON, Content: []byte("{not json"), OwnerID: "u1", Tags: []string{"x"}},
+		{ID: "refs", Category: CategoryAction, ContentType: ContentTypeText, Content: []byte("Shipped release 1.2"), OwnerID: "u1", Tags: []string{"release"},
+			References: []Reference{{ID: "fresh"}, {ID: "gone"}, {ID: "removed"}}},
+		{ID: "removed", Category: CategoryFact, ContentType: ContentTypeText, Content: []byte("Temporary note"), OwnerID: "u1", Tags: []string{"tmp"}},
+	}
+	for _, entry := range entries {
+		if err := store.AddRecord(entry); err != nil {
+			t.Fatalf("Failed to add record %s: %v", entry.ID, err)
+		}
+	}
+	if err := store.DeleteRecord("removed"); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+	return store
+}
+
+func issueIDs(issues []QualityIssue) []string {
+	ids := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		ids = append(ids, issue.EntryID)
+	}
+	return ids
+}
+
+func TestGenerateQualityReport(t *testing.T) {
+	store := newQualityTestStore(t)
+
+	report, err := GenerateQualityReport(store, DefaultQualityOptions(), nil)
+	if err != nil {
+		t.Fatalf("Failed to generate report: %v", err)
+	}
+
+	if report.TotalEntries != 7 {
+		t.Errorf("Expected 7 entries, got %d", report.TotalEntries)
+	}
+	if ids := issueIDs(report.Stale); len(ids) != 1 || ids[0] != "stale" {
+		t.Errorf("Expected only 'stale' to be stale, got %v", ids)
+	}
+	if ids := issueIDs(report.MissingTags); len(ids) != 1 || ids[0] != "untagged" {
+		t.Errorf("Expected only 'untagged' to miss tags, got %v", ids)
+	}
+	if ids := issueIDs(report.MissingOwner); len(ids) != 1 || ids[0] != "orphan" {
+		t.Errorf("Expected only 'orphan' to miss an owner, got %v", ids)
+	}
+	if len(report.NearDuplicates) != 1 || report.NearDuplicates[0].EntryID != "dup" || report.NearDuplicates[0].Related != "fresh" {
+		t.Errorf("Expected dup/fresh near-duplicate pair, got %+v", report.NearDuplicates)
+	}
+	if ids := issueIDs(report.ValidationFailures); len(ids) != 1 || ids[0] != "badjson" {
+		t.Errorf("Expected only 'badjson' to fail validation, got %v", ids)
+	}
+
+	if len(report.DanglingReferences) != 2 {
+		t.Fatalf("Expected 2 dangling references, got %+v", report.DanglingReferences)
+	}
+	details := map[string]string{}
+	for _, issue := range report.DanglingReferences {
+		details[issue.Related] = issue.Detail
+	}
+	if !strings.Contains(details["gone"], "missing") {
+		t.Errorf("Expected 'gone' to be reported as missing, got %q", details["gone"])
+	}
+	if !strings.Contains(details["removed"], "deleted") {
+		t.Errorf("Expected 'removed' to be reported as deleted, got %q", details["removed"])
+	}
+
+	if report.CategoryDistribution[CategoryFact] != 3 || report.CategoryDistribution[CategoryDecision] != 2 {
+		t.Errorf("Unexpected category distribution: %v", report.CategoryDistribution)
+	}
+	if report.CategoryChanges != nil {
+		t.Errorf("Expected no category changes without a previous report, got %v", report.CategoryChanges)
+	}
+}
+
+func TestQualityReportCategoryChanges(t *testing.T) {
+	store := newQualityTestStore(t)
+
+	first, err := GenerateQualityReport(store, DefaultQualityOptions(), nil)
+	if err != nil {
+		t.Fatalf("Failed to generate report: %v", err)
+	}
+
+	store.DeleteRecord("untagged")
+	store.DeleteRecord("orphan")
+	store.AddRecord(Entry{ID: "msg", Category: CategoryMessage, Content: []byte("hello"), OwnerID: "u1", Tags: []string{"chat"}})
+
+	second, err := GenerateQualityReport(store, DefaultQualityOptions(), &first)
+	if err != nil {
+		t.Fatalf("Failed to generate report: %v", err)
+	}
+
+	if second.CategoryChanges[CategoryDecision] != -2 {
+		t.Errorf("Expected decision change of -2, got %d", second.CategoryChanges[CategoryDecision])
+	}
+	if second.CategoryChanges[CategoryMessage] != 1 {
+		t.Errorf("Expected message change of +1, got %d", second.CategoryChanges[CategoryMessage])
+	}
+	if _, exists := second.CategoryChanges[CategoryFact]; exists {
+		t.Errorf("Expected no change for unchanged category, got %v", second.CategoryChanges)
+	}
+
+	digest := second.Digest()
+	if !strings.Contains(digest, "decision: 0 (-2)") {
+		t.Errorf("Expected digest to show the removed category, got:\n%s", digest)
+	}
+	if !strings.Contains(digest, "Validation failures (1)") {
+		t.Errorf("Expected digest to list validation failures, got:\n%s", digest)
+	}
+}
+
+func TestQualityReportTruncation(t *testing.T) {
+	store := newQualityTestStore(t)
+
+	options := DefaultQualityOptions()
+	options.MaxIssuesPerSection = 1
+	report, err := GenerateQualityReport(store, options, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate report: %v", err)
+	}
+
+	if len(report.DanglingReferences) != 1 {
+		t.Errorf("Expected dangling references to be cut to 1, got %d", len(report.DanglingReferences))
+	}
+	if !report.Truncated {
+		t.Error("Expected report to be marked as truncated")
+	}
+}
+
+func TestQualityReporterServeHTTP(t *testing.T) {
+	store := newQualityTestStore(t)
+
+	delivered := 0
+	reporter := NewQualityReporter(store, time.Hour, DefaultQualityOptions(), func(report QualityReport) {
+		delivered++
+	})
+
+	if _, ok := reporter.Latest(); ok {
+		t.Fatal("Expected no report before the first run")
+	}
+
+	// The first GET generates a report on demand
+	rec := httptest.NewRecorder()
+	reporter.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/quality", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var report QualityReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if report.TotalEntries != 7 {
+		t.Errorf("Expected 7 entries, got %d", report.TotalEntries)
+	}
+
+	// A second GET serves the cached report
+	rec = httptest.NewRecorder()
+	reporter.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/quality", nil))
+	if delivered != 1 {
+		t.Errorf("Expected 1 delivery, got %d", delivered)
+	}
+
+	// POST forces a new run
+	rec = httptest.NewRecorder()
+	reporter.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/quality", nil))
+	if delivered != 2 {
+		t.Errorf("Expected 2 deliveries, got %d", delivered)
+	}
+
+	rec = httptest.NewRecorder()
+	reporter.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/quality", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
+
+func TestQualityReporterSchedule(t *testing.T) {
+	store := newQualityTestStore(t)
+
+	delivered := make(chan QualityReport, 10)
+	reporter := NewQualityReporter(store, 10*time.Millisecond, DefaultQualityOptions(), func(report QualityReport) {
+		delivered <- report
+	})
+
+	reporter.Start(t.Context())
+	defer reporter.Stop()
+
+	select {
+	case report := <-delivered:
+		if report.TotalEntries != 7 {
+			t.Errorf("Expected 7 entries, got %d", report.TotalEntries)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a scheduled report to be delivered")
+	}
+}
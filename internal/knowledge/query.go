@@ -0,0 +1,208 @@
+package knowledge
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// comparisonOperators are the operators supported on scalar fields
+var comparisonOperators = map[string]bool{"=": true, "!=": true, ">": true, "<": true, ">=": true, "<=": true, "CONTAINS": true}
+
+// QueryBuilder builds a Filter fluently, checking field names and operators as it goes:
+//
+//	filter, err := knowledge.Query().
+//		Where("Category", "=", knowledge.CategoryFact).
+//		And("Tags", "CONTAINS", "backend").
+//		OrderBy("CreatedAt").Desc().
+//		Limit(10).
+//		Build()
+//
+// Conditions added with Where and And must all match. Or and Not add nested groups.
+type QueryBuilder struct {
+	filter Filter
+	err    error
+}
+
+// Query starts a new query that matches every active record
+func Query() *QueryBuilder {
+	return &QueryBuilder{filter: Filter{RootGroup: FilterGroup{Operator: OpAnd}}}
+}
+
+// Where adds a condition that must match
+func (q *QueryBuilder) Where(field, operator string, value interface{}) *QueryBuilder {
+	if q.err != nil {
+		return q
+	}
+	if err := validateCondition(field, operator, value); err != nil {
+		q.err = err
+		return q
+	}
+	q.filter.RootGroup.Conditions = append(q.filter.RootGroup.Conditions, Condition{Field: field, Operator: operator, Value: value})
+	return q
+}
+
+// And adds a condition that must match; it is Where under a name that reads better in a chain
+func (q *QueryBuilder) And(field, operator string, value interface{}) *QueryBuilder {
+	return q.Where(field, operator, value)
+}
+
+// WhereMetadata adds a condition that the metadata key has the value
+func (q *QueryBuilder) WhereMetadata(key, value string) *QueryBuilder {
+	if q.err != nil {
+		return q
+	}
+	if key == "" {
+		q.err = errors.New("metadata condition needs a key")
+		return q
+	}
+	q.filter.RootGroup.Conditions = append(q.filter.RootGroup.Conditions,
+		Condition{Field: "Metadata", Operator: "=", Value: map[string]string{key: value}})
+	return q
+}
+
+// Or adds a group that matches if any of the alternatives matches
+func (q *QueryBuilder) Or(alternatives ...*QueryBuilder) *QueryBuilder {
+	if q.err != nil {
+		return q
+	}
+	if len(alternatives) == 0 {
+		q.err = errors.New("Or needs at least one alternative")
+		return q
+	}
+	group := FilterGroup{Operator: OpOr}
+	for _, alternative := range alternatives {
+		if alternative.err != nil {
+			q.err = alternative.err
+			return q
+		}
+		group.Groups = append(group.Groups, alternative.filter.RootGroup)
+	}
+	q.filter.RootGroup.Groups = append(q.filter.RootGroup.Groups, group)
+	return q
+}
+
+// Not adds a group that matches if the query does not
+func (q *QueryBuilder) Not(query *QueryBuilder) *QueryBuilder {
+	if q.err != nil {
+		return q
+	}
+	if query.err != nil {
+		q.err = query.err
+		return q
+	}
+	q.filter.RootGroup.Groups = append(q.filter.RootGroup.Groups,
+		FilterGroup{Operator: OpNot, Groups: []FilterGroup{query.filter.RootGroup}})
+	return q
+}
+
+// OrderBy sorts the results by the field, ascending unless Desc is called
+func (q *QueryBuilder) OrderBy(field string) *QueryBuilder {
+	if q.err != nil {
+		return q
+	}
+	kind, ok := entryFieldKind(field)
+	if !ok {
+		q.err = fmt.Errorf("unknown field %s", field)
+		return q
+	}
+	if kind == reflect.Slice || kind == reflect.Map {
+		q.err = fmt.Errorf("cannot order by %s", field)
+		return q
+	}
+	q.filter.OrderBy = field
+	q.filter.OrderDir = "ASC"
+	return q
+}
+
+// Desc sorts the results in descending order
+func (q *QueryBuilder) Desc() *QueryBuilder {
+	q.filter.OrderDir = "DESC"
+	return q
+}
+
+// Limit returns at most n results
+func (q *QueryBuilder) Limit(n int) *QueryBuilder {
+	if q.err == nil && n < 0 {
+		q.err = fmt.Errorf("invalid limit %d", n)
+	}
+	q.filter.Limit = n
+	return q
+}
+
+// Offset skips the first n results
+func (q *QueryBuilder) Offset(n int) *QueryBuilder {
+	if q.err == nil && n < 0 {
+		q.err = fmt.Errorf("invalid offset %d", n)
+	}
+	q.filter.Offset = n
+	return q
+}
+
+// IncludeDeleted includes soft-deleted records in the results
+func (q *QueryBuilder) IncludeDeleted() *QueryBuilder {
+	q.filter.IncludeDeleted = true
+	return q
+}
+
+// OnlyDeleted returns soft-deleted records only
+func (q *QueryBuilder) OnlyDeleted() *QueryBuilder {
+	q.filter.OnlyDeleted = true
+	return q
+}
+
+// Build returns the filter, or the first error made while building it
+func (q *QueryBuilder) Build() (Filter, error) {
+	if q.err != nil {
+		return Filter{}, fmt.Errorf("invalid knowledge query: %w", q.err)
+	}
+	return q.filter, nil
+}
+
+// validateCondition checks that the operator and value suit the field
+func validateCondition(field, operator string, value interface{}) error {
+	kind, ok := entryFieldKind(field)
+	if !ok {
+		return fmt.Errorf("unknown field %s", field)
+	}
+
+	switch {
+	case field == "Metadata":
+		return errors.New("use WhereMetadata for metadata conditions")
+	case field == "Provenance":
+		if operator != "=" && operator != "!=" && operator != "CONTAINS" && operator != "NOT CONTAINS" {
+			return fmt.Errorf("operator %s is not supported on Provenance", operator)
+		}
+	case field == "Content":
+		if operator != "=" && operator != "!=" && operator != "CONTAINS" {
+			return fmt.Errorf("operator %s is not supported on Content", operator)
+		}
+	case kind == reflect.Slice:
+		if operator != "CONTAINS" {
+			return fmt.Errorf("operator %s is not supported on %s, use CONTAINS", operator, field)
+		}
+	case kind == reflect.Struct:
+		// Time fields
+		if !comparisonOperators[operator] || operator == "CONTAINS" {
+			return fmt.Errorf("operator %s is not supported on %s", operator, field)
+		}
+		if _, ok := value.(time.Time); !ok {
+			return fmt.Errorf("%s must be compared with a time.Time, got %T", field, value)
+		}
+	default:
+		if !comparisonOperators[operator] {
+			return fmt.Errorf("operator %s is not supported on %s", operator, field)
+		}
+	}
+	return nil
+}
+
+// entryFieldKind returns the kind of an Entry field, false if there is no such field
+func entryFieldKind(field string) (reflect.Kind, bool) {
+	structField, ok := reflect.TypeOf(Entry{}).FieldByName(field)
+	if !ok {
+		return reflect.Invalid, false
+	}
+	return structField.Type.Kind(), true
+}
@@ -0,0 +1,104 @@
+package knowledge
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryBuilderValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *QueryBuilder
+		err   string
+	}{
+		{"unknown field", Query().Where("Color", "=", "red"), "unknown field Color"},
+		{"bad operator", Query().Where("Category", "LIKE", "fact"), "operator LIKE"},
+		{"slice without contains", Query().Where("Tags", "=", "go"), "use CONTAINS"},
+		{"time value", Query().Where("CreatedAt", ">", "yesterday"), "time.Time"},
+		{"time contains", Query().Where("UpdatedAt", "CONTAINS", time.Now()), "operator CONTAINS"},
+		{"raw metadata", Query().Where("Metadata", "=", map[string]string{}), "WhereMetadata"},
+		{"order by slice", Query().OrderBy("Tags"), "cannot order by Tags"},
+		{"order by unknown", Query().OrderBy("Color"), "unknown field"},
+		{"negative limit", Query().Limit(-1), "invalid limit"},
+		{"bad alternative", Query().Or(Query().Where("Nope", "=", 1)), "unknown field Nope"},
+		{"first error wins", Query().Where("A", "=", 1).Where("B", "=", 2), "unknown field A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.query.Build()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+
+	filter, err := Query().Where("Category", "=", CategoryFact).And("Importance", ">=", ImportanceHigh).
+		OrderBy("CreatedAt").Desc().Limit(10).Offset(5).IncludeDeleted().Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if len(filter.RootGroup.Conditions) != 2 || filter.RootGroup.Operator != OpAnd ||
+		filter.OrderBy != "CreatedAt" || filter.OrderDir != "DESC" || filter.Limit != 10 || filter.Offset != 5 || !filter.IncludeDeleted {
+		t.Errorf("Unexpected filter: %+v", filter)
+	}
+}
+
+func testQueryBuilder(t *testing.T, store Store) {
+	store.AddRecords(
+		Entry{ID: "go", Category: CategoryFact, Tags: []string{"backend"}, Importance: ImportanceHigh, Metadata: map[string]string{"team": "core"}},
+		Entry{ID: "react", Category: CategoryFact, Tags: []string{"frontend"}, Importance: ImportanceLow, Metadata: map[string]string{"team": "web"}},
+		Entry{ID: "ship", Category: CategoryDecision, Tags: []string{"backend"}, Importance: ImportanceCritical},
+		Entry{ID: "hello", Category: CategoryMessage},
+	)
+
+	tests := []struct {
+		name     string
+		query    *QueryBuilder
+		expected string
+	}{
+		{"everything", Query(), "go,hello,react,ship"},
+		{"and", Query().Where("Category", "=", CategoryFact).And("Tags", "CONTAINS", "backend"), "go"},
+		{"metadata", Query().WhereMetadata("team", "web"), "react"},
+		{"or", Query().Or(
+			Query().Where("Category", "=", CategoryDecision),
+			Query().Where("Tags", "CONTAINS", "frontend")), "react,ship"},
+		{"not", Query().Where("Category", "=", CategoryFact).Not(
+			Query().Where("Importance", "<", ImportanceMedium)), "go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := tt.query.Build()
+			if err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+			results, err := store.SearchRecords(filter)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if got := sortedIDs(results); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	filter, _ := Query().Where("Importance", ">", ImportanceNone).OrderBy("Importance").Desc().Limit(2).Build()
+	results, _ := store.SearchRecords(filter)
+	if len(results) != 2 || results[0].ID != "ship" || results[1].ID != "go" {
+		t.Errorf("Expected ship and go by importance, got %s", sortedIDs(results))
+	}
+}
+
+func TestMemoryStoreQueryBuilder(t *testing.T) {
+	store, _ := NewMemoryStore()
+	store.Open()
+	testQueryBuilder(t, store)
+}
+
+func TestFileStoreQueryBuilder(t *testing.T) {
+	store, _ := NewFileStore(filepath.Join(t.TempDir(), "knowledge.json"))
+	store.Open()
+	defer store.Close()
+	testQueryBuilder(t, store)
+}
@@ -0,0 +1,82 @@
+package knowledge
+
+import (
+	"fmt"
+
+	"goproduct/internal/embeddings"
+)
+
+// indexEmbedding adds the record's embedding to the vector index, or removes a previous
+// one if the record no longer has an embedding
+func indexEmbedding(index *embeddings.Index, record Entry) error {
+	if len(record.Embedding) == 0 {
+		index.Remove(record.ID)
+		return nil
+	}
+	if err := index.Add(record.ID, record.Embedding); err != nil {
+		return fmt.Errorf("invalid embedding for knowledge record %s: %w", record.ID, err)
+	}
+	return nil
+}
+
+// validateEmbeddings checks that the embeddings of a batch of records can all be added
+// to the index, including that they agree on dimensions when the index is empty
+func validateEmbeddings(index *embeddings.Index, records []Entry) error {
+	dimensions := index.Dimensions()
+	for _, record := range records {
+		if len(record.Embedding) == 0 {
+			continue
+		}
+		if err := index.Validate(record.ID, record.Embedding); err != nil {
+			return fmt.Errorf("invalid embedding for knowledge record %s: %w", record.ID, err)
+		}
+		if dimensions == 0 {
+			dimensions = len(record.Embedding)
+		}
+		if len(record.Embedding) != dimensions {
+			return fmt.Errorf("invalid embedding for knowledge record %s: %w: expected %d, got %d",
+				record.ID, embeddings.ErrDimensionMismatch, dimensions, len(record.Embedding))
+		}
+	}
+	return nil
+}
+
+// buildVectorIndex indexes the embeddings of all entries of the given record maps.
+// Entries with embeddings that do not fit the index are left out.
+func buildVectorIndex(recordSets ...map[string]Entry) *embeddings.Index {
+	index := embeddings.NewIndex()
+	for _, records := range recordSets {
+		for _, record := range records {
+			if len(record.Embedding) > 0 {
+				index.Add(record.ID, record.Embedding)
+			}
+		}
+	}
+	return index
+}
+
+// searchSimilar runs a similarity search over the index and resolves the matches
+// to entries. lookup returns the entry for an ID if it should be part of the results.
+func searchSimilar(index *embeddings.Index, vector []float32, topK int, lookup func(id string) (Entry, bool)) ([]SearchResult, error) {
+	if index == nil {
+		return []SearchResult{}, nil
+	}
+
+	entries := make(map[string]Entry)
+	matches, err := index.SearchFunc(vector, topK, func(id string) bool {
+		entry, ok := lookup(id)
+		if ok {
+			entries[id] = entry
+		}
+		return ok
+	})
+	if err != nil {
+		return nil, fmt.Errorf("similarity search failed: %w", err)
+	}
+
+	results := make([]SearchResult, 0, len(matches))
+	for _, match := range matches {
+		results = append(results, SearchResult{Entry: entries[match.ID], Score: float64(match.Score)})
+	}
+	return results, nil
+}
@@ -0,0 +1,100 @@
+package knowledge
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"goproduct/internal/embeddings"
+)
+
+func testSearchSimilar(t *testing.T, store Store) {
+	err := store.LoadRecords(
+		Entry{ID: "go", Category: CategoryFact, Content: []byte("Backend uses Go"), Embedding: []float32{0.9, 0.1, 0}},
+		Entry{ID: "rust", Category: CategoryFact, Content: []byte("CLI uses Rust"), Embedding: []float32{0.7, 0.3, 0}},
+		Entry{ID: "lunch", Category: CategoryMessage, Content: []byte("Pizza on Friday"), Embedding: []float32{0, 0.1, 0.9}},
+		Entry{ID: "plain", Category: CategoryFact, Content: []byte("No embedding")},
+	)
+	if err != nil {
+		t.Fatalf("Failed to load records: %v", err)
+	}
+
+	results, err := store.SearchSimilar([]float32{1, 0, 0}, 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if ids := resultIDs(results); len(ids) != 2 || ids[0] != "go" || ids[1] != "rust" {
+		t.Errorf("Expected [go rust], got %v", ids)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("Expected results ordered by similarity, got %v and %v", results[0].Score, results[1].Score)
+	}
+
+	// Invalid embeddings are rejected without changing the store
+	err = store.AddRecord(Entry{ID: "bad", Category: CategoryFact, Embedding: []float32{1, 2}})
+	if !errors.Is(err, embeddings.ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := store.GetRecord("bad"); err == nil {
+		t.Error("Expected record with invalid embedding not to be added")
+	}
+	err = store.LoadRecords(
+		Entry{ID: "ok", Category: CategoryFact, Embedding: []float32{1, 1, 1}},
+		Entry{ID: "bad", Category: CategoryFact, Embedding: []float32{0, 0, 0}},
+	)
+	if !errors.Is(err, embeddings.ErrZeroVector) {
+		t.Errorf("Expected ErrZeroVector, got %v", err)
+	}
+	if _, err := store.GetRecord("ok"); err == nil {
+		t.Error("Expected batch with an invalid embedding to be rejected as a whole")
+	}
+
+	// Removing an embedding through an update removes it from the index
+	entry, _ := store.GetRecord("go")
+	entry.Embedding = nil
+	if err := store.UpdateRecord(entry); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+
+	// Deleted records are not recalled
+	store.DeleteRecord("rust")
+	results, _ = store.SearchSimilar([]float32{1, 0, 0}, 5)
+	if ids := resultIDs(results); len(ids) != 1 || ids[0] != "lunch" {
+		t.Errorf("Expected [lunch], got %v", ids)
+	}
+}
+
+func TestMemoryStoreSearchSimilar(t *testing.T) {
+	store, _ := NewMemoryStore()
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	testSearchSimilar(t, store)
+}
+
+func TestFileStoreSearchSimilar(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "knowledge.json")
+	store, _ := NewFileStore(filename)
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	testSearchSimilar(t, store)
+	store.RestoreRecord("rust")
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	// Embeddings are persisted and re-indexed on open
+	reopened, _ := NewFileStore(filename)
+	if err := reopened.Open(); err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	results, err := reopened.SearchSimilar([]float32{1, 0, 0}, 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if ids := resultIDs(results); len(ids) != 1 || ids[0] != "rust" {
+		t.Errorf("Expected [rust] after reopening, got %v", ids)
+	}
+}
@@ -0,0 +1,183 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// DefaultWALCompactAfter is the number of log entries after which the log is folded
+// into the snapshot file
+const DefaultWALCompactAfter = 1000
+
+// WAL operations, each carrying the final state of one record
+const (
+	walOpActive  = "active"  // Record is active
+	walOpDeleted = "deleted" // Record is soft-deleted
+	walOpPurge   = "purge"   // Record no longer exists
+)
+
+// walEntry is one line of the write-ahead log
+type walEntry struct {
+	Op     string `json:"op"`
+	ID     string `json:"id"`
+	Record *Entry `json:"record,omitempty"`
+}
+
+// writeAheadLog is the append-only change log of a FileStore
+type writeAheadLog struct {
+	path         string
+	compactAfter int
+	count        int                 // Entries in the log file
+	changed      map[string]struct{} // Records changed since the last append
+}
+
+// newWriteAheadLog creates the log state for the given path
+func newWriteAheadLog(path string, compactAfter int) *writeAheadLog {
+	if compactAfter <= 0 {
+		compactAfter = DefaultWALCompactAfter
+	}
+	return &writeAheadLog{
+		path:         path,
+		compactAfter: compactAfter,
+		changed:      make(map[string]struct{}),
+	}
+}
+
+// reset removes the log after its changes were written to the snapshot
+func (w *writeAheadLog) reset() error {
+	if err := os.Remove(w.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove knowledge write-ahead log: %w", err)
+	}
+	w.count = 0
+	w.changed = make(map[string]struct{})
+	return nil
+}
+
+// markDirty records that the records changed and need to be written
+// (must be called with lock held)
+func (f *FileStore) markDirty(ids ...string) {
+	f.isDirty = true
+	f.dirtyCount += len(ids)
+	if f.autoFlush != nil {
+		f.autoFlush.changed(f.dirtyCount)
+	}
+	if f.wal != nil {
+		for _, id := range ids {
+			f.wal.changed[id] = struct{}{}
+		}
+	}
+}
+
+// appendWAL appends the current state of every changed record to the log and compacts
+// the log into the snapshot once it is long enough (must be called with lock held)
+func (f *FileStore) appendWAL() error {
+	ids := make([]string, 0, len(f.wal.changed))
+	for id := range f.wal.changed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, id := range ids {
+		entry := walEntry{Op: walOpPurge, ID: id}
+		if record, ok := f.records[id]; ok {
+			entry = walEntry{Op: walOpActive, ID: id, Record: &record}
+		} else if record, ok := f.deletedRecs[id]; ok {
+			entry = walEntry{Op: walOpDeleted, ID: id, Record: &record}
+		}
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("failed to marshal knowledge log entry: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(f.wal.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open knowledge write-ahead log: %w", err)
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to append to knowledge write-ahead log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync knowledge write-ahead log: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close knowledge write-ahead log: %w", err)
+	}
+
+	f.wal.count += len(ids)
+	f.wal.changed = make(map[string]struct{})
+	f.isDirty = false
+	f.dirtyCount = 0
+
+	if f.wal.count >= f.wal.compactAfter {
+		return f.flush()
+	}
+	return nil
+}
+
+// replayWAL applies the logged changes to the loaded snapshot. A torn last line from a
+// crash during an append is discarded (must be called with lock held).
+func (f *FileStore) replayWAL() error {
+	if f.wal == nil {
+		return nil
+	}
+	f.wal.count = 0
+	f.wal.changed = make(map[string]struct{})
+
+	data, err := os.ReadFile(f.wal.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read knowledge write-ahead log: %w", err)
+	}
+
+	offset := 0
+	for lineNumber := 1; offset < len(data); lineNumber++ {
+		end := bytes.IndexByte(data[offset:], '\n')
+		if end < 0 {
+			// Unterminated last line: the append was interrupted. Cut it off so the
+			// next append starts on a clean line.
+			if err := os.Truncate(f.wal.path, int64(offset)); err != nil {
+				return fmt.Errorf("failed to repair knowledge write-ahead log: %w", err)
+			}
+			break
+		}
+		line := data[offset : offset+end]
+		offset += end + 1
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var entry walEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("corrupt knowledge write-ahead log at line %d: %w", lineNumber, err)
+		}
+		if entry.Op != walOpPurge && entry.Record == nil {
+			return fmt.Errorf("corrupt knowledge write-ahead log at line %d: missing record", lineNumber)
+		}
+
+		switch entry.Op {
+		case walOpActive:
+			f.records[entry.ID] = *entry.Record
+			delete(f.deletedRecs, entry.ID)
+		case walOpDeleted:
+			f.deletedRecs[entry.ID] = *entry.Record
+			delete(f.records, entry.ID)
+		case walOpPurge:
+			delete(f.records, entry.ID)
+			delete(f.deletedRecs, entry.ID)
+		default:
+			return fmt.Errorf("unknown operation %q in knowledge write-ahead log at line %d", entry.Op, lineNumber)
+		}
+		f.wal.count++
+	}
+	return nil
+}
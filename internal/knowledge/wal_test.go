@@ -0,0 +1,141 @@
+package knowledge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openWALStore(t *testing.T, filename string, compactAfter int) *FileStore {
+	t.Helper()
+	store, err := NewWALFileStore(filename, compactAfter)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	return store
+}
+
+func TestWALFileStoreRecoversFlushedChanges(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "knowledge.json")
+	store := openWALStore(t, filename, 100)
+
+	store.AddRecords(
+		Entry{ID: "keep", Category: CategoryFact, Content: []byte("kept")},
+		Entry{ID: "delete", Category: CategoryFact},
+		Entry{ID: "purge", Category: CategoryFact},
+	)
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	record, _ := store.GetRecord("keep")
+	record.Content = []byte("updated")
+	store.UpdateRecord(record)
+	store.DeleteRecord("delete")
+	store.PurgeRecord("purge")
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// Flushes append to the log instead of writing the snapshot
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Error("Expected no snapshot before compaction")
+	}
+	if info, _ := store.Info(); info["wal_entries"] != "6" {
+		t.Errorf("Expected 6 log entries, got %s", info["wal_entries"])
+	}
+
+	// Simulate a crash: reopen without closing
+	recovered := openWALStore(t, filename, 100)
+	defer recovered.Close()
+	if record, err := recovered.GetRecord("keep"); err != nil || string(record.Content) != "updated" {
+		t.Errorf("Expected updated record after recovery, got %q, %v", record.Content, err)
+	}
+	if _, err := recovered.GetRecord("delete"); err == nil {
+		t.Error("Expected deleted record to stay deleted")
+	}
+	if err := recovered.RestoreRecord("delete"); err != nil {
+		t.Errorf("Expected deleted record to be restorable: %v", err)
+	}
+	if err := recovered.PurgeRecord("purge"); err == nil {
+		t.Error("Expected purged record to stay purged")
+	}
+	if results, _ := recovered.FullTextSearch("updated"); len(results) != 1 {
+		t.Errorf("Expected recovered records to be indexed, got %d results", len(results))
+	}
+}
+
+func TestWALFileStoreCompacts(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "knowledge.json")
+	store := openWALStore(t, filename, 3)
+
+	store.AddRecords(Entry{ID: "a"}, Entry{ID: "b"})
+	store.Flush()
+	if _, err := os.Stat(filename + ".wal"); err != nil {
+		t.Fatalf("Expected log file after flush: %v", err)
+	}
+
+	store.AddRecord(Entry{ID: "c"})
+	store.Flush()
+	if _, err := os.Stat(filename + ".wal"); !os.IsNotExist(err) {
+		t.Error("Expected log to be removed after compaction")
+	}
+	if _, err := os.Stat(filename); err != nil {
+		t.Errorf("Expected snapshot after compaction: %v", err)
+	}
+
+	// Close folds remaining log entries into the snapshot
+	store.AddRecord(Entry{ID: "d"})
+	store.Flush()
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(filename + ".wal"); !os.IsNotExist(err) {
+		t.Error("Expected log to be removed on close")
+	}
+
+	reopened := openWALStore(t, filename, 3)
+	defer reopened.Close()
+	if all, _ := reopened.SearchRecords(Filter{}); len(all) != 4 {
+		t.Errorf("Expected 4 records, got %d", len(all))
+	}
+}
+
+func TestWALFileStoreRepairsTornAppend(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "knowledge.json")
+	store := openWALStore(t, filename, 100)
+	store.AddRecord(Entry{ID: "a", Category: CategoryFact})
+	store.Flush()
+
+	// A crash in the middle of an append leaves a partial line
+	file, _ := os.OpenFile(filename+".wal", os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"op":"active","id":"b","rec`)
+	file.Close()
+
+	recovered := openWALStore(t, filename, 100)
+	if _, err := recovered.GetRecord("a"); err != nil {
+		t.Errorf("Expected complete entries to be replayed: %v", err)
+	}
+	recovered.AddRecord(Entry{ID: "c", Category: CategoryFact})
+	if err := recovered.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	again := openWALStore(t, filename, 100)
+	defer again.Close()
+	if _, err := again.GetRecord("c"); err != nil {
+		t.Errorf("Expected entries appended after the repair to be replayed: %v", err)
+	}
+}
+
+func TestWALFileStoreRejectsCorruptLog(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "knowledge.json")
+	os.WriteFile(filename+".wal", []byte("not json\n{\"op\":\"purge\",\"id\":\"a\"}\n"), 0644)
+
+	store, _ := NewWALFileStore(filename, 100)
+	if err := store.Open(); err == nil {
+		t.Error("Expected error for a corrupt log")
+	}
+}
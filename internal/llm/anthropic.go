@@ -1,24 +1,123 @@
 package llm
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
 )
 
+// DefaultAnthropicBaseURL is the default base URL of the Anthropic API
+const DefaultAnthropicBaseURL = "https://api.anthropic.com/v1"
+
+// AnthropicAPIVersion is the Anthropic API version sent with every request
+const AnthropicAPIVersion = "2023-06-01"
+
 // AnthropicLLM is the Anthropic implementation of the LLM interface
 type AnthropicLLM struct {
-	// To be replaced with actual Anthropic client when dependency is added
-	// client      *anthropic.Client
+	client      *http.Client
 	apiKey      string
+	baseURL     string // Usually "https://api.anthropic.com/v1"
 	model       string // e.g., "claude-3-opus", "claude-3-sonnet"
 	temperature float32
-	maxTokens   int
+	maxTokens   int // Required by the messages API
+	timeoutSec  int // Timeout in seconds for requests
 }
 
 // AnthropicOption is a function that configures an AnthropicLLM
 type AnthropicOption func(*AnthropicLLM)
 
+// AnthropicMessage is a single message in the Anthropic messages API format. Messages
+// with images are sent as a list of image and text content blocks.
+type AnthropicMessage struct {
+	Role    string  `json:"role"` // "user" or "assistant"
+	Content string  `json:"content"`
+	Images  []Image `json:"-"`
+}
+
+// anthropicContentBlock is a content block of an AnthropicMessage
+type anthropicContentBlock struct {
+	Type   string                `json:"type"` // "text" or "image"
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+// anthropicImageSource is the image of an image content block
+type anthropicImageSource struct {
+	Type      string `json:"type"` // "base64" or "url"
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// MarshalJSON encodes the message with a string content, or content blocks if it has
+// images; the images come first, as the API recommends
+func (m AnthropicMessage) MarshalJSON() ([]byte, error) {
+	if len(m.Images) == 0 {
+		return json.Marshal(struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		}{m.Role, m.Content})
+	}
+
+	blocks := make([]anthropicContentBlock, 0, len(m.Images)+1)
+	for _, image := range m.Images {
+		source := &anthropicImageSource{Type: "url", URL: image.URL}
+		if len(image.Data) > 0 {
+			source = &anthropicImageSource{Type: "base64", MediaType: image.mediaType(), Data: base64.StdEncoding.EncodeToString(image.Data)}
+		}
+		blocks = append(blocks, anthropicContentBlock{Type: "image", Source: source})
+	}
+	if m.Content != "" {
+		blocks = append(blocks, anthropicContentBlock{Type: "text", Text: m.Content})
+	}
+	return json.Marshal(struct {
+		Role    string                  `json:"role"`
+		Content []anthropicContentBlock `json:"content"`
+	}{m.Role, blocks})
+}
+
+// AnthropicRequest represents a request to the Anthropic messages API
+type AnthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []AnthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float32            `json:"temperature"`
+}
+
+// AnthropicResponse represents a response from the Anthropic messages API
+type AnthropicResponse struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Role    string `json:"role"`
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// AnthropicErrorResponse represents an error payload returned by the Anthropic API
+type AnthropicErrorResponse struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
 // NewAnthropicLLM creates a new Anthropic LLM with the specified options
 func NewAnthropicLLM(apiKey string, options ...AnthropicOption) (*AnthropicLLM, error) {
 	if apiKey == "" {
@@ -27,10 +126,13 @@ func NewAnthropicLLM(apiKey string, options ...AnthropicOption) (*AnthropicLLM,
 
 	// Default values
 	llm := &AnthropicLLM{
+		client:      &http.Client{},
 		apiKey:      apiKey,
+		baseURL:     DefaultAnthropicBaseURL,
 		model:       "claude-3-opus-20240229",
 		temperature: 0.7,
 		maxTokens:   1024,
+		timeoutSec:  60,
 	}
 
 	// Apply options
@@ -38,8 +140,19 @@ func NewAnthropicLLM(apiKey string, options ...AnthropicOption) (*AnthropicLLM,
 		option(llm)
 	}
 
-	// Initialize client - when Anthropic Go SDK is added
-	// llm.client = anthropic.NewClient(apiKey)
+	// The messages API rejects requests without a positive max_tokens
+	if llm.maxTokens <= 0 {
+		llm.maxTokens = 1024
+	}
+
+	// Update client timeout based on timeoutSec setting
+	llm.client.Timeout = time.Duration(llm.timeoutSec) * time.Second
+
+	// Validate base URL
+	llm.baseURL = strings.TrimRight(llm.baseURL, "/")
+	if !strings.HasPrefix(llm.baseURL, "http") {
+		return nil, fmt.Errorf("invalid Anthropic base URL: %s, must start with http or https", llm.baseURL)
+	}
 
 	return llm, nil
 }
@@ -65,6 +178,32 @@ func WithAnthropicMaxTokens(maxTokens int) AnthropicOption {
 	}
 }
 
+// WithAnthropicBaseURL sets the base URL for the Anthropic API
+func WithAnthropicBaseURL(baseURL string) AnthropicOption {
+	return func(a *AnthropicLLM) {
+		if baseURL != "" {
+			a.baseURL = baseURL
+		}
+	}
+}
+
+// WithAnthropicTimeout sets the timeout for requests
+func WithAnthropicTimeout(timeoutSec int) AnthropicOption {
+	return func(a *AnthropicLLM) {
+		a.timeoutSec = timeoutSec
+	}
+}
+
+// Model returns the name of the model requests are sent to
+func (a *AnthropicLLM) Model() string {
+	return a.model
+}
+
+// SupportsVision reports whether the model accepts images, see VisionModels
+func (a *AnthropicLLM) SupportsVision() bool {
+	return isVisionModel(a.model)
+}
+
 // GenerateResponse generates a text response for a single prompt
 func (a *AnthropicLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
 	// Convert prompt to messages for the chat API
@@ -77,8 +216,7 @@ func (a *AnthropicLLM) GenerateResponse(ctx context.Context, prompt string) (str
 
 // GenerateChat generates a response based on a conversation history
 func (a *AnthropicLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
-	// This is a placeholder implementation
-	// When the Anthropic Go SDK is added as a dependency, this will be replaced with actual API calls
+	endpoint := fmt.Sprintf("%s/messages", a.baseURL)
 
 	// Check for context cancellation
 	select {
@@ -88,13 +226,154 @@ func (a *AnthropicLLM) GenerateChat(ctx context.Context, messages []Message) (st
 		// Continue processing
 	}
 
-	// For now, we'll return a message indicating this is a placeholder
-	return fmt.Sprintf("[Anthropic %s] This is a placeholder response. The actual Anthropic integration requires adding the Anthropic Go SDK as a dependency.", a.model), nil
+	if !a.SupportsVision() {
+		messages = TextOnly(messages)
+	}
+	system, anthropicMessages := toAnthropicMessages(messages)
+	if len(anthropicMessages) == 0 {
+		return "", fmt.Errorf("%w: no user or assistant messages to send", ErrInvalidResponse)
+	}
+
+	// Create request payload
+	request := AnthropicRequest{
+		Model:       a.model,
+		System:      system,
+		Messages:    anthropicMessages,
+		MaxTokens:   a.maxTokens,
+		Temperature: a.temperature,
+	}
+
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(requestJSON))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", AnthropicAPIVersion)
+
+	// Send request
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request to Anthropic: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check for error status code
+	if resp.StatusCode != http.StatusOK {
+		return "", a.statusError(resp.StatusCode, body)
+	}
+
+	// Parse response
+	var response AnthropicResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Join the text blocks of the response
+	var sb strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	if sb.Len() == 0 {
+		return "", ErrInvalidResponse
+	}
+
+	ReportUsage(ctx, Usage{
+		PromptTokens:     response.Usage.InputTokens,
+		CompletionTokens: response.Usage.OutputTokens,
+	})
+	return sb.String(), nil
+}
+
+// toAnthropicMessages converts internal messages to the Anthropic format.
+// System messages are pulled out into the separate system prompt, unknown roles are
+// sent as user messages and consecutive messages with the same role are merged,
+// since the messages API requires alternating user and assistant turns starting with user.
+// The system prompt is text only, so images of system messages are replaced by placeholders.
+func toAnthropicMessages(messages []Message) (string, []AnthropicMessage) {
+	systemParts := make([]string, 0)
+	result := make([]AnthropicMessage, 0, len(messages))
+
+	for _, msg := range messages {
+		role := msg.Role
+		switch role {
+		case "system":
+			content := TextOnly([]Message{msg})[0].Content
+			if strings.TrimSpace(content) != "" {
+				systemParts = append(systemParts, strings.TrimSpace(content))
+			}
+			continue
+		case "assistant":
+			// Supported as-is
+		default:
+			role = "user"
+		}
+
+		if len(result) == 0 && role == "assistant" {
+			// The conversation must open with a user turn
+			result = append(result, AnthropicMessage{Role: "user", Content: "(conversation continued)"})
+		}
+
+		if len(result) > 0 && result[len(result)-1].Role == role {
+			result[len(result)-1].Content += "\n\n" + msg.Content
+			result[len(result)-1].Images = append(result[len(result)-1].Images, msg.Images...)
+			continue
+		}
+		result = append(result, AnthropicMessage{Role: role, Content: msg.Content, Images: msg.Images})
+	}
+
+	return strings.Join(systemParts, "\n\n"), result
+}
+
+// Ping lists the models of the API, checking that it is reachable and accepts the API key
+func (a *AnthropicLLM) Ping(ctx context.Context) error {
+	return ping(ctx, a.client, "Anthropic", fmt.Sprintf("%s/models", a.baseURL), map[string]string{
+		"x-api-key":         a.apiKey,
+		"anthropic-version": AnthropicAPIVersion,
+	})
+}
+
+// statusError converts a non-200 response into an error wrapping the matching LLM error type
+func (a *AnthropicLLM) statusError(status int, body []byte) error {
+	message := string(body)
+	var errResponse AnthropicErrorResponse
+	if err := json.Unmarshal(body, &errResponse); err == nil && errResponse.Error.Message != "" {
+		message = errResponse.Error.Message
+	}
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return &StatusError{Provider: "Anthropic", StatusCode: status, Message: message, Err: ErrAPIKeyMissing}
+	case status == http.StatusTooManyRequests || status == 529: // 529 is Anthropic's "overloaded"
+		return &StatusError{Provider: "Anthropic", StatusCode: status, Message: message, Err: ErrRateLimited}
+	case status == http.StatusBadRequest && strings.Contains(message, "prompt is too long"):
+		return &StatusError{Provider: "Anthropic", StatusCode: status, Message: message, Err: ErrContextTooLarge}
+	default:
+		return &StatusError{Provider: "Anthropic", StatusCode: status, Message: message, Err: ErrProviderError}
+	}
 }
 
-// Initialize the factory function
+// Register the provider
 func init() {
-	newAnthropicFromConfig = createAnthropicFromConfig
+	Register(ProviderAnthropicAI, createAnthropicFromConfig)
+	RegisterConfigLoader(ProviderAnthropicAI, func(s Settings) (ProviderConfig, error) { return anthropicSettings(s) })
 }
 
 // Implementation for creating an AnthropicLLM from config
@@ -121,6 +400,7 @@ func createAnthropicFromConfig(config ProviderConfig) (LanguageModel, error) {
 			WithAnthropicModel(baseConfig.Model),
 			WithAnthropicTemperature(baseConfig.Temperature),
 			WithAnthropicMaxTokens(baseConfig.MaxTokens),
+			WithAnthropicBaseURL(getEnvWithDefault("ANTHROPIC_BASE_URL", DefaultAnthropicBaseURL)),
 		)
 	}
 
@@ -129,6 +409,7 @@ func createAnthropicFromConfig(config ProviderConfig) (LanguageModel, error) {
 		WithAnthropicModel(anthropicConfig.Model),
 		WithAnthropicTemperature(anthropicConfig.Temperature),
 		WithAnthropicMaxTokens(anthropicConfig.MaxTokens),
+		WithAnthropicBaseURL(anthropicConfig.BaseURL),
 	}
 
 	return NewAnthropicLLM(anthropicConfig.APIKey, options...)
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnthropicGenerateChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		if got := r.Header.Get("x-api-key"); got != "test-key" {
+			t.Errorf("Expected x-api-key header, got %q", got)
+		}
+		if got := r.Header.Get("anthropic-version"); got != AnthropicAPIVersion {
+			t.Errorf("Expected anthropic-version header, got %q", got)
+		}
+
+		var request AnthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if request.System != "Be brief." {
+			t.Errorf("Expected system prompt to be separated out, got %q", request.System)
+		}
+		if request.MaxTokens != 1024 {
+			t.Errorf("Expected default max_tokens, got %d", request.MaxTokens)
+		}
+		if len(request.Messages) != 3 || request.Messages[0].Role != "user" || request.Messages[1].Role != "assistant" {
+			t.Errorf("Unexpected messages: %+v", request.Messages)
+		}
+		if request.Messages[2].Content != "First\n\nSecond" {
+			t.Errorf("Expected consecutive user messages to be merged, got %q", request.Messages[2].Content)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Hi "},{"type":"text","text":"there"}],"stop_reason":"end_turn"}`))
+	}))
+	defer server.Close()
+
+	model, err := NewAnthropicLLM("test-key", WithAnthropicBaseURL(server.URL), WithAnthropicMaxTokens(0))
+	if err != nil {
+		t.Fatalf("Failed to create Anthropic LLM: %v", err)
+	}
+
+	response, err := model.GenerateChat(context.Background(), []Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hello"},
+		{Role: "assistant", Content: "Hey"},
+		{Role: "user", Content: "First"},
+		{Role: "user", Content: "Second"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate chat: %v", err)
+	}
+	if response != "Hi there" {
+		t.Errorf("Expected %q, got %q", "Hi there", response)
+	}
+}
+
+func TestAnthropicErrors(t *testing.T) {
+	status := http.StatusTooManyRequests
+	body := `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	model, err := NewAnthropicLLM("test-key", WithAnthropicBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create Anthropic LLM: %v", err)
+	}
+
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusTooManyRequests, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`, ErrRateLimited},
+		{529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, ErrRateLimited},
+		{http.StatusUnauthorized, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`, ErrAPIKeyMissing},
+		{http.StatusBadRequest, `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 300000 tokens"}}`, ErrContextTooLarge},
+		{http.StatusInternalServerError, `{"type":"error","error":{"type":"api_error","message":"boom"}}`, ErrProviderError},
+	}
+
+	for _, tt := range tests {
+		status, body = tt.status, tt.body
+		_, err := model.GenerateResponse(context.Background(), "Hello")
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Status %d: expected %v, got %v", tt.status, tt.want, err)
+		}
+	}
+}
+
+func TestAnthropicFactoryRequiresKey(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	if _, err := LoadAnthropicConfig(); !errors.Is(err, ErrAPIKeyMissing) {
+		t.Errorf("Expected ErrAPIKeyMissing, got %v", err)
+	}
+
+	t.Setenv("ANTHROPIC_API_KEY", "env-key")
+	config, err := LoadAnthropicConfig()
+	if err != nil {
+		t.Fatalf("Failed to load Anthropic config: %v", err)
+	}
+	model, err := NewLLM(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Failed to create Anthropic LLM from config: %v", err)
+	}
+	if _, ok := model.(*AnthropicLLM); !ok {
+		t.Errorf("Expected *AnthropicLLM, got %T", model)
+	}
+}
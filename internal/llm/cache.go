@@ -0,0 +1,301 @@
+package llm
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/logging"
+)
+
+// CacheTag tags the cached responses a CachingLLM persists in a knowledge store
+const CacheTag = "llm-cache"
+
+// CachingLLM is a LanguageModel decorator that answers repeated requests from a cache.
+// Requests are keyed on a hash of the model name, the messages and the request parameters;
+// responses expire after a TTL and the least recently used ones are evicted past the size
+// limit. Failed requests are not cached. With a knowledge store, responses are also
+// written to and looked up in the store, so the cache outlives the process.
+type CachingLLM struct {
+	model      LanguageModel
+	ttl        time.Duration
+	maxEntries int
+	parameters RequestOptions
+	store      knowledge.Store // Optional
+	logger     *logging.Logger
+	now        func() time.Time // Replaceable for tests
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // Elements hold *cacheEntry, most recently used first
+	order   *list.List
+	hits    int
+	misses  int
+}
+
+// cacheEntry is a cached response
+type cacheEntry struct {
+	key       string
+	response  string
+	expiresAt time.Time
+}
+
+// CacheStats counts the lookups of a CachingLLM
+type CacheStats struct {
+	Hits    int
+	Misses  int
+	Entries int // Responses held in memory
+}
+
+// CacheOption is a function that configures a CachingLLM
+type CacheOption func(*CachingLLM)
+
+// NewCachingLLM wraps a language model with a response cache
+func NewCachingLLM(model LanguageModel, options ...CacheOption) *CachingLLM {
+	c := &CachingLLM{
+		model:      model,
+		ttl:        time.Hour,
+		maxEntries: 1000,
+		parameters: DefaultRequestOptions,
+		logger:     logging.Get().Component("llm"),
+		now:        time.Now,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+
+	// Apply options
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// WithCacheTTL sets how long a response is reused
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(c *CachingLLM) {
+		if ttl > 0 {
+			c.ttl = ttl
+		}
+	}
+}
+
+// WithCacheSize sets how many responses are held in memory
+func WithCacheSize(entries int) CacheOption {
+	return func(c *CachingLLM) {
+		if entries > 0 {
+			c.maxEntries = entries
+		}
+	}
+}
+
+// WithCacheParameters sets the request parameters of the wrapped model, which are part of
+// the cache key so that a change of temperature or max tokens is not answered from the cache
+func WithCacheParameters(parameters RequestOptions) CacheOption {
+	return func(c *CachingLLM) {
+		c.parameters = parameters
+	}
+}
+
+// WithCacheStore persists responses in a knowledge store as records tagged CacheTag that
+// expire with the TTL
+func WithCacheStore(store knowledge.Store) CacheOption {
+	return func(c *CachingLLM) {
+		c.store = store
+	}
+}
+
+// GenerateResponse generates a text response for a single prompt, or returns the cached one
+func (c *CachingLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	key := c.key(ctx, "prompt", []Message{{Role: "user", Content: prompt}})
+	return c.do(key, func() (string, error) {
+		return c.model.GenerateResponse(ctx, prompt)
+	})
+}
+
+// GenerateChat generates a response based on a conversation history, or returns the cached one
+func (c *CachingLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
+	key := c.key(ctx, "chat", messages)
+	return c.do(key, func() (string, error) {
+		return c.model.GenerateChat(ctx, messages)
+	})
+}
+
+// StreamResponse streams a response for a single prompt, or returns the cached one as a
+// single token
+func (c *CachingLLM) StreamResponse(ctx context.Context, prompt string) (chan StreamToken, error) {
+	key := c.key(ctx, "prompt", []Message{{Role: "user", Content: prompt}})
+	return c.stream(ctx, key, func() (chan StreamToken, error) {
+		return streamResponse(ctx, c.model, prompt)
+	})
+}
+
+// StreamChat streams a response based on a conversation history, or returns the cached
+// one as a single token
+func (c *CachingLLM) StreamChat(ctx context.Context, messages []Message) (chan StreamToken, error) {
+	key := c.key(ctx, "chat", messages)
+	return c.stream(ctx, key, func() (chan StreamToken, error) {
+		return streamChat(ctx, c.model, messages)
+	})
+}
+
+// Unwrap returns the wrapped language model
+func (c *CachingLLM) Unwrap() LanguageModel {
+	return c.model
+}
+
+// Stats returns the hits and misses so far
+func (c *CachingLLM) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.order.Len()}
+}
+
+// Clear drops the responses held in memory; persisted responses stay in the store
+func (c *CachingLLM) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// do returns the cached response for the key, or calls the model and caches its response
+func (c *CachingLLM) do(key string, call func() (string, error)) (string, error) {
+	if response, ok := c.lookup(key); ok {
+		return response, nil
+	}
+
+	response, err := call()
+	if err != nil {
+		return "", err
+	}
+	c.save(key, response)
+	return response, nil
+}
+
+// stream returns the cached response for the key as a single token, or opens a stream and
+// caches its response once it is complete
+func (c *CachingLLM) stream(ctx context.Context, key string, open func() (chan StreamToken, error)) (chan StreamToken, error) {
+	if response, ok := c.lookup(key); ok {
+		return singleToken(response), nil
+	}
+
+	tokens, err := open()
+	if err != nil {
+		return nil, err
+	}
+	return relay(ctx, nil, tokens, func(response string, err error) {
+		if err == nil {
+			c.save(key, response)
+		}
+	}), nil
+}
+
+// save caches a response in memory and in the store
+func (c *CachingLLM) save(key, response string) {
+	expiresAt := c.now().Add(c.ttl)
+	c.put(key, response, expiresAt)
+	c.persist(key, response, expiresAt)
+}
+
+// key hashes what the response depends on, including the response schema of the context
+func (c *CachingLLM) key(ctx context.Context, kind string, messages []Message) string {
+	schema, _ := ResponseSchemaFrom(ctx)
+	data, _ := json.Marshal(struct {
+		Model      string         `json:"model"`
+		Kind       string         `json:"kind"`
+		Messages   []Message      `json:"messages"`
+		Parameters RequestOptions `json:"parameters"`
+		Schema     ResponseSchema `json:"schema"`
+	}{ModelName(c.model), kind, messages, c.parameters, schema})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// lookup returns an unexpired response from memory, else from the store
+func (c *CachingLLM) lookup(key string) (string, bool) {
+	now := c.now()
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if now.Before(entry.expiresAt) {
+			c.order.MoveToFront(element)
+			c.hits++
+			c.mu.Unlock()
+			return entry.response, true
+		}
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	if c.store != nil {
+		record, err := c.store.GetRecord(cacheRecordID(key))
+		if err == nil && now.Before(record.ExpiresAt) {
+			c.put(key, string(record.Content), record.ExpiresAt)
+			c.mu.Lock()
+			c.hits++
+			c.mu.Unlock()
+			return string(record.Content), true
+		}
+	}
+
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+	return "", false
+}
+
+// put holds a response in memory, evicting the least recently used past the size limit
+func (c *CachingLLM) put(key, response string, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value = &cacheEntry{key: key, response: response, expiresAt: expiresAt}
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, response: response, expiresAt: expiresAt})
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// persist writes a response to the store, replacing an expired one
+func (c *CachingLLM) persist(key, response string, expiresAt time.Time) {
+	if c.store == nil {
+		return
+	}
+	now := c.now()
+	entry := knowledge.Entry{
+		ID:          cacheRecordID(key),
+		Category:    knowledge.CategoryAction,
+		ContentType: knowledge.ContentTypeText,
+		Content:     []byte(response),
+		Importance:  knowledge.ImportanceLow,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ExpiresAt:   expiresAt,
+		SourceID:    ModelName(c.model),
+		SourceType:  "llm",
+		OwnerType:   "tool",
+		Tags:        []string{CacheTag},
+		References:  []knowledge.Reference{},
+		Metadata:    map[string]string{"key": key},
+		Provenance:  []knowledge.ProvenanceStep{knowledge.FromGeneration(ModelName(c.model), "")},
+	}
+	if err := c.store.LoadRecords(entry); err != nil {
+		c.logger.Warn("Failed to persist cached LLM response", "key", key, "error", err)
+	}
+}
+
+// cacheRecordID is the knowledge record ID of a cached response
+func cacheRecordID(key string) string {
+	return CacheTag + "-" + key
+}
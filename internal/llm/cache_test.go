@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"goproduct/internal/knowledge"
+)
+
+// countingLLM answers with the number of calls so far
+type countingLLM struct {
+	calls int
+	err   error
+}
+
+func (c *countingLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return c.GenerateChat(ctx, []Message{{Role: "user", Content: prompt}})
+}
+
+func (c *countingLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
+	c.calls++
+	if c.err != nil {
+		return "", c.err
+	}
+	return messages[len(messages)-1].Content + string(rune('0'+c.calls)), nil
+}
+
+func (c *countingLLM) Model() string {
+	return "counting"
+}
+
+func TestCachingLLM_ReusesResponses(t *testing.T) {
+	inner := &countingLLM{}
+	clock := time.Now()
+	model := NewCachingLLM(inner, WithCacheTTL(time.Minute))
+	model.now = func() time.Time { return clock }
+
+	first, _ := model.GenerateResponse(context.Background(), "Hi")
+	second, _ := model.GenerateResponse(context.Background(), "Hi")
+	if first != "Hi1" || second != "Hi1" || inner.calls != 1 {
+		t.Errorf("Expected the second request from the cache, got %q, %q after %d calls", first, second, inner.calls)
+	}
+
+	// A chat with the same content is a different request
+	if chat, _ := model.GenerateChat(context.Background(), []Message{{Role: "user", Content: "Hi"}}); chat != "Hi2" {
+		t.Errorf("Expected a chat to miss the prompt's entry, got %q", chat)
+	}
+
+	clock = clock.Add(2 * time.Minute)
+	if expired, _ := model.GenerateResponse(context.Background(), "Hi"); expired != "Hi3" {
+		t.Errorf("Expected an expired response to be regenerated, got %q", expired)
+	}
+
+	stats := model.Stats()
+	if stats.Hits != 1 || stats.Misses != 3 || stats.Entries != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestCachingLLM_KeysOnParameters(t *testing.T) {
+	inner := &countingLLM{}
+	cold := NewCachingLLM(inner, WithCacheParameters(RequestOptions{Temperature: 0}))
+	hot := NewCachingLLM(inner, WithCacheParameters(RequestOptions{Temperature: 1}))
+	if cold.key(context.Background(), "chat", nil) == hot.key(context.Background(), "chat", nil) {
+		t.Error("Expected different parameters to give different keys")
+	}
+}
+
+func TestCachingLLM_EvictsLeastRecentlyUsed(t *testing.T) {
+	inner := &countingLLM{}
+	model := NewCachingLLM(inner, WithCacheSize(2))
+
+	model.GenerateResponse(context.Background(), "a")
+	model.GenerateResponse(context.Background(), "b")
+	model.GenerateResponse(context.Background(), "a") // a is now the most recently used
+	model.GenerateResponse(context.Background(), "c") // Evicts b
+	if inner.calls != 3 {
+		t.Fatalf("Expected 3 calls, got %d", inner.calls)
+	}
+	model.GenerateResponse(context.Background(), "a")
+	model.GenerateResponse(context.Background(), "b")
+	if inner.calls != 4 {
+		t.Errorf("Expected only b to be regenerated, got %d calls", inner.calls)
+	}
+}
+
+func TestCachingLLM_DoesNotCacheErrors(t *testing.T) {
+	inner := &countingLLM{err: errors.New("down")}
+	model := NewCachingLLM(inner)
+
+	model.GenerateResponse(context.Background(), "Hi")
+	if _, err := model.GenerateResponse(context.Background(), "Hi"); err == nil || inner.calls != 2 {
+		t.Errorf("Expected failures to reach the model every time, got %v after %d calls", err, inner.calls)
+	}
+}
+
+func TestCachingLLM_PersistsInStore(t *testing.T) {
+	store, err := knowledge.NewMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &countingLLM{}
+	NewCachingLLM(inner, WithCacheStore(store)).GenerateResponse(context.Background(), "Hi")
+
+	records, err := store.SearchRecords(knowledge.Filter{
+		RootGroup: knowledge.FilterGroup{
+			Operator:   knowledge.OpAnd,
+			Conditions: []knowledge.Condition{{Field: "Tags", Operator: "CONTAINS", Value: CacheTag}},
+		},
+	})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("Expected one persisted response, got %d (%v)", len(records), err)
+	}
+
+	// A new cache, as in the next run, finds the response in the store
+	restarted := NewCachingLLM(inner, WithCacheStore(store))
+	if response, _ := restarted.GenerateResponse(context.Background(), "Hi"); response != "Hi1" || inner.calls != 1 {
+		t.Errorf("Expected the persisted response, got %q after %d calls", response, inner.calls)
+	}
+}
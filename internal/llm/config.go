@@ -10,20 +10,25 @@ import (
 // OpenAIConfig contains OpenAI-specific configuration
 type OpenAIConfig struct {
 	BaseConfig
-	APIKey       string
-	Organization string
+	APIKey         string
+	Organization   string
+	BaseURL        string // Defaults to https://api.openai.com/v1
+	EmbeddingModel string // Model of GenerateEmbeddings, e.g. "text-embedding-3-small"
 }
 
 // AnthropicConfig contains Anthropic-specific configuration
 type AnthropicConfig struct {
 	BaseConfig
-	APIKey string
+	APIKey  string
+	BaseURL string // Defaults to https://api.anthropic.com/v1
 }
 
 // OllamaConfig contains Ollama-specific configuration
 type OllamaConfig struct {
 	BaseConfig
-	Endpoint string // Usually http://localhost:11434
+	Endpoint   string // Usually http://localhost:11434
+	KeepAlive  string // How long the model stays loaded after a request, e.g. "5m", "-1" (forever), "0" (unload)
+	TimeoutSec int    // Timeout in seconds for requests
 }
 
 // TogetherConfig contains Together AI-specific configuration
@@ -35,7 +40,9 @@ type TogetherConfig struct {
 // LMStudioConfig contains LM Studio-specific configuration
 type LMStudioConfig struct {
 	BaseConfig
-	Endpoint string // Usually http://localhost:1234/v1
+	Endpoint       string // Usually http://localhost:1234/v1
+	TimeoutSec     int    // Timeout in seconds for requests
+	EmbeddingModel string // Model of GenerateEmbeddings, must be loaded in LM Studio
 }
 
 // MockConfig contains configuration for the mock LLM
@@ -45,108 +52,181 @@ type MockConfig struct {
 	DelayMs        int
 }
 
+// Settings are the settings of a provider, e.g. a provider section of the application
+// configuration. Settings take precedence over the provider's environment variables.
+type Settings map[string]string
+
+// String returns a setting, else the environment variable, else the fallback
+func (s Settings) String(key, env, fallback string) string {
+	if value := s[key]; value != "" {
+		return value
+	}
+	if env != "" {
+		return getEnvWithDefault(env, fallback)
+	}
+	return fallback
+}
+
+// Float returns a number setting, else the environment variable, else the fallback
+func (s Settings) Float(key, env string, fallback float32) (float32, error) {
+	raw := s.String(key, env, "")
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.ParseFloat(raw, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, raw, err)
+	}
+	return float32(value), nil
+}
+
+// Int returns an integer setting, else the environment variable, else the fallback
+func (s Settings) Int(key, env string, fallback int) (int, error) {
+	raw := s.String(key, env, "")
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, raw, err)
+	}
+	return value, nil
+}
+
+// baseSettings reads the model, temperature and max_tokens settings of a provider whose
+// environment variables start with the prefix, e.g. "OPENAI"
+func baseSettings(s Settings, provider, prefix, model string) (BaseConfig, error) {
+	temperature, err := s.Float("temperature", prefix+"_TEMPERATURE", 0.7)
+	if err != nil {
+		return BaseConfig{}, err
+	}
+	maxTokens, err := s.Int("max_tokens", prefix+"_MAX_TOKENS", 1024)
+	if err != nil {
+		return BaseConfig{}, err
+	}
+	return BaseConfig{
+		Provider:    provider,
+		Model:       s.String("model", prefix+"_MODEL", model),
+		Temperature: temperature,
+		MaxTokens:   maxTokens,
+	}, nil
+}
+
 // LoadOpenAIConfig loads OpenAI configuration from environment variables
 func LoadOpenAIConfig() (*OpenAIConfig, error) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
+	return openAISettings(nil)
+}
+
+// openAISettings loads OpenAI configuration from settings: api_key, organization,
+// endpoint, model, embedding_model, temperature and max_tokens
+func openAISettings(s Settings) (*OpenAIConfig, error) {
+	apiKey := s.String("api_key", "OPENAI_API_KEY", "")
 	if apiKey == "" {
 		return nil, ErrAPIKeyMissing
 	}
-
-	// Get optional variables with defaults
-	model := getEnvWithDefault("OPENAI_MODEL", "gpt-4o")
-	org := os.Getenv("OPENAI_ORGANIZATION") // Optional
-	temp, _ := strconv.ParseFloat(getEnvWithDefault("OPENAI_TEMPERATURE", "0.7"), 32)
-	maxTokens, _ := strconv.Atoi(getEnvWithDefault("OPENAI_MAX_TOKENS", "1024"))
-
+	base, err := baseSettings(s, ProviderOpenAI, "OPENAI", "gpt-4o")
+	if err != nil {
+		return nil, err
+	}
 	return &OpenAIConfig{
-		BaseConfig: BaseConfig{
-			Provider:    ProviderOpenAI,
-			Model:       model,
-			Temperature: float32(temp),
-			MaxTokens:   maxTokens,
-		},
-		APIKey:       apiKey,
-		Organization: org,
+		BaseConfig:     base,
+		APIKey:         apiKey,
+		Organization:   s.String("organization", "OPENAI_ORGANIZATION", ""), // Optional
+		BaseURL:        s.String("endpoint", "OPENAI_BASE_URL", DefaultOpenAIBaseURL),
+		EmbeddingModel: s.String("embedding_model", "OPENAI_EMBEDDING_MODEL", ""),
 	}, nil
 }
 
 // LoadAnthropicConfig loads Anthropic configuration from environment variables
 func LoadAnthropicConfig() (*AnthropicConfig, error) {
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	return anthropicSettings(nil)
+}
+
+// anthropicSettings loads Anthropic configuration from settings: api_key, endpoint, model,
+// temperature and max_tokens
+func anthropicSettings(s Settings) (*AnthropicConfig, error) {
+	apiKey := s.String("api_key", "ANTHROPIC_API_KEY", "")
 	if apiKey == "" {
 		return nil, ErrAPIKeyMissing
 	}
-
-	model := getEnvWithDefault("ANTHROPIC_MODEL", "claude-3-opus-20240229")
-	temp, _ := strconv.ParseFloat(getEnvWithDefault("ANTHROPIC_TEMPERATURE", "0.7"), 32)
-	maxTokens, _ := strconv.Atoi(getEnvWithDefault("ANTHROPIC_MAX_TOKENS", "1024"))
-
+	base, err := baseSettings(s, ProviderAnthropicAI, "ANTHROPIC", "claude-3-opus-20240229")
+	if err != nil {
+		return nil, err
+	}
 	return &AnthropicConfig{
-		BaseConfig: BaseConfig{
-			Provider:    ProviderAnthropicAI,
-			Model:       model,
-			Temperature: float32(temp),
-			MaxTokens:   maxTokens,
-		},
-		APIKey: apiKey,
+		BaseConfig: base,
+		APIKey:     apiKey,
+		BaseURL:    s.String("endpoint", "ANTHROPIC_BASE_URL", DefaultAnthropicBaseURL),
 	}, nil
 }
 
 // LoadOllamaConfig loads Ollama configuration from environment variables
 func LoadOllamaConfig() (*OllamaConfig, error) {
-	endpoint := getEnvWithDefault("OLLAMA_ENDPOINT", "http://localhost:11434")
-	model := getEnvWithDefault("OLLAMA_MODEL", "llama3")
-	temp, _ := strconv.ParseFloat(getEnvWithDefault("OLLAMA_TEMPERATURE", "0.7"), 32)
-	maxTokens, _ := strconv.Atoi(getEnvWithDefault("OLLAMA_MAX_TOKENS", "1024"))
+	return ollamaSettings(nil)
+}
 
+// ollamaSettings loads Ollama configuration from settings: endpoint, model, temperature,
+// max_tokens, keep_alive and timeout (seconds)
+func ollamaSettings(s Settings) (*OllamaConfig, error) {
+	base, err := baseSettings(s, ProviderOllama, "OLLAMA", "llama3")
+	if err != nil {
+		return nil, err
+	}
+	timeoutSec, err := s.Int("timeout", "OLLAMA_TIMEOUT", 120)
+	if err != nil {
+		return nil, err
+	}
 	return &OllamaConfig{
-		BaseConfig: BaseConfig{
-			Provider:    ProviderOllama,
-			Model:       model,
-			Temperature: float32(temp),
-			MaxTokens:   maxTokens,
-		},
-		Endpoint: endpoint,
+		BaseConfig: base,
+		Endpoint:   s.String("endpoint", "OLLAMA_ENDPOINT", "http://localhost:11434"),
+		KeepAlive:  s.String("keep_alive", "OLLAMA_KEEP_ALIVE", ""), // Optional, server default when empty
+		TimeoutSec: timeoutSec,
 	}, nil
 }
 
 // LoadTogetherConfig loads Together.ai configuration from environment variables
 func LoadTogetherConfig() (*TogetherConfig, error) {
-	apiKey := os.Getenv("TOGETHER_API_KEY")
+	return togetherSettings(nil)
+}
+
+// togetherSettings loads Together.ai configuration from settings: api_key, model,
+// temperature and max_tokens
+func togetherSettings(s Settings) (*TogetherConfig, error) {
+	apiKey := s.String("api_key", "TOGETHER_API_KEY", "")
 	if apiKey == "" {
 		return nil, ErrAPIKeyMissing
 	}
-
-	model := getEnvWithDefault("TOGETHER_MODEL", "togethercomputer/llama-3-8b")
-	temp, _ := strconv.ParseFloat(getEnvWithDefault("TOGETHER_TEMPERATURE", "0.7"), 32)
-	maxTokens, _ := strconv.Atoi(getEnvWithDefault("TOGETHER_MAX_TOKENS", "1024"))
-
+	base, err := baseSettings(s, ProviderTogetherAI, "TOGETHER", "togethercomputer/llama-3-8b")
+	if err != nil {
+		return nil, err
+	}
 	return &TogetherConfig{
-		BaseConfig: BaseConfig{
-			Provider:    ProviderTogetherAI,
-			Model:       model,
-			Temperature: float32(temp),
-			MaxTokens:   maxTokens,
-		},
-		APIKey: apiKey,
+		BaseConfig: base,
+		APIKey:     apiKey,
 	}, nil
 }
 
 // LoadLMStudioConfig loads LM Studio configuration from environment variables
 func LoadLMStudioConfig() (*LMStudioConfig, error) {
-	endpoint := getEnvWithDefault("LMSTUDIO_ENDPOINT", "http://localhost:1234/v1")
-	model := getEnvWithDefault("LMSTUDIO_MODEL", "local-model")
-	temp, _ := strconv.ParseFloat(getEnvWithDefault("LMSTUDIO_TEMPERATURE", "0.7"), 32)
-	maxTokens, _ := strconv.Atoi(getEnvWithDefault("LMSTUDIO_MAX_TOKENS", "1024"))
+	return lmStudioSettings(nil)
+}
 
+// lmStudioSettings loads LM Studio configuration from settings: endpoint, model,
+// embedding_model, temperature, max_tokens and timeout (seconds)
+func lmStudioSettings(s Settings) (*LMStudioConfig, error) {
+	base, err := baseSettings(s, ProviderLMStudio, "LMSTUDIO", "local-model")
+	if err != nil {
+		return nil, err
+	}
+	timeoutSec, err := s.Int("timeout", "LMSTUDIO_TIMEOUT", 60)
+	if err != nil {
+		return nil, err
+	}
 	return &LMStudioConfig{
-		BaseConfig: BaseConfig{
-			Provider:    ProviderLMStudio,
-			Model:       model,
-			Temperature: float32(temp),
-			MaxTokens:   maxTokens,
-		},
-		Endpoint: endpoint,
+		BaseConfig:     base,
+		Endpoint:       s.String("endpoint", "LMSTUDIO_ENDPOINT", "http://localhost:1234/v1"),
+		TimeoutSec:     timeoutSec,
+		EmbeddingModel: s.String("embedding_model", "LMSTUDIO_EMBEDDING_MODEL", ""),
 	}, nil
 }
 
@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxEmbeddingBatch is how many texts are sent in one embeddings request
+const maxEmbeddingBatch = 256
+
+// Embedder is an optional interface for providers that turn texts into embedding vectors,
+// e.g. for similarity search over knowledge records
+type Embedder interface {
+	// GenerateEmbeddings returns the embedding of each text, in the order of the texts
+	GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbedderFrom returns the embedder of a language model, or of the model it decorates
+func EmbedderFrom(model LanguageModel) (Embedder, bool) {
+	for model != nil {
+		if embedder, ok := model.(Embedder); ok {
+			return embedder, true
+		}
+		wrapper, ok := model.(interface{ Unwrap() LanguageModel })
+		if !ok {
+			return nil, false
+		}
+		model = wrapper.Unwrap()
+	}
+	return nil, false
+}
+
+// EmbeddingRequest represents a request to an OpenAI-compatible /embeddings endpoint
+type EmbeddingRequest struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	EncodingFormat string   `json:"encoding_format,omitempty"` // "float"
+}
+
+// EmbeddingResponse represents a response from an OpenAI-compatible /embeddings endpoint
+type EmbeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage struct {
+		PromptTokens int `json:"prompt_tokens"`
+		TotalTokens  int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// embeddingsClient sends requests to an OpenAI-compatible /embeddings endpoint
+type embeddingsClient struct {
+	client      *http.Client
+	endpoint    string // Full URL of the /embeddings endpoint
+	model       string
+	provider    string                              // For error messages, e.g. "OpenAI"
+	setHeaders  func(req *http.Request)             // Adds authentication, may be nil
+	statusError func(status int, body []byte) error // Converts a non-200 response
+}
+
+// generate embeds the texts in batches of maxEmbeddingBatch
+func (e embeddingsClient) generate(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += maxEmbeddingBatch {
+		end := min(start+maxEmbeddingBatch, len(texts))
+		batch, err := e.post(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// post embeds one batch of texts
+func (e embeddingsClient) post(ctx context.Context, texts []string) ([][]float32, error) {
+	requestJSON, err := json.Marshal(EmbeddingRequest{Model: e.model, Input: texts, EncodingFormat: "float"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.endpoint, bytes.NewBuffer(requestJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if e.setHeaders != nil {
+		e.setHeaders(req)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to %s: %w", e.provider, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, e.statusError(resp.StatusCode, body)
+	}
+
+	var response EmbeddingResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(response.Data) != len(texts) {
+		return nil, fmt.Errorf("%w: %d embeddings for %d texts", ErrInvalidResponse, len(response.Data), len(texts))
+	}
+
+	// The data is not guaranteed to be in input order
+	vectors := make([][]float32, len(texts))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(texts) || vectors[item.Index] != nil {
+			return nil, fmt.Errorf("%w: unexpected embedding index %d", ErrInvalidResponse, item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+
+	ReportUsage(ctx, Usage{PromptTokens: response.Usage.PromptTokens, TotalTokens: response.Usage.TotalTokens})
+	return vectors, nil
+}
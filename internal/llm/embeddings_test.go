@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// embeddingServer answers embeddings requests with vectors [index of text, length of text],
+// listing them in reverse order
+func embeddingServer(t *testing.T, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		var request EmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if request.Model != "embed-test" {
+			t.Errorf("Expected the embedding model, got %q", request.Model)
+		}
+		*requests++
+
+		var data []string
+		for i := len(request.Input) - 1; i >= 0; i-- {
+			data = append(data, fmt.Sprintf(`{"index":%d,"embedding":[%d,%d]}`, i, i, len(request.Input[i])))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":[%s],"usage":{"prompt_tokens":3,"total_tokens":3}}`, strings.Join(data, ","))
+	}))
+}
+
+func TestOpenAIGenerateEmbeddings(t *testing.T) {
+	requests := 0
+	server := embeddingServer(t, &requests)
+	defer server.Close()
+
+	model, err := NewOpenAILLM("key", WithOpenAIBaseURL(server.URL+"/v1"), WithOpenAIEmbeddingModel("embed-test"))
+	if err != nil {
+		t.Fatalf("Failed to create OpenAI LLM: %v", err)
+	}
+	texts := make([]string, maxEmbeddingBatch+2)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("text %d", i)
+	}
+
+	vectors, err := model.GenerateEmbeddings(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Failed to generate embeddings: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected the texts in 2 batches, got %d requests", requests)
+	}
+	if len(vectors) != len(texts) || vectors[1][0] != 1 || vectors[maxEmbeddingBatch+1][0] != 1 {
+		t.Errorf("Expected the vectors in the order of the texts, got %d vectors", len(vectors))
+	}
+}
+
+func TestLMStudioGenerateEmbeddings(t *testing.T) {
+	requests := 0
+	server := embeddingServer(t, &requests)
+	defer server.Close()
+
+	model, err := NewLMStudioLLM(server.URL+"/v1", WithLMStudioEmbeddingModel("embed-test"))
+	if err != nil {
+		t.Fatalf("Failed to create LM Studio LLM: %v", err)
+	}
+	embedder, ok := EmbedderFrom(NewRetryingLLM(model))
+	if !ok {
+		t.Fatal("Expected the embedder of the decorated model")
+	}
+
+	vectors, err := embedder.GenerateEmbeddings(context.Background(), []string{"a", "bcd"})
+	if err != nil {
+		t.Fatalf("Failed to generate embeddings: %v", err)
+	}
+	if len(vectors) != 2 || vectors[0][1] != 1 || vectors[1][1] != 3 {
+		t.Errorf("Unexpected vectors: %v", vectors)
+	}
+}
+
+func TestGenerateEmbeddingsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"index":0,"embedding":[1]}]}`))
+	}))
+	defer server.Close()
+
+	model, _ := NewLMStudioLLM(server.URL)
+	if _, err := model.GenerateEmbeddings(context.Background(), []string{"a", "b"}); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("Expected ErrInvalidResponse for a missing embedding, got %v", err)
+	}
+
+	if _, ok := EmbedderFrom(NewEchoLLM(0)); ok {
+		t.Error("Expected the echo model to have no embedder")
+	}
+}
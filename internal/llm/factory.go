@@ -3,7 +3,9 @@ package llm
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // Provider constants
@@ -18,60 +20,113 @@ const (
 	ProviderException   = "exception"
 )
 
-// NewLLM creates a new LLM instance based on the provided configuration
-func NewLLM(ctx context.Context, config ProviderConfig) (LanguageModel, error) {
-	switch strings.ToLower(config.GetProvider()) {
-	case ProviderOpenAI:
-		return newOpenAIFromConfig(config)
-	case ProviderAnthropicAI:
-		return newAnthropicFromConfig(config)
-	case ProviderOllama:
-		return newOllamaFromConfig(config)
-	case ProviderTogetherAI:
-		return newTogetherFromConfig(config)
-	case ProviderLMStudio:
-		return newLMStudioFromConfig(config)
-	case ProviderMock:
-		return newMockFromConfig(config)
-	case ProviderEcho:
-		return newEchoFromConfig(config)
-	case ProviderException:
-		return newExceptionFromConfig(config)
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", config.GetProvider())
-	}
-}
+// Factory creates a language model from the configuration of its provider
+type Factory func(config ProviderConfig) (LanguageModel, error)
 
-// Factory functions are declared here for documentation, but implemented in their respective files
+// ConfigLoader builds the configuration of a provider from its settings, e.g. a section
+// of the application configuration, falling back to the provider's environment variables
+type ConfigLoader func(settings Settings) (ProviderConfig, error)
 
-// newOpenAIFromConfig creates an OpenAI LLM from configuration
-// Implemented in openai.go
-var newOpenAIFromConfig func(config ProviderConfig) (LanguageModel, error)
+// provider is a registered provider
+type provider struct {
+	factory Factory
+	loader  ConfigLoader // Optional
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]provider)
+)
 
-// newAnthropicFromConfig creates an Anthropic LLM from configuration
-// Implemented in anthropic.go
-var newAnthropicFromConfig func(config ProviderConfig) (LanguageModel, error)
+// Register makes a provider available by name to NewLLM. Providers register themselves
+// from an init function; registering a name twice or a nil factory panics.
+func Register(name string, factory Factory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	name = strings.ToLower(name)
+	if factory == nil {
+		panic("llm: Register factory is nil for provider " + name)
+	}
+	if _, exists := providers[name]; exists {
+		panic("llm: Register called twice for provider " + name)
+	}
+	providers[name] = provider{factory: factory}
+}
 
-// newOllamaFromConfig creates an Ollama LLM from configuration
-// Implemented in ollama.go
-var newOllamaFromConfig func(config ProviderConfig) (LanguageModel, error)
+// RegisterConfigLoader sets how LoadProviderConfig builds the configuration of a
+// registered provider
+func RegisterConfigLoader(name string, loader ConfigLoader) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	name = strings.ToLower(name)
+	p, exists := providers[name]
+	if !exists {
+		panic("llm: RegisterConfigLoader called for unregistered provider " + name)
+	}
+	p.loader = loader
+	providers[name] = p
+}
 
-// newTogetherFromConfig creates a Together AI LLM from configuration
-// Implemented in together.go
-var newTogetherFromConfig func(config ProviderConfig) (LanguageModel, error)
+// Providers returns the names of the registered providers, sorted
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return sortedKeys(providers)
+}
 
-// newLMStudioFromConfig creates an LM Studio LLM from configuration
-// Implemented in lmstudio.go
-var newLMStudioFromConfig func(config ProviderConfig) (LanguageModel, error)
+// lookupProvider returns the registered provider with the name
+func lookupProvider(name string) (provider, error) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[strings.ToLower(name)]
+	if !ok {
+		return provider{}, fmt.Errorf("unsupported provider: %s (registered: %s)", name, strings.Join(sortedKeys(providers), ", "))
+	}
+	return p, nil
+}
 
-// newMockFromConfig creates a Mock LLM from configuration
-// Implemented in mock.go
-var newMockFromConfig func(config ProviderConfig) (LanguageModel, error)
+// sortedKeys returns the provider names of the map; the caller holds the lock
+func sortedKeys(m map[string]provider) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
-// newEchoFromConfig creates an Echo LLM from configuration
-// Implemented in mock_llms.go
-var newEchoFromConfig func(config ProviderConfig) (LanguageModel, error)
+// NewLLM creates a new LLM instance with the registered provider the configuration names
+func NewLLM(ctx context.Context, config ProviderConfig) (LanguageModel, error) {
+	p, err := lookupProvider(config.GetProvider())
+	if err != nil {
+		return nil, err
+	}
+	return p.factory(config)
+}
 
-// newExceptionFromConfig creates an Exception LLM from configuration
-// Implemented in mock_llms.go
-var newExceptionFromConfig func(config ProviderConfig) (LanguageModel, error)
+// LoadProviderConfig builds the configuration of a registered provider from its
+// settings. Providers without a config loader get a BaseConfig of the model,
+// temperature and max_tokens settings.
+func LoadProviderConfig(name string, settings Settings) (ProviderConfig, error) {
+	p, err := lookupProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	if p.loader != nil {
+		return p.loader(settings)
+	}
+	temperature, err := settings.Float("temperature", "", DefaultRequestOptions.Temperature)
+	if err != nil {
+		return nil, err
+	}
+	maxTokens, err := settings.Int("max_tokens", "", DefaultRequestOptions.MaxTokens)
+	if err != nil {
+		return nil, err
+	}
+	return BaseConfig{
+		Provider:    strings.ToLower(name),
+		Model:       settings.String("model", "", ""),
+		Temperature: temperature,
+		MaxTokens:   maxTokens,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegisterProvider(t *testing.T) {
+	Register("test-custom", func(config ProviderConfig) (LanguageModel, error) {
+		return NewMockLLM(WithResponsePrefix(config.(BaseConfig).Model + ": ")), nil
+	})
+
+	if !slices.Contains(Providers(), "test-custom") {
+		t.Fatalf("Expected test-custom in providers, got %v", Providers())
+	}
+
+	config, err := LoadProviderConfig("Test-Custom", Settings{"model": "tiny"})
+	if err != nil {
+		t.Fatalf("Failed to load provider config: %v", err)
+	}
+	model, err := NewLLM(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Failed to create registered provider: %v", err)
+	}
+	resp, err := model.GenerateResponse(context.Background(), "hi")
+	if err != nil {
+		t.Fatalf("Failed to generate response: %v", err)
+	}
+	if resp != "tiny: hi" {
+		t.Errorf("Expected response %q, got %q", "tiny: hi", resp)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a provider twice to panic")
+		}
+	}()
+	Register("test-custom", createMockFromConfig)
+}
+
+func TestNewLLMUnknownProvider(t *testing.T) {
+	_, err := NewLLM(context.Background(), BaseConfig{Provider: "nope"})
+	if err == nil || !strings.Contains(err.Error(), ProviderOllama) {
+		t.Errorf("Expected an error listing the registered providers, got %v", err)
+	}
+}
+
+func TestLoadProviderConfigFromSettings(t *testing.T) {
+	t.Setenv("OLLAMA_MODEL", "from-env")
+	t.Setenv("OLLAMA_KEEP_ALIVE", "5m")
+
+	config, err := LoadProviderConfig(ProviderOllama, Settings{
+		"model":    "llama3.1",
+		"endpoint": "http://gpu:11434",
+		"timeout":  "30",
+	})
+	if err != nil {
+		t.Fatalf("Failed to load provider config: %v", err)
+	}
+	ollama, ok := config.(*OllamaConfig)
+	if !ok {
+		t.Fatalf("Expected *OllamaConfig, got %T", config)
+	}
+	if ollama.Model != "llama3.1" || ollama.Endpoint != "http://gpu:11434" || ollama.TimeoutSec != 30 {
+		t.Errorf("Expected settings to win, got %+v", ollama)
+	}
+	if ollama.KeepAlive != "5m" {
+		t.Errorf("Expected keep alive from the environment, got %q", ollama.KeepAlive)
+	}
+
+	if _, err := LoadProviderConfig(ProviderOllama, Settings{"max_tokens": "many"}); err == nil {
+		t.Error("Expected an error for an invalid max_tokens")
+	}
+
+	t.Setenv("OPENAI_API_KEY", "")
+	if _, err := LoadProviderConfig(ProviderOpenAI, Settings{}); !errors.Is(err, ErrAPIKeyMissing) {
+		t.Errorf("Expected ErrAPIKeyMissing, got %v", err)
+	}
+	config, err = LoadProviderConfig(ProviderOpenAI, Settings{"api_key": "sk-test"})
+	if err != nil {
+		t.Fatalf("Failed to load OpenAI config: %v", err)
+	}
+	if openAI := config.(*OpenAIConfig); openAI.APIKey != "sk-test" || openAI.BaseURL != DefaultOpenAIBaseURL {
+		t.Errorf("Unexpected OpenAI config %+v", openAI)
+	}
+}
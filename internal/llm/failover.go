@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"goproduct/internal/logging"
+	"goproduct/internal/tracing"
+)
+
+// FailoverLLM is a LanguageModel decorator over an ordered list of models. In order, the
+// next model is asked when one fails or times out. Racing, it asks all models at once and
+// returns the first successful response. The model that answered is traced.
+type FailoverLLM struct {
+	models  []LanguageModel
+	timeout time.Duration // Per model, 0 for none
+	racing  bool
+	tracer  tracing.Tracer
+	logger  *logging.Logger
+}
+
+// FailoverOption is a function that configures a FailoverLLM
+type FailoverOption func(*FailoverLLM)
+
+// NewFailoverLLM creates a language model asking the models in order, the primary first
+func NewFailoverLLM(models []LanguageModel, options ...FailoverOption) (*FailoverLLM, error) {
+	if len(models) == 0 {
+		return nil, errors.New("failover needs at least one model")
+	}
+	f := &FailoverLLM{
+		models: models,
+		tracer: tracing.NewNoopTracer(),
+		logger: logging.Get().Component("llm"),
+	}
+
+	// Apply options
+	for _, option := range options {
+		option(f)
+	}
+
+	return f, nil
+}
+
+// WithFailoverTimeout sets how long each model may take before the next one is asked
+func WithFailoverTimeout(timeout time.Duration) FailoverOption {
+	return func(f *FailoverLLM) {
+		if timeout > 0 {
+			f.timeout = timeout
+		}
+	}
+}
+
+// WithFailoverRacing asks all models at once and returns the first successful response
+func WithFailoverRacing(racing bool) FailoverOption {
+	return func(f *FailoverLLM) {
+		f.racing = racing
+	}
+}
+
+// WithFailoverTracer sets the tracer receiving an event per failure and per answer
+func WithFailoverTracer(tracer tracing.Tracer) FailoverOption {
+	return func(f *FailoverLLM) {
+		if tracer != nil {
+			f.tracer = tracer
+		}
+	}
+}
+
+// GenerateResponse generates a text response for a single prompt
+func (f *FailoverLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return f.do(ctx, "GenerateResponse", func(ctx context.Context, model LanguageModel) (string, error) {
+		return model.GenerateResponse(ctx, prompt)
+	})
+}
+
+// GenerateChat generates a response based on a conversation history
+func (f *FailoverLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
+	return f.do(ctx, "GenerateChat", func(ctx context.Context, model LanguageModel) (string, error) {
+		return model.GenerateChat(ctx, messages)
+	})
+}
+
+// Unwrap returns the primary language model
+func (f *FailoverLLM) Unwrap() LanguageModel {
+	return f.models[0]
+}
+
+// do runs call against the models in order or racing
+func (f *FailoverLLM) do(ctx context.Context, method string, call func(ctx context.Context, model LanguageModel) (string, error)) (string, error) {
+	if f.racing && len(f.models) > 1 {
+		return f.race(ctx, method, call)
+	}
+
+	errs := make([]error, 0, len(f.models))
+	for i, model := range f.models {
+		response, err := f.ask(ctx, model, call)
+		if err == nil {
+			f.traceModel(ctx, tracing.LevelDebug, method, i, nil)
+			return response, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", modelLabel(model), err))
+		f.traceModel(ctx, tracing.LevelWarning, method, i, err)
+
+		// The caller gave up, not the model
+		if ctx.Err() != nil {
+			return "", err
+		}
+		if i < len(f.models)-1 {
+			f.logger.Warn("LLM failed, falling back",
+				"method", method,
+				"model", modelLabel(model),
+				"next", modelLabel(f.models[i+1]),
+				"error", err)
+		}
+	}
+	return "", fmt.Errorf("all %d models failed: %w", len(f.models), errors.Join(errs...))
+}
+
+// race asks all models at once; the first success cancels the others
+func (f *FailoverLLM) race(ctx context.Context, method string, call func(ctx context.Context, model LanguageModel) (string, error)) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		index    int
+		response string
+		err      error
+	}
+	results := make(chan result, len(f.models))
+	for i, model := range f.models {
+		go func() {
+			response, err := f.ask(ctx, model, call)
+			results <- result{index: i, response: response, err: err}
+		}()
+	}
+
+	errs := make([]error, len(f.models))
+	for range f.models {
+		r := <-results
+		if r.err == nil {
+			f.traceModel(ctx, tracing.LevelDebug, method, r.index, nil)
+			return r.response, nil
+		}
+		errs[r.index] = fmt.Errorf("%s: %w", modelLabel(f.models[r.index]), r.err)
+		f.traceModel(ctx, tracing.LevelWarning, method, r.index, r.err)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("all %d models failed: %w", len(f.models), errors.Join(errs...))
+}
+
+// ask calls one model within the per-model timeout
+func (f *FailoverLLM) ask(ctx context.Context, model LanguageModel, call func(ctx context.Context, model LanguageModel) (string, error)) (string, error) {
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+	return call(ctx, model)
+}
+
+// traceModel records a trace event for the answer or failure of a model
+func (f *FailoverLLM) traceModel(ctx context.Context, level tracing.Level, method string, index int, err error) {
+	model := f.models[index]
+	metadata := map[string]interface{}{
+		"method":      method,
+		"model":       modelLabel(model),
+		"model_index": index,
+		"models":      len(f.models),
+		"racing":      f.racing,
+	}
+	message := fmt.Sprintf("%s answered by %s", method, modelLabel(model))
+	if err != nil {
+		metadata["error"] = err.Error()
+		message = fmt.Sprintf("%s failed on %s: %v", method, modelLabel(model), err)
+	}
+
+	f.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentLLM,
+		Operation: tracing.OperationGenerate,
+		Level:     level,
+		Message:   message,
+		Metadata:  tracing.Correlate(ctx, metadata),
+	})
+}
+
+// modelLabel names a model for logs and traces: its model name, or its type if it does
+// not report one
+func modelLabel(model LanguageModel) string {
+	if name := ModelName(model); name != "" {
+		return name
+	}
+	return fmt.Sprintf("%T", model)
+}
@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/tracing"
+)
+
+// namedLLM answers with its name after a delay, or fails with its error
+type namedLLM struct {
+	name  string
+	delay time.Duration
+	err   error
+	calls int
+}
+
+func (n *namedLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return n.GenerateChat(ctx, []Message{{Role: "user", Content: prompt}})
+}
+
+func (n *namedLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
+	n.calls++
+	if n.delay > 0 {
+		select {
+		case <-time.After(n.delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	if n.err != nil {
+		return "", n.err
+	}
+	return n.name, nil
+}
+
+func (n *namedLLM) Model() string {
+	return n.name
+}
+
+func TestFailoverLLM_FallsBackInOrder(t *testing.T) {
+	primary := &namedLLM{name: "primary", err: &StatusError{Provider: "Test", StatusCode: 500, Err: ErrProviderError}}
+	slow := &namedLLM{name: "slow", delay: time.Second}
+	backup := &namedLLM{name: "backup"}
+
+	var buf strings.Builder
+	model, err := NewFailoverLLM([]LanguageModel{primary, slow, backup},
+		WithFailoverTimeout(20*time.Millisecond),
+		WithFailoverTracer(tracing.NewWriterTracer(&buf, tracing.LevelVerbose)),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create failover LLM: %v", err)
+	}
+
+	response, err := model.GenerateResponse(context.Background(), "Hi")
+	if err != nil {
+		t.Fatalf("Expected the backup to answer, got %v", err)
+	}
+	if response != "backup" {
+		t.Errorf("Expected response from backup, got %q", response)
+	}
+	if !strings.Contains(buf.String(), "answered by backup") {
+		t.Errorf("Expected the answering model to be traced, got %q", buf.String())
+	}
+	if ModelName(model) != "primary" {
+		t.Errorf("Expected the primary model name, got %q", ModelName(model))
+	}
+}
+
+func TestFailoverLLM_AllFail(t *testing.T) {
+	first := &namedLLM{name: "first", err: errors.New("down")}
+	second := &namedLLM{name: "second", err: ErrRateLimited}
+	model, _ := NewFailoverLLM([]LanguageModel{first, second})
+
+	_, err := model.GenerateChat(context.Background(), []Message{{Role: "user", Content: "Hi"}})
+	if err == nil || !errors.Is(err, ErrRateLimited) || !strings.Contains(err.Error(), "first: down") {
+		t.Errorf("Expected the errors of every model, got %v", err)
+	}
+
+	if _, err := NewFailoverLLM(nil); err == nil {
+		t.Error("Expected an error without models")
+	}
+}
+
+func TestFailoverLLM_StopsWhenCallerGivesUp(t *testing.T) {
+	first := &namedLLM{name: "first", delay: time.Second}
+	second := &namedLLM{name: "second"}
+	model, _ := NewFailoverLLM([]LanguageModel{first, second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := model.GenerateResponse(ctx, "Hi"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the caller's deadline, got %v", err)
+	}
+	if second.calls != 0 {
+		t.Errorf("Expected no fallback after the caller gave up, got %d calls", second.calls)
+	}
+}
+
+func TestFailoverLLM_Racing(t *testing.T) {
+	slow := &namedLLM{name: "slow", delay: time.Second}
+	failing := &namedLLM{name: "failing", err: errors.New("down")}
+	fast := &namedLLM{name: "fast", delay: 10 * time.Millisecond}
+	model, _ := NewFailoverLLM([]LanguageModel{slow, failing, fast}, WithFailoverRacing(true))
+
+	start := time.Now()
+	response, err := model.GenerateResponse(context.Background(), "Hi")
+	if err != nil {
+		t.Fatalf("Expected the fast model to answer, got %v", err)
+	}
+	if response != "fast" {
+		t.Errorf("Expected response from fast, got %q", response)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the race to end with the first answer, took %s", elapsed)
+	}
+}
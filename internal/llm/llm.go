@@ -3,6 +3,8 @@ package llm
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 )
 
 // LanguageModel defines the core interface that all language model implementations must satisfy
@@ -37,4 +39,83 @@ var (
 	ErrInvalidResponse = errors.New("invalid response from LLM")
 	ErrContextTooLarge = errors.New("context size exceeds model limits")
 	ErrProviderError   = errors.New("provider returned an error")
+	ErrModelNotFound   = errors.New("model not available on provider")
 )
+
+// StatusError is returned when a provider answers with a non-success HTTP status.
+// It unwraps to the matching common error (ErrRateLimited, ErrProviderError, etc.)
+// so callers can use errors.Is while retry logic can still inspect the status code.
+type StatusError struct {
+	Provider   string // Provider name, e.g. "OpenAI"
+	StatusCode int    // HTTP status code
+	Message    string // Error message returned by the provider
+	Err        error  // Common error type this status maps to
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%v: %s API error (status %d): %s", e.Err, e.Provider, e.StatusCode, e.Message)
+}
+
+// Unwrap returns the common error type this status maps to
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// TokenHandler receives tokens from a streaming response as they arrive.
+// Returning an error stops the stream and the error is returned to the caller.
+type TokenHandler func(token StreamToken) error
+
+// GenerateChatStream generates a chat response and delivers it token by token to handler.
+// Models implementing StreamingLLM stream natively; all other models fall back to a
+// single GenerateChat call delivered as one token. The full response text is returned.
+func GenerateChatStream(ctx context.Context, model LanguageModel, messages []Message, handler TokenHandler) (string, error) {
+	streamer, ok := model.(StreamingLLM)
+	if !ok {
+		response, err := model.GenerateChat(ctx, messages)
+		if err != nil {
+			return "", err
+		}
+		if handler != nil {
+			if err := handler(StreamToken{Text: response}); err != nil {
+				return response, err
+			}
+			if err := handler(StreamToken{Done: true}); err != nil {
+				return response, err
+			}
+		}
+		return response, nil
+	}
+
+	// Cancel the underlying stream if the handler stops early
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tokens, err := streamer.StreamChat(streamCtx, messages)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for token := range tokens {
+		if token.Error != nil {
+			return sb.String(), token.Error
+		}
+		sb.WriteString(token.Text)
+		if handler != nil {
+			if err := handler(token); err != nil {
+				return sb.String(), err
+			}
+		}
+		if token.Done {
+			break
+		}
+	}
+
+	// The stream may have been closed due to context cancellation
+	if err := ctx.Err(); err != nil {
+		return sb.String(), err
+	}
+
+	return sb.String(), nil
+}
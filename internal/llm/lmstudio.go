@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -24,6 +25,7 @@ type LMStudioLLM struct {
 	topP             float32 // Top-p sampling parameter
 	presencePenalty  float32 // Presence penalty parameter
 	frequencyPenalty float32 // Frequency penalty parameter
+	embeddingModel   string  // Model of GenerateEmbeddings
 }
 
 // LMStudioOption is a function that configures an LMStudioLLM
@@ -31,15 +33,17 @@ type LMStudioOption func(*LMStudioLLM)
 
 // LMStudioRequest represents a request to the LM Studio API for completions
 type LMStudioRequest struct {
-	Model            string    `json:"model"`
-	Prompt           string    `json:"prompt,omitempty"`
-	Messages         []Message `json:"messages,omitempty"`
-	Temperature      float32   `json:"temperature"`
-	MaxTokens        int       `json:"max_tokens"`
-	TopP             float32   `json:"top_p,omitempty"`
-	FrequencyPenalty float32   `json:"frequency_penalty,omitempty"`
-	PresencePenalty  float32   `json:"presence_penalty,omitempty"`
-	Stream           bool      `json:"stream,omitempty"`
+	Model            string          `json:"model"`
+	Prompt           string          `json:"prompt,omitempty"`
+	Messages         []OpenAIMessage `json:"messages,omitempty"`
+	Temperature      float32         `json:"temperature"`
+	MaxTokens        int             `json:"max_tokens"`
+	TopP             float32         `json:"top_p,omitempty"`
+	FrequencyPenalty float32         `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float32         `json:"presence_penalty,omitempty"`
+	Stream           bool            `json:"stream,omitempty"`
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"` // JSON output of chat requests, see WithResponseSchema
 }
 
 // LMStudioChatResponse represents a response from the LM Studio chat API
@@ -79,6 +83,23 @@ type LMStudioCompletionResponse struct {
 	} `json:"usage"`
 }
 
+// LMStudioStreamChunk represents a single server-sent event chunk from a streaming request
+type LMStudioStreamChunk struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index int    `json:"index"`
+		Text  string `json:"text,omitempty"` // Used by the completions endpoint
+		Delta struct {
+			Role    string `json:"role,omitempty"`
+			Content string `json:"content,omitempty"`
+		} `json:"delta"` // Used by the chat completions endpoint
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
 // NewLMStudioLLM creates a new LM Studio LLM with the specified options
 func NewLMStudioLLM(endpoint string, options ...LMStudioOption) (*LMStudioLLM, error) {
 	if endpoint == "" {
@@ -98,6 +119,7 @@ func NewLMStudioLLM(endpoint string, options ...LMStudioOption) (*LMStudioLLM, e
 		topP:             1.0,
 		presencePenalty:  0.0,
 		frequencyPenalty: 0.0,
+		embeddingModel:   "text-embedding-nomic-embed-text-v1.5",
 	}
 
 	// Apply options
@@ -165,6 +187,30 @@ func WithLMStudioFrequencyPenalty(penalty float32) LMStudioOption {
 	}
 }
 
+// WithLMStudioEmbeddingModel sets the model of GenerateEmbeddings, which must be loaded in LM Studio
+func WithLMStudioEmbeddingModel(model string) LMStudioOption {
+	return func(l *LMStudioLLM) {
+		if model != "" {
+			l.embeddingModel = model
+		}
+	}
+}
+
+// Model returns the name of the model requests are sent to
+func (l *LMStudioLLM) Model() string {
+	return l.model
+}
+
+// SupportsStructuredOutput reports that chat requests honor WithResponseSchema
+func (l *LMStudioLLM) SupportsStructuredOutput() bool {
+	return true
+}
+
+// SupportsVision reports whether the loaded model accepts images, see VisionModels
+func (l *LMStudioLLM) SupportsVision() bool {
+	return isVisionModel(l.model)
+}
+
 // GenerateResponse generates a text response for a single prompt
 func (l *LMStudioLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
 	// LM Studio implements OpenAI-compatible API
@@ -220,7 +266,7 @@ func (l *LMStudioLLM) GenerateResponse(ctx context.Context, prompt string) (stri
 
 	// Check for error status code
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("LM Studio API error (status %d): %s", resp.StatusCode, string(body))
+		return "", lmStudioStatusError(resp.StatusCode, body)
 	}
 
 	// Parse response
@@ -234,6 +280,11 @@ func (l *LMStudioLLM) GenerateResponse(ctx context.Context, prompt string) (stri
 		return "", errors.New("invalid response")
 	}
 
+	ReportUsage(ctx, Usage{
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+		TotalTokens:      response.Usage.TotalTokens,
+	})
 	return response.Choices[0].Text, nil
 }
 
@@ -253,12 +304,13 @@ func (l *LMStudioLLM) GenerateChat(ctx context.Context, messages []Message) (str
 	// Create request payload
 	request := LMStudioRequest{
 		Model:            l.model,
-		Messages:         messages,
+		Messages:         toOpenAIMessages(messages, l.SupportsVision()),
 		Temperature:      l.temperature,
 		MaxTokens:        l.maxTokens,
 		TopP:             l.topP,
 		FrequencyPenalty: l.frequencyPenalty,
 		PresencePenalty:  l.presencePenalty,
+		ResponseFormat:   responseFormat(ctx),
 	}
 
 	requestJSON, err := json.Marshal(request)
@@ -291,7 +343,7 @@ func (l *LMStudioLLM) GenerateChat(ctx context.Context, messages []Message) (str
 
 	// Check for error status code
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("LM Studio API error (status %d): %s", resp.StatusCode, string(body))
+		return "", lmStudioStatusError(resp.StatusCode, body)
 	}
 
 	// Parse response
@@ -305,12 +357,181 @@ func (l *LMStudioLLM) GenerateChat(ctx context.Context, messages []Message) (str
 		return "", errors.New("invalid response")
 	}
 
+	ReportUsage(ctx, Usage{
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+		TotalTokens:      response.Usage.TotalTokens,
+	})
 	return response.Choices[0].Message.Content, nil
 }
 
-// Initialize the factory function
+// StreamResponse streams a response token by token for a single prompt
+func (l *LMStudioLLM) StreamResponse(ctx context.Context, prompt string) (chan StreamToken, error) {
+	request := LMStudioRequest{
+		Model:            l.model,
+		Prompt:           prompt,
+		Temperature:      l.temperature,
+		MaxTokens:        l.maxTokens,
+		TopP:             l.topP,
+		FrequencyPenalty: l.frequencyPenalty,
+		PresencePenalty:  l.presencePenalty,
+		Stream:           true,
+	}
+
+	return l.stream(ctx, fmt.Sprintf("%s/completions", l.endpoint), request)
+}
+
+// StreamChat streams a response token by token for a conversation history
+func (l *LMStudioLLM) StreamChat(ctx context.Context, messages []Message) (chan StreamToken, error) {
+	request := LMStudioRequest{
+		Model:            l.model,
+		Messages:         toOpenAIMessages(messages, l.SupportsVision()),
+		Temperature:      l.temperature,
+		MaxTokens:        l.maxTokens,
+		TopP:             l.topP,
+		FrequencyPenalty: l.frequencyPenalty,
+		PresencePenalty:  l.presencePenalty,
+		Stream:           true,
+	}
+
+	return l.stream(ctx, fmt.Sprintf("%s/chat/completions", l.endpoint), request)
+}
+
+// stream sends a streaming request and relays the server-sent events as tokens.
+// The returned channel is closed when the stream ends, fails or the context is cancelled.
+func (l *LMStudioLLM) stream(ctx context.Context, endpoint string, request LMStudioRequest) (chan StreamToken, error) {
+	// Check for context cancellation
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		// Continue processing
+	}
+
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(requestJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	// Send request. The client timeout would cut long streams short,
+	// so streaming relies on the context for cancellation instead.
+	client := *l.client
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to LM Studio: %w", err)
+	}
+
+	// Check for error status code
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, lmStudioStatusError(resp.StatusCode, body)
+	}
+
+	tokens := make(chan StreamToken)
+	go func() {
+		defer close(tokens)
+		defer resp.Body.Close()
+
+		// send delivers a token unless the caller has gone away
+		send := func(token StreamToken) bool {
+			select {
+			case tokens <- token:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data:") {
+				// Skip blank separators, comments and other SSE fields
+				continue
+			}
+
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "[DONE]" {
+				send(StreamToken{Done: true})
+				return
+			}
+
+			var chunk LMStudioStreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				send(StreamToken{Error: fmt.Errorf("failed to parse stream chunk: %w", err)})
+				return
+			}
+
+			for _, choice := range chunk.Choices {
+				text := choice.Delta.Content
+				if text == "" {
+					text = choice.Text
+				}
+				if text != "" && !send(StreamToken{Text: text}) {
+					return
+				}
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			if ctx.Err() != nil {
+				send(StreamToken{Error: ctx.Err()})
+				return
+			}
+			send(StreamToken{Error: fmt.Errorf("failed to read stream: %w", err)})
+			return
+		}
+
+		// Stream ended without an explicit [DONE] marker
+		send(StreamToken{Done: true})
+	}()
+
+	return tokens, nil
+}
+
+// Ping lists the models of the server, checking that LM Studio is running
+func (l *LMStudioLLM) Ping(ctx context.Context) error {
+	return ping(ctx, l.client, "LM Studio", fmt.Sprintf("%s/models", l.endpoint), nil)
+}
+
+// GenerateEmbeddings returns the embedding of each text from the embeddings endpoint
+func (l *LMStudioLLM) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	client := embeddingsClient{
+		client:      l.client,
+		endpoint:    fmt.Sprintf("%s/embeddings", strings.TrimRight(l.endpoint, "/")),
+		model:       l.embeddingModel,
+		provider:    "LM Studio",
+		statusError: lmStudioStatusError,
+	}
+	return client.generate(ctx, texts)
+}
+
+// lmStudioStatusError converts a non-200 response into an error wrapping the matching LLM error type
+func lmStudioStatusError(status int, body []byte) error {
+	err := ErrProviderError
+	if status == http.StatusTooManyRequests {
+		err = ErrRateLimited
+	}
+	return &StatusError{Provider: "LM Studio", StatusCode: status, Message: string(body), Err: err}
+}
+
+// Register the provider
 func init() {
-	newLMStudioFromConfig = createLMStudioFromConfig
+	Register(ProviderLMStudio, createLMStudioFromConfig)
+	RegisterConfigLoader(ProviderLMStudio, func(s Settings) (ProviderConfig, error) { return lmStudioSettings(s) })
 }
 
 // Implementation for creating an LMStudioLLM from config
@@ -340,6 +561,10 @@ func createLMStudioFromConfig(config ProviderConfig) (LanguageModel, error) {
 		WithLMStudioModel(lmStudioConfig.Model),
 		WithLMStudioTemperature(lmStudioConfig.Temperature),
 		WithLMStudioMaxTokens(lmStudioConfig.MaxTokens),
+		WithLMStudioEmbeddingModel(lmStudioConfig.EmbeddingModel),
+	}
+	if lmStudioConfig.TimeoutSec > 0 {
+		options = append(options, WithLMStudioTimeout(lmStudioConfig.TimeoutSec))
 	}
 
 	return NewLMStudioLLM(lmStudioConfig.Endpoint, options...)
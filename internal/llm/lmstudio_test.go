@@ -0,0 +1,152 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStreamingServer returns a test server that streams the given tokens as SSE chunks
+func newStreamingServer(t *testing.T, tokens []string, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request LMStudioRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if !request.Stream {
+			t.Errorf("Expected stream=true in request")
+		}
+
+		flusher, _ := w.(http.Flusher)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, token := range tokens {
+			if r.URL.Path == "/chat/completions" {
+				fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", token)
+			} else {
+				fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"text\":%q}]}\n\n", token)
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			if delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestLMStudioStreamChat(t *testing.T) {
+	server := newStreamingServer(t, []string{"Hello", ", ", "world", "!"}, 0)
+	defer server.Close()
+
+	model, err := NewLMStudioLLM(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create LM Studio LLM: %v", err)
+	}
+
+	var received []string
+	response, err := GenerateChatStream(context.Background(), model, []Message{{Role: "user", Content: "Hi"}}, func(token StreamToken) error {
+		if token.Text != "" {
+			received = append(received, token.Text)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream chat: %v", err)
+	}
+
+	if response != "Hello, world!" {
+		t.Errorf("Expected response %q, got %q", "Hello, world!", response)
+	}
+	if len(received) != 4 {
+		t.Errorf("Expected 4 tokens, got %d: %v", len(received), received)
+	}
+}
+
+func TestLMStudioStreamResponse(t *testing.T) {
+	server := newStreamingServer(t, []string{"one ", "two"}, 0)
+	defer server.Close()
+
+	model, err := NewLMStudioLLM(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create LM Studio LLM: %v", err)
+	}
+
+	tokens, err := model.StreamResponse(context.Background(), "count")
+	if err != nil {
+		t.Fatalf("Failed to stream response: %v", err)
+	}
+
+	var sb strings.Builder
+	done := false
+	for token := range tokens {
+		if token.Error != nil {
+			t.Fatalf("Unexpected stream error: %v", token.Error)
+		}
+		sb.WriteString(token.Text)
+		done = done || token.Done
+	}
+
+	if sb.String() != "one two" {
+		t.Errorf("Expected %q, got %q", "one two", sb.String())
+	}
+	if !done {
+		t.Error("Expected a Done token at the end of the stream")
+	}
+}
+
+func TestLMStudioStreamCancellation(t *testing.T) {
+	server := newStreamingServer(t, []string{"a", "b", "c", "d", "e"}, 100*time.Millisecond)
+	defer server.Close()
+
+	model, err := NewLMStudioLLM(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create LM Studio LLM: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	count := 0
+	_, err = GenerateChatStream(ctx, model, []Message{{Role: "user", Content: "Hi"}}, func(token StreamToken) error {
+		count++
+		if count == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Expected error due to cancelled context, got nil")
+	}
+	if count >= 5 {
+		t.Errorf("Expected stream to stop early, received %d tokens", count)
+	}
+}
+
+func TestGenerateChatStreamFallback(t *testing.T) {
+	model := NewMockLLM(WithFixedResponse("full response"))
+
+	var tokens []StreamToken
+	response, err := GenerateChatStream(context.Background(), model, []Message{{Role: "user", Content: "Hi"}}, func(token StreamToken) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate fallback stream: %v", err)
+	}
+
+	if response != "full response" {
+		t.Errorf("Expected %q, got %q", "full response", response)
+	}
+	if len(tokens) != 2 || tokens[0].Text != "full response" || !tokens[1].Done {
+		t.Errorf("Expected a single text token followed by Done, got %+v", tokens)
+	}
+}
@@ -0,0 +1,13 @@
+package llm
+
+import "goproduct/internal/metrics"
+
+// Metrics of the language model calls, recorded by MeteredLLM
+var (
+	requestLatency = metrics.Default().Histogram("llm_request_seconds",
+		"Latency of language model calls, by model and method", metrics.SlowBuckets, "model", "method")
+	requestErrors = metrics.Default().Counter("llm_errors_total",
+		"Language model calls that failed, by model and method", "model", "method")
+	tokensUsed = metrics.Default().Counter("llm_tokens_total",
+		"Tokens used by language model calls, by model and type (prompt or completion)", "model", "type")
+)
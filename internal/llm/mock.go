@@ -150,9 +150,9 @@ func WithFixedResponse(response string) MockOption {
 	}
 }
 
-// Initialize the factory function
+// Register the provider
 func init() {
-	newMockFromConfig = createMockFromConfig
+	Register(ProviderMock, createMockFromConfig)
 }
 
 // Implementation for creating a MockLLM from config
@@ -39,7 +39,8 @@ func (e *EchoLLM) GenerateResponse(ctx context.Context, prompt string) (string,
 
 // GenerateChat implements the LLM interface for a conversation
 func (e *EchoLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
-	// Extract the last user message as the prompt
+	// Extract the last user message as the prompt, with its images as alt text
+	messages = TextOnly(messages)
 	var lastUserMessage string
 	for i := len(messages) - 1; i >= 0; i-- {
 		if messages[i].Role == "user" {
@@ -104,10 +105,28 @@ func (c *ExceptionConfig) GetProvider() string {
 	return ProviderException
 }
 
-// Initialize the factory functions
+// Register the providers
 func init() {
-	newEchoFromConfig = createEchoFromConfig
-	newExceptionFromConfig = createExceptionFromConfig
+	Register(ProviderEcho, createEchoFromConfig)
+	RegisterConfigLoader(ProviderEcho, func(s Settings) (ProviderConfig, error) {
+		delay, err := delaySetting(s)
+		return &EchoConfig{BaseConfig: BaseConfig{Provider: ProviderEcho}, DelaySeconds: delay}, err
+	})
+	Register(ProviderException, createExceptionFromConfig)
+	RegisterConfigLoader(ProviderException, func(s Settings) (ProviderConfig, error) {
+		delay, err := delaySetting(s)
+		return &ExceptionConfig{BaseConfig: BaseConfig{Provider: ProviderException}, DelaySeconds: delay}, err
+	})
+}
+
+// delaySetting returns the delay setting in seconds, else LLM_DELAY, which is ignored
+// when it is not a whole number of seconds
+func delaySetting(s Settings) (int, error) {
+	if s["delay"] != "" {
+		return s.Int("delay", "", 0)
+	}
+	delay, _ := strconv.Atoi(os.Getenv("LLM_DELAY"))
+	return delay, nil
 }
 
 // Implementation for creating EchoLLM from config
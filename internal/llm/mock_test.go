@@ -109,7 +109,7 @@ func TestMockLLM(t *testing.T) {
 	mockConfig := LoadMockConfig()
 	mockConfig.ResponsePrefix = "Factory test: "
 
-	llm, err := newMockFromConfig(mockConfig)
+	llm, err := createMockFromConfig(mockConfig)
 	if err != nil {
 		t.Fatalf("Failed to create mock from config: %v", err)
 	}
@@ -1,26 +1,102 @@
 package llm
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
-// OllamaLLM is the Ollama implementation of the LLM interface
+// OllamaLLM is the Ollama implementation of the LLM interface.
+// It speaks the native Ollama API (/api/chat, /api/generate and /api/pull).
 type OllamaLLM struct {
-	// Will use standard http client for now
 	client      *http.Client
 	endpoint    string // Usually "http://localhost:11434"
 	model       string // e.g., "llama3", "mistral"
 	temperature float32
 	maxTokens   int
+	timeoutSec  int    // Timeout in seconds for requests
+	keepAlive   string // How long the model stays loaded after a request, empty for the server default
 }
 
 // OllamaOption is a function that configures an OllamaLLM
 type OllamaOption func(*OllamaLLM)
 
+// OllamaOptions holds the model parameters sent with Ollama requests
+type OllamaOptions struct {
+	Temperature float32 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+// OllamaChatRequest represents a request to the Ollama /api/chat endpoint
+type OllamaChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []OllamaMessage `json:"messages"`
+	Stream    bool            `json:"stream"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
+	Options   OllamaOptions   `json:"options"`
+	Format    JSONSchema      `json:"format,omitempty"` // JSON output, see WithResponseSchema
+}
+
+// OllamaMessage is a message in the Ollama chat format, with images as base64 strings
+type OllamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+// OllamaGenerateRequest represents a request to the Ollama /api/generate endpoint
+type OllamaGenerateRequest struct {
+	Model     string        `json:"model"`
+	Prompt    string        `json:"prompt"`
+	Stream    bool          `json:"stream"`
+	KeepAlive string        `json:"keep_alive,omitempty"`
+	Options   OllamaOptions `json:"options"`
+}
+
+// OllamaChatResponse represents a response from the Ollama /api/chat endpoint
+type OllamaChatResponse struct {
+	Model           string  `json:"model"`
+	CreatedAt       string  `json:"created_at"`
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	DoneReason      string  `json:"done_reason,omitempty"`
+	PromptEvalCount int     `json:"prompt_eval_count,omitempty"`
+	EvalCount       int     `json:"eval_count,omitempty"`
+}
+
+// OllamaGenerateResponse represents a response from the Ollama /api/generate endpoint
+type OllamaGenerateResponse struct {
+	Model           string `json:"model"`
+	CreatedAt       string `json:"created_at"`
+	Response        string `json:"response"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason,omitempty"`
+	PromptEvalCount int    `json:"prompt_eval_count,omitempty"`
+	EvalCount       int    `json:"eval_count,omitempty"`
+}
+
+// OllamaPullStatus represents a progress line streamed by the Ollama /api/pull endpoint
+type OllamaPullStatus struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// OllamaErrorResponse represents an error payload returned by the Ollama API
+type OllamaErrorResponse struct {
+	Error string `json:"error"`
+}
+
 // NewOllamaLLM creates a new Ollama LLM with the specified options
 func NewOllamaLLM(endpoint string, options ...OllamaOption) (*OllamaLLM, error) {
 	if endpoint == "" {
@@ -34,6 +110,7 @@ func NewOllamaLLM(endpoint string, options ...OllamaOption) (*OllamaLLM, error)
 		model:       "llama3",
 		temperature: 0.7,
 		maxTokens:   1024,
+		timeoutSec:  120, // Local models can be slow to load on first use
 	}
 
 	// Apply options
@@ -41,7 +118,11 @@ func NewOllamaLLM(endpoint string, options ...OllamaOption) (*OllamaLLM, error)
 		option(llm)
 	}
 
+	// Update client timeout based on timeoutSec setting
+	llm.client.Timeout = time.Duration(llm.timeoutSec) * time.Second
+
 	// Validate endpoint
+	llm.endpoint = strings.TrimRight(llm.endpoint, "/")
 	if !strings.HasPrefix(llm.endpoint, "http") {
 		return nil, fmt.Errorf("invalid Ollama endpoint: %s, must start with http or https", llm.endpoint)
 	}
@@ -70,54 +151,266 @@ func WithOllamaMaxTokens(maxTokens int) OllamaOption {
 	}
 }
 
+// WithOllamaTimeout sets the timeout for requests
+func WithOllamaTimeout(timeoutSec int) OllamaOption {
+	return func(o *OllamaLLM) {
+		if timeoutSec > 0 {
+			o.timeoutSec = timeoutSec
+		}
+	}
+}
+
+// WithOllamaKeepAlive sets how long the model stays loaded after a request,
+// e.g. "5m", "1h", "-1" to keep it loaded or "0" to unload it immediately
+func WithOllamaKeepAlive(keepAlive string) OllamaOption {
+	return func(o *OllamaLLM) {
+		o.keepAlive = keepAlive
+	}
+}
+
+// Model returns the name of the model requests are sent to
+func (o *OllamaLLM) Model() string {
+	return o.model
+}
+
+// SupportsStructuredOutput reports that chat requests honor WithResponseSchema
+func (o *OllamaLLM) SupportsStructuredOutput() bool {
+	return true
+}
+
+// SupportsVision reports whether the model accepts images, see VisionModels
+func (o *OllamaLLM) SupportsVision() bool {
+	return isVisionModel(o.model)
+}
+
 // GenerateResponse generates a text response for a single prompt
 func (o *OllamaLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
-	// This is a placeholder implementation
-	// When we implement the real Ollama API calls, this would be replaced
+	request := OllamaGenerateRequest{
+		Model:     o.model,
+		Prompt:    prompt,
+		Stream:    false,
+		KeepAlive: o.keepAlive,
+		Options:   o.options(),
+	}
 
-	// Check for context cancellation
-	select {
-	case <-ctx.Done():
-		return "", ctx.Err()
-	default:
-		// Continue processing
+	body, err := o.post(ctx, "/api/generate", request)
+	if err != nil {
+		return "", err
+	}
+
+	var response OllamaGenerateResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	return fmt.Sprintf("[Ollama %s] This is a placeholder response. The actual Ollama integration will use the HTTP API at %s.", o.model, o.endpoint), nil
+	if !response.Done {
+		return "", ErrInvalidResponse
+	}
+
+	ReportUsage(ctx, Usage{PromptTokens: response.PromptEvalCount, CompletionTokens: response.EvalCount})
+	return response.Response, nil
+}
+
+// toOllamaMessages converts internal messages to the Ollama format. Ollama only takes
+// inline image data, so images given by URL, and all images for text-only models, are
+// replaced by their placeholders.
+func (o *OllamaLLM) toOllamaMessages(messages []Message) []OllamaMessage {
+	vision := o.SupportsVision()
+	result := make([]OllamaMessage, len(messages))
+	for i, m := range messages {
+		result[i] = OllamaMessage{Role: m.Role, Content: m.Content}
+		for _, image := range m.Images {
+			if !vision || len(image.Data) == 0 {
+				result[i].Content = withPlaceholder(result[i].Content, image)
+				continue
+			}
+			result[i].Images = append(result[i].Images, base64.StdEncoding.EncodeToString(image.Data))
+		}
+	}
+	return result
 }
 
 // GenerateChat generates a response based on a conversation history
 func (o *OllamaLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
-	// This is a placeholder implementation
-	// Ollama supports chat-style interactions with a specific format
+	request := OllamaChatRequest{
+		Model:     o.model,
+		Messages:  o.toOllamaMessages(messages),
+		Stream:    false,
+		KeepAlive: o.keepAlive,
+		Options:   o.options(),
+	}
+	if schema, ok := ResponseSchemaFrom(ctx); ok {
+		request.Format = schema.Schema
+	}
 
+	body, err := o.post(ctx, "/api/chat", request)
+	if err != nil {
+		return "", err
+	}
+
+	var response OllamaChatResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !response.Done {
+		return "", ErrInvalidResponse
+	}
+
+	ReportUsage(ctx, Usage{PromptTokens: response.PromptEvalCount, CompletionTokens: response.EvalCount})
+	return response.Message.Content, nil
+}
+
+// Ping lists the local models of the server, checking that Ollama is running
+func (o *OllamaLLM) Ping(ctx context.Context) error {
+	return ping(ctx, o.client, "Ollama", o.endpoint+"/api/tags", nil)
+}
+
+// PullModel asks the Ollama server to pull the configured model and reports progress
+// to the optional callback. Errors reported in the pull status stream are returned
+// wrapping ErrModelNotFound.
+func (o *OllamaLLM) PullModel(ctx context.Context, progress func(status OllamaPullStatus)) error {
+	requestJSON, err := json.Marshal(map[string]interface{}{"model": o.model, "stream": true})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.endpoint+"/api/pull", bytes.NewBuffer(requestJSON))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Pulls can take far longer than a generation, so rely on the context instead
+	client := *o.client
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return o.statusError(resp.StatusCode, body)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lastStatus := ""
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var status OllamaPullStatus
+		if err := json.Unmarshal(line, &status); err != nil {
+			return fmt.Errorf("failed to parse pull status: %w", err)
+		}
+		if status.Error != "" {
+			return fmt.Errorf("%w: Ollama failed to pull %s: %s", ErrModelNotFound, o.model, status.Error)
+		}
+		if progress != nil {
+			progress(status)
+		}
+		lastStatus = status.Status
+	}
+
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to read pull status: %w", err)
+	}
+
+	if lastStatus != "success" {
+		return fmt.Errorf("%w: Ollama pull of %s ended with status %q", ErrProviderError, o.model, lastStatus)
+	}
+
+	return nil
+}
+
+// options returns the model parameters for a request
+func (o *OllamaLLM) options() OllamaOptions {
+	return OllamaOptions{
+		Temperature: o.temperature,
+		NumPredict:  o.maxTokens,
+	}
+}
+
+// post sends a JSON request to the given API path and returns the response body
+func (o *OllamaLLM) post(ctx context.Context, path string, request interface{}) ([]byte, error) {
 	// Check for context cancellation
 	select {
 	case <-ctx.Done():
-		return "", ctx.Err()
+		return nil, ctx.Err()
 	default:
 		// Continue processing
 	}
 
-	// Extract the last user message for demonstration
-	var lastMessage string
-	for i := len(messages) - 1; i >= 0; i-- {
-		if messages[i].Role == "user" {
-			lastMessage = messages[i].Content
-			break
-		}
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, "POST", o.endpoint+path, bytes.NewBuffer(requestJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	// Send request
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	if lastMessage == "" {
-		lastMessage = "No user message found in conversation"
+	// Check for error status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, o.statusError(resp.StatusCode, body)
 	}
 
-	return fmt.Sprintf("[Ollama %s] Chat response placeholder for prompt: %s", o.model, lastMessage), nil
+	return body, nil
+}
+
+// statusError converts a non-200 response into an error wrapping the matching LLM error type.
+// Ollama answers 404 with "model ... not found, try pulling it first" for models that
+// have not been pulled yet.
+func (o *OllamaLLM) statusError(status int, body []byte) error {
+	message := string(body)
+	var errResponse OllamaErrorResponse
+	if err := json.Unmarshal(body, &errResponse); err == nil && errResponse.Error != "" {
+		message = errResponse.Error
+	}
+
+	switch {
+	case status == http.StatusNotFound || strings.Contains(message, "try pulling it first"):
+		return &StatusError{Provider: "Ollama", StatusCode: status, Message: fmt.Sprintf("model %s: %s", o.model, message), Err: ErrModelNotFound}
+	case status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable:
+		return &StatusError{Provider: "Ollama", StatusCode: status, Message: message, Err: ErrRateLimited}
+	case strings.Contains(message, "context length"):
+		return &StatusError{Provider: "Ollama", StatusCode: status, Message: message, Err: ErrContextTooLarge}
+	default:
+		return &StatusError{Provider: "Ollama", StatusCode: status, Message: message, Err: ErrProviderError}
+	}
 }
 
-// Initialize the factory function
+// Register the provider
 func init() {
-	newOllamaFromConfig = createOllamaFromConfig
+	Register(ProviderOllama, createOllamaFromConfig)
+	RegisterConfigLoader(ProviderOllama, func(s Settings) (ProviderConfig, error) { return ollamaSettings(s) })
 }
 
 // Implementation for creating an OllamaLLM from config
@@ -139,6 +432,7 @@ func createOllamaFromConfig(config ProviderConfig) (LanguageModel, error) {
 			WithOllamaModel(baseConfig.Model),
 			WithOllamaTemperature(baseConfig.Temperature),
 			WithOllamaMaxTokens(baseConfig.MaxTokens),
+			WithOllamaKeepAlive(getEnvWithDefault("OLLAMA_KEEP_ALIVE", "")),
 		)
 	}
 
@@ -147,6 +441,8 @@ func createOllamaFromConfig(config ProviderConfig) (LanguageModel, error) {
 		WithOllamaModel(ollamaConfig.Model),
 		WithOllamaTemperature(ollamaConfig.Temperature),
 		WithOllamaMaxTokens(ollamaConfig.MaxTokens),
+		WithOllamaKeepAlive(ollamaConfig.KeepAlive),
+		WithOllamaTimeout(ollamaConfig.TimeoutSec),
 	}
 
 	return NewOllamaLLM(ollamaConfig.Endpoint, options...)
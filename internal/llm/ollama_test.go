@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOllamaGenerateChatAndResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/chat":
+			var request OllamaChatRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Fatalf("Failed to decode request: %v", err)
+			}
+			if request.Model != "llama-test" || request.KeepAlive != "10m" || request.Stream || request.Options.NumPredict != 64 {
+				t.Errorf("Unexpected chat request: %+v", request)
+			}
+			w.Write([]byte(`{"model":"llama-test","message":{"role":"assistant","content":"chat reply"},"done":true}`))
+		case "/api/generate":
+			var request OllamaGenerateRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Fatalf("Failed to decode request: %v", err)
+			}
+			if request.Prompt != "Hello" {
+				t.Errorf("Unexpected prompt: %q", request.Prompt)
+			}
+			w.Write([]byte(`{"model":"llama-test","response":"generate reply","done":true}`))
+		default:
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	model, err := NewLLM(context.Background(), &OllamaConfig{
+		BaseConfig: BaseConfig{Provider: ProviderOllama, Model: "llama-test", Temperature: 0.2, MaxTokens: 64},
+		Endpoint:   server.URL,
+		KeepAlive:  "10m",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create Ollama LLM: %v", err)
+	}
+
+	response, err := model.GenerateChat(context.Background(), []Message{{Role: "user", Content: "Hi"}})
+	if err != nil {
+		t.Fatalf("Failed to generate chat: %v", err)
+	}
+	if response != "chat reply" {
+		t.Errorf("Expected %q, got %q", "chat reply", response)
+	}
+
+	response, err = model.GenerateResponse(context.Background(), "Hello")
+	if err != nil {
+		t.Fatalf("Failed to generate response: %v", err)
+	}
+	if response != "generate reply" {
+		t.Errorf("Expected %q, got %q", "generate reply", response)
+	}
+}
+
+func TestOllamaModelNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"model \"missing\" not found, try pulling it first"}`))
+	}))
+	defer server.Close()
+
+	model, err := NewOllamaLLM(server.URL, WithOllamaModel("missing"))
+	if err != nil {
+		t.Fatalf("Failed to create Ollama LLM: %v", err)
+	}
+
+	_, err = model.GenerateChat(context.Background(), []Message{{Role: "user", Content: "Hi"}})
+	if !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("Expected ErrModelNotFound, got %v", err)
+	}
+}
+
+func TestOllamaPullModel(t *testing.T) {
+	failPull := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/pull" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		if failPull {
+			fmt.Fprintln(w, `{"error":"pull model manifest: file does not exist"}`)
+			return
+		}
+		fmt.Fprintln(w, `{"status":"downloading","digest":"sha256:abc","total":100,"completed":100}`)
+		fmt.Fprintln(w, `{"status":"success"}`)
+	}))
+	defer server.Close()
+
+	model, err := NewOllamaLLM(server.URL, WithOllamaModel("llama-test"))
+	if err != nil {
+		t.Fatalf("Failed to create Ollama LLM: %v", err)
+	}
+
+	var statuses []string
+	if err := model.PullModel(context.Background(), func(status OllamaPullStatus) {
+		statuses = append(statuses, status.Status)
+	}); err != nil {
+		t.Fatalf("Failed to pull model: %v", err)
+	}
+	if len(statuses) != 3 || statuses[2] != "success" {
+		t.Errorf("Unexpected pull statuses: %v", statuses)
+	}
+
+	failPull = true
+	if err := model.PullModel(context.Background(), nil); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("Expected ErrModelNotFound from failed pull, got %v", err)
+	}
+}
@@ -1,25 +1,79 @@
 package llm
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
 )
 
+// DefaultOpenAIBaseURL is the default base URL of the OpenAI API
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
 // OpenAILLM is the OpenAI implementation of the LLM interface
 type OpenAILLM struct {
-	// To be replaced with actual OpenAI client when dependency is added
-	// client      *openai.Client
-	apiKey       string
-	organization string
-	model        string
-	temperature  float32
-	maxTokens    int
+	client           *http.Client
+	apiKey           string
+	organization     string
+	baseURL          string // Usually "https://api.openai.com/v1", can point at compatible gateways
+	model            string
+	temperature      float32
+	maxTokens        int
+	timeoutSec       int     // Timeout in seconds for requests
+	topP             float32 // Top-p sampling parameter
+	presencePenalty  float32 // Presence penalty parameter
+	frequencyPenalty float32 // Frequency penalty parameter
+	embeddingModel   string  // Model of GenerateEmbeddings
 }
 
 // OpenAIOption is a function that configures an OpenAILLM
 type OpenAIOption func(*OpenAILLM)
 
+// OpenAIRequest represents a request to the OpenAI chat completions API
+type OpenAIRequest struct {
+	Model            string          `json:"model"`
+	Messages         []OpenAIMessage `json:"messages"`
+	Temperature      float32         `json:"temperature"`
+	MaxTokens        int             `json:"max_tokens,omitempty"`
+	TopP             float32         `json:"top_p,omitempty"`
+	FrequencyPenalty float32         `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float32         `json:"presence_penalty,omitempty"`
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"` // JSON output, see WithResponseSchema
+}
+
+// OpenAIChatResponse represents a response from the OpenAI chat completions API
+type OpenAIChatResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index        int     `json:"index"`
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// OpenAIErrorResponse represents an error payload returned by the OpenAI API
+type OpenAIErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
 // NewOpenAILLM creates a new OpenAI LLM with the specified options
 func NewOpenAILLM(apiKey string, options ...OpenAIOption) (*OpenAILLM, error) {
 	if apiKey == "" {
@@ -28,10 +82,17 @@ func NewOpenAILLM(apiKey string, options ...OpenAIOption) (*OpenAILLM, error) {
 
 	// Default values
 	llm := &OpenAILLM{
-		apiKey:      apiKey,
-		model:       "gpt-4o",
-		temperature: 0.7,
-		maxTokens:   1024,
+		client:           &http.Client{},
+		apiKey:           apiKey,
+		baseURL:          DefaultOpenAIBaseURL,
+		model:            "gpt-4o",
+		temperature:      0.7,
+		maxTokens:        1024,
+		timeoutSec:       60,
+		topP:             1.0,
+		presencePenalty:  0.0,
+		frequencyPenalty: 0.0,
+		embeddingModel:   "text-embedding-3-small",
 	}
 
 	// Apply options
@@ -39,11 +100,14 @@ func NewOpenAILLM(apiKey string, options ...OpenAIOption) (*OpenAILLM, error) {
 		option(llm)
 	}
 
-	// Initialize client
-	// llm.client = openai.NewClient(apiKey)
-	// if llm.organization != "" {
-	//    llm.client.Organization = llm.organization
-	// }
+	// Update client timeout based on timeoutSec setting
+	llm.client.Timeout = time.Duration(llm.timeoutSec) * time.Second
+
+	// Validate base URL
+	llm.baseURL = strings.TrimRight(llm.baseURL, "/")
+	if !strings.HasPrefix(llm.baseURL, "http") {
+		return nil, fmt.Errorf("invalid OpenAI base URL: %s, must start with http or https", llm.baseURL)
+	}
 
 	return llm, nil
 }
@@ -76,11 +140,69 @@ func WithOpenAIOrganization(org string) OpenAIOption {
 	}
 }
 
+// WithOpenAIBaseURL sets the base URL for the OpenAI API
+func WithOpenAIBaseURL(baseURL string) OpenAIOption {
+	return func(o *OpenAILLM) {
+		if baseURL != "" {
+			o.baseURL = baseURL
+		}
+	}
+}
+
+// WithOpenAITimeout sets the timeout for requests
+func WithOpenAITimeout(timeoutSec int) OpenAIOption {
+	return func(o *OpenAILLM) {
+		o.timeoutSec = timeoutSec
+	}
+}
+
+// WithOpenAITopP sets the top-p sampling parameter
+func WithOpenAITopP(topP float32) OpenAIOption {
+	return func(o *OpenAILLM) {
+		o.topP = topP
+	}
+}
+
+// WithOpenAIPresencePenalty sets the presence penalty
+func WithOpenAIPresencePenalty(penalty float32) OpenAIOption {
+	return func(o *OpenAILLM) {
+		o.presencePenalty = penalty
+	}
+}
+
+// WithOpenAIFrequencyPenalty sets the frequency penalty
+func WithOpenAIFrequencyPenalty(penalty float32) OpenAIOption {
+	return func(o *OpenAILLM) {
+		o.frequencyPenalty = penalty
+	}
+}
+
+// WithOpenAIEmbeddingModel sets the model of GenerateEmbeddings
+func WithOpenAIEmbeddingModel(model string) OpenAIOption {
+	return func(o *OpenAILLM) {
+		if model != "" {
+			o.embeddingModel = model
+		}
+	}
+}
+
+// Model returns the name of the model requests are sent to
+func (o *OpenAILLM) Model() string {
+	return o.model
+}
+
+// SupportsStructuredOutput reports that chat requests honor WithResponseSchema
+func (o *OpenAILLM) SupportsStructuredOutput() bool {
+	return true
+}
+
+// SupportsVision reports whether the model accepts images, see VisionModels
+func (o *OpenAILLM) SupportsVision() bool {
+	return isVisionModel(o.model)
+}
+
 // GenerateResponse generates a text response for a single prompt
 func (o *OpenAILLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
-	// This is a placeholder implementation
-	// When the OpenAI Go SDK is added as a dependency, this will be replaced with actual API calls
-
 	// Convert prompt to messages for the chat API
 	messages := []Message{
 		{Role: "user", Content: prompt},
@@ -91,8 +213,7 @@ func (o *OpenAILLM) GenerateResponse(ctx context.Context, prompt string) (string
 
 // GenerateChat generates a response based on a conversation history
 func (o *OpenAILLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
-	// This is a placeholder implementation
-	// When the OpenAI Go SDK is added as a dependency, this will be replaced with actual API calls
+	endpoint := fmt.Sprintf("%s/chat/completions", o.baseURL)
 
 	// Check for context cancellation
 	select {
@@ -102,13 +223,125 @@ func (o *OpenAILLM) GenerateChat(ctx context.Context, messages []Message) (strin
 		// Continue processing
 	}
 
-	// For now, we'll return a message indicating this is a placeholder
-	return fmt.Sprintf("[OpenAI %s] This is a placeholder response. The actual OpenAI integration requires adding the OpenAI Go SDK as a dependency.", o.model), nil
+	// Create request payload
+	request := OpenAIRequest{
+		Model:            o.model,
+		Messages:         toOpenAIMessages(messages, o.SupportsVision()),
+		Temperature:      o.temperature,
+		MaxTokens:        o.maxTokens,
+		TopP:             o.topP,
+		FrequencyPenalty: o.frequencyPenalty,
+		PresencePenalty:  o.presencePenalty,
+		ResponseFormat:   responseFormat(ctx),
+	}
+
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(requestJSON))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	if o.organization != "" {
+		req.Header.Set("OpenAI-Organization", o.organization)
+	}
+
+	// Send request
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request to OpenAI: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check for error status code
+	if resp.StatusCode != http.StatusOK {
+		return "", o.statusError(resp.StatusCode, body)
+	}
+
+	// Parse response
+	var response OpenAIChatResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Extract text from choices
+	if len(response.Choices) == 0 {
+		return "", ErrInvalidResponse
+	}
+
+	ReportUsage(ctx, Usage{
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+		TotalTokens:      response.Usage.TotalTokens,
+	})
+	return response.Choices[0].Message.Content, nil
+}
+
+// Ping lists the models of the API, checking that it is reachable and accepts the API key
+func (o *OpenAILLM) Ping(ctx context.Context) error {
+	headers := map[string]string{"Authorization": "Bearer " + o.apiKey}
+	if o.organization != "" {
+		headers["OpenAI-Organization"] = o.organization
+	}
+	return ping(ctx, o.client, "OpenAI", fmt.Sprintf("%s/models", o.baseURL), headers)
+}
+
+// GenerateEmbeddings returns the embedding of each text from the embeddings endpoint
+func (o *OpenAILLM) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	client := embeddingsClient{
+		client:   o.client,
+		endpoint: fmt.Sprintf("%s/embeddings", o.baseURL),
+		model:    o.embeddingModel,
+		provider: "OpenAI",
+		setHeaders: func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+o.apiKey)
+			if o.organization != "" {
+				req.Header.Set("OpenAI-Organization", o.organization)
+			}
+		},
+		statusError: o.statusError,
+	}
+	return client.generate(ctx, texts)
+}
+
+// statusError converts a non-200 response into an error wrapping the matching LLM error type
+func (o *OpenAILLM) statusError(status int, body []byte) error {
+	message := string(body)
+	var errResponse OpenAIErrorResponse
+	if err := json.Unmarshal(body, &errResponse); err == nil && errResponse.Error.Message != "" {
+		message = errResponse.Error.Message
+	}
+
+	switch {
+	case status == http.StatusUnauthorized:
+		return &StatusError{Provider: "OpenAI", StatusCode: status, Message: message, Err: ErrAPIKeyMissing}
+	case status == http.StatusTooManyRequests:
+		return &StatusError{Provider: "OpenAI", StatusCode: status, Message: message, Err: ErrRateLimited}
+	case status == http.StatusBadRequest && strings.Contains(message, "context_length"):
+		return &StatusError{Provider: "OpenAI", StatusCode: status, Message: message, Err: ErrContextTooLarge}
+	default:
+		return &StatusError{Provider: "OpenAI", StatusCode: status, Message: message, Err: ErrProviderError}
+	}
 }
 
-// Initialize the factory function
+// Register the provider
 func init() {
-	newOpenAIFromConfig = createOpenAIFromConfig
+	Register(ProviderOpenAI, createOpenAIFromConfig)
+	RegisterConfigLoader(ProviderOpenAI, func(s Settings) (ProviderConfig, error) { return openAISettings(s) })
 }
 
 // Implementation for creating an OpenAILLM from config
@@ -135,6 +368,7 @@ func createOpenAIFromConfig(config ProviderConfig) (LanguageModel, error) {
 			WithOpenAIModel(baseConfig.Model),
 			WithOpenAITemperature(baseConfig.Temperature),
 			WithOpenAIMaxTokens(baseConfig.MaxTokens),
+			WithOpenAIBaseURL(getEnvWithDefault("OPENAI_BASE_URL", DefaultOpenAIBaseURL)),
 		)
 	}
 
@@ -143,6 +377,8 @@ func createOpenAIFromConfig(config ProviderConfig) (LanguageModel, error) {
 		WithOpenAIModel(openAIConfig.Model),
 		WithOpenAITemperature(openAIConfig.Temperature),
 		WithOpenAIMaxTokens(openAIConfig.MaxTokens),
+		WithOpenAIBaseURL(openAIConfig.BaseURL),
+		WithOpenAIEmbeddingModel(openAIConfig.EmbeddingModel),
 	}
 
 	if openAIConfig.Organization != "" {
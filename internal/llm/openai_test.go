@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIGenerateChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Expected bearer auth header, got %q", got)
+		}
+		if got := r.Header.Get("OpenAI-Organization"); got != "test-org" {
+			t.Errorf("Expected organization header, got %q", got)
+		}
+
+		var request OpenAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if request.Model != "gpt-test" || request.PresencePenalty != 0.5 || request.MaxTokens != 42 {
+			t.Errorf("Unexpected request: %+v", request)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi there"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	model, err := NewOpenAILLM("test-key",
+		WithOpenAIBaseURL(server.URL+"/"),
+		WithOpenAIModel("gpt-test"),
+		WithOpenAIMaxTokens(42),
+		WithOpenAIPresencePenalty(0.5),
+		WithOpenAIOrganization("test-org"),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create OpenAI LLM: %v", err)
+	}
+
+	response, err := model.GenerateResponse(context.Background(), "Hello")
+	if err != nil {
+		t.Fatalf("Failed to generate response: %v", err)
+	}
+	if response != "Hi there" {
+		t.Errorf("Expected %q, got %q", "Hi there", response)
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	status := http.StatusTooManyRequests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"error":{"message":"slow down","type":"rate_limit"}}`))
+	}))
+	defer server.Close()
+
+	model, err := NewOpenAILLM("test-key", WithOpenAIBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create OpenAI LLM: %v", err)
+	}
+
+	_, err = model.GenerateResponse(context.Background(), "Hello")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+
+	status = http.StatusInternalServerError
+	_, err = model.GenerateResponse(context.Background(), "Hello")
+	if !errors.Is(err, ErrProviderError) {
+		t.Errorf("Expected ErrProviderError, got %v", err)
+	}
+
+	if _, err := NewOpenAILLM(""); !errors.Is(err, ErrAPIKeyMissing) {
+		t.Errorf("Expected ErrAPIKeyMissing for empty key, got %v", err)
+	}
+}
+
+func TestOpenAIFromConfig(t *testing.T) {
+	config := &OpenAIConfig{
+		BaseConfig: BaseConfig{Provider: ProviderOpenAI, Model: "gpt-4o-mini", Temperature: 0.2, MaxTokens: 10},
+		APIKey:     "test-key",
+		BaseURL:    "http://localhost:9999/v1",
+	}
+
+	model, err := NewLLM(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Failed to create LLM from config: %v", err)
+	}
+
+	openAI, ok := model.(*OpenAILLM)
+	if !ok {
+		t.Fatalf("Expected *OpenAILLM, got %T", model)
+	}
+	if openAI.baseURL != "http://localhost:9999/v1" || openAI.model != "gpt-4o-mini" {
+		t.Errorf("Config not applied: baseURL=%s model=%s", openAI.baseURL, openAI.model)
+	}
+}
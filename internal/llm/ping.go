@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Pinger is an optional interface for language models that can check that their provider
+// is reachable and accepts their credentials, without generating anything
+type Pinger interface {
+	// Ping returns an error if the provider cannot be reached or refuses the request
+	Ping(ctx context.Context) error
+}
+
+// PingerFrom returns the pinger of a language model, or of the model it decorates
+func PingerFrom(model LanguageModel) (Pinger, bool) {
+	for model != nil {
+		if pinger, ok := model.(Pinger); ok {
+			return pinger, true
+		}
+		wrapper, ok := model.(interface{ Unwrap() LanguageModel })
+		if !ok {
+			return nil, false
+		}
+		model = wrapper.Unwrap()
+	}
+	return nil, false
+}
+
+// ping lists the models of a provider, which needs no tokens, and returns an error unless
+// the provider answers with success
+func ping(ctx context.Context, client *http.Client, provider, endpoint string, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered with status %d", provider, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	status := http.StatusOK
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/v1/models" && r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("Expected the API key, got %q", r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	openAI, err := NewOpenAILLM("key", WithOpenAIBaseURL(server.URL+"/v1"))
+	if err != nil {
+		t.Fatalf("Failed to create OpenAI LLM: %v", err)
+	}
+	pinger, ok := PingerFrom(NewRetryingLLM(openAI))
+	if !ok {
+		t.Fatal("Expected the pinger of the decorated model")
+	}
+	if err := pinger.Ping(context.Background()); err != nil {
+		t.Errorf("Expected the ping to succeed, got %v", err)
+	}
+
+	status = http.StatusUnauthorized
+	if err := pinger.Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected the status in the error, got %v", err)
+	}
+	if len(paths) != 2 || paths[0] != "/v1/models" {
+		t.Errorf("Expected the models to be listed, got %v", paths)
+	}
+
+	if _, ok := PingerFrom(NewEchoLLM(0)); ok {
+		t.Error("Expected the echo model to have no pinger")
+	}
+}
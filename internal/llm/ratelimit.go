@@ -0,0 +1,294 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"goproduct/internal/logging"
+	"goproduct/internal/tracing"
+)
+
+// ErrRateLimitExceeded is returned by a RateLimitedLLM rejecting a call over its budget
+var ErrRateLimitExceeded = errors.New("rate limit exceeded")
+
+// RateLimitedLLM is a LanguageModel decorator enforcing requests-per-minute and
+// tokens-per-minute budgets with token buckets, so that a runaway loop cannot hammer a
+// paid API or overload a local server. Calls over the budget wait for it, up to a maximum
+// wait, or are rejected with ErrRateLimitExceeded. A call is charged its estimated prompt
+// tokens up front and its completion tokens once it returns.
+type RateLimitedLLM struct {
+	model    LanguageModel
+	requests *tokenBucket // Nil for no request budget
+	tokens   *tokenBucket // Nil for no token budget
+	reject   bool         // Reject instead of waiting
+	maxWait  time.Duration
+	tracer   tracing.Tracer
+	logger   *logging.Logger
+	mu       sync.Mutex
+	now      func() time.Time                                 // Replaceable for tests
+	sleep    func(ctx context.Context, d time.Duration) error // Replaceable for tests
+}
+
+// RateLimitOption is a function that configures a RateLimitedLLM
+type RateLimitOption func(*RateLimitedLLM)
+
+// NewRateLimitedLLM wraps a language model with rate limits; without options it is not limited
+func NewRateLimitedLLM(model LanguageModel, options ...RateLimitOption) *RateLimitedLLM {
+	r := &RateLimitedLLM{
+		model:   model,
+		maxWait: time.Minute,
+		tracer:  tracing.NewNoopTracer(),
+		logger:  logging.Get().Component("llm"),
+		now:     time.Now,
+		sleep:   sleepContext,
+	}
+
+	// Apply options
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// WithRequestsPerMinute limits the calls per minute; bursts of up to that many calls pass at once
+func WithRequestsPerMinute(requests int) RateLimitOption {
+	return func(r *RateLimitedLLM) {
+		if requests > 0 {
+			r.requests = newTokenBucket(float64(requests), time.Minute)
+		}
+	}
+}
+
+// WithTokensPerMinute limits the prompt and completion tokens per minute
+func WithTokensPerMinute(tokens int) RateLimitOption {
+	return func(r *RateLimitedLLM) {
+		if tokens > 0 {
+			r.tokens = newTokenBucket(float64(tokens), time.Minute)
+		}
+	}
+}
+
+// WithRateLimitReject rejects calls over the budget instead of queueing them
+func WithRateLimitReject(reject bool) RateLimitOption {
+	return func(r *RateLimitedLLM) {
+		r.reject = reject
+	}
+}
+
+// WithRateLimitMaxWait sets how long a queued call may wait before it is rejected
+func WithRateLimitMaxWait(wait time.Duration) RateLimitOption {
+	return func(r *RateLimitedLLM) {
+		if wait > 0 {
+			r.maxWait = wait
+		}
+	}
+}
+
+// WithRateLimitTracer sets the tracer receiving an event per delayed or rejected call
+func WithRateLimitTracer(tracer tracing.Tracer) RateLimitOption {
+	return func(r *RateLimitedLLM) {
+		if tracer != nil {
+			r.tracer = tracer
+		}
+	}
+}
+
+// GenerateResponse generates a text response for a single prompt within the budgets
+func (r *RateLimitedLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return r.do(ctx, "GenerateResponse", []Message{{Role: "user", Content: prompt}}, func() (string, error) {
+		return r.model.GenerateResponse(ctx, prompt)
+	})
+}
+
+// GenerateChat generates a response based on a conversation history within the budgets
+func (r *RateLimitedLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
+	return r.do(ctx, "GenerateChat", messages, func() (string, error) {
+		return r.model.GenerateChat(ctx, messages)
+	})
+}
+
+// StreamResponse streams a response for a single prompt within the budgets
+func (r *RateLimitedLLM) StreamResponse(ctx context.Context, prompt string) (chan StreamToken, error) {
+	return r.stream(ctx, "StreamResponse", []Message{{Role: "user", Content: prompt}}, func() (chan StreamToken, error) {
+		return streamResponse(ctx, r.model, prompt)
+	})
+}
+
+// StreamChat streams a response based on a conversation history within the budgets
+func (r *RateLimitedLLM) StreamChat(ctx context.Context, messages []Message) (chan StreamToken, error) {
+	return r.stream(ctx, "StreamChat", messages, func() (chan StreamToken, error) {
+		return streamChat(ctx, r.model, messages)
+	})
+}
+
+// Unwrap returns the wrapped language model
+func (r *RateLimitedLLM) Unwrap() LanguageModel {
+	return r.model
+}
+
+// do waits for the budgets, runs call and charges its completion tokens
+func (r *RateLimitedLLM) do(ctx context.Context, method string, messages []Message, call func() (string, error)) (string, error) {
+	tokenizer, err := r.admit(ctx, method, messages)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := call()
+	if err == nil {
+		r.charge(float64(tokenizer.CountTokens(response)))
+	}
+	return response, err
+}
+
+// stream waits for the budgets, opens a stream and charges its completion tokens once
+// the stream is done
+func (r *RateLimitedLLM) stream(ctx context.Context, method string, messages []Message, open func() (chan StreamToken, error)) (chan StreamToken, error) {
+	tokenizer, err := r.admit(ctx, method, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := open()
+	if err != nil {
+		return nil, err
+	}
+	return relay(ctx, nil, tokens, func(response string, err error) {
+		if err == nil {
+			r.charge(float64(tokenizer.CountTokens(response)))
+		}
+	}), nil
+}
+
+// admit waits for the budgets of a call, returning the tokenizer its completion tokens
+// are counted with
+func (r *RateLimitedLLM) admit(ctx context.Context, method string, messages []Message) (Tokenizer, error) {
+	tokenizer := TokenizerFor(ModelName(r.model))
+	promptTokens := float64(CountMessageTokens(tokenizer, messages))
+
+	wait, err := r.reserve(promptTokens)
+	if err != nil {
+		r.trace(ctx, tracing.LevelWarning, method, wait, err)
+		return nil, err
+	}
+	if wait > 0 {
+		r.logger.Debug("LLM call delayed by rate limit", "method", method, "delay", wait)
+		r.trace(ctx, tracing.LevelDebug, method, wait, nil)
+		if err := r.sleep(ctx, wait); err != nil {
+			r.refund(promptTokens)
+			return nil, err
+		}
+	}
+	return tokenizer, nil
+}
+
+// reserve takes a request and the prompt tokens from the buckets and returns how long
+// the call has to wait for them; rejected calls take nothing
+func (r *RateLimitedLLM) reserve(promptTokens float64) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	var wait time.Duration
+	if r.requests != nil {
+		wait = max(wait, r.requests.waitFor(now, 1))
+	}
+	if r.tokens != nil {
+		wait = max(wait, r.tokens.waitFor(now, promptTokens))
+	}
+	if wait > 0 && (r.reject || wait > r.maxWait) {
+		return wait, fmt.Errorf("%w: budget available in %s", ErrRateLimitExceeded, wait.Round(time.Millisecond))
+	}
+	if r.requests != nil {
+		r.requests.take(1)
+	}
+	if r.tokens != nil {
+		r.tokens.take(promptTokens)
+	}
+	return wait, nil
+}
+
+// refund returns the reservation of a call that gave up waiting
+func (r *RateLimitedLLM) refund(promptTokens float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.requests != nil {
+		r.requests.take(-1)
+	}
+	if r.tokens != nil {
+		r.tokens.take(-promptTokens)
+	}
+}
+
+// charge takes the completion tokens of a call, delaying the calls after it
+func (r *RateLimitedLLM) charge(completionTokens float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tokens != nil {
+		r.tokens.refill(r.now())
+		r.tokens.take(completionTokens)
+	}
+}
+
+// trace records a trace event for a delayed or rejected call
+func (r *RateLimitedLLM) trace(ctx context.Context, level tracing.Level, method string, wait time.Duration, err error) {
+	metadata := map[string]interface{}{
+		"method":  method,
+		"wait_ms": wait.Milliseconds(),
+	}
+	message := fmt.Sprintf("%s delayed %s by rate limit", method, wait.Round(time.Millisecond))
+	if err != nil {
+		metadata["error"] = err.Error()
+		message = fmt.Sprintf("%s rejected: %v", method, err)
+	}
+
+	r.tracer.Trace(tracing.Event{
+		Timestamp: r.now(),
+		Component: tracing.ComponentLLM,
+		Operation: tracing.OperationGenerate,
+		Level:     level,
+		Message:   message,
+		Metadata:  tracing.Correlate(ctx, metadata),
+	})
+}
+
+// tokenBucket holds up to capacity tokens, refilled at capacity per period. The level
+// may go negative when a call is charged more than is left; later calls wait for it.
+type tokenBucket struct {
+	capacity float64
+	rate     float64 // Tokens per second
+	level    float64
+	last     time.Time
+}
+
+// newTokenBucket creates a full bucket
+func newTokenBucket(capacity float64, period time.Duration) *tokenBucket {
+	return &tokenBucket{capacity: capacity, rate: capacity / period.Seconds(), level: capacity}
+}
+
+// refill adds the tokens accrued since the last refill
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() && now.After(b.last) {
+		b.level = math.Min(b.capacity, b.level+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// waitFor refills the bucket and returns how long it takes until n tokens are available.
+// More than the capacity is never available, so n is capped at it.
+func (b *tokenBucket) waitFor(now time.Time, n float64) time.Duration {
+	b.refill(now)
+	n = math.Min(n, b.capacity)
+	if b.level >= n {
+		return 0
+	}
+	return time.Duration((n - b.level) / b.rate * float64(time.Second))
+}
+
+// take removes n tokens; negative n puts them back
+func (b *tokenBucket) take(n float64) {
+	b.level = math.Min(b.capacity, b.level-n)
+}
@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock advanced by the sleeps of a RateLimitedLLM
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+func newLimitedForTest(model LanguageModel, options ...RateLimitOption) (*RateLimitedLLM, *fakeClock) {
+	clock := &fakeClock{now: time.Now()}
+	limited := NewRateLimitedLLM(model, options...)
+	limited.now = clock.Now
+	limited.sleep = clock.Sleep
+	return limited, clock
+}
+
+func TestRateLimitedLLM_QueuesOverRequestBudget(t *testing.T) {
+	model, clock := newLimitedForTest(NewEchoLLM(0), WithRequestsPerMinute(2))
+
+	for i := 0; i < 3; i++ {
+		if _, err := model.GenerateResponse(context.Background(), "Hi"); err != nil {
+			t.Fatalf("Call %d failed: %v", i, err)
+		}
+	}
+	if len(clock.sleeps) != 1 || clock.sleeps[0] != 30*time.Second {
+		t.Errorf("Expected the third call to wait 30s, got %v", clock.sleeps)
+	}
+}
+
+func TestRateLimitedLLM_RejectsOverBudget(t *testing.T) {
+	inner := &countingLLM{}
+	model, clock := newLimitedForTest(inner, WithRequestsPerMinute(1), WithRateLimitReject(true))
+
+	model.GenerateResponse(context.Background(), "Hi")
+	if _, err := model.GenerateResponse(context.Background(), "Hi"); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Errorf("Expected ErrRateLimitExceeded, got %v", err)
+	}
+	if inner.calls != 1 {
+		t.Errorf("Expected the rejected call not to reach the model, got %d calls", inner.calls)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if _, err := model.GenerateResponse(context.Background(), "Hi"); err != nil {
+		t.Errorf("Expected the budget to refill, got %v", err)
+	}
+}
+
+func TestRateLimitedLLM_TokenBudget(t *testing.T) {
+	model, clock := newLimitedForTest(NewEchoLLM(0), WithTokensPerMinute(60), WithRateLimitMaxWait(10*time.Minute))
+
+	// The first call and its echoed completion overdraw the budget
+	long := "word word word word word word word word word word word word word word word word word word word word"
+	model.GenerateResponse(context.Background(), long)
+	model.GenerateResponse(context.Background(), "Hi")
+	if len(clock.sleeps) != 1 || clock.sleeps[0] <= 0 {
+		t.Errorf("Expected the second call to wait for tokens, got %v", clock.sleeps)
+	}
+}
+
+func TestRateLimitedLLM_MaxWait(t *testing.T) {
+	model, _ := newLimitedForTest(NewEchoLLM(0), WithRequestsPerMinute(1), WithRateLimitMaxWait(time.Second))
+
+	model.GenerateResponse(context.Background(), "Hi")
+	if _, err := model.GenerateResponse(context.Background(), "Hi"); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Errorf("Expected a call waiting past the maximum to be rejected, got %v", err)
+	}
+}
+
+func TestRateLimitedLLM_Unlimited(t *testing.T) {
+	model, clock := newLimitedForTest(NewEchoLLM(0))
+	for i := 0; i < 100; i++ {
+		model.GenerateResponse(context.Background(), "Hi")
+	}
+	if len(clock.sleeps) != 0 {
+		t.Errorf("Expected no waits without limits, got %d", len(clock.sleeps))
+	}
+}
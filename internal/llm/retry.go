@@ -0,0 +1,314 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"goproduct/internal/logging"
+	"goproduct/internal/tracing"
+)
+
+// RetryingLLM is a LanguageModel decorator that retries transient failures
+// (timeouts, rate limits, 5xx responses and dropped connections) with exponential backoff
+type RetryingLLM struct {
+	model          LanguageModel
+	maxAttempts    int           // Total attempts including the first one
+	initialBackoff time.Duration // Delay before the first retry
+	maxBackoff     time.Duration // Upper bound for a single delay
+	multiplier     float64       // Backoff growth factor per attempt
+	jitter         float64       // Fraction of the delay randomized, 0 to 1
+	tracer         tracing.Tracer
+	logger         *logging.Logger
+	randMu         sync.Mutex
+	rand           *rand.Rand
+	sleep          func(ctx context.Context, d time.Duration) error // Replaceable for tests
+}
+
+// RetryOption is a function that configures a RetryingLLM
+type RetryOption func(*RetryingLLM)
+
+// NewRetryingLLM wraps a language model with retry and backoff behavior
+func NewRetryingLLM(model LanguageModel, options ...RetryOption) *RetryingLLM {
+	r := &RetryingLLM{
+		model:          model,
+		maxAttempts:    3,
+		initialBackoff: 500 * time.Millisecond,
+		maxBackoff:     10 * time.Second,
+		multiplier:     2.0,
+		jitter:         0.2,
+		tracer:         tracing.NewNoopTracer(),
+		logger:         logging.Get().Component("llm"),
+		rand:           rand.New(rand.NewSource(time.Now().UnixNano())),
+		sleep:          sleepContext,
+	}
+
+	// Apply options
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// WithRetryMaxAttempts sets the total number of attempts, including the first one
+func WithRetryMaxAttempts(attempts int) RetryOption {
+	return func(r *RetryingLLM) {
+		if attempts > 0 {
+			r.maxAttempts = attempts
+		}
+	}
+}
+
+// WithRetryBackoff sets the initial and maximum delay between attempts
+func WithRetryBackoff(initial, max time.Duration) RetryOption {
+	return func(r *RetryingLLM) {
+		if initial > 0 {
+			r.initialBackoff = initial
+		}
+		if max > 0 {
+			r.maxBackoff = max
+		}
+	}
+}
+
+// WithRetryMultiplier sets the factor the delay grows by after each attempt
+func WithRetryMultiplier(multiplier float64) RetryOption {
+	return func(r *RetryingLLM) {
+		if multiplier >= 1 {
+			r.multiplier = multiplier
+		}
+	}
+}
+
+// WithRetryJitter sets the fraction of each delay that is randomized (0 disables jitter)
+func WithRetryJitter(jitter float64) RetryOption {
+	return func(r *RetryingLLM) {
+		if jitter >= 0 && jitter <= 1 {
+			r.jitter = jitter
+		}
+	}
+}
+
+// WithRetryTracer sets the tracer receiving an event per attempt
+func WithRetryTracer(tracer tracing.Tracer) RetryOption {
+	return func(r *RetryingLLM) {
+		if tracer != nil {
+			r.tracer = tracer
+		}
+	}
+}
+
+// GenerateResponse generates a text response for a single prompt, retrying transient failures
+func (r *RetryingLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return r.do(ctx, "GenerateResponse", func(ctx context.Context) (string, error) {
+		return r.model.GenerateResponse(ctx, prompt)
+	})
+}
+
+// GenerateChat generates a response based on a conversation history, retrying transient failures
+func (r *RetryingLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
+	return r.do(ctx, "GenerateChat", func(ctx context.Context) (string, error) {
+		return r.model.GenerateChat(ctx, messages)
+	})
+}
+
+// StreamResponse streams a response for a single prompt, retrying transient failures
+// until its first token arrives
+func (r *RetryingLLM) StreamResponse(ctx context.Context, prompt string) (chan StreamToken, error) {
+	return r.stream(ctx, "StreamResponse", func(ctx context.Context) (chan StreamToken, error) {
+		return streamResponse(ctx, r.model, prompt)
+	})
+}
+
+// StreamChat streams a response based on a conversation history, retrying transient
+// failures until its first token arrives
+func (r *RetryingLLM) StreamChat(ctx context.Context, messages []Message) (chan StreamToken, error) {
+	return r.stream(ctx, "StreamChat", func(ctx context.Context) (chan StreamToken, error) {
+		return streamChat(ctx, r.model, messages)
+	})
+}
+
+// Unwrap returns the wrapped language model
+func (r *RetryingLLM) Unwrap() LanguageModel {
+	return r.model
+}
+
+// do runs call until it succeeds, fails permanently or the attempt budget is spent
+func (r *RetryingLLM) do(ctx context.Context, method string, call func(ctx context.Context) (string, error)) (string, error) {
+	var lastErr error
+
+	for attempt := 1; attempt <= r.maxAttempts; attempt++ {
+		r.traceAttempt(ctx, tracing.OperationGenerate, tracing.LevelDebug, method, attempt, 0, nil)
+
+		response, err := call(ctx)
+		if err == nil {
+			return response, nil
+		}
+		lastErr = err
+
+		// Stop if the caller gave up or the failure is not worth retrying
+		if ctx.Err() != nil || !IsTransient(err) {
+			r.traceAttempt(ctx, tracing.OperationGenerate, tracing.LevelError, method, attempt, 0, err)
+			return "", err
+		}
+		if attempt == r.maxAttempts {
+			break
+		}
+
+		delay := r.backoff(attempt)
+		r.logger.Warn("Transient LLM failure, retrying",
+			"method", method,
+			"attempt", attempt,
+			"max_attempts", r.maxAttempts,
+			"delay", delay,
+			"error", err)
+		r.traceAttempt(ctx, tracing.OperationRetry, tracing.LevelWarning, method, attempt, delay, err)
+
+		if err := r.sleep(ctx, delay); err != nil {
+			return "", err
+		}
+	}
+
+	r.traceAttempt(ctx, tracing.OperationGenerate, tracing.LevelError, method, r.maxAttempts, 0, lastErr)
+	return "", fmt.Errorf("giving up after %d attempts: %w", r.maxAttempts, lastErr)
+}
+
+// stream opens a stream and waits for its first token, retrying like do. A stream that
+// fails once its text has started to arrive is not retried, the failure being the last
+// token of the stream.
+func (r *RetryingLLM) stream(ctx context.Context, method string, open func(ctx context.Context) (chan StreamToken, error)) (chan StreamToken, error) {
+	var (
+		tokens chan StreamToken
+		first  StreamToken
+	)
+	_, err := r.do(ctx, method, func(ctx context.Context) (string, error) {
+		var err error
+		if tokens, err = open(ctx); err != nil {
+			return "", err
+		}
+		select {
+		case token, ok := <-tokens:
+			if !ok {
+				token = StreamToken{Done: true}
+			}
+			if token.Error != nil {
+				go drain(tokens)
+				return "", token.Error
+			}
+			first = token
+			return "", nil
+		case <-ctx.Done():
+			go drain(tokens)
+			return "", ctx.Err()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return relay(ctx, []StreamToken{first}, tokens, nil), nil
+}
+
+// backoff returns the delay before the next attempt, with exponential growth and jitter
+func (r *RetryingLLM) backoff(attempt int) time.Duration {
+	delay := float64(r.initialBackoff) * math.Pow(r.multiplier, float64(attempt-1))
+	if delay > float64(r.maxBackoff) {
+		delay = float64(r.maxBackoff)
+	}
+
+	if r.jitter > 0 {
+		r.randMu.Lock()
+		// Spread the delay uniformly over [delay*(1-jitter), delay*(1+jitter)]
+		delay = delay * (1 - r.jitter + 2*r.jitter*r.rand.Float64())
+		r.randMu.Unlock()
+	}
+
+	return time.Duration(delay)
+}
+
+// traceAttempt records a trace event for an attempt
+func (r *RetryingLLM) traceAttempt(ctx context.Context, op tracing.Operation, level tracing.Level, method string, attempt int, delay time.Duration, err error) {
+	metadata := map[string]interface{}{
+		"method":       method,
+		"attempt":      attempt,
+		"max_attempts": r.maxAttempts,
+	}
+	message := fmt.Sprintf("%s attempt %d/%d", method, attempt, r.maxAttempts)
+	if delay > 0 {
+		metadata["delay_ms"] = delay.Milliseconds()
+		message = fmt.Sprintf("%s, retrying in %s", message, delay)
+	}
+	if err != nil {
+		metadata["error"] = err.Error()
+		message = fmt.Sprintf("%s failed: %v", message, err)
+	}
+
+	r.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentLLM,
+		Operation: op,
+		Level:     level,
+		Message:   message,
+		Metadata:  tracing.Correlate(ctx, metadata),
+	})
+}
+
+// IsTransient reports whether an LLM error is likely to succeed on retry:
+// rate limits, 408/5xx responses, timeouts and dropped or refused connections
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// Cancellation by the caller is never transient
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusRequestTimeout ||
+			statusErr.StatusCode == http.StatusTooManyRequests ||
+			statusErr.StatusCode >= 500
+	}
+
+	// Per-request timeouts, e.g. from the HTTP client
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// Connection blips
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// sleepContext waits for the duration or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
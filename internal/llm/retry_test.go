@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"goproduct/internal/tracing"
+)
+
+// flakyLLM fails with the given errors before succeeding
+type flakyLLM struct {
+	errs  []error
+	calls int
+}
+
+func (f *flakyLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return f.GenerateChat(ctx, []Message{{Role: "user", Content: prompt}})
+}
+
+func (f *flakyLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return "", f.errs[f.calls-1]
+	}
+	return "ok", nil
+}
+
+// noSleep records the requested delays without waiting
+func noSleep(delays *[]time.Duration) func(ctx context.Context, d time.Duration) error {
+	return func(ctx context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return nil
+	}
+}
+
+func TestRetryingLLM_RetriesTransientFailures(t *testing.T) {
+	inner := &flakyLLM{errs: []error{
+		&StatusError{Provider: "Test", StatusCode: 503, Message: "unavailable", Err: ErrProviderError},
+		&StatusError{Provider: "Test", StatusCode: 429, Message: "slow down", Err: ErrRateLimited},
+	}}
+
+	var buf strings.Builder
+	var delays []time.Duration
+	model := NewRetryingLLM(inner,
+		WithRetryMaxAttempts(3),
+		WithRetryBackoff(100*time.Millisecond, time.Second),
+		WithRetryJitter(0),
+		WithRetryTracer(tracing.NewWriterTracer(&buf, tracing.LevelVerbose)),
+	)
+	model.sleep = noSleep(&delays)
+
+	response, err := model.GenerateResponse(context.Background(), "Hi")
+	if err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if response != "ok" || inner.calls != 3 {
+		t.Errorf("Expected ok after 3 calls, got %q after %d calls", response, inner.calls)
+	}
+	if len(delays) != 2 || delays[0] != 100*time.Millisecond || delays[1] != 200*time.Millisecond {
+		t.Errorf("Expected exponential backoff of 100ms then 200ms, got %v", delays)
+	}
+	if got := strings.Count(buf.String(), "attempt"); got < 5 {
+		t.Errorf("Expected trace events per attempt, got %d:\n%s", got, buf.String())
+	}
+}
+
+func TestRetryingLLM_DoesNotRetryPermanentFailures(t *testing.T) {
+	inner := &flakyLLM{errs: []error{
+		&StatusError{Provider: "Test", StatusCode: 401, Message: "bad key", Err: ErrAPIKeyMissing},
+	}}
+
+	var delays []time.Duration
+	model := NewRetryingLLM(inner)
+	model.sleep = noSleep(&delays)
+
+	_, err := model.GenerateChat(context.Background(), []Message{{Role: "user", Content: "Hi"}})
+	if !errors.Is(err, ErrAPIKeyMissing) {
+		t.Errorf("Expected ErrAPIKeyMissing, got %v", err)
+	}
+	if inner.calls != 1 || len(delays) != 0 {
+		t.Errorf("Expected a single attempt, got %d calls and delays %v", inner.calls, delays)
+	}
+}
+
+func TestRetryingLLM_GivesUpAfterBudget(t *testing.T) {
+	transient := &StatusError{Provider: "Test", StatusCode: 500, Message: "boom", Err: ErrProviderError}
+	inner := &flakyLLM{errs: []error{transient, transient, transient, transient}}
+
+	var delays []time.Duration
+	model := NewRetryingLLM(inner, WithRetryMaxAttempts(2))
+	model.sleep = noSleep(&delays)
+
+	_, err := model.GenerateResponse(context.Background(), "Hi")
+	if !errors.Is(err, ErrProviderError) {
+		t.Errorf("Expected wrapped ErrProviderError, got %v", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("Expected 2 attempts, got %d", inner.calls)
+	}
+}
+
+func TestRetryingLLM_BackoffCapAndJitter(t *testing.T) {
+	model := NewRetryingLLM(&flakyLLM{},
+		WithRetryBackoff(time.Second, 3*time.Second),
+		WithRetryMultiplier(4),
+		WithRetryJitter(0.5),
+	)
+
+	for attempt := 1; attempt <= 5; attempt++ {
+		delay := model.backoff(attempt)
+		if delay < 500*time.Millisecond || delay > 4500*time.Millisecond {
+			t.Errorf("Attempt %d: delay %s outside jittered bounds", attempt, delay)
+		}
+	}
+}
+
+func TestRetryingLLM_WithHTTPProvider(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"error":{"message":"upstream hiccup"}}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"recovered"}}]}`))
+	}))
+	defer server.Close()
+
+	inner, err := NewOpenAILLM("test-key", WithOpenAIBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create OpenAI LLM: %v", err)
+	}
+	model := NewRetryingLLM(inner, WithRetryBackoff(time.Millisecond, time.Millisecond))
+
+	response, err := model.GenerateResponse(context.Background(), "Hi")
+	if err != nil {
+		t.Fatalf("Expected recovery after 502, got %v", err)
+	}
+	if response != "recovered" || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("Expected recovered response after 2 calls, got %q after %d", response, calls)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("boom"), false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, true},
+		{ErrRateLimited, true},
+		{&StatusError{StatusCode: 400, Err: ErrProviderError}, false},
+		{&StatusError{StatusCode: 408, Err: ErrProviderError}, true},
+		{&StatusError{StatusCode: 502, Err: ErrProviderError}, true},
+	}
+
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"context"
+	"strings"
+)
+
+// streamChat streams the model's response to a conversation, natively when the model
+// implements StreamingLLM and otherwise as the single token of a GenerateChat call
+func streamChat(ctx context.Context, model LanguageModel, messages []Message) (chan StreamToken, error) {
+	if streamer, ok := model.(StreamingLLM); ok {
+		return streamer.StreamChat(ctx, messages)
+	}
+	response, err := model.GenerateChat(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	return singleToken(response), nil
+}
+
+// streamResponse streams the model's response to a prompt, natively when the model
+// implements StreamingLLM and otherwise as the single token of a GenerateResponse call
+func streamResponse(ctx context.Context, model LanguageModel, prompt string) (chan StreamToken, error) {
+	if streamer, ok := model.(StreamingLLM); ok {
+		return streamer.StreamResponse(ctx, prompt)
+	}
+	response, err := model.GenerateResponse(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return singleToken(response), nil
+}
+
+// singleToken returns a closed stream holding the whole response
+func singleToken(response string) chan StreamToken {
+	tokens := make(chan StreamToken, 2)
+	tokens <- StreamToken{Text: response}
+	tokens <- StreamToken{Done: true}
+	close(tokens)
+	return tokens
+}
+
+// relay forwards the head tokens and then those of the stream to a new stream, which
+// decorators use to see the response go by. end, if set, is called once with the text of
+// the response when the stream is done, fails or is abandoned by the reader, in which
+// case the error is that of the context. It is called before the last token is
+// forwarded, so that whatever it records is in place when the reader has the response.
+func relay(ctx context.Context, head []StreamToken, tokens chan StreamToken, end func(response string, err error)) chan StreamToken {
+	out := make(chan StreamToken)
+	go func() {
+		defer close(out)
+		var sb strings.Builder
+		finish := func(err error) {
+			if end != nil {
+				end(sb.String(), err)
+			}
+		}
+		send := func(token StreamToken) bool {
+			select {
+			case out <- token:
+				return true
+			case <-ctx.Done():
+				// Let the producer finish rather than block on a stream nobody reads
+				go drain(tokens)
+				return false
+			}
+		}
+
+		next := func() (StreamToken, bool) {
+			if len(head) > 0 {
+				token := head[0]
+				head = head[1:]
+				return token, true
+			}
+			token, ok := <-tokens
+			return token, ok
+		}
+		for {
+			token, ok := next()
+			if !ok {
+				// The stream may have been closed because the context was cancelled
+				finish(ctx.Err())
+				return
+			}
+			sb.WriteString(token.Text)
+			switch {
+			case token.Error != nil:
+				finish(token.Error)
+				send(token)
+				return
+			case token.Done:
+				finish(nil)
+				send(token)
+				return
+			}
+			if !send(token) {
+				finish(ctx.Err())
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// drain reads a stream to its end
+func drain(tokens chan StreamToken) {
+	for range tokens {
+	}
+}
@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tokenLLM streams its reply word by word, after failing to open the stream with the
+// given errors or, if midStream, after the first word
+type tokenLLM struct {
+	reply     string
+	errs      []error
+	midStream bool
+	streams   int
+}
+
+func (l *tokenLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return l.reply, nil
+}
+
+func (l *tokenLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
+	return l.reply, nil
+}
+
+func (l *tokenLLM) StreamResponse(ctx context.Context, prompt string) (chan StreamToken, error) {
+	return l.StreamChat(ctx, []Message{{Role: "user", Content: prompt}})
+}
+
+func (l *tokenLLM) StreamChat(ctx context.Context, messages []Message) (chan StreamToken, error) {
+	l.streams++
+	var failure error
+	if l.streams <= len(l.errs) {
+		if !l.midStream {
+			return nil, l.errs[l.streams-1]
+		}
+		failure = l.errs[l.streams-1]
+	}
+	tokens := make(chan StreamToken)
+	go func() {
+		defer close(tokens)
+		for i, word := range strings.SplitAfter(l.reply, " ") {
+			if failure != nil && i == 1 {
+				tokens <- StreamToken{Error: failure}
+				return
+			}
+			select {
+			case tokens <- StreamToken{Text: word}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		tokens <- StreamToken{Done: true}
+	}()
+	return tokens, nil
+}
+
+// collect streams a chat response from the model, returning the tokens' text
+func collect(t *testing.T, model LanguageModel) ([]string, string, error) {
+	t.Helper()
+	var texts []string
+	response, err := GenerateChatStream(context.Background(), model, []Message{{Role: "user", Content: "Hi"}}, func(token StreamToken) error {
+		if token.Text != "" {
+			texts = append(texts, token.Text)
+		}
+		return nil
+	})
+	return texts, response, err
+}
+
+func TestDecoratorsStream(t *testing.T) {
+	inner := &tokenLLM{reply: "one two three"}
+	ledger := NewUsageLedger()
+	model := NewMeteredLLM(NewCachingLLM(NewRateLimitedLLM(NewRetryingLLM(inner), WithRequestsPerMinute(60))), ledger)
+
+	texts, response, err := collect(t, model)
+	if err != nil || response != "one two three" {
+		t.Fatalf("Expected the streamed reply, got %q, %v", response, err)
+	}
+	if len(texts) != 3 {
+		t.Errorf("Expected the reply token by token through the decorators, got %q", texts)
+	}
+	if records := ledger.Records(); len(records) != 1 || records[0].Usage.CompletionTokens == 0 {
+		t.Errorf("Expected the stream's usage to be recorded, got %+v", records)
+	}
+
+	// The streamed reply was cached
+	texts, response, err = collect(t, model)
+	if err != nil || response != "one two three" || len(texts) != 1 || inner.streams != 1 {
+		t.Errorf("Expected the cached reply as one token, got %q in %q, %v after %d streams", response, texts, err, inner.streams)
+	}
+}
+
+func TestDecoratorsStreamNonStreamingModels(t *testing.T) {
+	model := NewMeteredLLM(NewRetryingLLM(&flakyLLM{}), NewUsageLedger())
+	texts, response, err := collect(t, model)
+	if err != nil || response != "ok" || len(texts) != 1 {
+		t.Errorf("Expected the reply as one token, got %q in %q, %v", response, texts, err)
+	}
+}
+
+func TestRetryingLLMStreamRetriesUntilFirstToken(t *testing.T) {
+	unavailable := &StatusError{Provider: "Test", StatusCode: 503, Message: "unavailable", Err: ErrProviderError}
+	var delays []time.Duration
+
+	inner := &tokenLLM{reply: "one two", errs: []error{unavailable}}
+	model := NewRetryingLLM(inner, WithRetryJitter(0))
+	model.sleep = noSleep(&delays)
+	if _, response, err := collect(t, model); err != nil || response != "one two" || inner.streams != 2 {
+		t.Errorf("Expected the stream to be opened again, got %q, %v after %d streams", response, err, inner.streams)
+	}
+
+	inner = &tokenLLM{reply: "one two", errs: []error{unavailable}, midStream: true}
+	model = NewRetryingLLM(inner, WithRetryJitter(0))
+	model.sleep = noSleep(&delays)
+	if _, response, err := collect(t, model); !errors.Is(err, ErrProviderError) || response != "one " || inner.streams != 1 {
+		t.Errorf("Expected a failure after the first token not to be retried, got %q, %v after %d streams", response, err, inner.streams)
+	}
+}
+
+func TestRelayEndsBeforeLastToken(t *testing.T) {
+	for _, last := range []StreamToken{{Done: true}, {Error: ErrProviderError}} {
+		tokens := make(chan StreamToken, 2)
+		tokens <- StreamToken{Text: "hi"}
+		tokens <- last
+		close(tokens)
+
+		var ended string
+		for token := range relay(context.Background(), nil, tokens, func(response string, err error) { ended = response }) {
+			if (token.Done || token.Error != nil) && ended != "hi" {
+				t.Errorf("Expected the stream to be ended before %+v is forwarded", token)
+			}
+		}
+	}
+}
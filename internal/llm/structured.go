@@ -0,0 +1,369 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidStructuredOutput is returned when a model keeps answering with JSON that does
+// not match the schema after the repair attempts
+var ErrInvalidStructuredOutput = errors.New("invalid structured output")
+
+// DefaultStructuredRepairs is how often GenerateStructured asks the model to repair its reply
+const DefaultStructuredRepairs = 2
+
+// responseSchemaKey keys the response schema of a request context
+type responseSchemaKey struct{}
+
+// JSONSchema is a JSON schema document, e.g. {"type": "object", "properties": {...}}. Validate
+// supports type, enum, properties, required, additionalProperties, items, minItems,
+// maxItems, minimum and maximum; other keywords are sent to the provider but not checked.
+type JSONSchema map[string]any
+
+// ResponseSchema asks a provider for JSON output matching a schema
+type ResponseSchema struct {
+	Name   string // Name of the schema, required by some providers
+	Schema JSONSchema
+}
+
+// StructuredOutputSupporter is implemented by language models that can constrain their
+// output to a JSON schema given with WithResponseSchema
+type StructuredOutputSupporter interface {
+	SupportsStructuredOutput() bool
+}
+
+// ResponseFormat is the response_format of OpenAI-compatible chat requests
+type ResponseFormat struct {
+	Type       string              `json:"type"` // "json_schema"
+	JSONSchema *ResponseFormatSpec `json:"json_schema,omitempty"`
+}
+
+// ResponseFormatSpec is the schema of a json_schema ResponseFormat
+type ResponseFormatSpec struct {
+	Name   string     `json:"name"`
+	Schema JSONSchema `json:"schema"`
+}
+
+// WithResponseSchema returns a context asking the providers of its requests for JSON output
+// matching the schema. Providers that do not support it ignore it.
+func WithResponseSchema(ctx context.Context, schema ResponseSchema) context.Context {
+	return context.WithValue(ctx, responseSchemaKey{}, schema)
+}
+
+// ResponseSchemaFrom returns the response schema of a context
+func ResponseSchemaFrom(ctx context.Context) (ResponseSchema, bool) {
+	schema, ok := ctx.Value(responseSchemaKey{}).(ResponseSchema)
+	return schema, ok
+}
+
+// responseFormat returns the OpenAI-compatible response_format of a request context, nil
+// if it has no response schema
+func responseFormat(ctx context.Context) *ResponseFormat {
+	schema, ok := ResponseSchemaFrom(ctx)
+	if !ok {
+		return nil
+	}
+	return &ResponseFormat{Type: "json_schema", JSONSchema: &ResponseFormatSpec{Name: schema.Name, Schema: schema.Schema}}
+}
+
+// SupportsStructuredOutput reports whether a language model, or the model it decorates,
+// can constrain its output to a JSON schema
+func SupportsStructuredOutput(model LanguageModel) bool {
+	for model != nil {
+		if supporter, ok := model.(StructuredOutputSupporter); ok {
+			return supporter.SupportsStructuredOutput()
+		}
+		wrapper, ok := model.(interface{ Unwrap() LanguageModel })
+		if !ok {
+			return false
+		}
+		model = wrapper.Unwrap()
+	}
+	return false
+}
+
+// structuredOptions configures GenerateStructured
+type structuredOptions struct {
+	name    string
+	repairs int
+}
+
+// StructuredOption is a function that configures GenerateStructured
+type StructuredOption func(*structuredOptions)
+
+// WithSchemaName sets the name of the schema sent to the provider, "response" by default
+func WithSchemaName(name string) StructuredOption {
+	return func(o *structuredOptions) {
+		if name != "" {
+			o.name = name
+		}
+	}
+}
+
+// WithStructuredRepairs sets how often the model is asked to repair an invalid reply
+func WithStructuredRepairs(repairs int) StructuredOption {
+	return func(o *structuredOptions) {
+		if repairs >= 0 {
+			o.repairs = repairs
+		}
+	}
+}
+
+// structuredInstruction asks models without native JSON output for JSON matching the schema
+const structuredInstruction = "Reply with a single JSON value that matches this JSON schema, and nothing else:\n%s"
+
+// repairPrompt asks the model to fix a reply that did not match the schema
+const repairPrompt = "Your reply is not valid: %v. Reply with only the corrected JSON, matching the schema."
+
+// GenerateStructured generates a chat response as JSON matching the schema. Providers
+// supporting structured output are asked for it natively; others are instructed in the
+// prompt. Replies are parsed, code fences and surrounding text removed, and validated;
+// invalid ones are sent back with a repair prompt. The validated JSON is returned.
+func GenerateStructured(ctx context.Context, model LanguageModel, messages []Message, schema JSONSchema, options ...StructuredOption) (json.RawMessage, error) {
+	opts := structuredOptions{name: "response", repairs: DefaultStructuredRepairs}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	ctx = WithResponseSchema(ctx, ResponseSchema{Name: opts.name, Schema: schema})
+	conversation := append([]Message(nil), messages...)
+	if !SupportsStructuredOutput(model) {
+		schemaJSON, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal schema: %w", err)
+		}
+		conversation = append(conversation, Message{Role: "system", Content: fmt.Sprintf(structuredInstruction, schemaJSON)})
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= opts.repairs; attempt++ {
+		response, err := model.GenerateChat(ctx, conversation)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := parseStructured(response, schema)
+		if err == nil {
+			return raw, nil
+		}
+		lastErr = err
+		conversation = append(conversation,
+			Message{Role: "assistant", Content: response},
+			Message{Role: "user", Content: fmt.Sprintf(repairPrompt, err)},
+		)
+	}
+	return nil, fmt.Errorf("%w after %d repairs: %v", ErrInvalidStructuredOutput, opts.repairs, lastErr)
+}
+
+// parseStructured extracts the JSON value of a reply and validates it against the schema
+func parseStructured(response string, schema JSONSchema) (json.RawMessage, error) {
+	text := extractJSON(response)
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return nil, fmt.Errorf("not JSON: %v", err)
+	}
+	if err := schema.Validate(value); err != nil {
+		return nil, err
+	}
+	return json.RawMessage(text), nil
+}
+
+// extractJSON returns the JSON value in a reply, without code fences or surrounding text
+func extractJSON(response string) string {
+	text := strings.TrimSpace(response)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text[3:], "json")
+		if end := strings.LastIndex(text, "```"); end >= 0 {
+			text = text[:end]
+		}
+		text = strings.TrimSpace(text)
+	}
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return text
+	}
+	closing := "}"
+	if text[start] == '[' {
+		closing = "]"
+	}
+	if end := strings.LastIndex(text, closing); end > start {
+		return text[start : end+1]
+	}
+	return text[start:]
+}
+
+// Validate checks a decoded JSON value, as from json.Unmarshal into an any, against the schema
+func (s JSONSchema) Validate(value any) error {
+	return validateSchema(s, value, "$")
+}
+
+// validateSchema checks the value at the path against a schema
+func validateSchema(schema map[string]any, value any, path string) error {
+	if types, ok := schemaTypes(schema["type"]); ok && !matchesType(types, value) {
+		return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonType(value))
+	}
+	if enum, ok := anyList(schema["enum"]); ok {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := asSchema(schema["properties"])
+		for _, name := range stringList(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := asSchema(properties[name])
+			if !ok {
+				if allowed, isBool := schema["additionalProperties"].(bool); isBool && !allowed {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+				continue
+			}
+			if err := validateSchema(property, v[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if minItems, ok := number(schema["minItems"]); ok && float64(len(v)) < minItems {
+			return fmt.Errorf("%s: expected at least %v items, got %d", path, minItems, len(v))
+		}
+		if maxItems, ok := number(schema["maxItems"]); ok && float64(len(v)) > maxItems {
+			return fmt.Errorf("%s: expected at most %v items, got %d", path, maxItems, len(v))
+		}
+		if items, ok := asSchema(schema["items"]); ok {
+			for i, item := range v {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case float64:
+		if minimum, ok := number(schema["minimum"]); ok && v < minimum {
+			return fmt.Errorf("%s: %v is less than %v", path, v, minimum)
+		}
+		if maximum, ok := number(schema["maximum"]); ok && v > maximum {
+			return fmt.Errorf("%s: %v is more than %v", path, v, maximum)
+		}
+	}
+	return nil
+}
+
+// schemaTypes returns the types a schema allows, from a "type" string or list
+func schemaTypes(value any) ([]string, bool) {
+	switch t := value.(type) {
+	case string:
+		return []string{t}, true
+	case []any, []string:
+		types := stringList(t)
+		return types, len(types) > 0
+	}
+	return nil, false
+}
+
+// matchesType reports whether the value has one of the JSON types
+func matchesType(types []string, value any) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON schema type of a decoded value
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// stringList returns the strings of a schema list, written in Go or decoded from JSON
+func stringList(value any) []string {
+	switch list := value.(type) {
+	case []string:
+		return list
+	case []any:
+		strs := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
+// number returns a schema number, written in Go or decoded from JSON
+func number(value any) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// asSchema returns a schema object, written in Go or decoded from JSON
+func asSchema(value any) (map[string]any, bool) {
+	switch m := value.(type) {
+	case JSONSchema:
+		return m, true
+	case map[string]any:
+		return m, true
+	}
+	return nil, false
+}
+
+// anyList returns the items of a schema list, written in Go or decoded from JSON
+func anyList(value any) ([]any, bool) {
+	switch list := value.(type) {
+	case []any:
+		return list, true
+	case []string:
+		items := make([]any, len(list))
+		for i, item := range list {
+			items[i] = item
+		}
+		return items, true
+	}
+	return nil, false
+}
@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// repliesLLM answers chat requests with its replies in turn and records the requests
+type repliesLLM struct {
+	replies  []string
+	requests [][]Message
+}
+
+func (r *repliesLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return r.GenerateChat(ctx, []Message{{Role: "user", Content: prompt}})
+}
+
+func (r *repliesLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
+	r.requests = append(r.requests, messages)
+	if len(r.requests) > len(r.replies) {
+		return "", errors.New("no more replies")
+	}
+	return r.replies[len(r.requests)-1], nil
+}
+
+var taskSchema = JSONSchema{
+	"type":     "object",
+	"required": []string{"title", "priority"},
+	"properties": map[string]any{
+		"title":    JSONSchema{"type": "string"},
+		"priority": JSONSchema{"type": "integer", "minimum": 1, "maximum": 3},
+		"tags":     JSONSchema{"type": "array", "items": JSONSchema{"type": "string"}},
+		"status":   JSONSchema{"enum": []string{"open", "done"}},
+	},
+	"additionalProperties": false,
+}
+
+func TestGenerateStructured_RepairsInvalidReplies(t *testing.T) {
+	model := &repliesLLM{replies: []string{
+		"Sure! Here it is: {\"title\": \"Ship\", \"priority\": 5}",
+		"```json\n{\"title\": \"Ship\", \"priority\": 2, \"tags\": [\"q3\"]}\n```",
+	}}
+
+	raw, err := GenerateStructured(context.Background(), model, []Message{{Role: "user", Content: "Make a task"}}, taskSchema)
+	if err != nil {
+		t.Fatalf("Expected the repaired reply, got %v", err)
+	}
+	var task struct {
+		Title    string
+		Priority int
+	}
+	if err := json.Unmarshal(raw, &task); err != nil || task.Title != "Ship" || task.Priority != 2 {
+		t.Errorf("Unexpected task %+v (%v)", task, err)
+	}
+
+	first := model.requests[0]
+	if last := first[len(first)-1]; last.Role != "system" || !strings.Contains(last.Content, `"priority"`) {
+		t.Errorf("Expected the schema in the prompt of a model without JSON mode, got %+v", last)
+	}
+	repair := model.requests[1][len(model.requests[1])-1]
+	if !strings.Contains(repair.Content, "$.priority: 5 is more than 3") {
+		t.Errorf("Expected the validation error in the repair prompt, got %q", repair.Content)
+	}
+}
+
+func TestGenerateStructured_GivesUp(t *testing.T) {
+	model := &repliesLLM{replies: []string{"no", "still no"}}
+	_, err := GenerateStructured(context.Background(), model, nil, taskSchema, WithStructuredRepairs(1))
+	if !errors.Is(err, ErrInvalidStructuredOutput) {
+		t.Errorf("Expected ErrInvalidStructuredOutput, got %v", err)
+	}
+	if len(model.requests) != 2 {
+		t.Errorf("Expected one repair, got %d requests", len(model.requests))
+	}
+}
+
+func TestGenerateStructured_NativeResponseFormat(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"title\":\"Ship\",\"priority\":1}"}}]}`))
+	}))
+	defer server.Close()
+	lmStudio, err := NewLMStudioLLM(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model := NewRetryingLLM(lmStudio)
+	if _, err := GenerateStructured(context.Background(), model, []Message{{Role: "user", Content: "Make a task"}}, taskSchema, WithSchemaName("task")); err != nil {
+		t.Fatalf("Failed to generate structured output: %v", err)
+	}
+	if !strings.Contains(body, `"response_format":{"type":"json_schema","json_schema":{"name":"task"`) {
+		t.Errorf("Expected a response_format in the request, got %s", body)
+	}
+	if strings.Contains(body, "Reply with a single JSON value") {
+		t.Errorf("Expected no schema instruction with native JSON output, got %s", body)
+	}
+}
+
+func TestJSONSchemaValidate(t *testing.T) {
+	tests := map[string]string{
+		`{"title": "a", "priority": 1}`:                   "",
+		`{"title": "a"}`:                                  `missing required property "priority"`,
+		`{"title": 1, "priority": 1}`:                     "$.title: expected string, got integer",
+		`{"title": "a", "priority": 1.5}`:                 "$.priority: expected integer, got number",
+		`{"title": "a", "priority": 1, "tags": ["x", 2]}`: "$.tags[1]: expected string",
+		`{"title": "a", "priority": 1, "status": "gone"}`: "is not one of",
+		`{"title": "a", "priority": 1, "owner": "me"}`:    `unexpected property "owner"`,
+		`[]`: "$: expected object, got array",
+	}
+	for input, want := range tests {
+		var value any
+		if err := json.Unmarshal([]byte(input), &value); err != nil {
+			t.Fatal(err)
+		}
+		err := taskSchema.Validate(value)
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("Validate(%s) = %v, want no error", input, err)
+		case want != "" && (err == nil || !strings.Contains(err.Error(), want)):
+			t.Errorf("Validate(%s) = %v, want an error containing %q", input, err, want)
+		}
+	}
+}
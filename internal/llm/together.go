@@ -61,6 +61,11 @@ func WithTogetherMaxTokens(maxTokens int) TogetherOption {
 	}
 }
 
+// Model returns the name of the model requests are sent to
+func (t *TogetherLLM) Model() string {
+	return t.model
+}
+
 // GenerateResponse generates a text response for a single prompt
 func (t *TogetherLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
 	// This is a placeholder implementation
@@ -90,6 +95,9 @@ func (t *TogetherLLM) GenerateChat(ctx context.Context, messages []Message) (str
 		// Continue processing
 	}
 
+	// Text only, images are described by their alt text
+	messages = TextOnly(messages)
+
 	// Extract the last user message for demonstration
 	var lastMessage string
 	for i := len(messages) - 1; i >= 0; i-- {
@@ -106,9 +114,10 @@ func (t *TogetherLLM) GenerateChat(ctx context.Context, messages []Message) (str
 	return fmt.Sprintf("[Together AI %s] Chat response placeholder for prompt: %s", t.model, lastMessage), nil
 }
 
-// Initialize the factory function
+// Register the provider
 func init() {
-	newTogetherFromConfig = createTogetherFromConfig
+	Register(ProviderTogetherAI, createTogetherFromConfig)
+	RegisterConfigLoader(ProviderTogetherAI, func(s Settings) (ProviderConfig, error) { return togetherSettings(s) })
 }
 
 // Implementation for creating a TogetherLLM from config
@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token accounting overheads, after OpenAI's chat format: every message is wrapped in a
+// few tokens of role markup and every reply is primed with a few more
+const (
+	tokensPerMessage = 4
+	tokensPerReply   = 3
+)
+
+// shortWordLength is the length up to which a word is taken as a single token
+const shortWordLength = 6
+
+// DefaultContextWindow is the context window, in tokens, assumed for unknown models
+const DefaultContextWindow = 8192
+
+// contextWindows are the context windows of known models, by model name prefix. The
+// longest matching prefix wins, so "gpt-4o" is not taken for "gpt-4".
+var contextWindows = map[string]int{
+	"gpt-4o":        128000,
+	"gpt-4-turbo":   128000,
+	"gpt-4.1":       1047576,
+	"gpt-4":         8192,
+	"gpt-3.5-turbo": 16385,
+	"o1":            200000,
+	"o3":            200000,
+	"claude-":       200000,
+	"llama3.1":      131072,
+	"llama3.2":      131072,
+	"llama3":        8192,
+	"mistral":       32768,
+	"mixtral":       32768,
+	"gemma":         8192,
+	"qwen":          32768,
+	"phi3":          4096,
+
+	"togethercomputer/llama-3": 8192,
+}
+
+// Tokenizer counts the tokens a model reads for a text
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// EstimatingTokenizer approximates byte-pair encodings such as tiktoken's cl100k without
+// their vocabularies: common words are a token, longer words a token for every few
+// letters, and every punctuation mark or non-Latin character a token of its own. Counts
+// are within a few percent of the real ones on English text and err on the high side
+// elsewhere, which is the safe side for budgeting.
+type EstimatingTokenizer struct {
+	CharsPerToken int // Letters per token in long words, 4 if 0
+}
+
+// CountTokens estimates the tokens of the text
+func (t EstimatingTokenizer) CountTokens(text string) int {
+	charsPerToken := t.CharsPerToken
+	if charsPerToken <= 0 {
+		charsPerToken = 4
+	}
+
+	tokens := 0
+	word := 0 // Length of the current word in runes
+	flush := func() {
+		switch {
+		case word == 0:
+		case word <= shortWordLength:
+			tokens++
+		default:
+			tokens += (word + charsPerToken - 1) / charsPerToken
+		}
+		word = 0
+	}
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			word++
+		case unicode.IsSpace(r):
+			// Spaces are merged into the following word
+			flush()
+		default:
+			// Punctuation, symbols and non-Latin scripts rarely merge
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// TokenizerFor returns the tokenizer of a model
+func TokenizerFor(model string) Tokenizer {
+	return EstimatingTokenizer{}
+}
+
+// ContextWindow returns the context window of a model in tokens, DefaultContextWindow
+// if the model is unknown
+func ContextWindow(model string) int {
+	model = strings.ToLower(model)
+	window, matched := DefaultContextWindow, 0
+	for prefix, size := range contextWindows {
+		if len(prefix) > matched && strings.HasPrefix(model, prefix) {
+			window, matched = size, len(prefix)
+		}
+	}
+	return window
+}
+
+// CountMessageTokens counts the tokens of a chat request, including the markup around
+// each message
+func CountMessageTokens(tokenizer Tokenizer, messages []Message) int {
+	tokens := tokensPerReply
+	for _, m := range messages {
+		tokens += tokensPerMessage + tokenizer.CountTokens(m.Role) + tokenizer.CountTokens(m.Content) + len(m.Images)*tokensPerImage
+	}
+	return tokens
+}
+
+// FitMessages drops the oldest messages until a chat request fits the budget in tokens.
+// System messages and the latest message are always kept; if they alone exceed the
+// budget, ErrContextTooLarge is returned. The messages are not modified.
+func FitMessages(tokenizer Tokenizer, messages []Message, budget int) ([]Message, error) {
+	total := CountMessageTokens(tokenizer, messages)
+	if total <= budget {
+		return messages, nil
+	}
+
+	fitted := make([]Message, 0, len(messages))
+	last := len(messages) - 1
+	for i, m := range messages {
+		if total > budget && i != last && m.Role != "system" {
+			total -= tokensPerMessage + tokenizer.CountTokens(m.Role) + tokenizer.CountTokens(m.Content)
+			continue
+		}
+		fitted = append(fitted, m)
+	}
+	if total > budget {
+		return nil, fmt.Errorf("%w: %d tokens for a budget of %d", ErrContextTooLarge, total, budget)
+	}
+	return fitted, nil
+}
+
+// ModelName returns the name of the model a language model sends requests to, looking
+// through decorators such as RetryingLLM. Returns "" if the model does not report one.
+func ModelName(model LanguageModel) string {
+	for model != nil {
+		if named, ok := model.(interface{ Model() string }); ok {
+			return named.Model()
+		}
+		wrapper, ok := model.(interface{ Unwrap() LanguageModel })
+		if !ok {
+			return ""
+		}
+		model = wrapper.Unwrap()
+	}
+	return ""
+}
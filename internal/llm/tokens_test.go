@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEstimatingTokenizer(t *testing.T) {
+	tokenizer := EstimatingTokenizer{}
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"Hi, there!", 4},
+		{"internationalization", 5},
+		{"日本", 2},
+	}
+	for _, test := range tests {
+		if got := tokenizer.CountTokens(test.text); got != test.want {
+			t.Errorf("CountTokens(%q) = %d, want %d", test.text, got, test.want)
+		}
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := map[string]int{
+		"gpt-4o-mini":              128000,
+		"gpt-4":                    8192,
+		"GPT-4-turbo":              128000,
+		"claude-3-opus":            200000,
+		"llama3.1:8b":              131072,
+		"llama3":                   8192,
+		"some-unknown-local-model": DefaultContextWindow,
+	}
+	for model, want := range tests {
+		if got := ContextWindow(model); got != want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", model, got, want)
+		}
+	}
+}
+
+func TestFitMessages(t *testing.T) {
+	tokenizer := EstimatingTokenizer{}
+	long := strings.Repeat("word ", 50)
+	messages := []Message{
+		{Role: "system", Content: "You are helpful"},
+		{Role: "user", Content: long},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: "And now?"},
+	}
+
+	fitted, err := FitMessages(tokenizer, messages, 1000)
+	if err != nil || len(fitted) != len(messages) {
+		t.Fatalf("Expected messages within the budget to be kept, got %d, %v", len(fitted), err)
+	}
+
+	budget := CountMessageTokens(tokenizer, messages) - 10
+	fitted, err = FitMessages(tokenizer, messages, budget)
+	if err != nil {
+		t.Fatalf("Failed to fit messages: %v", err)
+	}
+	if len(fitted) != 3 || fitted[0].Role != "system" || fitted[1].Role != "assistant" || fitted[2].Content != "And now?" {
+		t.Errorf("Expected the oldest user message to be dropped, got %+v", fitted)
+	}
+	if CountMessageTokens(tokenizer, fitted) > budget {
+		t.Errorf("Fitted messages exceed the budget")
+	}
+
+	if _, err := FitMessages(tokenizer, messages, 5); !errors.Is(err, ErrContextTooLarge) {
+		t.Errorf("Expected ErrContextTooLarge, got %v", err)
+	}
+}
+
+func TestModelName(t *testing.T) {
+	model, err := NewOllamaLLM("http://localhost:11434", WithOllamaModel("mistral"))
+	if err != nil {
+		t.Fatalf("Failed to create model: %v", err)
+	}
+	if got := ModelName(NewRetryingLLM(model)); got != "mistral" {
+		t.Errorf("Expected the wrapped model's name, got %q", got)
+	}
+	if got := ModelName(NewMockLLM()); got != "" {
+		t.Errorf("Expected no name for the mock, got %q", got)
+	}
+}
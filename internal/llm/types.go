@@ -4,8 +4,9 @@ import "time"
 
 // Message represents a single message in a conversation
 type Message struct {
-	Role    string `json:"role"` // "system", "user", "assistant"
-	Content string `json:"content"`
+	Role    string  `json:"role"` // "system", "user", "assistant"
+	Content string  `json:"content"`
+	Images  []Image `json:"images,omitempty"` // Sent to models that accept images, see SupportsVision
 }
 
 // RequestOptions contains common parameters for LLM requests
@@ -0,0 +1,403 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"goproduct/internal/tracing"
+)
+
+// usageContextKey keys the usage values of a request context
+type usageContextKey int
+
+const (
+	usageReporterKey usageContextKey = iota // func(Usage) receiving provider-reported usage
+	usageScopeKey                           // UsageScope of the request
+)
+
+// maxUsageRecords is how many recent calls a UsageLedger keeps; totals cover every call
+const maxUsageRecords = 1000
+
+// Price is the price of a model in US dollars per million tokens
+type Price struct {
+	Prompt     float64
+	Completion float64
+}
+
+// DefaultPrices are the list prices of hosted models, by model name prefix; the longest
+// matching prefix wins. Models not listed, such as local ones, cost nothing.
+var DefaultPrices = map[string]Price{
+	"gpt-4o":            {Prompt: 2.50, Completion: 10},
+	"gpt-4o-mini":       {Prompt: 0.15, Completion: 0.60},
+	"gpt-4.1":           {Prompt: 2, Completion: 8},
+	"gpt-4.1-mini":      {Prompt: 0.40, Completion: 1.60},
+	"gpt-4-turbo":       {Prompt: 10, Completion: 30},
+	"gpt-4":             {Prompt: 30, Completion: 60},
+	"gpt-3.5-turbo":     {Prompt: 0.50, Completion: 1.50},
+	"claude-3-opus":     {Prompt: 15, Completion: 75},
+	"claude-3-5-sonnet": {Prompt: 3, Completion: 15},
+	"claude-3-7-sonnet": {Prompt: 3, Completion: 15},
+	"claude-3-haiku":    {Prompt: 0.25, Completion: 1.25},
+	"claude-3-5-haiku":  {Prompt: 0.80, Completion: 4},
+}
+
+// UsageScope attributes the usage of a request to an entity and a conversation
+type UsageScope struct {
+	EntityID       string
+	ConversationID string
+}
+
+// WithUsageScope returns a context attributing the usage of its requests to the entity
+// and conversation
+func WithUsageScope(ctx context.Context, entityID, conversationID string) context.Context {
+	return context.WithValue(ctx, usageScopeKey, UsageScope{EntityID: entityID, ConversationID: conversationID})
+}
+
+// UsageScopeFrom returns the usage scope of a context, empty if it has none
+func UsageScopeFrom(ctx context.Context) UsageScope {
+	scope, _ := ctx.Value(usageScopeKey).(UsageScope)
+	return scope
+}
+
+// ReportUsage hands the token counts a provider returned for a request to the MeteredLLM
+// that made it, if any. Providers call it after every successful request; empty counts,
+// from servers that do not report usage, are left to be estimated.
+func ReportUsage(ctx context.Context, usage Usage) {
+	if usage == (Usage{}) {
+		return
+	}
+	if report, ok := ctx.Value(usageReporterKey).(func(Usage)); ok {
+		if usage.TotalTokens == 0 {
+			usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		}
+		report(usage)
+	}
+}
+
+// UsageRecord is the usage of one call
+type UsageRecord struct {
+	Time           time.Time
+	EntityID       string
+	ConversationID string
+	Model          string
+	Usage
+	Cost      float64 // US dollars
+	Estimated bool    // The provider reported no usage; the tokens were counted locally
+}
+
+// UsageTotals adds up the usage of many calls
+type UsageTotals struct {
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	Cost             float64 // US dollars
+	EstimatedCalls   int     // Calls whose tokens were counted locally
+}
+
+// add counts a call
+func (t *UsageTotals) add(record UsageRecord) {
+	t.Calls++
+	t.PromptTokens += record.PromptTokens
+	t.CompletionTokens += record.CompletionTokens
+	t.TotalTokens += record.TotalTokens
+	t.Cost += record.Cost
+	if record.Estimated {
+		t.EstimatedCalls++
+	}
+}
+
+// UsageReport is the usage since a ledger was created, in total and by entity,
+// conversation and model
+type UsageReport struct {
+	Since          time.Time
+	Total          UsageTotals
+	ByEntity       map[string]UsageTotals
+	ByConversation map[string]UsageTotals
+	ByModel        map[string]UsageTotals
+}
+
+// UsageLedger records the usage of language model calls
+type UsageLedger struct {
+	mu      sync.Mutex
+	since   time.Time
+	prices  map[string]Price
+	total   UsageTotals
+	byKey   map[string]map[string]*UsageTotals // Totals by "entity", "conversation" and "model"
+	records []UsageRecord                      // Most recent calls, oldest first
+}
+
+// NewUsageLedger creates an empty ledger pricing calls with DefaultPrices
+func NewUsageLedger() *UsageLedger {
+	prices := make(map[string]Price, len(DefaultPrices))
+	for prefix, price := range DefaultPrices {
+		prices[prefix] = price
+	}
+	return &UsageLedger{
+		since:  time.Now(),
+		prices: prices,
+		byKey: map[string]map[string]*UsageTotals{
+			"entity":       {},
+			"conversation": {},
+			"model":        {},
+		},
+	}
+}
+
+// SetPrice sets the price of the models whose names start with the prefix
+func (l *UsageLedger) SetPrice(prefix string, price Price) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prices[strings.ToLower(prefix)] = price
+}
+
+// Cost returns the price of the usage of a model in US dollars
+func (l *UsageLedger) Cost(model string, usage Usage) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cost(model, usage)
+}
+
+// cost prices the usage; the caller holds the lock
+func (l *UsageLedger) cost(model string, usage Usage) float64 {
+	model = strings.ToLower(model)
+	var price Price
+	matched := 0
+	for prefix, p := range l.prices {
+		if len(prefix) > matched && strings.HasPrefix(model, prefix) {
+			price, matched = p, len(prefix)
+		}
+	}
+	return (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1e6
+}
+
+// Record adds a call to the ledger, pricing it if it has no cost, and returns the record
+func (l *UsageLedger) Record(record UsageRecord) UsageRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	if record.Cost == 0 {
+		record.Cost = l.cost(record.Model, record.Usage)
+	}
+
+	l.total.add(record)
+	for dimension, key := range map[string]string{
+		"entity":       record.EntityID,
+		"conversation": record.ConversationID,
+		"model":        record.Model,
+	} {
+		totals, ok := l.byKey[dimension][key]
+		if !ok {
+			totals = &UsageTotals{}
+			l.byKey[dimension][key] = totals
+		}
+		totals.add(record)
+	}
+
+	l.records = append(l.records, record)
+	if len(l.records) > maxUsageRecords {
+		l.records = l.records[len(l.records)-maxUsageRecords:]
+	}
+	return record
+}
+
+// Report returns the usage so far
+func (l *UsageLedger) Report() UsageReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	copyTotals := func(dimension string) map[string]UsageTotals {
+		totals := make(map[string]UsageTotals, len(l.byKey[dimension]))
+		for key, t := range l.byKey[dimension] {
+			totals[key] = *t
+		}
+		return totals
+	}
+	return UsageReport{
+		Since:          l.since,
+		Total:          l.total,
+		ByEntity:       copyTotals("entity"),
+		ByConversation: copyTotals("conversation"),
+		ByModel:        copyTotals("model"),
+	}
+}
+
+// Records returns the most recent calls, oldest first
+func (l *UsageLedger) Records() []UsageRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]UsageRecord(nil), l.records...)
+}
+
+// MeteredLLM is a LanguageModel decorator recording the usage of every successful call in
+// a ledger, attributed to the UsageScope of the request context. Token counts come from
+// the provider where it reports them and are estimated otherwise. The latency, failures and
+// tokens of every call also go to the default metrics registry.
+type MeteredLLM struct {
+	model  LanguageModel
+	ledger *UsageLedger
+	tracer tracing.Tracer
+}
+
+// MeterOption is a function that configures a MeteredLLM
+type MeterOption func(*MeteredLLM)
+
+// NewMeteredLLM wraps a language model, recording its usage in the ledger
+func NewMeteredLLM(model LanguageModel, ledger *UsageLedger, options ...MeterOption) *MeteredLLM {
+	m := &MeteredLLM{
+		model:  model,
+		ledger: ledger,
+		tracer: tracing.NewNoopTracer(),
+	}
+
+	// Apply options
+	for _, option := range options {
+		option(m)
+	}
+
+	return m
+}
+
+// WithMeterTracer sets the tracer receiving an event with the usage of every call
+func WithMeterTracer(tracer tracing.Tracer) MeterOption {
+	return func(m *MeteredLLM) {
+		if tracer != nil {
+			m.tracer = tracer
+		}
+	}
+}
+
+// GenerateResponse generates a text response for a single prompt and records its usage
+func (m *MeteredLLM) GenerateResponse(ctx context.Context, prompt string) (string, error) {
+	return m.do(ctx, "GenerateResponse", []Message{{Role: "user", Content: prompt}}, func(ctx context.Context) (string, error) {
+		return m.model.GenerateResponse(ctx, prompt)
+	})
+}
+
+// GenerateChat generates a response based on a conversation history and records its usage
+func (m *MeteredLLM) GenerateChat(ctx context.Context, messages []Message) (string, error) {
+	return m.do(ctx, "GenerateChat", messages, func(ctx context.Context) (string, error) {
+		return m.model.GenerateChat(ctx, messages)
+	})
+}
+
+// StreamResponse streams a response for a single prompt and records its usage once done
+func (m *MeteredLLM) StreamResponse(ctx context.Context, prompt string) (chan StreamToken, error) {
+	return m.stream(ctx, "StreamResponse", []Message{{Role: "user", Content: prompt}}, func(ctx context.Context) (chan StreamToken, error) {
+		return streamResponse(ctx, m.model, prompt)
+	})
+}
+
+// StreamChat streams a response based on a conversation history and records its usage
+// once done
+func (m *MeteredLLM) StreamChat(ctx context.Context, messages []Message) (chan StreamToken, error) {
+	return m.stream(ctx, "StreamChat", messages, func(ctx context.Context) (chan StreamToken, error) {
+		return streamChat(ctx, m.model, messages)
+	})
+}
+
+// Unwrap returns the wrapped language model
+func (m *MeteredLLM) Unwrap() LanguageModel {
+	return m.model
+}
+
+// Ledger returns the ledger the usage is recorded in
+func (m *MeteredLLM) Ledger() *UsageLedger {
+	return m.ledger
+}
+
+// do runs call with a usage reporter in its context and records the usage
+func (m *MeteredLLM) do(ctx context.Context, method string, messages []Message, call func(ctx context.Context) (string, error)) (string, error) {
+	ctx, record := m.meter(ctx, method, messages)
+	response, err := call(ctx)
+	if err := record(response, err); err != nil {
+		return "", err
+	}
+	return response, nil
+}
+
+// stream opens a stream with a usage reporter in its context and records the usage once
+// the stream ends
+func (m *MeteredLLM) stream(ctx context.Context, method string, messages []Message, open func(ctx context.Context) (chan StreamToken, error)) (chan StreamToken, error) {
+	ctx, record := m.meter(ctx, method, messages)
+	tokens, err := open(ctx)
+	if err != nil {
+		return nil, record("", err)
+	}
+	return relay(ctx, nil, tokens, func(response string, err error) {
+		record(response, err)
+	}), nil
+}
+
+// meter puts a usage reporter in the context of a call and returns the function recording
+// the latency and usage of its response, or its failure, which it returns
+func (m *MeteredLLM) meter(ctx context.Context, method string, messages []Message) (context.Context, func(response string, err error) error) {
+	var (
+		mu       sync.Mutex
+		reported Usage
+		got      bool
+	)
+	ctx = context.WithValue(ctx, usageReporterKey, func(usage Usage) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported.PromptTokens += usage.PromptTokens
+		reported.CompletionTokens += usage.CompletionTokens
+		reported.TotalTokens += usage.TotalTokens
+		got = true
+	})
+
+	model := ModelName(m.model)
+	start := time.Now()
+	return ctx, func(response string, err error) error {
+		requestLatency.ObserveSince(start, model, method)
+		if err != nil {
+			requestErrors.Inc(model, method)
+			return err
+		}
+
+		mu.Lock()
+		usage, estimated := reported, !got
+		mu.Unlock()
+		if estimated {
+			tokenizer := TokenizerFor(model)
+			usage.PromptTokens = CountMessageTokens(tokenizer, messages)
+			usage.CompletionTokens = tokenizer.CountTokens(response)
+			usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		}
+		tokensUsed.Add(float64(usage.PromptTokens), model, "prompt")
+		tokensUsed.Add(float64(usage.CompletionTokens), model, "completion")
+
+		scope := UsageScopeFrom(ctx)
+		record := m.ledger.Record(UsageRecord{
+			EntityID:       scope.EntityID,
+			ConversationID: scope.ConversationID,
+			Model:          model,
+			Usage:          usage,
+			Estimated:      estimated,
+		})
+		m.tracer.Trace(tracing.Event{
+			Timestamp: record.Time,
+			Component: tracing.ComponentLLM,
+			Operation: tracing.OperationGenerate,
+			Level:     tracing.LevelDebug,
+			SourceID:  scope.EntityID,
+			Message: fmt.Sprintf("%s used %d prompt and %d completion tokens of %s",
+				method, usage.PromptTokens, usage.CompletionTokens, model),
+			Metadata: tracing.Correlate(ctx, map[string]interface{}{
+				"method":            method,
+				"model":             model,
+				"prompt_tokens":     usage.PromptTokens,
+				"completion_tokens": usage.CompletionTokens,
+				"total_tokens":      usage.TotalTokens,
+				"cost_usd":          record.Cost,
+				"estimated":         estimated,
+				"conversation_id":   scope.ConversationID,
+			}),
+		})
+		return nil
+	}
+}
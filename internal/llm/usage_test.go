@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goproduct/internal/tracing"
+)
+
+func TestMeteredLLM_RecordsReportedUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hello"}}],` +
+			`"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`))
+	}))
+	defer server.Close()
+	lmStudio, err := NewLMStudioLLM(server.URL, WithLMStudioModel("gemma-3-4b-it"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf strings.Builder
+	ledger := NewUsageLedger()
+	model := NewMeteredLLM(lmStudio, ledger, WithMeterTracer(tracing.NewWriterTracer(&buf, tracing.LevelVerbose)))
+	ctx := WithUsageScope(context.Background(), "Andy", "alice")
+	if _, err := model.GenerateChat(ctx, []Message{{Role: "user", Content: "Hi"}}); err != nil {
+		t.Fatalf("Failed to generate chat: %v", err)
+	}
+
+	report := ledger.Report()
+	if report.Total.Calls != 1 || report.Total.PromptTokens != 12 || report.Total.CompletionTokens != 3 || report.Total.EstimatedCalls != 0 {
+		t.Errorf("Expected the reported usage, got %+v", report.Total)
+	}
+	if report.ByEntity["Andy"].TotalTokens != 15 || report.ByConversation["alice"].TotalTokens != 15 || report.ByModel["gemma-3-4b-it"].Calls != 1 {
+		t.Errorf("Unexpected aggregation %+v", report)
+	}
+	if report.Total.Cost != 0 {
+		t.Errorf("Expected a local model to cost nothing, got %v", report.Total.Cost)
+	}
+	if !strings.Contains(buf.String(), "12 prompt and 3 completion tokens") {
+		t.Errorf("Expected usage in the trace, got %q", buf.String())
+	}
+}
+
+func TestMeteredLLM_EstimatesMissingUsage(t *testing.T) {
+	ledger := NewUsageLedger()
+	model := NewMeteredLLM(NewEchoLLM(0), ledger)
+	if _, err := model.GenerateResponse(context.Background(), "How are you doing today?"); err != nil {
+		t.Fatal(err)
+	}
+
+	records := ledger.Records()
+	if len(records) != 1 || !records[0].Estimated || records[0].PromptTokens == 0 || records[0].CompletionTokens == 0 {
+		t.Errorf("Expected an estimated record, got %+v", records)
+	}
+	if report := ledger.Report(); report.ByEntity[""].Calls != 1 {
+		t.Errorf("Expected an unscoped call, got %+v", report.ByEntity)
+	}
+}
+
+func TestMeteredLLM_RecordsMetrics(t *testing.T) {
+	echo := NewMeteredLLM(NewEchoLLM(0), NewUsageLedger())
+	name := ModelName(echo)
+	calls := requestLatency.Count(name, "GenerateChat")
+	prompt := tokensUsed.Value(name, "prompt")
+	if _, err := echo.GenerateChat(context.Background(), []Message{{Role: "user", Content: "How are you doing today?"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := requestLatency.Count(name, "GenerateChat"); got != calls+1 {
+		t.Errorf("Expected one more timed call, got %d after %d", got, calls)
+	}
+	if tokensUsed.Value(name, "prompt") <= prompt {
+		t.Errorf("Expected the prompt tokens counted")
+	}
+
+	failing := NewMeteredLLM(NewExceptionLLM(0), NewUsageLedger())
+	name = ModelName(failing)
+	failures := requestErrors.Value(name, "GenerateResponse")
+	if _, err := failing.GenerateResponse(context.Background(), "Hi"); err == nil {
+		t.Fatal("Expected the call to fail")
+	}
+	if got := requestErrors.Value(name, "GenerateResponse"); got != failures+1 {
+		t.Errorf("Expected one more failure, got %v after %v", got, failures)
+	}
+}
+
+func TestUsageLedger_Cost(t *testing.T) {
+	ledger := NewUsageLedger()
+	usage := Usage{PromptTokens: 1_000_000, CompletionTokens: 1_000_000}
+
+	tests := map[string]float64{
+		"gpt-4o-2024-08-06":      12.50,
+		"gpt-4o-mini":            0.75,
+		"claude-3-opus-20240229": 90,
+		"llama3":                 0,
+	}
+	for model, want := range tests {
+		if got := ledger.Cost(model, usage); math.Abs(got-want) > 1e-9 {
+			t.Errorf("Cost(%s) = %v, want %v", model, got, want)
+		}
+	}
+
+	ledger.SetPrice("llama3", Price{Prompt: 0.1, Completion: 0.1})
+	if got := ledger.Cost("llama3", usage); math.Abs(got-0.2) > 1e-9 {
+		t.Errorf("Expected the set price, got %v", got)
+	}
+}
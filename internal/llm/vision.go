@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tokensPerImage estimates the prompt tokens of an image, as a 1024x1024 image costs on
+// OpenAI's high detail setting
+const tokensPerImage = 765
+
+// maxImageBytes is the largest image LoadImage reads; providers reject larger ones
+const maxImageBytes = 20 << 20
+
+// VisionModels are the model name fragments of models that accept images; a model whose
+// lowercased name contains one of them is sent images, other models their alt text
+var VisionModels = []string{
+	"gpt-4o", "gpt-4.1", "gpt-4-turbo", "gpt-4-vision", "o3", "o4",
+	"claude-3", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4",
+	"llava", "bakllava", "llama3.2-vision", "llama-3.2-vision", "llama4",
+	"gemma3", "gemma-3", "qwen2.5vl", "qwen2-vl", "qwen2.5-vl", "minicpm-v", "moondream", "pixtral",
+}
+
+// Image is an image attached to a message, either inline data or a URL
+type Image struct {
+	MediaType string `json:"media_type,omitempty"` // e.g. "image/png"
+	Data      []byte `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`      // Used when there is no data
+	AltText   string `json:"alt_text,omitempty"` // Sent instead of the image to text-only models
+}
+
+// VisionSupporter is implemented by language models that report whether they accept images
+type VisionSupporter interface {
+	SupportsVision() bool
+}
+
+// LoadImage reads an image file, detecting its media type from its content. The file
+// name is the alt text.
+func LoadImage(path string) (Image, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Image{}, err
+	}
+	if info.Size() > maxImageBytes {
+		return Image{}, fmt.Errorf("image %s is larger than %d MB", path, maxImageBytes>>20)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Image{}, err
+	}
+	mediaType := http.DetectContentType(data)
+	if !strings.HasPrefix(mediaType, "image/") {
+		return Image{}, fmt.Errorf("%s is not an image (%s)", path, mediaType)
+	}
+	return Image{MediaType: mediaType, Data: data, AltText: filepath.Base(path)}, nil
+}
+
+// DataURL returns the image as a data URL, or its URL if it has no data
+func (i Image) DataURL() string {
+	if len(i.Data) == 0 {
+		return i.URL
+	}
+	return "data:" + i.mediaType() + ";base64," + base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// Placeholder returns the text standing in for the image with text-only models
+func (i Image) Placeholder() string {
+	if i.AltText == "" {
+		return "[Image]"
+	}
+	return "[Image: " + i.AltText + "]"
+}
+
+// mediaType returns the media type of the image, detected from the data if not set
+func (i Image) mediaType() string {
+	if i.MediaType != "" {
+		return i.MediaType
+	}
+	return http.DetectContentType(i.Data)
+}
+
+// isVisionModel reports whether a model name matches VisionModels
+func isVisionModel(model string) bool {
+	model = strings.ToLower(model)
+	for _, fragment := range VisionModels {
+		if strings.Contains(model, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsVision reports whether a language model, or the model it decorates, accepts images
+func SupportsVision(model LanguageModel) bool {
+	for model != nil {
+		if supporter, ok := model.(VisionSupporter); ok {
+			return supporter.SupportsVision()
+		}
+		wrapper, ok := model.(interface{ Unwrap() LanguageModel })
+		if !ok {
+			return false
+		}
+		model = wrapper.Unwrap()
+	}
+	return false
+}
+
+// TextOnly returns the messages with their images replaced by placeholders in the text,
+// for models that do not accept images. Messages without images are returned as is.
+func TextOnly(messages []Message) []Message {
+	hasImages := false
+	for _, m := range messages {
+		if len(m.Images) > 0 {
+			hasImages = true
+			break
+		}
+	}
+	if !hasImages {
+		return messages
+	}
+
+	result := make([]Message, len(messages))
+	for i, m := range messages {
+		result[i] = Message{Role: m.Role, Content: m.Content}
+		for _, image := range m.Images {
+			result[i].Content = withPlaceholder(result[i].Content, image)
+		}
+	}
+	return result
+}
+
+// withPlaceholder appends the placeholder of an image to the text of a message
+func withPlaceholder(content string, image Image) string {
+	if content == "" {
+		return image.Placeholder()
+	}
+	return content + "\n" + image.Placeholder()
+}
+
+// OpenAIMessage is a message in the OpenAI chat format, also spoken by LM Studio.
+// Messages with images are sent as a list of text and image_url content parts.
+type OpenAIMessage struct {
+	Role    string
+	Content string
+	Images  []Image
+}
+
+// openAIContentPart is a part of the content of an OpenAIMessage
+type openAIContentPart struct {
+	Type     string          `json:"type"` // "text" or "image_url"
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+// openAIImageURL is the image of an image_url content part
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// MarshalJSON encodes the message with a string content, or content parts if it has images
+func (m OpenAIMessage) MarshalJSON() ([]byte, error) {
+	if len(m.Images) == 0 {
+		return json.Marshal(struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		}{m.Role, m.Content})
+	}
+
+	parts := make([]openAIContentPart, 0, len(m.Images)+1)
+	if m.Content != "" {
+		parts = append(parts, openAIContentPart{Type: "text", Text: m.Content})
+	}
+	for _, image := range m.Images {
+		parts = append(parts, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: image.DataURL()}})
+	}
+	return json.Marshal(struct {
+		Role    string              `json:"role"`
+		Content []openAIContentPart `json:"content"`
+	}{m.Role, parts})
+}
+
+// toOpenAIMessages converts internal messages to the OpenAI format, replacing images by
+// their placeholders if the model does not accept them
+func toOpenAIMessages(messages []Message, vision bool) []OpenAIMessage {
+	if !vision {
+		messages = TextOnly(messages)
+	}
+	result := make([]OpenAIMessage, len(messages))
+	for i, m := range messages {
+		result[i] = OpenAIMessage{Role: m.Role, Content: m.Content, Images: m.Images}
+	}
+	return result
+}
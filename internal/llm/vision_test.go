@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngHeader is enough of a PNG file for content detection
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestOpenAIImages(t *testing.T) {
+	var content json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Messages []struct {
+				Content json.RawMessage `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		content = request.Messages[len(request.Messages)-1].Content
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	messages := []Message{{Role: "user", Content: "Is this clear?", Images: []Image{{MediaType: "image/png", Data: pngHeader, AltText: "shot.png"}}}}
+
+	vision, _ := NewOpenAILLM("key", WithOpenAIBaseURL(server.URL), WithOpenAIModel("gpt-4o-mini"))
+	if _, err := vision.GenerateChat(context.Background(), messages); err != nil {
+		t.Fatalf("Failed to generate chat: %v", err)
+	}
+	var parts []openAIContentPart
+	if err := json.Unmarshal(content, &parts); err != nil {
+		t.Fatalf("Expected content parts, got %s", content)
+	}
+	if len(parts) != 2 || parts[0].Text != "Is this clear?" || parts[1].ImageURL == nil ||
+		!strings.HasPrefix(parts[1].ImageURL.URL, "data:image/png;base64,") {
+		t.Errorf("Unexpected content parts: %s", content)
+	}
+
+	textOnly, _ := NewOpenAILLM("key", WithOpenAIBaseURL(server.URL), WithOpenAIModel("gpt-3.5-turbo"))
+	if _, err := textOnly.GenerateChat(context.Background(), messages); err != nil {
+		t.Fatalf("Failed to generate chat: %v", err)
+	}
+	var text string
+	if err := json.Unmarshal(content, &text); err != nil || text != "Is this clear?\n[Image: shot.png]" {
+		t.Errorf("Expected the alt text in place of the image, got %s", content)
+	}
+}
+
+func TestAnthropicImages(t *testing.T) {
+	_, messages := toAnthropicMessages([]Message{
+		{Role: "user", Content: "First", Images: []Image{{MediaType: "image/png", Data: pngHeader}}},
+		{Role: "user", Content: "Second", Images: []Image{{URL: "https://example.com/mockup.png"}}},
+	})
+	if len(messages) != 1 || len(messages[0].Images) != 2 {
+		t.Fatalf("Expected one merged message with both images, got %+v", messages)
+	}
+
+	data, err := json.Marshal(messages[0])
+	if err != nil {
+		t.Fatalf("Failed to marshal message: %v", err)
+	}
+	var decoded struct {
+		Content []anthropicContentBlock `json:"content"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Expected content blocks, got %s", data)
+	}
+	blocks := decoded.Content
+	if len(blocks) != 3 || blocks[0].Source.Type != "base64" || blocks[0].Source.MediaType != "image/png" ||
+		blocks[1].Source.Type != "url" || blocks[2].Text != "First\n\nSecond" {
+		t.Errorf("Unexpected content blocks: %s", data)
+	}
+}
+
+func TestOllamaImages(t *testing.T) {
+	messages := []Message{{Role: "user", Content: "Look", Images: []Image{
+		{Data: pngHeader, AltText: "a.png"},
+		{URL: "https://example.com/b.png", AltText: "b.png"},
+	}}}
+
+	vision, _ := NewOllamaLLM("http://localhost:11434", WithOllamaModel("llava:13b"))
+	converted := vision.toOllamaMessages(messages)
+	if len(converted[0].Images) != 1 || converted[0].Content != "Look\n[Image: b.png]" {
+		t.Errorf("Expected inline data as an image and the URL as alt text, got %+v", converted[0])
+	}
+
+	textOnly, _ := NewOllamaLLM("http://localhost:11434", WithOllamaModel("llama3"))
+	converted = textOnly.toOllamaMessages(messages)
+	if len(converted[0].Images) != 0 || converted[0].Content != "Look\n[Image: a.png]\n[Image: b.png]" {
+		t.Errorf("Expected only alt text for a text-only model, got %+v", converted[0])
+	}
+}
+
+func TestSupportsVision(t *testing.T) {
+	vision, _ := NewAnthropicLLM("key", WithAnthropicModel("claude-3-5-sonnet-latest"))
+	if !SupportsVision(NewRetryingLLM(vision)) {
+		t.Error("Expected a decorated Claude 3 model to support vision")
+	}
+	if SupportsVision(NewEchoLLM(0)) {
+		t.Error("Expected the echo model not to support vision")
+	}
+}
+
+func TestLoadImage(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mockup.png")
+	os.WriteFile(path, pngHeader, 0o644)
+
+	image, err := LoadImage(path)
+	if err != nil {
+		t.Fatalf("Failed to load image: %v", err)
+	}
+	if image.MediaType != "image/png" || image.AltText != "mockup.png" {
+		t.Errorf("Unexpected image: %s %q", image.MediaType, image.AltText)
+	}
+
+	notes := filepath.Join(dir, "notes.txt")
+	os.WriteFile(notes, []byte("not an image"), 0o644)
+	if _, err := LoadImage(notes); err == nil {
+		t.Error("Expected an error for a text file")
+	}
+}
+
+func TestCountMessageTokensWithImages(t *testing.T) {
+	tokenizer := EstimatingTokenizer{}
+	text := []Message{{Role: "user", Content: "Hi"}}
+	withImage := []Message{{Role: "user", Content: "Hi", Images: []Image{{Data: pngHeader}}}}
+	if got := CountMessageTokens(tokenizer, withImage) - CountMessageTokens(tokenizer, text); got != tokensPerImage {
+		t.Errorf("Expected an image to count %d tokens, got %d", tokensPerImage, got)
+	}
+}
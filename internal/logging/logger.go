@@ -10,6 +10,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 )
 
 // LogLevel represents the severity of a log message
@@ -39,6 +41,22 @@ func (l LogLevel) String() string {
 	}
 }
 
+// ParseLevel returns the log level of a name such as "debug" or "WARN"
+func ParseLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level %q", name)
+	}
+}
+
 // ToSlogLevel converts our LogLevel to slog.Level
 func (l LogLevel) ToSlogLevel() slog.Level {
 	switch l {
@@ -55,10 +73,139 @@ func (l LogLevel) ToSlogLevel() slog.Level {
 	}
 }
 
+// Format is the format of the log lines
+type Format string
+
+// Log formats
+const (
+	FormatText Format = "text" // "time LEVEL file:line message [key=value, ...]"
+	FormatJSON Format = "json" // One JSON object per line
+)
+
+// ParseFormat returns the log format of a name, "text" or "json"
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(name))); format {
+	case FormatText, FormatJSON:
+		return format, nil
+	default:
+		return FormatText, fmt.Errorf("unknown log format %q", name)
+	}
+}
+
+// ComponentKey is the attribute key of the component of the loggers returned by Component
+const ComponentKey = "component"
+
+// Options configures a logger created by New or Open
+type Options struct {
+	Format     Format              // FormatText or FormatJSON
+	Level      LogLevel            // Level of the components without a level of their own
+	Components map[string]LogLevel // Levels of individual components, e.g. "llm": LevelDebug
+	Rotation   RotationOptions     // Rotation of the log file, for Open
+}
+
+// DefaultOptions returns the default options: text at debug level, with the default
+// rotation
+func DefaultOptions() Options {
+	return Options{
+		Format:   FormatText,
+		Level:    LevelDebug,
+		Rotation: DefaultRotationOptions(),
+	}
+}
+
 // Logger is a simple wrapper around slog
 type Logger struct {
-	Logger *slog.Logger // Capitalized for direct access
-	writer io.Writer
+	Logger    *slog.Logger // Capitalized for direct access
+	writer    io.Writer
+	base      slog.Handler // Formats the records, without level checks
+	component string
+	shared    *shared
+}
+
+// shared is the state shared by a logger and its component loggers: the levels, which
+// can be changed at runtime, and the component loggers created so far
+type shared struct {
+	mu         sync.RWMutex
+	level      LogLevel
+	components map[string]LogLevel
+	loggers    map[string]*Logger
+}
+
+// levelOf returns the level of a component
+func (s *shared) levelOf(component string) LogLevel {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if level, ok := s.components[component]; ok && component != "" {
+		return level
+	}
+	return s.level
+}
+
+// levelHandler filters the records of a component by its current level
+type levelHandler struct {
+	slog.Handler
+	component string
+	shared    *shared
+}
+
+// Enabled reports whether the component logs records at the given level
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.shared.levelOf(h.component).ToSlogLevel()
+}
+
+// WithAttrs returns a handler of the same component with the attributes added
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), component: h.component, shared: h.shared}
+}
+
+// WithGroup returns a handler of the same component with the group added
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), component: h.component, shared: h.shared}
+}
+
+// New creates a logger that writes to the writer
+func New(w io.Writer, options Options) *Logger {
+	var base slog.Handler
+	if options.Format == FormatJSON {
+		base = slog.NewJSONHandler(w, &slog.HandlerOptions{
+			AddSource:   true,
+			Level:       slog.LevelDebug,
+			ReplaceAttr: shortSource,
+		})
+	} else {
+		base = &customHandler{level: slog.LevelDebug, addSource: true, w: w}
+	}
+
+	components := make(map[string]LogLevel, len(options.Components))
+	for component, level := range options.Components {
+		components[component] = level
+	}
+	s := &shared{level: options.Level, components: components, loggers: make(map[string]*Logger)}
+	return &Logger{
+		Logger: slog.New(&levelHandler{Handler: base, shared: s}),
+		writer: w,
+		base:   base,
+		shared: s,
+	}
+}
+
+// Open creates a logger that writes to a rotating log file
+func Open(filename string, options Options) (*Logger, error) {
+	file, err := OpenRotatingFile(filename, options.Rotation)
+	if err != nil {
+		return nil, err
+	}
+	return New(file, options), nil
+}
+
+// shortSource replaces the source of a JSON record with its file name and line
+func shortSource(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.SourceKey && len(groups) == 0 {
+		if source, ok := attr.Value.Any().(*slog.Source); ok {
+			return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", filepath.Base(source.File), source.Line))
+		}
+	}
+	return attr
 }
 
 // Global singleton logger instance
@@ -83,6 +230,7 @@ type customHandler struct {
 	level     slog.Level
 	addSource bool
 	w         io.Writer
+	attrs     []slog.Attr
 }
 
 // Enabled reports whether the handler handles records at the given level
@@ -115,12 +263,16 @@ func (h *customHandler) Handle(ctx context.Context, r slog.Record) error {
 
 	// Extract attributes for structured logging
 	attrs := make([]string, 0)
-	r.Attrs(func(attr slog.Attr) bool {
+	add := func(attr slog.Attr) bool {
 		if attr.Key != "" && attr.Value.String() != "" {
 			attrs = append(attrs, fmt.Sprintf("%s=%s", attr.Key, attr.Value.String()))
 		}
 		return true
-	})
+	}
+	for _, attr := range h.attrs {
+		add(attr)
+	}
+	r.Attrs(add)
 
 	// Build the log message
 	messageWithAttrs := r.Message
@@ -143,8 +295,9 @@ func (h *customHandler) Handle(ctx context.Context, r slog.Record) error {
 // WithAttrs returns a new handler whose attributes consist of h's attributes
 // followed by attrs
 func (h *customHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	// For simplicity, we're ignoring attributes in this implementation
-	return h
+	handler := *h
+	handler.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &handler
 }
 
 // WithGroup returns a new handler with the given group appended to the receiver's
@@ -154,7 +307,7 @@ func (h *customHandler) WithGroup(name string) slog.Handler {
 	return h
 }
 
-// File creates a new file logger
+// File creates a new file logger, without rotation
 func File(filename string, append bool) *Logger {
 	var flag int
 	if append {
@@ -166,60 +319,147 @@ func File(filename string, append bool) *Logger {
 	file, err := os.OpenFile(filename, flag, 0666)
 	if err != nil {
 		// Fall back to stderr if file can't be opened
-		return &Logger{
-			Logger: slog.New(&customHandler{
-				level:     slog.LevelInfo,
-				addSource: true,
-				w:         os.Stderr,
-			}),
-			writer: os.Stderr,
-		}
+		return New(os.Stderr, Options{Format: FormatText, Level: LevelInfo})
 	}
+	return New(file, Options{Format: FormatText, Level: LevelDebug})
+}
+
+// Console creates a logger that writes to stderr
+func Console() *Logger {
+	return New(os.Stderr, Options{Format: FormatText, Level: LevelInfo})
+}
 
-	handler := &customHandler{
-		level:     slog.LevelDebug,
-		addSource: true,
-		w:         file,
+// Component returns the logger of a component: its records carry the component's name and
+// are filtered by the component's level. Component loggers share the levels and output of
+// the logger they come from.
+func (l *Logger) Component(name string) *Logger {
+	if l.shared == nil || name == "" {
+		return l
 	}
+	l.shared.mu.Lock()
+	defer l.shared.mu.Unlock()
+	if logger, ok := l.shared.loggers[name]; ok {
+		return logger
+	}
+	logger := &Logger{
+		Logger:    slog.New(&levelHandler{Handler: l.base.WithAttrs([]slog.Attr{slog.String(ComponentKey, name)}), component: name, shared: l.shared}),
+		writer:    l.writer,
+		base:      l.base,
+		component: name,
+		shared:    l.shared,
+	}
+	l.shared.loggers[name] = logger
+	return logger
+}
 
-	return &Logger{
-		Logger: slog.New(handler),
-		writer: file,
+// Level returns the level of the components without a level of their own
+func (l *Logger) Level() LogLevel {
+	if l.shared == nil {
+		return LevelDebug
 	}
+	return l.shared.levelOf("")
 }
 
-// Console creates a logger that writes to stderr
-func Console() *Logger {
-	handler := &customHandler{
-		level:     slog.LevelInfo,
-		addSource: true,
-		w:         os.Stderr,
+// SetLevel sets the level of the components without a level of their own
+func (l *Logger) SetLevel(level LogLevel) {
+	if l.shared == nil {
+		return
 	}
+	l.shared.mu.Lock()
+	defer l.shared.mu.Unlock()
+	l.shared.level = level
+}
 
-	return &Logger{
-		Logger: slog.New(handler),
-		writer: os.Stderr,
+// ComponentLevel returns the level of a component
+func (l *Logger) ComponentLevel(component string) LogLevel {
+	if l.shared == nil {
+		return LevelDebug
+	}
+	return l.shared.levelOf(component)
+}
+
+// SetComponentLevel sets the level of a component, overriding the level of the logger
+func (l *Logger) SetComponentLevel(component string, level LogLevel) {
+	if l.shared == nil || component == "" {
+		return
+	}
+	l.shared.mu.Lock()
+	defer l.shared.mu.Unlock()
+	l.shared.components[component] = level
+}
+
+// ResetComponentLevel removes the level of a component, which then logs at the level of
+// the logger
+func (l *Logger) ResetComponentLevel(component string) {
+	if l.shared == nil {
+		return
+	}
+	l.shared.mu.Lock()
+	defer l.shared.mu.Unlock()
+	delete(l.shared.components, component)
+}
+
+// SetLevels sets the level of the logger and replaces the levels of the components, as
+// when the configuration is reloaded
+func (l *Logger) SetLevels(level LogLevel, components map[string]LogLevel) {
+	if l.shared == nil {
+		return
+	}
+	l.shared.mu.Lock()
+	defer l.shared.mu.Unlock()
+	l.shared.level = level
+	l.shared.components = make(map[string]LogLevel, len(components))
+	for component, componentLevel := range components {
+		l.shared.components[component] = componentLevel
+	}
+}
+
+// ComponentLevels returns the components with a level of their own and their levels
+func (l *Logger) ComponentLevels() map[string]LogLevel {
+	levels := make(map[string]LogLevel)
+	if l.shared == nil {
+		return levels
+	}
+	l.shared.mu.RLock()
+	defer l.shared.mu.RUnlock()
+	for component, level := range l.shared.components {
+		levels[component] = level
 	}
+	return levels
 }
 
 // Debug logs a debug message
 func (l *Logger) Debug(msg string, args ...interface{}) {
-	l.Logger.Debug(msg, args...)
+	l.log(slog.LevelDebug, msg, args...)
 }
 
 // Info logs an info message
 func (l *Logger) Info(msg string, args ...interface{}) {
-	l.Logger.Info(msg, args...)
+	l.log(slog.LevelInfo, msg, args...)
 }
 
 // Warn logs a warning message
 func (l *Logger) Warn(msg string, args ...interface{}) {
-	l.Logger.Warn(msg, args...)
+	l.log(slog.LevelWarn, msg, args...)
 }
 
 // Error logs an error message
 func (l *Logger) Error(msg string, args ...interface{}) {
-	l.Logger.Error(msg, args...)
+	l.log(slog.LevelError, msg, args...)
+}
+
+// log logs a message with the source of the caller of Debug, Info, Warn or Error, rather
+// than of this file
+func (l *Logger) log(level slog.Level, msg string, args ...interface{}) {
+	ctx := context.Background()
+	if !l.Logger.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // Skip Callers, log and the level method
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(args...)
+	_ = l.Logger.Handler().Handle(ctx, r)
 }
 
 // Close closes the logger if needed (e.g., file handle)
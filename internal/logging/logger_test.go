@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLoggerTextFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, DefaultOptions())
+
+	logger.Component("agent").Info("Processing chat message", "sender", "alice")
+
+	line := buf.String()
+	if !strings.Contains(line, " INFO logger_test.go:") {
+		t.Errorf("Expected the level and the caller's source, got %q", line)
+	}
+	if !strings.Contains(line, "Processing chat message [component=agent, sender=alice]") {
+		t.Errorf("Expected the component and attributes, got %q", line)
+	}
+}
+
+func TestLoggerJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	options := DefaultOptions()
+	options.Format = FormatJSON
+	logger := New(&buf, options)
+
+	logger.Component("llm").Warn("Model failed", "attempt", 2)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON line, got %q: %v", buf.String(), err)
+	}
+	if record["level"] != "WARN" || record["msg"] != "Model failed" || record[ComponentKey] != "llm" || record["attempt"] != float64(2) {
+		t.Errorf("Unexpected record %v", record)
+	}
+	if source, _ := record["source"].(string); !strings.HasPrefix(source, "logger_test.go:") {
+		t.Errorf("Expected the caller's source, got %v", record["source"])
+	}
+}
+
+func TestLoggerComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	options := DefaultOptions()
+	options.Level = LevelInfo
+	options.Components = map[string]LogLevel{"llm": LevelDebug}
+	logger := New(&buf, options)
+	llm, bus := logger.Component("llm"), logger.Component("messaging")
+
+	llm.Debug("llm debug")
+	bus.Debug("bus debug")
+	bus.Info("bus info")
+	if out := buf.String(); !strings.Contains(out, "llm debug") || strings.Contains(out, "bus debug") || !strings.Contains(out, "bus info") {
+		t.Errorf("Expected the levels of the options to apply, got %q", out)
+	}
+
+	// Levels changed at runtime apply to the component loggers already handed out
+	buf.Reset()
+	logger.SetComponentLevel("messaging", LevelDebug)
+	logger.SetLevel(LevelError)
+	llm.Info("llm info")
+	bus.Debug("bus debug")
+	logger.Info("root info")
+	if out := buf.String(); !strings.Contains(out, "llm info") || !strings.Contains(out, "bus debug") || strings.Contains(out, "root info") {
+		t.Errorf("Expected the changed levels to apply, got %q", out)
+	}
+
+	logger.ResetComponentLevel("messaging")
+	if level := bus.ComponentLevel("messaging"); level != LevelError {
+		t.Errorf("Expected the logger's level after the reset, got %s", level)
+	}
+	if levels := logger.ComponentLevels(); len(levels) != 1 || levels["llm"] != LevelDebug {
+		t.Errorf("Unexpected component levels %v", levels)
+	}
+	if logger.Component("llm") != llm {
+		t.Error("Expected the component logger to be reused")
+	}
+
+	logger.SetLevels(LevelWarn, map[string]LogLevel{"messaging": LevelDebug})
+	if llm.ComponentLevel("llm") != LevelWarn || logger.ComponentLevel("messaging") != LevelDebug || len(logger.ComponentLevels()) != 1 {
+		t.Errorf("Expected the levels to be replaced, got %s and %v", logger.Level(), logger.ComponentLevels())
+	}
+}
+
+func TestParseLevelAndFormat(t *testing.T) {
+	if level, err := ParseLevel("Warning"); err != nil || level != LevelWarn {
+		t.Errorf("Expected warn, got %s, %v", level, err)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected an unknown level to be rejected")
+	}
+	if format, err := ParseFormat("JSON"); err != nil || format != FormatJSON {
+		t.Errorf("Expected json, got %s, %v", format, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,191 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat stamps the name of a rotated log file; it sorts in time order and
+// has no characters that are invalid in file names
+const backupTimeFormat = "20060102T150405.000"
+
+// RotationOptions bounds the size of a log file and of its rotated backups
+type RotationOptions struct {
+	MaxSize    int64         // Size in bytes at which the file is rotated; 0 never rotates
+	MaxAge     time.Duration // Age after which backups are removed; 0 keeps them regardless of age
+	MaxBackups int           // Number of backups kept; 0 keeps them all
+}
+
+// DefaultRotationOptions returns the default rotation: 10 MB files, with the last 5
+// backups of the past week kept
+func DefaultRotationOptions() RotationOptions {
+	return RotationOptions{
+		MaxSize:    10 * 1024 * 1024,
+		MaxAge:     7 * 24 * time.Hour,
+		MaxBackups: 5,
+	}
+}
+
+// RotatingFile is a log file that is renamed to a timestamped backup, e.g.
+// app-20250102T150405.000.log, when a write would take it past its maximum size. Backups
+// past the maximum age or count are removed.
+type RotatingFile struct {
+	path    string
+	options RotationOptions
+	now     func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens the log file at the path for appending, creating it if needed
+func OpenRotatingFile(path string, options RotationOptions) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, options: options, now: time.Now}
+	if err := r.openLocked(); err != nil {
+		return nil, err
+	}
+	r.pruneLocked()
+	return r, nil
+}
+
+// Write appends to the file, rotating it first if the write would take it past its
+// maximum size. A write larger than the maximum size goes to a file of its own.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.options.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.options.MaxSize {
+		if err := r.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate renames the file to a backup and starts a new one
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.rotateLocked()
+}
+
+// Close closes the file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// openLocked opens the file for appending and records its size
+func (r *RotatingFile) openLocked() error {
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+// rotateLocked renames the file to a backup, opens a new one and removes the backups no
+// longer kept
+func (r *RotatingFile) rotateLocked() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	r.file = nil
+	if err := os.Rename(r.path, r.backupName(r.now())); err != nil && !os.IsNotExist(err) {
+		// Keep writing to the same file rather than losing the log
+		if openErr := r.openLocked(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := r.openLocked(); err != nil {
+		return err
+	}
+	r.pruneLocked()
+	return nil
+}
+
+// backupName returns the name of the backup of the file rotated at the time
+func (r *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(r.path)
+	base := strings.TrimSuffix(r.path, ext)
+	return base + "-" + t.Format(backupTimeFormat) + ext
+}
+
+// backup is a rotated log file
+type backup struct {
+	path    string
+	rotated time.Time
+}
+
+// backups returns the backups of the file, newest first
+func (r *RotatingFile) backups() []backup {
+	dir := filepath.Dir(r.path)
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(filepath.Base(r.path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		rotated, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, name), rotated: rotated})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].rotated.After(backups[j].rotated)
+	})
+	return backups
+}
+
+// pruneLocked removes the backups past the maximum count or age
+func (r *RotatingFile) pruneLocked() {
+	if r.options.MaxBackups <= 0 && r.options.MaxAge <= 0 {
+		return
+	}
+	cutoff := r.now().Add(-r.options.MaxAge)
+	for i, b := range r.backups() {
+		tooMany := r.options.MaxBackups > 0 && i >= r.options.MaxBackups
+		tooOld := r.options.MaxAge > 0 && b.rotated.Before(cutoff)
+		if tooMany || tooOld {
+			os.Remove(b.path)
+		}
+	}
+}
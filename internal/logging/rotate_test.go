@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileRotatesAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	file, err := OpenRotatingFile(path, RotationOptions{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer file.Close()
+	now := time.Date(2025, 1, 2, 15, 4, 5, 0, time.Local)
+	file.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+
+	current, _ := os.ReadFile(path)
+	if string(current) != "fourth\n" {
+		t.Errorf("Expected the last line in the current file, got %q", current)
+	}
+	backups := file.backups()
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups to be kept, got %v", backups)
+	}
+	newest, _ := os.ReadFile(backups[0].path)
+	if string(newest) != "third\n" || !strings.HasPrefix(filepath.Base(backups[0].path), "app-20250102T1504") {
+		t.Errorf("Unexpected newest backup %s: %q", backups[0].path, newest)
+	}
+}
+
+func TestRotatingFileRemovesOldBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	now := time.Now()
+	old := filepath.Join(dir, "app-"+now.Add(-48*time.Hour).Format(backupTimeFormat)+".log")
+	recent := filepath.Join(dir, "app-"+now.Add(-time.Hour).Format(backupTimeFormat)+".log")
+	unrelated := filepath.Join(dir, "app-notes.log")
+	for _, name := range []string{old, recent, unrelated} {
+		if err := os.WriteFile(name, []byte("log\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := OpenRotatingFile(path, RotationOptions{MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("Expected the old backup to be removed")
+	}
+	for _, name := range []string{recent, unrelated} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("Expected %s to be kept: %v", name, err)
+		}
+	}
+}
+
+func TestOpenWritesToRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	options := DefaultOptions()
+	options.Format = FormatJSON
+	logger, err := Open(path, options)
+	if err != nil {
+		t.Fatalf("Failed to open logger: %v", err)
+	}
+	logger.Info("Application started")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close logger: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "{") || !strings.Contains(string(data), `"msg":"Application started"`) {
+		t.Errorf("Expected a JSON line, got %q", data)
+	}
+}
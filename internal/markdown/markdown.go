@@ -0,0 +1,124 @@
+// Package markdown renders the markdown of agent replies for a terminal with glamour:
+// headings, emphasis, lists, block quotes, tables and code fences with syntax
+// highlighting. Output that is not a terminal gets the text unchanged, as markdown reads
+// well as plain text.
+package markdown
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/muesli/termenv"
+)
+
+// Renderer formats markdown for a terminal. The zero value is not usable, use New.
+type Renderer struct {
+	color    bool   // Format with ANSI escape sequences rather than return the text as is
+	style    string // Name of the glamour style, e.g. "dark" or "light"
+	wordWrap int    // Column replies are wrapped at, 0 to leave lines as they are
+	term     *glamour.TermRenderer
+}
+
+// Option is a function that configures a Renderer
+type Option func(*Renderer)
+
+// WithColor formats the output for a terminal when enabled, and leaves the text as it is
+// otherwise
+func WithColor(enabled bool) Option {
+	return func(r *Renderer) {
+		r.color = enabled
+	}
+}
+
+// WithStyle selects a glamour style by name, "dark" by default; unknown names keep it
+func WithStyle(name string) Option {
+	return func(r *Renderer) {
+		if _, ok := styles.DefaultStyles[name]; ok {
+			r.style = name
+		}
+	}
+}
+
+// WithWordWrap wraps replies at the column, 80 by default; 0 leaves lines as they are
+func WithWordWrap(width int) Option {
+	return func(r *Renderer) {
+		if width >= 0 {
+			r.wordWrap = width
+		}
+	}
+}
+
+// New creates a renderer; without options it returns text unchanged
+func New(opts ...Option) *Renderer {
+	r := &Renderer{style: styles.DarkStyle, wordWrap: 80}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.color {
+		term, err := newTermRenderer(r.style, r.wordWrap)
+		if err != nil {
+			// Styles are built in, so this is a programming error; fall back to plain text
+			fmt.Fprintf(os.Stderr, "markdown: %v\n", err)
+			r.color = false
+		}
+		r.term = term
+	}
+	return r
+}
+
+// newTermRenderer creates the glamour renderer of a style. Replies follow the speaker's
+// name on the same line, so the document has no margin or surrounding blank lines.
+func newTermRenderer(style string, wordWrap int) (*glamour.TermRenderer, error) {
+	config := *styles.DefaultStyles[style]
+	config.Document = ansi.StyleBlock{StylePrimitive: config.Document.StylePrimitive}
+	config.Document.BlockPrefix, config.Document.BlockSuffix = "", ""
+
+	// The caller found a terminal that accepts colors, if not which ones
+	profile := termenv.EnvColorProfile()
+	if profile == termenv.Ascii {
+		profile = termenv.ANSI
+	}
+	return glamour.NewTermRenderer(
+		glamour.WithStyles(config),
+		glamour.WithColorProfile(profile),
+		glamour.WithWordWrap(wordWrap),
+	)
+}
+
+// IsTerminal reports whether the writer is a terminal that accepts colors: a character
+// device, with neither NO_COLOR set nor TERM=dumb
+func IsTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Render returns the markdown formatted for a terminal, or unchanged if the renderer has
+// no color or fails to render it
+func (r *Renderer) Render(text string) string {
+	if r == nil || !r.color {
+		return text
+	}
+	rendered, err := r.term.Render(text)
+	if err != nil {
+		return text
+	}
+	return strings.Trim(rendered, "\n")
+}
@@ -0,0 +1,119 @@
+package markdown
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// ansiPattern matches the escape sequences of the renderer
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// visible returns the lines of the text as a terminal shows them, without the padding
+// to the wrap column
+func visible(text string) []string {
+	lines := strings.Split(ansiPattern.ReplaceAllString(text, ""), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return lines
+}
+
+const reply = "# Release plan\n" +
+	"Ship **beta** to _design partners_, see [the brief](https://example.com/brief).\n\n" +
+	"- Keep `snake_case` names\n\n" +
+	"> Quality over dates\n\n" +
+	"| Owner | Task |\n" +
+	"|:------|-----:|\n" +
+	"| Ana | **QA** |\n" +
+	"| Bartholomew | Docs |\n\n" +
+	"```go\n" +
+	"func main() { fmt.Println(\"hi\", 42) } // entry\n" +
+	"```\n\n" +
+	"Done."
+
+func TestRenderPlain(t *testing.T) {
+	if got := New().Render(reply); got != reply {
+		t.Errorf("Expected the text unchanged without color, got:\n%s", got)
+	}
+	var r *Renderer
+	if got := r.Render("**hi**"); got != "**hi**" {
+		t.Errorf("Expected a nil renderer to leave text unchanged, got %q", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	got := New(WithColor(true), WithWordWrap(60)).Render(reply)
+	lines := visible(got)
+	if strings.HasPrefix(got, "\n") || strings.HasSuffix(got, "\n") {
+		t.Errorf("Expected no blank lines around the reply, got %q", got)
+	}
+
+	text := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"Release plan",
+		"Ship beta to design partners, see the brief",
+		"https://example.com/brief",
+		"• Keep  snake_case  names",
+		"│ Quality over dates",
+		`func main() { fmt.Println("hi", 42) } // entry`,
+		"Done.",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in the output:\n%s", want, text)
+		}
+	}
+	if strings.ContainsAny(text, "*`#") {
+		t.Errorf("Expected the markdown markers to be formatted away:\n%s", text)
+	}
+
+	// The table's columns are aligned
+	var separators []int
+	for _, line := range lines {
+		if strings.Contains(line, "Ana") || strings.Contains(line, "Bartholomew") {
+			separators = append(separators, strings.Index(line, "│"))
+		}
+	}
+	if len(separators) != 2 || separators[0] < 0 || separators[0] != separators[1] {
+		t.Errorf("Expected aligned table columns:\n%s", text)
+	}
+
+	// Code is highlighted: the keyword and the string are styled differently
+	keyword := regexp.MustCompile("\x1b\\[([0-9;]+)mfunc\x1b").FindStringSubmatch(got)
+	str := regexp.MustCompile("\x1b\\[([0-9;]+)m\"hi\"\x1b").FindStringSubmatch(got)
+	if keyword == nil || str == nil || keyword[1] == str[1] {
+		t.Errorf("Expected highlighted code, got %q", got)
+	}
+	for _, line := range lines {
+		if len([]rune(line)) > 60 {
+			t.Errorf("Expected lines wrapped at 60 columns, got %q", line)
+		}
+	}
+}
+
+func TestRenderStyles(t *testing.T) {
+	dark := New(WithColor(true)).Render(reply)
+	light := New(WithColor(true), WithStyle("light")).Render(reply)
+	if dark == light {
+		t.Error("Expected the light style to differ from the dark one")
+	}
+	if r := New(WithColor(true), WithStyle("neon")); r.style != "dark" {
+		t.Errorf("Expected an unknown style to keep the dark one, got %q", r.style)
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	if IsTerminal(&bytes.Buffer{}) {
+		t.Error("Expected a buffer not to be a terminal")
+	}
+	file, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	defer file.Close()
+	if IsTerminal(file) {
+		t.Error("Expected a regular file not to be a terminal")
+	}
+}
@@ -1,6 +1,9 @@
 package messaging
 
 import (
+	"context"
+	"time"
+
 	"goproduct/internal/tracing"
 )
 
@@ -12,18 +15,57 @@ type MessageBus interface {
 	// Publish a message to its recipients
 	Publish(message Message) error
 
-	// Subscribe to receive messages for an entity
-	Subscribe(entityID string, handler MessageHandler) error
+	// Request publishes a message and waits for its reply, see Request
+	Request(ctx context.Context, message Message) (Message, error)
+
+	// GetHistory returns the recent messages of an entity to backfill its context, see
+	// MemoryMessageBus.GetHistory
+	GetHistory(entityID string, since time.Time, limit int) ([]Message, error)
+
+	// Subscribe to receive messages for an entity, optionally only those matching any of the filters
+	Subscribe(entityID string, handler MessageHandler, filters ...SubscriptionFilter) error
 
 	// Unsubscribe entity from receiving messages
 	Unsubscribe(entityID string) error
 
+	// Topic subscriptions; publish to a topic with TopicAddress or NewTopicMessage
+	SubscribeTopic(entityID, pattern string) error
+	UnsubscribeTopic(entityID, pattern string) error
+
+	// Pattern subscriptions observe or stand in for the entities matching a glob or
+	// regular expression, see PatternMode for how they rank against Subscribe
+	SubscribePattern(pattern string, mode PatternMode, handler MessageHandler, filters ...SubscriptionFilter) error
+	UnsubscribePattern(pattern string) error
+
 	// Group management
 	CreateGroup(groupID, name string, members []string) error
 	AddToGroup(groupID, entityID string) error
 	RemoveFromGroup(groupID, entityID string) error
 	GetGroupMembers(groupID string) ([]string, error)
 
+	// Group administration; roles are recorded, callers enforce them with Group.Role
+	RemoveGroup(groupID string) error
+	RenameGroup(groupID, name string) error
+	GetGroup(groupID string) (Group, error)
+	ListGroups() []Group
+	SetMemberRole(groupID, entityID string, role GroupRole) error
+	SetMemberMetadata(groupID, entityID string, metadata map[string]string) error
+
+	// Use adds middleware wrapping every delivery, see Middleware
+	Use(middlewares ...Middleware)
+
+	// SetDeliveryMode chooses between concurrent and per-recipient ordered handling
+	SetDeliveryMode(mode DeliveryMode)
+
+	// Delivery of messages that requested acknowledgement, see Message.WithAck
+	SetRetryPolicy(policy RetryPolicy)
+	DeliveryStatus(messageID string) (DeliveryStatus, error)
+
+	// Dead letters: messages whose handler failed, kept for inspection and recovery
+	GetDeadLetters() []DeadLetter
+	ReplayDeadLetter(deadLetterID string) error
+	PurgeDeadLetters(deadLetterIDs ...string) int
+
 	// Tracer management
 	SetTracer(tracer tracing.Tracer)
 	GetTracer() tracing.Tracer
@@ -0,0 +1,115 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultDeadLetterCapacity is the number of dead letters a bus keeps; older ones are
+// dropped first
+const DefaultDeadLetterCapacity = 1000
+
+// ErrUnknownDeadLetter is returned for dead letter IDs that are not in the queue
+var ErrUnknownDeadLetter = errors.New("unknown dead letter")
+
+// DeadLetter is a message whose handling failed for one recipient: the handler returned
+// an error or panicked on its only attempt, or on every attempt its retry policy allowed
+type DeadLetter struct {
+	ID          string // Identifies the dead letter for replay and purge
+	Message     Message
+	RecipientID string
+	Attempts    int
+	LastError   string
+	FailedAt    time.Time
+}
+
+// deadLetterQueue keeps the dead letters of a bus, oldest first
+type deadLetterQueue struct {
+	mu       sync.Mutex
+	letters  []DeadLetter
+	capacity int
+}
+
+// newDeadLetterQueue creates a queue holding up to DefaultDeadLetterCapacity letters
+func newDeadLetterQueue() *deadLetterQueue {
+	return &deadLetterQueue{capacity: DefaultDeadLetterCapacity}
+}
+
+// add queues a failed delivery
+func (q *deadLetterQueue) add(msg Message, recipientID string, attempts int, err error) {
+	letter := DeadLetter{
+		ID:          uuid.New().String(),
+		Message:     msg,
+		RecipientID: recipientID,
+		Attempts:    attempts,
+		FailedAt:    time.Now(),
+	}
+	if err != nil {
+		letter.LastError = err.Error()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.letters = append(q.letters, letter)
+	if len(q.letters) > q.capacity {
+		q.letters = q.letters[len(q.letters)-q.capacity:]
+	}
+}
+
+// list returns a copy of the queue
+func (q *deadLetterQueue) list() []DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]DeadLetter(nil), q.letters...)
+}
+
+// take removes a letter from the queue and returns it
+func (q *deadLetterQueue) take(id string) (DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, letter := range q.letters {
+		if letter.ID == id {
+			q.letters = append(q.letters[:i], q.letters[i+1:]...)
+			return letter, nil
+		}
+	}
+	return DeadLetter{}, fmt.Errorf("%w: %s", ErrUnknownDeadLetter, id)
+}
+
+// restore puts a letter taken from the queue back at its place by failure time
+func (q *deadLetterQueue) restore(letter DeadLetter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := len(q.letters)
+	for i > 0 && q.letters[i-1].FailedAt.After(letter.FailedAt) {
+		i--
+	}
+	q.letters = append(q.letters, DeadLetter{})
+	copy(q.letters[i+1:], q.letters[i:])
+	q.letters[i] = letter
+}
+
+// purge removes the letters with the given IDs, or all letters if none are given, and
+// returns how many were removed
+func (q *deadLetterQueue) purge(ids ...string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(ids) == 0 {
+		removed := len(q.letters)
+		q.letters = nil
+		return removed
+	}
+	kept := q.letters[:0]
+	for _, letter := range q.letters {
+		if !containsString(ids, letter.ID) {
+			kept = append(kept, letter)
+		}
+	}
+	removed := len(q.letters) - len(kept)
+	q.letters = kept
+	return removed
+}
@@ -0,0 +1,209 @@
+package messaging
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForDeadLetters waits until the bus holds n dead letters and returns them
+func waitForDeadLetters(t *testing.T, bus MessageBus, n int) []DeadLetter {
+	t.Helper()
+	var letters []DeadLetter
+	require.Eventually(t, func() bool {
+		letters = bus.GetDeadLetters()
+		return len(letters) == n
+	}, 2*time.Second, 5*time.Millisecond)
+	return letters
+}
+
+func TestFailedHandlerBecomesDeadLetter(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.Subscribe("bob", func(msg Message) error { return errors.New("disk full") })
+	bus.Subscribe("carol", func(msg Message) error { panic("handler bug") })
+
+	msg := NewTextMessage("alice", []string{"bob", "carol"}, "hello")
+	require.NoError(t, bus.Publish(msg))
+
+	letters := waitForDeadLetters(t, bus, 2)
+	errs := map[string]string{}
+	for _, letter := range letters {
+		assert.NotEmpty(t, letter.ID)
+		assert.Equal(t, msg.ID, letter.Message.ID)
+		assert.Equal(t, 1, letter.Attempts)
+		assert.False(t, letter.FailedAt.IsZero())
+		errs[letter.RecipientID] = letter.LastError
+	}
+	assert.Equal(t, "disk full", errs["bob"])
+	assert.Contains(t, errs["carol"], "handler bug")
+}
+
+func TestAcknowledgedDeliveryDeadLetteredAfterRetries(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetRetryPolicy(fastRetries)
+	bus.Subscribe("bob", func(msg Message) error { return errors.New("busy") })
+
+	msg := NewTextMessage("alice", []string{"bob"}, "hello").WithAck()
+	require.NoError(t, bus.Publish(msg))
+	waitForDelivery(t, bus, msg.ID)
+
+	letters := waitForDeadLetters(t, bus, 1)
+	assert.Equal(t, "bob", letters[0].RecipientID)
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.Equal(t, "busy", letters[0].LastError)
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	var healthy atomic.Bool
+	received := make(chan Message, 1)
+	bus.Subscribe("bob", func(msg Message) error {
+		if !healthy.Load() {
+			return errors.New("database down")
+		}
+		received <- msg
+		return nil
+	})
+
+	msg := NewTextMessage("alice", []string{"bob"}, "hello")
+	require.NoError(t, bus.Publish(msg))
+	letters := waitForDeadLetters(t, bus, 1)
+
+	healthy.Store(true)
+	require.NoError(t, bus.ReplayDeadLetter(letters[0].ID))
+	select {
+	case got := <-received:
+		assert.Equal(t, msg.ID, got.ID)
+	case <-time.After(time.Second):
+		t.Fatal("replayed message was not delivered")
+	}
+	assert.Empty(t, bus.GetDeadLetters())
+
+	err := bus.ReplayDeadLetter(letters[0].ID)
+	assert.ErrorIs(t, err, ErrUnknownDeadLetter)
+}
+
+func TestReplayDeadLetterFailsAgain(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.Subscribe("bob", func(msg Message) error { return errors.New("still broken") })
+
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "hello")))
+	first := waitForDeadLetters(t, bus, 1)[0]
+
+	require.NoError(t, bus.ReplayDeadLetter(first.ID))
+	again := waitForDeadLetters(t, bus, 1)[0]
+	assert.NotEqual(t, first.ID, again.ID)
+	assert.Equal(t, first.Message.ID, again.Message.ID)
+}
+
+func TestReplayDeadLetterWithoutSubscriber(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.Subscribe("bob", func(msg Message) error { return errors.New("broken") })
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "hello")))
+	letter := waitForDeadLetters(t, bus, 1)[0]
+
+	bus.Unsubscribe("bob")
+	assert.Error(t, bus.ReplayDeadLetter(letter.ID))
+	assert.Equal(t, []DeadLetter{letter}, bus.GetDeadLetters(), "letter should stay queued")
+}
+
+func TestReplayDeadLetterDoesNotHoldBusLock(t *testing.T) {
+	bus := NewMemoryMessageBus(WithQueueDepth(1), WithQueueWorkers(1))
+	var healthy atomic.Bool
+	handler := newRecordingHandler()
+	require.NoError(t, bus.Subscribe("bob", func(msg Message) error {
+		if !healthy.Load() {
+			return errors.New("broken")
+		}
+		return handler.handle(msg)
+	}))
+	require.NoError(t, publishText(bus, []string{"bob"}, "hello"))
+	letter := waitForDeadLetters(t, bus, 1)[0]
+
+	healthy.Store(true)
+	require.NoError(t, publishText(bus, []string{"bob"}, "first"))
+	<-handler.started
+	require.NoError(t, publishText(bus, []string{"bob"}, "second"))
+	replayed := make(chan error, 1)
+	go func() { replayed <- bus.ReplayDeadLetter(letter.ID) }()
+	select {
+	case <-replayed:
+		t.Fatal("ReplayDeadLetter should wait for space in the queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Writers, such as subscriptions, go on while the replay waits for space
+	subscribed := make(chan error, 1)
+	go func() { subscribed <- bus.Subscribe("carol", func(Message) error { return nil }) }()
+	select {
+	case err := <-subscribed:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Subscribe should not wait for the blocked replay")
+	}
+
+	close(handler.gate)
+	select {
+	case err := <-replayed:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("ReplayDeadLetter should return once the queue has space")
+	}
+	require.Eventually(t, func() bool { return len(handler.handled()) == 3 }, time.Second, 5*time.Millisecond)
+	assert.Empty(t, bus.GetDeadLetters())
+}
+
+func TestPurgeDeadLetters(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.Subscribe("bob", func(msg Message) error { return errors.New("broken") })
+	for i := 0; i < 3; i++ {
+		require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "hello")))
+	}
+	letters := waitForDeadLetters(t, bus, 3)
+
+	assert.Equal(t, 1, bus.PurgeDeadLetters(letters[0].ID, "missing"))
+	assert.Len(t, bus.GetDeadLetters(), 2)
+	assert.Equal(t, 2, bus.PurgeDeadLetters())
+	assert.Empty(t, bus.GetDeadLetters())
+}
+
+func TestDeadLetterQueueCapacity(t *testing.T) {
+	queue := newDeadLetterQueue()
+	queue.capacity = 2
+	for _, text := range []string{"one", "two", "three"} {
+		queue.add(NewTextMessage("alice", []string{"bob"}, text), "bob", 1, errors.New("broken"))
+	}
+
+	letters := queue.list()
+	require.Len(t, letters, 2)
+	assert.Equal(t, "two", string(letters[0].Message.Content))
+	assert.Equal(t, "three", string(letters[1].Message.Content))
+}
+
+func TestNatsMessageBusDeadLetters(t *testing.T) {
+	server := newFakeNats(t, "")
+	bus, err := NewNatsMessageBus(server.url())
+	require.NoError(t, err)
+	defer bus.Close()
+
+	var healthy atomic.Bool
+	received := make(chan Message, 1)
+	require.NoError(t, bus.Subscribe("bob", func(msg Message) error {
+		if !healthy.Load() {
+			return errors.New("broken")
+		}
+		received <- msg
+		return nil
+	}))
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "hello")))
+
+	letter := waitForDeadLetters(t, bus, 1)[0]
+	healthy.Store(true)
+	require.NoError(t, bus.ReplayDeadLetter(letter.ID))
+	assert.Equal(t, "hello", receiveText(t, received))
+	assert.Equal(t, 0, bus.PurgeDeadLetters())
+}
@@ -0,0 +1,221 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MetadataAck is the metadata key requesting acknowledged delivery of a message
+const MetadataAck = "ack"
+
+// ErrUnknownMessage is returned for delivery status queries of untracked messages
+var ErrUnknownMessage = errors.New("unknown message")
+
+// errNotSubscribed is the delivery error while a recipient has no subscription
+var errNotSubscribed = errors.New("recipient is not subscribed")
+
+// DefaultRetryPolicy retries a delivery five times, from 100ms up to 10s apart
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Multiplier:     2,
+}
+
+// maxTrackedDeliveries bounds the finished deliveries kept for status queries
+const maxTrackedDeliveries = 10000
+
+// RetryPolicy controls the redelivery of messages that requested acknowledgement
+type RetryPolicy struct {
+	MaxAttempts    int           // Attempts per recipient, including the first
+	InitialBackoff time.Duration // Pause before the first retry
+	MaxBackoff     time.Duration // Longest pause between retries
+	Multiplier     float64       // Growth of the pause after each retry
+}
+
+// backoff returns the pause after the given attempt, counting from 1
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		wait *= p.Multiplier
+	}
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(wait)
+}
+
+// DeliveryState is the state of a message delivery to one recipient
+type DeliveryState string
+
+// Delivery states
+const (
+	DeliveryPending DeliveryState = "pending" // Not acknowledged yet, retries remain
+	DeliveryAcked   DeliveryState = "acked"   // The handler returned without error
+	DeliveryFailed  DeliveryState = "failed"  // The retries are exhausted
+	DeliverySkipped DeliveryState = "skipped" // The recipient's filters declined the message
+	DeliveryExpired DeliveryState = "expired" // The message expired before it was acknowledged
+)
+
+// RecipientStatus is the delivery state of a message for one recipient
+type RecipientStatus struct {
+	State     DeliveryState
+	Attempts  int
+	LastError string // Error of the last failed attempt: a nack, a panic, or no subscription
+	UpdatedAt time.Time
+}
+
+// DeliveryStatus reports the delivery of a message that requested acknowledgement
+type DeliveryStatus struct {
+	MessageID  string
+	Recipients map[string]RecipientStatus
+}
+
+// Done checks if no recipient is still pending
+func (s DeliveryStatus) Done() bool {
+	for _, recipient := range s.Recipients {
+		if recipient.State == DeliveryPending {
+			return false
+		}
+	}
+	return true
+}
+
+// WithAck requests acknowledged delivery: the bus retries recipients whose handler
+// returns an error or panics, or who are not subscribed yet, according to its retry
+// policy, and tracks the outcome for DeliveryStatus
+func (m Message) WithAck() Message {
+	metadata := make(map[string]string, len(m.Metadata)+1)
+	for key, value := range m.Metadata {
+		metadata[key] = value
+	}
+	metadata[MetadataAck] = "true"
+	m.Metadata = metadata
+	return m
+}
+
+// AckRequested checks if the message requested acknowledged delivery
+func (m Message) AckRequested() bool {
+	return m.Metadata[MetadataAck] == "true"
+}
+
+// deliveryTracker runs acknowledged deliveries and keeps their status
+type deliveryTracker struct {
+	mu          sync.Mutex
+	policy      RetryPolicy
+	deadLetters *deadLetterQueue // Receives the deliveries that failed
+	statuses    map[string]*DeliveryStatus
+	finished    []string // Message IDs in the order their delivery finished, oldest first
+}
+
+// newDeliveryTracker creates a tracker with the default policy that queues failed
+// deliveries as dead letters
+func newDeliveryTracker(deadLetters *deadLetterQueue) *deliveryTracker {
+	return &deliveryTracker{policy: DefaultRetryPolicy, statuses: make(map[string]*DeliveryStatus), deadLetters: deadLetters}
+}
+
+// setPolicy replaces the retry policy for deliveries started from now on
+func (t *deliveryTracker) setPolicy(policy RetryPolicy) {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 1
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.policy = policy
+}
+
+// start tracks the delivery to one recipient as pending and returns the function that
+// runs it, making attempts until one succeeds or the policy gives up. attempt returns nil on
+// acknowledgement; errSkipped and errExpired end the delivery without one. A delivery that fails
+// becomes a dead letter.
+func (t *deliveryTracker) start(msg Message, recipientID string, attempt func() error) func() {
+	msgID := msg.ID
+	t.mu.Lock()
+	policy := t.policy
+	status, ok := t.statuses[msgID]
+	if !ok {
+		status = &DeliveryStatus{MessageID: msgID, Recipients: make(map[string]RecipientStatus)}
+		t.statuses[msgID] = status
+	}
+	status.Recipients[recipientID] = RecipientStatus{State: DeliveryPending, UpdatedAt: time.Now()}
+	t.mu.Unlock()
+
+	return func() {
+		for attempts := 1; ; attempts++ {
+			err := attempt()
+			state := DeliveryPending
+			switch {
+			case err == nil:
+				state = DeliveryAcked
+			case errors.Is(err, errSkipped):
+				state, err = DeliverySkipped, nil
+			case errors.Is(err, errExpired):
+				state = DeliveryExpired
+			case attempts >= policy.MaxAttempts:
+				state = DeliveryFailed
+			}
+			t.record(msgID, recipientID, state, attempts, err)
+			if state == DeliveryFailed {
+				t.deadLetters.add(msg, recipientID, attempts, err)
+			}
+			if state != DeliveryPending {
+				return
+			}
+			time.Sleep(policy.backoff(attempts))
+		}
+	}
+}
+
+// abandon records a delivery that never ran as failed
+func (t *deliveryTracker) abandon(msgID, recipientID string, err error) {
+	t.record(msgID, recipientID, DeliveryFailed, 0, err)
+}
+
+// errSkipped ends a delivery the recipient's filters declined
+var errSkipped = errors.New("declined by subscription filters")
+
+// record stores the outcome of an attempt
+func (t *deliveryTracker) record(msgID, recipientID string, state DeliveryState, attempts int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status, ok := t.statuses[msgID]
+	if !ok {
+		return
+	}
+	recipient := RecipientStatus{State: state, Attempts: attempts, UpdatedAt: time.Now()}
+	if err != nil {
+		recipient.LastError = err.Error()
+	}
+	status.Recipients[recipientID] = recipient
+
+	if status.Done() {
+		t.finished = append(t.finished, msgID)
+		for len(t.finished) > maxTrackedDeliveries {
+			oldest := t.finished[0]
+			t.finished = t.finished[1:]
+			if old, ok := t.statuses[oldest]; ok && old.Done() {
+				delete(t.statuses, oldest)
+			}
+		}
+	}
+}
+
+// status returns a copy of the delivery status of a message
+func (t *deliveryTracker) status(msgID string) (DeliveryStatus, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status, ok := t.statuses[msgID]
+	if !ok {
+		return DeliveryStatus{}, fmt.Errorf("%w: %s", ErrUnknownMessage, msgID)
+	}
+	copied := DeliveryStatus{MessageID: status.MessageID, Recipients: make(map[string]RecipientStatus, len(status.Recipients))}
+	for id, recipient := range status.Recipients {
+		copied.Recipients[id] = recipient
+	}
+	return copied, nil
+}
@@ -0,0 +1,129 @@
+package messaging
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastRetries keeps retry tests quick
+var fastRetries = RetryPolicy{MaxAttempts: 3, InitialBackoff: 5 * time.Millisecond, MaxBackoff: 20 * time.Millisecond, Multiplier: 2}
+
+// waitForDelivery waits until the delivery of the message is done and returns its status
+func waitForDelivery(t *testing.T, bus MessageBus, messageID string) DeliveryStatus {
+	t.Helper()
+	var status DeliveryStatus
+	require.Eventually(t, func() bool {
+		var err error
+		status, err = bus.DeliveryStatus(messageID)
+		return err == nil && status.Done()
+	}, 2*time.Second, 5*time.Millisecond)
+	return status
+}
+
+func TestDeliveryRetriesUntilAcknowledged(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetRetryPolicy(fastRetries)
+
+	var calls atomic.Int32
+	bus.Subscribe("bob", func(msg Message) error {
+		if calls.Add(1) < 3 {
+			return errors.New("busy")
+		}
+		return nil
+	})
+
+	msg := NewTextMessage("alice", []string{"bob"}, "hello").WithAck()
+	require.NoError(t, bus.Publish(msg))
+	status, err := bus.DeliveryStatus(msg.ID)
+	require.NoError(t, err, "status should be known as soon as Publish returns")
+
+	status = waitForDelivery(t, bus, msg.ID)
+	assert.Equal(t, DeliveryAcked, status.Recipients["bob"].State)
+	assert.Equal(t, 3, status.Recipients["bob"].Attempts)
+	assert.Empty(t, status.Recipients["bob"].LastError)
+}
+
+func TestDeliveryFailsAfterPolicyLimit(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetRetryPolicy(fastRetries)
+
+	var calls atomic.Int32
+	bus.Subscribe("bob", func(msg Message) error {
+		calls.Add(1)
+		panic("handler bug")
+	})
+	bus.Subscribe("carol", func(msg Message) error { return nil })
+	bus.CreateGroup("team", "Team", []string{"bob", "carol"})
+
+	msg := NewTextMessage("alice", []string{"team"}, "hello").WithAck()
+	require.NoError(t, bus.Publish(msg))
+
+	status := waitForDelivery(t, bus, msg.ID)
+	assert.Equal(t, DeliveryFailed, status.Recipients["bob"].State)
+	assert.Equal(t, 3, status.Recipients["bob"].Attempts)
+	assert.Contains(t, status.Recipients["bob"].LastError, "handler bug")
+	assert.Equal(t, DeliveryAcked, status.Recipients["carol"].State)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestDeliveryWaitsForSubscriber(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetRetryPolicy(RetryPolicy{MaxAttempts: 20, InitialBackoff: 5 * time.Millisecond, Multiplier: 1})
+
+	msg := NewTextMessage("alice", []string{"late"}, "hello").WithAck()
+	require.NoError(t, bus.Publish(msg))
+	time.Sleep(20 * time.Millisecond)
+
+	received := make(chan Message, 1)
+	bus.Subscribe("late", func(msg Message) error { received <- msg; return nil })
+	assert.Equal(t, "hello", receiveText(t, received))
+	assert.Equal(t, DeliveryAcked, waitForDelivery(t, bus, msg.ID).Recipients["late"].State)
+}
+
+func TestDeliverySkippedByFilters(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.Subscribe("bob", func(msg Message) error { return nil }, SubscriptionFilter{Topics: []string{"billing"}})
+
+	msg := NewTextMessage("alice", []string{"bob"}, "hello").WithAck()
+	require.NoError(t, bus.Publish(msg))
+	status := waitForDelivery(t, bus, msg.ID)
+	assert.Equal(t, DeliverySkipped, status.Recipients["bob"].State)
+	assert.Equal(t, 1, status.Recipients["bob"].Attempts)
+}
+
+func TestDeliveryWithoutAckIsUntracked(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetRetryPolicy(fastRetries)
+
+	var calls atomic.Int32
+	bus.Subscribe("bob", func(msg Message) error {
+		calls.Add(1)
+		return errors.New("busy")
+	})
+	msg := NewTextMessage("alice", []string{"bob"}, "hello")
+	require.NoError(t, bus.Publish(msg))
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Equal(t, int32(1), calls.Load(), "messages without ack are attempted once")
+	_, err := bus.DeliveryStatus(msg.ID)
+	assert.ErrorIs(t, err, ErrUnknownMessage)
+	assert.False(t, msg.AckRequested())
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	assert.Equal(t, 100*time.Millisecond, DefaultRetryPolicy.backoff(1))
+	assert.Equal(t, 400*time.Millisecond, DefaultRetryPolicy.backoff(3))
+	assert.Equal(t, 10*time.Second, DefaultRetryPolicy.backoff(20))
+}
+
+func TestWithAckCopiesMetadata(t *testing.T) {
+	msg := NewTextMessage("alice", []string{"bob"}, "hello")
+	acked := msg.WithAck()
+	assert.True(t, acked.AckRequested())
+	assert.False(t, msg.AckRequested(), "the original message should be unchanged")
+}
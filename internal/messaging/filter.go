@@ -0,0 +1,68 @@
+package messaging
+
+// MetadataTopic is the metadata key holding the topic of a message
+const MetadataTopic = "topic"
+
+// SubscriptionFilter selects the messages a subscriber wants to receive. The bus
+// evaluates filters before dispatching, so discarded traffic never reaches the handler.
+// All non-empty fields must match; an empty filter matches every message.
+type SubscriptionFilter struct {
+	SenderIDs    []string           // Accept only messages from these senders
+	ContentTypes []string           // Accept only these content types
+	Topics       []string           // Accept only messages whose "topic" metadata is one of these
+	Metadata     map[string]string  // Required metadata values; "*" only requires the key to be present
+	Predicate    func(Message) bool // Custom check run after the other fields matched
+}
+
+// Matches checks if a message passes the filter
+func (f SubscriptionFilter) Matches(msg Message) bool {
+	if len(f.SenderIDs) > 0 && !containsString(f.SenderIDs, msg.SenderID) {
+		return false
+	}
+	if len(f.ContentTypes) > 0 && !containsString(f.ContentTypes, msg.ContentType) {
+		return false
+	}
+	if len(f.Topics) > 0 && !containsString(f.Topics, msg.Metadata[MetadataTopic]) {
+		return false
+	}
+	for key, want := range f.Metadata {
+		value, exists := msg.Metadata[key]
+		if !exists || (want != "*" && value != want) {
+			return false
+		}
+	}
+	if f.Predicate != nil && !f.Predicate(msg) {
+		return false
+	}
+	return true
+}
+
+// subscription is a registered handler with its filters
+type subscription struct {
+	handler MessageHandler
+	filters []SubscriptionFilter
+}
+
+// accepts checks if the message passes any of the subscription's filters.
+// A subscription without filters accepts everything.
+func (s subscription) accepts(msg Message) bool {
+	if len(s.filters) == 0 {
+		return true
+	}
+	for _, filter := range s.filters {
+		if filter.Matches(msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString checks if a slice contains a value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
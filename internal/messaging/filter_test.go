@@ -0,0 +1,83 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionFilterMatches(t *testing.T) {
+	msg := NewTextMessage("alice", []string{"bob"}, "hello")
+	msg.Metadata[MetadataTopic] = "roadmap"
+	msg.Metadata["priority"] = "high"
+
+	tests := []struct {
+		name   string
+		filter SubscriptionFilter
+		want   bool
+	}{
+		{"empty filter", SubscriptionFilter{}, true},
+		{"sender match", SubscriptionFilter{SenderIDs: []string{"carol", "alice"}}, true},
+		{"sender mismatch", SubscriptionFilter{SenderIDs: []string{"carol"}}, false},
+		{"content type match", SubscriptionFilter{ContentTypes: []string{ContentTypeText}}, true},
+		{"content type mismatch", SubscriptionFilter{ContentTypes: []string{ContentTypeJSON}}, false},
+		{"topic match", SubscriptionFilter{Topics: []string{"roadmap"}}, true},
+		{"topic mismatch", SubscriptionFilter{Topics: []string{"billing"}}, false},
+		{"metadata match", SubscriptionFilter{Metadata: map[string]string{"priority": "high"}}, true},
+		{"metadata wildcard", SubscriptionFilter{Metadata: map[string]string{"priority": "*"}}, true},
+		{"metadata missing", SubscriptionFilter{Metadata: map[string]string{"thread_id": "*"}}, false},
+		{"predicate", SubscriptionFilter{Predicate: func(m Message) bool { return len(m.Content) > 10 }}, false},
+		{"all fields must match", SubscriptionFilter{SenderIDs: []string{"alice"}, Topics: []string{"billing"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(msg))
+		})
+	}
+}
+
+func TestSubscribeWithFilters(t *testing.T) {
+	bus := NewMemoryMessageBus()
+
+	received := make(chan Message, 10)
+	err := bus.Subscribe("logger", func(msg Message) error {
+		received <- msg
+		return nil
+	},
+		SubscriptionFilter{ContentTypes: []string{ContentTypeCommand}},
+		SubscriptionFilter{SenderIDs: []string{"agent"}, Topics: []string{"audit"}},
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, bus.Subscribe("agent", func(msg Message) error { return nil }))
+	assert.NoError(t, bus.CreateGroup("team", "Team", []string{"logger", "agent"}))
+
+	// Filtered out: plain text without the audit topic, on every delivery path
+	assert.NoError(t, bus.Publish(NewTextMessage("agent", []string{"logger"}, "direct")))
+	assert.NoError(t, bus.Publish(NewTextMessage("agent", []string{BroadcastAddress}, "broadcast")))
+	assert.NoError(t, bus.Publish(NewTextMessage("agent", []string{"team"}, "group")))
+
+	// Accepted by the first filter
+	assert.NoError(t, bus.Publish(NewMessage("someone", []string{"logger"}, ContentTypeCommand, []byte("/flush"))))
+
+	// Accepted by the second filter
+	audit := NewTextMessage("agent", []string{"team"}, "audit entry")
+	audit.Metadata[MetadataTopic] = "audit"
+	assert.NoError(t, bus.Publish(audit))
+
+	got := make([]string, 0)
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-received:
+			got = append(got, string(msg.Content))
+		case <-time.After(200 * time.Millisecond):
+			t.Fatal("Timeout waiting for message")
+		}
+	}
+	assert.ElementsMatch(t, []string{"/flush", "audit entry"}, got)
+
+	// The handler was never invoked for the filtered messages
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, received, 0)
+}
@@ -0,0 +1,138 @@
+package messaging
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GroupRole is the role of a member in a group. Roles are not enforced by the bus;
+// callers check them with Group.Role or Group.CanAdminister before changing a group.
+type GroupRole string
+
+// Group roles, from least to most privileged
+const (
+	GroupRoleMember GroupRole = "member"
+	GroupRoleAdmin  GroupRole = "admin"
+	GroupRoleOwner  GroupRole = "owner" // At most one member per group
+)
+
+// Group represents a message group with members
+type Group struct {
+	ID             string                       `json:"id"`
+	Name           string                       `json:"name"`
+	Members        map[string]bool              `json:"members"`
+	Roles          map[string]GroupRole         `json:"roles,omitempty"`          // Roles above GroupRoleMember by member
+	MemberMetadata map[string]map[string]string `json:"memberMetadata,omitempty"` // Free-form details by member
+}
+
+// newGroup creates a group with the members
+func newGroup(groupID, name string, members []string) *Group {
+	group := &Group{
+		ID:             groupID,
+		Name:           name,
+		Members:        make(map[string]bool),
+		Roles:          make(map[string]GroupRole),
+		MemberMetadata: make(map[string]map[string]string),
+	}
+	for _, memberID := range members {
+		group.Members[memberID] = true
+	}
+	return group
+}
+
+// Role returns the role of a member, or "" if the entity is not a member
+func (g Group) Role(entityID string) GroupRole {
+	if !g.Members[entityID] {
+		return ""
+	}
+	if role, ok := g.Roles[entityID]; ok {
+		return role
+	}
+	return GroupRoleMember
+}
+
+// CanAdminister checks if the entity is the owner or an admin of the group
+func (g Group) CanAdminister(entityID string) bool {
+	role := g.Role(entityID)
+	return role == GroupRoleOwner || role == GroupRoleAdmin
+}
+
+// Owner returns the owner of the group, or "" if it has none
+func (g Group) Owner() string {
+	for entityID, role := range g.Roles {
+		if role == GroupRoleOwner {
+			return entityID
+		}
+	}
+	return ""
+}
+
+// setRole gives a member a role; a new owner demotes the previous one to admin
+func (g *Group) setRole(entityID string, role GroupRole) error {
+	if role != GroupRoleMember && role != GroupRoleAdmin && role != GroupRoleOwner {
+		return fmt.Errorf("invalid group role %q", role)
+	}
+	if !g.Members[entityID] {
+		return fmt.Errorf("entity %s is not a member of group %s", entityID, g.ID)
+	}
+	if role == GroupRoleOwner {
+		if owner := g.Owner(); owner != "" && owner != entityID {
+			g.Roles[owner] = GroupRoleAdmin
+		}
+	}
+	if role == GroupRoleMember {
+		delete(g.Roles, entityID)
+	} else {
+		g.Roles[entityID] = role
+	}
+	return nil
+}
+
+// setMetadata replaces the metadata of a member, removing it for empty metadata
+func (g *Group) setMetadata(entityID string, metadata map[string]string) error {
+	if !g.Members[entityID] {
+		return fmt.Errorf("entity %s is not a member of group %s", entityID, g.ID)
+	}
+	if len(metadata) == 0 {
+		delete(g.MemberMetadata, entityID)
+		return nil
+	}
+	copied := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		copied[key] = value
+	}
+	g.MemberMetadata[entityID] = copied
+	return nil
+}
+
+// removeMember removes a member with its role and metadata
+func (g *Group) removeMember(entityID string) {
+	delete(g.Members, entityID)
+	delete(g.Roles, entityID)
+	delete(g.MemberMetadata, entityID)
+}
+
+// clone returns a copy of the group that shares nothing with it
+func (g *Group) clone() Group {
+	copied := newGroup(g.ID, g.Name, nil)
+	for memberID := range g.Members {
+		copied.Members[memberID] = true
+	}
+	for memberID, role := range g.Roles {
+		copied.Roles[memberID] = role
+	}
+	for memberID, metadata := range g.MemberMetadata {
+		copied.setMetadata(memberID, metadata)
+	}
+	return *copied
+}
+
+// listGroups returns copies of the groups sorted by ID
+func listGroups(groups map[string]*Group) []Group {
+	list := make([]Group, 0, len(groups))
+	for _, group := range groups {
+		list = append(list, group.clone())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
@@ -0,0 +1,92 @@
+package messaging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupRoles(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	require.NoError(t, bus.CreateGroup("team", "Team", []string{"alice", "bob", "carol"}))
+
+	require.NoError(t, bus.SetMemberRole("team", "alice", GroupRoleOwner))
+	require.NoError(t, bus.SetMemberRole("team", "bob", GroupRoleAdmin))
+	group, err := bus.GetGroup("team")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", group.Owner())
+	assert.Equal(t, GroupRoleAdmin, group.Role("bob"))
+	assert.Equal(t, GroupRoleMember, group.Role("carol"))
+	assert.Equal(t, GroupRole(""), group.Role("dave"))
+	assert.True(t, group.CanAdminister("bob"))
+	assert.False(t, group.CanAdminister("carol"))
+
+	// A new owner demotes the previous one to admin
+	require.NoError(t, bus.SetMemberRole("team", "carol", GroupRoleOwner))
+	group, _ = bus.GetGroup("team")
+	assert.Equal(t, "carol", group.Owner())
+	assert.Equal(t, GroupRoleAdmin, group.Role("alice"))
+
+	assert.Error(t, bus.SetMemberRole("team", "dave", GroupRoleAdmin), "only members have roles")
+	assert.Error(t, bus.SetMemberRole("team", "bob", GroupRole("root")))
+	assert.Error(t, bus.SetMemberRole("nobody", "bob", GroupRoleAdmin))
+
+	// Leaving the group drops the role and metadata
+	require.NoError(t, bus.SetMemberMetadata("team", "bob", map[string]string{"timezone": "UTC"}))
+	require.NoError(t, bus.RemoveFromGroup("team", "bob"))
+	require.NoError(t, bus.AddToGroup("team", "bob"))
+	group, _ = bus.GetGroup("team")
+	assert.Equal(t, GroupRoleMember, group.Role("bob"))
+	assert.Empty(t, group.MemberMetadata["bob"])
+}
+
+func TestGroupAdministration(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	require.NoError(t, bus.CreateGroup("ops", "Ops", []string{"bob"}))
+	require.NoError(t, bus.CreateGroup("dev", "Dev", []string{"alice"}))
+
+	require.NoError(t, bus.RenameGroup("dev", "Developers"))
+	require.NoError(t, bus.SetMemberMetadata("dev", "alice", map[string]string{"focus": "backend"}))
+	groups := bus.ListGroups()
+	require.Len(t, groups, 2)
+	assert.Equal(t, "dev", groups[0].ID)
+	assert.Equal(t, "Developers", groups[0].Name)
+	assert.Equal(t, "backend", groups[0].MemberMetadata["alice"]["focus"])
+
+	// Returned groups are copies
+	groups[0].Members["mallory"] = true
+	members, _ := bus.GetGroupMembers("dev")
+	assert.Equal(t, []string{"alice"}, members)
+
+	received := make(chan Message, 1)
+	bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil })
+	require.NoError(t, bus.RemoveGroup("ops"))
+	require.NoError(t, publishText(bus, []string{"ops"}, "anyone?"))
+	expectNothing(t, received)
+	assert.Error(t, bus.RemoveGroup("ops"))
+	assert.Error(t, bus.RenameGroup("ops", "Ops"))
+	assert.Len(t, bus.ListGroups(), 1)
+}
+
+func TestRemoteBusGroupAdministration(t *testing.T) {
+	server := newFakeNats(t, "")
+	bus, err := NewNatsMessageBus(server.url())
+	require.NoError(t, err)
+	defer bus.Close()
+
+	received := make(chan Message, 1)
+	require.NoError(t, bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil }))
+	require.NoError(t, bus.CreateGroup("team", "Team", []string{"bob"}))
+	require.NoError(t, bus.SetMemberRole("team", "bob", GroupRoleOwner))
+	require.NoError(t, bus.RenameGroup("team", "Core team"))
+	group, err := bus.GetGroup("team")
+	require.NoError(t, err)
+	assert.Equal(t, "Core team", group.Name)
+	assert.Equal(t, "bob", group.Owner())
+
+	require.NoError(t, bus.RemoveGroup("team"))
+	assert.Empty(t, bus.ListGroups())
+	require.NoError(t, publishText(bus, []string{"team"}, "anyone?"))
+	expectNothing(t, received)
+}
@@ -0,0 +1,355 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"goproduct/internal/logging"
+	"goproduct/internal/messaging"
+)
+
+// Client defaults
+const (
+	DefaultCallTimeout   = 30 * time.Second
+	DefaultReconnectWait = 2 * time.Second
+)
+
+// Client is a MessageBus backed by a remote Server. Publishing, groups, topics and
+// history are served by the remote bus. Each entity subscribed through the client gets
+// its messages over its own stream, and they are handed to the local handlers by an
+// embedded MemoryMessageBus, which also provides the middleware, retries, dead letters
+// and pattern subscriptions for them. Streams are reopened when they drop.
+type Client struct {
+	*messaging.MemoryMessageBus
+	baseURL       string
+	http          *http.Client
+	callTimeout   time.Duration
+	reconnectWait time.Duration
+	streams       map[string]context.CancelFunc // Open subscription streams by entity
+	logger        *logging.Logger
+	mu            sync.Mutex
+}
+
+// Status is the error of a gRPC call that the server failed
+type Status struct {
+	Code    int
+	Message string
+}
+
+// Error implements error
+func (s *Status) Error() string {
+	return fmt.Sprintf("gRPC error %d: %s", s.Code, s.Message)
+}
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithCallTimeout bounds each call other than the subscription streams
+func WithCallTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) { c.callTimeout = timeout }
+}
+
+// WithReconnectWait sets the pause before reopening a subscription stream that dropped
+func WithReconnectWait(wait time.Duration) ClientOption {
+	return func(c *Client) { c.reconnectWait = wait }
+}
+
+// NewClient creates a client for the server at the address, e.g. "localhost:50051".
+// No connection is made until the first call.
+func NewClient(addr string, opts ...ClientOption) *Client {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	c := &Client{
+		MemoryMessageBus: messaging.NewMemoryMessageBus(),
+		baseURL:          "http://" + addr + "/" + ServiceName + "/",
+		http:             &http.Client{Transport: &http.Transport{Protocols: &protocols}},
+		callTimeout:      DefaultCallTimeout,
+		reconnectWait:    DefaultReconnectWait,
+		streams:          make(map[string]context.CancelFunc),
+		logger:           logging.Get().Component("messaging"),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Publish sends the message through the remote bus
+func (c *Client) Publish(msg messaging.Message) error {
+	_, err := c.call("Publish", encodeMessage(msg))
+	return err
+}
+
+// Request publishes a message and waits for its reply, see messaging.Request
+func (c *Client) Request(ctx context.Context, msg messaging.Message) (messaging.Message, error) {
+	return messaging.Request(ctx, c, msg)
+}
+
+// Subscribe registers an entity of this process and opens its stream; the entity is
+// subscribed on the remote bus when Subscribe returns
+func (c *Client) Subscribe(entityID string, handler messaging.MessageHandler, filters ...messaging.SubscriptionFilter) error {
+	if err := c.MemoryMessageBus.Subscribe(entityID, handler, filters...); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if _, open := c.streams[entityID]; open {
+		c.mu.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.streams[entityID] = cancel
+	c.mu.Unlock()
+
+	body, err := c.openStream(ctx, entityID)
+	if err != nil {
+		c.closeStream(entityID)
+		c.MemoryMessageBus.Unsubscribe(entityID)
+		return err
+	}
+	go c.receive(ctx, entityID, body)
+	return nil
+}
+
+// Unsubscribe closes the stream of an entity, which unsubscribes it on the remote bus
+func (c *Client) Unsubscribe(entityID string) error {
+	c.closeStream(entityID)
+	return c.MemoryMessageBus.Unsubscribe(entityID)
+}
+
+// SubscribeTopic subscribes an entity to the topics matching the pattern on the remote bus
+func (c *Client) SubscribeTopic(entityID, pattern string) error {
+	_, err := c.call("SubscribeTopic", topicRequest{entityID: entityID, pattern: pattern}.encode())
+	return err
+}
+
+// UnsubscribeTopic removes a topic subscription on the remote bus
+func (c *Client) UnsubscribeTopic(entityID, pattern string) error {
+	_, err := c.call("UnsubscribeTopic", topicRequest{entityID: entityID, pattern: pattern}.encode())
+	return err
+}
+
+// CreateGroup creates a group on the remote bus
+func (c *Client) CreateGroup(groupID, name string, members []string) error {
+	_, err := c.call("CreateGroup", groupRequest{groupID: groupID, name: name, members: members}.encode())
+	return err
+}
+
+// AddToGroup adds an entity to a group on the remote bus
+func (c *Client) AddToGroup(groupID, entityID string) error {
+	_, err := c.call("AddToGroup", groupRequest{groupID: groupID, entityID: entityID}.encode())
+	return err
+}
+
+// RemoveFromGroup removes an entity from a group on the remote bus
+func (c *Client) RemoveFromGroup(groupID, entityID string) error {
+	_, err := c.call("RemoveFromGroup", groupRequest{groupID: groupID, entityID: entityID}.encode())
+	return err
+}
+
+// RemoveGroup deletes a group on the remote bus
+func (c *Client) RemoveGroup(groupID string) error {
+	_, err := c.call("RemoveGroup", groupRequest{groupID: groupID}.encode())
+	return err
+}
+
+// RenameGroup renames a group on the remote bus
+func (c *Client) RenameGroup(groupID, name string) error {
+	_, err := c.call("RenameGroup", groupRequest{groupID: groupID, name: name}.encode())
+	return err
+}
+
+// SetMemberRole gives a member of a group on the remote bus a role
+func (c *Client) SetMemberRole(groupID, entityID string, role messaging.GroupRole) error {
+	_, err := c.call("SetMemberRole", groupRequest{groupID: groupID, entityID: entityID, role: role}.encode())
+	return err
+}
+
+// SetMemberMetadata replaces the metadata of a member of a group on the remote bus
+func (c *Client) SetMemberMetadata(groupID, entityID string, metadata map[string]string) error {
+	_, err := c.call("SetMemberMetadata", groupRequest{groupID: groupID, entityID: entityID, metadata: metadata}.encode())
+	return err
+}
+
+// GetGroup returns a group of the remote bus
+func (c *Client) GetGroup(groupID string) (messaging.Group, error) {
+	reply, err := c.call("GetGroup", groupRequest{groupID: groupID}.encode())
+	if err != nil {
+		return messaging.Group{}, err
+	}
+	return decodeGroup(reply)
+}
+
+// GetGroupMembers returns the members of a group of the remote bus
+func (c *Client) GetGroupMembers(groupID string) ([]string, error) {
+	group, err := c.GetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, 0, len(group.Members))
+	for memberID := range group.Members {
+		members = append(members, memberID)
+	}
+	return members, nil
+}
+
+// ListGroups returns the groups of the remote bus sorted by ID; it returns none if the
+// server cannot be reached, which is logged
+func (c *Client) ListGroups() []messaging.Group {
+	reply, err := c.call("ListGroups", nil)
+	if err == nil {
+		var groups []messaging.Group
+		if groups, err = decodeGroupList(reply); err == nil {
+			return groups
+		}
+	}
+	c.logger.Error("Failed to list groups over gRPC", "error", err)
+	return nil
+}
+
+// GetHistory returns the history the remote bus retains for the entity
+func (c *Client) GetHistory(entityID string, since time.Time, limit int) ([]messaging.Message, error) {
+	reply, err := c.call("GetHistory", historyRequest{entityID: entityID, since: since, limit: limit}.encode())
+	if err != nil {
+		return nil, err
+	}
+	return decodeMessageList(reply)
+}
+
+// Close closes every subscription stream
+func (c *Client) Close() error {
+	c.mu.Lock()
+	for entityID, cancel := range c.streams {
+		cancel()
+		delete(c.streams, entityID)
+	}
+	c.mu.Unlock()
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+// call makes a unary call and returns the encoded reply
+func (c *Client) call(method string, request []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.callTimeout)
+	defer cancel()
+	resp, err := c.post(ctx, method, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	reply, err := readFrame(resp.Body)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read %s reply: %w", method, err)
+	}
+	io.Copy(io.Discard, resp.Body) // Trailers arrive after the body
+	if err := status(resp); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// openStream opens the subscription stream of an entity, returning once the entity is
+// subscribed on the remote bus
+func (c *Client) openStream(ctx context.Context, entityID string) (io.ReadCloser, error) {
+	resp, err := c.post(ctx, "Subscribe", subscribeRequest{entityID: entityID}.encode())
+	if err != nil {
+		return nil, err
+	}
+	if err := status(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// receive hands the messages of an entity's stream to its handler, reopening the stream
+// when it drops until it is closed
+func (c *Client) receive(ctx context.Context, entityID string, body io.ReadCloser) {
+	for {
+		for {
+			payload, err := readFrame(body)
+			if err != nil {
+				break
+			}
+			msg, err := decodeMessage(payload)
+			if err != nil {
+				c.logger.Error("Failed to decode gRPC message", "entity_id", entityID, "error", err)
+				continue
+			}
+			c.DeliverTo(entityID, msg)
+		}
+		body.Close()
+
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			c.logger.Warn("gRPC subscription stream dropped, reopening", "entity_id", entityID)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(c.reconnectWait):
+			}
+			var err error
+			if body, err = c.openStream(ctx, entityID); err == nil {
+				break
+			}
+		}
+	}
+}
+
+// closeStream cancels the stream of an entity
+func (c *Client) closeStream(entityID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cancel, open := c.streams[entityID]; open {
+		cancel()
+		delete(c.streams, entityID)
+	}
+}
+
+// post sends a gRPC request for the method
+func (c *Client) post(ctx context.Context, method string, request []byte) (*http.Response, error) {
+	var body bytes.Buffer
+	writeFrame(&body, request)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+method, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, &Status{Code: codeUnavailable, Message: err.Error()}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &Status{Code: codeUnknown, Message: resp.Status}
+	}
+	return resp, nil
+}
+
+// status returns the error of a failed call from its trailers, or from its headers for
+// calls that failed before replying
+func status(resp *http.Response) error {
+	value, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if value == "" {
+		value, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if value == "" || value == strconv.Itoa(codeOK) {
+		return nil
+	}
+	code, _ := strconv.Atoi(value)
+	if decoded, err := url.PathUnescape(message); err == nil {
+		message = decoded
+	}
+	return &Status{Code: code, Message: message}
+}
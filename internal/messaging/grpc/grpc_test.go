@@ -0,0 +1,145 @@
+package grpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"goproduct/internal/messaging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBridge starts a server for a memory bus and connects a client to it
+func newTestBridge(t *testing.T) (*messaging.MemoryMessageBus, *Client) {
+	bus := messaging.NewMemoryMessageBus(messaging.WithHistory(10))
+	server := NewServer("127.0.0.1:0", bus)
+	require.NoError(t, server.Start())
+	client := NewClient(server.Addr(), WithReconnectWait(10*time.Millisecond))
+	t.Cleanup(func() { client.Close(); server.Close() })
+	return bus, client
+}
+
+// receive waits for a message on the channel
+func receive(t *testing.T, received <-chan messaging.Message) messaging.Message {
+	t.Helper()
+	select {
+	case msg := <-received:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for message")
+		return messaging.Message{}
+	}
+}
+
+func TestMessageEncodingRoundTrip(t *testing.T) {
+	msg := messaging.NewTextMessage("alice", []string{"bob", "team"}, "hello").
+		WithPriority(messaging.PriorityLow).
+		WithTTL(time.Minute)
+	msg.ReplyToID = "m0"
+	msg.ReplyTo = "_inbox-1"
+	msg.CorrelationID = "c1"
+	msg.Metadata["conversation_id"] = "42"
+
+	decoded, err := decodeMessage(encodeMessage(msg))
+	require.NoError(t, err)
+	assert.Equal(t, msg.ID, decoded.ID)
+	assert.Equal(t, msg.Recipients, decoded.Recipients)
+	assert.Equal(t, msg.Content, decoded.Content)
+	assert.Equal(t, messaging.PriorityLow, decoded.Priority)
+	assert.True(t, msg.ExpiresAt.Equal(decoded.ExpiresAt))
+	assert.True(t, msg.Timestamp.Equal(decoded.Timestamp))
+	assert.True(t, decoded.DeliverAt.IsZero())
+	assert.Equal(t, "_inbox-1", decoded.ReplyTo)
+	assert.Equal(t, "c1", decoded.CorrelationID)
+	assert.Equal(t, msg.Metadata, decoded.Metadata)
+
+	_, err = decodeMessage([]byte{0x0a, 0x05, 'a'})
+	assert.Error(t, err, "truncated field")
+}
+
+func TestClientPublishAndSubscribe(t *testing.T) {
+	bus, client := newTestBridge(t)
+
+	local := make(chan messaging.Message, 1)
+	require.NoError(t, bus.Subscribe("bob", func(msg messaging.Message) error { local <- msg; return nil }))
+	remote := make(chan messaging.Message, 1)
+	require.NoError(t, client.Subscribe("carol", func(msg messaging.Message) error { remote <- msg; return nil }))
+
+	// From the remote entity to the local one and back
+	require.NoError(t, client.Publish(messaging.NewTextMessage("carol", []string{"bob"}, "ping")))
+	assert.Equal(t, "ping", string(receive(t, local).Content))
+	require.NoError(t, bus.Publish(messaging.NewTextMessage("bob", []string{"carol"}, "pong")))
+	msg := receive(t, remote)
+	assert.Equal(t, "pong", string(msg.Content))
+	assert.Equal(t, []string{"carol"}, msg.Recipients)
+
+	// Groups live on the served bus and reach the remote member
+	require.NoError(t, client.CreateGroup("team", "Team", []string{"carol"}))
+	require.NoError(t, client.SetMemberRole("team", "carol", messaging.GroupRoleOwner))
+	require.NoError(t, bus.Publish(messaging.NewTextMessage("bob", []string{"team"}, "standup")))
+	assert.Equal(t, "standup", string(receive(t, remote).Content))
+	group, err := client.GetGroup("team")
+	require.NoError(t, err)
+	assert.Equal(t, "carol", group.Owner())
+	assert.Len(t, client.ListGroups(), 1)
+
+	history, err := client.GetHistory("carol", time.Time{}, 0)
+	require.NoError(t, err)
+	assert.Len(t, history, 3)
+
+	// Unsubscribing closes the stream, which unsubscribes the entity on the served bus,
+	// so a fallback for it takes its messages
+	orphaned := make(chan messaging.Message, 100)
+	require.NoError(t, bus.SubscribePattern("carol", messaging.PatternFallback, func(msg messaging.Message) error { orphaned <- msg; return nil }))
+	require.NoError(t, client.Unsubscribe("carol"))
+	require.Eventually(t, func() bool {
+		bus.Publish(messaging.NewTextMessage("bob", []string{"carol"}, "gone"))
+		return len(orphaned) > 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestClientRequestReply(t *testing.T) {
+	bus, client := newTestBridge(t)
+	require.NoError(t, bus.Subscribe("agent", func(msg messaging.Message) error {
+		return bus.Publish(messaging.NewTextReplyMessage("agent", msg, "re: "+string(msg.Content)))
+	}))
+
+	reply, err := client.Request(t.Context(), messaging.NewTextMessage("carol", []string{"agent"}, "status?"))
+	require.NoError(t, err)
+	assert.Equal(t, "re: status?", string(reply.Content))
+}
+
+func TestClientErrors(t *testing.T) {
+	_, client := newTestBridge(t)
+
+	err := client.AddToGroup("missing", "carol")
+	var status *Status
+	require.True(t, errors.As(err, &status))
+	assert.Equal(t, codeUnknown, status.Code)
+	assert.Contains(t, status.Message, "does not exist")
+
+	unreachable := NewClient("127.0.0.1:1", WithCallTimeout(time.Second))
+	err = unreachable.Publish(messaging.NewTextMessage("carol", []string{"bob"}, "hello"))
+	require.True(t, errors.As(err, &status))
+	assert.Equal(t, codeUnavailable, status.Code)
+	assert.Error(t, unreachable.Subscribe("carol", func(msg messaging.Message) error { return nil }))
+}
+
+func TestClientKeepsLocalDeliveryFeatures(t *testing.T) {
+	bus, client := newTestBridge(t)
+	client.SetRetryPolicy(messaging.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1})
+
+	failing := make(chan messaging.Message, 2)
+	require.NoError(t, client.Subscribe("carol", func(msg messaging.Message) error {
+		failing <- msg
+		return errors.New("busy")
+	}))
+	require.NoError(t, bus.Publish(messaging.NewTextMessage("bob", []string{"carol"}, "work").WithAck()))
+	receive(t, failing)
+	receive(t, failing)
+	require.Eventually(t, func() bool { return len(client.GetDeadLetters()) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+var _ messaging.MessageBus = (*Client)(nil)
@@ -0,0 +1,333 @@
+// Package grpc bridges the message bus to other processes over gRPC, so services written
+// in other languages can participate as entities. The Server exposes a MessageBus as the
+// gogoproduct.bus.v1.MessageBus service described in bus.proto; the Client is a
+// MessageBus backed by a remote Server. gRPC runs over unencrypted HTTP/2 from net/http
+// with the protobuf encoding written by hand, so the bridge needs no dependencies; put a
+// TLS terminating proxy in front of the server to expose it beyond a trusted network.
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"goproduct/internal/logging"
+	"goproduct/internal/messaging"
+)
+
+// ServiceName is the full name of the gRPC service in bus.proto
+const ServiceName = "gogoproduct.bus.v1.MessageBus"
+
+// subscriptionBuffer bounds the messages waiting to be streamed to a subscriber; when it
+// is full, deliveries to the subscriber fail and follow the bus retry rules
+const subscriptionBuffer = 256
+
+// gRPC status codes used by the bridge
+const (
+	codeOK              = 0
+	codeUnknown         = 2
+	codeInvalidArgument = 3
+	codeUnavailable     = 14
+	codeUnimplemented   = 12
+)
+
+// unaryHandler serves a unary method, returning the encoded reply
+type unaryHandler func(request []byte) ([]byte, error)
+
+// invalidArgument marks request errors so they are reported as InvalidArgument
+type invalidArgument struct{ error }
+
+// Server serves a message bus over gRPC. Each streamed subscription subscribes its entity
+// on the bus for as long as the stream is open.
+type Server struct {
+	addr     string
+	bus      messaging.MessageBus
+	unary    map[string]unaryHandler
+	streams  map[string]*stream // Open subscription streams by entity
+	server   *http.Server
+	listener net.Listener
+	logger   *logging.Logger
+	mu       sync.Mutex // Protects streams, server and listener
+}
+
+// stream is an open subscription stream
+type stream struct {
+	messages chan messaging.Message
+	done     chan struct{}
+}
+
+// NewServer creates a server for the bus on the TCP address, e.g. ":50051"
+func NewServer(addr string, bus messaging.MessageBus) *Server {
+	s := &Server{addr: addr, bus: bus, streams: make(map[string]*stream), logger: logging.Get().Component("messaging")}
+	s.unary = map[string]unaryHandler{
+		"Publish":           s.publish,
+		"SubscribeTopic":    s.subscribeTopic,
+		"UnsubscribeTopic":  s.unsubscribeTopic,
+		"CreateGroup":       s.group(func(r groupRequest) error { return bus.CreateGroup(r.groupID, r.name, r.members) }),
+		"AddToGroup":        s.group(func(r groupRequest) error { return bus.AddToGroup(r.groupID, r.entityID) }),
+		"RemoveFromGroup":   s.group(func(r groupRequest) error { return bus.RemoveFromGroup(r.groupID, r.entityID) }),
+		"RemoveGroup":       s.group(func(r groupRequest) error { return bus.RemoveGroup(r.groupID) }),
+		"RenameGroup":       s.group(func(r groupRequest) error { return bus.RenameGroup(r.groupID, r.name) }),
+		"SetMemberRole":     s.group(func(r groupRequest) error { return bus.SetMemberRole(r.groupID, r.entityID, r.role) }),
+		"SetMemberMetadata": s.group(func(r groupRequest) error { return bus.SetMemberMetadata(r.groupID, r.entityID, r.metadata) }),
+		"GetGroup":          s.getGroup,
+		"ListGroups":        s.listGroups,
+		"GetHistory":        s.getHistory,
+	}
+	return s
+}
+
+// Start listens on the address and serves gRPC calls until Close is called
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for gRPC on %s: %w", s.addr, err)
+	}
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	server := &http.Server{Handler: s, Protocols: &protocols}
+
+	s.mu.Lock()
+	s.server, s.listener = server, listener
+	s.mu.Unlock()
+
+	s.logger.Info("gRPC bus bridge listening", "address", listener.Addr().String())
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("gRPC bus bridge stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on, or "" before Start
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Close stops the server, ending every subscription stream
+func (s *Server) Close() error {
+	s.mu.Lock()
+	server := s.server
+	s.server, s.listener = nil, nil
+	s.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	return server.Close()
+}
+
+// ServeHTTP serves a gRPC call
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	// Calls that fail before replying send their status in the headers, as a
+	// trailers-only response
+	w.Header().Set("Content-Type", "application/grpc")
+
+	service, method, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	request, err := readFrame(r.Body)
+	if err != nil {
+		finish(w, codeInvalidArgument, fmt.Sprintf("failed to read request: %v", err))
+		return
+	}
+	if service == ServiceName && method == "Subscribe" {
+		s.subscribe(w, r, request)
+		return
+	}
+	handler, ok := s.unary[method]
+	if service != ServiceName || !ok {
+		finish(w, codeUnimplemented, fmt.Sprintf("unknown method %s", r.URL.Path))
+		return
+	}
+
+	reply, err := handler(request)
+	var invalid invalidArgument
+	switch {
+	case errors.As(err, &invalid):
+		finish(w, codeInvalidArgument, err.Error())
+	case err != nil:
+		finish(w, codeUnknown, err.Error())
+	default:
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		if err := writeFrame(w, reply); err != nil {
+			s.logger.Debug("Failed to write gRPC reply", "method", method, "error", err)
+		}
+		finish(w, codeOK, "")
+	}
+}
+
+// subscribe streams the messages for an entity until the call ends. A new stream for
+// the same entity replaces the previous one.
+func (s *Server) subscribe(w http.ResponseWriter, r *http.Request, request []byte) {
+	var req subscribeRequest
+	if err := req.decode(request); err != nil || req.entityID == "" {
+		finish(w, codeInvalidArgument, "subscribe needs an entity ID")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		finish(w, codeUnimplemented, "streaming is not supported")
+		return
+	}
+
+	st := &stream{messages: make(chan messaging.Message, subscriptionBuffer), done: make(chan struct{})}
+	err := s.bus.Subscribe(req.entityID, func(msg messaging.Message) error {
+		select {
+		case st.messages <- msg:
+			return nil
+		case <-st.done:
+			return fmt.Errorf("subscription stream of %s closed", req.entityID)
+		default:
+			return fmt.Errorf("subscription stream of %s is full", req.entityID)
+		}
+	})
+	if err != nil {
+		finish(w, codeUnknown, err.Error())
+		return
+	}
+	s.mu.Lock()
+	previous := s.streams[req.entityID]
+	s.streams[req.entityID] = st
+	s.mu.Unlock()
+	if previous != nil {
+		close(previous.done)
+	}
+	s.logger.Info("gRPC subscription opened", "entity_id", req.entityID)
+
+	defer func() {
+		s.mu.Lock()
+		current := s.streams[req.entityID] == st
+		if current {
+			delete(s.streams, req.entityID)
+		}
+		s.mu.Unlock()
+		if current {
+			close(st.done)
+			s.bus.Unsubscribe(req.entityID)
+		}
+		s.logger.Info("gRPC subscription closed", "entity_id", req.entityID)
+	}()
+
+	// Sending the headers tells the client the entity is subscribed
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-st.done:
+			finish(w, codeUnavailable, "replaced by a newer subscription")
+			return
+		case msg := <-st.messages:
+			if err := writeFrame(w, encodeMessage(msg)); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// publish serves Publish
+func (s *Server) publish(request []byte) ([]byte, error) {
+	msg, err := decodeMessage(request)
+	if err != nil {
+		return nil, invalidArgument{err}
+	}
+	return nil, s.bus.Publish(msg)
+}
+
+// subscribeTopic serves SubscribeTopic
+func (s *Server) subscribeTopic(request []byte) ([]byte, error) {
+	var req topicRequest
+	if err := req.decode(request); err != nil {
+		return nil, invalidArgument{err}
+	}
+	return nil, s.bus.SubscribeTopic(req.entityID, req.pattern)
+}
+
+// unsubscribeTopic serves UnsubscribeTopic
+func (s *Server) unsubscribeTopic(request []byte) ([]byte, error) {
+	var req topicRequest
+	if err := req.decode(request); err != nil {
+		return nil, invalidArgument{err}
+	}
+	return nil, s.bus.UnsubscribeTopic(req.entityID, req.pattern)
+}
+
+// group serves a group operation without a reply
+func (s *Server) group(operation func(req groupRequest) error) unaryHandler {
+	return func(request []byte) ([]byte, error) {
+		var req groupRequest
+		if err := req.decode(request); err != nil {
+			return nil, invalidArgument{err}
+		}
+		return nil, operation(req)
+	}
+}
+
+// getGroup serves GetGroup
+func (s *Server) getGroup(request []byte) ([]byte, error) {
+	var req groupRequest
+	if err := req.decode(request); err != nil {
+		return nil, invalidArgument{err}
+	}
+	group, err := s.bus.GetGroup(req.groupID)
+	if err != nil {
+		return nil, err
+	}
+	return encodeGroup(group), nil
+}
+
+// listGroups serves ListGroups
+func (s *Server) listGroups([]byte) ([]byte, error) {
+	return encodeGroupList(s.bus.ListGroups()), nil
+}
+
+// getHistory serves GetHistory
+func (s *Server) getHistory(request []byte) ([]byte, error) {
+	var req historyRequest
+	if err := req.decode(request); err != nil {
+		return nil, invalidArgument{err}
+	}
+	messages, err := s.bus.GetHistory(req.entityID, req.since, req.limit)
+	if err != nil {
+		return nil, err
+	}
+	return encodeMessageList(messages), nil
+}
+
+// finish ends a call with its status, in the trailers if they were declared
+func finish(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set("Grpc-Message", percentEncode(message))
+	}
+}
+
+// percentEncode encodes a status message as gRPC requires, escaping '%' and bytes
+// outside printable ASCII
+func percentEncode(message string) string {
+	var sb strings.Builder
+	for i := 0; i < len(message); i++ {
+		if c := message[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,480 @@
+package grpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"goproduct/internal/messaging"
+)
+
+// The protobuf encoding of the messages in bus.proto, written by hand like the NATS and
+// Redis protocols of the messaging package to keep the bridge free of dependencies.
+
+// maxFrameSize bounds a message received in a gRPC frame
+const maxFrameSize = 4 << 20
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// errMalformed reports a protobuf message that cannot be decoded
+var errMalformed = errors.New("malformed protobuf message")
+
+// encoder appends protobuf fields to a buffer; zero values are omitted as in proto3
+type encoder struct {
+	buf []byte
+}
+
+// tag appends the key of a field
+func (e *encoder) tag(field, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+// int appends an int32 or int64 field
+func (e *encoder) int(field int, value int64) {
+	if value != 0 {
+		e.tag(field, wireVarint)
+		e.buf = binary.AppendUvarint(e.buf, uint64(value))
+	}
+}
+
+// bytes appends a bytes field
+func (e *encoder) bytes(field int, value []byte) {
+	if len(value) > 0 {
+		e.embedded(field, value)
+	}
+}
+
+// string appends a string field
+func (e *encoder) string(field int, value string) {
+	if value != "" {
+		e.embedded(field, []byte(value))
+	}
+}
+
+// strings appends a repeated string field
+func (e *encoder) strings(field int, values []string) {
+	for _, value := range values {
+		e.embedded(field, []byte(value))
+	}
+}
+
+// embedded appends a length-delimited field even if it is empty, as repeated and
+// embedded messages need
+func (e *encoder) embedded(field int, value []byte) {
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(value)))
+	e.buf = append(e.buf, value...)
+}
+
+// stringMap appends a map<string, string> field, sorted by key
+func (e *encoder) stringMap(field int, values map[string]string) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var entry encoder
+		entry.string(1, key)
+		entry.string(2, values[key])
+		e.embedded(field, entry.buf)
+	}
+}
+
+// time appends a timestamp as Unix nanoseconds, omitting the zero time
+func (e *encoder) time(field int, value time.Time) {
+	if !value.IsZero() {
+		e.int(field, value.UnixNano())
+	}
+}
+
+// decode calls fn for every field of a protobuf message with its varint value or its
+// bytes, depending on the wire type. Fixed-size fields are skipped, as bus.proto has none.
+func decode(data []byte, fn func(field int, varint uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errMalformed
+		}
+		data = data[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case wireVarint:
+			value, n := binary.Uvarint(data)
+			if n <= 0 {
+				return errMalformed
+			}
+			data = data[n:]
+			if err := fn(field, value, nil); err != nil {
+				return err
+			}
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return errMalformed
+			}
+			value := data[n : n+int(length)]
+			data = data[n+int(length):]
+			if err := fn(field, 0, value); err != nil {
+				return err
+			}
+		case wireFixed64:
+			if len(data) < 8 {
+				return errMalformed
+			}
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errMalformed
+			}
+			data = data[4:]
+		default:
+			return errMalformed
+		}
+	}
+	return nil
+}
+
+// decodeMapEntry decodes an entry of a map<string, string> field into the map
+func decodeMapEntry(data []byte, values map[string]string) error {
+	var key, value string
+	err := decode(data, func(field int, _ uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			key = string(bytes)
+		case 2:
+			value = string(bytes)
+		}
+		return nil
+	})
+	values[key] = value
+	return err
+}
+
+// unixNano converts Unix nanoseconds back to a time, keeping 0 as the zero time
+func unixNano(value uint64) time.Time {
+	if value == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(value))
+}
+
+// encodeMessage encodes a bus message as a Message
+func encodeMessage(msg messaging.Message) []byte {
+	var e encoder
+	e.string(1, msg.ID)
+	e.string(2, msg.SenderID)
+	e.strings(3, msg.Recipients)
+	e.string(4, msg.ContentType)
+	e.bytes(5, msg.Content)
+	e.string(6, msg.ReplyToID)
+	e.string(7, msg.ReplyTo)
+	e.string(8, msg.CorrelationID)
+	e.int(9, int64(msg.Priority))
+	e.time(10, msg.ExpiresAt)
+	e.time(11, msg.DeliverAt)
+	e.time(12, msg.Timestamp)
+	e.stringMap(13, msg.Metadata)
+	return e.buf
+}
+
+// decodeMessage decodes a Message into a bus message
+func decodeMessage(data []byte) (messaging.Message, error) {
+	msg := messaging.Message{Metadata: make(map[string]string)}
+	err := decode(data, func(field int, varint uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			msg.ID = string(bytes)
+		case 2:
+			msg.SenderID = string(bytes)
+		case 3:
+			msg.Recipients = append(msg.Recipients, string(bytes))
+		case 4:
+			msg.ContentType = string(bytes)
+		case 5:
+			msg.Content = append([]byte(nil), bytes...)
+		case 6:
+			msg.ReplyToID = string(bytes)
+		case 7:
+			msg.ReplyTo = string(bytes)
+		case 8:
+			msg.CorrelationID = string(bytes)
+		case 9:
+			msg.Priority = messaging.Priority(int32(varint))
+		case 10:
+			msg.ExpiresAt = unixNano(varint)
+		case 11:
+			msg.DeliverAt = unixNano(varint)
+		case 12:
+			msg.Timestamp = unixNano(varint)
+		case 13:
+			return decodeMapEntry(bytes, msg.Metadata)
+		}
+		return nil
+	})
+	return msg, err
+}
+
+// encodeMessageList encodes messages as a MessageList
+func encodeMessageList(messages []messaging.Message) []byte {
+	var e encoder
+	for _, msg := range messages {
+		e.embedded(1, encodeMessage(msg))
+	}
+	return e.buf
+}
+
+// decodeMessageList decodes a MessageList
+func decodeMessageList(data []byte) ([]messaging.Message, error) {
+	var messages []messaging.Message
+	err := decode(data, func(field int, _ uint64, bytes []byte) error {
+		if field != 1 {
+			return nil
+		}
+		msg, err := decodeMessage(bytes)
+		messages = append(messages, msg)
+		return err
+	})
+	return messages, err
+}
+
+// subscribeRequest is a SubscribeRequest
+type subscribeRequest struct {
+	entityID string
+}
+
+func (r subscribeRequest) encode() []byte {
+	var e encoder
+	e.string(1, r.entityID)
+	return e.buf
+}
+
+func (r *subscribeRequest) decode(data []byte) error {
+	return decode(data, func(field int, _ uint64, bytes []byte) error {
+		if field == 1 {
+			r.entityID = string(bytes)
+		}
+		return nil
+	})
+}
+
+// topicRequest is a TopicRequest
+type topicRequest struct {
+	entityID string
+	pattern  string
+}
+
+func (r topicRequest) encode() []byte {
+	var e encoder
+	e.string(1, r.entityID)
+	e.string(2, r.pattern)
+	return e.buf
+}
+
+func (r *topicRequest) decode(data []byte) error {
+	return decode(data, func(field int, _ uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			r.entityID = string(bytes)
+		case 2:
+			r.pattern = string(bytes)
+		}
+		return nil
+	})
+}
+
+// groupRequest is a GroupRequest
+type groupRequest struct {
+	groupID  string
+	entityID string
+	name     string
+	members  []string
+	role     messaging.GroupRole
+	metadata map[string]string
+}
+
+func (r groupRequest) encode() []byte {
+	var e encoder
+	e.string(1, r.groupID)
+	e.string(2, r.entityID)
+	e.string(3, r.name)
+	e.strings(4, r.members)
+	e.string(5, string(r.role))
+	e.stringMap(6, r.metadata)
+	return e.buf
+}
+
+func (r *groupRequest) decode(data []byte) error {
+	return decode(data, func(field int, _ uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			r.groupID = string(bytes)
+		case 2:
+			r.entityID = string(bytes)
+		case 3:
+			r.name = string(bytes)
+		case 4:
+			r.members = append(r.members, string(bytes))
+		case 5:
+			r.role = messaging.GroupRole(bytes)
+		case 6:
+			if r.metadata == nil {
+				r.metadata = make(map[string]string)
+			}
+			return decodeMapEntry(bytes, r.metadata)
+		}
+		return nil
+	})
+}
+
+// historyRequest is a HistoryRequest
+type historyRequest struct {
+	entityID string
+	since    time.Time
+	limit    int
+}
+
+func (r historyRequest) encode() []byte {
+	var e encoder
+	e.string(1, r.entityID)
+	e.time(2, r.since)
+	e.int(3, int64(r.limit))
+	return e.buf
+}
+
+func (r *historyRequest) decode(data []byte) error {
+	return decode(data, func(field int, varint uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			r.entityID = string(bytes)
+		case 2:
+			r.since = unixNano(varint)
+		case 3:
+			r.limit = int(int32(varint))
+		}
+		return nil
+	})
+}
+
+// encodeGroup encodes a bus group as a Group, with its members sorted
+func encodeGroup(group messaging.Group) []byte {
+	members := make([]string, 0, len(group.Members))
+	for memberID := range group.Members {
+		members = append(members, memberID)
+	}
+	sort.Strings(members)
+
+	var e encoder
+	e.string(1, group.ID)
+	e.string(2, group.Name)
+	for _, memberID := range members {
+		var member encoder
+		member.string(1, memberID)
+		member.string(2, string(group.Role(memberID)))
+		member.stringMap(3, group.MemberMetadata[memberID])
+		e.embedded(3, member.buf)
+	}
+	return e.buf
+}
+
+// decodeGroup decodes a Group into a bus group
+func decodeGroup(data []byte) (messaging.Group, error) {
+	group := messaging.Group{
+		Members:        make(map[string]bool),
+		Roles:          make(map[string]messaging.GroupRole),
+		MemberMetadata: make(map[string]map[string]string),
+	}
+	err := decode(data, func(field int, _ uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			group.ID = string(bytes)
+		case 2:
+			group.Name = string(bytes)
+		case 3:
+			var memberID string
+			var role messaging.GroupRole
+			metadata := make(map[string]string)
+			err := decode(bytes, func(field int, _ uint64, bytes []byte) error {
+				switch field {
+				case 1:
+					memberID = string(bytes)
+				case 2:
+					role = messaging.GroupRole(bytes)
+				case 3:
+					return decodeMapEntry(bytes, metadata)
+				}
+				return nil
+			})
+			group.Members[memberID] = true
+			if role != "" && role != messaging.GroupRoleMember {
+				group.Roles[memberID] = role
+			}
+			if len(metadata) > 0 {
+				group.MemberMetadata[memberID] = metadata
+			}
+			return err
+		}
+		return nil
+	})
+	return group, err
+}
+
+// encodeGroupList encodes groups as a GroupList
+func encodeGroupList(groups []messaging.Group) []byte {
+	var e encoder
+	for _, group := range groups {
+		e.embedded(1, encodeGroup(group))
+	}
+	return e.buf
+}
+
+// decodeGroupList decodes a GroupList
+func decodeGroupList(data []byte) ([]messaging.Group, error) {
+	var groups []messaging.Group
+	err := decode(data, func(field int, _ uint64, bytes []byte) error {
+		if field != 1 {
+			return nil
+		}
+		group, err := decodeGroup(bytes)
+		groups = append(groups, group)
+		return err
+	})
+	return groups, err
+}
+
+// writeFrame writes a gRPC length-prefixed message
+func writeFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	_, err := w.Write(append(frame, payload...))
+	return err
+}
+
+// readFrame reads a gRPC length-prefixed message; compressed messages are not supported
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0] != 0 {
+		return nil, errors.New("compressed gRPC messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("gRPC message of %d bytes exceeds the limit of %d", size, maxFrameSize)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
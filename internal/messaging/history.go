@@ -0,0 +1,89 @@
+package messaging
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultHistoryCapacity is a reasonable number of messages to retain for backfill
+const DefaultHistoryCapacity = 1000
+
+// MetadataEphemeral is the metadata key marking a message that is not retained in the
+// history, such as a heartbeat, see Message.Ephemeral
+const MetadataEphemeral = "ephemeral"
+
+// ErrHistoryDisabled is returned by GetHistory on a bus that retains no history
+var ErrHistoryDisabled = errors.New("message history is not enabled")
+
+// historyEntry is a retained message with the entities it concerns: its sender and
+// the entities it was addressed or delivered to
+type historyEntry struct {
+	msg      Message
+	entities map[string]bool
+}
+
+// messageHistory retains the most recent messages in a ring buffer so entities can
+// backfill their conversation context. A nil history retains nothing.
+type messageHistory struct {
+	entries []*historyEntry // Ring buffer, oldest at next once full
+	next    int
+	byID    map[string]*historyEntry
+	mu      sync.Mutex
+}
+
+// newMessageHistory creates a history of the capacity, or nil for no history
+func newMessageHistory(capacity int) *messageHistory {
+	if capacity <= 0 {
+		return nil
+	}
+	return &messageHistory{entries: make([]*historyEntry, 0, capacity), byID: make(map[string]*historyEntry)}
+}
+
+// record retains the message for the entities; recording a message again adds the
+// entities to its existing entry. Ephemeral messages are not retained.
+func (h *messageHistory) record(msg Message, entityIDs ...string) {
+	if h == nil || msg.IsEphemeral() {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry, exists := h.byID[msg.ID]
+	if !exists {
+		entry = &historyEntry{msg: msg, entities: make(map[string]bool, len(entityIDs))}
+		if len(h.entries) < cap(h.entries) {
+			h.entries = append(h.entries, entry)
+		} else {
+			delete(h.byID, h.entries[h.next].msg.ID)
+			h.entries[h.next] = entry
+			h.next = (h.next + 1) % len(h.entries)
+		}
+		h.byID[msg.ID] = entry
+	}
+	for _, entityID := range entityIDs {
+		entry.entities[entityID] = true
+	}
+}
+
+// query returns the retained messages concerning the entity with a timestamp at or
+// after since, oldest first; a positive limit keeps only the most recent ones
+func (h *messageHistory) query(entityID string, since time.Time, limit int) ([]Message, error) {
+	if h == nil {
+		return nil, ErrHistoryDisabled
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var messages []Message
+	for i := range h.entries {
+		entry := h.entries[(h.next+i)%len(h.entries)]
+		if entry.entities[entityID] && !entry.msg.Timestamp.Before(since) {
+			messages = append(messages, entry.msg)
+		}
+	}
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return messages, nil
+}
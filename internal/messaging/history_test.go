@@ -0,0 +1,127 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"goproduct/internal/knowledge"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// texts returns the text content of the messages
+func texts(messages []Message) []string {
+	result := make([]string, len(messages))
+	for i, msg := range messages {
+		result[i] = string(msg.Content)
+	}
+	return result
+}
+
+func TestHistoryDisabledByDefault(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	_, err := bus.GetHistory("bob", time.Time{}, 0)
+	assert.ErrorIs(t, err, ErrHistoryDisabled)
+}
+
+func TestHistoryByEntity(t *testing.T) {
+	bus := NewMemoryMessageBus(WithHistory(10))
+	bus.Subscribe("bob", func(msg Message) error { return nil })
+	bus.Subscribe("carol", func(msg Message) error { return nil })
+	require.NoError(t, bus.CreateGroup("team", "Team", []string{"bob", "carol"}))
+
+	require.NoError(t, publishText(bus, []string{"bob"}, "direct"))
+	require.NoError(t, bus.Publish(NewTextMessage("bob", []string{"dave"}, "reply")))
+	require.NoError(t, publishText(bus, []string{"team"}, "group"))
+	require.NoError(t, publishText(bus, []string{BroadcastAddress}, "everyone"))
+	require.NoError(t, publishText(bus, []string{"carol"}, "not for bob"))
+
+	history, err := bus.GetHistory("bob", time.Time{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"direct", "reply", "group", "everyone"}, texts(history))
+
+	// The limit keeps the most recent messages
+	history, _ = bus.GetHistory("bob", time.Time{}, 2)
+	assert.Equal(t, []string{"group", "everyone"}, texts(history))
+
+	// Unsubscribed entities still have the messages addressed to them
+	history, _ = bus.GetHistory("dave", time.Time{}, 0)
+	assert.Equal(t, []string{"reply"}, texts(history))
+}
+
+func TestHistorySinceAndCapacity(t *testing.T) {
+	bus := NewMemoryMessageBus(WithHistory(3))
+	old := NewTextMessage("alice", []string{"bob"}, "old")
+	old.Timestamp = time.Now().Add(-time.Hour)
+	require.NoError(t, bus.Publish(old))
+	for _, text := range []string{"one", "two", "three"} {
+		require.NoError(t, publishText(bus, []string{"bob"}, text))
+	}
+
+	// The oldest message fell out of the ring buffer
+	history, _ := bus.GetHistory("bob", time.Time{}, 0)
+	assert.Equal(t, []string{"one", "two", "three"}, texts(history))
+
+	history, _ = bus.GetHistory("bob", time.Now().Add(time.Minute), 0)
+	assert.Empty(t, history)
+}
+
+func TestHistorySkipsEphemeralMessages(t *testing.T) {
+	bus := NewMemoryMessageBus(WithHistory(10))
+	delivered := make(chan string, 2)
+	bus.Subscribe("bob", func(msg Message) error { delivered <- string(msg.Content); return nil })
+
+	beat := NewTextMessage("alice", []string{"bob"}, "heartbeat").Ephemeral()
+	require.NoError(t, bus.Publish(beat))
+	require.NoError(t, publishText(bus, []string{"bob"}, "hello"))
+
+	history, _ := bus.GetHistory("bob", time.Time{}, 0)
+	assert.Equal(t, []string{"hello"}, texts(history))
+	assert.ElementsMatch(t, []string{"heartbeat", "hello"}, []string{<-delivered, <-delivered})
+}
+
+func TestRemoteBusHistory(t *testing.T) {
+	server := newFakeNats(t, "")
+	busA, err := NewNatsMessageBus(server.url(), WithNatsHistory(10))
+	require.NoError(t, err)
+	defer busA.Close()
+	busB, err := NewNatsMessageBus(server.url(), WithNatsHistory(10))
+	require.NoError(t, err)
+	defer busB.Close()
+
+	received := make(chan Message, 1)
+	require.NoError(t, busB.Subscribe("bob", func(msg Message) error { received <- msg; return nil }))
+	require.NoError(t, busA.Publish(NewTextMessage("alice", []string{"bob"}, "hello")))
+	assert.Equal(t, "hello", receiveText(t, received))
+
+	// Each process retains the messages of its own entities
+	history, _ := busA.GetHistory("alice", time.Time{}, 0)
+	assert.Equal(t, []string{"hello"}, texts(history))
+	history, _ = busB.GetHistory("bob", time.Time{}, 0)
+	assert.Equal(t, []string{"hello"}, texts(history))
+	history, _ = busA.GetHistory("bob", time.Time{}, 0)
+	assert.Empty(t, history)
+}
+
+func TestPersistentMessageBusHistory(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	bus := NewPersistentMessageBus(NewMemoryMessageBus(), store)
+	require.NoError(t, bus.CreateGroup("team", "Team", []string{"bob"}))
+
+	start := time.Now().Add(-time.Minute)
+	for i, text := range []string{"direct", "group", "everyone", "elsewhere"} {
+		recipients := map[string]string{"direct": "bob", "group": "team", "everyone": BroadcastAddress, "elsewhere": "carol"}
+		msg := NewTextMessage("alice", []string{recipients[text]}, text)
+		msg.Timestamp = start.Add(time.Duration(i) * time.Second)
+		require.NoError(t, bus.Publish(msg))
+	}
+
+	history, err := bus.GetHistory("bob", time.Time{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"direct", "group", "everyone"}, texts(history))
+	history, _ = bus.GetHistory("bob", time.Time{}, 1)
+	assert.Equal(t, []string{"everyone"}, texts(history))
+	history, _ = bus.GetHistory("alice", start.Add(2*time.Second), 0)
+	assert.Equal(t, []string{"everyone", "elsewhere"}, texts(history))
+}
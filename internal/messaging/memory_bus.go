@@ -1,6 +1,8 @@
 package messaging
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"goproduct/internal/logging"
 	"goproduct/internal/tracing"
@@ -10,47 +12,109 @@ import (
 
 // MemoryMessageBus implements MessageBus using in-knowledge structures
 type MemoryMessageBus struct {
-	subscriptions map[string]MessageHandler
+	subscriptions map[string]subscription
+	topics        topicSubscriptions // Topic patterns and the entities subscribed with them
 	groups        map[string]*Group
 	tracer        tracing.Tracer
 	logger        *logging.Logger
+	deliveries    *deliveryTracker     // Acknowledged deliveries and their retries
+	deadLetters   *deadLetterQueue     // Deliveries that failed
+	queues        *recipientQueues     // Runs deliveries according to the delivery mode
+	scheduler     *scheduler           // Holds messages until their DeliverAt
+	middleware    middlewareChain      // Wraps every delivery
+	patterns      patternSubscriptions // Handlers for the entities matching a pattern
+	history       *messageHistory      // Recent messages by entity, nil unless enabled
 	mu            sync.RWMutex
 }
 
-// Group represents a message group with members
-type Group struct {
-	ID      string
-	Name    string
-	Members map[string]bool
+// MemoryBusOption configures a MemoryMessageBus
+type MemoryBusOption func(*memoryBusConfig)
+
+// memoryBusConfig holds the options of a MemoryMessageBus
+type memoryBusConfig struct {
+	queueDepth int
+	workers    int
+	overflow   OverflowPolicy
+	history    int
+}
+
+// WithHistory retains the last capacity messages published through the bus for
+// GetHistory; see DefaultHistoryCapacity
+func WithHistory(capacity int) MemoryBusOption {
+	return func(c *memoryBusConfig) { c.history = capacity }
+}
+
+// WithQueueDepth bounds each recipient's queue to depth waiting deliveries, handled by a
+// pool of workers per recipient instead of a goroutine per delivery
+func WithQueueDepth(depth int) MemoryBusOption {
+	return func(c *memoryBusConfig) { c.queueDepth = depth }
+}
+
+// WithQueueWorkers sets the workers per recipient for bounded queues in
+// DeliveryConcurrent mode, DefaultQueueWorkers by default
+func WithQueueWorkers(workers int) MemoryBusOption {
+	return func(c *memoryBusConfig) { c.workers = workers }
+}
+
+// WithOverflowPolicy sets what happens when a bounded queue is full, OverflowBlock by
+// default. Publish waits for space without holding the bus lock, so subscriptions and
+// retries go on meanwhile. A handler publishing to its own recipient's full queue still
+// waits for one of the recipient's other workers, forever in DeliveryOrdered mode where
+// it is the only one; such recipients should use another policy.
+func WithOverflowPolicy(policy OverflowPolicy) MemoryBusOption {
+	return func(c *memoryBusConfig) { c.overflow = policy }
 }
 
 // NewMemoryMessageBus creates a new in-knowledge message bus
-func NewMemoryMessageBus() *MemoryMessageBus {
-	return &MemoryMessageBus{
-		subscriptions: make(map[string]MessageHandler),
-		groups:        make(map[string]*Group),
-		tracer:        tracing.NewNoopTracer(), // Default to no-op tracer
-		logger:        logging.Get(),           // Use default logger
-	}
+func NewMemoryMessageBus(opts ...MemoryBusOption) *MemoryMessageBus {
+	return NewMemoryMessageBusWithTracer(tracing.NewNoopTracer(), opts...) // Default to no-op tracer
 }
 
 // NewMemoryMessageBusWithTracer creates a new in-knowledge message bus with a custom tracer
-func NewMemoryMessageBusWithTracer(tracer tracing.Tracer) *MemoryMessageBus {
-	return &MemoryMessageBus{
-		subscriptions: make(map[string]MessageHandler),
+func NewMemoryMessageBusWithTracer(tracer tracing.Tracer, opts ...MemoryBusOption) *MemoryMessageBus {
+	config := memoryBusConfig{workers: DefaultQueueWorkers, overflow: OverflowBlock}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	m := &MemoryMessageBus{
+		subscriptions: make(map[string]subscription),
+		topics:        make(topicSubscriptions),
 		groups:        make(map[string]*Group),
 		tracer:        tracer,
-		logger:        logging.Get(), // Use default logger
+		logger:        logging.Get().Component("messaging"), // Use default logger
 	}
+	m.deadLetters = newDeadLetterQueue()
+	m.deliveries = newDeliveryTracker(m.deadLetters)
+	m.queues = newRecipientQueues()
+	m.queues.setLimits(config.queueDepth, config.workers, config.overflow)
+	m.scheduler = newScheduler(m.Publish)
+	m.history = newMessageHistory(config.history)
+	return m
 }
 
-// Publish sends a message to all its recipients
+// Publish sends a message to all its recipients. A message with a DeliverAt in the
+// future is held back and published then; an expired message is dropped. With bounded
+// queues, Publish may wait for space or fail for recipients whose queue is full,
+// depending on the overflow policy; it still delivers to the other recipients. A message
+// to an invalid topic is rejected without being delivered to any recipient.
 func (m *MemoryMessageBus) Publish(msg Message) error {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	if err := validateTopics(msg.Recipients); err != nil {
+		return err
+	}
 
-	// Track recipients for delivery
-	delivered := make(map[string]bool)
+	m.mu.RLock()
+	if now := time.Now(); msg.scheduled(now) {
+		m.scheduler.schedule(msg)
+		traceScheduled(m.tracer, msg)
+		m.mu.RUnlock()
+		return nil
+	} else if msg.Expired(now) {
+		traceExpired(m.tracer, msg, "")
+		m.mu.RUnlock()
+		return nil
+	}
+	messagesPublished.Inc("memory")
 
 	// Log the message being sent
 	m.logger.Debug("Message published",
@@ -68,81 +132,62 @@ func (m *MemoryMessageBus) Publish(msg Message) error {
 		SourceID:  msg.SenderID,
 		ObjectID:  msg.ID,
 		Message:   "Message published",
-		Metadata: map[string]interface{}{
-			"contentType": msg.ContentType,
-			"recipients":  msg.Recipients,
-		},
+		Metadata:  publishMetadata(msg, ""),
 	})
 
-	// Handle each recipient
+	targets, concerned := m.route(msg)
+	m.mu.RUnlock()
+
+	// Dispatch without the lock, as a full queue may make dispatch wait for a worker
+	// that needs the lock to retry a delivery, collecting the recipients whose queue
+	// is full
+	var errs []error
+	for _, t := range targets {
+		errs = append(errs, m.dispatch(t.recipientID, t.sub, t.subscribed, msg, t.via, t.from))
+	}
+
+	m.history.record(msg, concerned...)
+	return errors.Join(errs...)
+}
+
+// target is a recipient a message is dispatched to, with the subscription it had when
+// the message was routed
+type target struct {
+	recipientID string
+	sub         subscription
+	subscribed  bool
+	via, from   string
+}
+
+// route returns the recipients of a message, followed by the pattern subscriptions it
+// reaches, and the entities it concerns (must be called with read lock held)
+func (m *MemoryMessageBus) route(msg Message) ([]target, []string) {
+	var targets []target
+	var matches patternMatches
+	concerned := []string{msg.SenderID}
 	for _, recipientID := range msg.Recipients {
 		// Handle broadcast
 		if recipientID == BroadcastAddress {
-			for subID, handler := range m.subscriptions {
+			for subID, sub := range m.subscriptions {
 				if subID != msg.SenderID { // Don't send to self
-					// Pass message to handler in a goroutine
-					go func(recID string, h MessageHandler, message Message) {
-						// Recover from panics in message handlers
-						defer func() {
-							if r := recover(); r != nil {
-								m.tracer.Trace(tracing.Event{
-									Timestamp: time.Now(),
-									Component: tracing.ComponentMessaging,
-									Operation: tracing.OperationReceive,
-									Level:     tracing.LevelError,
-									SourceID:  message.SenderID,
-									TargetID:  recID,
-									ObjectID:  message.ID,
-									Message:   fmt.Sprintf("Panic in message handler: %v", r),
-								})
-								m.logger.Error("Panic in message handler",
-									"error", r,
-									"message_id", message.ID,
-									"sender", message.SenderID,
-									"recipient", recID)
-							}
-						}()
-
-						// Log the message being received
-						m.logger.Debug("Message received via broadcast",
-							"message_id", message.ID,
-							"sender", message.SenderID,
-							"recipient", recID)
-
-						// Trace the message being received
-						m.tracer.Trace(tracing.Event{
-							Timestamp: message.Timestamp,
-							Component: tracing.ComponentMessaging,
-							Operation: tracing.OperationReceive,
-							Level:     tracing.LevelInfo,
-							SourceID:  message.SenderID,
-							TargetID:  recID,
-							ObjectID:  message.ID,
-							Message:   "Message received via broadcast",
-						})
-
-						// Call the handler and capture any error
-						if err := h(message); err != nil {
-							// Log the error
-							m.logger.Error("Error in message handler",
-								"error", err,
-								"message_id", message.ID,
-								"sender", message.SenderID,
-								"recipient", recID)
-
-							m.tracer.Trace(tracing.Event{
-								Timestamp: time.Now(),
-								Component: tracing.ComponentMessaging,
-								Operation: tracing.OperationReceive,
-								Level:     tracing.LevelError,
-								SourceID:  message.SenderID,
-								TargetID:  recID,
-								ObjectID:  message.ID,
-								Message:   fmt.Sprintf("Error in message handler: %v", err),
-							})
-						}
-					}(subID, handler, msg)
-					delivered[subID] = true
+					concerned = append(concerned, subID)
+					m.patterns.match(subID, true, &matches)
+					targets = append(targets, target{subID, sub, true, "broadcast", ""})
+				}
+			}
+			continue
+		}
+
+		// Deliver to the subscribers of a topic
+		if topic, ok := topicOf(recipientID); ok {
+			for _, subscriberID := range m.topics.subscribers(topic) {
+				if subscriberID != msg.SenderID { // Don't send to self
+					concerned = append(concerned, subscriberID)
+					sub, exists := m.subscriptions[subscriberID]
+					if m.patterns.match(subscriberID, exists, &matches) {
+						continue
+					}
+					targets = append(targets, target{subscriberID, sub, exists, "topic", topic})
 				}
 			}
 			continue
@@ -165,161 +210,300 @@ func (m *MemoryMessageBus) Publish(msg Message) error {
 			// Deliver to each group member
 			for memberID := range group.Members {
 				if memberID != msg.SenderID { // Don't send to self
-					if handler, exists := m.subscriptions[memberID]; exists {
-						// Pass message to handler in a goroutine
-						go func(recID string, h MessageHandler, message Message, grpID string) {
-							// Recover from panics in message handlers
-							defer func() {
-								if r := recover(); r != nil {
-									m.tracer.Trace(tracing.Event{
-										Timestamp: time.Now(),
-										Component: tracing.ComponentMessaging,
-										Operation: tracing.OperationReceive,
-										Level:     tracing.LevelError,
-										SourceID:  message.SenderID,
-										TargetID:  recID,
-										ObjectID:  message.ID,
-										Message:   fmt.Sprintf("Panic in group message handler: %v", r),
-										Metadata: map[string]interface{}{
-											"groupID": grpID,
-										},
-									})
-									m.logger.Error("Panic in group message handler",
-										"error", r,
-										"message_id", message.ID,
-										"sender", message.SenderID,
-										"recipient", recID,
-										"group_id", grpID)
-								}
-							}()
-
-							// Log the message being received by a group member
-							m.logger.Debug("Message received via group",
-								"message_id", message.ID,
-								"sender", message.SenderID,
-								"recipient", recID,
-								"group_id", grpID)
-
-							// Trace the message being received by a group member
-							m.tracer.Trace(tracing.Event{
-								Timestamp: message.Timestamp,
-								Component: tracing.ComponentMessaging,
-								Operation: tracing.OperationReceive,
-								Level:     tracing.LevelInfo,
-								SourceID:  message.SenderID,
-								TargetID:  recID,
-								ObjectID:  message.ID,
-								Message:   "Message received via group",
-								Metadata: map[string]interface{}{
-									"groupID": grpID,
-								},
-							})
-
-							// Call the handler and capture any error
-							if err := h(message); err != nil {
-								// Log the error
-								m.logger.Error("Error in group message handler",
-									"error", err,
-									"message_id", message.ID,
-									"sender", message.SenderID,
-									"recipient", recID,
-									"group_id", grpID)
-
-								m.tracer.Trace(tracing.Event{
-									Timestamp: time.Now(),
-									Component: tracing.ComponentMessaging,
-									Operation: tracing.OperationReceive,
-									Level:     tracing.LevelError,
-									SourceID:  message.SenderID,
-									TargetID:  recID,
-									ObjectID:  message.ID,
-									Message:   fmt.Sprintf("Error in group message handler: %v", err),
-									Metadata: map[string]interface{}{
-										"groupID": grpID,
-									},
-								})
-							}
-						}(memberID, handler, msg, recipientID)
-						delivered[memberID] = true
+					concerned = append(concerned, memberID)
+					sub, exists := m.subscriptions[memberID]
+					if m.patterns.match(memberID, exists, &matches) {
+						continue
 					}
+					targets = append(targets, target{memberID, sub, exists, "group", recipientID})
 				}
 			}
 			continue
 		}
 
 		// Direct message to an entity
-		if handler, ok := m.subscriptions[recipientID]; ok {
-			// Pass message to handler in a goroutine
-			go func(recID string, h MessageHandler, message Message) {
-				// Recover from panics in message handlers
-				defer func() {
-					if r := recover(); r != nil {
-						m.tracer.Trace(tracing.Event{
-							Timestamp: time.Now(),
-							Component: tracing.ComponentMessaging,
-							Operation: tracing.OperationReceive,
-							Level:     tracing.LevelError,
-							SourceID:  message.SenderID,
-							TargetID:  recID,
-							ObjectID:  message.ID,
-							Message:   fmt.Sprintf("Panic in direct message handler: %v", r),
-						})
-						m.logger.Error("Panic in direct message handler",
-							"error", r,
-							"message_id", message.ID,
-							"sender", message.SenderID,
-							"recipient", recID)
-					}
-				}()
-
-				// Log the message being received
-				m.logger.Debug("Message received directly",
-					"message_id", message.ID,
-					"sender", message.SenderID,
-					"recipient", recID)
-
-				// Trace the message being received
-				m.tracer.Trace(tracing.Event{
-					Timestamp: message.Timestamp,
-					Component: tracing.ComponentMessaging,
-					Operation: tracing.OperationReceive,
-					Level:     tracing.LevelInfo,
-					SourceID:  message.SenderID,
-					TargetID:  recID,
-					ObjectID:  message.ID,
-					Message:   "Message received directly",
-				})
-
-				// Call the handler and capture any error
-				if err := h(message); err != nil {
-					// Log the error
-					m.logger.Error("Error in direct message handler",
-						"error", err,
-						"message_id", message.ID,
-						"sender", message.SenderID,
-						"recipient", recID)
-
-					m.tracer.Trace(tracing.Event{
-						Timestamp: time.Now(),
-						Component: tracing.ComponentMessaging,
-						Operation: tracing.OperationReceive,
-						Level:     tracing.LevelError,
-						SourceID:  message.SenderID,
-						TargetID:  recID,
-						ObjectID:  message.ID,
-						Message:   fmt.Sprintf("Error in direct message handler: %v", err),
-					})
-				}
-			}(recipientID, handler, msg)
-			delivered[recipientID] = true
+		concerned = append(concerned, recipientID)
+		sub, ok := m.subscriptions[recipientID]
+		if m.patterns.match(recipientID, ok, &matches) {
+			continue
+		}
+		targets = append(targets, target{recipientID, sub, ok, "direct", ""})
+	}
+
+	// Deliver to the pattern subscriptions, keyed by their pattern
+	matches.each(func(ps *patternSubscription, entityID string) {
+		targets = append(targets, target{ps.pattern, ps.sub, true, "pattern", entityID})
+	})
+	return targets, concerned
+}
+
+// DeliverTo delivers a message that arrived over another transport, such as a bridge
+// to a remote bus, to a subscriber of this bus as if it was addressed to it directly.
+// The message is not routed to its own recipients. Pattern subscriptions and history
+// see the delivery as usual.
+func (m *MemoryMessageBus) DeliverTo(entityID string, msg Message) error {
+	m.mu.RLock()
+	if msg.Expired(time.Now()) {
+		traceExpired(m.tracer, msg, entityID)
+		m.mu.RUnlock()
+		return nil
+	}
+
+	var targets []target
+	var matches patternMatches
+	sub, ok := m.subscriptions[entityID]
+	if !m.patterns.match(entityID, ok, &matches) {
+		targets = append(targets, target{entityID, sub, ok, "direct", ""})
+	}
+	matches.each(func(ps *patternSubscription, entityID string) {
+		targets = append(targets, target{ps.pattern, ps.sub, true, "pattern", entityID})
+	})
+	m.mu.RUnlock()
+
+	// Dispatch without the lock, as Publish does
+	var errs []error
+	for _, t := range targets {
+		errs = append(errs, m.dispatch(t.recipientID, t.sub, t.subscribed, msg, t.via, t.from))
+	}
+
+	m.history.record(msg, entityID)
+	return errors.Join(errs...)
+}
+
+// Request publishes a message and waits for its reply, see Request
+func (m *MemoryMessageBus) Request(ctx context.Context, msg Message) (Message, error) {
+	return Request(ctx, m, msg)
+}
+
+// dispatch passes the message to a recipient's handler on its own goroutine, or through
+// the recipient's queue in DeliveryOrdered mode or with a queue depth set. Messages that
+// requested acknowledgement are retried by the delivery tracker, looking up the
+// subscription again for each retry; others get a single attempt if the recipient is
+// subscribed and accepts them. Messages that expired in the meantime are dropped, and
+// failed deliveries and deliveries dropped from a full queue become dead letters. It
+// returns ErrQueueFull if the queue is full under OverflowError. via is "broadcast",
+// "group", "topic", "pattern" or "direct", and from is the group ID or topic the message
+// was published to, or the entity a pattern matched (must be called without the lock, as
+// it may wait for space in a full queue). Pattern subscriptions are dispatched with their
+// pattern as the recipient ID.
+func (m *MemoryMessageBus) dispatch(recipientID string, sub subscription, subscribed bool, msg Message, via, from string) error {
+	if !msg.AckRequested() {
+		if !subscribed || !sub.accepts(msg) {
+			return nil
 		}
+		return m.queues.run(recipientID, msg.Priority, func() {
+			if msg.Expired(time.Now()) {
+				traceExpired(m.tracer, msg, recipientID)
+				return
+			}
+			if err := m.handle(recipientID, sub.handler, msg, via, from); err != nil {
+				m.deadLetters.add(msg, recipientID, 1, err)
+			}
+		}, func(err error) {
+			m.deadLetters.add(msg, recipientID, 0, err)
+		})
 	}
 
+	first := true
+	delivery := m.deliveries.start(msg, recipientID, func() error {
+		if msg.Expired(time.Now()) {
+			traceExpired(m.tracer, msg, recipientID)
+			return errExpired
+		}
+		if !first {
+			m.mu.RLock()
+			sub, subscribed = m.subscription(recipientID, via)
+			m.mu.RUnlock()
+		}
+		first = false
+		if !subscribed {
+			return errNotSubscribed
+		}
+		if !sub.accepts(msg) {
+			return errSkipped
+		}
+		return m.handle(recipientID, sub.handler, msg, via, from)
+	})
+	err := m.queues.run(recipientID, msg.Priority, delivery, func(err error) {
+		m.deliveries.abandon(msg.ID, recipientID, err)
+		m.deadLetters.add(msg, recipientID, 0, err)
+	})
+	if err != nil {
+		m.deliveries.abandon(msg.ID, recipientID, err)
+	}
+	return err
+}
+
+// handle calls a handler, tracing the delivery, and returns the handler error; a panic
+// in the handler is recovered and returned as an error
+func (m *MemoryMessageBus) handle(recipientID string, handler MessageHandler, message Message, via, from string) (err error) {
+	defer func() { recordDelivery(via, err) }()
+
+	// Trace and log texts name how the message arrived
+	received, panicked, failed := "Message received directly", "Panic in direct message handler", "Error in direct message handler"
+	var metadata map[string]interface{}
+	logArgs := []interface{}{"message_id", message.ID, "sender", message.SenderID, "recipient", recipientID}
+	switch via {
+	case "group":
+		received, panicked, failed = "Message received via group", "Panic in group message handler", "Error in group message handler"
+		metadata = map[string]interface{}{"groupID": from}
+		logArgs = append(logArgs, "group_id", from)
+	case "topic":
+		received, panicked, failed = "Message received via topic", "Panic in topic message handler", "Error in topic message handler"
+		metadata = map[string]interface{}{"topic": from}
+		logArgs = append(logArgs, "topic", from)
+	case "pattern":
+		received, panicked, failed = "Message received via pattern", "Panic in pattern message handler", "Error in pattern message handler"
+		metadata = map[string]interface{}{"matched": from}
+		logArgs = append(logArgs, "matched", from)
+	case "broadcast":
+		received, panicked, failed = "Message received via broadcast", "Panic in message handler", "Error in message handler"
+	}
+
+	// Recover from panics in message handlers
+	defer func() {
+		if r := recover(); r != nil {
+			m.tracer.Trace(tracing.Event{
+				Timestamp: time.Now(),
+				Component: tracing.ComponentMessaging,
+				Operation: tracing.OperationReceive,
+				Level:     tracing.LevelError,
+				SourceID:  message.SenderID,
+				TargetID:  recipientID,
+				ObjectID:  message.ID,
+				Message:   fmt.Sprintf("%s: %v", panicked, r),
+				Metadata:  metadata,
+			})
+			m.logger.Error(panicked, append([]interface{}{"error", r}, logArgs...)...)
+			err = fmt.Errorf("panic in message handler: %v", r)
+		}
+	}()
+
+	// Log the message being received
+	m.logger.Debug(received, logArgs...)
+
+	// Trace the message being received
+	m.tracer.Trace(tracing.Event{
+		Timestamp: message.Timestamp,
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationReceive,
+		Level:     tracing.LevelInfo,
+		SourceID:  message.SenderID,
+		TargetID:  recipientID,
+		ObjectID:  message.ID,
+		Message:   received,
+		Metadata:  metadata,
+	})
+
+	// Call the handler through the middleware and capture any error
+	if err := m.middleware.deliver(handler, Delivery{RecipientID: recipientID, Via: via, From: from}, message); err != nil {
+		// Log the error
+		m.logger.Error(failed, append([]interface{}{"error", err}, logArgs...)...)
+
+		m.tracer.Trace(tracing.Event{
+			Timestamp: time.Now(),
+			Component: tracing.ComponentMessaging,
+			Operation: tracing.OperationReceive,
+			Level:     tracing.LevelError,
+			SourceID:  message.SenderID,
+			TargetID:  recipientID,
+			ObjectID:  message.ID,
+			Message:   fmt.Sprintf("%s: %v", failed, err),
+			Metadata:  metadata,
+		})
+		return err
+	}
 	return nil
 }
 
-// Subscribe registers an entity to receive messages
-func (m *MemoryMessageBus) Subscribe(entityID string, handler MessageHandler) error {
+// SetDeliveryMode sets how handlers are run for messages published from now on
+func (m *MemoryMessageBus) SetDeliveryMode(mode DeliveryMode) {
+	m.queues.setMode(mode)
+}
+
+// Use adds middleware wrapping the delivery of every message from now on
+func (m *MemoryMessageBus) Use(middlewares ...Middleware) {
+	m.middleware.use(middlewares...)
+}
+
+// Drain waits until the deliveries started have run, including those of the messages
+// their handlers publish, or the context is done. The retries of a message that requested
+// acknowledgement are part of its delivery, so Drain also waits for them and their
+// backoff, up to the retry policy's last attempt. Messages held for a later DeliverAt are
+// not waited for.
+func (m *MemoryMessageBus) Drain(ctx context.Context) error {
+	return m.queues.drain(ctx)
+}
+
+// QueueStats returns the queue state of every recipient that has used a queue, which
+// happens in DeliveryOrdered mode or with a queue depth set
+func (m *MemoryMessageBus) QueueStats() map[string]QueueStats {
+	return m.queues.stats()
+}
+
+// SetRetryPolicy sets how messages that requested acknowledgement are retried
+func (m *MemoryMessageBus) SetRetryPolicy(policy RetryPolicy) {
+	m.deliveries.setPolicy(policy)
+}
+
+// DeliveryStatus returns the delivery state of a message that requested acknowledgement
+func (m *MemoryMessageBus) DeliveryStatus(messageID string) (DeliveryStatus, error) {
+	return m.deliveries.status(messageID)
+}
+
+// GetDeadLetters returns the messages whose handling failed, oldest first
+func (m *MemoryMessageBus) GetDeadLetters() []DeadLetter {
+	return m.deadLetters.list()
+}
+
+// ReplayDeadLetter removes a dead letter from the queue and delivers its message to
+// the recipient again; if that fails too, the message becomes a new dead letter
+func (m *MemoryMessageBus) ReplayDeadLetter(deadLetterID string) error {
+	m.mu.RLock()
+	letter, err := m.deadLetters.take(deadLetterID)
+	if err != nil {
+		m.mu.RUnlock()
+		return err
+	}
+	via := "direct"
+	sub, ok := m.subscriptions[letter.RecipientID]
+	if !ok {
+		if sub, ok = m.subscription(letter.RecipientID, "pattern"); !ok {
+			m.deadLetters.restore(letter)
+			m.mu.RUnlock()
+			return fmt.Errorf("recipient %s is not subscribed", letter.RecipientID)
+		}
+		via = "pattern"
+	}
+	m.mu.RUnlock()
+
+	// Dispatch without the lock, as Publish does
+	return m.dispatch(letter.RecipientID, sub, true, letter.Message, via, "")
+}
+
+// subscription looks up the subscription of a recipient, which is a pattern for
+// pattern deliveries (must be called with read lock held)
+func (m *MemoryMessageBus) subscription(recipientID, via string) (subscription, bool) {
+	if via == "pattern" {
+		ps, ok := m.patterns.byPattern[recipientID]
+		if !ok {
+			return subscription{}, false
+		}
+		return ps.sub, true
+	}
+	sub, ok := m.subscriptions[recipientID]
+	return sub, ok
+}
+
+// PurgeDeadLetters removes the given dead letters, or all of them if none are given,
+// and returns how many were removed
+func (m *MemoryMessageBus) PurgeDeadLetters(deadLetterIDs ...string) int {
+	return m.deadLetters.purge(deadLetterIDs...)
+}
+
+// Subscribe registers an entity to receive messages. If filters are given, only
+// messages matching at least one of them are passed to the handler.
+func (m *MemoryMessageBus) Subscribe(entityID string, handler MessageHandler, filters ...SubscriptionFilter) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -328,10 +512,10 @@ func (m *MemoryMessageBus) Subscribe(entityID string, handler MessageHandler) er
 		return fmt.Errorf("message handler cannot be nil")
 	}
 
-	m.subscriptions[entityID] = handler
+	m.subscriptions[entityID] = subscription{handler: handler, filters: filters}
 
 	// Log the subscription
-	m.logger.Info("Entity subscribed to message bus", "entity_id", entityID)
+	m.logger.Info("Entity subscribed to message bus", "entity_id", entityID, "filters", len(filters))
 
 	// Trace the subscription
 	m.tracer.Trace(tracing.Event{
@@ -378,17 +562,7 @@ func (m *MemoryMessageBus) CreateGroup(groupID, name string, members []string) e
 		return fmt.Errorf("group with ID %s already exists", groupID)
 	}
 
-	group := &Group{
-		ID:      groupID,
-		Name:    name,
-		Members: make(map[string]bool),
-	}
-
-	for _, memberID := range members {
-		group.Members[memberID] = true
-	}
-
-	m.groups[groupID] = group
+	m.groups[groupID] = newGroup(groupID, name, members)
 
 	// Log group creation
 	m.logger.Info("Message group created",
@@ -449,7 +623,7 @@ func (m *MemoryMessageBus) RemoveFromGroup(groupID, entityID string) error {
 		return fmt.Errorf("group with ID %s does not exist", groupID)
 	}
 
-	delete(group.Members, entityID)
+	group.removeMember(entityID)
 
 	// Trace member removal
 	m.tracer.Trace(tracing.Event{
@@ -483,6 +657,245 @@ func (m *MemoryMessageBus) GetGroupMembers(groupID string) ([]string, error) {
 	return members, nil
 }
 
+// RemoveGroup deletes a group; its messages are no longer delivered to its members
+func (m *MemoryMessageBus) RemoveGroup(groupID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.groups[groupID]; !exists {
+		return fmt.Errorf("group with ID %s does not exist", groupID)
+	}
+	delete(m.groups, groupID)
+
+	// Log group removal
+	m.logger.Info("Message group removed", "group_id", groupID)
+
+	// Trace group removal
+	m.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationDelete,
+		Level:     tracing.LevelInfo,
+		ObjectID:  groupID,
+		Message:   "Message group removed",
+	})
+
+	return nil
+}
+
+// RenameGroup changes the display name of a group
+func (m *MemoryMessageBus) RenameGroup(groupID, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group, exists := m.groups[groupID]
+	if !exists {
+		return fmt.Errorf("group with ID %s does not exist", groupID)
+	}
+	group.Name = name
+
+	// Trace the rename
+	m.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationUpdate,
+		Level:     tracing.LevelInfo,
+		ObjectID:  groupID,
+		Message:   "Message group renamed",
+		Metadata:  map[string]interface{}{"name": name},
+	})
+
+	return nil
+}
+
+// GetGroup returns a copy of a group
+func (m *MemoryMessageBus) GetGroup(groupID string) (Group, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	group, exists := m.groups[groupID]
+	if !exists {
+		return Group{}, fmt.Errorf("group with ID %s does not exist", groupID)
+	}
+	return group.clone(), nil
+}
+
+// ListGroups returns copies of all groups sorted by ID
+func (m *MemoryMessageBus) ListGroups() []Group {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return listGroups(m.groups)
+}
+
+// SetMemberRole gives a member of a group a role. Making a member the owner demotes
+// the previous owner to admin.
+func (m *MemoryMessageBus) SetMemberRole(groupID, entityID string, role GroupRole) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group, exists := m.groups[groupID]
+	if !exists {
+		return fmt.Errorf("group with ID %s does not exist", groupID)
+	}
+	if err := group.setRole(entityID, role); err != nil {
+		return err
+	}
+
+	// Trace the role change
+	m.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationUpdate,
+		Level:     tracing.LevelInfo,
+		TargetID:  entityID,
+		ObjectID:  groupID,
+		Message:   "Message group role changed",
+		Metadata:  map[string]interface{}{"role": string(role)},
+	})
+
+	return nil
+}
+
+// SetMemberMetadata replaces the metadata of a member of a group
+func (m *MemoryMessageBus) SetMemberMetadata(groupID, entityID string, metadata map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group, exists := m.groups[groupID]
+	if !exists {
+		return fmt.Errorf("group with ID %s does not exist", groupID)
+	}
+	if err := group.setMetadata(entityID, metadata); err != nil {
+		return err
+	}
+
+	// Trace the metadata change
+	m.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationUpdate,
+		Level:     tracing.LevelInfo,
+		TargetID:  entityID,
+		ObjectID:  groupID,
+		Message:   "Message group member metadata changed",
+	})
+
+	return nil
+}
+
+// SubscribeTopic delivers the messages published to topics matching the pattern to the
+// entity's subscription; see MatchTopic for the wildcards
+func (m *MemoryMessageBus) SubscribeTopic(entityID, pattern string) error {
+	if err := validateTopicPattern(pattern); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.topics.add(entityID, pattern)
+
+	// Trace the topic subscription
+	m.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationJoin,
+		Level:     tracing.LevelInfo,
+		TargetID:  entityID,
+		ObjectID:  pattern,
+		Message:   "Entity subscribed to topic",
+	})
+
+	return nil
+}
+
+// UnsubscribeTopic stops delivering the topics matching the pattern to the entity
+func (m *MemoryMessageBus) UnsubscribeTopic(entityID, pattern string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.topics.remove(entityID, pattern) {
+		return fmt.Errorf("entity %s is not subscribed to topic %s", entityID, pattern)
+	}
+
+	// Trace the topic unsubscription
+	m.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationLeave,
+		Level:     tracing.LevelInfo,
+		TargetID:  entityID,
+		ObjectID:  pattern,
+		Message:   "Entity unsubscribed from topic",
+	})
+
+	return nil
+}
+
+// GetHistory returns the retained messages sent by the entity or addressed to it,
+// directly or through a group, topic or broadcast, with a timestamp at or after since,
+// oldest first. A positive limit keeps only the most recent ones. It fails with
+// ErrHistoryDisabled unless the bus was created WithHistory.
+func (m *MemoryMessageBus) GetHistory(entityID string, since time.Time, limit int) ([]Message, error) {
+	return m.history.query(entityID, since, limit)
+}
+
+// SubscribePattern registers a handler for the messages to entities matching the
+// pattern, a glob such as "agent:*" (see path.Match) or a regular expression prefixed
+// with RegexpPatternPrefix. The mode decides how it ranks against exact subscriptions;
+// see PatternMode for the precedence rules. Subscribing the same pattern again replaces
+// its handler.
+func (m *MemoryMessageBus) SubscribePattern(pattern string, mode PatternMode, handler MessageHandler, filters ...SubscriptionFilter) error {
+	ps, err := newPatternSubscription(pattern, mode, handler, filters)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.patterns.add(ps)
+
+	// Log the subscription
+	m.logger.Info("Pattern subscribed to message bus", "pattern", pattern, "filters", len(filters))
+
+	// Trace the subscription
+	m.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationCreate,
+		Level:     tracing.LevelInfo,
+		TargetID:  pattern,
+		Message:   "Pattern subscribed to message bus",
+	})
+
+	return nil
+}
+
+// UnsubscribePattern removes the handler registered for a pattern
+func (m *MemoryMessageBus) UnsubscribePattern(pattern string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.patterns.remove(pattern) {
+		return fmt.Errorf("pattern %s is not subscribed", pattern)
+	}
+
+	// Log the unsubscription
+	m.logger.Info("Pattern unsubscribed from message bus", "pattern", pattern)
+
+	// Trace the unsubscription
+	m.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationDelete,
+		Level:     tracing.LevelInfo,
+		TargetID:  pattern,
+		Message:   "Pattern unsubscribed from message bus",
+	})
+
+	return nil
+}
+
 // SetTracer sets the tracer for this message bus
 func (m *MemoryMessageBus) SetTracer(tracer tracing.Tracer) {
 	m.mu.Lock()
@@ -514,7 +927,7 @@ func (m *MemoryMessageBus) SetLogger(logger *logging.Logger) {
 
 	// If nil logger is provided, use the default logger
 	if logger == nil {
-		m.logger = logging.Get()
+		m.logger = logging.Get().Component("messaging")
 	} else {
 		m.logger = logger
 	}
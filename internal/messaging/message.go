@@ -1,10 +1,13 @@
 package messaging
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
+	"goproduct/internal/tracing"
+
 	"github.com/google/uuid"
 )
 
@@ -13,21 +16,57 @@ const (
 	BroadcastAddress = "*"
 
 	// Content types
-	ContentTypeText    = "text/plain"
-	ContentTypeJSON    = "application/json"
-	ContentTypeCommand = "application/x-command"
+	ContentTypeText      = "text/plain"
+	ContentTypeJSON      = "application/json"
+	ContentTypeCommand   = "application/x-command"
+	ContentTypeMultipart = "application/x-multipart+json" // Text with attachments, see MultipartContent
+)
+
+// MetadataThreadID is the metadata key holding the conversation thread of a message;
+// replies keep it, see NewReplyMessage
+const MetadataThreadID = "thread_id"
+
+// MetadataStream is the metadata key of a request asking for its reply as it is written,
+// in partial replies sent ahead of the reply; see RequestStream
+const MetadataStream = "stream"
+
+// MetadataPartial is the metadata key marking a partial reply, whose content is the reply
+// as written so far; the reply itself follows it
+const MetadataPartial = "partial"
+
+// MetadataCorrelationID is the metadata key holding the correlation ID of a message,
+// generated when a human sends it and kept by everything that comes of it: the agent's
+// work, its language model calls, the reply and their trace events. Unlike CorrelationID,
+// which matches a reply to its request, it is kept across retries and handoffs.
+const MetadataCorrelationID = tracing.MetadataCorrelationID
+
+// Priority orders the deliveries of a recipient in DeliveryOrdered mode; higher
+// priorities are handled first
+type Priority int
+
+// Message priorities
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+	PriorityUrgent Priority = 2 // Control messages that must jump the queue
 )
 
 // Message represents communication between entities
 type Message struct {
-	ID          string   // UUID for the message
-	SenderID    string   // UUID of the sending entity
-	Recipients  []string // UUIDs of recipient entities
-	ContentType string   // MIME type
-	Content     []byte   // Raw binary content
-	ReplyToID   string   // UUID of message being replied to
-	Timestamp   time.Time
-	Metadata    map[string]string
+	ID            string    // UUID for the message
+	SenderID      string    // UUID of the sending entity
+	Recipients    []string  // UUIDs of recipient entities
+	ContentType   string    // MIME type
+	Content       []byte    // Raw binary content
+	ReplyToID     string    // UUID of message being replied to
+	ReplyTo       string    // Address replies should be sent to instead of the sender
+	CorrelationID string    // Ties replies to the request they answer, carried over by NewReplyMessage
+	Priority      Priority  // Delivery priority, PriorityNormal unless set
+	ExpiresAt     time.Time // The message is dropped instead of delivered from then on; zero never expires
+	DeliverAt     time.Time // The bus holds the message back until then; zero delivers immediately
+	Timestamp     time.Time
+	Metadata      map[string]string
 }
 
 // NewMessage creates a new message
@@ -53,17 +92,46 @@ func NewJSONMessage(senderID string, recipients []string, jsonData []byte) Messa
 	return NewMessage(senderID, recipients, ContentTypeJSON, jsonData)
 }
 
-// NewReplyMessage creates a new message in reply to another message
+// Attachment is a file sent along with the text of a multipart message, e.g. a screenshot
+type Attachment struct {
+	Name      string `json:"name,omitempty"`
+	MediaType string `json:"media_type"`
+	Data      []byte `json:"data"`
+	AltText   string `json:"alt_text,omitempty"` // Describes the attachment to readers that cannot see it
+}
+
+// MultipartContent is the content of a ContentTypeMultipart message
+type MultipartContent struct {
+	Text        string       `json:"text"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// NewMultipartMessage creates a message of text with attachments
+func NewMultipartMessage(senderID string, recipients []string, text string, attachments []Attachment) (Message, error) {
+	content, err := json.Marshal(MultipartContent{Text: text, Attachments: attachments})
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to marshal multipart content: %w", err)
+	}
+	return NewMessage(senderID, recipients, ContentTypeMultipart, content), nil
+}
+
+// NewReplyMessage creates a new message in reply to another message, addressed to its
+// ReplyTo address or else its sender
 func NewReplyMessage(senderID string, originalMsg Message, contentType string, content []byte) Message {
 	// Create a new message with a unique ID
-	msg := NewMessage(senderID, []string{originalMsg.SenderID}, contentType, content)
+	recipient := originalMsg.SenderID
+	if originalMsg.ReplyTo != "" {
+		recipient = originalMsg.ReplyTo
+	}
+	msg := NewMessage(senderID, []string{recipient}, contentType, content)
 
 	// Set the ReplyToID to link this message to the original
 	msg.ReplyToID = originalMsg.ID
+	msg.CorrelationID = originalMsg.CorrelationID
 
 	// Propagate any relevant metadata
 	for k, v := range originalMsg.Metadata {
-		if strings.HasPrefix(k, "conversation_") || strings.HasPrefix(k, "thread_") {
+		if strings.HasPrefix(k, "conversation_") || strings.HasPrefix(k, "thread_") || k == MetadataCorrelationID {
 			msg.Metadata[k] = v
 		}
 	}
@@ -76,14 +144,73 @@ func NewTextReplyMessage(senderID string, originalMsg Message, text string) Mess
 	return NewReplyMessage(senderID, originalMsg, ContentTypeText, []byte(text))
 }
 
-// TextContent extracts the text content of a message
+// TextContent extracts the text content of a message, the text part of a multipart message
 func (m Message) TextContent() (string, error) {
+	if m.ContentType == ContentTypeMultipart {
+		content, err := m.MultipartContent()
+		return content.Text, err
+	}
 	if m.ContentType != ContentTypeText {
 		return "", fmt.Errorf("message content is not text: %s", m.ContentType)
 	}
 	return string(m.Content), nil
 }
 
+// MultipartContent extracts the text and attachments of a multipart message
+func (m Message) MultipartContent() (MultipartContent, error) {
+	if m.ContentType != ContentTypeMultipart {
+		return MultipartContent{}, fmt.Errorf("message content is not multipart: %s", m.ContentType)
+	}
+	var content MultipartContent
+	if err := json.Unmarshal(m.Content, &content); err != nil {
+		return MultipartContent{}, fmt.Errorf("invalid multipart content: %w", err)
+	}
+	return content, nil
+}
+
+// WithCorrelation gives the message a new correlation ID, unless it has one; humans'
+// messages get one as they are sent, see MetadataCorrelationID
+func (m Message) WithCorrelation() Message {
+	if m.Correlation() != "" {
+		return m
+	}
+	metadata := make(map[string]string, len(m.Metadata)+1)
+	for key, value := range m.Metadata {
+		metadata[key] = value
+	}
+	metadata[MetadataCorrelationID] = uuid.New().String()
+	m.Metadata = metadata
+	return m
+}
+
+// Correlation returns the correlation ID of the message, empty if it has none
+func (m Message) Correlation() string {
+	return m.Metadata[MetadataCorrelationID]
+}
+
+// WithPriority sets the delivery priority of the message
+func (m Message) WithPriority(priority Priority) Message {
+	m.Priority = priority
+	return m
+}
+
+// Ephemeral marks the message as not worth keeping in the history of the bus, as it is
+// only of use when delivered, e.g. a heartbeat
+func (m Message) Ephemeral() Message {
+	metadata := make(map[string]string, len(m.Metadata)+1)
+	for key, value := range m.Metadata {
+		metadata[key] = value
+	}
+	metadata[MetadataEphemeral] = "true"
+	m.Metadata = metadata
+	return m
+}
+
+// IsEphemeral checks if the message is kept out of the history of the bus
+func (m Message) IsEphemeral() bool {
+	return m.Metadata[MetadataEphemeral] == "true"
+}
+
 // WithReplyTo sets the message as a reply to another message
 func (m Message) WithReplyTo(replyToID string) Message {
 	m.ReplyToID = replyToID
@@ -188,3 +188,34 @@ func TestMemoryMessageBus(t *testing.T) {
 		assert.Equal(t, entity1ID, members[0])
 	})
 }
+
+func TestMultipartMessage(t *testing.T) {
+	attachment := Attachment{Name: "mockup.png", MediaType: "image/png", Data: []byte{0x89, 'P', 'N', 'G'}}
+	msg, err := NewMultipartMessage("human", []string{"agent"}, "Is this clear?", []Attachment{attachment})
+	assert.NoError(t, err)
+	assert.Equal(t, ContentTypeMultipart, msg.ContentType)
+
+	text, err := msg.TextContent()
+	assert.NoError(t, err)
+	assert.Equal(t, "Is this clear?", text)
+
+	content, err := msg.MultipartContent()
+	assert.NoError(t, err)
+	assert.Equal(t, []Attachment{attachment}, content.Attachments)
+
+	_, err = NewTextMessage("human", []string{"agent"}, "Hi").MultipartContent()
+	assert.Error(t, err)
+}
+
+func TestMessageCorrelation(t *testing.T) {
+	msg := NewTextMessage("human", []string{"agent"}, "Hi")
+	correlated := msg.WithCorrelation()
+	assert.Empty(t, msg.Correlation(), "the original message is left as is")
+	assert.NotEmpty(t, correlated.Correlation())
+	assert.Equal(t, correlated.Correlation(), correlated.WithCorrelation().Correlation(), "an existing correlation ID is kept")
+
+	reply := NewTextReplyMessage("agent", correlated, "Hello")
+	assert.Equal(t, correlated.Correlation(), reply.Correlation())
+	assert.Equal(t, correlated.Correlation(), publishMetadata(reply, "")[MetadataCorrelationID])
+	assert.Equal(t, correlated.ID, publishMetadata(reply, "")["reply_to_id"])
+}
@@ -0,0 +1,24 @@
+package messaging
+
+import "goproduct/internal/metrics"
+
+// Metrics of the message buses
+var (
+	messagesPublished = metrics.Default().Counter("bus_messages_published_total",
+		"Messages published for delivery, by bus", "bus")
+	messagesDelivered = metrics.Default().Counter("bus_messages_delivered_total",
+		"Messages handed to a subscriber's handler, by how they arrived", "via")
+	handlerErrors = metrics.Default().Counter("bus_handler_errors_total",
+		"Message handlers that returned an error or panicked, by how the message arrived", "via")
+)
+
+// recordDelivery counts a delivery to a subscriber and whether its handler failed
+func recordDelivery(via string, err error) {
+	if via == "" {
+		via = "direct"
+	}
+	messagesDelivered.Inc(via)
+	if err != nil {
+		handlerErrors.Inc(via)
+	}
+}
@@ -0,0 +1,25 @@
+package messaging
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryBusRecordsMetrics(t *testing.T) {
+	published := messagesPublished.Value("memory")
+	delivered := messagesDelivered.Value("direct")
+	failed := handlerErrors.Value("direct")
+
+	bus := NewMemoryMessageBus()
+	require.NoError(t, bus.Subscribe("bob", func(msg Message) error { return nil }))
+	require.NoError(t, bus.Subscribe("carol", func(msg Message) error { return errors.New("boom") }))
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob", "carol"}, "Hi")))
+
+	require.Eventually(t, func() bool { return messagesDelivered.Value("direct") >= delivered+2 }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, published+1, messagesPublished.Value("memory"))
+	assert.Equal(t, failed+1, handlerErrors.Value("direct"))
+}
@@ -0,0 +1,72 @@
+package messaging
+
+import "sync"
+
+// Delivery describes the delivery of a message to one recipient
+type Delivery struct {
+	RecipientID string
+	Via         string // "direct", "group", "topic", "pattern" or "broadcast"
+	From        string // The group ID or topic the message was published to, or the entity a pattern matched
+}
+
+// DeliveryHandler handles the delivery of a message to one recipient
+type DeliveryHandler func(delivery Delivery, msg Message) error
+
+// Middleware wraps the delivery of every message to every recipient, whether sent
+// directly, to a group, to a topic or as a broadcast. Code before calling next runs
+// before the recipient's handler and may change the message or return an error instead
+// of delivering it; code after runs once the handler returned. Errors are treated like
+// handler errors, so acknowledged messages are retried and others become dead letters.
+type Middleware func(next DeliveryHandler) DeliveryHandler
+
+// BeforeDelivery creates a middleware running the interceptor before each delivery. The
+// interceptor returns the message to deliver, or an error to reject it.
+func BeforeDelivery(interceptor func(delivery Delivery, msg Message) (Message, error)) Middleware {
+	return func(next DeliveryHandler) DeliveryHandler {
+		return func(delivery Delivery, msg Message) error {
+			msg, err := interceptor(delivery, msg)
+			if err != nil {
+				return err
+			}
+			return next(delivery, msg)
+		}
+	}
+}
+
+// AfterDelivery creates a middleware running the interceptor after each delivery with
+// the handler's error
+func AfterDelivery(interceptor func(delivery Delivery, msg Message, err error)) Middleware {
+	return func(next DeliveryHandler) DeliveryHandler {
+		return func(delivery Delivery, msg Message) error {
+			err := next(delivery, msg)
+			interceptor(delivery, msg, err)
+			return err
+		}
+	}
+}
+
+// middlewareChain holds the middleware of a bus, the first added running outermost
+type middlewareChain struct {
+	mu          sync.RWMutex
+	middlewares []Middleware
+}
+
+// use appends middleware to the chain
+func (c *middlewareChain) use(middlewares ...Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
+// deliver passes the message through the chain to the handler
+func (c *middlewareChain) deliver(handler MessageHandler, delivery Delivery, msg Message) error {
+	c.mu.RLock()
+	middlewares := c.middlewares
+	c.mu.RUnlock()
+
+	next := func(_ Delivery, msg Message) error { return handler(msg) }
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		next = middlewares[i](next)
+	}
+	return next(delivery, msg)
+}
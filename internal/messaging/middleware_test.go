@@ -0,0 +1,138 @@
+package messaging
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+	named := func(name string) Middleware {
+		return func(next DeliveryHandler) DeliveryHandler {
+			return func(delivery Delivery, msg Message) error {
+				record(name + " before")
+				err := next(delivery, msg)
+				record(name + " after")
+				return err
+			}
+		}
+	}
+	bus.Use(named("outer"), named("inner"))
+	done := make(chan struct{})
+	bus.Subscribe("bob", func(msg Message) error {
+		record("handler")
+		close(done)
+		return nil
+	})
+
+	require.NoError(t, publishText(bus, []string{"bob"}, "hello"))
+	<-done
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(calls) == 5
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"outer before", "inner before", "handler", "inner after", "outer after"}, calls)
+}
+
+func TestMiddlewareAppliesToEveryPath(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	deliveries := make(chan Delivery, 10)
+	bus.Use(AfterDelivery(func(delivery Delivery, msg Message, err error) { deliveries <- delivery }))
+	bus.Subscribe("alice", func(msg Message) error { return nil })
+	bus.Subscribe("bob", func(msg Message) error { return nil })
+	bus.CreateGroup("team", "Team", []string{"bob"})
+	bus.SubscribeTopic("bob", "product.*")
+
+	receive := func() Delivery {
+		select {
+		case delivery := <-deliveries:
+			return delivery
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for delivery")
+			return Delivery{}
+		}
+	}
+	require.NoError(t, publishText(bus, []string{"bob"}, "direct"))
+	assert.Equal(t, Delivery{RecipientID: "bob", Via: "direct"}, receive())
+	require.NoError(t, publishText(bus, []string{"team"}, "group"))
+	assert.Equal(t, Delivery{RecipientID: "bob", Via: "group", From: "team"}, receive())
+	require.NoError(t, bus.Publish(NewTopicMessage("alice", "product.backlog", ContentTypeText, []byte("topic"))))
+	assert.Equal(t, Delivery{RecipientID: "bob", Via: "topic", From: "product.backlog"}, receive())
+	require.NoError(t, publishText(bus, []string{BroadcastAddress}, "broadcast"))
+	assert.Equal(t, Delivery{RecipientID: "bob", Via: "broadcast"}, receive())
+}
+
+func TestBeforeDeliveryRedacts(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.Use(BeforeDelivery(func(delivery Delivery, msg Message) (Message, error) {
+		msg.Content = []byte(strings.ReplaceAll(string(msg.Content), "hunter2", "[redacted]"))
+		return msg, nil
+	}))
+	received := make(chan Message, 1)
+	bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil })
+
+	require.NoError(t, publishText(bus, []string{"bob"}, "the password is hunter2"))
+	assert.Equal(t, "the password is [redacted]", receiveText(t, received))
+}
+
+func TestBeforeDeliveryRejects(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.Use(BeforeDelivery(func(delivery Delivery, msg Message) (Message, error) {
+		if len(msg.Content) == 0 {
+			return msg, errors.New("empty message")
+		}
+		return msg, nil
+	}))
+	received := make(chan Message, 1)
+	bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil })
+
+	require.NoError(t, publishText(bus, []string{"bob"}, ""))
+	expectNothing(t, received)
+	letters := waitForDeadLetters(t, bus, 1)
+	assert.Equal(t, "empty message", letters[0].LastError)
+}
+
+func TestAfterDeliverySeesHandlerError(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	errs := make(chan error, 1)
+	bus.Use(AfterDelivery(func(delivery Delivery, msg Message, err error) { errs <- err }))
+	bus.Subscribe("bob", func(msg Message) error { return errors.New("busy") })
+
+	require.NoError(t, publishText(bus, []string{"bob"}, "hello"))
+	select {
+	case err := <-errs:
+		assert.EqualError(t, err, "busy")
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for delivery")
+	}
+}
+
+func TestNatsMessageBusMiddleware(t *testing.T) {
+	server := newFakeNats(t, "")
+	bus, err := NewNatsMessageBus(server.url())
+	require.NoError(t, err)
+	defer bus.Close()
+
+	bus.Use(BeforeDelivery(func(delivery Delivery, msg Message) (Message, error) {
+		msg.Content = []byte(strings.ToUpper(string(msg.Content)))
+		return msg, nil
+	}))
+	received := make(chan Message, 1)
+	require.NoError(t, bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil }))
+	require.NoError(t, publishText(bus, []string{"bob"}, "hello"))
+	assert.Equal(t, "HELLO", receiveText(t, received))
+}
@@ -0,0 +1,36 @@
+//go:build integration
+
+package messaging
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"goproduct/internal/integration"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNatsMessageBusIntegration(t *testing.T) {
+	// A prefix of its own keeps concurrent runs apart
+	prefix := WithNatsSubjectPrefix(fmt.Sprintf("test%d", time.Now().UnixNano()))
+	busA, err := NewNatsMessageBus(integration.NatsURL(), prefix)
+	require.NoError(t, err, "NATS service unavailable, start it with `make integration-up`")
+	defer busA.Close()
+	busB, err := NewNatsMessageBus(integration.NatsURL(), prefix)
+	require.NoError(t, err)
+	defer busB.Close()
+
+	received := make(chan Message, 10)
+	require.NoError(t, busB.Subscribe("bob", func(msg Message) error { received <- msg; return nil }))
+	require.NoError(t, busB.CreateGroup("team", "Team", []string{"bob"}))
+
+	require.NoError(t, busA.Publish(NewTextMessage("alice", []string{"bob"}, "direct")))
+	assert.Equal(t, "direct", receiveText(t, received))
+	require.NoError(t, busA.Publish(NewTextMessage("alice", []string{"team"}, "group")))
+	assert.Equal(t, "group", receiveText(t, received))
+	require.NoError(t, busA.Publish(NewTextMessage("alice", []string{BroadcastAddress}, "broadcast")))
+	assert.Equal(t, "broadcast", receiveText(t, received))
+}
@@ -0,0 +1,260 @@
+package messaging
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNats is a NATS server supporting the operations the bus uses, with exact subject
+// matching
+type fakeNats struct {
+	listener net.Listener
+	token    string // Required auth token, if set
+
+	mu    sync.Mutex
+	conns map[net.Conn]map[string]string // Connection to subscription ID to subject
+}
+
+func newFakeNats(t *testing.T, token string) *fakeNats {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakeNats{listener: listener, token: token, conns: make(map[net.Conn]map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close(); server.drop() })
+	return server
+}
+
+func (f *fakeNats) url() string {
+	return "nats://" + f.listener.Addr().String()
+}
+
+func (f *fakeNats) serve(conn net.Conn) {
+	f.mu.Lock()
+	f.conns[conn] = make(map[string]string)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, conn)
+		f.mu.Unlock()
+		conn.Close()
+	}()
+
+	fmt.Fprint(conn, "INFO {\"server_id\":\"fake\"}\r\n")
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT":
+			var options map[string]interface{}
+			json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "CONNECT ")), &options)
+			if f.token != "" && options["auth_token"] != f.token {
+				fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case "PING":
+			f.write(conn, "PONG\r\n")
+		case "SUB":
+			f.mu.Lock()
+			f.conns[conn][fields[2]] = fields[1]
+			f.mu.Unlock()
+		case "UNSUB":
+			f.mu.Lock()
+			delete(f.conns[conn], fields[1])
+			f.mu.Unlock()
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			f.mu.Lock()
+			for other, subs := range f.conns {
+				for sid, subject := range subs {
+					if subject == fields[1] {
+						fmt.Fprintf(other, "MSG %s %s %d\r\n%s", subject, sid, size, payload)
+					}
+				}
+			}
+			f.mu.Unlock()
+		}
+	}
+}
+
+func (f *fakeNats) write(conn net.Conn, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fmt.Fprint(conn, data)
+}
+
+// drop closes every client connection
+func (f *fakeNats) drop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for conn := range f.conns {
+		conn.Close()
+	}
+}
+
+// receiveText waits for a message on the channel and returns its text
+func receiveText(t *testing.T, received <-chan Message) string {
+	t.Helper()
+	select {
+	case msg := <-received:
+		return string(msg.Content)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for message")
+		return ""
+	}
+}
+
+// expectNothing checks that no message arrives on the channel
+func expectNothing(t *testing.T, received <-chan Message) {
+	t.Helper()
+	select {
+	case msg := <-received:
+		t.Errorf("Unexpected message: %s", msg.Content)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNatsMessageBusBetweenProcesses(t *testing.T) {
+	server := newFakeNats(t, "")
+	// Two buses stand for two processes sharing the server
+	busA, err := NewNatsMessageBus(server.url())
+	require.NoError(t, err)
+	defer busA.Close()
+	busB, err := NewNatsMessageBus(server.url())
+	require.NoError(t, err)
+	defer busB.Close()
+
+	alice := make(chan Message, 10)
+	bob := make(chan Message, 10)
+	carol := make(chan Message, 10)
+	require.NoError(t, busA.Subscribe("alice", func(msg Message) error { alice <- msg; return nil }))
+	require.NoError(t, busB.Subscribe("bob", func(msg Message) error { bob <- msg; return nil }))
+	require.NoError(t, busB.Subscribe("carol", func(msg Message) error { carol <- msg; return nil },
+		SubscriptionFilter{SenderIDs: []string{"bob"}}))
+
+	// Direct messages cross processes
+	msg := NewTextMessage("alice", []string{"bob"}, "hello bob")
+	msg.Metadata["conversation_id"] = "c1"
+	require.NoError(t, busA.Publish(msg))
+	select {
+	case got := <-bob:
+		assert.Equal(t, "hello bob", string(got.Content))
+		assert.Equal(t, msg.ID, got.ID)
+		assert.Equal(t, "c1", got.Metadata["conversation_id"])
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for message")
+	}
+	expectNothing(t, carol)
+
+	// Groups are joined per process; a local member receives messages from anywhere
+	require.NoError(t, busB.CreateGroup("team", "Team", []string{"bob"}))
+	require.NoError(t, busA.Publish(NewTextMessage("alice", []string{"team"}, "team update")))
+	assert.Equal(t, "team update", receiveText(t, bob))
+	members, _ := busB.GetGroupMembers("team")
+	assert.Equal(t, []string{"bob"}, members)
+
+	// Broadcasts reach every process but not the sender
+	require.NoError(t, busB.Publish(NewTextMessage("bob", []string{BroadcastAddress}, "all hands")))
+	assert.Equal(t, "all hands", receiveText(t, alice))
+	assert.Equal(t, "all hands", receiveText(t, carol))
+	expectNothing(t, bob)
+
+	// Unsubscribed entities no longer receive messages
+	require.NoError(t, busB.Unsubscribe("bob"))
+	require.NoError(t, busB.RemoveFromGroup("team", "bob"))
+	require.NoError(t, busA.Publish(NewTextMessage("alice", []string{"bob", "team"}, "anyone?")))
+	expectNothing(t, bob)
+
+	assert.Error(t, busA.Subscribe("bad id", func(Message) error { return nil }))
+}
+
+func TestNatsMessageBusReconnects(t *testing.T) {
+	server := newFakeNats(t, "")
+	bus, err := NewNatsMessageBus(server.url(), WithNatsReconnectWait(10*time.Millisecond))
+	require.NoError(t, err)
+	defer bus.Close()
+
+	received := make(chan Message, 10)
+	require.NoError(t, bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil }))
+
+	server.drop()
+	// Subscriptions are restored once the connection is back
+	require.Eventually(t, func() bool {
+		return bus.Publish(NewTextMessage("alice", []string{"bob"}, "after reconnect")) == nil
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "after reconnect", receiveText(t, received))
+
+	require.NoError(t, bus.Close())
+	assert.ErrorIs(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "closed")), ErrBusClosed)
+}
+
+func TestNatsMessageBusAcknowledgedDelivery(t *testing.T) {
+	server := newFakeNats(t, "")
+	sender, err := NewNatsMessageBus(server.url())
+	require.NoError(t, err)
+	defer sender.Close()
+	receiver, err := NewNatsMessageBus(server.url())
+	require.NoError(t, err)
+	defer receiver.Close()
+	receiver.SetRetryPolicy(fastRetries)
+
+	failures := 1
+	require.NoError(t, receiver.Subscribe("bob", func(msg Message) error {
+		if failures > 0 {
+			failures--
+			return errors.New("busy")
+		}
+		return nil
+	}))
+
+	// The receiving process retries its subscriber and holds the status
+	msg := NewTextMessage("alice", []string{"bob"}, "hello").WithAck()
+	require.NoError(t, sender.Publish(msg))
+	status := waitForDelivery(t, receiver, msg.ID)
+	assert.Equal(t, DeliveryAcked, status.Recipients["bob"].State)
+	assert.Equal(t, 2, status.Recipients["bob"].Attempts)
+	_, err = sender.DeliveryStatus(msg.ID)
+	assert.ErrorIs(t, err, ErrUnknownMessage)
+}
+
+func TestNatsMessageBusAuthentication(t *testing.T) {
+	server := newFakeNats(t, "secret")
+	_, err := NewNatsMessageBus(server.url())
+	assert.ErrorContains(t, err, "Authorization Violation")
+
+	bus, err := NewNatsMessageBus("nats://secret@" + server.listener.Addr().String())
+	require.NoError(t, err)
+	bus.Close()
+}
+
+var _ MessageBus = (*NatsMessageBus)(nil)
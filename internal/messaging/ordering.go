@@ -0,0 +1,280 @@
+package messaging
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DeliveryMode controls how a bus runs the handlers of a recipient
+type DeliveryMode string
+
+// Delivery modes
+const (
+	// DeliveryConcurrent runs every delivery on its own goroutine, so a recipient may
+	// handle several messages at once and in any order. This is the default. With a
+	// queue depth set, deliveries wait in the recipient's queue for one of its workers.
+	DeliveryConcurrent DeliveryMode = "concurrent"
+
+	// DeliveryOrdered queues the deliveries of each recipient for a single worker, which
+	// handles them one at a time by priority and in publishing order within a priority.
+	// A delivery being retried holds up the recipient's queue.
+	DeliveryOrdered DeliveryMode = "ordered"
+)
+
+// OverflowPolicy decides what happens to a delivery when the recipient's queue is full
+type OverflowPolicy string
+
+// Overflow policies
+const (
+	OverflowBlock      OverflowPolicy = "block"       // Publish waits until the queue has space
+	OverflowDropOldest OverflowPolicy = "drop_oldest" // The oldest waiting delivery becomes a dead letter
+	OverflowError      OverflowPolicy = "error"       // Publish returns ErrQueueFull for the recipient
+)
+
+// DefaultQueueWorkers is the number of workers per recipient for bounded queues in
+// DeliveryConcurrent mode
+const DefaultQueueWorkers = 4
+
+// ErrQueueFull is returned when a recipient's queue is full under OverflowError, and is
+// the error of deliveries dropped under OverflowDropOldest
+var ErrQueueFull = errors.New("recipient queue is full")
+
+// QueueStats describes the queue of one recipient
+type QueueStats struct {
+	Depth    int    // Deliveries waiting for a worker
+	Active   int    // Workers handling deliveries
+	Dropped  uint64 // Deliveries dropped by OverflowDropOldest
+	Rejected uint64 // Deliveries refused by OverflowError
+}
+
+// recipientQueues runs deliveries according to the delivery mode, keeping a queue and
+// workers per recipient in ordered mode or when the queues are bounded. Workers exit
+// when their queue is empty.
+type recipientQueues struct {
+	mu       sync.Mutex
+	space    *sync.Cond // Signaled when a waiting delivery is taken from a queue
+	mode     DeliveryMode
+	depth    int // Bound of each queue, 0 for unbounded
+	workers  int // Workers per recipient in concurrent mode
+	overflow OverflowPolicy
+	queues   map[string]*recipientQueue
+	seq      uint64        // Publishing order of queued deliveries
+	inFlight int           // Deliveries started and not yet run or dropped
+	idle     chan struct{} // Closed when no delivery is in flight, nil unless drain waits
+}
+
+// recipientQueue holds the waiting deliveries of one recipient
+type recipientQueue struct {
+	pending  deliveryQueue
+	active   int
+	dropped  uint64
+	rejected uint64
+}
+
+// newRecipientQueues creates unbounded queues in concurrent mode
+func newRecipientQueues() *recipientQueues {
+	q := &recipientQueues{
+		mode:     DeliveryConcurrent,
+		workers:  DefaultQueueWorkers,
+		overflow: OverflowBlock,
+		queues:   make(map[string]*recipientQueue),
+	}
+	q.space = sync.NewCond(&q.mu)
+	return q
+}
+
+// setMode switches the mode for deliveries started from now on; queued ones still run
+func (q *recipientQueues) setMode(mode DeliveryMode) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.mode = mode
+}
+
+// setLimits bounds the queues to depth waiting deliveries (0 for unbounded), handled by
+// the given number of workers per recipient in concurrent mode
+func (q *recipientQueues) setLimits(depth, workers int, overflow OverflowPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.depth, q.workers, q.overflow = depth, workers, overflow
+	q.space.Broadcast()
+}
+
+// run starts a delivery to the recipient. If the recipient's queue is full, the
+// overflow policy applies: run waits for space, drops the oldest waiting delivery
+// passing ErrQueueFull to its drop function, or returns ErrQueueFull.
+func (q *recipientQueues) run(recipientID string, priority Priority, delivery func(), drop func(error)) error {
+	q.mu.Lock()
+	q.inFlight++
+	var once sync.Once
+	done := func() { once.Do(q.finished) }
+	run, onDrop := delivery, drop
+	delivery = func() {
+		defer done()
+		run()
+	}
+	drop = func(err error) {
+		defer done()
+		if onDrop != nil {
+			onDrop(err)
+		}
+	}
+	if q.mode != DeliveryOrdered && q.depth == 0 {
+		q.mu.Unlock()
+		go delivery()
+		return nil
+	}
+
+	queue, ok := q.queues[recipientID]
+	if !ok {
+		queue = &recipientQueue{}
+		q.queues[recipientID] = queue
+	}
+	var dropped []queuedDelivery
+	for q.depth > 0 && queue.pending.Len() >= q.depth {
+		switch q.overflow {
+		case OverflowError:
+			queue.rejected++
+			q.mu.Unlock()
+			done()
+			return fmt.Errorf("%w: %s", ErrQueueFull, recipientID)
+		case OverflowDropOldest:
+			dropped = append(dropped, queue.pending.removeOldest())
+			queue.dropped++
+		default:
+			q.space.Wait()
+		}
+	}
+	q.seq++
+	heap.Push(&queue.pending, queuedDelivery{priority: priority, seq: q.seq, run: delivery, drop: drop})
+	limit := q.workers
+	if q.mode == DeliveryOrdered || limit < 1 {
+		limit = 1
+	}
+	startWorker := queue.active < limit
+	if startWorker {
+		queue.active++
+	}
+	q.mu.Unlock()
+
+	for _, d := range dropped {
+		if d.drop != nil {
+			d.drop(fmt.Errorf("%w: %s", ErrQueueFull, recipientID))
+		}
+	}
+	if startWorker {
+		go q.work(queue)
+	}
+	return nil
+}
+
+// work runs the deliveries of a recipient until its queue is empty
+func (q *recipientQueues) work(queue *recipientQueue) {
+	for {
+		q.mu.Lock()
+		if queue.pending.Len() == 0 {
+			queue.active--
+			q.mu.Unlock()
+			return
+		}
+		next := heap.Pop(&queue.pending).(queuedDelivery)
+		q.space.Broadcast()
+		q.mu.Unlock()
+		next.run()
+	}
+}
+
+// finished counts a delivery out of those in flight
+func (q *recipientQueues) finished() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inFlight--
+	if q.inFlight == 0 && q.idle != nil {
+		close(q.idle)
+		q.idle = nil
+	}
+}
+
+// drain waits until no delivery is in flight, including those started by the deliveries
+// it waits for, or the context is done
+func (q *recipientQueues) drain(ctx context.Context) error {
+	for {
+		q.mu.Lock()
+		if q.inFlight == 0 {
+			q.mu.Unlock()
+			return nil
+		}
+		if q.idle == nil {
+			q.idle = make(chan struct{})
+		}
+		idle, count := q.idle, q.inFlight
+		q.mu.Unlock()
+
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			return fmt.Errorf("%d deliveries still in flight: %w", count, ctx.Err())
+		}
+	}
+}
+
+// stats returns the state of the queue of every recipient that has used one
+func (q *recipientQueues) stats() map[string]QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := make(map[string]QueueStats, len(q.queues))
+	for recipientID, queue := range q.queues {
+		stats[recipientID] = QueueStats{
+			Depth:    queue.pending.Len(),
+			Active:   queue.active,
+			Dropped:  queue.dropped,
+			Rejected: queue.rejected,
+		}
+	}
+	return stats
+}
+
+// queuedDelivery is a delivery waiting in a recipient's queue
+type queuedDelivery struct {
+	priority Priority
+	seq      uint64
+	run      func()
+	drop     func(error) // Called instead of run if the delivery is dropped
+}
+
+// deliveryQueue orders queued deliveries by priority, then publishing order
+// (implements heap.Interface)
+type deliveryQueue []queuedDelivery
+
+func (d deliveryQueue) Len() int { return len(d) }
+
+func (d deliveryQueue) Less(i, j int) bool {
+	if d[i].priority != d[j].priority {
+		return d[i].priority > d[j].priority
+	}
+	return d[i].seq < d[j].seq
+}
+
+func (d deliveryQueue) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+
+func (d *deliveryQueue) Push(x interface{}) { *d = append(*d, x.(queuedDelivery)) }
+
+func (d *deliveryQueue) Pop() interface{} {
+	old := *d
+	last := old[len(old)-1]
+	*d = old[:len(old)-1]
+	return last
+}
+
+// removeOldest removes and returns the delivery queued first, whatever its priority
+func (d *deliveryQueue) removeOldest() queuedDelivery {
+	oldest := 0
+	for i := range *d {
+		if (*d)[i].seq < (*d)[oldest].seq {
+			oldest = i
+		}
+	}
+	return heap.Remove(d, oldest).(queuedDelivery)
+}
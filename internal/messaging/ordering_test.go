@@ -0,0 +1,207 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"goproduct/internal/knowledge"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler records the text of each message it handles; handling blocks while
+// the gate is closed
+type recordingHandler struct {
+	mu      sync.Mutex
+	texts   []string
+	gate    chan struct{}
+	started chan struct{}
+}
+
+func newRecordingHandler() *recordingHandler {
+	return &recordingHandler{gate: make(chan struct{}), started: make(chan struct{}, 100)}
+}
+
+func (r *recordingHandler) handle(msg Message) error {
+	r.started <- struct{}{}
+	<-r.gate
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.texts = append(r.texts, string(msg.Content))
+	return nil
+}
+
+func (r *recordingHandler) handled() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.texts...)
+}
+
+func TestOrderedDeliveryPreservesOrder(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetDeliveryMode(DeliveryOrdered)
+	handler := newRecordingHandler()
+	close(handler.gate)
+	require.NoError(t, bus.Subscribe("bob", handler.handle))
+
+	var want []string
+	for i := 0; i < 50; i++ {
+		text := fmt.Sprintf("message %d", i)
+		want = append(want, text)
+		require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, text)))
+	}
+
+	require.Eventually(t, func() bool { return len(handler.handled()) == len(want) }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, want, handler.handled())
+}
+
+func TestOrderedDeliveryUrgentJumpsQueue(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetDeliveryMode(DeliveryOrdered)
+	handler := newRecordingHandler()
+	require.NoError(t, bus.Subscribe("bob", handler.handle))
+
+	// The first message occupies the worker while the others queue up
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "first")))
+	<-handler.started
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "low").WithPriority(PriorityLow)))
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "second")))
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "third")))
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "stop").WithPriority(PriorityUrgent)))
+	close(handler.gate)
+
+	require.Eventually(t, func() bool { return len(handler.handled()) == 5 }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"first", "stop", "second", "third", "low"}, handler.handled())
+}
+
+func TestOrderedDeliveryRecipientsRunIndependently(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetDeliveryMode(DeliveryOrdered)
+	blocked := newRecordingHandler()
+	free := newRecordingHandler()
+	close(free.gate)
+	require.NoError(t, bus.Subscribe("bob", blocked.handle))
+	require.NoError(t, bus.Subscribe("carol", free.handle))
+
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob", "carol"}, "hello")))
+	require.Eventually(t, func() bool { return len(free.handled()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Empty(t, blocked.handled())
+	close(blocked.gate)
+}
+
+func TestConcurrentDeliveryIsDefault(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	handler := newRecordingHandler()
+	require.NoError(t, bus.Subscribe("bob", handler.handle))
+
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "one")))
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "two")))
+	// Both handlers run at once although neither has finished
+	for i := 0; i < 2; i++ {
+		select {
+		case <-handler.started:
+		case <-time.After(time.Second):
+			t.Fatal("handlers should run concurrently")
+		}
+	}
+	close(handler.gate)
+}
+
+func TestDrainWaitsForDeliveriesInFlight(t *testing.T) {
+	for _, mode := range []DeliveryMode{DeliveryConcurrent, DeliveryOrdered} {
+		bus := NewMemoryMessageBus()
+		bus.SetDeliveryMode(mode)
+		handler := newRecordingHandler()
+		require.NoError(t, bus.Subscribe("bob", handler.handle))
+		require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "first")))
+		require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "second")))
+		<-handler.started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := bus.Drain(ctx)
+		cancel()
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "mode %v", mode)
+
+		close(handler.gate)
+		require.NoError(t, bus.Drain(context.Background()))
+		assert.Len(t, handler.handled(), 2, "mode %v", mode)
+	}
+}
+
+func TestDrainWaitsForRetries(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetRetryPolicy(fastRetries)
+	var calls atomic.Int32
+	require.NoError(t, bus.Subscribe("bob", func(msg Message) error {
+		if calls.Add(1) < 3 {
+			return errors.New("busy")
+		}
+		return nil
+	}))
+
+	msg := NewTextMessage("alice", []string{"bob"}, "hello").WithAck()
+	require.NoError(t, bus.Publish(msg))
+	require.NoError(t, bus.Drain(context.Background()))
+	assert.EqualValues(t, 3, calls.Load(), "Drain should return after the last retry")
+	status, err := bus.DeliveryStatus(msg.ID)
+	require.NoError(t, err)
+	assert.Equal(t, DeliveryAcked, status.Recipients["bob"].State)
+}
+
+func TestDrainWithoutDeliveries(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, bus.Drain(ctx))
+}
+
+func TestOrderedDeliveryRetriesHoldQueue(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetDeliveryMode(DeliveryOrdered)
+	bus.SetRetryPolicy(fastRetries)
+
+	var mu sync.Mutex
+	var handled []string
+	failures := 2
+	require.NoError(t, bus.Subscribe("bob", func(msg Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if string(msg.Content) == "first" && failures > 0 {
+			failures--
+			return assert.AnError
+		}
+		handled = append(handled, string(msg.Content))
+		return nil
+	}))
+
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "first").WithAck()))
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "second")))
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 2
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"first", "second"}, handled)
+}
+
+func TestPersistentMessageBusJournalsPriority(t *testing.T) {
+	store, _ := knowledge.NewFileStore(filepath.Join(t.TempDir(), "journal.json"))
+	require.NoError(t, store.Open())
+	defer store.Close()
+	bus := NewPersistentMessageBus(NewMemoryMessageBus(), store)
+
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "stop").WithPriority(PriorityUrgent)))
+	journal, err := bus.Journal(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, journal, 1)
+	assert.Equal(t, PriorityUrgent, journal[0].Priority)
+	assert.Empty(t, journal[0].Metadata)
+}
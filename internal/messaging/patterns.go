@@ -0,0 +1,161 @@
+package messaging
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RegexpPatternPrefix marks a subscription pattern as a regular expression, as in
+// "re:^agent:[0-9]+$"; other patterns are globs such as "agent:*"
+const RegexpPatternPrefix = "re:"
+
+// PatternMode decides which messages a pattern subscription receives when the entity
+// the message is for also has an exact subscription. The precedence rules are:
+//
+//  1. An exact subscription always receives the messages for its entity.
+//  2. PatternObserve subscriptions receive a copy of every message for an entity they
+//     match, next to the exact subscription.
+//  3. PatternFallback subscriptions receive the messages for entities they match that
+//     have no exact subscription, standing in for them. If several fallbacks match, the
+//     most specific one receives the message: globs before regular expressions, then
+//     the pattern with more literal characters, then the one subscribed first.
+//
+// A pattern subscription receives a message at most once per publish, however many of
+// its recipients match.
+type PatternMode int
+
+// Pattern modes
+const (
+	PatternObserve PatternMode = iota
+	PatternFallback
+)
+
+// patternSubscription is a handler registered for the entities matching a pattern
+type patternSubscription struct {
+	pattern  string
+	mode     PatternMode
+	sub      subscription
+	match    func(entityID string) bool
+	regexp   bool
+	literals int    // Characters other than wildcards, the specificity among fallbacks
+	seq      uint64 // Subscription order, the final tie breaker among fallbacks
+}
+
+// newPatternSubscription compiles a pattern subscription
+func newPatternSubscription(pattern string, mode PatternMode, handler MessageHandler, filters []SubscriptionFilter) (*patternSubscription, error) {
+	if handler == nil {
+		return nil, fmt.Errorf("message handler cannot be nil")
+	}
+	if mode != PatternObserve && mode != PatternFallback {
+		return nil, fmt.Errorf("invalid pattern mode %d", mode)
+	}
+	ps := &patternSubscription{pattern: pattern, mode: mode, sub: subscription{handler: handler, filters: filters}}
+	if expr, ok := strings.CutPrefix(pattern, RegexpPatternPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		ps.match, ps.regexp = re.MatchString, true
+		return ps, nil
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern cannot be empty")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	ps.match = func(entityID string) bool {
+		matched, _ := path.Match(pattern, entityID)
+		return matched
+	}
+	ps.literals = len(pattern) - strings.Count(pattern, "*") - strings.Count(pattern, "?")
+	return ps, nil
+}
+
+// moreSpecific checks if the fallback takes precedence over another
+func (p *patternSubscription) moreSpecific(other *patternSubscription) bool {
+	if p.regexp != other.regexp {
+		return !p.regexp
+	}
+	if p.literals != other.literals {
+		return p.literals > other.literals
+	}
+	return p.seq < other.seq
+}
+
+// patternSubscriptions holds the pattern subscriptions of a bus by pattern
+// (not safe for concurrent use; guarded by the lock of the bus)
+type patternSubscriptions struct {
+	byPattern map[string]*patternSubscription
+	seq       uint64
+}
+
+// add registers a pattern subscription, replacing one with the same pattern
+func (p *patternSubscriptions) add(ps *patternSubscription) {
+	if p.byPattern == nil {
+		p.byPattern = make(map[string]*patternSubscription)
+	}
+	p.seq++
+	ps.seq = p.seq
+	p.byPattern[ps.pattern] = ps
+}
+
+// remove drops a pattern subscription and reports whether it existed
+func (p *patternSubscriptions) remove(pattern string) bool {
+	_, exists := p.byPattern[pattern]
+	delete(p.byPattern, pattern)
+	return exists
+}
+
+// match records the pattern subscriptions reached by a message for the entity and
+// reports whether a fallback stands in for it, so the entity itself is skipped
+func (p *patternSubscriptions) match(entityID string, subscribed bool, matches *patternMatches) bool {
+	var fallback *patternSubscription
+	for _, ps := range p.byPattern {
+		if !ps.match(entityID) {
+			continue
+		}
+		switch {
+		case ps.mode == PatternObserve:
+			matches.add(ps, entityID)
+		case !subscribed && (fallback == nil || ps.moreSpecific(fallback)):
+			fallback = ps
+		}
+	}
+	if fallback == nil {
+		return false
+	}
+	matches.add(fallback, entityID)
+	return true
+}
+
+// patternMatches collects the pattern subscriptions a published message reaches, with
+// the first entity each matched
+type patternMatches struct {
+	entities map[*patternSubscription]string
+}
+
+// add records a pattern subscription unless it already matched
+func (m *patternMatches) add(ps *patternSubscription, entityID string) {
+	if m.entities == nil {
+		m.entities = make(map[*patternSubscription]string)
+	}
+	if _, exists := m.entities[ps]; !exists {
+		m.entities[ps] = entityID
+	}
+}
+
+// each calls fn for every matched pattern subscription in subscription order
+func (m *patternMatches) each(fn func(ps *patternSubscription, entityID string)) {
+	matched := make([]*patternSubscription, 0, len(m.entities))
+	for ps := range m.entities {
+		matched = append(matched, ps)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].seq < matched[j].seq })
+	for _, ps := range matched {
+		fn(ps, m.entities[ps])
+	}
+}
@@ -0,0 +1,121 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatternSubscriptionValidation(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	handler := func(msg Message) error { return nil }
+	assert.Error(t, bus.SubscribePattern("", PatternObserve, handler))
+	assert.Error(t, bus.SubscribePattern("agent:[", PatternObserve, handler))
+	assert.Error(t, bus.SubscribePattern("re:agent:(", PatternObserve, handler))
+	assert.Error(t, bus.SubscribePattern("agent:*", PatternMode(7), handler))
+	assert.Error(t, bus.SubscribePattern("agent:*", PatternObserve, nil))
+	assert.Error(t, bus.UnsubscribePattern("agent:*"))
+
+	require.NoError(t, bus.SubscribePattern("agent:*", PatternObserve, handler))
+	require.NoError(t, bus.UnsubscribePattern("agent:*"))
+}
+
+func TestPatternObserversSeeMatchingRecipients(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	agent := make(chan Message, 10)
+	globbed := make(chan Message, 10)
+	regexped := make(chan Message, 10)
+	bus.Subscribe("agent:1", func(msg Message) error { agent <- msg; return nil })
+	require.NoError(t, bus.SubscribePattern("agent:*", PatternObserve, func(msg Message) error { globbed <- msg; return nil }))
+	require.NoError(t, bus.SubscribePattern("re:^agent:[0-9]+$", PatternObserve, func(msg Message) error { regexped <- msg; return nil }))
+
+	// The exact subscription and both observers receive the message
+	require.NoError(t, publishText(bus, []string{"agent:1"}, "hello"))
+	assert.Equal(t, "hello", receiveText(t, agent))
+	assert.Equal(t, "hello", receiveText(t, globbed))
+	assert.Equal(t, "hello", receiveText(t, regexped))
+
+	// Observers receive a message once however many recipients match, subscribed or not
+	require.NoError(t, publishText(bus, []string{"agent:1", "agent:2", "agent:x"}, "all"))
+	assert.Equal(t, "all", receiveText(t, globbed))
+	assert.Equal(t, "all", receiveText(t, regexped))
+	expectNothing(t, globbed)
+	expectNothing(t, regexped)
+
+	require.NoError(t, publishText(bus, []string{"human:1"}, "elsewhere"))
+	expectNothing(t, globbed)
+
+	// Group members and topic subscribers are matched too
+	require.NoError(t, bus.CreateGroup("team", "Team", []string{"agent:1"}))
+	require.NoError(t, publishText(bus, []string{"team"}, "standup"))
+	assert.Equal(t, "standup", receiveText(t, globbed))
+	require.NoError(t, bus.SubscribeTopic("agent:1", "product.>"))
+	require.NoError(t, bus.Publish(NewTopicMessage("human:1", "product.backlog", ContentTypeText, []byte("groomed"))))
+	assert.Equal(t, "groomed", receiveText(t, globbed))
+}
+
+func TestPatternFallbackPrecedence(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	exact := make(chan Message, 10)
+	broad := make(chan Message, 10)
+	narrow := make(chan Message, 10)
+	regexped := make(chan Message, 10)
+	bus.Subscribe("agent:pm:1", func(msg Message) error { exact <- msg; return nil })
+	require.NoError(t, bus.SubscribePattern("re:^agent", PatternFallback, func(msg Message) error { regexped <- msg; return nil }))
+	require.NoError(t, bus.SubscribePattern("agent:*", PatternFallback, func(msg Message) error { broad <- msg; return nil }))
+	require.NoError(t, bus.SubscribePattern("agent:pm:*", PatternFallback, func(msg Message) error { narrow <- msg; return nil }))
+
+	// An exact subscription takes precedence over every fallback
+	require.NoError(t, publishText(bus, []string{"agent:pm:1"}, "exact"))
+	assert.Equal(t, "exact", receiveText(t, exact))
+
+	// The fallback with more literal characters wins over the broader glob
+	require.NoError(t, publishText(bus, []string{"agent:pm:2"}, "narrow"))
+	assert.Equal(t, "narrow", receiveText(t, narrow))
+
+	// Globs win over regular expressions
+	require.NoError(t, publishText(bus, []string{"agent:qa"}, "broad"))
+	assert.Equal(t, "broad", receiveText(t, broad))
+
+	// The regular expression covers what no glob matches
+	require.NoError(t, publishText(bus, []string{"agents:qa"}, "regexp"))
+	assert.Equal(t, "regexp", receiveText(t, regexped))
+
+	expectNothing(t, exact)
+	expectNothing(t, broad)
+	expectNothing(t, narrow)
+	expectNothing(t, regexped)
+}
+
+func TestPatternFallbackTakesAcknowledgedDeliveries(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetRetryPolicy(fastRetries)
+	fallback := make(chan Message, 10)
+	require.NoError(t, bus.SubscribePattern("agent:*", PatternFallback, func(msg Message) error { fallback <- msg; return nil }))
+
+	msg := NewMessage("human:1", []string{"agent:offline"}, ContentTypeText, []byte("ping")).WithAck()
+	require.NoError(t, bus.Publish(msg))
+	assert.Equal(t, "ping", receiveText(t, fallback))
+
+	status := waitForDelivery(t, bus, msg.ID)
+	assert.Equal(t, DeliveryAcked, status.Recipients["agent:*"].State)
+	_, tracked := status.Recipients["agent:offline"]
+	assert.False(t, tracked, "the fallback stands in for the recipient")
+}
+
+func TestPatternDeliveryReportsMatchedEntity(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	deliveries := make(chan Delivery, 1)
+	bus.Use(BeforeDelivery(func(d Delivery, msg Message) (Message, error) { deliveries <- d; return msg, nil }))
+	require.NoError(t, bus.SubscribePattern("agent:*", PatternObserve, func(msg Message) error { return nil }))
+
+	require.NoError(t, publishText(bus, []string{"agent:7"}, "hi"))
+	select {
+	case d := <-deliveries:
+		assert.Equal(t, Delivery{RecipientID: "agent:*", Via: "pattern", From: "agent:7"}, d)
+	case <-time.After(time.Second):
+		t.Fatal("no delivery")
+	}
+}
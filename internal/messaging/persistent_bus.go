@@ -0,0 +1,361 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"goproduct/internal/knowledge"
+)
+
+// Journal entry conventions
+const (
+	JournalIDPrefix   = "bus/"     // Prefix of the knowledge IDs of journaled messages
+	JournalSourceType = "bus"      // SourceType of journaled messages
+	referenceReplyTo  = "reply_to" // Reference type linking a reply to its message
+
+	GroupIDPrefix   = "bus-group/" // Prefix of the knowledge IDs of persisted group definitions
+	GroupSourceType = "bus_group"  // SourceType of persisted group definitions
+
+	// Journal metadata keys holding the reply routing of a message
+	journalReplyToKey       = "bus_reply_to"
+	journalCorrelationIDKey = "bus_correlation_id"
+	journalPriorityKey      = "bus_priority"
+	journalExpiresAtKey     = "bus_expires_at"
+	journalDeliverAtKey     = "bus_deliver_at"
+)
+
+// PersistentMessageBus journals every published message into a knowledge store before
+// delivering it through the wrapped bus, so traffic between agents and humans survives
+// a crash and can be replayed or audited. Messages are stored as knowledge.CategoryMessage
+// entries, which keeps conversation metadata searchable alongside other knowledge.
+type PersistentMessageBus struct {
+	MessageBus
+	store         knowledge.Store
+	persistGroups bool
+}
+
+// PersistentBusOption configures a PersistentMessageBus
+type PersistentBusOption func(*PersistentMessageBus)
+
+// WithGroupPersistence saves the definition of every group changed through the bus in
+// the store, so RestoreGroups can recreate the groups after a restart
+func WithGroupPersistence() PersistentBusOption {
+	return func(p *PersistentMessageBus) { p.persistGroups = true }
+}
+
+// NewPersistentMessageBus wraps the bus with a journal in the store
+func NewPersistentMessageBus(bus MessageBus, store knowledge.Store, opts ...PersistentBusOption) *PersistentMessageBus {
+	p := &PersistentMessageBus{MessageBus: bus, store: store}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Publish journals the message, then delivers it. A message that cannot be journaled is
+// not delivered, so the journal holds everything that was. Publishing a message with an
+// ID that is already journaled delivers it again without a second journal entry. Partial
+// replies, superseded by the reply, are delivered without being journaled.
+func (p *PersistentMessageBus) Publish(msg Message) error {
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	if msg.Metadata[MetadataPartial] == "true" {
+		return p.MessageBus.Publish(msg)
+	}
+	if err := p.store.AddRecord(journalEntry(msg)); err != nil {
+		if _, getErr := p.store.GetRecord(JournalIDPrefix + msg.ID); getErr != nil {
+			return fmt.Errorf("failed to journal message %s: %w", msg.ID, err)
+		}
+	}
+	return p.MessageBus.Publish(msg)
+}
+
+// Request journals and publishes a message and waits for its reply, see Request. The
+// reply is journaled when its sender publishes it through a persistent bus.
+func (p *PersistentMessageBus) Request(ctx context.Context, msg Message) (Message, error) {
+	return Request(ctx, p, msg)
+}
+
+// Journal returns the journaled messages published at or after since, oldest first
+func (p *PersistentMessageBus) Journal(since time.Time) ([]Message, error) {
+	filter, err := knowledge.Query().
+		Where("Category", "=", knowledge.CategoryMessage).
+		Where("SourceType", "=", JournalSourceType).
+		Where("CreatedAt", ">=", since).
+		OrderBy("CreatedAt").
+		Build()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := p.store.SearchRecords(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message journal: %w", err)
+	}
+	messages := make([]Message, len(entries))
+	for i, entry := range entries {
+		messages[i] = journalMessage(entry)
+	}
+	return messages, nil
+}
+
+// GetHistory returns the journaled messages sent by the entity or addressed to it
+// directly, by broadcast or through a group it is a member of now, with a timestamp at or
+// after since, oldest first. A positive limit keeps only the most recent ones. Unlike the
+// history of the wrapped bus, it survives restarts; messages to topics are not included.
+func (p *PersistentMessageBus) GetHistory(entityID string, since time.Time, limit int) ([]Message, error) {
+	addressed := []*knowledge.QueryBuilder{
+		knowledge.Query().Where("OwnerID", "=", entityID),
+		knowledge.Query().Where("SubjectIDs", "CONTAINS", entityID),
+		knowledge.Query().Where("SubjectIDs", "CONTAINS", BroadcastAddress),
+	}
+	for _, group := range p.MessageBus.ListGroups() {
+		if group.Members[entityID] {
+			addressed = append(addressed, knowledge.Query().Where("SubjectIDs", "CONTAINS", group.ID))
+		}
+	}
+	query := knowledge.Query().
+		Where("Category", "=", knowledge.CategoryMessage).
+		Where("SourceType", "=", JournalSourceType).
+		Where("CreatedAt", ">=", since).
+		Or(addressed...).
+		OrderBy("CreatedAt").
+		Desc()
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	filter, err := query.Build()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := p.store.SearchRecords(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message history: %w", err)
+	}
+	messages := make([]Message, len(entries))
+	for i, entry := range entries {
+		messages[len(entries)-1-i] = journalMessage(entry)
+	}
+	return messages, nil
+}
+
+// Replay passes the journaled messages published at or after since to the handler,
+// oldest first, and returns how many were replayed. It stops at the first handler error.
+// Replayed messages are not published again; to redeliver them, publish from the handler.
+func (p *PersistentMessageBus) Replay(since time.Time, handler MessageHandler) (int, error) {
+	messages, err := p.Journal(since)
+	if err != nil {
+		return 0, err
+	}
+	for i, msg := range messages {
+		if err := handler(msg); err != nil {
+			return i, fmt.Errorf("failed to replay message %s: %w", msg.ID, err)
+		}
+	}
+	return len(messages), nil
+}
+
+// CreateGroup creates a group and persists its definition
+func (p *PersistentMessageBus) CreateGroup(groupID, name string, members []string) error {
+	return p.saveGroup(groupID, p.MessageBus.CreateGroup(groupID, name, members))
+}
+
+// AddToGroup adds an entity to a group and persists its definition
+func (p *PersistentMessageBus) AddToGroup(groupID, entityID string) error {
+	return p.saveGroup(groupID, p.MessageBus.AddToGroup(groupID, entityID))
+}
+
+// RemoveFromGroup removes an entity from a group and persists its definition
+func (p *PersistentMessageBus) RemoveFromGroup(groupID, entityID string) error {
+	return p.saveGroup(groupID, p.MessageBus.RemoveFromGroup(groupID, entityID))
+}
+
+// RenameGroup renames a group and persists its definition
+func (p *PersistentMessageBus) RenameGroup(groupID, name string) error {
+	return p.saveGroup(groupID, p.MessageBus.RenameGroup(groupID, name))
+}
+
+// SetMemberRole gives a member a role and persists the group definition
+func (p *PersistentMessageBus) SetMemberRole(groupID, entityID string, role GroupRole) error {
+	return p.saveGroup(groupID, p.MessageBus.SetMemberRole(groupID, entityID, role))
+}
+
+// SetMemberMetadata replaces the metadata of a member and persists the group definition
+func (p *PersistentMessageBus) SetMemberMetadata(groupID, entityID string, metadata map[string]string) error {
+	return p.saveGroup(groupID, p.MessageBus.SetMemberMetadata(groupID, entityID, metadata))
+}
+
+// RemoveGroup deletes a group and its persisted definition
+func (p *PersistentMessageBus) RemoveGroup(groupID string) error {
+	if err := p.MessageBus.RemoveGroup(groupID); err != nil || !p.persistGroups {
+		return err
+	}
+	if err := p.store.PurgeRecord(GroupIDPrefix + groupID); err != nil {
+		return fmt.Errorf("failed to remove persisted group %s: %w", groupID, err)
+	}
+	return nil
+}
+
+// RestoreGroups recreates the persisted groups in the wrapped bus and returns how many
+// were restored. Groups that fail to restore, such as ones that already exist, are
+// skipped and reported in the error.
+func (p *PersistentMessageBus) RestoreGroups() (int, error) {
+	filter, err := knowledge.Query().
+		Where("SourceType", "=", GroupSourceType).
+		OrderBy("ID").
+		Build()
+	if err != nil {
+		return 0, err
+	}
+	entries, err := p.store.SearchRecords(filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read persisted groups: %w", err)
+	}
+	restored := 0
+	var errs []error
+	for _, entry := range entries {
+		if err := p.restoreGroup(entry); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore group %s: %w", entry.SourceID, err))
+			continue
+		}
+		restored++
+	}
+	return restored, errors.Join(errs...)
+}
+
+// restoreGroup recreates a group from its persisted definition
+func (p *PersistentMessageBus) restoreGroup(entry knowledge.Entry) error {
+	var group Group
+	if err := json.Unmarshal(entry.Content, &group); err != nil {
+		return err
+	}
+	members := make([]string, 0, len(group.Members))
+	for memberID := range group.Members {
+		members = append(members, memberID)
+	}
+	if err := p.MessageBus.CreateGroup(group.ID, group.Name, members); err != nil {
+		return err
+	}
+	for memberID, role := range group.Roles {
+		if err := p.MessageBus.SetMemberRole(group.ID, memberID, role); err != nil {
+			return err
+		}
+	}
+	for memberID, metadata := range group.MemberMetadata {
+		if err := p.MessageBus.SetMemberMetadata(group.ID, memberID, metadata); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveGroup persists the definition of a group after a successful change
+func (p *PersistentMessageBus) saveGroup(groupID string, changeErr error) error {
+	if changeErr != nil || !p.persistGroups {
+		return changeErr
+	}
+	group, err := p.MessageBus.GetGroup(groupID)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(group)
+	if err != nil {
+		return err
+	}
+	entry := knowledge.Entry{
+		ID:          GroupIDPrefix + groupID,
+		Category:    knowledge.CategoryFact,
+		ContentType: knowledge.ContentTypeJSON,
+		Content:     content,
+		SourceID:    groupID,
+		SourceType:  GroupSourceType,
+		OwnerID:     group.Owner(),
+	}
+	if existing, err := p.store.GetRecord(entry.ID); err == nil {
+		entry.CreatedAt = existing.CreatedAt
+	}
+	if err := p.store.LoadRecords(entry); err != nil {
+		return fmt.Errorf("failed to persist group %s: %w", groupID, err)
+	}
+	return nil
+}
+
+// journalEntry converts a message to its journal entry
+func journalEntry(msg Message) knowledge.Entry {
+	metadata := make(map[string]string, len(msg.Metadata))
+	for key, value := range msg.Metadata {
+		metadata[key] = value
+	}
+	entry := knowledge.Entry{
+		ID:          JournalIDPrefix + msg.ID,
+		Category:    knowledge.CategoryMessage,
+		ContentType: msg.ContentType,
+		Content:     msg.Content,
+		CreatedAt:   msg.Timestamp,
+		SourceID:    msg.ID,
+		SourceType:  JournalSourceType,
+		OwnerID:     msg.SenderID,
+		SubjectIDs:  append([]string(nil), msg.Recipients...),
+		Metadata:    metadata,
+	}
+	if msg.ReplyTo != "" {
+		metadata[journalReplyToKey] = msg.ReplyTo
+	}
+	if msg.CorrelationID != "" {
+		metadata[journalCorrelationIDKey] = msg.CorrelationID
+	}
+	if msg.Priority != PriorityNormal {
+		metadata[journalPriorityKey] = strconv.Itoa(int(msg.Priority))
+	}
+	if !msg.ExpiresAt.IsZero() {
+		metadata[journalExpiresAtKey] = msg.ExpiresAt.Format(time.RFC3339Nano)
+	}
+	if !msg.DeliverAt.IsZero() {
+		metadata[journalDeliverAtKey] = msg.DeliverAt.Format(time.RFC3339Nano)
+	}
+	if msg.ReplyToID != "" {
+		entry.References = []knowledge.Reference{{ID: JournalIDPrefix + msg.ReplyToID, Type: referenceReplyTo}}
+	}
+	return entry
+}
+
+// journalMessage converts a journal entry back to its message
+func journalMessage(entry knowledge.Entry) Message {
+	msg := Message{
+		ID:          entry.SourceID,
+		SenderID:    entry.OwnerID,
+		Recipients:  append([]string(nil), entry.SubjectIDs...),
+		ContentType: entry.ContentType,
+		Content:     entry.Content,
+		Timestamp:   entry.CreatedAt,
+		Metadata:    make(map[string]string, len(entry.Metadata)),
+	}
+	for key, value := range entry.Metadata {
+		switch key {
+		case journalReplyToKey:
+			msg.ReplyTo = value
+		case journalCorrelationIDKey:
+			msg.CorrelationID = value
+		case journalPriorityKey:
+			priority, _ := strconv.Atoi(value)
+			msg.Priority = Priority(priority)
+		case journalExpiresAtKey:
+			msg.ExpiresAt, _ = time.Parse(time.RFC3339Nano, value)
+		case journalDeliverAtKey:
+			msg.DeliverAt, _ = time.Parse(time.RFC3339Nano, value)
+		default:
+			msg.Metadata[key] = value
+		}
+	}
+	for _, ref := range entry.References {
+		if ref.Type == referenceReplyTo {
+			msg.ReplyToID = strings.TrimPrefix(ref.ID, JournalIDPrefix)
+		}
+	}
+	return msg
+}
@@ -0,0 +1,159 @@
+package messaging
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goproduct/internal/knowledge"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore rejects every new record
+type failingStore struct {
+	knowledge.Store
+}
+
+func (f failingStore) AddRecord(record knowledge.Entry) error {
+	return errors.New("disk full")
+}
+
+func TestPersistentMessageBusJournalsBeforeDelivery(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	bus := NewPersistentMessageBus(NewMemoryMessageBus(), store)
+
+	journaled := make(chan bool, 1)
+	require.NoError(t, bus.Subscribe("bob", func(msg Message) error {
+		_, err := store.GetRecord(JournalIDPrefix + msg.ID)
+		journaled <- err == nil
+		return nil
+	}))
+
+	msg := NewTextMessage("alice", []string{"bob"}, "hello")
+	msg.Metadata["conversation_id"] = "c1"
+	require.NoError(t, bus.Publish(msg))
+	select {
+	case ok := <-journaled:
+		assert.True(t, ok, "message should be journaled before delivery")
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for message")
+	}
+
+	entry, err := store.GetRecord(JournalIDPrefix + msg.ID)
+	require.NoError(t, err)
+	assert.Equal(t, knowledge.CategoryMessage, entry.Category)
+	assert.Equal(t, "alice", entry.OwnerID)
+	assert.Equal(t, []string{"bob"}, entry.SubjectIDs)
+	assert.Equal(t, "c1", entry.Metadata["conversation_id"])
+
+	// Publishing the same message again redelivers it without a second entry
+	require.NoError(t, bus.Publish(msg))
+	count, _ := store.CountRecords(knowledge.Filter{})
+	assert.Equal(t, 1, count)
+}
+
+func TestPersistentMessageBusDoesNotDeliverUnjournaled(t *testing.T) {
+	memory, _ := knowledge.NewMemoryStore()
+	bus := NewPersistentMessageBus(NewMemoryMessageBus(), failingStore{memory})
+
+	received := make(chan Message, 1)
+	require.NoError(t, bus.Subscribe("bob", func(msg Message) error {
+		received <- msg
+		return nil
+	}))
+
+	err := bus.Publish(NewTextMessage("alice", []string{"bob"}, "hello"))
+	assert.ErrorContains(t, err, "disk full")
+	select {
+	case <-received:
+		t.Error("Message that failed to journal should not be delivered")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPersistentMessageBusReplayAfterRestart(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "journal.json")
+	store, _ := knowledge.NewFileStore(filename)
+	require.NoError(t, store.Open())
+
+	bus := NewPersistentMessageBus(NewMemoryMessageBus(), store)
+	start := time.Now()
+	question := NewTextMessage("alice", []string{"andy"}, "When is the release?")
+	question.Timestamp = start
+	answer := NewTextReplyMessage("andy", question, "Friday")
+	answer.Timestamp = start.Add(time.Second)
+	require.NoError(t, bus.Publish(question))
+	require.NoError(t, bus.Publish(answer))
+	require.NoError(t, store.Close())
+
+	// A new process replays the conversation from the same store
+	reopened, _ := knowledge.NewFileStore(filename)
+	require.NoError(t, reopened.Open())
+	defer reopened.Close()
+	restarted := NewPersistentMessageBus(NewMemoryMessageBus(), reopened)
+
+	var replayed []Message
+	count, err := restarted.Replay(start, func(msg Message) error {
+		replayed = append(replayed, msg)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	assert.Equal(t, question.ID, replayed[0].ID)
+	assert.Equal(t, "When is the release?", string(replayed[0].Content))
+	assert.Equal(t, question.ID, replayed[1].ReplyToID)
+	assert.Equal(t, []string{"alice"}, replayed[1].Recipients)
+
+	// Only messages from the given time on are replayed
+	later, err := restarted.Journal(start.Add(500 * time.Millisecond))
+	require.NoError(t, err)
+	require.Len(t, later, 1)
+	assert.Equal(t, answer.ID, later[0].ID)
+
+	// Replay stops at the first handler error
+	count, err = restarted.Replay(start, func(msg Message) error { return errors.New("stop") })
+	assert.Error(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestPersistentMessageBusRestoresGroups(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	bus := NewPersistentMessageBus(NewMemoryMessageBus(), store, WithGroupPersistence())
+	require.NoError(t, bus.CreateGroup("team", "Team", []string{"alice", "bob"}))
+	require.NoError(t, bus.SetMemberRole("team", "alice", GroupRoleOwner))
+	require.NoError(t, bus.SetMemberMetadata("team", "bob", map[string]string{"timezone": "UTC"}))
+	require.NoError(t, bus.RenameGroup("team", "Core team"))
+	require.NoError(t, bus.CreateGroup("gone", "Gone", nil))
+	require.NoError(t, bus.RemoveGroup("gone"))
+
+	entry, err := store.GetRecord(GroupIDPrefix + "team")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", entry.OwnerID)
+
+	// A new process restores the groups from the store
+	restarted := NewPersistentMessageBus(NewMemoryMessageBus(), store, WithGroupPersistence())
+	count, err := restarted.RestoreGroups()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	group, err := restarted.GetGroup("team")
+	require.NoError(t, err)
+	assert.Equal(t, "Core team", group.Name)
+	assert.Equal(t, GroupRoleOwner, group.Role("alice"))
+	assert.Equal(t, "UTC", group.MemberMetadata["bob"]["timezone"])
+
+	// Restoring again reports the groups that already exist
+	count, err = restarted.RestoreGroups()
+	assert.Equal(t, 0, count)
+	assert.ErrorContains(t, err, "already exists")
+}
+
+func TestPersistentMessageBusGroupsAreNotPersistedByDefault(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	bus := NewPersistentMessageBus(NewMemoryMessageBus(), store)
+	require.NoError(t, bus.CreateGroup("team", "Team", []string{"alice"}))
+	count, _ := store.CountRecords(knowledge.Filter{})
+	assert.Equal(t, 0, count)
+}
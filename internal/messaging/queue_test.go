@@ -0,0 +1,160 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// publishText publishes a text message from alice
+func publishText(bus MessageBus, recipients []string, text string) error {
+	return bus.Publish(NewTextMessage("alice", recipients, text))
+}
+
+func TestBoundedQueueLimitsWorkers(t *testing.T) {
+	bus := NewMemoryMessageBus(WithQueueDepth(10), WithQueueWorkers(2))
+	handler := newRecordingHandler()
+	require.NoError(t, bus.Subscribe("bob", handler.handle))
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, publishText(bus, []string{"bob"}, "hello"))
+	}
+	<-handler.started
+	<-handler.started
+	select {
+	case <-handler.started:
+		t.Fatal("only two workers should handle messages")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, QueueStats{Depth: 3, Active: 2}, bus.QueueStats()["bob"])
+
+	close(handler.gate)
+	require.Eventually(t, func() bool { return len(handler.handled()) == 5 }, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool { return bus.QueueStats()["bob"] == QueueStats{} }, time.Second, 5*time.Millisecond)
+}
+
+func TestOverflowError(t *testing.T) {
+	bus := NewMemoryMessageBus(WithQueueDepth(1), WithQueueWorkers(1), WithOverflowPolicy(OverflowError))
+	blocked := newRecordingHandler()
+	free := newRecordingHandler()
+	close(free.gate)
+	require.NoError(t, bus.Subscribe("bob", blocked.handle))
+	require.NoError(t, bus.Subscribe("carol", free.handle))
+
+	require.NoError(t, publishText(bus, []string{"bob"}, "handled"))
+	<-blocked.started
+	require.NoError(t, publishText(bus, []string{"bob"}, "queued"))
+
+	err := publishText(bus, []string{"bob", "carol"}, "overflow")
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Contains(t, err.Error(), "bob")
+	require.Eventually(t, func() bool { return len(free.handled()) == 1 }, time.Second, 5*time.Millisecond, "other recipients still receive the message")
+	assert.Equal(t, uint64(1), bus.QueueStats()["bob"].Rejected)
+	assert.Empty(t, bus.GetDeadLetters(), "the publisher is told instead")
+	close(blocked.gate)
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	bus := NewMemoryMessageBus(WithQueueDepth(2), WithQueueWorkers(1), WithOverflowPolicy(OverflowDropOldest))
+	handler := newRecordingHandler()
+	require.NoError(t, bus.Subscribe("bob", handler.handle))
+
+	require.NoError(t, publishText(bus, []string{"bob"}, "first"))
+	<-handler.started
+	for _, text := range []string{"second", "third", "fourth"} {
+		require.NoError(t, publishText(bus, []string{"bob"}, text))
+	}
+
+	letters := bus.GetDeadLetters()
+	require.Len(t, letters, 1)
+	assert.Equal(t, "second", string(letters[0].Message.Content))
+	assert.Contains(t, letters[0].LastError, ErrQueueFull.Error())
+	assert.Equal(t, uint64(1), bus.QueueStats()["bob"].Dropped)
+
+	close(handler.gate)
+	require.Eventually(t, func() bool { return len(handler.handled()) == 3 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"first", "third", "fourth"}, handler.handled())
+}
+
+func TestOverflowDropOldestFailsAcknowledgedDelivery(t *testing.T) {
+	bus := NewMemoryMessageBus(WithQueueDepth(1), WithQueueWorkers(1), WithOverflowPolicy(OverflowDropOldest))
+	handler := newRecordingHandler()
+	require.NoError(t, bus.Subscribe("bob", handler.handle))
+
+	require.NoError(t, publishText(bus, []string{"bob"}, "first"))
+	<-handler.started
+	dropped := NewTextMessage("alice", []string{"bob"}, "dropped").WithAck()
+	require.NoError(t, bus.Publish(dropped))
+	require.NoError(t, publishText(bus, []string{"bob"}, "kept"))
+
+	status, err := bus.DeliveryStatus(dropped.ID)
+	require.NoError(t, err)
+	assert.Equal(t, DeliveryFailed, status.Recipients["bob"].State)
+	close(handler.gate)
+}
+
+func TestOverflowBlock(t *testing.T) {
+	bus := NewMemoryMessageBus(WithQueueDepth(1), WithQueueWorkers(1))
+	handler := newRecordingHandler()
+	require.NoError(t, bus.Subscribe("bob", handler.handle))
+
+	require.NoError(t, publishText(bus, []string{"bob"}, "first"))
+	<-handler.started
+	require.NoError(t, publishText(bus, []string{"bob"}, "second"))
+
+	published := make(chan error, 1)
+	go func() { published <- publishText(bus, []string{"bob"}, "third") }()
+	select {
+	case <-published:
+		t.Fatal("Publish should wait for space in the queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(handler.gate)
+	select {
+	case err := <-published:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Publish should return once the queue has space")
+	}
+	require.Eventually(t, func() bool { return len(handler.handled()) == 3 }, time.Second, 5*time.Millisecond)
+}
+
+func TestOverflowBlockDoesNotHoldBusLock(t *testing.T) {
+	bus := NewMemoryMessageBus(WithQueueDepth(1), WithQueueWorkers(1))
+	handler := newRecordingHandler()
+	require.NoError(t, bus.Subscribe("bob", handler.handle))
+
+	require.NoError(t, publishText(bus, []string{"bob"}, "first"))
+	<-handler.started
+	require.NoError(t, publishText(bus, []string{"bob"}, "second"))
+	published := make(chan error, 1)
+	go func() { published <- publishText(bus, []string{"bob"}, "third") }()
+	require.Eventually(t, func() bool { return bus.QueueStats()["bob"].Depth == 1 }, time.Second, 5*time.Millisecond)
+
+	// Writers, such as subscriptions, go on while Publish waits for space
+	subscribed := make(chan error, 1)
+	go func() { subscribed <- bus.Subscribe("carol", func(Message) error { return nil }) }()
+	select {
+	case err := <-subscribed:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Subscribe should not wait for the blocked Publish")
+	}
+	select {
+	case <-published:
+		t.Fatal("Publish should still wait for space in the queue")
+	default:
+	}
+
+	close(handler.gate)
+	select {
+	case err := <-published:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Publish should return once the queue has space")
+	}
+	require.Eventually(t, func() bool { return len(handler.handled()) == 3 }, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,197 @@
+package messaging
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is a Redis server supporting AUTH, PUBLISH, SUBSCRIBE and UNSUBSCRIBE
+type fakeRedis struct {
+	listener net.Listener
+	password string // Required password, if set
+
+	mu    sync.Mutex
+	conns map[net.Conn]map[string]bool // Connection to subscribed channels
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakeRedis{listener: listener, password: password, conns: make(map[net.Conn]map[string]bool)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close(); server.drop() })
+	return server
+}
+
+func (f *fakeRedis) url() string {
+	return "redis://" + f.listener.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	f.mu.Lock()
+	f.conns[conn] = make(map[string]bool)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, conn)
+		f.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := &respConn{conn: conn, reader: bufio.NewReader(conn)}
+	authenticated := f.password == ""
+	for {
+		reply, err := reader.read()
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		f.mu.Lock()
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			if args[len(args)-1] == f.password {
+				authenticated = true
+				fmt.Fprint(conn, "+OK\r\n")
+			} else {
+				fmt.Fprint(conn, "-WRONGPASS invalid password\r\n")
+			}
+		case "PUBLISH":
+			if !authenticated {
+				fmt.Fprint(conn, "-NOAUTH Authentication required\r\n")
+				break
+			}
+			receivers := 0
+			for other, channels := range f.conns {
+				if channels[args[1]] {
+					fmt.Fprintf(other, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(args[1]), args[1], len(args[2]), args[2])
+					receivers++
+				}
+			}
+			fmt.Fprintf(conn, ":%d\r\n", receivers)
+		case "SUBSCRIBE":
+			for _, channel := range args[1:] {
+				f.conns[conn][channel] = true
+				fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:%d\r\n", len(channel), channel, len(f.conns[conn]))
+			}
+		case "UNSUBSCRIBE":
+			for _, channel := range args[1:] {
+				delete(f.conns[conn], channel)
+				fmt.Fprintf(conn, "*3\r\n$11\r\nunsubscribe\r\n$%d\r\n%s\r\n:%d\r\n", len(channel), channel, len(f.conns[conn]))
+			}
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", args[0])
+		}
+		f.mu.Unlock()
+	}
+}
+
+// drop closes every client connection
+func (f *fakeRedis) drop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for conn := range f.conns {
+		conn.Close()
+	}
+}
+
+func TestRedisMessageBusBetweenProcesses(t *testing.T) {
+	server := newFakeRedis(t, "")
+	busA, err := NewRedisMessageBus(server.url())
+	require.NoError(t, err)
+	defer busA.Close()
+	busB, err := NewRedisMessageBus(server.url())
+	require.NoError(t, err)
+	defer busB.Close()
+
+	alice := make(chan Message, 10)
+	bob := make(chan Message, 10)
+	require.NoError(t, busA.Subscribe("alice", func(msg Message) error { alice <- msg; return nil }))
+	require.NoError(t, busB.Subscribe("bob", func(msg Message) error { bob <- msg; return nil }))
+
+	msg := NewTextMessage("alice", []string{"bob"}, "hello bob")
+	require.NoError(t, busA.Publish(msg))
+	select {
+	case got := <-bob:
+		assert.Equal(t, msg.ID, got.ID)
+		assert.Equal(t, "alice", got.SenderID)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for message")
+	}
+
+	// Group messages fan out to the local members of every process
+	require.NoError(t, busA.CreateGroup("team", "Team", []string{"alice"}))
+	require.NoError(t, busB.CreateGroup("team", "Team", []string{"bob"}))
+	require.NoError(t, busB.Publish(NewTextMessage("carol", []string{"team"}, "standup")))
+	assert.Equal(t, "standup", receiveText(t, alice))
+	assert.Equal(t, "standup", receiveText(t, bob))
+
+	require.NoError(t, busA.Publish(NewTextMessage("alice", []string{BroadcastAddress}, "all hands")))
+	assert.Equal(t, "all hands", receiveText(t, bob))
+	expectNothing(t, alice)
+
+	require.NoError(t, busB.Unsubscribe("bob"))
+	require.NoError(t, busA.Publish(NewTextMessage("alice", []string{"bob"}, "gone?")))
+	expectNothing(t, bob)
+}
+
+func TestRedisMessageBusReconnects(t *testing.T) {
+	server := newFakeRedis(t, "")
+	bus, err := NewRedisMessageBus(server.url(), WithRedisReconnectWait(10*time.Millisecond))
+	require.NoError(t, err)
+	defer bus.Close()
+
+	received := make(chan Message, 10)
+	require.NoError(t, bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil }))
+
+	server.drop()
+	// Both connections recover: publishing redials and the channels are resubscribed
+	require.Eventually(t, func() bool {
+		bus.Publish(NewTextMessage("alice", []string{"bob"}, "after reconnect"))
+		select {
+		case msg := <-received:
+			return string(msg.Content) == "after reconnect"
+		case <-time.After(20 * time.Millisecond):
+			return false
+		}
+	}, 2*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, bus.Close())
+	assert.ErrorIs(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "closed")), ErrBusClosed)
+}
+
+func TestRedisMessageBusAuthentication(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	_, err := NewRedisMessageBus("redis://:wrong@" + server.listener.Addr().String())
+	assert.ErrorContains(t, err, "WRONGPASS")
+
+	bus, err := NewRedisMessageBus("redis://:secret@" + server.listener.Addr().String())
+	require.NoError(t, err)
+	defer bus.Close()
+	assert.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "hello")))
+}
+
+var _ MessageBus = (*RedisMessageBus)(nil)
@@ -0,0 +1,616 @@
+package messaging
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"goproduct/internal/logging"
+	"goproduct/internal/tracing"
+)
+
+// localRegistry holds what a bus spanning several processes knows about this process:
+// the subscriptions of its entities and the groups they joined here. Messages arriving
+// from the transport are routed to them. Group membership is local to each process;
+// every process whose entities join a group receives the group's messages. Messages
+// that requested acknowledgement are retried by the receiving process, which also
+// keeps their delivery status.
+type localRegistry struct {
+	subscriptions map[string]subscription
+	topics        topicSubscriptions
+	groups        map[string]*Group
+	tracer        tracing.Tracer
+	logger        *logging.Logger
+	deliveries    *deliveryTracker // Acknowledged deliveries to local subscribers
+	deadLetters   *deadLetterQueue // Deliveries to local subscribers that failed
+	queues        *recipientQueues // Runs deliveries according to the delivery mode
+	scheduler     *scheduler       // Holds messages published by this process until their DeliverAt
+	middleware    middlewareChain
+	patterns      patternSubscriptions // Pattern handlers for the traffic reaching this process
+	history       *messageHistory      // Messages published by or routed to this process, nil unless enabled
+	mu            sync.RWMutex
+}
+
+// newLocalRegistry creates an empty registry
+func newLocalRegistry() *localRegistry {
+	deadLetters := newDeadLetterQueue()
+	return &localRegistry{
+		subscriptions: make(map[string]subscription),
+		topics:        make(topicSubscriptions),
+		groups:        make(map[string]*Group),
+		tracer:        tracing.NewNoopTracer(),
+		logger:        logging.Get().Component("messaging"),
+		deliveries:    newDeliveryTracker(deadLetters),
+		deadLetters:   deadLetters,
+		queues:        newRecipientQueues(),
+	}
+}
+
+// route delivers a message received for the address to the local subscribers: every
+// subscriber for a broadcast, the local subscribers of a topic, the local members of a
+// group, or the entity itself. Pattern subscriptions only see the entities of this
+// process, and receive the message once per address it arrived on.
+func (r *localRegistry) route(address string, msg Message) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matches patternMatches
+	var concerned []string
+	defer func() {
+		matches.each(func(ps *patternSubscription, entityID string) {
+			r.deliver(ps.pattern, ps.sub, true, msg, "pattern", entityID)
+		})
+		r.history.record(msg, concerned...)
+	}()
+
+	if topic, ok := topicOf(address); ok {
+		for _, entityID := range r.topics.subscribers(topic) {
+			sub, subscribed := r.subscriptions[entityID]
+			if entityID == msg.SenderID {
+				continue
+			}
+			concerned = append(concerned, entityID)
+			if r.patterns.match(entityID, subscribed, &matches) {
+				continue
+			}
+			r.deliver(entityID, sub, subscribed, msg, "topic", topic)
+		}
+		return
+	}
+
+	if address == BroadcastAddress {
+		for entityID, sub := range r.subscriptions {
+			if entityID != msg.SenderID {
+				concerned = append(concerned, entityID)
+				r.patterns.match(entityID, true, &matches)
+			}
+			r.deliver(entityID, sub, true, msg, "broadcast", "")
+		}
+		return
+	}
+	if group, ok := r.groups[address]; ok {
+		for memberID := range group.Members {
+			sub, subscribed := r.subscriptions[memberID]
+			if memberID == msg.SenderID {
+				continue
+			}
+			concerned = append(concerned, memberID)
+			if r.patterns.match(memberID, subscribed, &matches) {
+				continue
+			}
+			r.deliver(memberID, sub, subscribed, msg, "group", address)
+		}
+	}
+	if sub, ok := r.subscriptions[address]; ok {
+		concerned = append(concerned, address)
+		r.patterns.match(address, true, &matches)
+		r.deliver(address, sub, true, msg, "direct", "")
+	}
+}
+
+// deliver passes the message to the handler on its own goroutine, or through the
+// recipient's queue in DeliveryOrdered mode, unless the recipient sent it. Messages that
+// expired in the meantime are dropped. Messages that requested acknowledgement are retried by the delivery tracker;
+// others get a single attempt if the recipient is subscribed and accepts them. Failed
+// deliveries become dead letters (must be called with the read lock held).
+func (r *localRegistry) deliver(recipientID string, sub subscription, subscribed bool, msg Message, via, from string) {
+	if recipientID == msg.SenderID {
+		return
+	}
+	if !msg.AckRequested() {
+		if subscribed && sub.accepts(msg) {
+			dropped := func(err error) { r.deadLetters.add(msg, recipientID, 0, err) }
+			err := r.queues.run(recipientID, msg.Priority, func() {
+				if msg.Expired(time.Now()) {
+					traceExpired(r.getTracer(), msg, recipientID)
+					return
+				}
+				if err := r.handle(recipientID, sub.handler, msg, via, from); err != nil {
+					r.deadLetters.add(msg, recipientID, 1, err)
+				}
+			}, dropped)
+			if err != nil {
+				dropped(err)
+			}
+		}
+		return
+	}
+
+	first := true
+	delivery := r.deliveries.start(msg, recipientID, func() error {
+		if msg.Expired(time.Now()) {
+			traceExpired(r.getTracer(), msg, recipientID)
+			return errExpired
+		}
+		if !first {
+			r.mu.RLock()
+			sub, subscribed = r.subscription(recipientID, via)
+			r.mu.RUnlock()
+		}
+		first = false
+		if !subscribed {
+			return errNotSubscribed
+		}
+		if !sub.accepts(msg) {
+			return errSkipped
+		}
+		return r.handle(recipientID, sub.handler, msg, via, from)
+	})
+	// There is no publisher to report a full queue to, so refused deliveries are dropped
+	dropped := func(err error) {
+		r.deliveries.abandon(msg.ID, recipientID, err)
+		r.deadLetters.add(msg, recipientID, 0, err)
+	}
+	if err := r.queues.run(recipientID, msg.Priority, delivery, dropped); err != nil {
+		dropped(err)
+	}
+}
+
+// handle calls a handler, tracing the delivery, and returns the handler error; a panic
+// in the handler is recovered and returned as an error
+func (r *localRegistry) handle(recipientID string, handler MessageHandler, msg Message, via, from string) (err error) {
+	defer func() { recordDelivery(via, err) }()
+	tracer, logger := r.getTracer(), r.logger
+	defer func() {
+		if p := recover(); p != nil {
+			tracer.Trace(tracing.Event{
+				Timestamp: time.Now(),
+				Component: tracing.ComponentMessaging,
+				Operation: tracing.OperationReceive,
+				Level:     tracing.LevelError,
+				SourceID:  msg.SenderID,
+				TargetID:  recipientID,
+				ObjectID:  msg.ID,
+				Message:   fmt.Sprintf("Panic in message handler: %v", p),
+			})
+			logger.Error("Panic in message handler", "error", p, "message_id", msg.ID, "recipient", recipientID)
+			err = fmt.Errorf("panic in message handler: %v", p)
+		}
+	}()
+
+	tracer.Trace(tracing.Event{
+		Timestamp: msg.Timestamp,
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationReceive,
+		Level:     tracing.LevelInfo,
+		SourceID:  msg.SenderID,
+		TargetID:  recipientID,
+		ObjectID:  msg.ID,
+		Message:   "Message received via " + via,
+	})
+	if err := r.middleware.deliver(handler, Delivery{RecipientID: recipientID, Via: via, From: from}, msg); err != nil {
+		logger.Error("Error in message handler", "error", err, "message_id", msg.ID, "recipient", recipientID)
+		tracer.Trace(tracing.Event{
+			Timestamp: time.Now(),
+			Component: tracing.ComponentMessaging,
+			Operation: tracing.OperationReceive,
+			Level:     tracing.LevelError,
+			SourceID:  msg.SenderID,
+			TargetID:  recipientID,
+			ObjectID:  msg.ID,
+			Message:   fmt.Sprintf("Error in message handler: %v", err),
+		})
+		return err
+	}
+	return nil
+}
+
+// subscribe registers the handler and reports whether the address is new to this process
+func (r *localRegistry) subscribe(entityID string, handler MessageHandler, filters []SubscriptionFilter) (bool, error) {
+	if handler == nil {
+		return false, fmt.Errorf("message handler cannot be nil")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	isNew := !r.listening(entityID)
+	r.subscriptions[entityID] = subscription{handler: handler, filters: filters}
+	r.logger.Info("Entity subscribed to message bus", "entity_id", entityID, "filters", len(filters))
+	return isNew, nil
+}
+
+// unsubscribe removes the handler and reports whether the address is no longer needed
+func (r *localRegistry) unsubscribe(entityID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, existed := r.subscriptions[entityID]
+	delete(r.subscriptions, entityID)
+	r.logger.Info("Entity unsubscribed from message bus", "entity_id", entityID)
+	return existed && !r.listening(entityID)
+}
+
+// createGroup adds a group whose messages this process receives
+func (r *localRegistry) createGroup(groupID, name string, members []string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.groups[groupID]; exists {
+		return false, fmt.Errorf("group with ID %s already exists", groupID)
+	}
+	isNew := !r.listening(groupID)
+	r.groups[groupID] = newGroup(groupID, name, members)
+	r.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationCreate,
+		Level:     tracing.LevelInfo,
+		ObjectID:  groupID,
+		Message:   "Message group created",
+		Metadata:  map[string]interface{}{"name": name, "members": members},
+	})
+	return isNew, nil
+}
+
+// setMember adds or removes a local member of a group
+func (r *localRegistry) setMember(groupID, entityID string, member bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	group, exists := r.groups[groupID]
+	if !exists {
+		return fmt.Errorf("group with ID %s does not exist", groupID)
+	}
+	operation, message := tracing.OperationJoin, "Entity added to message group"
+	if member {
+		group.Members[entityID] = true
+	} else {
+		group.removeMember(entityID)
+		operation, message = tracing.OperationLeave, "Entity removed from message group"
+	}
+	r.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: operation,
+		Level:     tracing.LevelInfo,
+		TargetID:  entityID,
+		ObjectID:  groupID,
+		Message:   message,
+	})
+	return nil
+}
+
+// removeGroup deletes a local group and reports whether the address is no longer needed
+func (r *localRegistry) removeGroup(groupID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.groups[groupID]; !exists {
+		return false, fmt.Errorf("group with ID %s does not exist", groupID)
+	}
+	delete(r.groups, groupID)
+	r.logger.Info("Message group removed", "group_id", groupID)
+	r.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationDelete,
+		Level:     tracing.LevelInfo,
+		ObjectID:  groupID,
+		Message:   "Message group removed",
+	})
+	return !r.listening(groupID), nil
+}
+
+// updateGroup changes a local group, tracing the change with the message
+func (r *localRegistry) updateGroup(groupID, entityID, message string, update func(group *Group) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	group, exists := r.groups[groupID]
+	if !exists {
+		return fmt.Errorf("group with ID %s does not exist", groupID)
+	}
+	if err := update(group); err != nil {
+		return err
+	}
+	r.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationUpdate,
+		Level:     tracing.LevelInfo,
+		TargetID:  entityID,
+		ObjectID:  groupID,
+		Message:   message,
+	})
+	return nil
+}
+
+// getGroup returns a copy of a local group
+func (r *localRegistry) getGroup(groupID string) (Group, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	group, exists := r.groups[groupID]
+	if !exists {
+		return Group{}, fmt.Errorf("group with ID %s does not exist", groupID)
+	}
+	return group.clone(), nil
+}
+
+// listGroups returns copies of the local groups sorted by ID
+func (r *localRegistry) listGroups() []Group {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return listGroups(r.groups)
+}
+
+// subscribeTopic subscribes a local entity to the topics matching the pattern
+func (r *localRegistry) subscribeTopic(entityID, pattern string) error {
+	if err := validateTopicPattern(pattern); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.topics.add(entityID, pattern)
+	r.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationJoin,
+		Level:     tracing.LevelInfo,
+		TargetID:  entityID,
+		ObjectID:  pattern,
+		Message:   "Entity subscribed to topic",
+	})
+	return nil
+}
+
+// unsubscribeTopic removes a topic subscription of a local entity
+func (r *localRegistry) unsubscribeTopic(entityID, pattern string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.topics.remove(entityID, pattern) {
+		return fmt.Errorf("entity %s is not subscribed to topic %s", entityID, pattern)
+	}
+	r.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationLeave,
+		Level:     tracing.LevelInfo,
+		TargetID:  entityID,
+		ObjectID:  pattern,
+		Message:   "Entity unsubscribed from topic",
+	})
+	return nil
+}
+
+// getHistory returns the retained messages of a local entity, see
+// MemoryMessageBus.GetHistory
+func (r *localRegistry) getHistory(entityID string, since time.Time, limit int) ([]Message, error) {
+	return r.history.query(entityID, since, limit)
+}
+
+// subscribePattern registers a handler for the local entities matching the pattern
+func (r *localRegistry) subscribePattern(pattern string, mode PatternMode, handler MessageHandler, filters []SubscriptionFilter) error {
+	ps, err := newPatternSubscription(pattern, mode, handler, filters)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.patterns.add(ps)
+	r.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationCreate,
+		Level:     tracing.LevelInfo,
+		TargetID:  pattern,
+		Message:   "Pattern subscribed to message bus",
+	})
+	return nil
+}
+
+// unsubscribePattern removes the handler registered for a pattern
+func (r *localRegistry) unsubscribePattern(pattern string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.patterns.remove(pattern) {
+		return fmt.Errorf("pattern %s is not subscribed", pattern)
+	}
+	r.tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationDelete,
+		Level:     tracing.LevelInfo,
+		TargetID:  pattern,
+		Message:   "Pattern unsubscribed from message bus",
+	})
+	return nil
+}
+
+// members returns the local members of a group
+func (r *localRegistry) members(groupID string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	group, exists := r.groups[groupID]
+	if !exists {
+		return nil, fmt.Errorf("group with ID %s does not exist", groupID)
+	}
+	members := make([]string, 0, len(group.Members))
+	for memberID := range group.Members {
+		members = append(members, memberID)
+	}
+	return members, nil
+}
+
+// addresses returns every address this process listens on
+func (r *localRegistry) addresses() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	addresses := make([]string, 0, len(r.subscriptions)+len(r.groups))
+	for entityID := range r.subscriptions {
+		addresses = append(addresses, entityID)
+	}
+	for groupID := range r.groups {
+		if _, ok := r.subscriptions[groupID]; !ok {
+			addresses = append(addresses, groupID)
+		}
+	}
+	return addresses
+}
+
+// listening checks if an entity or group of this process uses the address
+// (must be called with lock held)
+func (r *localRegistry) listening(address string) bool {
+	_, isEntity := r.subscriptions[address]
+	_, isGroup := r.groups[address]
+	return isEntity || isGroup
+}
+
+// setRetryPolicy sets how acknowledged deliveries to local subscribers are retried
+func (r *localRegistry) setRetryPolicy(policy RetryPolicy) {
+	r.deliveries.setPolicy(policy)
+}
+
+// deliveryStatus returns the status of an acknowledged delivery to local subscribers
+func (r *localRegistry) deliveryStatus(messageID string) (DeliveryStatus, error) {
+	return r.deliveries.status(messageID)
+}
+
+// replayDeadLetter removes a dead letter from the queue and delivers its message to
+// the local recipient again
+func (r *localRegistry) replayDeadLetter(deadLetterID string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	letter, err := r.deadLetters.take(deadLetterID)
+	if err != nil {
+		return err
+	}
+	via := "direct"
+	sub, ok := r.subscriptions[letter.RecipientID]
+	if !ok {
+		if sub, ok = r.subscription(letter.RecipientID, "pattern"); !ok {
+			r.deadLetters.restore(letter)
+			return fmt.Errorf("recipient %s is not subscribed", letter.RecipientID)
+		}
+		via = "pattern"
+	}
+	r.deliver(letter.RecipientID, sub, true, letter.Message, via, "")
+	return nil
+}
+
+// subscription looks up the subscription of a local recipient, which is a pattern for
+// pattern deliveries (must be called with the read lock held)
+func (r *localRegistry) subscription(recipientID, via string) (subscription, bool) {
+	if via == "pattern" {
+		ps, ok := r.patterns.byPattern[recipientID]
+		if !ok {
+			return subscription{}, false
+		}
+		return ps.sub, true
+	}
+	sub, ok := r.subscriptions[recipientID]
+	return sub, ok
+}
+
+// setTracer replaces the tracer, using a no-op tracer for nil
+func (r *localRegistry) setTracer(tracer tracing.Tracer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if tracer == nil {
+		tracer = tracing.NewNoopTracer()
+	}
+	r.tracer = tracer
+}
+
+// getTracer returns the tracer
+func (r *localRegistry) getTracer() tracing.Tracer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tracer
+}
+
+// hold checks if a message is to be published later or dropped as expired instead of
+// published now, scheduling or tracing it
+func (r *localRegistry) hold(msg Message) bool {
+	now := time.Now()
+	switch {
+	case msg.scheduled(now):
+		r.scheduler.schedule(msg)
+		traceScheduled(r.getTracer(), msg)
+		return true
+	case msg.Expired(now):
+		traceExpired(r.getTracer(), msg, "")
+		return true
+	}
+	return false
+}
+
+// tracePublish traces and counts a message handed to the transport and retains it in the
+// history of its sender
+func (r *localRegistry) tracePublish(msg Message, transport string) {
+	messagesPublished.Inc(transport)
+	r.history.record(msg, msg.SenderID)
+	r.getTracer().Trace(tracing.Event{
+		Timestamp: msg.Timestamp,
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationSend,
+		Level:     tracing.LevelInfo,
+		SourceID:  msg.SenderID,
+		ObjectID:  msg.ID,
+		Message:   "Message published",
+		Metadata:  publishMetadata(msg, transport),
+	})
+}
+
+// publishMetadata returns the metadata of the trace event of a published message; the
+// message it replies to and its correlation ID let tracers correlate it with the rest
+func publishMetadata(msg Message, transport string) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"contentType": msg.ContentType,
+		"recipients":  msg.Recipients,
+	}
+	if transport != "" {
+		metadata["transport"] = transport
+	}
+	if msg.ReplyToID != "" {
+		metadata["reply_to_id"] = msg.ReplyToID
+	}
+	if correlationID := msg.Correlation(); correlationID != "" {
+		metadata[MetadataCorrelationID] = correlationID
+	}
+	return metadata
+}
+
+// splitTopics separates the topics among the recipients of a message from the other
+// recipients, validating them
+func splitTopics(recipients []string) (topics, others []string, err error) {
+	for _, recipientID := range recipients {
+		topic, ok := topicOf(recipientID)
+		if !ok {
+			others = append(others, recipientID)
+			continue
+		}
+		if err := validateTopic(topic); err != nil {
+			return nil, nil, err
+		}
+		topics = append(topics, recipientID)
+	}
+	return topics, others, nil
+}
+
+// validateAddress rejects entity and group IDs that cannot be used as a subject or
+// channel name
+func validateAddress(address string) error {
+	if address == "" {
+		return fmt.Errorf("address cannot be empty")
+	}
+	if strings.ContainsAny(address, " \t\r\n*>") {
+		return fmt.Errorf("invalid address %q: must not contain whitespace, '*' or '>'", address)
+	}
+	return nil
+}
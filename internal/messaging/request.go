@@ -0,0 +1,84 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// inboxPrefix starts the addresses of the temporary reply inboxes of requests
+const inboxPrefix = "_inbox-"
+
+// Request publishes msg on the bus and waits until a reply arrives or ctx is done. The
+// message is sent with a temporary inbox as its ReplyTo address and, unless it already
+// has one, its ID as CorrelationID; replies created with NewReplyMessage go to the inbox
+// and match on the CorrelationID. Replies without one match on ReplyToID.
+func Request(ctx context.Context, bus MessageBus, msg Message) (Message, error) {
+	return RequestStream(ctx, bus, msg, nil)
+}
+
+// RequestStream is Request asking the recipient, with MetadataStream, for its reply as it
+// is written. The partial replies that come ahead of the reply are passed to partial one
+// at a time, and none after the reply is returned; as each holds the reply so far, one
+// that arrives out of order is superseded by those after it. A nil partial asks for none.
+func RequestStream(ctx context.Context, bus MessageBus, msg Message, partial func(Message)) (Message, error) {
+	if msg.CorrelationID == "" {
+		msg.CorrelationID = msg.ID
+	}
+	inbox := inboxPrefix + uuid.New().String()
+	msg.ReplyTo = inbox
+	if partial != nil {
+		metadata := make(map[string]string, len(msg.Metadata)+1)
+		for k, v := range msg.Metadata {
+			metadata[k] = v
+		}
+		metadata[MetadataStream] = "true"
+		msg.Metadata = metadata
+	}
+
+	var (
+		mu       sync.Mutex // Serializes the partial replies and ends them with the reply
+		finished bool
+	)
+	replies := make(chan Message, 1)
+	err := bus.Subscribe(inbox, func(reply Message) error {
+		if reply.CorrelationID != msg.CorrelationID && (reply.CorrelationID != "" || reply.ReplyToID != msg.ID) {
+			return nil
+		}
+		if reply.Metadata[MetadataPartial] == "true" {
+			mu.Lock()
+			defer mu.Unlock()
+			if partial != nil && !finished {
+				partial(reply)
+			}
+			return nil
+		}
+		select {
+		case replies <- reply:
+		default: // Only the first reply is returned
+		}
+		return nil
+	})
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to subscribe reply inbox: %w", err)
+	}
+	defer bus.Unsubscribe(inbox)
+	defer func() {
+		mu.Lock()
+		finished = true
+		mu.Unlock()
+	}()
+
+	if err := bus.Publish(msg); err != nil {
+		return Message{}, err
+	}
+
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-ctx.Done():
+		return Message{}, fmt.Errorf("no reply to message %s: %w", msg.ID, ctx.Err())
+	}
+}
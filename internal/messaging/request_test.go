@@ -0,0 +1,150 @@
+package messaging
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goproduct/internal/knowledge"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoResponder subscribes an entity that replies to every message with its text
+func echoResponder(t *testing.T, bus MessageBus, entityID string) {
+	t.Helper()
+	require.NoError(t, bus.Subscribe(entityID, func(msg Message) error {
+		text, _ := msg.TextContent()
+		return bus.Publish(NewTextReplyMessage(entityID, msg, "echo: "+text))
+	}))
+}
+
+func TestRequestReturnsReply(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	echoResponder(t, bus, "andy")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	request := NewTextMessage("alice", []string{"andy"}, "ping")
+	reply, err := bus.Request(ctx, request)
+	require.NoError(t, err)
+
+	assert.Equal(t, "echo: ping", string(reply.Content))
+	assert.Equal(t, request.ID, reply.ReplyToID)
+	assert.Equal(t, request.ID, reply.CorrelationID)
+	require.Len(t, reply.Recipients, 1)
+	assert.Contains(t, reply.Recipients[0], inboxPrefix, "reply should go to the request inbox")
+}
+
+func TestRequestKeepsCorrelationID(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	echoResponder(t, bus, "andy")
+
+	request := NewTextMessage("alice", []string{"andy"}, "ping")
+	request.CorrelationID = "conversation-42"
+	reply, err := Request(context.Background(), bus, request)
+	require.NoError(t, err)
+	assert.Equal(t, "conversation-42", reply.CorrelationID)
+}
+
+func TestRequestIgnoresUncorrelatedMessages(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	require.NoError(t, bus.Subscribe("andy", func(msg Message) error {
+		// A message to the inbox that does not answer the request, then the reply
+		bus.Publish(NewTextMessage("andy", []string{msg.ReplyTo}, "unrelated"))
+		return bus.Publish(NewTextReplyMessage("andy", msg, "answer"))
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	reply, err := bus.Request(ctx, NewTextMessage("alice", []string{"andy"}, "ping"))
+	require.NoError(t, err)
+	assert.Equal(t, "answer", string(reply.Content))
+}
+
+func TestRequestTimesOut(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	require.NoError(t, bus.Subscribe("andy", func(msg Message) error { return nil }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := bus.Request(ctx, NewTextMessage("alice", []string{"andy"}, "ping"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The reply inbox is removed again
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+	assert.Len(t, bus.subscriptions, 1)
+}
+
+func TestRequestStreamPassesPartialReplies(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetDeliveryMode(DeliveryOrdered)
+	require.NoError(t, bus.Subscribe("andy", func(msg Message) error {
+		if msg.Metadata[MetadataStream] == "true" {
+			for _, text := range []string{"ec", "echo"} {
+				partial := NewTextReplyMessage("andy", msg, text)
+				partial.Metadata[MetadataPartial] = "true"
+				bus.Publish(partial)
+			}
+		}
+		return bus.Publish(NewTextReplyMessage("andy", msg, "echo: ping"))
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var partials []string
+	reply, err := RequestStream(ctx, bus, NewTextMessage("alice", []string{"andy"}, "ping"), func(partial Message) {
+		partials = append(partials, string(partial.Content))
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "echo: ping", string(reply.Content))
+	assert.Equal(t, []string{"ec", "echo"}, partials)
+
+	// A plain request asks for no partial replies
+	reply, err = bus.Request(ctx, NewTextMessage("alice", []string{"andy"}, "ping"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo: ping", string(reply.Content))
+}
+
+func TestPersistentMessageBusRequest(t *testing.T) {
+	store, _ := knowledge.NewFileStore(filepath.Join(t.TempDir(), "journal.json"))
+	require.NoError(t, store.Open())
+	defer store.Close()
+	bus := NewPersistentMessageBus(NewMemoryMessageBus(), store)
+	echoResponder(t, bus, "andy")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	request := NewTextMessage("alice", []string{"andy"}, "ping")
+	_, err := bus.Request(ctx, request)
+	require.NoError(t, err)
+
+	journal, err := bus.Journal(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, journal, 2)
+	assert.Equal(t, request.ID, journal[0].CorrelationID)
+	assert.Contains(t, journal[0].ReplyTo, inboxPrefix)
+	assert.Empty(t, journal[0].Metadata, "routing fields should not leak into metadata")
+	assert.Equal(t, request.ID, journal[1].CorrelationID)
+}
+
+func TestNatsMessageBusRequest(t *testing.T) {
+	server := newFakeNats(t, "")
+	// The requester and the responder run in different processes
+	requester, err := NewNatsMessageBus(server.url())
+	require.NoError(t, err)
+	defer requester.Close()
+	responder, err := NewNatsMessageBus(server.url())
+	require.NoError(t, err)
+	defer responder.Close()
+	echoResponder(t, responder, "andy")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	reply, err := requester.Request(ctx, NewTextMessage("alice", []string{"andy"}, "ping"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo: ping", string(reply.Content))
+}
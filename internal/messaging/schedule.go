@@ -0,0 +1,177 @@
+package messaging
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+	"time"
+
+	"goproduct/internal/logging"
+	"goproduct/internal/tracing"
+)
+
+// errExpired ends the delivery of a message past its ExpiresAt
+var errExpired = errors.New("message expired")
+
+// WithTTL sets the message to expire the given duration from now
+func (m Message) WithTTL(ttl time.Duration) Message {
+	m.ExpiresAt = time.Now().Add(ttl)
+	return m
+}
+
+// WithDeliverAt holds the message back until the given time; the bus publishes it then
+func (m Message) WithDeliverAt(deliverAt time.Time) Message {
+	m.DeliverAt = deliverAt
+	return m
+}
+
+// Expired checks if the message has an expiry time that has passed
+func (m Message) Expired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && !now.Before(m.ExpiresAt)
+}
+
+// scheduled checks if the message is to be held back until later
+func (m Message) scheduled(now time.Time) bool {
+	return m.DeliverAt.After(now)
+}
+
+// traceExpired records that an expired message was dropped, before delivery to the
+// recipient or, without one, when it was published
+func traceExpired(tracer tracing.Tracer, msg Message, recipientID string) {
+	tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationExpire,
+		Level:     tracing.LevelWarning,
+		SourceID:  msg.SenderID,
+		TargetID:  recipientID,
+		ObjectID:  msg.ID,
+		Message:   "Expired message dropped",
+		Metadata:  map[string]interface{}{"expiresAt": msg.ExpiresAt},
+	})
+}
+
+// traceScheduled records that a message is held back until its DeliverAt
+func traceScheduled(tracer tracing.Tracer, msg Message) {
+	tracer.Trace(tracing.Event{
+		Timestamp: time.Now(),
+		Component: tracing.ComponentMessaging,
+		Operation: tracing.OperationSend,
+		Level:     tracing.LevelInfo,
+		SourceID:  msg.SenderID,
+		ObjectID:  msg.ID,
+		Message:   "Message scheduled",
+		Metadata:  map[string]interface{}{"deliverAt": msg.DeliverAt, "recipients": msg.Recipients},
+	})
+}
+
+// scheduler holds messages until their DeliverAt and then publishes them. Its goroutine
+// runs while messages are waiting. Scheduled messages live in memory only and are lost
+// when the process exits.
+type scheduler struct {
+	mu      sync.Mutex
+	pending scheduledMessages
+	seq     uint64
+	running bool
+	wake    chan struct{} // Signals a new message to the running goroutine
+	publish func(Message) error
+	logger  *logging.Logger
+}
+
+// newScheduler creates a scheduler publishing due messages with the function
+func newScheduler(publish func(Message) error) *scheduler {
+	return &scheduler{wake: make(chan struct{}, 1), publish: publish, logger: logging.Get().Component("messaging")}
+}
+
+// schedule holds the message until its DeliverAt
+func (s *scheduler) schedule(msg Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	heap.Push(&s.pending, scheduledMessage{msg: msg, seq: s.seq})
+	if !s.running {
+		s.running = true
+		go s.run()
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default: // The goroutine is already signaled
+	}
+}
+
+// count returns the number of messages waiting
+func (s *scheduler) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending.Len()
+}
+
+// clear drops the waiting messages
+func (s *scheduler) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = nil
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run publishes the waiting messages as they become due until none are left
+func (s *scheduler) run() {
+	for {
+		s.mu.Lock()
+		if s.pending.Len() == 0 {
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+		wait := time.Until(s.pending[0].msg.DeliverAt)
+		if wait > 0 {
+			s.mu.Unlock()
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-s.wake:
+				timer.Stop()
+			}
+			continue
+		}
+		due := heap.Pop(&s.pending).(scheduledMessage).msg
+		s.mu.Unlock()
+
+		if err := s.publish(due); err != nil {
+			s.logger.Error("Failed to publish scheduled message", "message_id", due.ID, "error", err)
+		}
+	}
+}
+
+// scheduledMessage is a message waiting in the scheduler
+type scheduledMessage struct {
+	msg Message
+	seq uint64 // Keeps messages due at the same time in scheduling order
+}
+
+// scheduledMessages orders waiting messages by DeliverAt (implements heap.Interface)
+type scheduledMessages []scheduledMessage
+
+func (s scheduledMessages) Len() int { return len(s) }
+
+func (s scheduledMessages) Less(i, j int) bool {
+	if !s[i].msg.DeliverAt.Equal(s[j].msg.DeliverAt) {
+		return s[i].msg.DeliverAt.Before(s[j].msg.DeliverAt)
+	}
+	return s[i].seq < s[j].seq
+}
+
+func (s scheduledMessages) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *scheduledMessages) Push(x interface{}) { *s = append(*s, x.(scheduledMessage)) }
+
+func (s *scheduledMessages) Pop() interface{} {
+	old := *s
+	last := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,123 @@
+package messaging
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiredMessageIsDropped(t *testing.T) {
+	var output bytes.Buffer
+	bus := NewMemoryMessageBusWithTracer(tracing.NewWriterTracer(&output, tracing.LevelInfo))
+	received := make(chan Message, 1)
+	bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil })
+
+	msg := NewTextMessage("alice", []string{"bob"}, "too late").WithTTL(-time.Second)
+	require.NoError(t, bus.Publish(msg))
+	expectNothing(t, received)
+	assert.Contains(t, output.String(), "Expired message dropped")
+}
+
+func TestMessageExpiresWhileQueued(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetDeliveryMode(DeliveryOrdered)
+	handler := newRecordingHandler()
+	require.NoError(t, bus.Subscribe("bob", handler.handle))
+
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "first")))
+	<-handler.started
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "stale").WithTTL(20*time.Millisecond)))
+	require.NoError(t, bus.Publish(NewTextMessage("alice", []string{"bob"}, "fresh")))
+	time.Sleep(40 * time.Millisecond)
+	close(handler.gate)
+
+	require.Eventually(t, func() bool { return len(handler.handled()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"first", "fresh"}, handler.handled())
+}
+
+func TestAcknowledgedDeliveryExpires(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	bus.SetRetryPolicy(RetryPolicy{MaxAttempts: 100, InitialBackoff: 10 * time.Millisecond, Multiplier: 1})
+
+	// Nobody subscribes, so the delivery retries until the message expires
+	msg := NewTextMessage("alice", []string{"bob"}, "hello").WithAck().WithTTL(50 * time.Millisecond)
+	require.NoError(t, bus.Publish(msg))
+
+	status := waitForDelivery(t, bus, msg.ID)
+	assert.Equal(t, DeliveryExpired, status.Recipients["bob"].State)
+	assert.Empty(t, bus.GetDeadLetters(), "expired messages are not dead letters")
+}
+
+func TestScheduledDelivery(t *testing.T) {
+	var output bytes.Buffer
+	bus := NewMemoryMessageBusWithTracer(tracing.NewWriterTracer(&output, tracing.LevelInfo))
+	received := make(chan Message, 3)
+	bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil })
+
+	start := time.Now()
+	later := NewTextMessage("alice", []string{"bob"}, "later").WithDeliverAt(start.Add(300 * time.Millisecond))
+	sooner := NewTextMessage("alice", []string{"bob"}, "sooner").WithDeliverAt(start.Add(200 * time.Millisecond))
+	require.NoError(t, bus.Publish(later))
+	require.NoError(t, bus.Publish(sooner))
+	assert.Equal(t, 2, bus.scheduler.count())
+	assert.Contains(t, output.String(), "Message scheduled")
+	expectNothing(t, received)
+
+	assert.Equal(t, "sooner", receiveText(t, received))
+	assert.Equal(t, "later", receiveText(t, received))
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	require.Eventually(t, func() bool { return bus.scheduler.count() == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestScheduledMessageExpiringFirstIsDropped(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	received := make(chan Message, 1)
+	bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil })
+
+	msg := NewTextMessage("alice", []string{"bob"}, "reminder").
+		WithDeliverAt(time.Now().Add(30 * time.Millisecond)).
+		WithTTL(10 * time.Millisecond)
+	require.NoError(t, bus.Publish(msg))
+	time.Sleep(50 * time.Millisecond)
+	expectNothing(t, received)
+}
+
+func TestPersistentMessageBusJournalsSchedule(t *testing.T) {
+	store, _ := knowledge.NewFileStore(filepath.Join(t.TempDir(), "journal.json"))
+	require.NoError(t, store.Open())
+	defer store.Close()
+	bus := NewPersistentMessageBus(NewMemoryMessageBus(), store)
+
+	deliverAt := time.Now().Add(time.Hour).Round(time.Millisecond)
+	msg := NewTextMessage("alice", []string{"bob"}, "reminder").WithDeliverAt(deliverAt)
+	msg.ExpiresAt = deliverAt.Add(time.Hour)
+	require.NoError(t, bus.Publish(msg))
+
+	journal, err := bus.Journal(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, journal, 1)
+	assert.True(t, deliverAt.Equal(journal[0].DeliverAt))
+	assert.True(t, msg.ExpiresAt.Equal(journal[0].ExpiresAt))
+	assert.Empty(t, journal[0].Metadata)
+}
+
+func TestNatsMessageBusScheduledDelivery(t *testing.T) {
+	server := newFakeNats(t, "")
+	bus, err := NewNatsMessageBus(server.url())
+	require.NoError(t, err)
+	defer bus.Close()
+
+	received := make(chan Message, 1)
+	require.NoError(t, bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil }))
+	msg := NewTextMessage("alice", []string{"bob"}, "later").WithDeliverAt(time.Now().Add(200 * time.Millisecond))
+	require.NoError(t, bus.Publish(msg))
+	expectNothing(t, received)
+	assert.Equal(t, "later", receiveText(t, received))
+}
@@ -0,0 +1,138 @@
+package messaging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TopicAddressPrefix starts the recipient addresses of topics, see TopicAddress
+const TopicAddressPrefix = "topic:"
+
+// TopicAddress returns the recipient address publishing to a topic. Topics are names of
+// dot separated tokens such as "product.backlog"; entities receive the messages of the
+// topics matching a pattern they subscribed with SubscribeTopic.
+func TopicAddress(topic string) string {
+	return TopicAddressPrefix + topic
+}
+
+// topicOf returns the topic of a topic address
+func topicOf(address string) (string, bool) {
+	if !strings.HasPrefix(address, TopicAddressPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(address, TopicAddressPrefix), true
+}
+
+// NewTopicMessage creates a message published to a topic, with the topic also in its
+// "topic" metadata for subscription filters
+func NewTopicMessage(senderID, topic string, contentType string, content []byte) Message {
+	msg := NewMessage(senderID, []string{TopicAddress(topic)}, contentType, content)
+	msg.Metadata[MetadataTopic] = topic
+	return msg
+}
+
+// MatchTopic checks if a topic matches a subscription pattern. In patterns "*" matches
+// exactly one token and a final ">" matches one or more tokens, so "product.*" matches
+// "product.backlog" and "product.>" also matches "product.backlog.groomed".
+func MatchTopic(pattern, topic string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	topicTokens := strings.Split(topic, ".")
+	for i, token := range patternTokens {
+		if token == ">" && i == len(patternTokens)-1 {
+			return len(topicTokens) > i
+		}
+		if i >= len(topicTokens) || (token != "*" && token != topicTokens[i]) {
+			return false
+		}
+	}
+	return len(topicTokens) == len(patternTokens)
+}
+
+// validateTopic rejects topics that cannot be published to
+func validateTopic(topic string) error {
+	if err := validateTopicPattern(topic); err != nil {
+		return err
+	}
+	if strings.ContainsAny(topic, "*>") {
+		return fmt.Errorf("invalid topic %q: wildcards are only allowed in subscriptions", topic)
+	}
+	return nil
+}
+
+// validateTopics rejects a message to a topic that cannot be published to, before it is
+// delivered to any of its recipients
+func validateTopics(recipients []string) error {
+	for _, recipientID := range recipients {
+		if topic, ok := topicOf(recipientID); ok {
+			if err := validateTopic(topic); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateTopicPattern rejects malformed subscription patterns
+func validateTopicPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("topic cannot be empty")
+	}
+	if strings.ContainsAny(pattern, " \t\r\n") {
+		return fmt.Errorf("invalid topic %q: must not contain whitespace", pattern)
+	}
+	tokens := strings.Split(pattern, ".")
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return fmt.Errorf("invalid topic %q: empty token", pattern)
+		case token == ">" && i != len(tokens)-1:
+			return fmt.Errorf("invalid topic %q: '>' must be the last token", pattern)
+		case token != "*" && token != ">" && strings.ContainsAny(token, "*>"):
+			return fmt.Errorf("invalid topic %q: wildcards must be whole tokens", pattern)
+		}
+	}
+	return nil
+}
+
+// topicSubscriptions maps subscription patterns to the entities using them
+// (not safe for concurrent use; guarded by the lock of the bus)
+type topicSubscriptions map[string]map[string]bool
+
+// add subscribes an entity to a pattern
+func (t topicSubscriptions) add(entityID, pattern string) {
+	if t[pattern] == nil {
+		t[pattern] = make(map[string]bool)
+	}
+	t[pattern][entityID] = true
+}
+
+// remove unsubscribes an entity from a pattern and reports whether it was subscribed
+func (t topicSubscriptions) remove(entityID, pattern string) bool {
+	if !t[pattern][entityID] {
+		return false
+	}
+	delete(t[pattern], entityID)
+	if len(t[pattern]) == 0 {
+		delete(t, pattern)
+	}
+	return true
+}
+
+// subscribers returns the entities with a pattern matching the topic, each once
+func (t topicSubscriptions) subscribers(topic string) []string {
+	matched := make(map[string]bool)
+	for pattern, entities := range t {
+		if MatchTopic(pattern, topic) {
+			for entityID := range entities {
+				matched[entityID] = true
+			}
+		}
+	}
+	subscribers := make([]string, 0, len(matched))
+	for entityID := range matched {
+		subscribers = append(subscribers, entityID)
+	}
+	sort.Strings(subscribers)
+	return subscribers
+}
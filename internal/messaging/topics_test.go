@@ -0,0 +1,158 @@
+package messaging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"product.backlog", "product.backlog", true},
+		{"product.backlog", "product.roadmap", false},
+		{"product.*", "product.backlog", true},
+		{"product.*", "product.backlog.groomed", false},
+		{"product.*", "product", false},
+		{"*.backlog", "product.backlog", true},
+		{"product.>", "product.backlog", true},
+		{"product.>", "product.backlog.groomed", true},
+		{"product.>", "product", false},
+		{">", "product.backlog", true},
+		{"product", "product.backlog", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MatchTopic(tt.pattern, tt.topic), "%s against %s", tt.pattern, tt.topic)
+	}
+}
+
+func TestValidateTopicPattern(t *testing.T) {
+	for _, valid := range []string{"product", "product.backlog", "product.*", "*.backlog", "product.>"} {
+		assert.NoError(t, validateTopicPattern(valid), valid)
+	}
+	for _, invalid := range []string{"", "product..backlog", "product.>.groomed", "product.back*", "product backlog"} {
+		assert.Error(t, validateTopicPattern(invalid), invalid)
+	}
+	assert.Error(t, validateTopic("product.*"), "wildcards cannot be published to")
+}
+
+func TestTopicPublishSubscribe(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	backlog := make(chan Message, 10)
+	everything := make(chan Message, 10)
+	bystander := make(chan Message, 10)
+	bus.Subscribe("bob", func(msg Message) error { backlog <- msg; return nil })
+	bus.Subscribe("carol", func(msg Message) error { everything <- msg; return nil })
+	bus.Subscribe("dave", func(msg Message) error { bystander <- msg; return nil })
+	require.NoError(t, bus.SubscribeTopic("bob", "product.backlog"))
+	require.NoError(t, bus.SubscribeTopic("carol", "product.>"))
+	// Overlapping patterns still deliver a message once
+	require.NoError(t, bus.SubscribeTopic("carol", "product.*"))
+
+	msg := NewTopicMessage("alice", "product.backlog", ContentTypeText, []byte("groomed"))
+	require.NoError(t, bus.Publish(msg))
+	assert.Equal(t, "groomed", receiveText(t, backlog))
+	assert.Equal(t, "groomed", receiveText(t, everything))
+	expectNothing(t, everything)
+	expectNothing(t, bystander)
+
+	require.NoError(t, bus.Publish(NewTopicMessage("alice", "product.roadmap.q3", ContentTypeText, []byte("drafted"))))
+	assert.Equal(t, "drafted", receiveText(t, everything))
+	expectNothing(t, backlog)
+}
+
+func TestTopicSenderDoesNotReceiveOwnMessage(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	received := make(chan Message, 1)
+	bus.Subscribe("alice", func(msg Message) error { received <- msg; return nil })
+	require.NoError(t, bus.SubscribeTopic("alice", "product.>"))
+
+	require.NoError(t, bus.Publish(NewTopicMessage("alice", "product.backlog", ContentTypeText, []byte("mine"))))
+	expectNothing(t, received)
+}
+
+func TestUnsubscribeTopic(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	received := make(chan Message, 1)
+	bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil })
+	require.NoError(t, bus.SubscribeTopic("bob", "product.*"))
+	require.NoError(t, bus.UnsubscribeTopic("bob", "product.*"))
+	assert.Error(t, bus.UnsubscribeTopic("bob", "product.*"))
+
+	require.NoError(t, bus.Publish(NewTopicMessage("alice", "product.backlog", ContentTypeText, []byte("hello"))))
+	expectNothing(t, received)
+}
+
+func TestTopicPublishRejectsWildcards(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	err := bus.Publish(NewTextMessage("alice", []string{TopicAddress("product.*")}, "hello"))
+	assert.Error(t, err)
+	assert.Error(t, bus.SubscribeTopic("bob", "product..backlog"))
+}
+
+func TestTopicPublishRejectsInvalidTopicBeforeDelivering(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	received := make(chan Message, 2)
+	bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil })
+	require.NoError(t, bus.SubscribeTopic("bob", "product.>"))
+
+	// The invalid topic comes after recipients the message would otherwise reach
+	msg := NewTextMessage("alice", []string{"bob", TopicAddress("product.backlog"), TopicAddress("product..roadmap")}, "hello")
+	assert.Error(t, bus.Publish(msg))
+	expectNothing(t, received)
+}
+
+func TestTopicMessageMatchesTopicFilter(t *testing.T) {
+	bus := NewMemoryMessageBus()
+	received := make(chan Message, 10)
+	bus.Subscribe("bob", func(msg Message) error { received <- msg; return nil }, SubscriptionFilter{Topics: []string{"product.backlog"}})
+	require.NoError(t, bus.SubscribeTopic("bob", "product.*"))
+
+	require.NoError(t, bus.Publish(NewTopicMessage("alice", "product.roadmap", ContentTypeText, []byte("skipped"))))
+	require.NoError(t, bus.Publish(NewTopicMessage("alice", "product.backlog", ContentTypeText, []byte("kept"))))
+	assert.Equal(t, "kept", receiveText(t, received))
+	expectNothing(t, received)
+}
+
+func TestNatsMessageBusTopics(t *testing.T) {
+	server := newFakeNats(t, "")
+	publisher, err := NewNatsMessageBus(server.url())
+	require.NoError(t, err)
+	defer publisher.Close()
+	subscriber, err := NewNatsMessageBus(server.url())
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	received := make(chan Message, 10)
+	require.NoError(t, subscriber.Subscribe("bob", func(msg Message) error { received <- msg; return nil }))
+	require.NoError(t, subscriber.SubscribeTopic("bob", "product.*"))
+
+	msg := NewTopicMessage("alice", "product.backlog", ContentTypeText, []byte("groomed"))
+	msg.Recipients = append(msg.Recipients, TopicAddress("sales.leads"))
+	require.NoError(t, publisher.Publish(msg))
+	assert.Equal(t, "groomed", receiveText(t, received))
+	expectNothing(t, received)
+}
+
+func TestRedisMessageBusTopics(t *testing.T) {
+	server := newFakeRedis(t, "")
+	publisher, err := NewRedisMessageBus(server.url())
+	require.NoError(t, err)
+	defer publisher.Close()
+	subscriber, err := NewRedisMessageBus(server.url())
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	received := make(chan Message, 10)
+	require.NoError(t, subscriber.Subscribe("bob", func(msg Message) error { received <- msg; return nil }))
+	require.NoError(t, subscriber.SubscribeTopic("bob", "product.>"))
+
+	require.NoError(t, publisher.Publish(NewTopicMessage("alice", "product.backlog.groomed", ContentTypeText, []byte("done"))))
+	assert.Equal(t, "done", receiveText(t, received))
+
+	require.NoError(t, publisher.Publish(NewTopicMessage("alice", "sales.leads", ContentTypeText, []byte("ignored"))))
+	expectNothing(t, received)
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the content type of the Prometheus text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteTo writes the metrics in the Prometheus text format, sorted by name
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mu.Unlock()
+
+	var sb strings.Builder
+	for _, m := range metrics {
+		m.write(&sb)
+	}
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// Handler serves the metrics of the registry to Prometheus
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+// writeHeader writes the help and type lines of a metric
+func (s *series) writeHeader(sb *strings.Builder, kind string) {
+	sb.WriteString("# HELP " + s.name + " " + escapeHelp(s.help) + "\n")
+	sb.WriteString("# TYPE " + s.name + " " + kind + "\n")
+}
+
+func (c *Counter) write(sb *strings.Builder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(sb, kindCounter)
+	for _, key := range c.sortedKeys() {
+		writeSample(sb, c.name, c.labels, c.keys[key], c.values[key].(float64))
+	}
+}
+
+func (h *Histogram) write(sb *strings.Builder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(sb, kindHistogram)
+	labels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range h.sortedKeys() {
+		v := h.values[key].(*histogramValue)
+		values := h.keys[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += v.counts[i]
+			writeSample(sb, h.name+"_bucket", labels, append(append([]string(nil), values...), formatFloat(bound)), float64(cumulative))
+		}
+		writeSample(sb, h.name+"_bucket", labels, append(append([]string(nil), values...), "+Inf"), float64(v.count))
+		writeSample(sb, h.name+"_sum", h.labels, values, v.sum)
+		writeSample(sb, h.name+"_count", h.labels, values, float64(v.count))
+	}
+}
+
+// writeSample writes a sample line
+func writeSample(sb *strings.Builder, name string, labels, values []string, value float64) {
+	sb.WriteString(name)
+	if len(labels) > 0 {
+		sb.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(label + `="` + escapeLabel(values[i]) + `"`)
+		}
+		sb.WriteByte('}')
+	}
+	sb.WriteString(" " + formatFloat(value) + "\n")
+}
+
+// formatFloat formats a value as the exposition format expects
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string   { return helpEscaper.Replace(help) }
+func escapeLabel(value string) string { return labelEscaper.Replace(value) }
@@ -0,0 +1,206 @@
+// Package metrics counts what the application does, messages published and delivered,
+// store operations, language model calls and their tokens, and exposes the counts in the
+// Prometheus text format. Packages record into the default registry, which the HTTP
+// server serves on /metrics.
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of histograms of fast operations such
+// as store reads and writes
+var DefaultBuckets = []float64{0.0005, 0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// SlowBuckets are the upper bounds, in seconds, of histograms of slow operations such as
+// language model calls
+var SlowBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120}
+
+// Metric kinds, as named in the exposition format
+const (
+	kindCounter   = "counter"
+	kindHistogram = "histogram"
+)
+
+// metric is a counter or histogram of a registry
+type metric interface {
+	kind() string
+	write(sb *strings.Builder)
+}
+
+// Registry holds metrics by name. The zero value is not usable, use NewRegistry.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the registry the application's packages record into
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Counter returns the counter with the name, creating it with the help text and label
+// names on first use. It panics if the name is taken by a metric of another kind.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return r.register(name, kindCounter, func() metric {
+		return &Counter{series: newSeries(name, help, labels)}
+	}).(*Counter)
+}
+
+// Histogram returns the histogram with the name, creating it with the help text, bucket
+// upper bounds and label names on first use. It panics if the name is taken by a metric
+// of another kind.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return r.register(name, kindHistogram, func() metric {
+		bounds := append([]float64(nil), buckets...)
+		sort.Float64s(bounds)
+		return &Histogram{series: newSeries(name, help, labels), buckets: bounds}
+	}).(*Histogram)
+}
+
+// register returns the metric with the name, created if new
+func (r *Registry) register(name, kind string, create func() metric) metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.metrics[name]; ok {
+		if existing.kind() != kind {
+			panic("metrics: " + name + " is already registered as a " + existing.kind())
+		}
+		return existing
+	}
+	m := create()
+	r.metrics[name] = m
+	return m
+}
+
+// series holds the values of a metric by label values
+type series struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]interface{} // By labelKey of the label values
+	keys   map[string][]string    // Label values by labelKey
+}
+
+func newSeries(name, help string, labels []string) series {
+	return series{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]interface{}),
+		keys:   make(map[string][]string),
+	}
+}
+
+// labelKey returns the key of label values, which are padded or cut to the label names
+func (s *series) labelKey(values []string) (string, []string) {
+	fitted := make([]string, len(s.labels))
+	copy(fitted, values)
+	return strings.Join(fitted, "\xff"), fitted
+}
+
+// sortedKeys returns the label keys in order, with the lock held
+func (s *series) sortedKeys() []string {
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a value that only goes up, one per combination of label values
+type Counter struct {
+	series
+}
+
+func (c *Counter) kind() string { return kindCounter }
+
+// Inc adds one to the counter of the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds a value to the counter of the label values; negative values are ignored
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 || math.IsNaN(value) {
+		return
+	}
+	key, fitted := c.labelKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current, _ := c.values[key].(float64)
+	c.values[key] = current + value
+	c.keys[key] = fitted
+}
+
+// Value returns the counter of the label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	key, _ := c.labelKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, _ := c.values[key].(float64)
+	return value
+}
+
+// histogramValue is the distribution of one combination of label values
+type histogramValue struct {
+	counts []uint64 // Observations per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// Histogram counts observations in buckets, one distribution per combination of label
+// values
+type Histogram struct {
+	series
+	buckets []float64
+}
+
+func (h *Histogram) kind() string { return kindHistogram }
+
+// Observe records a value in the distribution of the label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key, fitted := h.labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v, ok := h.values[key].(*histogramValue)
+	if !ok {
+		v = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+		h.keys[key] = fitted
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		v.counts[i]++
+	}
+	v.sum += value
+	v.count++
+}
+
+// ObserveSince records the seconds elapsed since start
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Count returns the number of observations of the label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key, _ := h.labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if v, ok := h.values[key].(*histogramValue); ok {
+		return v.count
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	registry := NewRegistry()
+	published := registry.Counter("bus_messages_published_total", "Messages published")
+	delivered := registry.Counter("bus_messages_delivered_total", "Messages delivered", "via")
+	latency := registry.Histogram("store_operation_seconds", "Store latency", []float64{0.1, 1}, "operation")
+
+	published.Inc()
+	published.Add(2)
+	published.Add(-5) // Counters only go up
+	delivered.Inc("topic")
+	delivered.Inc(`say "hi"`)
+	latency.Observe(0.05, "get")
+	latency.Observe(0.5, "get")
+	latency.Observe(3, "get")
+
+	if registry.Counter("bus_messages_published_total", "Messages published") != published {
+		t.Error("Expected the registered counter to be returned again")
+	}
+	if published.Value() != 3 || delivered.Value("topic") != 1 || latency.Count("get") != 3 {
+		t.Errorf("Unexpected values: %v %v %v", published.Value(), delivered.Value("topic"), latency.Count("get"))
+	}
+
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if recorder.Header().Get("Content-Type") != ContentType {
+		t.Errorf("Unexpected content type %q", recorder.Header().Get("Content-Type"))
+	}
+	want := `# HELP bus_messages_delivered_total Messages delivered
+# TYPE bus_messages_delivered_total counter
+bus_messages_delivered_total{via="say \"hi\""} 1
+bus_messages_delivered_total{via="topic"} 1
+# HELP bus_messages_published_total Messages published
+# TYPE bus_messages_published_total counter
+bus_messages_published_total 3
+# HELP store_operation_seconds Store latency
+# TYPE store_operation_seconds histogram
+store_operation_seconds_bucket{operation="get",le="0.1"} 1
+store_operation_seconds_bucket{operation="get",le="1"} 2
+store_operation_seconds_bucket{operation="get",le="+Inf"} 3
+store_operation_seconds_sum{operation="get"} 3.55
+store_operation_seconds_count{operation="get"} 3
+`
+	if got := recorder.Body.String(); got != want {
+		t.Errorf("Unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestKindConflict(t *testing.T) {
+	registry := NewRegistry()
+	registry.Counter("calls", "Calls")
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "already registered") {
+			t.Errorf("Expected a panic for a name taken by another kind, got %v", r)
+		}
+	}()
+	registry.Histogram("calls", "Calls", DefaultBuckets)
+}
@@ -0,0 +1,76 @@
+// Package objectstore abstracts the object storage services that stores can keep their
+// data in: S3 and S3-compatible services such as MinIO or Google Cloud Storage's
+// interoperability API, a local directory, or memory.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Storage is a flat namespace of objects addressed by key. Keys use "/" to form
+// prefixes, as in S3.
+type Storage interface {
+	Get(ctx context.Context, key string) ([]byte, error)       // Read an object, ErrNotFound if missing
+	Put(ctx context.Context, key string, data []byte) error    // Create or replace an object
+	Delete(ctx context.Context, key string) error              // Remove an object; missing objects are not an error
+	List(ctx context.Context, prefix string) ([]string, error) // Keys starting with prefix in lexical order
+}
+
+// Memory keeps objects in memory, for tests and ephemeral deployments
+type Memory struct {
+	objects map[string][]byte
+	mu      sync.RWMutex
+}
+
+// NewMemory creates an empty in-memory object storage
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string][]byte)}
+}
+
+// Get returns a copy of the object
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Put stores a copy of the data
+func (m *Memory) Put(ctx context.Context, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+// Delete removes the object
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+// List returns the keys starting with prefix
+func (m *Memory) List(ctx context.Context, prefix string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0)
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,141 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testStorage(t *testing.T, storage Storage) {
+	ctx := context.Background()
+
+	if _, err := storage.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	for _, key := range []string{"a/1.json", "a/2.json", "b/1.json"} {
+		if err := storage.Put(ctx, key, []byte("data "+key)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if data, err := storage.Get(ctx, "a/2.json"); err != nil || string(data) != "data a/2.json" {
+		t.Errorf("Unexpected object: %q, %v", data, err)
+	}
+
+	keys, err := storage.List(ctx, "a/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"a/1.json", "a/2.json"}) {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+
+	if err := storage.Delete(ctx, "a/1.json"); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if err := storage.Delete(ctx, "a/1.json"); err != nil {
+		t.Errorf("Expected deleting a missing object to succeed, got %v", err)
+	}
+	if keys, _ := storage.List(ctx, ""); len(keys) != 2 {
+		t.Errorf("Expected 2 objects left, got %v", keys)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStorage(t, NewMemory())
+}
+
+func TestDir(t *testing.T) {
+	dir, err := NewDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDir failed: %v", err)
+	}
+	testStorage(t, dir)
+
+	if err := dir.Put(context.Background(), "../escape", []byte("x")); err == nil {
+		t.Error("Expected error for a key outside the directory")
+	}
+}
+
+// fakeS3 serves the subset of the S3 API used by the client
+type fakeS3 struct {
+	objects map[string][]byte
+	mu      sync.Mutex
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AK/") || r.Header.Get("x-amz-date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch {
+	case r.URL.Path == "/bucket" && r.Method == "GET":
+		keys := make([]string, 0)
+		for key := range f.objects {
+			if strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		// Page one key at a time to exercise continuation
+		start := 0
+		if token := r.URL.Query().Get("continuation-token"); token != "" {
+			fmt.Sscanf(token, "%d", &start)
+		}
+		fmt.Fprint(w, "<ListBucketResult>")
+		if start < len(keys) {
+			fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", keys[start])
+		}
+		if start+1 < len(keys) {
+			fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%d</NextContinuationToken>", start+1)
+		}
+		fmt.Fprint(w, "</ListBucketResult>")
+	case r.Method == "PUT":
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == "GET":
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+		w.Write(data)
+	case r.Method == "DELETE":
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: make(map[string][]byte)})
+	defer server.Close()
+
+	s3, err := NewS3(S3Config{Endpoint: server.URL, Bucket: "bucket", AccessKeyID: "AK", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatalf("NewS3 failed: %v", err)
+	}
+	testStorage(t, s3)
+
+	unsigned, _ := NewS3(S3Config{Endpoint: server.URL, Bucket: "bucket", AccessKeyID: "other", SecretAccessKey: "secret"})
+	if err := unsigned.Put(context.Background(), "x", []byte("x")); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Expected a 403 error, got %v", err)
+	}
+}
+
+func TestURIEncode(t *testing.T) {
+	if got := encodePath("/bucket/a b/ü+c.json"); got != "/bucket/a%20b/%C3%BC%2Bc.json" {
+		t.Errorf("Unexpected encoded path: %s", got)
+	}
+}
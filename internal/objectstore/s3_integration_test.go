@@ -0,0 +1,61 @@
+//go:build integration
+
+package objectstore_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"goproduct/internal/integration"
+	"goproduct/internal/objectstore"
+)
+
+func TestS3Integration(t *testing.T) {
+	storage := integration.S3(t)
+	prefix := integration.Prefix(t, storage)
+	ctx := context.Background()
+
+	if _, err := storage.Get(ctx, prefix+"missing"); !errors.Is(err, objectstore.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	// Keys that need encoding must round-trip through the signature
+	keys := []string{prefix + "a/1.json", prefix + "a/with space+plus.json", prefix + "b/ü.json"}
+	for _, key := range keys {
+		if err := storage.Put(ctx, key, []byte("data "+key)); err != nil {
+			t.Fatalf("Put %s failed: %v", key, err)
+		}
+		if data, err := storage.Get(ctx, key); err != nil || string(data) != "data "+key {
+			t.Errorf("Unexpected object %s: %q, %v", key, data, err)
+		}
+	}
+
+	listed, err := storage.List(ctx, prefix+"a/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if !reflect.DeepEqual(listed, keys[:2]) {
+		t.Errorf("Unexpected keys: %v", listed)
+	}
+
+	if err := storage.Delete(ctx, keys[0]); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if err := storage.Delete(ctx, keys[0]); err != nil {
+		t.Errorf("Expected deleting a missing object to succeed, got %v", err)
+	}
+	if listed, _ := storage.List(ctx, prefix); len(listed) != 2 {
+		t.Errorf("Expected 2 objects left, got %v", listed)
+	}
+}
+
+func TestS3IntegrationBadCredentials(t *testing.T) {
+	integration.S3(t)
+	config := integration.S3Config()
+	config.SecretAccessKey = "wrong"
+	storage, _ := objectstore.NewS3(config)
+	if err := storage.Put(context.Background(), "test/denied", []byte("x")); err == nil {
+		t.Error("Expected a request with a bad signature to be rejected")
+	}
+}
@@ -0,0 +1,140 @@
+// Package presence tracks which entities are reachable. Entities publish heartbeats to
+// the presence topic of the message bus with a Heartbeat; a Registry listening on the
+// topic knows which are online, busy or offline, so that the chat can tell the user an
+// agent is unreachable before they wait for a reply that will not come.
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"goproduct/internal/entity"
+	"goproduct/internal/logging"
+	"goproduct/internal/messaging"
+)
+
+// Topic is the message bus topic heartbeats are published to
+const Topic = "presence"
+
+// DefaultInterval is how often heartbeats are published unless set otherwise
+const DefaultInterval = 10 * time.Second
+
+// missedBeats is how many heartbeats an entity may miss before it is considered offline
+const missedBeats = 3
+
+// Status is the reachability of an entity
+type Status string
+
+const (
+	StatusUnknown Status = "unknown" // No heartbeat was received, e.g. the entity does not publish any
+	StatusOnline  Status = "online"  // Ready to answer
+	StatusBusy    Status = "busy"    // Reachable, but answering other messages first
+	StatusOffline Status = "offline" // Stopped, or its heartbeats stopped arriving
+)
+
+// beat is the content of a heartbeat message
+type beat struct {
+	EntityID string        `json:"entity_id"`
+	Name     string        `json:"name"`
+	Status   Status        `json:"status"`
+	Interval time.Duration `json:"interval"` // Until the next heartbeat
+}
+
+// statusOf returns the presence status of an entity's status
+func statusOf(status entity.EntityStatus) Status {
+	switch status {
+	case entity.StatusBusy:
+		return StatusBusy
+	case entity.StatusInactive:
+		return StatusOffline
+	}
+	return StatusOnline
+}
+
+// Heartbeat publishes the status of an entity at regular intervals, and that it is
+// offline when stopped. The zero value is not usable, use NewHeartbeat.
+type Heartbeat struct {
+	bus      messaging.MessageBus
+	entity   entity.Entity
+	interval time.Duration
+	logger   *logging.Logger
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// HeartbeatOption is a function that configures a Heartbeat
+type HeartbeatOption func(*Heartbeat)
+
+// WithInterval sets how often heartbeats are published, DefaultInterval by default
+func WithInterval(interval time.Duration) HeartbeatOption {
+	return func(h *Heartbeat) {
+		if interval > 0 {
+			h.interval = interval
+		}
+	}
+}
+
+// NewHeartbeat creates the heartbeat of an entity
+func NewHeartbeat(bus messaging.MessageBus, e entity.Entity, opts ...HeartbeatOption) *Heartbeat {
+	h := &Heartbeat{
+		bus:      bus,
+		entity:   e,
+		interval: DefaultInterval,
+		logger:   logging.Get().Component("presence"),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Start publishes heartbeats, the first at once, until the context is cancelled or Stop
+// is called
+func (h *Heartbeat) Start(ctx context.Context) {
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+
+		h.publish(statusOf(h.entity.Status()))
+		for {
+			select {
+			case <-ticker.C:
+				h.publish(statusOf(h.entity.Status()))
+			case <-ctx.Done():
+				h.publish(StatusOffline)
+				return
+			case <-h.stop:
+				h.publish(StatusOffline)
+				return
+			}
+		}
+	}()
+}
+
+// Stop publishes that the entity is offline and stops the heartbeats
+func (h *Heartbeat) Stop() {
+	h.once.Do(func() { close(h.stop) })
+	<-h.done
+}
+
+// publish sends a heartbeat with the status
+func (h *Heartbeat) publish(status Status) {
+	content, err := json.Marshal(beat{EntityID: h.entity.ID(), Name: h.entity.Name(), Status: status, Interval: h.interval})
+	if err != nil {
+		h.logger.Error("Failed to encode heartbeat", "entity_id", h.entity.ID(), "error", err)
+		return
+	}
+	msg := messaging.NewTopicMessage(h.entity.ID(), Topic, messaging.ContentTypeJSON, content).Ephemeral()
+	if err := h.bus.Publish(msg.WithTTL(h.interval)); err != nil {
+		h.logger.Warn("Failed to publish heartbeat", "entity_id", h.entity.ID(), "error", err)
+	}
+}
@@ -0,0 +1,78 @@
+package presence
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"goproduct/internal/entity"
+	"goproduct/internal/messaging"
+)
+
+// waitFor waits until the entity has the status in the registry
+func waitFor(t *testing.T, r *Registry, entityID string, want Status) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for r.Get(entityID).Status != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s, got %+v", want, r.Get(entityID))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPresence(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus(messaging.WithHistory(10))
+	registry := NewRegistry(bus)
+	if err := registry.Start(); err != nil {
+		t.Fatalf("Failed to start registry: %v", err)
+	}
+	defer registry.Stop()
+
+	andy := entity.NewCliHumanEntity("Andy", bus)
+	if got := registry.Get(andy.ID()).Status; got != StatusUnknown {
+		t.Errorf("Expected an entity without heartbeats to be unknown, got %s", got)
+	}
+
+	heartbeat := NewHeartbeat(bus, andy, WithInterval(10*time.Millisecond))
+	heartbeat.Start(context.Background())
+	waitFor(t, registry, andy.ID(), StatusOnline)
+	andy.SetStatus(entity.StatusBusy)
+	waitFor(t, registry, andy.ID(), StatusBusy)
+
+	entries := registry.Entries()
+	if len(entries) != 1 || entries[0].Name != "Andy" || entries[0].LastSeen.IsZero() {
+		t.Errorf("Expected Andy listed, got %+v", entries)
+	}
+	if history, _ := bus.GetHistory(andy.ID(), time.Time{}, 0); len(history) != 0 {
+		t.Errorf("Expected heartbeats kept out of the history, got %d messages", len(history))
+	}
+
+	heartbeat.Stop()
+	waitFor(t, registry, andy.ID(), StatusOffline)
+}
+
+func TestMissedHeartbeats(t *testing.T) {
+	bus := messaging.NewMemoryMessageBus()
+	registry := NewRegistry(bus)
+	registry.Start()
+	defer registry.Stop()
+	now := time.Now()
+	registry.now = func() time.Time { return now }
+
+	andy := entity.NewCliHumanEntity("Andy", bus)
+	heartbeat := NewHeartbeat(bus, andy, WithInterval(time.Minute))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	heartbeat.Start(ctx)
+	waitFor(t, registry, andy.ID(), StatusOnline)
+
+	now = now.Add(2 * time.Minute)
+	if got := registry.Get(andy.ID()).Status; got != StatusOnline {
+		t.Errorf("Expected a missed heartbeat to be tolerated, got %s", got)
+	}
+	now = now.Add(2 * time.Minute)
+	if got := registry.Get(andy.ID()).Status; got != StatusOffline {
+		t.Errorf("Expected the entity offline after missing heartbeats, got %s", got)
+	}
+}
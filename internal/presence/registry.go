@@ -0,0 +1,122 @@
+package presence
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"goproduct/internal/logging"
+	"goproduct/internal/messaging"
+)
+
+// Entry is the presence of an entity as last heard
+type Entry struct {
+	EntityID string
+	Name     string
+	Status   Status
+	LastSeen time.Time // When its last heartbeat arrived; zero if none did
+}
+
+// Registry tracks the presence of the entities publishing heartbeats. The zero value is
+// not usable, use NewRegistry.
+type Registry struct {
+	id        string // Subscribes to the presence topic
+	bus       messaging.MessageBus
+	logger    *logging.Logger
+	now       func() time.Time
+	mutex     sync.RWMutex
+	entries   map[string]Entry         // By entity ID
+	intervals map[string]time.Duration // Announced heartbeat interval by entity ID
+}
+
+// NewRegistry creates a registry of the entities publishing heartbeats on the bus
+func NewRegistry(bus messaging.MessageBus) *Registry {
+	return &Registry{
+		id:        "presence-registry-" + uuid.New().String(),
+		bus:       bus,
+		logger:    logging.Get().Component("presence"),
+		now:       time.Now,
+		entries:   make(map[string]Entry),
+		intervals: make(map[string]time.Duration),
+	}
+}
+
+// Start listens to the heartbeats
+func (r *Registry) Start() error {
+	if err := r.bus.Subscribe(r.id, r.handle); err != nil {
+		return fmt.Errorf("failed to subscribe presence registry: %w", err)
+	}
+	if err := r.bus.SubscribeTopic(r.id, Topic); err != nil {
+		return fmt.Errorf("failed to subscribe presence registry: %w", err)
+	}
+	return nil
+}
+
+// Stop stops listening to the heartbeats
+func (r *Registry) Stop() error {
+	return r.bus.Unsubscribe(r.id)
+}
+
+// handle records a heartbeat
+func (r *Registry) handle(msg messaging.Message) error {
+	var b beat
+	if err := json.Unmarshal(msg.Content, &b); err != nil || b.EntityID == "" {
+		r.logger.Warn("Ignoring malformed heartbeat", "message_id", msg.ID, "sender", msg.SenderID)
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if previous := r.entries[b.EntityID]; previous.Status != b.Status {
+		r.logger.Info("Entity presence changed", "entity_id", b.EntityID, "name", b.Name, "from", previous.Status, "to", b.Status)
+	}
+	r.entries[b.EntityID] = Entry{EntityID: b.EntityID, Name: b.Name, Status: b.Status, LastSeen: r.now()}
+	r.intervals[b.EntityID] = b.Interval
+	return nil
+}
+
+// Get returns the presence of an entity: StatusUnknown if no heartbeat of it arrived, and
+// StatusOffline if it missed several in a row
+func (r *Registry) Get(entityID string) Entry {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	entry, found := r.entries[entityID]
+	if !found {
+		return Entry{EntityID: entityID, Status: StatusUnknown}
+	}
+	return r.current(entry)
+}
+
+// Entries returns the presence of every entity heard of, by name
+func (r *Registry) Entries() []Entry {
+	r.mutex.RLock()
+	entries := make([]Entry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		entries = append(entries, r.current(entry))
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].EntityID < entries[j].EntityID
+	})
+	return entries
+}
+
+// current returns the entry, offline if its heartbeats stopped; the mutex must be held
+func (r *Registry) current(entry Entry) Entry {
+	interval := r.intervals[entry.EntityID]
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if r.now().Sub(entry.LastSeen) > missedBeats*interval {
+		entry.Status = StatusOffline
+	}
+	return entry
+}
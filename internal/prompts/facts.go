@@ -0,0 +1,52 @@
+package prompts
+
+import (
+	"text/template"
+
+	"goproduct/internal/knowledge"
+)
+
+// DefaultFactLimit is how many facts the facts function returns without a limit
+const DefaultFactLimit = 10
+
+// KnowledgeFuncs returns template functions interpolating facts from a knowledge store:
+//
+//	{{fact "stack-backend"}}            the content of the record with the ID, "" if there is none
+//	{{range facts "backend"}}- {{.}}{{end}}  the contents of the fact records with the tag
+//
+// facts returns the most important active fact records first, DefaultFactLimit of them
+// unless a limit follows the tag; an empty tag matches every fact.
+func KnowledgeFuncs(store knowledge.Store) template.FuncMap {
+	return template.FuncMap{
+		"fact": func(id string) string {
+			record, err := store.GetRecord(id)
+			if err != nil {
+				return ""
+			}
+			return string(record.Content)
+		},
+		"facts": func(tag string, limit ...int) ([]string, error) {
+			n := DefaultFactLimit
+			if len(limit) > 0 && limit[0] > 0 {
+				n = limit[0]
+			}
+			query := knowledge.Query().Where("Category", "=", knowledge.CategoryFact)
+			if tag != "" {
+				query = query.And("Tags", "CONTAINS", tag)
+			}
+			filter, err := query.OrderBy("Importance").Desc().Limit(n).Build()
+			if err != nil {
+				return nil, err
+			}
+			records, err := store.SearchRecords(filter)
+			if err != nil {
+				return nil, err
+			}
+			contents := make([]string, len(records))
+			for i, record := range records {
+				contents[i] = string(record.Content)
+			}
+			return contents, nil
+		},
+	}
+}
@@ -0,0 +1,238 @@
+// Package prompts renders prompts from named text/template templates. Templates include
+// each other as partials with {{template "name" .}}, and a persona can override any of
+// them, so a persona's system prompt can be assembled from shared sections and
+// interpolate facts known at runtime.
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Extension is the file extension of the templates Load reads
+const Extension = ".tmpl"
+
+// ErrNotFound is returned when rendering a template the library does not have
+var ErrNotFound = errors.New("prompt template not found")
+
+// Library is a set of named prompt templates with per-persona overrides. It is safe for
+// concurrent use.
+type Library struct {
+	mu        sync.RWMutex
+	templates map[string]string            // Sources by name
+	overrides map[string]map[string]string // Sources by lower-cased persona name, then template name
+	funcs     template.FuncMap
+}
+
+// Option is a function that configures a Library
+type Option func(*Library)
+
+// New creates an empty library
+func New(options ...Option) *Library {
+	l := &Library{
+		templates: make(map[string]string),
+		overrides: make(map[string]map[string]string),
+		funcs:     defaultFuncs(),
+	}
+
+	// Apply options
+	for _, option := range options {
+		option(l)
+	}
+
+	return l
+}
+
+// WithFuncs makes functions available to the templates, in addition to join, lower,
+// upper, trim and default. Templates calling a function must be added after it.
+func WithFuncs(funcs template.FuncMap) Option {
+	return func(l *Library) {
+		for name, fn := range funcs {
+			l.funcs[name] = fn
+		}
+	}
+}
+
+// defaultFuncs returns the functions every template can call
+func defaultFuncs() template.FuncMap {
+	return template.FuncMap{
+		"join":  func(items []string, sep string) string { return strings.Join(items, sep) },
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		"trim":  strings.TrimSpace,
+		// default returns the fallback if the value is empty, e.g. {{.Role | default "Assistant"}}
+		"default": func(fallback string, value any) any {
+			if value == nil || value == "" {
+				return fallback
+			}
+			return value
+		},
+	}
+}
+
+// Add adds or replaces a template
+func (l *Library) Add(name, text string) error {
+	if err := l.check(name, text); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.templates[name] = text
+	return nil
+}
+
+// Override replaces a template for one persona only; the persona name is not case sensitive
+func (l *Library) Override(persona, name, text string) error {
+	if err := l.check(name, text); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := strings.ToLower(persona)
+	if l.overrides[key] == nil {
+		l.overrides[key] = make(map[string]string)
+	}
+	l.overrides[key][name] = text
+	return nil
+}
+
+// check parses a template on its own to report syntax errors when it is added
+func (l *Library) check(name, text string) error {
+	if name == "" {
+		return errors.New("prompt template needs a name")
+	}
+	if _, err := template.New(name).Funcs(l.funcs).Parse(text); err != nil {
+		return fmt.Errorf("invalid prompt template %s: %w", name, err)
+	}
+	return nil
+}
+
+// Load adds the .tmpl files at the top of a directory as templates named after the file,
+// e.g. "product-owner" for product-owner.tmpl, and those in its subdirectories as
+// overrides for the persona the subdirectory is named after
+func (l *Library) Load(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return fmt.Errorf("failed to read prompt templates: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			if err := l.loadFile(fsys, entry.Name(), l.Add); err != nil {
+				return err
+			}
+			continue
+		}
+
+		persona := entry.Name()
+		files, err := fs.ReadDir(fsys, persona)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt templates of %s: %w", persona, err)
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			err := l.loadFile(fsys, path.Join(persona, file.Name()), func(name, text string) error {
+				return l.Override(persona, name, text)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadFile reads a template file and adds it with add; other files are skipped
+func (l *Library) loadFile(fsys fs.FS, file string, add func(name, text string) error) error {
+	base := path.Base(file)
+	if !strings.HasSuffix(base, Extension) {
+		return nil
+	}
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return fmt.Errorf("failed to read prompt template %s: %w", file, err)
+	}
+	return add(strings.TrimSuffix(base, Extension), string(data))
+}
+
+// Names returns the names of the templates, sorted
+func (l *Library) Names() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	names := make([]string, 0, len(l.templates))
+	for name := range l.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render executes the named template as seen by the persona, with its overrides
+func (l *Library) Render(persona, name string, data any) (string, error) {
+	set, err := l.build(persona)
+	if err != nil {
+		return "", err
+	}
+	tmpl := set.Lookup(name)
+	if tmpl == nil {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return execute(tmpl, data)
+}
+
+// RenderText executes a template given as text, e.g. a persona's system prompt, which
+// can include the templates of the library as seen by the persona
+func (l *Library) RenderText(persona, text string, data any) (string, error) {
+	set, err := l.build(persona)
+	if err != nil {
+		return "", err
+	}
+	if _, err := set.Parse(text); err != nil {
+		return "", fmt.Errorf("invalid prompt: %w", err)
+	}
+	return execute(set, data)
+}
+
+// build parses the templates as seen by the persona into one set. Sets are built for
+// every render, since text/template does not allow adding to a set once executed.
+func (l *Library) build(persona string) (*template.Template, error) {
+	l.mu.RLock()
+	sources := make(map[string]string, len(l.templates))
+	for name, text := range l.templates {
+		sources[name] = text
+	}
+	for name, text := range l.overrides[strings.ToLower(persona)] {
+		sources[name] = text
+	}
+	l.mu.RUnlock()
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	set := template.New("").Funcs(l.funcs)
+	for _, name := range names {
+		if _, err := set.New(name).Parse(sources[name]); err != nil {
+			return nil, fmt.Errorf("invalid prompt template %s: %w", name, err)
+		}
+	}
+	return set, nil
+}
+
+// execute renders a template to a string
+func execute(tmpl *template.Template, data any) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+	return sb.String(), nil
+}
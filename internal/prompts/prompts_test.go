@@ -0,0 +1,79 @@
+package prompts
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"goproduct/internal/knowledge"
+)
+
+func TestRenderWithPartialsAndOverrides(t *testing.T) {
+	library := New()
+	if err := library.Add("greeting", `Hi, I am {{.}}. {{template "tone" .}}`); err != nil {
+		t.Fatalf("Failed to add template: %v", err)
+	}
+	library.Add("tone", "Keep it short.")
+	library.Override("Ada", "tone", "Be precise.")
+
+	if got, err := library.Render("Andy", "greeting", "Andy"); err != nil || got != "Hi, I am Andy. Keep it short." {
+		t.Errorf("Unexpected rendering: %q, %v", got, err)
+	}
+	if got, err := library.Render("ada", "greeting", "Ada"); err != nil || got != "Hi, I am Ada. Be precise." {
+		t.Errorf("Expected the persona's override, got %q, %v", got, err)
+	}
+	if got, err := library.RenderText("Andy", `{{template "tone"}} {{"x" | upper}}`, nil); err != nil || got != "Keep it short. X" {
+		t.Errorf("Unexpected text rendering: %q, %v", got, err)
+	}
+	if _, err := library.Render("Andy", "missing", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := library.Add("broken", "{{.Name"); err == nil {
+		t.Error("Expected an error for an invalid template")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"intro.tmpl":      {Data: []byte(`{{template "role" .}} at Acme`)},
+		"role.tmpl":       {Data: []byte("Product owner")},
+		"notes.txt":       {Data: []byte("skipped")},
+		"quinn/role.tmpl": {Data: []byte("QA engineer")},
+	}
+	library := New()
+	if err := library.Load(fsys); err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+	if names := library.Names(); strings.Join(names, ",") != "intro,role" {
+		t.Errorf("Unexpected templates: %v", names)
+	}
+	if got, _ := library.Render("Quinn", "intro", nil); got != "QA engineer at Acme" {
+		t.Errorf("Expected the override of the persona directory, got %q", got)
+	}
+}
+
+func TestKnowledgeFuncs(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	now := time.Now()
+	for _, entry := range []knowledge.Entry{
+		{ID: "stack", Category: knowledge.CategoryFact, Content: []byte("We use Go"), Importance: knowledge.ImportanceHigh, Tags: []string{"backend"}},
+		{ID: "policy", Category: knowledge.CategoryFact, Content: []byte("Ship on Fridays"), Importance: knowledge.ImportanceCritical},
+		{ID: "chat", Category: knowledge.CategoryMessage, Content: []byte("Hello"), Importance: knowledge.ImportanceCritical},
+	} {
+		entry.CreatedAt, entry.UpdatedAt = now, now
+		if err := store.AddRecord(entry); err != nil {
+			t.Fatalf("Failed to add record: %v", err)
+		}
+	}
+
+	library := New(WithFuncs(KnowledgeFuncs(store)))
+	got, err := library.RenderText("Andy", `{{fact "stack"}}|{{fact "missing"}}|{{join (facts "") ", "}}|{{join (facts "backend" 1) ", "}}`, nil)
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if want := "We use Go||Ship on Fridays, We use Go|We use Go"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
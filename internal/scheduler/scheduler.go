@@ -0,0 +1,389 @@
+// Package scheduler runs agent tasks at set times, once or periodically, e.g. "summarize
+// today's decisions at 5pm". A task is a prompt sent to an agent over the message bus of
+// the runtime context when due; the agent's answer is sent on to the entity that asked for
+// the task. Task definitions are kept in the knowledge store so they survive restarts.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"goproduct/internal/common"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/logging"
+	"goproduct/internal/messaging"
+)
+
+// Category is the knowledge category task definitions are stored under
+const Category = "scheduled_task"
+
+// Topic is the message bus topic the results of tasks nobody asked to be told about are
+// published to
+const Topic = "scheduled-tasks"
+
+// Metadata keys of the messages sent when a task runs, and of its result
+const (
+	MetadataTaskID     = "task_id"
+	MetadataTaskPrompt = "task_prompt"
+)
+
+// Defaults of the scheduler
+const (
+	DefaultTick       = time.Second     // How often due tasks are looked for
+	DefaultRunTimeout = 2 * time.Minute // How long an agent has to answer a task
+)
+
+// recordPrefix namespaces the knowledge record IDs of tasks
+const recordPrefix = "task-"
+
+// Task is a prompt sent to an agent at a set time, and again at an interval if periodic
+type Task struct {
+	ID        string        `json:"id"`
+	AgentID   string        `json:"agent_id"`            // Entity doing the task
+	Prompt    string        `json:"prompt"`              // Sent to the agent, e.g. "Summarize today's decisions"
+	NotifyID  string        `json:"notify_id,omitempty"` // Entity the result is sent to; published to Topic if empty
+	At        time.Time     `json:"at"`                  // First, or only, run
+	Every     time.Duration `json:"every,omitempty"`     // Between runs; zero runs the task once
+	LastRun   time.Time     `json:"last_run,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// Next returns when the task runs next, zero if it does not run again. Runs missed while
+// the scheduler was stopped are skipped, except for the last one.
+func (t Task) Next() time.Time {
+	switch {
+	case t.LastRun.IsZero():
+		return t.At
+	case t.Every <= 0:
+		return time.Time{}
+	case t.LastRun.Before(t.At):
+		return t.At
+	}
+	return t.At.Add((t.LastRun.Sub(t.At)/t.Every + 1) * t.Every)
+}
+
+// Scheduler runs the tasks of agents when due. The zero value is not usable, use
+// NewScheduler.
+type Scheduler struct {
+	id         string // Sends the tasks to the agents
+	store      knowledge.Store
+	bus        messaging.MessageBus
+	logger     *logging.Logger
+	tick       time.Duration
+	runTimeout time.Duration
+	now        func() time.Time
+	mutex      sync.Mutex
+	tasks      map[string]Task // By ID
+	cancel     context.CancelFunc
+	done       chan struct{}
+	runs       sync.WaitGroup // Tasks being run
+}
+
+// Option is a function that configures a Scheduler
+type Option func(*Scheduler)
+
+// WithTick sets how often due tasks are looked for, DefaultTick by default
+func WithTick(tick time.Duration) Option {
+	return func(s *Scheduler) {
+		if tick > 0 {
+			s.tick = tick
+		}
+	}
+}
+
+// WithRunTimeout sets how long an agent has to answer a task, DefaultRunTimeout by default
+func WithRunTimeout(timeout time.Duration) Option {
+	return func(s *Scheduler) {
+		if timeout > 0 {
+			s.runTimeout = timeout
+		}
+	}
+}
+
+// NewScheduler creates a scheduler keeping tasks in the memory of the runtime context and
+// sending them over its message bus
+func NewScheduler(runtime *common.RuntimeContext, opts ...Option) (*Scheduler, error) {
+	store, err := runtime.GetMemory()
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, errors.New("scheduler needs a knowledge store in the runtime context")
+	}
+	bus, err := runtime.GetMessageBus()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scheduler{
+		id:         "scheduler-" + uuid.New().String(),
+		store:      store,
+		bus:        bus,
+		logger:     logging.Get().Component("scheduler"),
+		tick:       DefaultTick,
+		runTimeout: DefaultRunTimeout,
+		now:        time.Now,
+		tasks:      make(map[string]Task),
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// Schedule adds a task, stored so it is run after a restart too, and returns it with its ID
+func (s *Scheduler) Schedule(task Task) (Task, error) {
+	task.AgentID = strings.TrimSpace(task.AgentID)
+	task.Prompt = strings.TrimSpace(task.Prompt)
+	switch {
+	case task.AgentID == "":
+		return Task{}, errors.New("task needs an agent")
+	case task.Prompt == "":
+		return Task{}, errors.New("task needs a prompt")
+	case task.At.IsZero():
+		return Task{}, errors.New("task needs a time to run at")
+	case task.Every < 0:
+		return Task{}, fmt.Errorf("task interval cannot be negative, got %s", task.Every)
+	}
+	task.ID = uuid.New().String()[:8]
+	task.LastRun = time.Time{}
+	task.CreatedAt = s.now()
+
+	entry, err := taskEntry(task)
+	if err != nil {
+		return Task{}, err
+	}
+	if err := s.store.AddRecord(entry); err != nil {
+		return Task{}, fmt.Errorf("failed to store task: %w", err)
+	}
+	if err := s.store.Flush(); err != nil {
+		return Task{}, fmt.Errorf("failed to store task: %w", err)
+	}
+
+	s.mutex.Lock()
+	s.tasks[task.ID] = task
+	s.mutex.Unlock()
+	s.logger.Info("Task scheduled", "task_id", task.ID, "agent_id", task.AgentID, "at", task.At, "every", task.Every)
+	return task, nil
+}
+
+// Cancel removes a task so it does not run again
+func (s *Scheduler) Cancel(id string) error {
+	s.mutex.Lock()
+	_, found := s.tasks[id]
+	delete(s.tasks, id)
+	s.mutex.Unlock()
+	if !found {
+		return fmt.Errorf("no task %s", id)
+	}
+	if err := s.store.DeleteRecord(recordPrefix + id); err != nil {
+		return fmt.Errorf("failed to delete task %s: %w", id, err)
+	}
+	s.logger.Info("Task cancelled", "task_id", id)
+	return s.store.Flush()
+}
+
+// Tasks returns the scheduled tasks, the next to run first
+func (s *Scheduler) Tasks() []Task {
+	s.mutex.Lock()
+	tasks := make([]Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, task)
+	}
+	s.mutex.Unlock()
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if next, other := tasks[i].Next(), tasks[j].Next(); !next.Equal(other) {
+			return next.Before(other)
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks
+}
+
+// Start loads the stored tasks and runs them when due until the context is cancelled or
+// Stop is called. Tasks that were due while the scheduler was stopped run at once.
+func (s *Scheduler) Start(ctx context.Context) error {
+	tasks, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.cancel != nil {
+		return errors.New("scheduler already started")
+	}
+	for _, task := range tasks {
+		s.tasks[task.ID] = task
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(s.tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.runDue(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(s.done)
+	s.logger.Info("Scheduler started", "tasks", len(tasks))
+	return nil
+}
+
+// Stop stops running tasks, abandoning those waiting for an agent's answer
+func (s *Scheduler) Stop() {
+	s.mutex.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mutex.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+	s.runs.Wait()
+}
+
+// runDue runs the tasks that are due. They are recorded as run before the agent is asked,
+// so a task is not run twice if the scheduler stops in the meantime.
+func (s *Scheduler) runDue(ctx context.Context) {
+	now := s.now()
+	var due []Task
+	s.mutex.Lock()
+	for id, task := range s.tasks {
+		next := task.Next()
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+		task.LastRun = now
+		if task.Next().IsZero() {
+			delete(s.tasks, id)
+		} else {
+			s.tasks[id] = task
+		}
+		due = append(due, task)
+	}
+	s.mutex.Unlock()
+
+	for _, task := range due {
+		if err := s.record(task); err != nil {
+			s.logger.Error("Failed to record task run", "task_id", task.ID, "error", err)
+		}
+		s.runs.Add(1)
+		go func(task Task) {
+			defer s.runs.Done()
+			s.run(ctx, task)
+		}(task)
+	}
+}
+
+// record stores the last run of a task, or deletes a task that does not run again
+func (s *Scheduler) record(task Task) error {
+	if task.Next().IsZero() {
+		if err := s.store.DeleteRecord(recordPrefix + task.ID); err != nil {
+			return err
+		}
+		return s.store.Flush()
+	}
+	entry, err := taskEntry(task)
+	if err != nil {
+		return err
+	}
+	if err := s.store.UpdateRecord(entry); err != nil {
+		return err
+	}
+	return s.store.Flush()
+}
+
+// run sends a task to its agent and the agent's answer on to whoever asked for the task
+func (s *Scheduler) run(ctx context.Context, task Task) {
+	s.logger.Info("Running task", "task_id", task.ID, "agent_id", task.AgentID)
+	msg := messaging.NewTextMessage(s.id, []string{task.AgentID}, task.Prompt)
+	msg.Metadata[MetadataTaskID] = task.ID
+
+	ctx, cancel := context.WithTimeout(ctx, s.runTimeout)
+	defer cancel()
+	reply, err := s.bus.Request(ctx, msg)
+	if err != nil {
+		s.logger.Warn("Task not answered", "task_id", task.ID, "agent_id", task.AgentID, "error", err)
+		return
+	}
+
+	// The result comes from the agent, as if it had sent it itself
+	var result messaging.Message
+	if task.NotifyID != "" {
+		result = messaging.NewMessage(reply.SenderID, []string{task.NotifyID}, reply.ContentType, reply.Content)
+	} else {
+		result = messaging.NewTopicMessage(reply.SenderID, Topic, reply.ContentType, reply.Content)
+	}
+	result.Metadata[MetadataTaskID] = task.ID
+	result.Metadata[MetadataTaskPrompt] = task.Prompt
+	if err := s.bus.Publish(result); err != nil {
+		s.logger.Error("Failed to send task result", "task_id", task.ID, "error", err)
+		return
+	}
+	s.logger.Info("Task done", "task_id", task.ID, "result_id", result.ID)
+}
+
+// load returns the tasks kept in the store
+func (s *Scheduler) load() ([]Task, error) {
+	records, err := s.store.SearchRecords(knowledge.Filter{
+		RootGroup: knowledge.FilterGroup{
+			Operator:   knowledge.OpAnd,
+			Conditions: []knowledge.Condition{{Field: "Category", Operator: "=", Value: Category}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %w", err)
+	}
+
+	tasks := make([]Task, 0, len(records))
+	for _, record := range records {
+		var task Task
+		if err := json.Unmarshal(record.Content, &task); err != nil {
+			return nil, fmt.Errorf("corrupt task %s: %w", record.ID, err)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// taskEntry converts a task to the record it is stored as
+func taskEntry(task Task) (knowledge.Entry, error) {
+	content, err := json.Marshal(task)
+	if err != nil {
+		return knowledge.Entry{}, fmt.Errorf("failed to marshal task %s: %w", task.ID, err)
+	}
+	return knowledge.Entry{
+		ID:          recordPrefix + task.ID,
+		Category:    Category,
+		ContentType: knowledge.ContentTypeJSON,
+		Content:     content,
+		CreatedAt:   task.CreatedAt,
+		UpdatedAt:   time.Now(),
+		OwnerID:     task.AgentID,
+		OwnerType:   "agent",
+		SubjectIDs:  []string{},
+		Tags:        []string{},
+		References:  []knowledge.Reference{},
+		Metadata:    map[string]string{"prompt": task.Prompt},
+	}, nil
+}
@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/common"
+	"goproduct/internal/knowledge"
+	"goproduct/internal/messaging"
+	"goproduct/internal/tools"
+)
+
+// newTestScheduler returns a scheduler looking for due tasks every few milliseconds, with
+// an agent answering every task
+func newTestScheduler(t *testing.T) (*Scheduler, *common.RuntimeContext, messaging.MessageBus) {
+	t.Helper()
+	store, err := knowledge.NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	bus := messaging.NewMemoryMessageBus()
+	runtime, err := common.NewRuntimeContext(common.RuntimeOptions{Memory: store, MessageBus: bus})
+	if err != nil {
+		t.Fatalf("Failed to create runtime context: %v", err)
+	}
+	bus.Subscribe("andy", func(msg messaging.Message) error {
+		return bus.Publish(messaging.NewTextReplyMessage("andy", msg, "Done: "+string(msg.Content)))
+	})
+	s, err := NewScheduler(runtime, WithTick(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+	return s, runtime, bus
+}
+
+func TestTaskNext(t *testing.T) {
+	at := time.Date(2025, 6, 2, 17, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		task Task
+		want time.Time
+	}{
+		{"not run yet", Task{At: at, Every: time.Hour}, at},
+		{"run once", Task{At: at, LastRun: at}, time.Time{}},
+		{"periodic", Task{At: at, Every: 24 * time.Hour, LastRun: at.Add(time.Minute)}, at.Add(24 * time.Hour)},
+		{"missed runs skipped", Task{At: at, Every: time.Hour, LastRun: at.Add(150 * time.Minute)}, at.Add(3 * time.Hour)},
+	}
+	for _, tt := range tests {
+		if got := tt.task.Next(); !got.Equal(tt.want) {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	s, runtime, bus := newTestScheduler(t)
+	results := make(chan messaging.Message, 10)
+	bus.Subscribe("user", func(msg messaging.Message) error {
+		results <- msg
+		return nil
+	})
+
+	once, err := s.Schedule(Task{AgentID: "andy", Prompt: "Summarize today's decisions", NotifyID: "user", At: time.Now()})
+	if err != nil {
+		t.Fatalf("Failed to schedule: %v", err)
+	}
+	later, _ := s.Schedule(Task{AgentID: "andy", Prompt: "Plan the week", NotifyID: "user", At: time.Now().Add(time.Hour), Every: 24 * time.Hour})
+	if _, err := s.Schedule(Task{AgentID: "andy", At: time.Now()}); err == nil {
+		t.Error("Expected a task without a prompt to be refused")
+	}
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	select {
+	case result := <-results:
+		if string(result.Content) != "Done: Summarize today's decisions" || result.SenderID != "andy" || result.Metadata[MetadataTaskID] != once.ID {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the task to run")
+	}
+	s.Stop()
+
+	// The task run once is gone; the other one is stored and picked up by a new scheduler
+	if tasks := s.Tasks(); len(tasks) != 1 || tasks[0].ID != later.ID {
+		t.Errorf("Expected the periodic task left, got %+v", tasks)
+	}
+	restarted, _ := NewScheduler(runtime)
+	if err := restarted.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to restart: %v", err)
+	}
+	defer restarted.Stop()
+	if tasks := restarted.Tasks(); len(tasks) != 1 || tasks[0].Prompt != "Plan the week" {
+		t.Errorf("Expected the periodic task loaded, got %+v", tasks)
+	}
+	if err := restarted.Cancel(later.ID); err != nil || len(restarted.Tasks()) != 0 {
+		t.Errorf("Expected the task cancelled, got %v", err)
+	}
+	if err := restarted.Cancel(later.ID); err == nil {
+		t.Error("Expected an error cancelling an unknown task")
+	}
+}
+
+func TestTaskTools(t *testing.T) {
+	s, _, _ := newTestScheduler(t)
+	now := time.Date(2025, 6, 2, 18, 30, 0, 0, time.Local)
+	s.now = func() time.Time { return now }
+	registry := tools.NewRegistry(Tools(s, "andy")...)
+	ctx := tools.WithCaller(context.Background(), tools.Caller{AgentName: "Andy", SenderID: "user"})
+
+	call := func(name string, args tools.Arguments) (string, error) {
+		return registry.Call(ctx, tools.Call{Name: name, Arguments: args})
+	}
+
+	result, err := call(ToolScheduleTask, tools.Arguments{"prompt": "Summarize today's decisions", "at": "17:00", "every": "daily"})
+	if err != nil || !strings.Contains(result, "from 2025-06-03 17:00, every 24h0m0s") {
+		t.Errorf("Expected the task from tomorrow 17:00, got %q, %v", result, err)
+	}
+	if _, err := call(ToolScheduleTask, tools.Arguments{"prompt": "Ping", "at": "45m", "every": "10s"}); err == nil {
+		t.Error("Expected a too short interval to be refused")
+	}
+	if _, err := call(ToolScheduleTask, tools.Arguments{"prompt": "Ping", "at": "soon"}); err == nil {
+		t.Error("Expected an invalid time to be refused")
+	}
+
+	tasks := s.Tasks()
+	if len(tasks) != 1 || tasks[0].NotifyID != "user" || tasks[0].AgentID != "andy" {
+		t.Fatalf("Expected a task for the caller, got %+v", tasks)
+	}
+	if result, _ := call(ToolListTasks, nil); !strings.Contains(result, "["+tasks[0].ID+"] next at 2025-06-03 17:00, every 24h0m0s: Summarize") {
+		t.Errorf("Unexpected list: %q", result)
+	}
+	if result, err := call(ToolCancelTask, tools.Arguments{"id": tasks[0].ID}); err != nil || result != "Cancelled task "+tasks[0].ID {
+		t.Errorf("Expected the task cancelled, got %q, %v", result, err)
+	}
+	if result, _ := call(ToolListTasks, nil); result != "No tasks scheduled" {
+		t.Errorf("Expected no tasks, got %q", result)
+	}
+}
@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"goproduct/internal/tools"
+)
+
+// Task tool names
+const (
+	ToolScheduleTask = "schedule_task"
+	ToolListTasks    = "list_tasks"
+	ToolCancelTask   = "cancel_task"
+)
+
+// minEvery is the shortest interval of a periodic task scheduled by an agent
+const minEvery = time.Minute
+
+// Tools returns tools letting an agent schedule tasks for itself, list and cancel them.
+// The result of a task is sent to the entity whose message the agent was answering when
+// it scheduled the task.
+func Tools(s *Scheduler, agentID string) []tools.Tool {
+	t := taskTools{scheduler: s, agentID: agentID}
+	return []tools.Tool{
+		{
+			Name:        ToolScheduleTask,
+			Description: "Schedule something to do later, once or periodically, e.g. summarize today's decisions at 17:00 every day; the result is sent to the user",
+			Parameters: []tools.Parameter{
+				{Name: "prompt", Type: tools.TypeString, Description: "What to do, as an instruction to yourself", Required: true},
+				{Name: "at", Type: tools.TypeString, Description: `When to do it: "17:00", "2025-06-30 09:00" or a delay such as "45m"`, Required: true},
+				{Name: "every", Type: tools.TypeString, Description: `How often to do it again: "hourly", "daily", "weekly" or a duration such as "2h"; once if empty`},
+			},
+			Handler: t.schedule,
+		},
+		{
+			Name:        ToolListTasks,
+			Description: "List your scheduled tasks, with the ID to cancel them by",
+			Handler:     t.list,
+		},
+		{
+			Name:        ToolCancelTask,
+			Description: "Cancel a scheduled task",
+			Parameters: []tools.Parameter{
+				{Name: "id", Type: tools.TypeString, Description: "ID of the task, from list_tasks", Required: true},
+			},
+			Handler: t.cancel,
+		},
+	}
+}
+
+// taskTools implements the task tools of an agent on a scheduler
+type taskTools struct {
+	scheduler *Scheduler
+	agentID   string
+}
+
+// schedule serves schedule_task
+func (t taskTools) schedule(ctx context.Context, args tools.Arguments) (string, error) {
+	prompt, err := args.String("prompt")
+	if err != nil {
+		return "", err
+	}
+	at, err := args.String("at")
+	if err != nil {
+		return "", err
+	}
+	every, err := args.String("every")
+	if err != nil {
+		return "", err
+	}
+
+	now := t.scheduler.now()
+	task := Task{AgentID: t.agentID, Prompt: prompt, NotifyID: tools.CallerFrom(ctx).SenderID}
+	if task.At, err = parseAt(at, now); err != nil {
+		return "", err
+	}
+	if task.Every, err = parseEvery(every); err != nil {
+		return "", err
+	}
+	task, err = t.scheduler.Schedule(task)
+	if err != nil {
+		return "", err
+	}
+	if task.Every > 0 {
+		return fmt.Sprintf("Scheduled task %s from %s, every %s", task.ID, task.At.Format("2006-01-02 15:04"), task.Every), nil
+	}
+	return fmt.Sprintf("Scheduled task %s at %s", task.ID, task.At.Format("2006-01-02 15:04")), nil
+}
+
+// list serves list_tasks
+func (t taskTools) list(ctx context.Context, args tools.Arguments) (string, error) {
+	var sb strings.Builder
+	for _, task := range t.scheduler.Tasks() {
+		if task.AgentID != t.agentID {
+			continue
+		}
+		fmt.Fprintf(&sb, "[%s] next at %s", task.ID, task.Next().Format("2006-01-02 15:04"))
+		if task.Every > 0 {
+			fmt.Fprintf(&sb, ", every %s", task.Every)
+		}
+		fmt.Fprintf(&sb, ": %s\n", task.Prompt)
+	}
+	if sb.Len() == 0 {
+		return "No tasks scheduled", nil
+	}
+	return sb.String(), nil
+}
+
+// cancel serves cancel_task
+func (t taskTools) cancel(ctx context.Context, args tools.Arguments) (string, error) {
+	id, err := args.String("id")
+	if err != nil {
+		return "", err
+	}
+	for _, task := range t.scheduler.Tasks() {
+		if task.ID == id && task.AgentID == t.agentID {
+			return fmt.Sprintf("Cancelled task %s", id), t.scheduler.Cancel(id)
+		}
+	}
+	return "", fmt.Errorf("no task %s", id)
+}
+
+// parseAt parses when a task runs: a time of day, the next one to come, a date and time,
+// or a delay from now
+func parseAt(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if delay, err := time.ParseDuration(value); err == nil && delay >= 0 {
+		return now.Add(delay), nil
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	if at, err := time.ParseInLocation("2006-01-02 15:04", value, now.Location()); err == nil {
+		return at, nil
+	}
+	if clock, err := time.ParseInLocation("15:04", value, now.Location()); err == nil {
+		at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+		if at.Before(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+	return time.Time{}, fmt.Errorf(`invalid time %q, expected e.g. "17:00", "2025-06-30 09:00" or "45m"`, value)
+}
+
+// parseEvery parses the interval of a periodic task, zero for a task run once
+func parseEvery(value string) (time.Duration, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "", "once", "never":
+		return 0, nil
+	case "hourly":
+		return time.Hour, nil
+	case "daily":
+		return 24 * time.Hour, nil
+	case "weekly":
+		return 7 * 24 * time.Hour, nil
+	}
+	every, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf(`invalid interval %q, expected "hourly", "daily", "weekly" or e.g. "2h"`, value)
+	}
+	if every < minEvery {
+		return 0, fmt.Errorf("interval must be at least %s, got %s", minEvery, every)
+	}
+	return every, nil
+}
@@ -0,0 +1,419 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/logging"
+	"goproduct/internal/messaging"
+)
+
+// API limits
+const (
+	maxRequestBody = 1 << 20          // Largest message a client may post
+	maxWait        = 2 * time.Minute  // Longest a request may wait for a reply or messages
+	keepAlive      = 15 * time.Second // Interval of comments keeping idle streams open
+)
+
+// API serves the product agent to web frontends as a REST API:
+//
+//	POST /api/messages          Send a message, to the agent unless recipients are given;
+//	                            ?wait=30s waits for the reply
+//	GET  /api/messages          Poll the messages received after ?after=<seq>;
+//	                            ?wait=30s waits for one to arrive
+//	GET  /api/messages/stream   Stream received messages as server-sent events
+//	GET  /api/history           Conversation history, ?since=<RFC 3339>&limit=<n>
+//	GET  /api/knowledge         Search the knowledge store, ?q=<text>&limit=<n>&category=<c>
+//
+// Every request is authenticated and acts as the entity of its principal. Received
+// messages are kept in a per-entity inbox from the entity's first request on; the inbox
+// observes the entity's messages rather than subscribing it, so the entity may also be
+// connected through the Gateway or live in this process.
+type API struct {
+	bus          messaging.MessageBus
+	agentID      string
+	store        knowledge.Store
+	authenticate Authenticate
+	mux          *http.ServeMux
+	inboxes      map[string]*inbox // Inboxes by entity
+	logger       *logging.Logger
+	mu           sync.Mutex // Protects inboxes
+}
+
+// NewAPI creates an API for the agent entity on the bus. The store may be nil, in which
+// case knowledge search is not available.
+func NewAPI(bus messaging.MessageBus, agentID string, store knowledge.Store, authenticate Authenticate) *API {
+	a := &API{
+		bus:          bus,
+		agentID:      agentID,
+		store:        store,
+		authenticate: authenticate,
+		mux:          http.NewServeMux(),
+		inboxes:      make(map[string]*inbox),
+		logger:       logging.Get().Component("server"),
+	}
+	a.mux.HandleFunc("POST /api/messages", a.authenticated(a.sendMessage))
+	a.mux.HandleFunc("GET /api/messages", a.authenticated(a.pollMessages))
+	a.mux.HandleFunc("GET /api/messages/stream", a.authenticated(a.streamMessages))
+	a.mux.HandleFunc("GET /api/history", a.authenticated(a.history))
+	a.mux.HandleFunc("GET /api/knowledge", a.authenticated(a.searchKnowledge))
+	return a
+}
+
+// ServeHTTP serves an API request
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+// Close stops observing the messages of every inbox
+func (a *API) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var errs []error
+	for entityID := range a.inboxes {
+		if err := a.bus.UnsubscribePattern(inboxPattern(entityID)); err != nil {
+			errs = append(errs, err)
+		}
+		delete(a.inboxes, entityID)
+	}
+	return errors.Join(errs...)
+}
+
+// authenticated resolves the principal of a request before calling the handler
+func (a *API) authenticated(handler func(http.ResponseWriter, *http.Request, Principal)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.authenticate(r)
+		if err != nil || principal.EntityID == "" {
+			writeError(w, http.StatusUnauthorized, ErrUnauthenticated)
+			return
+		}
+		handler(w, r, principal)
+	}
+}
+
+// inboxPattern returns the pattern matching exactly the entity
+func inboxPattern(entityID string) string {
+	return messaging.RegexpPatternPrefix + "^" + regexp.QuoteMeta(entityID) + "$"
+}
+
+// inbox returns the inbox of an entity, creating it on first use
+func (a *API) inbox(entityID string) (*inbox, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if b, ok := a.inboxes[entityID]; ok {
+		return b, nil
+	}
+	b := newInbox()
+	err := a.bus.SubscribePattern(inboxPattern(entityID), messaging.PatternObserve, func(msg messaging.Message) error {
+		b.add(msg)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inbox of %s: %w", entityID, err)
+	}
+	a.inboxes[entityID] = b
+	return b, nil
+}
+
+// sendMessage serves POST /api/messages
+func (a *API) sendMessage(w http.ResponseWriter, r *http.Request, principal Principal) {
+	wait, err := waitParameter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var frame MessageFrame
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&frame); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid message: %w", err))
+		return
+	}
+	if len(frame.Recipients) == 0 {
+		frame.Recipients = []string{a.agentID}
+	}
+	msg, err := decodeMessage(principal.EntityID, &frame)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Open the inbox first so a fast reply is not missed
+	b, err := a.inbox(principal.EntityID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	seq := b.last()
+	if err := a.bus.Publish(msg); err != nil {
+		a.logger.Error("Failed to publish API message", "entity_id", principal.EntityID, "message_id", msg.ID, "error", err)
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	a.logger.Info("API message sent", "entity_id", principal.EntityID, "message_id", msg.ID)
+	if wait == 0 {
+		writeJSON(w, http.StatusAccepted, map[string]string{"id": msg.ID})
+		return
+	}
+
+	timeout := time.After(wait)
+	for {
+		messages, arrived := b.after(seq)
+		for _, m := range messages {
+			if m.Message.ReplyToID == msg.ID {
+				writeJSON(w, http.StatusOK, map[string]interface{}{"id": msg.ID, "reply": m.Message})
+				return
+			}
+			seq = m.Seq
+		}
+		select {
+		case <-arrived:
+		case <-timeout:
+			// The reply may still come; the client can poll for it
+			writeJSON(w, http.StatusAccepted, map[string]string{"id": msg.ID})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// pollMessages serves GET /api/messages
+func (a *API) pollMessages(w http.ResponseWriter, r *http.Request, principal Principal) {
+	after, err := afterParameter(r.URL.Query().Get("after"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	wait, err := waitParameter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	b, err := a.inbox(principal.EntityID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	messages, arrived := b.after(after)
+	if len(messages) == 0 && wait > 0 {
+		select {
+		case <-arrived:
+			messages, _ = b.after(after)
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	next := after
+	if len(messages) > 0 {
+		next = messages[len(messages)-1].Seq
+	}
+	if messages == nil {
+		messages = []InboxMessage{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"messages": messages, "next": next})
+}
+
+// streamMessages serves GET /api/messages/stream as server-sent events, each carrying a
+// message frame with its sequence number as event ID. Reconnecting clients resume after
+// the Last-Event-ID they send.
+func (a *API) streamMessages(w http.ResponseWriter, r *http.Request, principal Principal) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("after")
+	}
+	after, err := afterParameter(value)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	b, err := a.inbox(principal.EntityID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		messages, arrived := b.after(after)
+		for _, m := range messages {
+			data, err := json.Marshal(m.Message)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: message\ndata: %s\n\n", m.Seq, data); err != nil {
+				return
+			}
+			after = m.Seq
+		}
+		flusher.Flush()
+
+		select {
+		case <-arrived:
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// history serves GET /api/history
+func (a *API) history(w http.ResponseWriter, r *http.Request, principal Principal) {
+	query := r.URL.Query()
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid since: %w", err))
+			return
+		}
+		since = parsed
+	}
+	limit, err := limitParameter(query.Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	messages, err := a.bus.GetHistory(principal.EntityID, since, limit)
+	if errors.Is(err, messaging.ErrHistoryDisabled) {
+		writeError(w, http.StatusNotImplemented, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	frames := make([]*MessageFrame, 0, len(messages))
+	for _, msg := range messages {
+		frames = append(frames, encodeMessage(msg))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"messages": frames})
+}
+
+// KnowledgeResult is a knowledge search hit as returned by the API
+type KnowledgeResult struct {
+	ID          string    `json:"id"`
+	Category    string    `json:"category"`
+	ContentType string    `json:"content_type"`
+	Content     string    `json:"content"`
+	Tags        []string  `json:"tags,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Score       float64   `json:"score"`
+}
+
+// searchKnowledge serves GET /api/knowledge
+func (a *API) searchKnowledge(w http.ResponseWriter, r *http.Request, principal Principal) {
+	if a.store == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("knowledge search is not available"))
+		return
+	}
+	query := r.URL.Query()
+	text := strings.TrimSpace(query.Get("q"))
+	if text == "" {
+		writeError(w, http.StatusBadRequest, errors.New("missing query parameter q"))
+		return
+	}
+	limit, err := limitParameter(query.Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if limit == 0 {
+		limit = 10
+	}
+	opts := []knowledge.SearchOption{knowledge.WithSearchLimit(limit)}
+	if categories := query["category"]; len(categories) > 0 {
+		opts = append(opts, knowledge.WithSearchCategories(categories...))
+	}
+
+	hits, err := a.store.FullTextSearch(text, opts...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	results := make([]KnowledgeResult, 0, len(hits))
+	for _, hit := range hits {
+		results = append(results, KnowledgeResult{
+			ID:          hit.Entry.ID,
+			Category:    hit.Entry.Category,
+			ContentType: hit.Entry.ContentType,
+			Content:     string(hit.Entry.Content),
+			Tags:        hit.Entry.Tags,
+			CreatedAt:   hit.Entry.CreatedAt,
+			Score:       hit.Score,
+		})
+	}
+	a.logger.Debug("API knowledge search", "entity_id", principal.EntityID, "query", text, "results", len(results))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// waitParameter parses the wait query parameter, a duration of at most maxWait
+func waitParameter(r *http.Request) (time.Duration, error) {
+	value := r.URL.Query().Get("wait")
+	if value == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(value)
+	if err != nil || wait < 0 {
+		return 0, fmt.Errorf("invalid wait %q", value)
+	}
+	return min(wait, maxWait), nil
+}
+
+// afterParameter parses a sequence number, 0 when empty
+func afterParameter(value string) (uint64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	after, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sequence number %q", value)
+	}
+	return after, nil
+}
+
+// limitParameter parses a result limit, 0 when empty
+func limitParameter(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("invalid limit %q", value)
+	}
+	return limit, nil
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/messaging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAPI serves an API for an agent that echoes what it receives
+func newTestAPI(t *testing.T, store knowledge.Store) (*messaging.MemoryMessageBus, *httptest.Server) {
+	bus := messaging.NewMemoryMessageBus(messaging.WithHistory(10))
+	require.NoError(t, bus.Subscribe("agent", func(msg messaging.Message) error {
+		return bus.Publish(messaging.NewTextReplyMessage("agent", msg, "echo: "+string(msg.Content)))
+	}))
+	api := NewAPI(bus, "agent", store, TokenAuthenticator("secret", Principal{EntityID: "web-user"}))
+	server := httptest.NewServer(api)
+	t.Cleanup(func() { server.Close(); api.Close() })
+	return bus, server
+}
+
+// call makes an authenticated API request and decodes the JSON response
+func call(t *testing.T, server *httptest.Server, method, path, body string) (int, map[string]json.RawMessage) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var decoded map[string]json.RawMessage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+	return resp.StatusCode, decoded
+}
+
+// field decodes a field of a JSON response
+func field[T any](t *testing.T, response map[string]json.RawMessage, name string) T {
+	t.Helper()
+	var value T
+	require.NoError(t, json.Unmarshal(response[name], &value))
+	return value
+}
+
+func TestAPISendAndWaitForReply(t *testing.T) {
+	_, server := newTestAPI(t, nil)
+
+	status, response := call(t, server, http.MethodPost, "/api/messages?wait=2s", `{"content":"hello"}`)
+	require.Equal(t, http.StatusOK, status)
+	reply := field[MessageFrame](t, response, "reply")
+	assert.Equal(t, "echo: hello", reply.Content)
+	assert.Equal(t, "agent", reply.SenderID)
+	assert.Equal(t, field[string](t, response, "id"), reply.ReplyToID)
+
+	status, response = call(t, server, http.MethodPost, "/api/messages", `{"recipients":[]}`)
+	assert.Equal(t, http.StatusAccepted, status, "an empty message is still a message")
+	assert.NotEmpty(t, field[string](t, response, "id"))
+
+	status, _ = call(t, server, http.MethodPost, "/api/messages", `not json`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = call(t, server, http.MethodPost, "/api/messages?wait=soon", `{"content":"hi"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestAPIPollAndHistory(t *testing.T) {
+	bus, server := newTestAPI(t, nil)
+
+	// The inbox opens with the first request of the entity
+	status, response := call(t, server, http.MethodGet, "/api/messages", "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, field[[]InboxMessage](t, response, "messages"))
+
+	require.NoError(t, bus.Publish(messaging.NewTextMessage("agent", []string{"web-user"}, "first")))
+	require.NoError(t, bus.Publish(messaging.NewTextMessage("agent", []string{"web-user"}, "second")))
+	require.Eventually(t, func() bool {
+		_, response = call(t, server, http.MethodGet, "/api/messages", "")
+		return len(field[[]InboxMessage](t, response, "messages")) == 2
+	}, time.Second, 10*time.Millisecond)
+	next := field[uint64](t, response, "next")
+
+	// Waiting polls return when a message arrives
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		bus.Publish(messaging.NewTextMessage("agent", []string{"web-user"}, "third"))
+	}()
+	_, response = call(t, server, http.MethodGet, "/api/messages?wait=2s&after="+strconv.FormatUint(next, 10), "")
+	messages := field[[]InboxMessage](t, response, "messages")
+	require.Len(t, messages, 1)
+	assert.Equal(t, "third", messages[0].Message.Content)
+
+	status, response = call(t, server, http.MethodGet, "/api/history?limit=2", "")
+	require.Equal(t, http.StatusOK, status)
+	history := field[[]MessageFrame](t, response, "messages")
+	require.Len(t, history, 2)
+	assert.Equal(t, "third", history[1].Content)
+}
+
+func TestAPIStreamsMessages(t *testing.T) {
+	bus, server := newTestAPI(t, nil)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/messages/stream?access_token=secret", nil)
+	require.NoError(t, err)
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	require.NoError(t, bus.Publish(messaging.NewTextMessage("agent", []string{"web-user"}, "streamed")))
+	reader := bufio.NewReader(resp.Body)
+	var event []string
+	for len(event) < 3 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		event = append(event, strings.TrimSpace(line))
+	}
+	assert.Equal(t, "id: 1", event[0])
+	assert.Equal(t, "event: message", event[1])
+	var frame MessageFrame
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(event[2], "data: ")), &frame))
+	assert.Equal(t, "streamed", frame.Content)
+}
+
+func TestAPIKnowledgeSearchAndAuthentication(t *testing.T) {
+	store, err := knowledge.NewMemoryStore()
+	require.NoError(t, err)
+	require.NoError(t, store.Open())
+	require.NoError(t, store.AddRecord(knowledge.Entry{
+		ID:          "k1",
+		Category:    knowledge.CategoryFact,
+		ContentType: knowledge.ContentTypeText,
+		Content:     []byte("The checkout redesign ships in March"),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}))
+	_, server := newTestAPI(t, store)
+
+	status, response := call(t, server, http.MethodGet, "/api/knowledge?q=checkout", "")
+	require.Equal(t, http.StatusOK, status)
+	results := field[[]KnowledgeResult](t, response, "results")
+	require.Len(t, results, 1)
+	assert.Equal(t, "k1", results[0].ID)
+	assert.Equal(t, "The checkout redesign ships in March", results[0].Content)
+
+	status, _ = call(t, server, http.MethodGet, "/api/knowledge", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	resp, err := server.Client().Get(server.URL + "/api/history")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	_, noStore := newTestAPI(t, nil)
+	status, _ = call(t, noStore, http.MethodGet, "/api/knowledge?q=checkout", "")
+	assert.Equal(t, http.StatusNotImplemented, status)
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"goproduct/internal/auth"
+	"goproduct/internal/logging"
+)
+
+// stateCookie holds the state of a redirect sign-in between its start and its callback
+const stateCookie = "auth_state"
+
+// stateTTL bounds how long a user has to sign in at a redirect provider
+const stateTTL = 10 * time.Minute
+
+// SessionResponse is a session as returned by logins and refreshes
+type SessionResponse struct {
+	EntityID         string    `json:"entity_id"`
+	Name             string    `json:"name,omitempty"`
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// AuthHandler signs web users in with the providers of an authenticator, issuing the
+// sessions whose access tokens SessionAuthenticator accepts:
+//
+//	POST /api/auth/login                Log in with {"provider", "username", "password"},
+//	                                    or the {"provider", "code"} of a redirect
+//	GET  /api/auth/{provider}/start     Redirect to the sign-in page of a redirect provider
+//	GET  /api/auth/{provider}/callback  Where the provider sends the user back; logs in
+//	POST /api/auth/refresh              Exchange {"refresh_token"} for a new session
+//	POST /api/auth/logout               End the session of the bearer token
+type AuthHandler struct {
+	authenticator *auth.Authenticator
+	mux           *http.ServeMux
+	logger        *logging.Logger
+}
+
+// NewAuthHandler creates the sign-in endpoints of the authenticator
+func NewAuthHandler(authenticator *auth.Authenticator) *AuthHandler {
+	h := &AuthHandler{
+		authenticator: authenticator,
+		mux:           http.NewServeMux(),
+		logger:        logging.Get().Component("server"),
+	}
+	h.mux.HandleFunc("POST /api/auth/login", h.login)
+	h.mux.HandleFunc("GET /api/auth/{provider}/start", h.start)
+	h.mux.HandleFunc("GET /api/auth/{provider}/callback", h.callback)
+	h.mux.HandleFunc("POST /api/auth/refresh", h.refresh)
+	h.mux.HandleFunc("POST /api/auth/logout", h.logout)
+	return h
+}
+
+// ServeHTTP serves a sign-in request
+func (h *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// login serves POST /api/auth/login
+func (h *AuthHandler) login(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Provider string `json:"provider"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid login: %w", err))
+		return
+	}
+	if body.Provider == "" {
+		body.Provider = auth.ProviderLocal
+	}
+	h.startSession(w, r, body.Provider, auth.Credentials{Username: body.Username, Password: body.Password, Code: body.Code})
+}
+
+// start serves GET /api/auth/{provider}/start, remembering the state it sends to the
+// provider in a cookie so that the callback can check it
+func (h *AuthHandler) start(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("provider")
+	provider, ok := h.authenticator.Provider(name)
+	redirect, isRedirect := provider.(auth.RedirectProvider)
+	if !ok || !isRedirect {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", auth.ErrUnknownProvider, name))
+		return
+	}
+	state, err := newState()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	target, err := redirect.AuthCodeURL(r.Context(), state)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state,
+		Path:     "/api/auth/",
+		MaxAge:   int(stateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// callback serves GET /api/auth/{provider}/callback
+func (h *AuthHandler) callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if reason := query.Get("error"); reason != "" {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("sign-in refused: %s", reason))
+		return
+	}
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid sign-in state"))
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/api/auth/", MaxAge: -1})
+	h.startSession(w, r, r.PathValue("provider"), auth.Credentials{Code: query.Get("code")})
+}
+
+// refresh serves POST /api/auth/refresh
+func (h *AuthHandler) refresh(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid refresh: %w", err))
+		return
+	}
+	session, err := h.authenticator.Refresh(body.RefreshToken)
+	if err != nil {
+		writeError(w, authStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sessionResponse(session))
+}
+
+// logout serves POST /api/auth/logout
+func (h *AuthHandler) logout(w http.ResponseWriter, r *http.Request) {
+	token := requestToken(r)
+	if token == "" {
+		writeError(w, http.StatusUnauthorized, ErrUnauthenticated)
+		return
+	}
+	if err := h.authenticator.Logout(token); err != nil {
+		writeError(w, authStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startSession logs in with the provider and writes the session
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, provider string, credentials auth.Credentials) {
+	session, err := h.authenticator.Login(r.Context(), provider, credentials)
+	if err != nil {
+		h.logger.Warn("Login failed", "provider", provider, "error", err)
+		writeError(w, authStatus(err), err)
+		return
+	}
+	h.logger.Info("User logged in", "provider", provider, "entity_id", session.EntityID)
+	writeJSON(w, http.StatusOK, sessionResponse(session))
+}
+
+// sessionResponse converts a session for the response
+func sessionResponse(session auth.Session) SessionResponse {
+	return SessionResponse{
+		EntityID:         session.EntityID,
+		Name:             session.Identity.Name,
+		AccessToken:      session.AccessToken,
+		RefreshToken:     session.RefreshToken,
+		ExpiresAt:        session.ExpiresAt,
+		RefreshExpiresAt: session.RefreshExpiresAt,
+	}
+}
+
+// authStatus returns the HTTP status of an authentication error; errors other than
+// refused credentials or tokens come from the identity provider
+func authStatus(err error) int {
+	switch {
+	case errors.Is(err, auth.ErrUnknownProvider):
+		return http.StatusNotFound
+	case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrUnknownIdentity),
+		errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrTokenExpired):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// newState returns a random state for a redirect sign-in
+func newState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
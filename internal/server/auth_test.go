@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"goproduct/internal/auth"
+	"goproduct/internal/messaging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider accepts one password, or one code after a redirect to its sign-in page
+type fakeProvider struct {
+	name     string
+	loginURL string
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) Authenticate(ctx context.Context, credentials auth.Credentials) (auth.Identity, error) {
+	if credentials.Password == "s3cret" || credentials.Code == "good-code" {
+		return auth.Identity{Provider: p.name, Subject: "42", Name: "Alice"}, nil
+	}
+	return auth.Identity{}, auth.ErrInvalidCredentials
+}
+
+func (p *fakeProvider) AuthCodeURL(ctx context.Context, state string) (string, error) {
+	return p.loginURL + "?state=" + state, nil
+}
+
+// newTestAuth serves the sign-in endpoints and an API behind their sessions
+func newTestAuth(t *testing.T) (*httptest.Server, *fakeProvider) {
+	provider := &fakeProvider{name: "sso", loginURL: "https://sso.example.com/login"}
+	authenticator := auth.NewAuthenticator(auth.NewSessionManager(0, 0),
+		auth.IdentityMap{Identities: map[string]string{"sso:42": "alice"}}.Resolve, provider)
+
+	bus := messaging.NewMemoryMessageBus()
+	mux := http.NewServeMux()
+	mux.Handle("/api/auth/", NewAuthHandler(authenticator))
+	api := NewAPI(bus, "agent", nil, SessionAuthenticator(authenticator))
+	mux.Handle("/api/", api)
+	server := httptest.NewServer(mux)
+	t.Cleanup(func() { server.Close(); api.Close() })
+	return server, provider
+}
+
+// post makes a JSON request with an optional bearer token
+func post(t *testing.T, client *http.Client, target, token, body string) (*http.Response, SessionResponse) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var session SessionResponse
+	json.NewDecoder(resp.Body).Decode(&session)
+	return resp, session
+}
+
+func TestAuthLoginRefreshAndLogout(t *testing.T) {
+	server, _ := newTestAuth(t)
+	client := server.Client()
+
+	resp, _ := post(t, client, server.URL+"/api/auth/login", "", `{"provider":"sso","username":"alice","password":"wrong"}`)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp, _ = post(t, client, server.URL+"/api/auth/login", "", `{"provider":"ldap","username":"alice","password":"s3cret"}`)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, session := post(t, client, server.URL+"/api/auth/login", "", `{"provider":"sso","username":"alice","password":"s3cret"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "alice", session.EntityID)
+	assert.Equal(t, "Alice", session.Name)
+	require.NotEmpty(t, session.AccessToken)
+
+	// The API accepts the session's access token only
+	resp, _ = post(t, client, server.URL+"/api/messages", session.AccessToken, `{"content":"hello"}`)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	resp, _ = post(t, client, server.URL+"/api/messages", "secret", `{"content":"hello"}`)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, refreshed := post(t, client, server.URL+"/api/auth/refresh", "", `{"refresh_token":"`+session.RefreshToken+`"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEqual(t, session.AccessToken, refreshed.AccessToken)
+
+	resp, _ = post(t, client, server.URL+"/api/auth/logout", refreshed.AccessToken, "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = post(t, client, server.URL+"/api/messages", refreshed.AccessToken, `{"content":"hello"}`)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestAuthRedirectSignIn(t *testing.T) {
+	server, provider := newTestAuth(t)
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, err := client.Get(server.URL + "/api/auth/sso/start")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(location.String(), provider.loginURL))
+	state := location.Query().Get("state")
+	require.NotEmpty(t, state)
+
+	resp, err = client.Get(server.URL + "/api/auth/sso/callback?code=good-code&state=forged")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "the state must match the cookie")
+
+	resp, err = client.Get(server.URL + "/api/auth/sso/callback?code=good-code&state=" + state)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var session SessionResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&session))
+	assert.Equal(t, "alice", session.EntityID)
+}
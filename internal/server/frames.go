@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"goproduct/internal/messaging"
+)
+
+// Frame types exchanged with clients
+const (
+	FrameReady   = "ready"   // Sent once on connecting, naming the entity of the connection
+	FrameMessage = "message" // A message delivered to, or published by, the entity
+	FrameAck     = "ack"     // A message published by the client was accepted by the bus
+	FrameError   = "error"   // A frame from the client failed
+)
+
+// Frame is a JSON text frame on the WebSocket. Clients send message frames; the gateway
+// sends every type.
+type Frame struct {
+	Type     string        `json:"type"`
+	Message  *MessageFrame `json:"message,omitempty"`
+	EntityID string        `json:"entity_id,omitempty"` // Entity of the connection, for ready frames
+	Name     string        `json:"name,omitempty"`      // Display name of the entity, for ready frames
+	Ref      string        `json:"ref,omitempty"`       // ID of the message an ack or error answers
+	Error    string        `json:"error,omitempty"`
+}
+
+// MessageFrame is the JSON encoding of a messaging.Message. Text and JSON content is
+// carried as is; other content is base64 encoded, which Encoding then says.
+type MessageFrame struct {
+	ID            string            `json:"id,omitempty"`
+	SenderID      string            `json:"sender_id,omitempty"`
+	Recipients    []string          `json:"recipients"`
+	ContentType   string            `json:"content_type,omitempty"`
+	Content       string            `json:"content"`
+	Encoding      string            `json:"encoding,omitempty"`
+	ReplyToID     string            `json:"reply_to_id,omitempty"`
+	ReplyTo       string            `json:"reply_to,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Priority      int               `json:"priority,omitempty"`
+	ExpiresAt     time.Time         `json:"expires_at,omitzero"`
+	Timestamp     time.Time         `json:"timestamp,omitzero"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// encodingBase64 marks base64 encoded content
+const encodingBase64 = "base64"
+
+// encodeMessage returns the frame of a message
+func encodeMessage(msg messaging.Message) *MessageFrame {
+	frame := &MessageFrame{
+		ID:            msg.ID,
+		SenderID:      msg.SenderID,
+		Recipients:    msg.Recipients,
+		ContentType:   msg.ContentType,
+		ReplyToID:     msg.ReplyToID,
+		ReplyTo:       msg.ReplyTo,
+		CorrelationID: msg.CorrelationID,
+		Priority:      int(msg.Priority),
+		ExpiresAt:     msg.ExpiresAt,
+		Timestamp:     msg.Timestamp,
+		Metadata:      msg.Metadata,
+	}
+	if isTextual(msg.ContentType) && utf8.Valid(msg.Content) {
+		frame.Content = string(msg.Content)
+	} else {
+		frame.Content = base64.StdEncoding.EncodeToString(msg.Content)
+		frame.Encoding = encodingBase64
+	}
+	return frame
+}
+
+// decodeMessage returns the message a client publishes as the sender. The sender of the
+// frame is ignored, so clients cannot speak for other entities; a missing content type
+// defaults to plain text and a missing correlation ID is generated.
+func decodeMessage(senderID string, frame *MessageFrame) (messaging.Message, error) {
+	if len(frame.Recipients) == 0 {
+		return messaging.Message{}, fmt.Errorf("message has no recipients")
+	}
+	content := []byte(frame.Content)
+	switch frame.Encoding {
+	case "":
+	case encodingBase64:
+		var err error
+		if content, err = base64.StdEncoding.DecodeString(frame.Content); err != nil {
+			return messaging.Message{}, fmt.Errorf("invalid base64 content: %w", err)
+		}
+	default:
+		return messaging.Message{}, fmt.Errorf("unknown content encoding %q", frame.Encoding)
+	}
+	contentType := frame.ContentType
+	if contentType == "" {
+		contentType = messaging.ContentTypeText
+	}
+
+	msg := messaging.NewMessage(senderID, frame.Recipients, contentType, content)
+	if frame.ID != "" {
+		msg.ID = frame.ID
+	}
+	msg.ReplyToID = frame.ReplyToID
+	msg.ReplyTo = frame.ReplyTo
+	msg.CorrelationID = frame.CorrelationID
+	msg.Priority = messaging.Priority(frame.Priority)
+	msg.ExpiresAt = frame.ExpiresAt
+	for key, value := range frame.Metadata {
+		msg.Metadata[key] = value
+	}
+	return msg.WithCorrelation(), nil
+}
+
+// isTextual reports whether content of the type is text a browser can show as is
+func isTextual(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") || contentType == messaging.ContentTypeJSON
+}
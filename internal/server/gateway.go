@@ -0,0 +1,322 @@
+// Package server exposes the runtime to browser and web clients over HTTP. The Gateway
+// bridges WebSocket connections to the message bus: each authenticated connection acts
+// as a human entity, receiving its messages as JSON frames and publishing the messages
+// it sends. The API serves the product agent as a REST API for frontends that poll or
+// stream instead, and the AuthHandler signs users in for both.
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"goproduct/internal/auth"
+	"goproduct/internal/logging"
+	"goproduct/internal/messaging"
+)
+
+// connectionBuffer bounds the frames waiting to be written to a connection; when it is
+// full, deliveries to the connection fail and follow the bus retry rules
+const connectionBuffer = 256
+
+// ErrUnauthenticated is returned by authenticators for requests without credentials
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Principal is the entity a connection acts as
+type Principal struct {
+	EntityID string
+	Name     string // Display name, may be empty
+}
+
+// Authenticate establishes the principal of a connection request. Returning an error
+// refuses the connection with 401 Unauthorized.
+type Authenticate func(r *http.Request) (Principal, error)
+
+// SessionAuthenticator authenticates requests with the access token of an auth session
+func SessionAuthenticator(authenticator *auth.Authenticator) Authenticate {
+	return func(r *http.Request) (Principal, error) {
+		token := requestToken(r)
+		if token == "" {
+			return Principal{}, ErrUnauthenticated
+		}
+		session, err := authenticator.Validate(token)
+		if err != nil {
+			return Principal{}, err
+		}
+		return Principal{EntityID: session.EntityID, Name: session.Identity.Name}, nil
+	}
+}
+
+// TokenAuthenticator authenticates requests presenting the shared token as the principal,
+// for single-user deployments. An empty token accepts every request.
+func TokenAuthenticator(token string, principal Principal) Authenticate {
+	return func(r *http.Request) (Principal, error) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(requestToken(r)), []byte(token)) != 1 {
+			return Principal{}, ErrUnauthenticated
+		}
+		return principal, nil
+	}
+}
+
+// requestToken returns the bearer token of a request. Browsers cannot set headers on
+// WebSocket requests or event streams, so the token is taken from the access_token query
+// parameter when there is no bearer Authorization header.
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// Gateway serves WebSocket connections for human entities. An entity is subscribed on
+// the bus while it has a connection; an entity may have several connections, e.g. one per
+// browser tab, which all receive its messages.
+type Gateway struct {
+	bus            messaging.MessageBus
+	authenticate   Authenticate
+	allowedOrigins map[string]bool
+	entities       map[string]map[*connection]bool // Open connections by entity
+	logger         *logging.Logger
+	mu             sync.Mutex // Protects entities
+}
+
+// connection is an open WebSocket connection of an entity
+type connection struct {
+	ws        *wsConn
+	principal Principal
+	frames    chan Frame
+	done      chan struct{} // Closed when the connection is detached
+	stopped   chan struct{} // Closed when the writer stops
+}
+
+// GatewayOption configures a Gateway
+type GatewayOption func(*Gateway)
+
+// WithAllowedOrigins accepts connections from pages of the origins, e.g.
+// "https://app.example.com", in addition to pages served by the gateway's own host
+func WithAllowedOrigins(origins ...string) GatewayOption {
+	return func(g *Gateway) {
+		for _, origin := range origins {
+			g.allowedOrigins[strings.ToLower(origin)] = true
+		}
+	}
+}
+
+// NewGateway creates a gateway that connects the clients authenticate accepts to the bus
+func NewGateway(bus messaging.MessageBus, authenticate Authenticate, opts ...GatewayOption) *Gateway {
+	g := &Gateway{
+		bus:            bus,
+		authenticate:   authenticate,
+		allowedOrigins: make(map[string]bool),
+		entities:       make(map[string]map[*connection]bool),
+		logger:         logging.Get().Component("server"),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// ServeHTTP authenticates a WebSocket request and serves the connection until it closes
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !g.originAllowed(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	principal, err := g.authenticate(r)
+	if err != nil || principal.EntityID == "" {
+		g.logger.Warn("WebSocket connection refused", "remote", r.RemoteAddr, "error", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ws, err := upgrade(w, r)
+	if err != nil {
+		g.logger.Debug("WebSocket handshake failed", "remote", r.RemoteAddr, "error", err)
+		return
+	}
+
+	c := &connection{ws: ws, principal: principal, frames: make(chan Frame, connectionBuffer), done: make(chan struct{}), stopped: make(chan struct{})}
+	c.frames <- Frame{Type: FrameReady, EntityID: principal.EntityID, Name: principal.Name}
+	if err := g.attach(c); err != nil {
+		g.logger.Error("Failed to subscribe WebSocket entity", "entity_id", principal.EntityID, "error", err)
+		ws.close(closeGoingAway, "subscription failed")
+		return
+	}
+	g.logger.Info("WebSocket client connected", "entity_id", principal.EntityID, "remote", r.RemoteAddr)
+	defer func() {
+		g.detach(c)
+		close(c.done)
+		ws.close(closeNormal, "")
+		g.logger.Info("WebSocket client disconnected", "entity_id", principal.EntityID)
+	}()
+
+	go g.write(c)
+	g.read(c)
+}
+
+// Close disconnects every client
+func (g *Gateway) Close() error {
+	g.mu.Lock()
+	var connections []*connection
+	for _, entityConnections := range g.entities {
+		for c := range entityConnections {
+			connections = append(connections, c)
+		}
+	}
+	g.mu.Unlock()
+
+	for _, c := range connections {
+		c.ws.close(closeGoingAway, "server shutting down")
+	}
+	return nil
+}
+
+// Connected returns the number of open connections of an entity
+func (g *Gateway) Connected(entityID string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.entities[entityID])
+}
+
+// originAllowed reports whether a request from a browser page may connect. Requests
+// without an Origin header do not come from a page and are allowed.
+func (g *Gateway) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if g.allowedOrigins[strings.ToLower(origin)] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// attach registers a connection, subscribing its entity on the bus if it is the first
+func (g *Gateway) attach(c *connection) error {
+	entityID := c.principal.EntityID
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if connections, ok := g.entities[entityID]; ok {
+		connections[c] = true
+		return nil
+	}
+	if err := g.bus.Subscribe(entityID, g.deliver(entityID)); err != nil {
+		return err
+	}
+	g.entities[entityID] = map[*connection]bool{c: true}
+	return nil
+}
+
+// detach removes a connection, unsubscribing its entity from the bus if it was the last
+func (g *Gateway) detach(c *connection) {
+	entityID := c.principal.EntityID
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	connections := g.entities[entityID]
+	delete(connections, c)
+	if len(connections) > 0 {
+		return
+	}
+	delete(g.entities, entityID)
+	if err := g.bus.Unsubscribe(entityID); err != nil {
+		g.logger.Error("Failed to unsubscribe WebSocket entity", "entity_id", entityID, "error", err)
+	}
+}
+
+// deliver returns the bus handler of an entity, which queues its messages on every
+// connection of the entity. Delivery fails only if no connection could take the message.
+func (g *Gateway) deliver(entityID string) messaging.MessageHandler {
+	return func(msg messaging.Message) error {
+		frame := Frame{Type: FrameMessage, Message: encodeMessage(msg)}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		queued := false
+		for c := range g.entities[entityID] {
+			select {
+			case c.frames <- frame:
+				queued = true
+			default:
+				g.logger.Warn("WebSocket connection is full, dropping message", "entity_id", entityID, "message_id", msg.ID)
+			}
+		}
+		if !queued {
+			return fmt.Errorf("no WebSocket connection of %s could take the message", entityID)
+		}
+		return nil
+	}
+}
+
+// read publishes the messages the client sends until the connection closes
+func (g *Gateway) read(c *connection) {
+	for {
+		opcode, payload, err := c.ws.readMessage()
+		if err != nil {
+			return
+		}
+		if opcode != opText {
+			c.ws.close(closeUnsupportedData, "text frames only")
+			return
+		}
+		if !utf8.Valid(payload) {
+			c.ws.close(closeInvalidPayload, "invalid UTF-8")
+			return
+		}
+		g.reply(c, g.handle(c, payload))
+	}
+}
+
+// handle publishes the message of a client frame and returns the ack or error frame
+func (g *Gateway) handle(c *connection, payload []byte) Frame {
+	var frame Frame
+	if err := json.Unmarshal(payload, &frame); err != nil {
+		return Frame{Type: FrameError, Error: fmt.Sprintf("invalid frame: %v", err)}
+	}
+	if frame.Type != FrameMessage || frame.Message == nil {
+		return Frame{Type: FrameError, Error: fmt.Sprintf("unsupported frame type %q", frame.Type)}
+	}
+	msg, err := decodeMessage(c.principal.EntityID, frame.Message)
+	if err != nil {
+		return Frame{Type: FrameError, Ref: frame.Message.ID, Error: err.Error()}
+	}
+	if err := g.bus.Publish(msg); err != nil {
+		g.logger.Error("Failed to publish WebSocket message", "entity_id", c.principal.EntityID, "message_id", msg.ID, "error", err)
+		return Frame{Type: FrameError, Ref: msg.ID, Error: err.Error()}
+	}
+	return Frame{Type: FrameAck, Ref: msg.ID}
+}
+
+// reply queues a frame answering the client, waiting for room if the connection is busy
+func (g *Gateway) reply(c *connection, frame Frame) {
+	select {
+	case c.frames <- frame:
+	case <-c.stopped:
+	}
+}
+
+// write sends the queued frames of a connection until it closes
+func (g *Gateway) write(c *connection) {
+	defer close(c.stopped)
+	for {
+		select {
+		case <-c.done:
+			return
+		case frame := <-c.frames:
+			payload, err := json.Marshal(frame)
+			if err != nil {
+				g.logger.Error("Failed to encode WebSocket frame", "entity_id", c.principal.EntityID, "error", err)
+				continue
+			}
+			if err := c.ws.writeFrame(opText, payload); err != nil {
+				c.ws.close(closeGoingAway, "")
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"goproduct/internal/messaging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenAuth accepts "token-<entity>" bearer tokens and access_token parameters
+func tokenAuth(r *http.Request) (Principal, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("access_token")
+	}
+	entityID, ok := strings.CutPrefix(token, "token-")
+	if !ok {
+		return Principal{}, ErrUnauthenticated
+	}
+	return Principal{EntityID: entityID, Name: strings.ToUpper(entityID)}, nil
+}
+
+// newTestGateway serves a gateway for a memory bus
+func newTestGateway(t *testing.T, opts ...GatewayOption) (*messaging.MemoryMessageBus, *Gateway, string) {
+	bus := messaging.NewMemoryMessageBus()
+	gateway := NewGateway(bus, tokenAuth, opts...)
+	server := httptest.NewServer(gateway)
+	t.Cleanup(func() { gateway.Close(); server.Close() })
+	return bus, gateway, server.Listener.Addr().String()
+}
+
+// dial opens a client connection to the gateway, returning the HTTP status on failure
+func dial(t *testing.T, addr string, header http.Header) (*wsConn, int) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	request := "GET /?x=1 HTTP/1.1\r\nHost: " + addr + "\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n"
+	for name, values := range header {
+		request += name + ": " + values[0] + "\r\n"
+	}
+	_, err = fmt.Fprint(conn, request+"\r\n")
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, resp.StatusCode
+	}
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+	ws := &wsConn{conn: conn, reader: reader, mask: true}
+	t.Cleanup(func() { ws.close(closeNormal, "") })
+	return ws, resp.StatusCode
+}
+
+// bearer returns the Authorization header of an entity
+func bearer(entityID string) http.Header {
+	return http.Header{"Authorization": {"Bearer token-" + entityID}}
+}
+
+// readJSON reads the next frame from the gateway
+func readJSON(t *testing.T, ws *wsConn) Frame {
+	t.Helper()
+	ws.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	opcode, payload, err := ws.readMessage()
+	require.NoError(t, err)
+	require.Equal(t, byte(opText), opcode)
+	var frame Frame
+	require.NoError(t, json.Unmarshal(payload, &frame))
+	return frame
+}
+
+// sendJSON sends a frame to the gateway
+func sendJSON(t *testing.T, ws *wsConn, frame Frame) {
+	t.Helper()
+	payload, err := json.Marshal(frame)
+	require.NoError(t, err)
+	require.NoError(t, ws.writeFrame(opText, payload))
+}
+
+func TestGatewayBridgesMessages(t *testing.T) {
+	bus, gateway, addr := newTestGateway(t)
+	received := make(chan messaging.Message, 1)
+	require.NoError(t, bus.Subscribe("agent", func(msg messaging.Message) error { received <- msg; return nil }))
+
+	ws, _ := dial(t, addr, bearer("carol"))
+	ready := readJSON(t, ws)
+	assert.Equal(t, Frame{Type: FrameReady, EntityID: "carol", Name: "CAROL"}, ready)
+	assert.Equal(t, 1, gateway.Connected("carol"))
+
+	// The client publishes as its own entity, whatever sender it claims
+	sendJSON(t, ws, Frame{Type: FrameMessage, Message: &MessageFrame{ID: "m1", SenderID: "mallory", Recipients: []string{"agent"}, Content: "hello"}})
+	assert.Equal(t, Frame{Type: FrameAck, Ref: "m1"}, readJSON(t, ws))
+	msg := <-received
+	assert.Equal(t, "carol", msg.SenderID)
+	assert.Equal(t, messaging.ContentTypeText, msg.ContentType)
+	assert.Equal(t, "hello", string(msg.Content))
+
+	// Messages for the entity arrive as frames
+	reply := messaging.NewTextReplyMessage("agent", msg, "hi carol")
+	require.NoError(t, bus.Publish(reply))
+	frame := readJSON(t, ws)
+	require.Equal(t, FrameMessage, frame.Type)
+	assert.Equal(t, reply.ID, frame.Message.ID)
+	assert.Equal(t, "agent", frame.Message.SenderID)
+	assert.Equal(t, "hi carol", frame.Message.Content)
+	assert.Equal(t, "m1", frame.Message.ReplyToID)
+	assert.Empty(t, frame.Message.Encoding)
+
+	// Binary content is base64 encoded
+	require.NoError(t, bus.Publish(messaging.NewMessage("agent", []string{"carol"}, "image/png", []byte{0x89, 'P', 'N', 'G'})))
+	frame = readJSON(t, ws)
+	assert.Equal(t, encodingBase64, frame.Message.Encoding)
+	decoded, err := decodeMessage("carol", frame.Message)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G'}, decoded.Content)
+}
+
+func TestGatewayRejectsInvalidFrames(t *testing.T) {
+	_, _, addr := newTestGateway(t)
+	ws, _ := dial(t, addr, bearer("carol"))
+	readJSON(t, ws)
+
+	require.NoError(t, ws.writeFrame(opText, []byte("not json")))
+	assert.Equal(t, FrameError, readJSON(t, ws).Type)
+
+	sendJSON(t, ws, Frame{Type: FrameMessage, Message: &MessageFrame{ID: "m2", Content: "to nobody"}})
+	frame := readJSON(t, ws)
+	assert.Equal(t, FrameError, frame.Type)
+	assert.Equal(t, "m2", frame.Ref)
+	assert.Contains(t, frame.Error, "no recipients")
+
+	// Binary frames end the connection
+	require.NoError(t, ws.writeFrame(opBinary, []byte{1, 2, 3}))
+	ws.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := ws.readMessage()
+	assert.True(t, errors.Is(err, errClosed))
+}
+
+func TestGatewayAuthenticationAndOrigins(t *testing.T) {
+	_, _, addr := newTestGateway(t, WithAllowedOrigins("https://app.example.com"))
+
+	_, status := dial(t, addr, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	_, status = dial(t, addr, http.Header{"Authorization": {"Bearer wrong"}})
+	assert.Equal(t, http.StatusUnauthorized, status)
+	_, status = dial(t, addr, http.Header{"Authorization": {"Bearer token-carol"}, "Origin": {"https://evil.example.com"}})
+	assert.Equal(t, http.StatusForbidden, status)
+
+	_, status = dial(t, addr, http.Header{"Authorization": {"Bearer token-carol"}, "Origin": {"https://app.example.com"}})
+	assert.Equal(t, http.StatusSwitchingProtocols, status)
+	_, status = dial(t, addr, http.Header{"Authorization": {"Bearer token-dave"}, "Origin": {"http://" + addr}})
+	assert.Equal(t, http.StatusSwitchingProtocols, status)
+}
+
+func TestGatewayFansOutToConnectionsOfAnEntity(t *testing.T) {
+	bus, gateway, addr := newTestGateway(t)
+	first, _ := dial(t, addr, bearer("carol"))
+	readJSON(t, first)
+	second, _ := dial(t, addr, bearer("carol"))
+	readJSON(t, second)
+	assert.Equal(t, 2, gateway.Connected("carol"))
+
+	require.NoError(t, bus.Publish(messaging.NewTextMessage("agent", []string{"carol"}, "both tabs")))
+	assert.Equal(t, "both tabs", readJSON(t, first).Message.Content)
+	assert.Equal(t, "both tabs", readJSON(t, second).Message.Content)
+
+	// The entity stays subscribed until its last connection closes
+	first.close(closeNormal, "")
+	require.Eventually(t, func() bool { return gateway.Connected("carol") == 1 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, bus.Publish(messaging.NewTextMessage("agent", []string{"carol"}, "one tab")))
+	assert.Equal(t, "one tab", readJSON(t, second).Message.Content)
+
+	orphaned := make(chan messaging.Message, 10)
+	require.NoError(t, bus.SubscribePattern("carol", messaging.PatternFallback, func(msg messaging.Message) error { orphaned <- msg; return nil }))
+	second.close(closeNormal, "")
+	require.Eventually(t, func() bool {
+		bus.Publish(messaging.NewTextMessage("agent", []string{"carol"}, "gone"))
+		return len(orphaned) > 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"sync"
+
+	"goproduct/internal/messaging"
+)
+
+// inboxCapacity is the number of recent messages an inbox keeps for polling clients
+const inboxCapacity = 100
+
+// InboxMessage is a message received by an entity, numbered in the order it arrived
+type InboxMessage struct {
+	Seq     uint64        `json:"seq"`
+	Message *MessageFrame `json:"message"`
+}
+
+// inbox keeps the recent messages of an entity for HTTP clients that poll or stream them
+type inbox struct {
+	messages []InboxMessage // Most recent messages, oldest first
+	seq      uint64         // Sequence number of the last message
+	arrived  chan struct{}  // Closed, and replaced, when a message arrives
+	mu       sync.Mutex
+}
+
+// newInbox creates an empty inbox
+func newInbox() *inbox {
+	return &inbox{arrived: make(chan struct{})}
+}
+
+// add stores a message, dropping the oldest one if the inbox is full, and wakes waiters
+func (b *inbox) add(msg messaging.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	b.messages = append(b.messages, InboxMessage{Seq: b.seq, Message: encodeMessage(msg)})
+	if len(b.messages) > inboxCapacity {
+		b.messages = b.messages[len(b.messages)-inboxCapacity:]
+	}
+	close(b.arrived)
+	b.arrived = make(chan struct{})
+}
+
+// after returns the messages after the sequence number and a channel closed when the next
+// message arrives
+func (b *inbox) after(seq uint64) ([]InboxMessage, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var messages []InboxMessage
+	for _, m := range b.messages {
+		if m.Seq > seq {
+			messages = append(messages, m)
+		}
+	}
+	return messages, b.arrived
+}
+
+// last returns the sequence number of the last message
+func (b *inbox) last() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq
+}
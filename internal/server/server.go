@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"goproduct/internal/logging"
+)
+
+// shutdownTimeout bounds how long Close waits for requests in flight
+const shutdownTimeout = 5 * time.Second
+
+// Server serves an HTTP handler on a TCP address
+type Server struct {
+	addr     string
+	handler  http.Handler
+	server   *http.Server
+	listener net.Listener
+	logger   *logging.Logger
+	mu       sync.Mutex // Protects server and listener
+}
+
+// NewServer creates a server for the handler on the TCP address, e.g. ":8080"
+func NewServer(addr string, handler http.Handler) *Server {
+	return &Server{addr: addr, handler: handler, logger: logging.Get().Component("server")}
+}
+
+// Start listens on the address and serves requests until Close is called
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	server := &http.Server{Handler: s.handler, ReadHeaderTimeout: 10 * time.Second}
+
+	s.mu.Lock()
+	s.server, s.listener = server, listener
+	s.mu.Unlock()
+
+	s.logger.Info("HTTP server listening", "address", listener.Addr().String())
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("HTTP server stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on, or "" before Start
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Close stops accepting requests and waits briefly for those in flight. Hijacked
+// connections, such as WebSockets, are not tracked and must be closed by their handler.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	server := s.server
+	s.server, s.listener = nil, nil
+	s.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return server.Close()
+	}
+	return nil
+}
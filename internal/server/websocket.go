@@ -0,0 +1,303 @@
+package server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is appended to the client key to compute the accept key, see RFC 6455
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessageSize bounds a message received from a client, fragments included
+const maxMessageSize = 1 << 20
+
+// writeTimeout bounds how long writing a frame to a client may take
+const writeTimeout = 10 * time.Second
+
+// WebSocket opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// WebSocket close codes
+const (
+	closeNormal          = 1000
+	closeGoingAway       = 1001
+	closeProtocolError   = 1002
+	closeUnsupportedData = 1003
+	closeInvalidPayload  = 1007
+	closeTooBig          = 1009
+)
+
+// errClosed is returned by readMessage when the peer closed the connection
+var errClosed = errors.New("websocket closed")
+
+// closeError is a protocol violation that ends the connection with the close code
+type closeError struct {
+	code   int
+	reason string
+}
+
+// Error implements error
+func (e *closeError) Error() string {
+	return fmt.Sprintf("websocket error %d: %s", e.code, e.reason)
+}
+
+// wsConn is a WebSocket connection. Reads must come from one goroutine; writes may come
+// from any.
+type wsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mask   bool       // Whether frames written are masked, as clients must
+	mu     sync.Mutex // Serializes writes
+	closed bool       // Whether a close frame was written
+}
+
+// acceptKey returns the Sec-WebSocket-Accept value for a client key
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains reports whether a comma separated header holds the token
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgrade completes the opening handshake of a WebSocket request and takes over its
+// connection. It replies with an HTTP error itself when the request is not a valid
+// WebSocket handshake.
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	switch {
+	case r.Method != http.MethodGet:
+		http.Error(w, "WebSocket handshakes must use GET", http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("handshake with method %s", r.Method)
+	case !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket"):
+		http.Error(w, "WebSocket connections only", http.StatusUpgradeRequired)
+		return nil, errors.New("request is not a WebSocket upgrade")
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported WebSocket version")
+	case key == "":
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket connections are not supported", http.StatusInternalServerError)
+		return nil, errors.New("response does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take over connection: %w", err)
+	}
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := io.WriteString(conn, response); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to complete handshake: %w", err)
+	}
+	conn.SetDeadline(time.Time{})
+	return &wsConn{conn: conn, reader: rw.Reader}, nil
+}
+
+// readMessage returns the next text or binary message, reassembling fragments and
+// answering pings. It returns errClosed once the peer closes the connection; protocol
+// violations close it with the matching code.
+func (c *wsConn) readMessage() (opcode byte, payload []byte, err error) {
+	for {
+		fin, op, data, err := c.readFrame()
+		if err != nil {
+			var protocolErr *closeError
+			if errors.As(err, &protocolErr) {
+				c.close(protocolErr.code, protocolErr.reason)
+			}
+			return 0, nil, err
+		}
+
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, data); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code := closeNormal
+			if len(data) >= 2 {
+				code = int(binary.BigEndian.Uint16(data))
+			}
+			c.close(code, "")
+			return 0, nil, errClosed
+		case opContinuation:
+			if opcode == 0 {
+				c.close(closeProtocolError, "unexpected continuation frame")
+				return 0, nil, errors.New("unexpected continuation frame")
+			}
+		case opText, opBinary:
+			if opcode != 0 {
+				c.close(closeProtocolError, "expected continuation frame")
+				return 0, nil, errors.New("expected continuation frame")
+			}
+			opcode = op
+		default:
+			c.close(closeProtocolError, "unknown opcode")
+			return 0, nil, fmt.Errorf("unknown opcode %d", op)
+		}
+
+		if len(payload)+len(data) > maxMessageSize {
+			c.close(closeTooBig, "message too big")
+			return 0, nil, errors.New("message too big")
+		}
+		payload = append(payload, data...)
+		if fin {
+			return opcode, payload, nil
+		}
+	}
+}
+
+// readFrame reads one frame and unmasks its payload
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = header[0]&0x80 != 0, header[0]&0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, &closeError{closeProtocolError, "reserved bits set"}
+	}
+	masked := header[1]&0x80 != 0
+	if masked == c.mask {
+		return false, 0, nil, &closeError{closeProtocolError, "wrong frame masking"}
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if opcode >= opClose && (length > 125 || !fin) {
+		return false, 0, nil, &closeError{closeProtocolError, "invalid control frame"}
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, &closeError{closeTooBig, "message too big"}
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, key[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		maskBytes(key, payload)
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unfragmented frame
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errClosed
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+// writeFrameLocked writes a frame while holding the write lock
+func (c *wsConn) writeFrameLocked(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+	var maskBit byte
+	if c.mask {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	if c.mask {
+		var key [4]byte
+		rand.Read(key[:])
+		frame = append(frame, key[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(key, frame[start:])
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// close sends a close frame with the code, once, and closes the connection
+func (c *wsConn) close(code int, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+		c.writeFrameLocked(opClose, append(payload, reason...))
+	}
+	return c.conn.Close()
+}
+
+// maskBytes masks or unmasks a payload with the key
+func maskBytes(key [4]byte, payload []byte) {
+	for i := range payload {
+		payload[i] ^= key[i%4]
+	}
+}
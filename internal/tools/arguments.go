@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+)
+
+// Arguments are the arguments of a tool call, as decoded from JSON
+type Arguments map[string]interface{}
+
+// String returns a string argument, or "" if it is missing
+func (a Arguments) String(name string) (string, error) {
+	value, ok := a[name]
+	if !ok || value == nil {
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %s must be a string", name)
+	}
+	return s, nil
+}
+
+// Int returns an integer argument, or the fallback if it is missing
+func (a Arguments) Int(name string, fallback int) (int, error) {
+	value, ok := a[name]
+	if !ok || value == nil {
+		return fallback, nil
+	}
+	f, ok := value.(float64)
+	if !ok || f != math.Trunc(f) {
+		return 0, fmt.Errorf("argument %s must be an integer", name)
+	}
+	return int(f), nil
+}
+
+// Strings returns a string array argument, or nil if it is missing. A single string is
+// taken as an array of one.
+func (a Arguments) Strings(name string) ([]string, error) {
+	value, ok := a[name]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		strings := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("argument %s must be an array of strings", name)
+			}
+			strings = append(strings, s)
+		}
+		return strings, nil
+	default:
+		return nil, fmt.Errorf("argument %s must be an array of strings", name)
+	}
+}
@@ -0,0 +1,216 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"goproduct/internal/knowledge"
+)
+
+// Knowledge tool names
+const (
+	ToolRememberFact = "remember_fact"
+	ToolRecall       = "recall"
+	ToolUpdateFact   = "update_fact"
+	ToolForget       = "forget"
+)
+
+// RememberedTag tags the facts agents store with remember_fact
+const RememberedTag = "remembered"
+
+// maxRecallResults bounds the results recall returns
+const maxRecallResults = 20
+
+// KnowledgeTools returns tools letting an agent search the store and keep its facts up to
+// date during a conversation. Agents may only change and forget facts, never messages,
+// decisions or actions.
+func KnowledgeTools(store knowledge.Store) []Tool {
+	k := knowledgeTools{store: store}
+	return []Tool{
+		{
+			Name:        ToolRememberFact,
+			Description: "Store a fact worth remembering in future conversations, e.g. a decision, preference or deadline",
+			Parameters: []Parameter{
+				{Name: "content", Type: TypeString, Description: "The fact, as a complete sentence", Required: true},
+				{Name: "title", Type: TypeString, Description: "A short title for the fact"},
+				{Name: "tags", Type: TypeArray, Description: "Keywords to find the fact by"},
+			},
+			Handler: k.remember,
+		},
+		{
+			Name:        ToolRecall,
+			Description: "Search stored knowledge; results start with the ID to update or forget an entry by",
+			Parameters: []Parameter{
+				{Name: "query", Type: TypeString, Description: "What to search for", Required: true},
+				{Name: "limit", Type: TypeInteger, Description: "Most results to return, 5 by default"},
+			},
+			Handler: k.recall,
+		},
+		{
+			Name:        ToolUpdateFact,
+			Description: "Replace the content of a stored fact that changed",
+			Parameters: []Parameter{
+				{Name: "id", Type: TypeString, Description: "ID of the fact, from recall", Required: true},
+				{Name: "content", Type: TypeString, Description: "The new content of the fact", Required: true},
+			},
+			Handler: k.update,
+		},
+		{
+			Name:        ToolForget,
+			Description: "Delete a stored fact that is wrong or no longer true",
+			Parameters: []Parameter{
+				{Name: "id", Type: TypeString, Description: "ID of the fact, from recall", Required: true},
+			},
+			Handler: k.forget,
+		},
+	}
+}
+
+// knowledgeTools implements the knowledge tools on a store
+type knowledgeTools struct {
+	store knowledge.Store
+}
+
+// provenance returns the provenance step of a change made by the calling agent
+func provenance(caller Caller) knowledge.ProvenanceStep {
+	return knowledge.ProvenanceStep{
+		Origin:         knowledge.OriginGeneration,
+		ActorID:        caller.AgentName,
+		ActorType:      "agent",
+		ConversationID: caller.ConversationID,
+		MessageID:      caller.MessageID,
+		Timestamp:      time.Now(),
+		Details:        map[string]string{"tool": "true"},
+	}
+}
+
+// remember serves remember_fact
+func (k knowledgeTools) remember(ctx context.Context, args Arguments) (string, error) {
+	content, err := args.String("content")
+	if err != nil {
+		return "", err
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", errors.New("content cannot be empty")
+	}
+	title, err := args.String("title")
+	if err != nil {
+		return "", err
+	}
+	tags, err := args.Strings("tags")
+	if err != nil {
+		return "", err
+	}
+
+	caller := CallerFrom(ctx)
+	now := time.Now()
+	entry := knowledge.Entry{
+		ID:          uuid.New().String(),
+		Category:    knowledge.CategoryFact,
+		ContentType: knowledge.ContentTypeText,
+		Content:     []byte(content),
+		Importance:  knowledge.ImportanceMedium,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		SourceID:    caller.SenderID,
+		SourceType:  "chat",
+		OwnerID:     caller.AgentName,
+		OwnerType:   "agent",
+		SubjectIDs:  []string{},
+		Tags:        append([]string{RememberedTag}, tags...),
+		References:  []knowledge.Reference{},
+		Metadata:    map[string]string{},
+		Provenance:  []knowledge.ProvenanceStep{provenance(caller)},
+	}
+	if title != "" {
+		entry.Metadata["title"] = title
+	}
+	if err := k.store.AddRecord(entry); err != nil {
+		return "", fmt.Errorf("failed to remember fact: %w", err)
+	}
+	return fmt.Sprintf("Remembered as %s", entry.ID), k.store.Flush()
+}
+
+// recall serves recall
+func (k knowledgeTools) recall(ctx context.Context, args Arguments) (string, error) {
+	query, err := args.String("query")
+	if err != nil {
+		return "", err
+	}
+	limit, err := args.Int("limit", 5)
+	if err != nil {
+		return "", err
+	}
+	limit = max(1, min(limit, maxRecallResults))
+
+	results, err := k.store.FullTextSearch(query, knowledge.WithSearchLimit(limit))
+	if err != nil {
+		return "", fmt.Errorf("failed to search knowledge: %w", err)
+	}
+	if len(results) == 0 {
+		return "Nothing found", nil
+	}
+	var sb strings.Builder
+	for _, result := range results {
+		fmt.Fprintf(&sb, "[%s] (%s, updated %s) %s\n", result.Entry.ID, result.Entry.Category,
+			result.Entry.UpdatedAt.Format("2006-01-02"), strings.TrimSpace(string(result.Entry.Content)))
+	}
+	return sb.String(), nil
+}
+
+// fact returns the fact with the ID in the arguments, refusing other categories
+func (k knowledgeTools) fact(args Arguments) (knowledge.Entry, error) {
+	id, err := args.String("id")
+	if err != nil {
+		return knowledge.Entry{}, err
+	}
+	entry, err := k.store.GetRecord(id)
+	if err != nil {
+		return knowledge.Entry{}, fmt.Errorf("no entry %s: %w", id, err)
+	}
+	if entry.Category != knowledge.CategoryFact {
+		return knowledge.Entry{}, fmt.Errorf("entry %s is a %s, only facts can be changed", id, entry.Category)
+	}
+	return entry, nil
+}
+
+// update serves update_fact
+func (k knowledgeTools) update(ctx context.Context, args Arguments) (string, error) {
+	entry, err := k.fact(args)
+	if err != nil {
+		return "", err
+	}
+	content, err := args.String("content")
+	if err != nil {
+		return "", err
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", errors.New("content cannot be empty")
+	}
+	entry.Content = []byte(content)
+	entry.UpdatedAt = time.Now()
+	entry.Provenance = append(entry.Provenance, provenance(CallerFrom(ctx)))
+	if err := k.store.UpdateRecord(entry); err != nil {
+		return "", fmt.Errorf("failed to update fact: %w", err)
+	}
+	return fmt.Sprintf("Updated %s", entry.ID), k.store.Flush()
+}
+
+// forget serves forget; facts are soft deleted so they can be restored
+func (k knowledgeTools) forget(ctx context.Context, args Arguments) (string, error) {
+	entry, err := k.fact(args)
+	if err != nil {
+		return "", err
+	}
+	if err := k.store.DeleteRecord(entry.ID); err != nil {
+		return "", fmt.Errorf("failed to forget fact: %w", err)
+	}
+	return fmt.Sprintf("Forgot %s", entry.ID), k.store.Flush()
+}
@@ -0,0 +1,214 @@
+// Package tools lets agents act during a conversation. Tools are described to the
+// language model in the system prompt, and the model calls one by replying with a
+// tagged JSON block:
+//
+//	<tool_call>{"name": "recall", "arguments": {"query": "release date"}}</tool_call>
+//
+// The agent runs the tool and hands the result back to the model in a tool_result block.
+// The protocol is plain text, so it works with every provider, including those without
+// native function calling.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Parameter types
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeArray   = "array" // An array of strings
+)
+
+// ErrUnknownTool is returned when a call names a tool that is not registered
+var ErrUnknownTool = errors.New("unknown tool")
+
+// Parameter describes an argument of a tool
+type Parameter struct {
+	Name        string
+	Type        string // TypeString, TypeInteger, TypeBoolean or TypeArray
+	Description string
+	Required    bool
+}
+
+// Handler runs a tool with its arguments and returns the result shown to the model
+type Handler func(ctx context.Context, args Arguments) (string, error)
+
+// Tool is an action an agent can take
+type Tool struct {
+	Name        string      // Name the model calls the tool by, e.g. "recall"
+	Description string      // One-line description shown to the model
+	Parameters  []Parameter // Arguments of the tool
+	Handler     Handler     // Runs the tool
+}
+
+// Call is a tool call requested by the model
+type Call struct {
+	Name      string    `json:"name"`
+	Arguments Arguments `json:"arguments"`
+}
+
+// Caller identifies who a tool runs for; handlers read it from the context
+type Caller struct {
+	AgentName      string // Agent calling the tool
+	SenderID       string // Entity whose message the agent is answering
+	MessageID      string // Message the agent is answering
+	ConversationID string // Conversation of the message, if known
+}
+
+// callerKey is the context key of the Caller
+type callerKey struct{}
+
+// WithCaller returns a context carrying the caller of the tools run with it
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFrom returns the caller carried by the context, or a zero Caller
+func CallerFrom(ctx context.Context) Caller {
+	caller, _ := ctx.Value(callerKey{}).(Caller)
+	return caller
+}
+
+// Registry holds the tools available to an agent
+type Registry struct {
+	tools map[string]Tool
+	mu    sync.RWMutex // Protects tools
+}
+
+// NewRegistry creates a registry with the tools
+func NewRegistry(tools ...Tool) *Registry {
+	r := &Registry{tools: make(map[string]Tool)}
+	for _, tool := range tools {
+		r.Register(tool)
+	}
+	return r
+}
+
+// Register adds a tool, replacing any tool with the same name
+func (r *Registry) Register(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Name] = tool
+}
+
+// Get returns the tool with the name
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// List returns the tools sorted by name
+func (r *Registry) List() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// Call runs the tool a call names after checking its required arguments
+func (r *Registry) Call(ctx context.Context, call Call) (string, error) {
+	tool, ok := r.Get(call.Name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownTool, call.Name)
+	}
+	for _, param := range tool.Parameters {
+		if _, present := call.Arguments[param.Name]; param.Required && !present {
+			return "", fmt.Errorf("%s needs the %s argument", tool.Name, param.Name)
+		}
+	}
+	if call.Arguments == nil {
+		call.Arguments = Arguments{}
+	}
+	return tool.Handler(ctx, call.Arguments)
+}
+
+// Prompt returns the system prompt section describing the tools and how to call them,
+// or "" if there are none
+func (r *Registry) Prompt() string {
+	tools := r.List()
+	if len(tools) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n# Tools\n")
+	sb.WriteString("You can use these tools. To use one, reply with only a tool call and wait for its result:\n")
+	sb.WriteString(`<tool_call>{"name": "<tool>", "arguments": {<arguments>}}</tool_call>` + "\n")
+	sb.WriteString("Answer the user normally once you have what you need.\n\n")
+	sb.WriteString(r.Describe())
+	return sb.String()
+}
+
+// Describe returns the list of the tools with their parameters, for prompts
+func (r *Registry) Describe() string {
+	var sb strings.Builder
+	for _, tool := range r.List() {
+		fmt.Fprintf(&sb, "- %s: %s\n", tool.Name, tool.Description)
+		for _, param := range tool.Parameters {
+			required := "optional"
+			if param.Required {
+				required = "required"
+			}
+			fmt.Fprintf(&sb, "  - %s (%s, %s): %s\n", param.Name, param.Type, required, param.Description)
+		}
+	}
+	return sb.String()
+}
+
+// Tool call protocol tags
+const (
+	callOpen    = "<tool_call>"
+	callClose   = "</tool_call>"
+	resultClose = "</tool_result>"
+)
+
+// ParseCall finds the first tool call in a model response. It returns the call and
+// whether the response holds one; a malformed call is reported as an error so the model
+// can be told to fix it.
+func ParseCall(response string) (Call, bool, error) {
+	start := strings.Index(response, callOpen)
+	if start < 0 {
+		return Call{}, false, nil
+	}
+	body := response[start+len(callOpen):]
+	if end := strings.Index(body, callClose); end >= 0 {
+		body = body[:end]
+	}
+	var call Call
+	if err := json.Unmarshal([]byte(strings.TrimSpace(body)), &call); err != nil {
+		return Call{}, true, fmt.Errorf("malformed tool call: %w", err)
+	}
+	if call.Name == "" {
+		return Call{}, true, errors.New("malformed tool call: missing name")
+	}
+	return call, true, nil
+}
+
+// StripCall returns the response without its tool call and anything after it
+func StripCall(response string) string {
+	if start := strings.Index(response, callOpen); start >= 0 {
+		response = response[:start]
+	}
+	return strings.TrimSpace(response)
+}
+
+// FormatResult returns the message handing a tool result, or its error, back to the model
+func FormatResult(name, result string, err error) string {
+	if err != nil {
+		return fmt.Sprintf("<tool_result name=%q error=\"true\">%s%s", name, err.Error(), resultClose)
+	}
+	return fmt.Sprintf("<tool_result name=%q>%s%s", name, result, resultClose)
+}
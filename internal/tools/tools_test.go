@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"goproduct/internal/knowledge"
+)
+
+func TestParseCall(t *testing.T) {
+	call, found, err := ParseCall(`Let me check. <tool_call>{"name": "recall", "arguments": {"query": "launch", "limit": 3}}</tool_call>`)
+	if !found || err != nil {
+		t.Fatalf("Expected a call, got found=%v err=%v", found, err)
+	}
+	if call.Name != "recall" {
+		t.Errorf("Expected recall, got %q", call.Name)
+	}
+	if limit, _ := call.Arguments.Int("limit", 5); limit != 3 {
+		t.Errorf("Expected limit 3, got %d", limit)
+	}
+
+	if _, found, _ := ParseCall("The launch is in March."); found {
+		t.Error("Expected no call in a plain answer")
+	}
+	if _, found, err := ParseCall("<tool_call>{not json</tool_call>"); !found || err == nil {
+		t.Errorf("Expected a malformed call error, got found=%v err=%v", found, err)
+	}
+	if got := StripCall("Sure. <tool_call>{}</tool_call> trailing"); got != "Sure." {
+		t.Errorf("Unexpected stripped response: %q", got)
+	}
+}
+
+func TestRegistryCall(t *testing.T) {
+	registry := NewRegistry(Tool{
+		Name:       "echo",
+		Parameters: []Parameter{{Name: "text", Type: TypeString, Required: true}},
+		Handler: func(ctx context.Context, args Arguments) (string, error) {
+			text, err := args.String("text")
+			return CallerFrom(ctx).AgentName + ": " + text, err
+		},
+	})
+
+	ctx := WithCaller(context.Background(), Caller{AgentName: "Max"})
+	result, err := registry.Call(ctx, Call{Name: "echo", Arguments: Arguments{"text": "hi"}})
+	if err != nil || result != "Max: hi" {
+		t.Errorf("Unexpected result %q, %v", result, err)
+	}
+	if _, err := registry.Call(ctx, Call{Name: "echo"}); err == nil {
+		t.Error("Expected an error for a missing required argument")
+	}
+	if _, err := registry.Call(ctx, Call{Name: "missing"}); !errors.Is(err, ErrUnknownTool) {
+		t.Errorf("Expected ErrUnknownTool, got %v", err)
+	}
+	if !strings.Contains(registry.Prompt(), "- echo") {
+		t.Errorf("Expected the prompt to describe the tool, got %q", registry.Prompt())
+	}
+	if NewRegistry().Prompt() != "" {
+		t.Error("Expected no prompt without tools")
+	}
+}
+
+func TestKnowledgeTools(t *testing.T) {
+	store, err := knowledge.NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	registry := NewRegistry(KnowledgeTools(store)...)
+	ctx := WithCaller(context.Background(), Caller{AgentName: "Max", SenderID: "user", MessageID: "m1"})
+
+	result, err := registry.Call(ctx, Call{Name: ToolRememberFact, Arguments: Arguments{
+		"content": "The checkout redesign ships in March",
+		"tags":    []interface{}{"checkout"},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to remember: %v", err)
+	}
+	id := strings.TrimPrefix(result, "Remembered as ")
+	entry, err := store.GetRecord(id)
+	if err != nil {
+		t.Fatalf("Remembered fact not stored: %v", err)
+	}
+	if entry.OwnerID != "Max" || entry.SourceID != "user" || entry.Tags[0] != RememberedTag {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+
+	result, err = registry.Call(ctx, Call{Name: ToolRecall, Arguments: Arguments{"query": "checkout"}})
+	if err != nil || !strings.Contains(result, "["+id+"]") {
+		t.Errorf("Expected recall to find the fact, got %q, %v", result, err)
+	}
+
+	if _, err := registry.Call(ctx, Call{Name: ToolUpdateFact, Arguments: Arguments{"id": id, "content": "The checkout redesign ships in April"}}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	entry, _ = store.GetRecord(id)
+	if string(entry.Content) != "The checkout redesign ships in April" || len(entry.Provenance) != 2 {
+		t.Errorf("Unexpected updated entry: %q with %d provenance steps", entry.Content, len(entry.Provenance))
+	}
+
+	if _, err := registry.Call(ctx, Call{Name: ToolForget, Arguments: Arguments{"id": id}}); err != nil {
+		t.Fatalf("Failed to forget: %v", err)
+	}
+	if result, _ := registry.Call(ctx, Call{Name: ToolRecall, Arguments: Arguments{"query": "checkout"}}); result != "Nothing found" {
+		t.Errorf("Expected a forgotten fact not to be recalled, got %q", result)
+	}
+
+	store.AddRecord(knowledge.Entry{ID: "d1", Category: knowledge.CategoryDecision, Content: []byte("Use Go")})
+	if _, err := registry.Call(ctx, Call{Name: ToolForget, Arguments: Arguments{"id": "d1"}}); err == nil {
+		t.Error("Expected decisions to be protected from forget")
+	}
+}
@@ -0,0 +1,136 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// MetadataCorrelationID is the metadata key of the correlation ID of a trace event. A
+// correlation ID is generated when a human sends a message and is shared by everything
+// that comes of it: the agent's work on it, its language model calls and the reply.
+const MetadataCorrelationID = "correlation_id"
+
+// maxEventLine bounds the length of a trace log line read back
+const maxEventLine = 16 * 1024 * 1024
+
+// correlationContextKey keys the correlation ID of a context
+type correlationContextKey struct{}
+
+// WithCorrelationID returns a context carrying the correlation ID, for the trace events of
+// the work done under it
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	if correlationID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationContextKey{}, correlationID)
+}
+
+// CorrelationIDFrom returns the correlation ID of a context, empty if it has none
+func CorrelationIDFrom(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationContextKey{}).(string)
+	return correlationID
+}
+
+// Correlate adds the correlation ID of the context to the metadata of a trace event,
+// creating the metadata if needed. The metadata is returned as is if the context has none.
+func Correlate(ctx context.Context, metadata map[string]interface{}) map[string]interface{} {
+	correlationID := CorrelationIDFrom(ctx)
+	if correlationID == "" {
+		return metadata
+	}
+	if metadata == nil {
+		metadata = make(map[string]interface{}, 1)
+	}
+	metadata[MetadataCorrelationID] = correlationID
+	return metadata
+}
+
+// CorrelationIDOf returns the correlation ID of an event, empty if it has none
+func CorrelationIDOf(event Event) string {
+	correlationID, _ := event.Metadata[MetadataCorrelationID].(string)
+	return correlationID
+}
+
+// Correlated returns the events of a correlation ID, in their order: the events recording
+// it, and the events about the messages whose publication recorded it, such as their
+// deliveries
+func Correlated(events []Event, correlationID string) []Event {
+	if correlationID == "" {
+		return nil
+	}
+	messages := make(map[string]bool)
+	for _, event := range events {
+		if event.Operation == OperationSend && event.ObjectID != "" && CorrelationIDOf(event) == correlationID {
+			messages[event.ObjectID] = true
+		}
+	}
+
+	var correlated []Event
+	for _, event := range events {
+		if CorrelationIDOf(event) == correlationID || (event.Component == ComponentMessaging && messages[event.ObjectID]) {
+			correlated = append(correlated, event)
+		}
+	}
+	return correlated
+}
+
+// ReadEvents reads the events of a trace log, as FileTracer and WriterTracer write them.
+// Lines that are not JSON events are skipped.
+func ReadEvents(r io.Reader) ([]Event, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventLine)
+
+	var events []Event
+	for scanner.Scan() {
+		var line struct {
+			Timestamp string                 `json:"timestamp"`
+			Component Component              `json:"component"`
+			Operation Operation              `json:"operation"`
+			Level     Level                  `json:"level"`
+			SourceID  string                 `json:"source_id"`
+			TargetID  string                 `json:"target_id"`
+			ObjectID  string                 `json:"object_id"`
+			Message   string                 `json:"message"`
+			Metadata  map[string]interface{} `json:"metadata"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		timestamp, _ := time.Parse(time.RFC3339Nano, line.Timestamp)
+		events = append(events, Event{
+			Timestamp: timestamp,
+			Component: line.Component,
+			Operation: line.Operation,
+			Level:     line.Level,
+			SourceID:  line.SourceID,
+			TargetID:  line.TargetID,
+			ObjectID:  line.ObjectID,
+			Message:   line.Message,
+			Metadata:  line.Metadata,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return events, fmt.Errorf("failed to read trace events: %w", err)
+	}
+	return events, nil
+}
+
+// ReadCorrelated reads the events of a correlation ID from a trace log file
+func ReadCorrelated(path, correlationID string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace log: %w", err)
+	}
+	defer file.Close()
+
+	events, err := ReadEvents(file)
+	if err != nil {
+		return nil, err
+	}
+	return Correlated(events, correlationID), nil
+}
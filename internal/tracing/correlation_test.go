@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCorrelate(t *testing.T) {
+	if metadata := Correlate(context.Background(), nil); metadata != nil {
+		t.Errorf("Expected no metadata without a correlation ID, got %v", metadata)
+	}
+	ctx := WithCorrelationID(context.Background(), "c1")
+	if got := CorrelationIDFrom(ctx); got != "c1" {
+		t.Errorf("Expected the correlation ID of the context, got %q", got)
+	}
+	metadata := Correlate(ctx, map[string]interface{}{"step": 1})
+	if metadata[MetadataCorrelationID] != "c1" || metadata["step"] != 1 {
+		t.Errorf("Expected the correlation ID added, got %v", metadata)
+	}
+}
+
+func TestReadCorrelatedEvents(t *testing.T) {
+	var log strings.Builder
+	tracer := NewWriterTracer(&log, LevelVerbose)
+	now := time.Now()
+	ctx := WithCorrelationID(context.Background(), "c1")
+	events := []Event{
+		{Timestamp: now, Component: ComponentMessaging, Operation: OperationSend, SourceID: "alice", ObjectID: "m1",
+			Metadata: map[string]interface{}{MetadataCorrelationID: "c1"}},
+		{Timestamp: now, Component: ComponentMessaging, Operation: OperationReceive, SourceID: "alice", TargetID: "andy", ObjectID: "m1"},
+		{Timestamp: now, Component: ComponentLLM, Operation: OperationGenerate, Message: "GenerateChat used 12 tokens",
+			Metadata: Correlate(ctx, map[string]interface{}{"prompt_tokens": 12})},
+		{Timestamp: now, Component: ComponentMessaging, Operation: OperationSend, SourceID: "bob", ObjectID: "m2",
+			Metadata: map[string]interface{}{MetadataCorrelationID: "c2"}},
+		{Timestamp: now, Component: ComponentMessaging, Operation: OperationReceive, SourceID: "bob", TargetID: "andy", ObjectID: "m2"},
+	}
+	for _, event := range events {
+		tracer.Trace(event)
+	}
+	log.WriteString("not an event\n")
+
+	read, err := ReadEvents(strings.NewReader(log.String()))
+	if err != nil {
+		t.Fatalf("Failed to read events: %v", err)
+	}
+	if len(read) != len(events) || !read[0].Timestamp.Equal(now) || read[1].TargetID != "andy" {
+		t.Fatalf("Expected the events back, got %+v", read)
+	}
+
+	correlated := Correlated(read, "c1")
+	if len(correlated) != 3 {
+		t.Fatalf("Expected the message, its delivery and the model call, got %+v", correlated)
+	}
+	if correlated[1].Operation != OperationReceive || correlated[2].Component != ComponentLLM {
+		t.Errorf("Expected the events in order, got %+v", correlated)
+	}
+	if got := Correlated(read, ""); got != nil {
+		t.Errorf("Expected nothing for an empty correlation ID, got %+v", got)
+	}
+}
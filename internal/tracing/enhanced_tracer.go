@@ -79,6 +79,14 @@ func (t *EnhancedTracer) Trace(event Event) error {
 	return t.tracer.Trace(event)
 }
 
+// Level returns the level of the underlying tracer, LevelInfo if it does not report one
+func (t *EnhancedTracer) Level() Level {
+	if reporter, ok := t.tracer.(LevelReporter); ok {
+		return reporter.Level()
+	}
+	return LevelInfo
+}
+
 // SetLevel sets the level of the underlying tracer
 func (t *EnhancedTracer) SetLevel(level Level) {
 	t.tracer.SetLevel(level)
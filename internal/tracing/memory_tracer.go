@@ -62,6 +62,13 @@ func (t *MemoryTracer) Close() error {
 	return nil
 }
 
+// Level returns the minimum level of events to trace
+func (t *MemoryTracer) Level() Level {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.level
+}
+
 // SetLevel sets the minimum level of events to trace
 func (t *MemoryTracer) SetLevel(level Level) {
 	t.mu.Lock()
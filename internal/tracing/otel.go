@@ -0,0 +1,485 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// otlpTracesPath is where an OTLP/HTTP collector receives spans
+const otlpTracesPath = "/v1/traces"
+
+// maxTrackedMessages bounds the messages whose span an OTelTracer remembers, for the
+// events that follow them
+const maxTrackedMessages = 10000
+
+// Span kinds of the OTLP protocol
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+	spanKindProducer = 4
+	spanKindConsumer = 5
+)
+
+// statusError is the OTLP status code of a failed span
+const statusError = 2
+
+// OTelTracerOptions contains options for creating an OTelTracer
+type OTelTracerOptions struct {
+	Endpoint      string            // OTLP/HTTP collector, e.g. http://localhost:4318
+	ServiceName   string            // Names the application in Jaeger, Tempo and the like
+	Headers       map[string]string // Sent with every export, e.g. an authorization header
+	BatchSize     int               // Spans exported at once
+	FlushInterval time.Duration
+	Timeout       time.Duration // Of an export
+	Level         Level
+}
+
+// DefaultOTelTracerOptions returns the default options for OTelTracer
+func DefaultOTelTracerOptions() OTelTracerOptions {
+	return OTelTracerOptions{
+		Endpoint:      "http://localhost:4318",
+		ServiceName:   "goproduct",
+		BatchSize:     512,
+		FlushInterval: DefaultFlushInterval,
+		Timeout:       10 * time.Second,
+		Level:         LevelDebug,
+	}
+}
+
+// spanContext identifies a span within its trace
+type spanContext struct {
+	traceID string
+	spanID  string
+}
+
+// OTelTracer exports trace events as OpenTelemetry spans to an OTLP/HTTP collector, in
+// batches. The events of a conversation share a trace: a published message is a span,
+// the parent of the spans of its deliveries and of whatever else is traced about it, and
+// a child of the message it replies to. Spans that cannot be exported are dropped.
+type OTelTracer struct {
+	url         string
+	serviceName string
+	headers     map[string]string
+	client      *http.Client
+	batchSize   int
+	stop        chan struct{}
+	sending     sync.Mutex // Held while a batch is exported, so batches go out in order
+
+	mu       sync.Mutex
+	level    Level
+	spans    []otlpSpan             // Waiting for export
+	messages map[string]spanContext // Span of each recent message by message ID, and of the first message of each correlation ID
+	order    []string               // Keys of messages, oldest first
+	closed   bool
+}
+
+// NewOTelTracer creates a tracer exporting to the collector of the options. A base
+// endpoint gets the OTLP traces path appended.
+func NewOTelTracer(options OTelTracerOptions) (*OTelTracer, error) {
+	endpoint, err := url.Parse(options.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, expected e.g. http://localhost:4318", options.Endpoint)
+	}
+	if !strings.HasSuffix(endpoint.Path, otlpTracesPath) {
+		endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + otlpTracesPath
+	}
+	defaults := DefaultOTelTracerOptions()
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaults.BatchSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = defaults.FlushInterval
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaults.Timeout
+	}
+	if options.ServiceName == "" {
+		options.ServiceName = defaults.ServiceName
+	}
+
+	tracer := &OTelTracer{
+		url:         endpoint.String(),
+		serviceName: options.ServiceName,
+		headers:     options.Headers,
+		client:      &http.Client{Timeout: options.Timeout},
+		batchSize:   options.BatchSize,
+		stop:        make(chan struct{}),
+		level:       options.Level,
+		messages:    make(map[string]spanContext),
+	}
+	go tracer.flushEvery(options.FlushInterval)
+	return tracer, nil
+}
+
+// flushEvery exports the waiting spans at the interval until the tracer is closed
+func (t *OTelTracer) flushEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = t.Flush()
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// Trace converts the event to a span if it meets the level threshold; a full batch is
+// exported in the background
+func (t *OTelTracer) Trace(event Event) error {
+	t.mu.Lock()
+	if event.Level > t.level || t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.spans = append(t.spans, t.spanLocked(event))
+	full := len(t.spans) >= t.batchSize
+	t.mu.Unlock()
+
+	if full {
+		go func() { _ = t.Flush() }()
+	}
+	return nil
+}
+
+// Flush exports the spans waiting for export
+func (t *OTelTracer) Flush() error {
+	t.sending.Lock()
+	defer t.sending.Unlock()
+
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+	return t.export(spans)
+}
+
+// Close exports the waiting spans and stops tracing
+func (t *OTelTracer) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.mu.Unlock()
+
+	close(t.stop)
+	return t.Flush()
+}
+
+// Level returns the minimum level of events to trace
+func (t *OTelTracer) Level() Level {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.level
+}
+
+// SetLevel sets the minimum level of events to trace
+func (t *OTelTracer) SetLevel(level Level) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.level = level
+}
+
+// spanLocked converts an event to a span (assumes lock is already held)
+func (t *OTelTracer) spanLocked(event Event) otlpSpan {
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	nanos := strconv.FormatInt(timestamp.UnixNano(), 10)
+
+	placed, parentID := t.correlateLocked(event)
+	span := otlpSpan{
+		TraceID:           placed.traceID,
+		SpanID:            placed.spanID,
+		ParentSpanID:      parentID,
+		Name:              string(event.Component) + "." + string(event.Operation),
+		Kind:              spanKind(event.Operation),
+		StartTimeUnixNano: nanos,
+		EndTimeUnixNano:   nanos,
+		Attributes:        eventAttributes(event),
+	}
+	if event.Message != "" {
+		span.Events = []otlpEvent{{TimeUnixNano: nanos, Name: event.Message}}
+	}
+	if event.Level == LevelError {
+		span.Status = &otlpStatus{Code: statusError, Message: event.Message}
+	}
+	return span
+}
+
+// correlateLocked places the span of an event in its trace and returns the ID of its
+// parent span, empty for a root span (assumes lock is already held). The spans of a
+// correlation ID hang off the first message that carried it.
+func (t *OTelTracer) correlateLocked(event Event) (spanContext, string) {
+	messageID := metadataString(event.Metadata, "message_id")
+	if messageID == "" {
+		messageID = event.ObjectID
+	}
+	parent, known := t.messages[messageID]
+	correlationID := CorrelationIDOf(event)
+	correlated, correlationKnown := t.messages[correlationKey(correlationID)]
+
+	// The first publication of a message is the message's span
+	if event.Component == ComponentMessaging && event.Operation == OperationSend && event.ObjectID != "" && !known {
+		placed := spanContext{traceID: conversationTraceID(conversationOf(event)), spanID: derivedID("message:"+event.ObjectID, 8)}
+		parentID := ""
+		if replied, ok := t.messages[metadataString(event.Metadata, "reply_to_id")]; ok {
+			placed.traceID, parentID = replied.traceID, replied.spanID
+		} else if correlationKnown {
+			placed.traceID, parentID = correlated.traceID, correlated.spanID
+		}
+		t.rememberLocked(event.ObjectID, placed)
+		if !correlationKnown && correlationID != "" {
+			t.rememberLocked(correlationKey(correlationID), placed)
+		}
+		return placed, parentID
+	}
+
+	switch {
+	case known:
+		return spanContext{traceID: parent.traceID, spanID: randomID(8)}, parent.spanID
+	case correlationKnown:
+		return spanContext{traceID: correlated.traceID, spanID: randomID(8)}, correlated.spanID
+	case metadataString(event.Metadata, "conversation_id") != "":
+		return spanContext{traceID: conversationTraceID(metadataString(event.Metadata, "conversation_id")), spanID: randomID(8)}, ""
+	default:
+		return spanContext{traceID: randomID(16), spanID: randomID(8)}, ""
+	}
+}
+
+// rememberLocked records the span of a message or correlation, forgetting the oldest
+// beyond maxTrackedMessages (assumes lock is already held)
+func (t *OTelTracer) rememberLocked(key string, placed spanContext) {
+	t.messages[key] = placed
+	t.order = append(t.order, key)
+	if len(t.order) > maxTrackedMessages {
+		delete(t.messages, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// export sends spans to the collector
+func (t *OTelTracer) export(spans []otlpSpan) error {
+	serviceName := t.serviceName
+	body, err := json.Marshal(otlpExport{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpValue{StringValue: &serviceName}},
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "goproduct/internal/tracing"},
+			Spans: spans,
+		}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(context.Background(), http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range t.headers {
+		request.Header.Set(name, value)
+	}
+	response, err := t.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to export %d spans: %w", len(spans), err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to export %d spans: collector answered %s", len(spans), response.Status)
+	}
+	return nil
+}
+
+// correlationKey keys the span of a correlation ID among those of the messages
+func correlationKey(correlationID string) string {
+	return "correlation:" + correlationID
+}
+
+// conversationOf returns the conversation of a published message: the conversation ID
+// of its metadata, or its sender and recipients, the same both ways
+func conversationOf(event Event) string {
+	if conversationID := metadataString(event.Metadata, "conversation_id"); conversationID != "" {
+		return conversationID
+	}
+	participants := []string{event.SourceID}
+	switch recipients := event.Metadata["recipients"].(type) {
+	case []string:
+		participants = append(participants, recipients...)
+	default:
+		if event.TargetID != "" {
+			participants = append(participants, event.TargetID)
+		}
+	}
+	sort.Strings(participants)
+	return strings.Join(participants, ",")
+}
+
+// conversationTraceID returns the trace ID of a conversation
+func conversationTraceID(conversationID string) string {
+	return derivedID("conversation:"+conversationID, 16)
+}
+
+// derivedID returns a hex ID of size bytes derived from the key, the same for the same key
+func derivedID(key string, size int) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:size])
+}
+
+// randomID returns a random hex ID of size bytes
+func randomID(size int) string {
+	id := make([]byte, size)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// metadataString returns a string value of the metadata, empty if it has none
+func metadataString(metadata map[string]interface{}, key string) string {
+	value, _ := metadata[key].(string)
+	return value
+}
+
+// spanKind returns the OTLP span kind of an operation
+func spanKind(operation Operation) int {
+	switch operation {
+	case OperationSend:
+		return spanKindProducer
+	case OperationReceive:
+		return spanKindConsumer
+	case OperationGenerate:
+		return spanKindClient
+	default:
+		return spanKindInternal
+	}
+}
+
+// eventAttributes returns the fields and metadata of an event as span attributes
+func eventAttributes(event Event) []otlpAttribute {
+	fields := map[string]interface{}{
+		"component": string(event.Component),
+		"operation": string(event.Operation),
+		"level":     event.Level.String(),
+	}
+	for key, value := range map[string]string{"source_id": event.SourceID, "target_id": event.TargetID, "object_id": event.ObjectID} {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	for key, value := range event.Metadata {
+		if _, taken := fields[key]; !taken && value != nil {
+			fields[key] = value
+		}
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	attributes := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		attributes = append(attributes, otlpAttribute{Key: key, Value: attributeValue(fields[key])})
+	}
+	return attributes
+}
+
+// attributeValue converts a metadata value to an attribute value; values without an OTLP
+// type are sent as JSON
+func attributeValue(value interface{}) otlpValue {
+	switch v := value.(type) {
+	case string:
+		return otlpValue{StringValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case int:
+		return otlpValue{IntValue: strconv.Itoa(v)}
+	case int64:
+		return otlpValue{IntValue: strconv.FormatInt(v, 10)}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	case time.Duration:
+		text := v.String()
+		return otlpValue{StringValue: &text}
+	}
+	text := fmt.Sprint(value)
+	if data, err := json.Marshal(value); err == nil {
+		text = string(data)
+	}
+	return otlpValue{StringValue: &text}
+}
+
+// OTLP/JSON messages, as the collector's /v1/traces endpoint receives them
+type (
+	otlpExport struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"` // Hex encoded, as are span IDs
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Events            []otlpEvent     `json:"events,omitempty"`
+		Status            *otlpStatus     `json:"status,omitempty"`
+	}
+	otlpEvent struct {
+		TimeUnixNano string `json:"timeUnixNano"`
+		Name         string `json:"name"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    string   `json:"intValue,omitempty"` // 64-bit integers are strings in OTLP/JSON
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
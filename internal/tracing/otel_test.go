@@ -0,0 +1,183 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector records the spans exported to it by name
+type collector struct {
+	mu     sync.Mutex
+	paths  []string
+	spans  map[string][]otlpSpan
+	status int
+}
+
+func newCollector(t *testing.T) (*collector, *httptest.Server) {
+	c := &collector{spans: make(map[string][]otlpSpan), status: http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var export otlpExport
+		if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+			t.Errorf("Failed to decode export: %v", err)
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.paths = append(c.paths, r.URL.Path)
+		for _, resource := range export.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				for _, span := range scope.Spans {
+					c.spans[span.Name] = append(c.spans[span.Name], span)
+				}
+			}
+		}
+		w.WriteHeader(c.status)
+	}))
+	t.Cleanup(server.Close)
+	return c, server
+}
+
+func newTestOTelTracer(t *testing.T, endpoint string) *OTelTracer {
+	t.Helper()
+	options := DefaultOTelTracerOptions()
+	options.Endpoint = endpoint
+	options.FlushInterval = time.Hour
+	tracer, err := NewOTelTracer(options)
+	if err != nil {
+		t.Fatalf("Failed to create tracer: %v", err)
+	}
+	t.Cleanup(func() { tracer.Close() })
+	return tracer
+}
+
+func TestOTelTracerCorrelatesConversation(t *testing.T) {
+	c, server := newCollector(t)
+	tracer := newTestOTelTracer(t, server.URL)
+
+	now := time.Now()
+	events := []Event{
+		{Timestamp: now, Component: ComponentMessaging, Operation: OperationSend, Level: LevelInfo, SourceID: "alice", ObjectID: "m1",
+			Metadata: map[string]interface{}{"recipients": []string{"andy"}}},
+		{Timestamp: now, Component: ComponentMessaging, Operation: OperationReceive, Level: LevelInfo, SourceID: "alice", TargetID: "andy", ObjectID: "m1"},
+		{Timestamp: now, Component: ComponentAgent, Operation: OperationPlan, Level: LevelDebug, SourceID: "andy", ObjectID: "m1",
+			Metadata: map[string]interface{}{"step": 1, "action": "search"}},
+		{Timestamp: now, Component: ComponentMessaging, Operation: OperationSend, Level: LevelInfo, SourceID: "andy", ObjectID: "m2",
+			Metadata: map[string]interface{}{"recipients": []string{"alice"}, "reply_to_id": "m1"}},
+		{Timestamp: now, Component: ComponentMessaging, Operation: OperationSend, Level: LevelInfo, SourceID: "andy", ObjectID: "m3",
+			Metadata: map[string]interface{}{"recipients": []string{"alice"}}},
+	}
+	for _, event := range events {
+		tracer.Trace(event)
+	}
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.paths) != 1 || c.paths[0] != otlpTracesPath {
+		t.Fatalf("Expected one export to %s, got %v", otlpTracesPath, c.paths)
+	}
+	sends, receive, plan := c.spans["messaging.send"], c.spans["messaging.receive"], c.spans["agent.plan"]
+	if len(sends) != 3 || len(receive) != 1 || len(plan) != 1 {
+		t.Fatalf("Unexpected spans %+v", c.spans)
+	}
+	message, reply, unrelated := sends[0], sends[1], sends[2]
+	if message.ParentSpanID != "" || message.Kind != spanKindProducer {
+		t.Errorf("Expected a root producer span for the message, got %+v", message)
+	}
+	for _, child := range []otlpSpan{receive[0], plan[0], reply} {
+		if child.TraceID != message.TraceID || child.ParentSpanID != message.SpanID {
+			t.Errorf("Expected a child of the message span, got %+v", child)
+		}
+	}
+	if unrelated.TraceID != message.TraceID || unrelated.ParentSpanID != "" {
+		t.Errorf("Expected a root span in the conversation's trace, got %+v", unrelated)
+	}
+	if len(message.TraceID) != 32 || len(message.SpanID) != 16 {
+		t.Errorf("Expected hex trace and span IDs, got %q and %q", message.TraceID, message.SpanID)
+	}
+}
+
+func TestOTelTracerLevelsAndErrors(t *testing.T) {
+	c, server := newCollector(t)
+	tracer := newTestOTelTracer(t, server.URL+"/")
+	tracer.SetLevel(LevelInfo)
+
+	tracer.Trace(Event{Component: ComponentLLM, Operation: OperationGenerate, Level: LevelDebug, Message: "skipped"})
+	tracer.Trace(Event{Component: ComponentLLM, Operation: OperationGenerate, Level: LevelError, Message: "model failed",
+		Metadata: map[string]interface{}{"conversation_id": "alice", "attempt": 2}})
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	c.mu.Lock()
+	spans := c.spans["llm.generate"]
+	c.status = http.StatusServiceUnavailable
+	c.mu.Unlock()
+	if len(spans) != 1 {
+		t.Fatalf("Expected the error span only, got %+v", spans)
+	}
+	span := spans[0]
+	if span.Status == nil || span.Status.Code != statusError || span.Kind != spanKindClient {
+		t.Errorf("Expected a failed client span, got %+v", span)
+	}
+	if span.TraceID != conversationTraceID("alice") {
+		t.Errorf("Expected the conversation's trace, got %s", span.TraceID)
+	}
+	found := false
+	for _, attribute := range span.Attributes {
+		if attribute.Key == "attempt" && attribute.Value.IntValue == "2" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the metadata as attributes, got %+v", span.Attributes)
+	}
+
+	tracer.Trace(Event{Component: ComponentLLM, Operation: OperationGenerate, Level: LevelError})
+	if err := tracer.Flush(); err == nil {
+		t.Error("Expected the collector's refusal to be reported")
+	}
+}
+
+func TestNewOTelTracerRejectsInvalidEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"", "localhost:4318", "ftp://collector"} {
+		options := DefaultOTelTracerOptions()
+		options.Endpoint = endpoint
+		if _, err := NewOTelTracer(options); err == nil {
+			t.Errorf("Expected %q to be rejected", endpoint)
+		}
+	}
+}
+
+func TestOTelTracerCorrelatesModelCalls(t *testing.T) {
+	c, server := newCollector(t)
+	tracer := newTestOTelTracer(t, server.URL)
+
+	tracer.Trace(Event{Component: ComponentMessaging, Operation: OperationSend, Level: LevelInfo, SourceID: "alice", ObjectID: "m1",
+		Metadata: map[string]interface{}{"recipients": []string{"andy"}, MetadataCorrelationID: "c1"}})
+	tracer.Trace(Event{Component: ComponentMessaging, Operation: OperationSend, Level: LevelInfo, SourceID: "alice", ObjectID: "m1-retry",
+		Metadata: map[string]interface{}{"recipients": []string{"andy"}, MetadataCorrelationID: "c1"}})
+	tracer.Trace(Event{Component: ComponentLLM, Operation: OperationGenerate, Level: LevelDebug,
+		Metadata: Correlate(WithCorrelationID(context.Background(), "c1"), nil)})
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sends, generate := c.spans["messaging.send"], c.spans["llm.generate"]
+	if len(sends) != 2 || len(generate) != 1 {
+		t.Fatalf("Unexpected spans %+v", c.spans)
+	}
+	for _, child := range []otlpSpan{sends[1], generate[0]} {
+		if child.TraceID != sends[0].TraceID || child.ParentSpanID != sends[0].SpanID {
+			t.Errorf("Expected a child of the first message of the correlation, got %+v", child)
+		}
+	}
+}
@@ -24,6 +24,10 @@ const (
 	ComponentEntity Component = "entity"
 	// ComponentAgent identifies the agent system
 	ComponentAgent Component = "agent"
+	// ComponentLLM identifies the language model layer
+	ComponentLLM Component = "llm"
+	// ComponentGuardrails identifies the content checks on messages
+	ComponentGuardrails Component = "guardrails"
 )
 
 // Operation identifies the type of operation being traced
@@ -44,6 +48,16 @@ const (
 	OperationJoin Operation = "join"
 	// OperationLeave identifies a leave operation (e.g., leaving a group)
 	OperationLeave Operation = "leave"
+	// OperationGenerate identifies a language model generation
+	OperationGenerate Operation = "generate"
+	// OperationRetry identifies a retry of a failed operation
+	OperationRetry Operation = "retry"
+	// OperationExpire identifies the expiry of a record
+	OperationExpire Operation = "expire"
+	// OperationInspect identifies a content check of a message
+	OperationInspect Operation = "inspect"
+	// OperationPlan identifies a step of an agent's reasoning loop
+	OperationPlan Operation = "plan"
 )
 
 // Level defines the verbosity level of tracing
@@ -62,6 +76,33 @@ const (
 	LevelVerbose
 )
 
+// levelNames are the names of the levels, in level order
+var levelNames = []string{"error", "warning", "info", "debug", "verbose"}
+
+// String returns the name of the level, e.g. "debug"
+func (l Level) String() string {
+	if l < LevelError || int(l) >= len(levelNames) {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level with the given name, ignoring case
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown trace level %q (use %s)", name, strings.Join(levelNames, ", "))
+}
+
+// LevelReporter is implemented by tracers that report the minimum level of the events
+// they trace
+type LevelReporter interface {
+	Level() Level
+}
+
 // Event represents a traceable event
 type Event struct {
 	Timestamp time.Time              `json:"timestamp"`
@@ -146,6 +187,13 @@ func (t *ConsoleTracer) Close() error {
 	return nil
 }
 
+// Level returns the minimum level of events to trace
+func (t *ConsoleTracer) Level() Level {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.level
+}
+
 // SetLevel sets the minimum level of events to trace
 func (t *ConsoleTracer) SetLevel(level Level) {
 	t.mu.Lock()
@@ -288,6 +336,13 @@ func (t *FileTracer) Close() error {
 	return t.file.Close()
 }
 
+// Level returns the minimum level of events to trace
+func (t *FileTracer) Level() Level {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.level
+}
+
 // SetLevel sets the minimum level of events to trace
 func (t *FileTracer) SetLevel(level Level) {
 	t.mu.Lock()
@@ -360,6 +415,13 @@ func (t *MultiTracer) Close() error {
 	return lastErr
 }
 
+// Level returns the minimum level of events to trace
+func (t *MultiTracer) Level() Level {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.level
+}
+
 // SetLevel sets the minimum level of events to trace
 func (t *MultiTracer) SetLevel(level Level) {
 	t.mu.Lock()
@@ -416,6 +478,13 @@ func (t *WriterTracer) Close() error {
 	return nil
 }
 
+// Level returns the minimum level of events to trace
+func (t *WriterTracer) Level() Level {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.level
+}
+
 // SetLevel sets the minimum level of events to trace
 func (t *WriterTracer) SetLevel(level Level) {
 	t.mu.Lock()
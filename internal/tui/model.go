@@ -0,0 +1,254 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// titleStyle sets the title of a pane off from its lines
+var titleStyle = lipgloss.NewStyle().Reverse(true)
+
+// Smallest terminal the panes are laid out in
+const (
+	minWidth  = 40
+	minHeight = 10
+)
+
+// Messages of the sources, which update the model like the keys pressed
+type (
+	chatEvent      []string // Lines written by the chat
+	memoryEvent    []string // Recent knowledge entries, replacing the ones shown
+	traceEvent     []string // Lines appended to the trace and log files
+	chatEndedEvent struct{ err error }
+)
+
+// pane is a titled region of the screen showing the end of its lines, which can be
+// scrolled back
+type pane struct {
+	title string
+	lines []string
+	limit int  // Lines kept
+	wrap  bool // Wrap lines longer than the width rather than cut them
+	view  viewport.Model
+}
+
+// newPane creates an empty pane
+func newPane(title string, limit int, wrap bool) pane {
+	return pane{title: title, limit: limit, wrap: wrap, view: viewport.New(0, 0)}
+}
+
+// add appends lines, dropping the oldest over the limit; a scrolled pane keeps showing
+// the same lines
+func (p *pane) add(lines ...string) {
+	atBottom := p.view.AtBottom()
+	for _, line := range lines {
+		p.lines = append(p.lines, sanitize(line))
+	}
+	dropped := 0
+	if over := len(p.lines) - p.limit; over > 0 {
+		dropped = len(p.rows(p.lines[:over]))
+		p.lines = p.lines[over:]
+	}
+	offset := p.view.YOffset - dropped
+	p.view.SetContent(strings.Join(p.rows(p.lines), "\n"))
+	if atBottom {
+		p.view.GotoBottom()
+	} else {
+		p.view.SetYOffset(offset)
+	}
+}
+
+// set replaces the lines
+func (p *pane) set(lines []string) {
+	p.lines = p.lines[:0]
+	p.add(lines...)
+	p.view.GotoBottom()
+}
+
+// resize lays the pane out in width columns and height rows, its title included
+func (p *pane) resize(width, height int) {
+	atBottom := p.view.AtBottom()
+	p.view.Width, p.view.Height = width, height-1
+	p.view.SetContent(strings.Join(p.rows(p.lines), "\n"))
+	if atBottom {
+		p.view.GotoBottom()
+	}
+}
+
+// rows returns the rows the lines take, wrapped at the width if the pane wraps and cut
+// otherwise
+func (p *pane) rows(lines []string) []string {
+	if p.view.Width <= 0 {
+		return lines
+	}
+	wrap := lipgloss.NewStyle().Width(p.view.Width)
+	rows := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if p.wrap {
+			rows = append(rows, strings.Split(wrap.Render(line), "\n")...)
+		} else {
+			rows = append(rows, fit(line, p.view.Width))
+		}
+	}
+	return rows
+}
+
+// scrolled returns how many rows the pane is scrolled back from the end
+func (p *pane) scrolled() int {
+	return max(p.view.TotalLineCount()-p.view.Height-p.view.YOffset, 0)
+}
+
+// render returns the title and rows of the pane
+func (p *pane) render() string {
+	title := " " + p.title
+	if back := p.scrolled(); back > 0 {
+		title += fmt.Sprintf(" (scrolled back %d lines, PgDn to return)", back)
+	}
+	return titleStyle.Render(fit(title, p.view.Width)) + "\n" + p.view.View()
+}
+
+// model is the state of the screen in the Elm architecture of Bubble Tea: messages
+// update it and the view is drawn from it
+type model struct {
+	chat, memory, trace pane
+	input               textinput.Model
+	width, height       int
+	submitted           chan<- string // Lines typed by the user, for the chat
+	ended               bool          // The chat ended rather than the user quit
+	err                 error         // Why the chat ended
+}
+
+// newModel creates the model of an empty screen sending the typed lines to submitted
+func newModel(prompt string, submitted chan<- string) *model {
+	input := textinput.New()
+	input.Prompt = prompt + ": "
+	input.Focus()
+
+	m := &model{
+		chat:      newPane("Chat", 2000, true),
+		memory:    newPane("Recent knowledge", 100, false),
+		trace:     newPane("Trace and log", 500, false),
+		input:     input,
+		submitted: submitted,
+	}
+	m.resize(80, 24)
+	return m
+}
+
+// Init starts the cursor blinking
+func (m *model) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// Update applies a message to the model
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.resize(msg.Width, msg.Height)
+	case tea.KeyMsg:
+		return m, m.key(msg)
+	case chatEvent:
+		m.chat.add(msg...)
+	case memoryEvent:
+		m.memory.set(msg)
+	case traceEvent:
+		m.trace.add(msg...)
+	case chatEndedEvent:
+		m.ended, m.err = true, msg.err
+		return m, tea.Quit
+	default:
+		// The cursor's blinks
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// key applies a key press; the keys that do not scroll the chat or quit edit the input
+func (m *model) key(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyCtrlC, tea.KeyCtrlD:
+		return tea.Quit
+	case tea.KeyEnter:
+		// The channel is buffered and read by the chat, so the screen is not held up
+		m.submitted <- m.input.Value()
+		m.input.Reset()
+		m.chat.view.GotoBottom()
+	case tea.KeyEsc:
+		m.input.Reset()
+	case tea.KeyUp:
+		m.chat.view.ScrollUp(1)
+	case tea.KeyDown:
+		m.chat.view.ScrollDown(1)
+	case tea.KeyPgUp:
+		m.chat.view.PageUp()
+	case tea.KeyPgDown:
+		m.chat.view.PageDown()
+	default:
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+		return cmd
+	}
+	return nil
+}
+
+// resize lays the panes out: the chat beside the memory pane, the trace pane below them
+// and the input line at the bottom
+func (m *model) resize(width, height int) {
+	m.width, m.height = width, height
+	if width < minWidth || height < minHeight {
+		return
+	}
+	traceHeight := max(height/4, 4)
+	topHeight := height - traceHeight - 1
+	chatWidth := width * 2 / 3
+	m.chat.resize(chatWidth, topHeight)
+	m.memory.resize(width-chatWidth-1, topHeight)
+	m.trace.resize(width, traceHeight)
+	m.input.Width = max(width-lipgloss.Width(m.input.Prompt)-1, 1)
+}
+
+// View draws the screen
+func (m *model) View() string {
+	if m.width < minWidth || m.height < minHeight {
+		return fmt.Sprintf("Enlarge the terminal to at least %dx%d.", minWidth, minHeight)
+	}
+	separator := strings.TrimSuffix(strings.Repeat("│\n", m.chat.view.Height+1), "\n")
+	top := lipgloss.JoinHorizontal(lipgloss.Top, m.chat.render(), separator, m.memory.render())
+	return lipgloss.JoinVertical(lipgloss.Left, top, m.trace.render(), m.input.View())
+}
+
+// escapePattern matches ANSI escape sequences, which would upset the layout
+var escapePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// sanitize removes escape sequences and control characters from a line and expands tabs
+func sanitize(line string) string {
+	line = escapePattern.ReplaceAllString(line, "")
+	line = strings.ReplaceAll(line, "\t", "    ")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, line)
+}
+
+// fit pads or cuts a line to exactly width runes
+func fit(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) > width {
+		if width > 0 {
+			runes = append(runes[:width-1], '…')
+		}
+		return string(runes)
+	}
+	return line + strings.Repeat(" ", width-len(runes))
+}
@@ -0,0 +1,141 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"goproduct/internal/knowledge"
+)
+
+// tailBacklog is how much of the end of a file the trace pane starts with
+const tailBacklog = 8 * 1024
+
+// watchMemory posts the recent knowledge entries when the interface starts and again
+// after each change to the store
+func (a *App) watchMemory(ctx context.Context) {
+	changes, cancel := a.store.Watch(knowledge.Filter{})
+	defer cancel()
+
+	a.post(ctx, memoryEvent(a.recentEntries()))
+	for {
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+			// Changes made together, such as a batch, are shown once
+			for drained := false; !drained; {
+				select {
+				case _, ok = <-changes:
+					if !ok {
+						return
+					}
+				default:
+					drained = true
+				}
+			}
+			a.post(ctx, memoryEvent(a.recentEntries()))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// recentEntries lists the most recently updated entries of the store, newest first.
+// Conversation messages are left out, the chat pane showing them already.
+func (a *App) recentEntries() []string {
+	entries, err := a.store.SearchRecords(knowledge.Filter{})
+	if err != nil {
+		a.logger.Error("Failed to list recent knowledge", "error", err)
+		return []string{fmt.Sprintf("Failed to list knowledge: %v", err)}
+	}
+	recent := entries[:0]
+	for _, entry := range entries {
+		if entry.Category != knowledge.CategoryMessage {
+			recent = append(recent, entry)
+		}
+	}
+	sort.Slice(recent, func(i, j int) bool {
+		return recent[i].UpdatedAt.After(recent[j].UpdatedAt)
+	})
+	if len(recent) > a.memoryRows {
+		recent = recent[:a.memoryRows]
+	}
+
+	lines := make([]string, 0, len(recent))
+	for _, entry := range recent {
+		content := strings.Join(strings.Fields(string(entry.Content)), " ")
+		lines = append(lines, fmt.Sprintf("%s %s: %s", entry.UpdatedAt.Format("15:04"), entry.Category, content))
+	}
+	// The pane shows the end of its lines, so the newest goes last
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// tail posts the lines appended to a file, starting with the end of what it holds. A
+// file that does not exist yet is waited for, and one that is truncated is read again
+// from the start.
+func (a *App) tail(ctx context.Context, path string) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	offset := int64(-1) // The file has not been read yet
+	var partial string
+	midLine := false // The backlog starts in the middle of a line
+	for {
+		if info, err := os.Stat(path); err == nil {
+			size := info.Size()
+			switch {
+			case offset < 0:
+				offset = max(size-tailBacklog, 0)
+				midLine = offset > 0
+			case size < offset:
+				offset, partial = 0, ""
+			}
+			if size > offset {
+				text, err := readRange(path, offset, size)
+				if err != nil {
+					a.logger.Warn("Failed to tail file", "path", path, "error", err)
+				} else {
+					if midLine {
+						text = text[strings.IndexByte(text, '\n')+1:]
+						midLine = false
+					}
+					offset = size
+					lines := strings.Split(partial+text, "\n")
+					partial = lines[len(lines)-1]
+					if complete := lines[:len(lines)-1]; len(complete) > 0 {
+						a.post(ctx, traceEvent(complete))
+					}
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// readRange returns the bytes of a file from start to end
+func readRange(path string, start, end int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	data := make([]byte, end-start)
+	if _, err := file.ReadAt(data, start); err != nil && err != io.EOF {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,200 @@
+// Package tui runs the chat full screen: the conversation with the agent in one pane,
+// the knowledge entries it is adding or updating in another and the end of the trace and
+// log files in a third, with the message being typed at the bottom. The chat, its
+// entities and the message bus are wired as for the prompt; the application only draws
+// them, as a Bubble Tea program.
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/chzyer/readline"
+
+	"goproduct/internal/knowledge"
+	"goproduct/internal/logging"
+)
+
+// ErrNotTerminal is returned by Run when the input or output is not a terminal
+var ErrNotTerminal = errors.New("the full-screen interface needs a terminal")
+
+// Chat is the conversation shown in the chat pane, e.g. a chat.EnhancedChat. It reads
+// the lines the user typed from in until it ends, and writes to out.
+type Chat interface {
+	StartWithIO(in io.Reader, out io.Writer) error
+}
+
+// App is the full-screen interface. The zero value is not usable, use New.
+type App struct {
+	chat       Chat
+	prompt     string
+	store      knowledge.Store // Optional source of the memory pane
+	memoryRows int             // Entries shown in the memory pane
+	logPaths   []string        // Files tailed in the trace pane
+	interval   time.Duration   // How often the tailed files are checked
+	in, out    *os.File
+	logger     *logging.Logger
+	events     chan any // Messages of the chat and the sources for the program
+}
+
+// Option is a function that configures an App
+type Option func(*App)
+
+// WithKnowledgeStore shows the most recently updated entries of the store, other than
+// conversation messages, in the memory pane
+func WithKnowledgeStore(store knowledge.Store) Option {
+	return func(a *App) {
+		a.store = store
+	}
+}
+
+// WithLogTail shows the lines appended to the file in the trace pane; it can be given
+// for several files
+func WithLogTail(path string) Option {
+	return func(a *App) {
+		if path != "" {
+			a.logPaths = append(a.logPaths, path)
+		}
+	}
+}
+
+// WithPrompt sets the label of the input line, "You" by default
+func WithPrompt(prompt string) Option {
+	return func(a *App) {
+		a.prompt = prompt
+	}
+}
+
+// WithTerminal sets the terminal of the application, os.Stdin and os.Stdout by default
+func WithTerminal(in, out *os.File) Option {
+	return func(a *App) {
+		a.in, a.out = in, out
+	}
+}
+
+// New creates the full-screen interface of the chat
+func New(chat Chat, opts ...Option) *App {
+	a := &App{
+		chat:       chat,
+		prompt:     "You",
+		memoryRows: 50,
+		interval:   500 * time.Millisecond,
+		in:         os.Stdin,
+		out:        os.Stdout,
+		logger:     logging.Get().Component("tui"),
+		events:     make(chan any, 256),
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Run shows the interface until the user quits with Ctrl+C or Ctrl+D, the chat ends,
+// e.g. with /exit, or the context is cancelled. The terminal is restored on return.
+func (a *App) Run(ctx context.Context) error {
+	if !readline.IsTerminal(int(a.in.Fd())) || !readline.IsTerminal(int(a.out.Fd())) {
+		return ErrNotTerminal
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The chat reads the submitted lines in order from a pipe and writes to the chat pane
+	chatIn, submitted := io.Pipe()
+	lines := make(chan string, 64)
+	go func() {
+		defer submitted.Close()
+		for {
+			select {
+			case line := <-lines:
+				if _, err := io.WriteString(submitted, line+"\n"); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		err := a.chat.StartWithIO(chatIn, &lineWriter{post: func(lines []string) { a.post(ctx, chatEvent(lines)) }})
+		a.post(ctx, chatEndedEvent{err: err})
+	}()
+
+	if a.store != nil {
+		go a.watchMemory(ctx)
+	}
+	for _, path := range a.logPaths {
+		go a.tail(ctx, path)
+	}
+
+	m := newModel(a.prompt, lines)
+	program := tea.NewProgram(m,
+		tea.WithContext(ctx),
+		tea.WithInput(a.in),
+		tea.WithOutput(a.out),
+		tea.WithAltScreen())
+	go func() {
+		for {
+			select {
+			case event := <-a.events:
+				program.Send(event)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	a.logger.Info("Full-screen interface started", "log_tails", len(a.logPaths))
+	if _, err := program.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("full-screen interface failed: %w", err)
+	}
+	if m.ended {
+		a.logger.Info("Full-screen interface closed as the chat ended", "error", m.err)
+		return m.err
+	}
+	a.logger.Info("Full-screen interface closed by the user")
+	return nil
+}
+
+// post sends an event to the program, unless the application is closing
+func (a *App) post(ctx context.Context, event any) {
+	select {
+	case a.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+// lineWriter passes the complete lines written to it to post, holding back a partial
+// last line until it is ended
+type lineWriter struct {
+	post    func(lines []string)
+	mutex   sync.Mutex
+	partial string
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	text := w.partial + string(p)
+	lines := strings.Split(text, "\n")
+	w.partial = lines[len(lines)-1]
+	w.mutex.Unlock()
+
+	if complete := lines[:len(lines)-1]; len(complete) > 0 {
+		w.post(complete)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,165 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"goproduct/internal/knowledge"
+)
+
+// ansiPattern matches the escape sequences of the view
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// screen returns the rows of the view as a terminal shows them
+func screen(m *model) []string {
+	return strings.Split(ansiPattern.ReplaceAllString(m.View(), ""), "\n")
+}
+
+// typed returns the key presses of typing the text
+func typed(text string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)}
+}
+
+func TestView(t *testing.T) {
+	m := newModel("Ana", make(chan string, 1))
+	m.Update(tea.WindowSizeMsg{Width: 60, Height: 12})
+	m.Update(chatEvent{"User: What is next?", "Andy: Ship the beta to design partners, then gather feedback.", "\x1b[1mdone\x1b[0m"})
+	m.Update(memoryEvent{"09:30 fact: Beta launches March 3rd"})
+	m.Update(traceEvent{"INFO agent started"})
+	m.Update(typed("Thanks"))
+
+	rows := screen(m)
+	if len(rows) != 12 {
+		t.Fatalf("Expected 12 rows, got %d:\n%s", len(rows), strings.Join(rows, "\n"))
+	}
+	want := map[int]string{
+		0:  " Chat                                   │ Recent knowledge",
+		1:  "User: What is next?                     │09:30 fact: Beta l…",
+		2:  "Andy: Ship the beta to design partners, │",
+		3:  "then gather feedback.                   │",
+		4:  "done                                    │",
+		7:  " Trace and log",
+		8:  "INFO agent started",
+		11: "Ana: Thanks",
+	}
+	for row, text := range want {
+		if got := strings.TrimRight(rows[row], " "); got != text {
+			t.Errorf("Row %d: expected %q, got %q", row, text, got)
+		}
+	}
+
+	m.Update(tea.WindowSizeMsg{Width: 20, Height: 5})
+	if !strings.Contains(m.View(), "Enlarge the terminal") {
+		t.Error("Expected a small terminal to be reported")
+	}
+}
+
+func TestUpdateKeys(t *testing.T) {
+	submitted := make(chan string, 1)
+	m := newModel("Ana", submitted)
+	for _, msg := range []tea.KeyMsg{typed("hé"), {Type: tea.KeyBackspace}, typed("y"), {Type: tea.KeyUp}, typed("ok"), {Type: tea.KeyEnter}} {
+		m.Update(msg)
+	}
+	if line := <-submitted; line != "hyok" {
+		t.Errorf("Expected \"hyok\" submitted, got %q", line)
+	}
+	if m.input.Value() != "" {
+		t.Errorf("Expected the input cleared on Enter, got %q", m.input.Value())
+	}
+
+	m.Update(chatEvent(strings.Split(strings.Repeat("line\n", 30), "\n")))
+	m.Update(tea.KeyMsg{Type: tea.KeyPgUp})
+	if m.chat.scrolled() == 0 {
+		t.Error("Expected PgUp to scroll the chat back")
+	}
+	m.Update(chatEvent{"new"})
+	scrolled := m.chat.scrolled()
+	m.Update(tea.KeyMsg{Type: tea.KeyPgDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyPgDown})
+	if scrolled == 0 || m.chat.scrolled() != 0 {
+		t.Errorf("Expected the chat scrolled back until PgDn, got %d then %d", scrolled, m.chat.scrolled())
+	}
+
+	m.Update(typed("draft"))
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.input.Value() != "" {
+		t.Errorf("Expected Esc to clear the input, got %q", m.input.Value())
+	}
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlC}); cmd == nil || cmd() != tea.Quit() {
+		t.Error("Expected Ctrl+C to quit")
+	}
+	if _, cmd := m.Update(chatEndedEvent{}); cmd == nil || cmd() != tea.Quit() || !m.ended {
+		t.Error("Expected the end of the chat to quit")
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	var got []string
+	w := &lineWriter{post: func(lines []string) { got = append(got, lines...) }}
+	w.Write([]byte("Message sent [1234]\nAndy: "))
+	w.Write([]byte("Hello\n\n"))
+	if strings.Join(got, "|") != "Message sent [1234]|Andy: Hello|" {
+		t.Errorf("Unexpected lines: %q", got)
+	}
+}
+
+func TestSources(t *testing.T) {
+	store, _ := knowledge.NewMemoryStore()
+	now := time.Now()
+	store.AddRecords(
+		knowledge.Entry{ID: "1", Category: knowledge.CategoryFact, Content: []byte("Older fact"), CreatedAt: now, UpdatedAt: now.Add(-time.Hour)},
+		knowledge.Entry{ID: "2", Category: knowledge.CategoryMessage, Content: []byte("A message"), CreatedAt: now, UpdatedAt: now},
+	)
+	path := filepath.Join(t.TempDir(), "trace.log")
+	os.WriteFile(path, []byte(strings.Repeat("x", tailBacklog)+"\nfirst\npart"), 0644)
+
+	a := New(nil, WithKnowledgeStore(store), WithLogTail(path))
+	a.interval = 5 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.watchMemory(ctx)
+
+	// next returns the next event of the type
+	next := func(match func(any) bool) any {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case event := <-a.events:
+				if match(event) {
+					return event
+				}
+			case <-timeout:
+				t.Fatal("Timed out waiting for an event")
+			}
+		}
+	}
+	isMemory := func(event any) bool { _, ok := event.(memoryEvent); return ok }
+	isTrace := func(event any) bool { _, ok := event.(traceEvent); return ok }
+
+	if got := next(isMemory).(memoryEvent); len(got) != 1 || !strings.HasSuffix(got[0], "fact: Older fact") {
+		t.Errorf("Expected the fact without the message, got %q", got)
+	}
+	store.AddRecord(knowledge.Entry{ID: "3", Category: knowledge.CategoryFact, Content: []byte("New\nfact"), CreatedAt: now, UpdatedAt: now})
+	if got := next(isMemory).(memoryEvent); len(got) != 2 || !strings.HasSuffix(got[1], "fact: New fact") {
+		t.Errorf("Expected the new fact last, got %q", got)
+	}
+
+	go a.tail(ctx, path)
+	if got := next(isTrace).(traceEvent); len(got) != 1 || got[0] != "first" {
+		t.Errorf("Expected the backlog from its first whole line, got %q", got)
+	}
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString("ial\nsecond\n")
+	file.Close()
+	if got := next(isTrace).(traceEvent); strings.Join(got, "|") != "partial|second" {
+		t.Errorf("Expected the appended lines, got %q", got)
+	}
+}